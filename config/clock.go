@@ -0,0 +1,37 @@
+package config
+
+import (
+	"time"
+
+	"github.com/libp2p/go-libp2p/p2p/net/swarm"
+
+	"github.com/benbjohnson/clock"
+)
+
+// swarmClock adapts a clock.Clock to the swarm.Clock interface.
+type swarmClock struct {
+	clock.Clock
+}
+
+var _ swarm.Clock = swarmClock{}
+
+func (c swarmClock) InstantTimer(when time.Time) swarm.InstantTimer {
+	return &swarmTimer{cl: c.Clock, t: c.Clock.Timer(c.Clock.Until(when))}
+}
+
+type swarmTimer struct {
+	cl clock.Clock
+	t  *clock.Timer
+}
+
+func (t *swarmTimer) Reset(when time.Time) bool {
+	return t.t.Reset(t.cl.Until(when))
+}
+
+func (t *swarmTimer) Stop() bool {
+	return t.t.Stop()
+}
+
+func (t *swarmTimer) Ch() <-chan time.Time {
+	return t.t.C
+}
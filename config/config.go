@@ -5,6 +5,9 @@ import (
 	"crypto/rand"
 	"errors"
 	"fmt"
+	"log/slog"
+	mrand "math/rand"
+	"slices"
 	"time"
 
 	"github.com/libp2p/go-libp2p/core/connmgr"
@@ -25,18 +28,23 @@ import (
 	"github.com/libp2p/go-libp2p/p2p/host/autorelay"
 	bhost "github.com/libp2p/go-libp2p/p2p/host/basic"
 	blankhost "github.com/libp2p/go-libp2p/p2p/host/blank"
+	"github.com/libp2p/go-libp2p/p2p/host/bootstrap"
 	"github.com/libp2p/go-libp2p/p2p/host/eventbus"
+	"github.com/libp2p/go-libp2p/p2p/host/peerscore"
 	"github.com/libp2p/go-libp2p/p2p/host/peerstore/pstoremem"
 	rcmgr "github.com/libp2p/go-libp2p/p2p/host/resource-manager"
 	routed "github.com/libp2p/go-libp2p/p2p/host/routed"
+	"github.com/libp2p/go-libp2p/p2p/net/dnsresolver"
 	"github.com/libp2p/go-libp2p/p2p/net/swarm"
 	tptu "github.com/libp2p/go-libp2p/p2p/net/upgrader"
 	circuitv2 "github.com/libp2p/go-libp2p/p2p/protocol/circuitv2/client"
 	relayv2 "github.com/libp2p/go-libp2p/p2p/protocol/circuitv2/relay"
 	"github.com/libp2p/go-libp2p/p2p/protocol/holepunch"
+	"github.com/libp2p/go-libp2p/p2p/protocol/ping"
 	"github.com/libp2p/go-libp2p/p2p/transport/quicreuse"
 	"github.com/prometheus/client_golang/prometheus"
 
+	"github.com/benbjohnson/clock"
 	ma "github.com/multiformats/go-multiaddr"
 	madns "github.com/multiformats/go-multiaddr-dns"
 	"github.com/quic-go/quic-go"
@@ -48,6 +56,9 @@ import (
 // returns the set of multiaddrs we should advertise to the network.
 type AddrsFactory = bhost.AddrsFactory
 
+// AddrScorer scores the multiaddrs we might advertise to the network.
+type AddrScorer = bhost.AddrScorer
+
 // NATManagerC is a NATManager constructor.
 type NATManagerC func(network.Network) bhost.NATManager
 
@@ -60,6 +71,9 @@ type AutoNATConfig struct {
 	ThrottleGlobalLimit int
 	ThrottlePeerLimit   int
 	ThrottleInterval    time.Duration
+	// ConcurrencyLimit caps the number of parallel dial-backs performed by
+	// the service. 0 means no limit.
+	ConcurrencyLimit int
 }
 
 type Security struct {
@@ -92,6 +106,11 @@ type Config struct {
 	Insecure           bool
 	PSK                pnet.PSK
 
+	// DisableEarlyMuxerNegotiation stops the security transports from
+	// negotiating the stream muxer during the handshake. The muxer is then
+	// negotiated using multistream after the handshake.
+	DisableEarlyMuxerNegotiation bool
+
 	DialTimeout time.Duration
 
 	RelayCustom bool
@@ -104,6 +123,12 @@ type Config struct {
 	AddrsFactory    bhost.AddrsFactory
 	ConnectionGater connmgr.ConnectionGater
 
+	AddrScorers        map[string]bhost.AddrScorer
+	MaxAdvertisedAddrs int
+	// DisableAddrWithdrawal keeps advertising addresses that AutoNAT found
+	// unreachable.
+	DisableAddrWithdrawal bool
+
 	ConnManager     connmgr.ConnManager
 	ResourceManager network.ResourceManager
 
@@ -112,6 +137,9 @@ type Config struct {
 	Reporter   metrics.Reporter
 
 	MultiaddrResolver *madns.Resolver
+	// DNSResolverOpts configures the caching DNS resolver used to resolve
+	// multiaddrs, if MultiaddrResolver isn't set.
+	DNSResolverOpts []dnsresolver.Option
 
 	DisablePing bool
 
@@ -126,19 +154,109 @@ type Config struct {
 
 	DisableMetrics       bool
 	PrometheusRegisterer prometheus.Registerer
+	// MetricsSubsystems restricts metrics to the given subsystems.
+	// If nil, metrics are enabled for all subsystems, unless DisableMetrics is set.
+	MetricsSubsystems map[MetricsSubsystem]struct{}
 
 	DialRanker network.DialRanker
 
+	// AddrTranslator translates addresses of peers before dialing them, and
+	// observed addresses used by identify.
+	AddrTranslator network.AddrTranslator
+
 	SwarmOpts []swarm.Option
 
+	UpgraderOpts []tptu.Option
+
 	DisableIdentifyAddressDiscovery bool
 
+	// IdentifyPushConcurrency and IdentifyPushRoundBudget configure how
+	// identify pushes are scheduled. See IdentifyPushScheduling.
+	IdentifyPushConcurrency int
+	IdentifyPushRoundBudget time.Duration
+
 	EnableAutoNATv2 bool
 
 	UDPBlackHoleSuccessCounter        *swarm.BlackHoleSuccessCounter
 	CustomUDPBlackHoleSuccessCounter  bool
 	IPv6BlackHoleSuccessCounter       *swarm.BlackHoleSuccessCounter
 	CustomIPv6BlackHoleSuccessCounter bool
+
+	// Clock is the clock used by time-dependent services. If nil, the real clock is used.
+	Clock clock.Clock
+
+	// RandSource is the source of randomness used by services that make random
+	// choices, like relay and AutoNAT peer selection. If nil, these services
+	// are randomly seeded.
+	RandSource mrand.Source
+
+	// Logger is the logger used by the swarm, identify and the relay service.
+	// If nil, these subsystems log to go-log.
+	Logger *slog.Logger
+
+	StreamOpenLimits []bhost.StreamOpenLimit
+
+	BootstrapPeers []peer.AddrInfo
+	BootstrapOpts  []bootstrap.Option
+
+	EnableKeepAlive bool
+	KeepAliveOpts   []ping.KeepAliveOption
+
+	// PeerScorer, if set, collects feedback about peers from libp2p services.
+	PeerScorer *peerscore.Scorer
+}
+
+// MetricsSubsystem is a subsystem that exports Prometheus metrics.
+type MetricsSubsystem string
+
+const (
+	MetricsSwarm           MetricsSubsystem = "swarm"
+	MetricsIdentify        MetricsSubsystem = "identify"
+	MetricsAutoNAT         MetricsSubsystem = "autonat"
+	MetricsRelayService    MetricsSubsystem = "relay"
+	MetricsAutoRelay       MetricsSubsystem = "autorelay"
+	MetricsHolePunch       MetricsSubsystem = "holepunch"
+	MetricsEventBus        MetricsSubsystem = "eventbus"
+	MetricsResourceManager MetricsSubsystem = "rcmgr"
+	MetricsConnManager     MetricsSubsystem = "connmgr"
+	MetricsStreamLimiter   MetricsSubsystem = "streamlimiter"
+	MetricsDNS             MetricsSubsystem = "dns"
+	MetricsUpgrader        MetricsSubsystem = "upgrader"
+)
+
+// MetricsEnabled returns true if metrics are enabled for the given subsystem.
+func (cfg *Config) MetricsEnabled(s MetricsSubsystem) bool {
+	if cfg.DisableMetrics {
+		return false
+	}
+	if cfg.MetricsSubsystems == nil {
+		return true
+	}
+	_, ok := cfg.MetricsSubsystems[s]
+	return ok
+}
+
+// newRandSource returns a source of randomness for a single service, derived
+// from RandSource, so that services don't share a source and the values each
+// of them draws don't depend on the order they run in. It returns nil if
+// RandSource isn't set.
+func (cfg *Config) newRandSource() mrand.Source {
+	if cfg.RandSource == nil {
+		return nil
+	}
+	return mrand.NewSource(cfg.RandSource.Int63())
+}
+
+// disabledHostMetrics returns the set of subsystems managed by the BasicHost
+// that metrics are disabled for.
+func (cfg *Config) disabledHostMetrics() map[string]struct{} {
+	disabled := make(map[string]struct{})
+	for _, s := range []MetricsSubsystem{MetricsIdentify, MetricsHolePunch, MetricsRelayService, MetricsAutoNAT, MetricsStreamLimiter} {
+		if !cfg.MetricsEnabled(s) {
+			disabled[string(s)] = struct{}{}
+		}
+	}
+	return disabled
 }
 
 func (cfg *Config) makeSwarm(eventBus event.Bus, enableMetrics bool) (*swarm.Swarm, error) {
@@ -195,6 +313,15 @@ func (cfg *Config) makeSwarm(eventBus event.Bus, enableMetrics bool) (*swarm.Swa
 	if cfg.DialRanker != nil {
 		opts = append(opts, swarm.WithDialRanker(cfg.DialRanker))
 	}
+	if cfg.AddrTranslator != nil {
+		opts = append(opts, swarm.WithAddrTranslator(cfg.AddrTranslator))
+	}
+	if cfg.Clock != nil {
+		opts = append(opts, swarm.WithClock(swarmClock{cfg.Clock}))
+	}
+	if cfg.Logger != nil {
+		opts = append(opts, swarm.WithLogger(cfg.Logger))
+	}
 
 	if enableMetrics {
 		opts = append(opts,
@@ -215,19 +342,23 @@ func (cfg *Config) makeAutoNATV2Host() (host.Host, error) {
 	}
 
 	autoNatCfg := Config{
-		Transports:                  cfg.Transports,
-		Muxers:                      cfg.Muxers,
-		SecurityTransports:          cfg.SecurityTransports,
-		Insecure:                    cfg.Insecure,
-		PSK:                         cfg.PSK,
-		ConnectionGater:             cfg.ConnectionGater,
-		Reporter:                    cfg.Reporter,
-		PeerKey:                     autonatPrivKey,
-		Peerstore:                   ps,
-		DialRanker:                  swarm.NoDelayDialRanker,
-		UDPBlackHoleSuccessCounter:  cfg.UDPBlackHoleSuccessCounter,
-		IPv6BlackHoleSuccessCounter: cfg.IPv6BlackHoleSuccessCounter,
-		ResourceManager:             cfg.ResourceManager,
+		Transports:                   cfg.Transports,
+		Muxers:                       cfg.Muxers,
+		SecurityTransports:           cfg.SecurityTransports,
+		Insecure:                     cfg.Insecure,
+		PSK:                          cfg.PSK,
+		DisableEarlyMuxerNegotiation: cfg.DisableEarlyMuxerNegotiation,
+		ConnectionGater:              cfg.ConnectionGater,
+		Reporter:                     cfg.Reporter,
+		PeerKey:                      autonatPrivKey,
+		Peerstore:                    ps,
+		DialRanker:                   swarm.NoDelayDialRanker,
+		UDPBlackHoleSuccessCounter:   cfg.UDPBlackHoleSuccessCounter,
+		IPv6BlackHoleSuccessCounter:  cfg.IPv6BlackHoleSuccessCounter,
+		ResourceManager:              cfg.ResourceManager,
+		Logger:                       cfg.Logger,
+		AddrTranslator:               cfg.AddrTranslator,
+		DisableMetrics:               true,
 		SwarmOpts: []swarm.Option{
 			// Don't update black hole state for failed autonat dials
 			swarm.WithReadOnlyBlackHoleDetector(),
@@ -276,9 +407,19 @@ func (cfg *Config) makeAutoNATV2Host() (host.Host, error) {
 }
 
 func (cfg *Config) addTransports() ([]fx.Option, error) {
+	upgraderOpts := slices.Clone(cfg.UpgraderOpts)
+	if cfg.MetricsEnabled(MetricsUpgrader) {
+		upgraderOpts = append(upgraderOpts,
+			tptu.WithMetricsTracer(tptu.NewMetricsTracer(tptu.WithRegisterer(cfg.PrometheusRegisterer))))
+	}
 	fxopts := []fx.Option{
 		fx.WithLogger(func() fxevent.Logger { return getFXLogger() }),
-		fx.Provide(fx.Annotate(tptu.New, fx.ParamTags(`name:"security"`))),
+		fx.Provide(fx.Annotate(
+			func(security []sec.SecureTransport, muxers []tptu.StreamMuxer, psk pnet.PSK, rcmgr network.ResourceManager, gater connmgr.ConnectionGater) (transport.Upgrader, error) {
+				return tptu.New(security, muxers, psk, rcmgr, gater, upgraderOpts...)
+			},
+			fx.ParamTags(`name:"security"`),
+		)),
 		fx.Supply(cfg.Muxers),
 		fx.Provide(func() connmgr.ConnectionGater { return cfg.ConnectionGater }),
 		fx.Provide(func() pnet.PSK { return cfg.PSK }),
@@ -301,10 +442,11 @@ func (cfg *Config) addTransports() ([]fx.Option, error) {
 		// fx groups are unordered, but we need to preserve the order of the security transports
 		// First of all, we construct the security transports that are needed,
 		// and save them to a group call security_unordered.
+		var secopts []fx.Option
 		for _, s := range cfg.SecurityTransports {
 			fxName := fmt.Sprintf(`name:"security_%s"`, s.ID)
-			fxopts = append(fxopts, fx.Supply(fx.Annotate(s.ID, fx.ResultTags(fxName))))
-			fxopts = append(fxopts,
+			secopts = append(secopts, fx.Supply(fx.Annotate(s.ID, fx.ResultTags(fxName))))
+			secopts = append(secopts,
 				fx.Provide(fx.Annotate(
 					s.Constructor,
 					fx.ParamTags(fxName),
@@ -313,6 +455,12 @@ func (cfg *Config) addTransports() ([]fx.Option, error) {
 				)),
 			)
 		}
+		if cfg.DisableEarlyMuxerNegotiation {
+			// Security transports only negotiate the muxers they're passed during the handshake.
+			// The decorator only applies within the module, the upgrader still uses all muxers.
+			secopts = append(secopts, fx.Decorate(func([]tptu.StreamMuxer) []tptu.StreamMuxer { return nil }))
+		}
+		fxopts = append(fxopts, fx.Module("security", secopts...))
 		// Then we consume the group security_unordered, and order them by the user's preference.
 		fxopts = append(fxopts, fx.Provide(
 			fx.Annotate(
@@ -384,7 +532,11 @@ func (cfg *Config) newBasicHost(swrm *swarm.Swarm, eventBus event.Bus) (*bhost.B
 	h, err := bhost.NewHost(swrm, &bhost.HostOpts{
 		EventBus:                        eventBus,
 		ConnManager:                     cfg.ConnManager,
+		ConnectionGater:                 cfg.ConnectionGater,
 		AddrsFactory:                    cfg.AddrsFactory,
+		AddrScorers:                     cfg.AddrScorers,
+		MaxAdvertisedAddrs:              cfg.MaxAdvertisedAddrs,
+		DisableAddrWithdrawal:           cfg.DisableAddrWithdrawal,
 		NATManager:                      cfg.NATManager,
 		EnablePing:                      !cfg.DisablePing,
 		UserAgent:                       cfg.UserAgent,
@@ -394,10 +546,18 @@ func (cfg *Config) newBasicHost(swrm *swarm.Swarm, eventBus event.Bus) (*bhost.B
 		EnableRelayService:              cfg.EnableRelayService,
 		RelayServiceOpts:                cfg.RelayServiceOpts,
 		EnableMetrics:                   !cfg.DisableMetrics,
+		DisabledMetrics:                 cfg.disabledHostMetrics(),
 		PrometheusRegisterer:            cfg.PrometheusRegisterer,
 		DisableIdentifyAddressDiscovery: cfg.DisableIdentifyAddressDiscovery,
+		IdentifyPushConcurrency:         cfg.IdentifyPushConcurrency,
+		IdentifyPushRoundBudget:         cfg.IdentifyPushRoundBudget,
 		EnableAutoNATv2:                 cfg.EnableAutoNATv2,
 		AutoNATv2Dialer:                 autonatv2Dialer,
+		Clock:                           cfg.Clock,
+		Logger:                          cfg.Logger,
+		StreamOpenLimits:                cfg.StreamOpenLimits,
+		AddrTranslator:                  cfg.AddrTranslator,
+		RandSource:                      cfg.newRandSource(),
 	})
 	if err != nil {
 		return nil, err
@@ -431,16 +591,41 @@ func (cfg *Config) NewNode() (host.Host, error) {
 		}
 	}
 
-	if !cfg.DisableMetrics {
+	if cfg.MetricsEnabled(MetricsResourceManager) {
 		rcmgr.MustRegisterWith(cfg.PrometheusRegisterer)
 	}
 
+	if cfg.DNSResolverOpts != nil {
+		if cfg.MultiaddrResolver != nil {
+			cfg.closeOwnedComponents()
+			return nil, errors.New("cannot configure both a multiaddr resolver and a DNS resolver")
+		}
+		var opts []dnsresolver.Option
+		if cfg.MetricsEnabled(MetricsDNS) {
+			opts = append(opts, dnsresolver.WithMetricsTracer(dnsresolver.NewMetricsTracer(dnsresolver.WithRegisterer(cfg.PrometheusRegisterer))))
+		}
+		opts = append(opts, cfg.DNSResolverOpts...)
+		r, err := dnsresolver.New(opts...)
+		if err != nil {
+			cfg.closeOwnedComponents()
+			return nil, fmt.Errorf("failed to create DNS resolver: %w", err)
+		}
+		cfg.MultiaddrResolver, err = madns.NewResolver(madns.WithDefaultResolver(r))
+		if err != nil {
+			cfg.closeOwnedComponents()
+			return nil, err
+		}
+	}
+
 	fxopts := []fx.Option{
 		fx.Provide(func() event.Bus {
+			if !cfg.MetricsEnabled(MetricsEventBus) {
+				return eventbus.NewBus()
+			}
 			return eventbus.NewBus(eventbus.WithMetricsTracer(eventbus.NewMetricsTracer(eventbus.WithRegisterer(cfg.PrometheusRegisterer))))
 		}),
 		fx.Provide(func(eventBus event.Bus, lifecycle fx.Lifecycle) (*swarm.Swarm, error) {
-			sw, err := cfg.makeSwarm(eventBus, !cfg.DisableMetrics)
+			sw, err := cfg.makeSwarm(eventBus, cfg.MetricsEnabled(MetricsSwarm))
 			if err != nil {
 				return nil, err
 			}
@@ -469,6 +654,19 @@ func (cfg *Config) NewNode() (host.Host, error) {
 		}),
 		fx.Provide(func(h *swarm.Swarm) peer.ID { return h.LocalPeer() }),
 		fx.Provide(func(h *swarm.Swarm) crypto.PrivKey { return h.Peerstore().PrivKey(h.LocalPeer()) }),
+		// Emit the resource manager events on the event bus of the host.
+		fx.Invoke(func(b event.Bus, lifecycle fx.Lifecycle) error {
+			r, ok := cfg.ResourceManager.(rcmgr.ResourceManagerEventEmitter)
+			if !ok {
+				return nil
+			}
+			c, err := r.AddEventBus(b)
+			if err != nil {
+				return err
+			}
+			lifecycle.Append(fx.StopHook(c.Close))
+			return nil
+		}),
 	}
 	transportOpts, err := cfg.addTransports()
 	if err != nil {
@@ -489,12 +687,15 @@ func (cfg *Config) NewNode() (host.Host, error) {
 	// Note: h.AddrsFactory may be changed by relayFinder, but non-relay version is
 	// used by AutoNAT below.
 	if cfg.EnableAutoRelay {
-		if !cfg.DisableMetrics {
+		if cfg.MetricsEnabled(MetricsAutoRelay) {
 			mt := autorelay.WithMetricsTracer(
 				autorelay.NewMetricsTracer(autorelay.WithRegisterer(cfg.PrometheusRegisterer)))
 			mtOpts := []autorelay.Option{mt}
 			cfg.AutoRelayOpts = append(mtOpts, cfg.AutoRelayOpts...)
 		}
+		if src := cfg.newRandSource(); src != nil {
+			cfg.AutoRelayOpts = append([]autorelay.Option{autorelay.WithRandSource(src)}, cfg.AutoRelayOpts...)
+		}
 		fxopts = append(fxopts,
 			fx.Invoke(func(h *bhost.BasicHost, lifecycle fx.Lifecycle) (*autorelay.AutoRelay, error) {
 				ar, err := autorelay.NewAutoRelay(h, cfg.AutoRelayOpts...)
@@ -513,6 +714,53 @@ func (cfg *Config) NewNode() (host.Host, error) {
 		lifecycle.Append(fx.StartHook(h.Start))
 	}))
 
+	if len(cfg.BootstrapPeers) > 0 {
+		bootstrapOpts := cfg.BootstrapOpts
+		if cfg.Clock != nil {
+			bootstrapOpts = append([]bootstrap.Option{bootstrap.WithClock(cfg.Clock)}, bootstrapOpts...)
+		}
+		fxopts = append(fxopts,
+			fx.Invoke(func(h host.Host, lifecycle fx.Lifecycle) (*bootstrap.Bootstrapper, error) {
+				b, err := bootstrap.New(h, cfg.BootstrapPeers, bootstrapOpts...)
+				if err != nil {
+					return nil, err
+				}
+				lifecycle.Append(fx.StartStopHook(b.Start, b.Close))
+				return b, nil
+			}),
+		)
+	}
+
+	if cfg.EnableKeepAlive {
+		fxopts = append(fxopts,
+			fx.Invoke(func(h host.Host, lifecycle fx.Lifecycle) (*ping.KeepAlive, error) {
+				opts := cfg.KeepAliveOpts
+				if cfg.PeerScorer != nil {
+					opts = append([]ping.KeepAliveOption{ping.WithKeepAliveScorer(cfg.PeerScorer)}, opts...)
+				}
+				k, err := ping.NewKeepAlive(h, opts...)
+				if err != nil {
+					return nil, err
+				}
+				lifecycle.Append(fx.StartStopHook(k.Start, k.Close))
+				return k, nil
+			}),
+		)
+	}
+
+	if cfg.PeerScorer != nil {
+		fxopts = append(fxopts,
+			fx.Invoke(func(h host.Host, lifecycle fx.Lifecycle) error {
+				stop, err := cfg.PeerScorer.Watch(h.EventBus())
+				if err != nil {
+					return err
+				}
+				lifecycle.Append(fx.StopHook(stop))
+				return nil
+			}),
+		)
+	}
+
 	var rh *routed.RoutedHost
 	if cfg.Routing != nil {
 		fxopts = append(fxopts, fx.Invoke(func(bho *routed.RoutedHost) { rh = bho }))
@@ -539,6 +787,20 @@ func (cfg *Config) NewNode() (host.Host, error) {
 	return &closableBasicHost{App: app, BasicHost: bh}, nil
 }
 
+// closeOwnedComponents closes the components that the host takes ownership of,
+// for when NewNode fails before the host has been constructed.
+func (cfg *Config) closeOwnedComponents() {
+	if cfg.ResourceManager != nil {
+		cfg.ResourceManager.Close()
+	}
+	if cfg.ConnManager != nil {
+		cfg.ConnManager.Close()
+	}
+	if cfg.Peerstore != nil {
+		cfg.Peerstore.Close()
+	}
+}
+
 func (cfg *Config) addAutoNAT(h *bhost.BasicHost) error {
 	addrF := h.AddrsFactory
 	autonatOpts := []autonat.Option{
@@ -546,7 +808,7 @@ func (cfg *Config) addAutoNAT(h *bhost.BasicHost) error {
 			return addrF(h.AllAddrs())
 		}),
 	}
-	if !cfg.DisableMetrics {
+	if cfg.MetricsEnabled(MetricsAutoNAT) {
 		autonatOpts = append(autonatOpts, autonat.WithMetricsTracer(
 			autonat.NewMetricsTracer(autonat.WithRegisterer(cfg.PrometheusRegisterer)),
 		))
@@ -556,6 +818,9 @@ func (cfg *Config) addAutoNAT(h *bhost.BasicHost) error {
 			autonat.WithThrottling(cfg.AutoNATConfig.ThrottleGlobalLimit, cfg.AutoNATConfig.ThrottleInterval),
 			autonat.WithPeerThrottling(cfg.AutoNATConfig.ThrottlePeerLimit))
 	}
+	if cfg.AutoNATConfig.ConcurrencyLimit != 0 {
+		autonatOpts = append(autonatOpts, autonat.WithMaxConcurrentDials(cfg.AutoNATConfig.ConcurrencyLimit))
+	}
 	if cfg.AutoNATConfig.EnableService {
 		autonatPrivKey, _, err := crypto.GenerateEd25519Key(rand.Reader)
 		if err != nil {
@@ -569,17 +834,21 @@ func (cfg *Config) addAutoNAT(h *bhost.BasicHost) error {
 		// Pull out the pieces of the config that we _actually_ care about.
 		// Specifically, don't set up things like listeners, identify, etc.
 		autoNatCfg := Config{
-			Transports:         cfg.Transports,
-			Muxers:             cfg.Muxers,
-			SecurityTransports: cfg.SecurityTransports,
-			Insecure:           cfg.Insecure,
-			PSK:                cfg.PSK,
-			ConnectionGater:    cfg.ConnectionGater,
-			Reporter:           cfg.Reporter,
-			PeerKey:            autonatPrivKey,
-			Peerstore:          ps,
-			DialRanker:         swarm.NoDelayDialRanker,
-			ResourceManager:    cfg.ResourceManager,
+			Transports:                   cfg.Transports,
+			Muxers:                       cfg.Muxers,
+			SecurityTransports:           cfg.SecurityTransports,
+			Insecure:                     cfg.Insecure,
+			PSK:                          cfg.PSK,
+			DisableEarlyMuxerNegotiation: cfg.DisableEarlyMuxerNegotiation,
+			ConnectionGater:              cfg.ConnectionGater,
+			Reporter:                     cfg.Reporter,
+			PeerKey:                      autonatPrivKey,
+			Peerstore:                    ps,
+			DialRanker:                   swarm.NoDelayDialRanker,
+			ResourceManager:              cfg.ResourceManager,
+			Logger:                       cfg.Logger,
+			AddrTranslator:               cfg.AddrTranslator,
+			DisableMetrics:               true,
 			SwarmOpts: []swarm.Option{
 				swarm.WithUDPBlackHoleSuccessCounter(nil),
 				swarm.WithIPv6BlackHoleSuccessCounter(nil),
@@ -624,6 +893,9 @@ func (cfg *Config) addAutoNAT(h *bhost.BasicHost) error {
 	if cfg.AutoNATConfig.ForceReachability != nil {
 		autonatOpts = append(autonatOpts, autonat.WithReachability(*cfg.AutoNATConfig.ForceReachability))
 	}
+	if src := cfg.newRandSource(); src != nil {
+		autonatOpts = append(autonatOpts, autonat.WithRandSource(src))
+	}
 
 	autonat, err := autonat.New(h, autonatOpts...)
 	if err != nil {
@@ -1,6 +1,7 @@
 package config
 
 import (
+	"math/rand"
 	"testing"
 )
 
@@ -21,3 +22,28 @@ func TestNilOption(t *testing.T) {
 		t.Fatalf("expected to have handled 3 options, handled %d", optsRun)
 	}
 }
+
+func TestNewRandSource(t *testing.T) {
+	var cfg Config
+	if cfg.newRandSource() != nil {
+		t.Fatal("expected no rand source if RandSource isn't set")
+	}
+
+	draw := func() []int64 {
+		cfg := Config{RandSource: rand.NewSource(42)}
+		var vals []int64
+		for i := 0; i < 3; i++ {
+			vals = append(vals, cfg.newRandSource().Int63())
+		}
+		return vals
+	}
+	a, b := draw(), draw()
+	for i := range a {
+		if a[i] != b[i] {
+			t.Fatalf("expected the same values for the same seed: %v, %v", a, b)
+		}
+	}
+	if a[0] == a[1] || a[1] == a[2] {
+		t.Fatalf("expected every service to get a different source: %v", a)
+	}
+}
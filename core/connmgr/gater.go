@@ -6,6 +6,7 @@ import (
 	"github.com/libp2p/go-libp2p/core/control"
 	"github.com/libp2p/go-libp2p/core/network"
 	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
 )
 
 // ConnectionGater can be implemented by a type that supports active
@@ -87,3 +88,16 @@ type ConnectionGater interface {
 	// NOTE: the go-libp2p implementation currently IGNORES the disconnect reason.
 	InterceptUpgraded(network.Conn) (allow bool, reason control.DisconnectReason)
 }
+
+// IdentifyGater is an optional interface a ConnectionGater can implement to
+// gate connections based on the information a peer sends in identify.
+//
+// InterceptIdentified is called by the identify service each time it receives
+// an identify (or identify push) message on a connection, before the
+// information is announced to the rest of the system. If it returns false, the
+// connection is closed and an event.EvtPeerIdentificationDenied is emitted.
+// Typical uses are dropping connections from outdated clients, or from
+// implementations known to misbehave.
+type IdentifyGater interface {
+	InterceptIdentified(c network.Conn, agentVersion string, protocols []protocol.ID) (allow bool)
+}
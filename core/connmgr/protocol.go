@@ -0,0 +1,36 @@
+package connmgr
+
+import "github.com/libp2p/go-libp2p/core/protocol"
+
+// ProtocolProtector is implemented by connection managers that can keep a
+// minimum number of connections to peers speaking a given protocol.
+//
+// Instead of protecting individual peers, and keeping track of which peers
+// need to be unprotected when they go away, a protocol implementation declares
+// how many peers speaking its protocol it needs. The connection manager then
+// avoids trimming connections that would bring the number of connected peers
+// speaking that protocol below the declared floor. Which protocols a peer
+// speaks is determined using the peerstore.
+//
+// Use the SupportsProtocolProtection function to safely cast a ConnManager to
+// a ProtocolProtector, if supported.
+type ProtocolProtector interface {
+	// ProtectProtocol asks the connection manager to keep connections to at
+	// least n peers speaking proto. Floors are tagged, so that multiple
+	// components can declare a floor for the same protocol; the largest one
+	// applies. Calling ProtectProtocol again with the same tag replaces the
+	// floor.
+	ProtectProtocol(proto protocol.ID, tag string, n int)
+
+	// UnprotectProtocol removes the floor declared using the given tag. It
+	// returns true if another floor is still in place for the protocol.
+	UnprotectProtocol(proto protocol.ID, tag string) (protected bool)
+}
+
+// SupportsProtocolProtection evaluates if the provided ConnManager supports
+// protocol protection, and if so, it returns the ProtocolProtector object.
+// Refer to godocs on ProtocolProtector for more info.
+func SupportsProtocolProtection(mgr ConnManager) (ProtocolProtector, bool) {
+	p, ok := mgr.(ProtocolProtector)
+	return p, ok
+}
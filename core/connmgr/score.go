@@ -0,0 +1,23 @@
+package connmgr
+
+import (
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// PeerScorer aggregates feedback about peers from different sources (e.g.
+// identify, ping, the resource manager or application protocols) into a single
+// score per peer. Negative feedback lowers a peer's score, positive feedback
+// raises it. Implementations typically weigh the sources differently and let
+// scores decay over time.
+//
+// The score is consumed by components that need to judge peers, like the
+// connection manager (when trimming) or a connection gater.
+type PeerScorer interface {
+	// Report adds delta to the score of p for the given source. By
+	// convention, libp2p services use their resource manager service name as
+	// source.
+	Report(p peer.ID, source string, delta float64)
+	// Score returns the current score of p. Peers that were never reported
+	// have a score of 0.
+	Score(p peer.ID) float64
+}
@@ -0,0 +1,13 @@
+package event
+
+// EvtBootstrapHealthChanged is emitted by the bootstrap service when the
+// number of connected bootstrap peers, or the health derived from it, changes.
+type EvtBootstrapHealthChanged struct {
+	// Connected is the number of bootstrap peers we're currently connected to.
+	Connected int
+	// Total is the number of configured bootstrap peers, including persisted ones.
+	Total int
+	// Healthy is true if we're connected to at least the configured minimum
+	// number of bootstrap peers.
+	Healthy bool
+}
@@ -0,0 +1,30 @@
+package event
+
+import "github.com/libp2p/go-libp2p/core/peer"
+
+// ConnManagerTrimReason is the reason why the connection manager trimmed connections.
+type ConnManagerTrimReason string
+
+const (
+	// TrimReasonHighWater means that the connection count exceeded the high watermark.
+	TrimReasonHighWater ConnManagerTrimReason = "high_water"
+	// TrimReasonManual means that the trim was explicitly requested, e.g. by calling TrimOpenConns.
+	TrimReasonManual ConnManagerTrimReason = "manual"
+	// TrimReasonMemoryEmergency means that the node was running low on memory.
+	TrimReasonMemoryEmergency ConnManagerTrimReason = "memory_emergency"
+)
+
+// EvtConnManagerTrimmed is emitted by the connection manager after it closed
+// connections during a trim. Trims that didn't close any connections are not reported.
+type EvtConnManagerTrimmed struct {
+	// Reason is the reason why the trim was performed.
+	Reason ConnManagerTrimReason
+	// ConnsClosed is the number of connections that were closed.
+	ConnsClosed int
+	// Peers is the set of peers whose connections were closed.
+	Peers []peer.ID
+	// ConnCount is the number of connections before the trim.
+	ConnCount int
+	// LowWater and HighWater are the watermarks of the connection manager.
+	LowWater, HighWater int
+}
@@ -0,0 +1,15 @@
+package event
+
+import "github.com/libp2p/go-libp2p/core/peer"
+
+// EvtHolePunchFinished is emitted by the hole punching service when it finished
+// hole punching to a peer it's connected to through a relay, on both the side
+// initiating and the side receiving the hole punch.
+type EvtHolePunchFinished struct {
+	// Peer is the remote peer.
+	Peer peer.ID
+	// Success is true if hole punching established a direct connection.
+	Success bool
+	// Attempts is the number of hole punch attempts made.
+	Attempts int
+}
@@ -19,6 +19,12 @@ type EvtPeerIdentificationCompleted struct {
 	// ListenAddrs is the list of addresses the peer is listening on.
 	ListenAddrs []multiaddr.Multiaddr
 
+	// ListenAddrsReachability is the reachability of the peer's listen
+	// addresses, keyed by their string representation, as reported by the
+	// peer itself. Only peers speaking identify v2 report it, and not
+	// necessarily for all addresses. This is not verified.
+	ListenAddrsReachability map[string]network.Reachability
+
 	// Protocols is the list of protocols the peer advertised on this connection.
 	Protocols []protocol.ID
 
@@ -37,6 +43,20 @@ type EvtPeerIdentificationCompleted struct {
 	ObservedAddr multiaddr.Multiaddr
 }
 
+// EvtPeerIdentificationDenied is emitted when the connection gater (see
+// connmgr.IdentifyGater) rejected a connection based on the information the
+// peer sent in identify. The connection is closed.
+type EvtPeerIdentificationDenied struct {
+	// Peer is the ID of the peer.
+	Peer peer.ID
+	// Conn is the connection that was closed.
+	Conn network.Conn
+	// AgentVersion is the agent version the peer sent.
+	AgentVersion string
+	// Protocols is the list of protocols the peer advertised.
+	Protocols []protocol.ID
+}
+
 // EvtPeerIdentificationFailed is emitted when the initial identification round for a peer failed.
 type EvtPeerIdentificationFailed struct {
 	// Peer is the ID of the peer whose identification failed.
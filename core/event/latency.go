@@ -0,0 +1,18 @@
+package event
+
+import (
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// EvtPeerLatencyChanged is emitted by the latency monitor when the smoothed
+// round trip time to a peer changed significantly since it was last reported.
+type EvtPeerLatencyChanged struct {
+	// Peer is the peer whose latency changed.
+	Peer peer.ID
+	// Previous is the latency that was last reported for the peer.
+	Previous time.Duration
+	// Current is the current EWMA of the latency to the peer.
+	Current time.Duration
+}
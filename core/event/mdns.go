@@ -0,0 +1,11 @@
+package event
+
+import "github.com/libp2p/go-libp2p/core/peer"
+
+// EvtMdnsPeerFound is emitted by the mDNS discovery service when it found a
+// peer on the local network. It is emitted every time the peer responds to a
+// query, so the same peer may be reported multiple times.
+type EvtMdnsPeerFound struct {
+	// Peer is the peer that was found, including the addresses it announced.
+	Peer peer.AddrInfo
+}
@@ -1,6 +1,11 @@
 package event
 
-import "github.com/libp2p/go-libp2p/core/network"
+import (
+	"net/netip"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+)
 
 // EvtNATDeviceTypeChanged is an event struct to be emitted when the type of the NAT device changes for a Transport Protocol.
 //
@@ -16,3 +21,33 @@ type EvtNATDeviceTypeChanged struct {
 	// how they impact Connectivity and Hole Punching.
 	NatDeviceType network.NATDeviceType
 }
+
+// NATPortMappingChange describes what happened to a port mapping.
+type NATPortMappingChange int
+
+const (
+	// NATPortMappingCreated means that the NAT device granted a new mapping,
+	// or granted a different external port for an existing mapping.
+	NATPortMappingCreated NATPortMappingChange = iota
+	// NATPortMappingRenewed means that the lease of an existing mapping was renewed.
+	NATPortMappingRenewed
+	// NATPortMappingLost means that the NAT device refused to create or renew the mapping.
+	NATPortMappingLost
+	// NATPortMappingRemoved means that we removed the mapping, e.g. because we stopped listening on the port.
+	NATPortMappingRemoved
+)
+
+// EvtNATPortMappingChanged is emitted by the NAT manager when a port mapping
+// (UPnP / NAT-PMP) is created, renewed, lost or removed.
+type EvtNATPortMappingChanged struct {
+	Change NATPortMappingChange
+	// Protocol is either "tcp" or "udp".
+	Protocol     string
+	InternalPort int
+	// External is the external address of the mapping. It is invalid if the mapping was lost.
+	External netip.AddrPort
+	// Gateway is the address of the NAT device, if known.
+	Gateway netip.Addr
+	// Expiry is the time the lease of the mapping expires. It is zero for permanent mappings.
+	Expiry time.Time
+}
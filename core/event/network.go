@@ -53,3 +53,30 @@ type EvtPeerConnectednessChanged struct {
 	// Connectedness is the new connectedness state.
 	Connectedness network.Connectedness
 }
+
+// EvtPeerConnectionUpgraded is emitted when a direct connection to a peer is
+// established while we were only connected to the peer through limited
+// connections, i.e. through relays. This usually happens after a successful
+// hole punch.
+type EvtPeerConnectionUpgraded struct {
+	// Peer is the remote peer.
+	Peer peer.ID
+	// Relays are the relays we were connected to the peer through.
+	Relays []peer.ID
+}
+
+// EvtConnectionEstablished is emitted once a new connection is fully set up,
+// i.e. after the first identify exchange on it completed. It contains the
+// timing breakdown of establishing the connection.
+type EvtConnectionEstablished struct {
+	// Peer is the remote peer.
+	Peer peer.ID
+	// Conn is the new connection.
+	Conn network.Conn
+	// Transport is the transport used for the connection, e.g. tcp or quic-v1.
+	Transport string
+	// Direction is the direction of the connection.
+	Direction network.Direction
+	// Timings is the timing breakdown of establishing the connection.
+	Timings network.ConnTimings
+}
@@ -3,6 +3,7 @@ package network
 import (
 	"context"
 	"io"
+	"time"
 
 	ic "github.com/libp2p/go-libp2p/core/crypto"
 	"github.com/libp2p/go-libp2p/core/peer"
@@ -49,6 +50,12 @@ type ConnectionState struct {
 	Transport string
 	// indicates whether StreamMultiplexer was selected using inlined muxer negotiation
 	UsedEarlyMuxerNegotiation bool
+	// The cipher suite negotiated by the security handshake (if known).
+	// For example: TLS_CHACHA20_POLY1305_SHA256 or 25519_ChaChaPoly_SHA256
+	CipherSuite string
+	// indicates whether application data was sent during the security handshake,
+	// e.g. using QUIC 0-RTT, or the Noise handshake payload extensions
+	UsedEarlyData bool
 }
 
 // ConnSecurity is the interface that one can mix into a connection interface to
@@ -91,3 +98,23 @@ type ConnScoper interface {
 	// Scope returns the user view of this connection's resource scope
 	Scope() ConnScope
 }
+
+// ProtocolUsage describes how a protocol was used on a connection.
+type ProtocolUsage struct {
+	// Streams is the number of streams that were opened for the protocol.
+	Streams int
+	// BytesIn and BytesOut are the number of bytes read from and written to
+	// these streams.
+	BytesIn, BytesOut int64
+	// LastUsed is the time a stream for the protocol was last opened, read
+	// from or written to.
+	LastUsed time.Time
+}
+
+// ProtocolUsageConn is an interface mixin for connection types that track
+// which protocols are used over the connection.
+type ProtocolUsageConn interface {
+	// ProtocolUsage returns the usage of every protocol that had a stream
+	// opened over this connection.
+	ProtocolUsage() map[protocol.ID]ProtocolUsage
+}
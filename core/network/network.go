@@ -110,6 +110,27 @@ type ConnStats struct {
 	Stats
 	// NumStreams is the number of streams on the connection.
 	NumStreams int
+	// Timings is the timing breakdown of establishing the connection.
+	Timings ConnTimings
+}
+
+// ConnTimings is the timing breakdown of establishing a connection.
+// Phases that don't apply to a connection, or that weren't measured, are zero.
+type ConnTimings struct {
+	// Handshake is the duration of the transport handshake, e.g. the TCP or
+	// the QUIC handshake. It is only measured for outbound connections.
+	// Transports that secure the connection themselves (like QUIC) include the
+	// security handshake and muxer negotiation in this phase.
+	Handshake time.Duration
+	// Security is the duration of the security handshake, including the
+	// negotiation of the security protocol.
+	Security time.Duration
+	// Muxer is the duration of the stream muxer negotiation. It is zero if
+	// the muxer was negotiated during the security handshake.
+	Muxer time.Duration
+	// Identify is the duration from the connection being established until
+	// the first identify exchange completed.
+	Identify time.Duration
 }
 
 // Stats stores metadata pertaining to a given Stream / Conn.
@@ -208,3 +229,16 @@ type AddrDelay struct {
 
 // DialRanker provides a schedule of dialing the provided addresses
 type DialRanker func([]ma.Multiaddr) []AddrDelay
+
+// AddrTranslator translates between the addresses peers use and the addresses
+// reachable from the local network, for example to dial IPv4 addresses through
+// a NAT64 gateway.
+type AddrTranslator interface {
+	// TranslateDialAddr returns the addresses to dial instead of addr. It
+	// returns addr itself if addr doesn't need to be translated.
+	TranslateDialAddr(addr ma.Multiaddr) []ma.Multiaddr
+	// ReverseTranslate translates an address as seen from the local network
+	// back into the address peers use. It returns addr itself if addr isn't a
+	// translated address.
+	ReverseTranslate(addr ma.Multiaddr) ma.Multiaddr
+}
@@ -1,6 +1,8 @@
 package peerstore
 
 import (
+	"time"
+
 	"github.com/libp2p/go-libp2p/core/peer"
 )
 
@@ -12,3 +14,21 @@ func AddrInfos(ps Peerstore, peers []peer.ID) []peer.AddrInfo {
 	}
 	return pi
 }
+
+// MetadataKey returns the PeerMetadata key for key in namespace ns, e.g.
+// MetadataKey("myservice", "score") returns "myservice/score".
+// Services storing new metadata should namespace their keys to avoid
+// collisions with other services. Existing keys, such as "AgentVersion" and
+// "ProtocolVersion", are not namespaced.
+func MetadataKey(ns, key string) string {
+	return ns + "/" + key
+}
+
+// PutWithTTL stores val for p under key, and removes it once ttl has elapsed.
+// If pm doesn't support expiring values, val is stored without expiry.
+func PutWithTTL(pm PeerMetadata, p peer.ID, key string, val interface{}, ttl time.Duration) error {
+	if pmt, ok := pm.(PeerMetadataWithTTL); ok {
+		return pmt.PutWithTTL(p, key, val, ttl)
+	}
+	return pm.Put(p, key, val)
+}
@@ -85,6 +85,18 @@ type PeerMetadata interface {
 	RemovePeer(peer.ID)
 }
 
+// PeerMetadataWithTTL is implemented by PeerMetadata stores that support
+// values that expire. Expired values are removed by the store's garbage
+// collection, so that we don't keep metadata for peers we saw once forever.
+type PeerMetadataWithTTL interface {
+	PeerMetadata
+
+	// PutWithTTL stores val under key, and removes it once ttl has elapsed.
+	// A ttl of zero or less removes the value. Put stores a value without
+	// expiry, overwriting any previous ttl.
+	PutWithTTL(p peer.ID, key string, val interface{}, ttl time.Duration) error
+}
+
 // AddrBook holds the multiaddrs of peers.
 type AddrBook interface {
 	// AddAddr calls AddAddrs(p, []ma.Multiaddr{addr}, ttl)
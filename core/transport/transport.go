@@ -96,6 +96,58 @@ type Listener interface {
 	Multiaddr() ma.Multiaddr
 }
 
+// SocketInfo describes the socket a listener is bound to.
+type SocketInfo struct {
+	// LocalAddr is the address the socket is bound to.
+	LocalAddr net.Addr
+	// ReusePort is true if outgoing connections may be dialed from the
+	// socket's port. For TCP, this means that SO_REUSEPORT is set. For QUIC,
+	// dials reuse the listening UDP socket.
+	ReusePort bool
+	// PacketInfo is true if the socket receives the destination address of
+	// incoming packets (IP_PKTINFO / IPV6_RECVPKTINFO).
+	PacketInfo bool
+	// Shared is true if the socket is intentionally shared with other
+	// listeners, e.g. QUIC and WebTransport listening on the same UDP port.
+	Shared bool
+}
+
+// SocketInfoListener is a Listener that can report on the socket it's bound
+// to.
+type SocketInfoListener interface {
+	Listener
+	SocketInfo() SocketInfo
+}
+
+// ReusePortListener is a Listener whose port can be excluded from being
+// reused for outgoing connections at runtime.
+type ReusePortListener interface {
+	Listener
+	// SetReusePort sets whether outgoing connections may be dialed from the
+	// listener's port. It returns an error if port reuse isn't supported
+	// for this listener.
+	SetReusePort(bool) error
+}
+
+// ListenerSocket describes the socket a listener is bound to.
+type ListenerSocket struct {
+	// Addr is the listen address.
+	Addr ma.Multiaddr
+	// Transport is the transport listening on Addr.
+	Transport Transport
+	// SocketInfo reports on the socket. If the listener doesn't implement
+	// SocketInfoListener, only LocalAddr is set, derived from Addr.
+	SocketInfo
+	// SharedWith are the other listen addresses that share the socket, e.g.
+	// QUIC and WebTransport listening on the same UDP port.
+	SharedWith []ma.Multiaddr
+	// Conflicts are the listen addresses that are bound to a different
+	// socket on the same port, e.g. TCP and WebSocket listening on the same
+	// port with SO_REUSEPORT. Incoming connections are distributed between
+	// these sockets by the kernel.
+	Conflicts []ma.Multiaddr
+}
+
 // ErrListenerClosed is returned by Listener.Accept when the listener is gracefully closed.
 var ErrListenerClosed = errors.New("listener closed")
 
@@ -20,7 +20,6 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 
 	"github.com/multiformats/go-multiaddr"
-	madns "github.com/multiformats/go-multiaddr-dns"
 )
 
 // DefaultSecurity is the default security option.
@@ -60,7 +59,11 @@ var DefaultPrivateTransports = ChainOptions(
 
 // DefaultPeerstore configures libp2p to use the default peerstore.
 var DefaultPeerstore Option = func(cfg *Config) error {
-	ps, err := pstoremem.NewPeerstore()
+	var opts []pstoremem.Option
+	if cfg.Clock != nil {
+		opts = append(opts, pstoremem.WithClock(cfg.Clock))
+	}
+	ps, err := pstoremem.NewPeerstore(opts...)
 	if err != nil {
 		return err
 	}
@@ -106,7 +109,19 @@ var DefaultResourceManager = func(cfg *Config) error {
 	// Default memory limit: 1/8th of total memory, minimum 128MB, maximum 1GB
 	limits := rcmgr.DefaultLimits
 	SetDefaultServiceLimits(&limits)
-	mgr, err := rcmgr.NewResourceManager(rcmgr.NewFixedLimiter(limits.AutoScale()))
+	var opts []rcmgr.Option
+	if cfg.MetricsEnabled(MetricsResourceManager) {
+		str, err := rcmgr.NewStatsTraceReporter()
+		if err != nil {
+			return err
+		}
+		opts = append(opts, rcmgr.WithTraceReporter(str))
+	}
+	// This enables tracing in the resource manager, see PeerScorer.
+	if cfg.PeerScorer != nil {
+		opts = append(opts, rcmgr.WithTraceReporter(cfg.PeerScorer.ResourceManagerReporter()))
+	}
+	mgr, err := rcmgr.NewResourceManager(rcmgr.NewFixedLimiter(limits.AutoScale()), opts...)
 	if err != nil {
 		return err
 	}
@@ -116,7 +131,18 @@ var DefaultResourceManager = func(cfg *Config) error {
 
 // DefaultConnectionManager creates a default connection manager
 var DefaultConnectionManager = func(cfg *Config) error {
-	mgr, err := connmgr.NewConnManager(160, 192)
+	opts := []connmgr.Option{connmgr.WithProtoBook(cfg.Peerstore)}
+	if cfg.MetricsEnabled(MetricsConnManager) {
+		opts = append(opts, connmgr.WithMetricsTracer(
+			connmgr.NewMetricsTracer(connmgr.WithRegisterer(cfg.PrometheusRegisterer))))
+	}
+	if cfg.Clock != nil {
+		opts = append(opts, connmgr.WithClock(cfg.Clock))
+	}
+	if cfg.PeerScorer != nil {
+		opts = append(opts, connmgr.WithScoring(cfg.PeerScorer.ConnMgrScore(connmgr.DefaultScore)))
+	}
+	mgr, err := connmgr.NewConnManager(160, 192, opts...)
 	if err != nil {
 		return err
 	}
@@ -124,9 +150,10 @@ var DefaultConnectionManager = func(cfg *Config) error {
 	return cfg.Apply(ConnectionManager(mgr))
 }
 
-// DefaultMultiaddrResolver creates a default connection manager
+// DefaultMultiaddrResolver configures libp2p to resolve DNS multiaddrs using
+// the system resolver, caching the results.
 var DefaultMultiaddrResolver = func(cfg *Config) error {
-	return cfg.Apply(MultiaddrResolver(madns.DefaultResolver))
+	return cfg.Apply(DNSResolver())
 }
 
 // DefaultPrometheusRegisterer configures libp2p to use the default registerer
@@ -195,7 +222,7 @@ var defaults = []struct {
 		opt:      DefaultConnectionManager,
 	},
 	{
-		fallback: func(cfg *Config) bool { return cfg.MultiaddrResolver == nil },
+		fallback: func(cfg *Config) bool { return cfg.MultiaddrResolver == nil && cfg.DNSResolverOpts == nil },
 		opt:      DefaultMultiaddrResolver,
 	},
 	{
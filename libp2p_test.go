@@ -5,7 +5,12 @@ import (
 	"crypto/rand"
 	"errors"
 	"fmt"
+	"log/slog"
+	mrand "math/rand"
 	"regexp"
+	"slices"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -19,16 +24,23 @@ import (
 	"github.com/libp2p/go-libp2p/core/routing"
 	"github.com/libp2p/go-libp2p/core/transport"
 	rcmgr "github.com/libp2p/go-libp2p/p2p/host/resource-manager"
+	"github.com/libp2p/go-libp2p/p2p/net/dnsresolver"
 	"github.com/libp2p/go-libp2p/p2p/net/swarm"
+	tptu "github.com/libp2p/go-libp2p/p2p/net/upgrader"
 	"github.com/libp2p/go-libp2p/p2p/protocol/ping"
 	"github.com/libp2p/go-libp2p/p2p/security/noise"
 	tls "github.com/libp2p/go-libp2p/p2p/security/tls"
 	quic "github.com/libp2p/go-libp2p/p2p/transport/quic"
 	"github.com/libp2p/go-libp2p/p2p/transport/tcp"
 	webtransport "github.com/libp2p/go-libp2p/p2p/transport/webtransport"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
 	"go.uber.org/goleak"
 
+	"github.com/benbjohnson/clock"
+
 	ma "github.com/multiformats/go-multiaddr"
+	madns "github.com/multiformats/go-multiaddr-dns"
 	"github.com/stretchr/testify/require"
 )
 
@@ -283,6 +295,49 @@ func TestSecurityConstructor(t *testing.T) {
 	require.NoError(t, h2.Connect(context.Background(), ai))
 }
 
+func TestDisableEarlyMuxerNegotiation(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		constructor interface{}
+		id          string
+	}{
+		{name: "tls", constructor: tls.New, id: tls.ID},
+		{name: "noise", constructor: noise.New, id: noise.ID},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			h1, err := New(
+				Transport(tcp.NewTCPTransport),
+				Security(tc.id, tc.constructor),
+				ListenAddrStrings("/ip4/127.0.0.1/tcp/0"),
+				DisableRelay(),
+			)
+			require.NoError(t, err)
+			defer h1.Close()
+
+			for _, disable := range []bool{false, true} {
+				opts := []Option{
+					NoListenAddrs,
+					Transport(tcp.NewTCPTransport),
+					Security(tc.id, tc.constructor),
+					DisableRelay(),
+				}
+				if disable {
+					opts = append(opts, DisableEarlyMuxerNegotiation())
+				}
+				h2, err := New(opts...)
+				require.NoError(t, err)
+				defer h2.Close()
+
+				require.NoError(t, h2.Connect(context.Background(), peer.AddrInfo{ID: h1.ID(), Addrs: h1.Addrs()}))
+				conns := h2.Network().ConnsToPeer(h1.ID())
+				require.Len(t, conns, 1)
+				require.Equal(t, !disable, conns[0].ConnState().UsedEarlyMuxerNegotiation)
+				require.NotEmpty(t, conns[0].ConnState().StreamMultiplexer)
+			}
+		})
+	}
+}
+
 func TestTransportConstructorWebTransport(t *testing.T) {
 	h, err := New(
 		Transport(webtransport.New),
@@ -465,3 +520,299 @@ func TestDialCircuitAddrWithWrappedResourceManager(t *testing.T) {
 	require.NoError(t, res.Error)
 	defer cancel()
 }
+
+// recordingRegisterer records the names of all metrics registered with it.
+type recordingRegisterer struct {
+	prometheus.Registerer
+
+	mx    sync.Mutex
+	names []string
+}
+
+func (r *recordingRegisterer) Register(c prometheus.Collector) error {
+	ch := make(chan *prometheus.Desc, 100)
+	go func() {
+		c.Describe(ch)
+		close(ch)
+	}()
+	r.mx.Lock()
+	for d := range ch {
+		r.names = append(r.names, d.String())
+	}
+	r.mx.Unlock()
+	return r.Registerer.Register(c)
+}
+
+func (r *recordingRegisterer) hasPrefix(prefix string) bool {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	for _, n := range r.names {
+		if strings.Contains(n, `fqName: "`+prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestWithMetrics(t *testing.T) {
+	reg := &recordingRegisterer{Registerer: prometheus.NewRegistry()}
+	h, err := New(
+		NoListenAddrs,
+		WithMetrics(reg, MetricsNamespace("test"), MetricsSubsystems(MetricsSwarm, MetricsEventBus)),
+	)
+	require.NoError(t, err)
+	defer h.Close()
+
+	require.True(t, reg.hasPrefix("test_libp2p_swarm_"))
+	require.True(t, reg.hasPrefix("test_libp2p_eventbus_"))
+	require.False(t, reg.hasPrefix("test_libp2p_identify_"))
+	require.False(t, reg.hasPrefix("test_libp2p_rcmgr_"))
+	require.False(t, reg.hasPrefix("libp2p_"))
+}
+
+func TestWithMetricsDisabled(t *testing.T) {
+	_, err := New(DisableMetrics(), WithMetrics(prometheus.NewRegistry()))
+	require.Error(t, err)
+}
+
+func TestWithClock(t *testing.T) {
+	cl := clock.NewMock()
+	cl.Set(time.Now())
+	h, err := New(NoListenAddrs, WithClock(cl))
+	require.NoError(t, err)
+	defer h.Close()
+
+	priv, _, err := crypto.GenerateEd25519Key(rand.Reader)
+	require.NoError(t, err)
+	p, err := peer.IDFromPrivateKey(priv)
+	require.NoError(t, err)
+	h.Peerstore().AddAddr(p, ma.StringCast("/ip4/1.2.3.4/tcp/1"), time.Hour)
+	require.Len(t, h.Peerstore().Addrs(p), 1)
+	cl.Add(time.Hour + time.Second)
+	require.Empty(t, h.Peerstore().Addrs(p))
+
+	_, err = New(WithClock(cl), WithClock(cl))
+	require.Error(t, err)
+}
+
+// systemsHandler records the subsystems that logged.
+type systemsHandler struct {
+	mu      *sync.Mutex
+	systems map[string]struct{}
+	attrs   []slog.Attr
+}
+
+func (h *systemsHandler) Enabled(context.Context, slog.Level) bool { return true }
+
+func (h *systemsHandler) Handle(context.Context, slog.Record) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, a := range h.attrs {
+		if a.Key == "system" {
+			h.systems[a.Value.String()] = struct{}{}
+		}
+	}
+	return nil
+}
+
+func (h *systemsHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &systemsHandler{mu: h.mu, systems: h.systems, attrs: append(slices.Clip(h.attrs), attrs...)}
+}
+
+func (h *systemsHandler) WithGroup(string) slog.Handler { return h }
+
+func TestLogger(t *testing.T) {
+	h := &systemsHandler{mu: &sync.Mutex{}, systems: make(map[string]struct{})}
+	h1, err := New(Logger(slog.New(h)), ListenAddrStrings("/ip4/127.0.0.1/tcp/0"))
+	require.NoError(t, err)
+	defer h1.Close()
+	h2, err := New(NoListenAddrs)
+	require.NoError(t, err)
+	defer h2.Close()
+
+	require.NoError(t, h2.Connect(context.Background(), peer.AddrInfo{ID: h1.ID(), Addrs: h1.Addrs()}))
+	require.Eventually(t, func() bool {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		_, swarm := h.systems["swarm2"]
+		_, identify := h.systems["net/identify"]
+		return swarm && identify
+	}, 5*time.Second, 10*time.Millisecond)
+
+	_, err = New(Logger(slog.Default()), Logger(slog.Default()))
+	require.Error(t, err)
+}
+
+func TestDNSResolver(t *testing.T) {
+	h, err := New(NoListenAddrs, DNSResolver(dnsresolver.WithServers("127.0.0.1")))
+	require.NoError(t, err)
+	h.Close()
+
+	_, err = New(NoListenAddrs, DNSResolver(), MultiaddrResolver(madns.DefaultResolver))
+	require.Error(t, err)
+	_, err = New(NoListenAddrs, DNSResolver(), DNSResolver())
+	require.Error(t, err)
+	_, err = New(NoListenAddrs, DNSResolver(dnsresolver.WithCacheSize(-1)))
+	require.Error(t, err)
+}
+
+func TestRandSource(t *testing.T) {
+	h, err := New(NoListenAddrs, RandSource(mrand.NewSource(42)), EnableAutoNATv2(), EnableRelay(), EnableAutoRelayWithStaticRelays(nil))
+	require.NoError(t, err)
+	h.Close()
+
+	_, err = New(NoListenAddrs, RandSource(mrand.NewSource(1)), RandSource(mrand.NewSource(2)))
+	require.Error(t, err)
+}
+
+func TestUpgraderOptions(t *testing.T) {
+	h, err := New(NoListenAddrs, UpgraderOptions(tptu.WithSecurityTimeout(5*time.Second), tptu.WithInboundHandshakeLimit(16, 64)))
+	require.NoError(t, err)
+	h.Close()
+
+	_, err = New(NoListenAddrs, UpgraderOptions(tptu.WithMuxerTimeout(time.Second)), UpgraderOptions(tptu.WithMuxerTimeout(time.Second)))
+	require.Error(t, err)
+}
+
+func TestShared(t *testing.T) {
+	shared, err := NewShared(SharedPrometheusRegisterer(prometheus.NewRegistry()))
+	require.NoError(t, err)
+	defer shared.Close()
+
+	h1, err := New(shared.Option(), ListenAddrStrings("/ip4/127.0.0.1/tcp/0"))
+	require.NoError(t, err)
+	h2, err := New(shared.Option(), ListenAddrStrings("/ip4/127.0.0.1/tcp/0"))
+	require.NoError(t, err)
+	defer h2.Close()
+	require.Equal(t, shared.ResourceManager(), h1.Network().ResourceManager())
+	require.Equal(t, shared.ResourceManager(), h2.Network().ResourceManager())
+
+	require.NoError(t, h2.Connect(context.Background(), peer.AddrInfo{ID: h1.ID(), Addrs: h1.Addrs()}))
+	// Both ends of the connection are accounted for by the shared resource manager.
+	require.Eventually(t, func() bool {
+		usage := shared.ResourceManager().(rcmgr.ResourceManagerUsageReporter).Usage()
+		return usage.System.NumConnsInbound == 1 && usage.System.NumConnsOutbound == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// Closing one host doesn't close the shared resource manager.
+	h1.Close()
+	s, err := shared.ResourceManager().OpenStream("", network.DirOutbound)
+	require.NoError(t, err)
+	s.Done()
+
+	_, err = New(shared.Option(), ResourceManager(&network.NullResourceManager{}))
+	require.Error(t, err)
+	_, err = NewShared(SharedDisableMetrics(), SharedPrometheusRegisterer(prometheus.NewRegistry()))
+	require.Error(t, err)
+}
+
+func TestSharedMetrics(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	shared, err := NewShared(SharedPrometheusRegisterer(reg))
+	require.NoError(t, err)
+	defer shared.Close()
+
+	h1, err := New(shared.Option(), ListenAddrStrings("/ip4/127.0.0.1/tcp/0"))
+	require.NoError(t, err)
+	defer h1.Close()
+	h2, err := New(shared.Option(), ListenAddrStrings("/ip4/127.0.0.1/tcp/0"))
+	require.NoError(t, err)
+	defer h2.Close()
+	require.NoError(t, h2.Connect(context.Background(), peer.AddrInfo{ID: h1.ID(), Addrs: h1.Addrs()}))
+
+	// Each host reports its own connections, labeled with its ID.
+	opened := func(mfs []*dto.MetricFamily) map[string]string {
+		dirs := make(map[string]string)
+		for _, mf := range mfs {
+			if mf.GetName() != "libp2p_swarm_connections_opened_total" {
+				continue
+			}
+			for _, m := range mf.GetMetric() {
+				var host, dir string
+				for _, l := range m.GetLabel() {
+					switch l.GetName() {
+					case "host":
+						host = l.GetValue()
+					case "dir":
+						dir = l.GetValue()
+					}
+				}
+				dirs[host] = dir
+			}
+		}
+		return dirs
+	}
+	require.Eventually(t, func() bool {
+		mfs, err := reg.Gather()
+		require.NoError(t, err)
+		return len(opened(mfs)) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+	mfs, err := reg.Gather()
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{h1.ID().String(): "inbound", h2.ID().String(): "outbound"}, opened(mfs))
+
+	// The shared resource manager reports its metrics once, without a host label.
+	var found bool
+	for _, mf := range mfs {
+		if !strings.HasPrefix(mf.GetName(), "libp2p_rcmgr_") {
+			continue
+		}
+		found = true
+		for _, m := range mf.GetMetric() {
+			for _, l := range m.GetLabel() {
+				require.NotEqual(t, "host", l.GetName())
+			}
+		}
+	}
+	require.True(t, found)
+}
+
+func TestSharedResourceManagerExtensions(t *testing.T) {
+	shared, err := NewShared(SharedDisableMetrics())
+	require.NoError(t, err)
+	defer shared.Close()
+
+	h, err := New(shared.Option(), NoListenAddrs)
+	require.NoError(t, err)
+	defer h.Close()
+	mgr := h.Network().ResourceManager()
+
+	_, ok := mgr.(rcmgr.ResourceManagerState)
+	require.True(t, ok)
+	_, ok = mgr.(rcmgr.ResourceManagerUsageReporter)
+	require.True(t, ok)
+
+	// Limits updated through the host apply to the shared resource manager,
+	// and are announced on the event bus of the host.
+	sub, err := h.EventBus().Subscribe(new(rcmgr.EvtLimitsUpdated))
+	require.NoError(t, err)
+	defer sub.Close()
+	_, ok = mgr.(rcmgr.ResourceManagerLimitUpdater)
+	require.True(t, ok)
+	limits := rcmgr.PartialLimitConfig{System: rcmgr.ResourceLimits{Conns: 42}}
+	require.NoError(t, rcmgr.UpdateLimits(mgr, rcmgr.NewFixedLimiter(limits.Build(rcmgr.DefaultLimits.AutoScale()))))
+	usage := shared.ResourceManager().(rcmgr.ResourceManagerUsageReporter).Usage()
+	require.Equal(t, 42, usage.System.Limit.Conns)
+	select {
+	case e := <-sub.Out():
+		require.Equal(t, 42, e.(rcmgr.EvtLimitsUpdated).Limiter.GetSystemLimits().GetConnTotalLimit())
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected a limits updated event")
+	}
+
+	_, ok = mgr.(rcmgr.ResourceManagerAllowlister)
+	require.True(t, ok)
+	require.NotNil(t, rcmgr.GetAllowlist(mgr))
+	require.Same(t, rcmgr.GetAllowlist(mgr), rcmgr.GetAllowlist(shared.ResourceManager()))
+}
+
+func TestIdentifyPushScheduling(t *testing.T) {
+	h, err := New(NoListenAddrs, IdentifyPushScheduling(8, time.Second))
+	require.NoError(t, err)
+	h.Close()
+
+	_, err = New(NoListenAddrs, IdentifyPushScheduling(0, time.Second))
+	require.Error(t, err)
+	_, err = New(NoListenAddrs, IdentifyPushScheduling(8, -time.Second))
+	require.Error(t, err)
+}
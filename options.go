@@ -8,6 +8,8 @@ import (
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"log/slog"
+	mrand "math/rand"
 	"reflect"
 	"time"
 
@@ -23,13 +25,18 @@ import (
 	"github.com/libp2p/go-libp2p/core/transport"
 	"github.com/libp2p/go-libp2p/p2p/host/autorelay"
 	bhost "github.com/libp2p/go-libp2p/p2p/host/basic"
+	"github.com/libp2p/go-libp2p/p2p/host/bootstrap"
+	"github.com/libp2p/go-libp2p/p2p/host/peerscore"
+	"github.com/libp2p/go-libp2p/p2p/net/dnsresolver"
 	"github.com/libp2p/go-libp2p/p2p/net/swarm"
 	tptu "github.com/libp2p/go-libp2p/p2p/net/upgrader"
 	relayv2 "github.com/libp2p/go-libp2p/p2p/protocol/circuitv2/relay"
 	"github.com/libp2p/go-libp2p/p2p/protocol/holepunch"
+	"github.com/libp2p/go-libp2p/p2p/protocol/ping"
 	"github.com/libp2p/go-libp2p/p2p/transport/quicreuse"
 	"github.com/prometheus/client_golang/prometheus"
 
+	"github.com/benbjohnson/clock"
 	ma "github.com/multiformats/go-multiaddr"
 	madns "github.com/multiformats/go-multiaddr-dns"
 	"go.uber.org/fx"
@@ -100,6 +107,18 @@ func Muxer(name string, muxer network.Multiplexer) Option {
 	}
 }
 
+// DisableEarlyMuxerNegotiation configures libp2p to not negotiate the stream
+// multiplexer during the security handshake (using ALPN for TLS, and the handshake
+// payload for Noise). Instead, the multiplexer is negotiated using multistream,
+// which takes an additional round trip. This is only useful for interoperability
+// with peers that fail to handle early muxer negotiation.
+func DisableEarlyMuxerNegotiation() Option {
+	return func(cfg *Config) error {
+		cfg.DisableEarlyMuxerNegotiation = true
+		return nil
+	}
+}
+
 func QUICReuse(constructor interface{}, opts ...quicreuse.Option) Option {
 	return func(cfg *Config) error {
 		tag := `group:"quicreuseopts"`
@@ -268,6 +287,46 @@ func AddrsFactory(factory config.AddrsFactory) Option {
 	}
 }
 
+// AddrScorer registers a scorer under the given name, used to rank the
+// addresses we advertise. Addresses are advertised in order of their total
+// score, and addresses with a negative score are not advertised.
+// Scorers can also be registered at runtime, see basichost.BasicHost.SetAddrScorer.
+func AddrScorer(name string, s config.AddrScorer) Option {
+	return func(cfg *Config) error {
+		if _, ok := cfg.AddrScorers[name]; ok {
+			return fmt.Errorf("address scorer %q already configured", name)
+		}
+		if cfg.AddrScorers == nil {
+			cfg.AddrScorers = make(map[string]config.AddrScorer)
+		}
+		cfg.AddrScorers[name] = s
+		return nil
+	}
+}
+
+// MaxAdvertisedAddrs caps the number of addresses we advertise to the n
+// highest scoring ones. See AddrScorer.
+func MaxAdvertisedAddrs(n int) Option {
+	return func(cfg *Config) error {
+		if n <= 0 {
+			return errors.New("maximum number of advertised addresses must be positive")
+		}
+		cfg.MaxAdvertisedAddrs = n
+		return nil
+	}
+}
+
+// DisableAddrWithdrawal configures libp2p to keep advertising addresses that
+// AutoNAT v2 found unreachable. By default, these addresses are withdrawn and
+// peers are notified with an identify push, while the addresses are still
+// listed locally.
+func DisableAddrWithdrawal() Option {
+	return func(cfg *Config) error {
+		cfg.DisableAddrWithdrawal = true
+		return nil
+	}
+}
+
 // EnableRelay configures libp2p to enable the relay transport.
 // This option only configures libp2p to accept inbound connections from relays
 // and make outbound connections_through_ relays when requested by the remote peer.
@@ -388,6 +447,19 @@ func AutoNATServiceRateLimit(global, perPeer int, interval time.Duration) Option
 	}
 }
 
+// AutoNATServiceConcurrencyLimit limits the number of dial-backs the AutoNAT
+// service performs in parallel. Requests exceeding the limit are refused.
+// A value of '0' disables the limit.
+func AutoNATServiceConcurrencyLimit(n int) Option {
+	return func(cfg *Config) error {
+		if n < 0 {
+			return errors.New("autonat concurrency limit must not be negative")
+		}
+		cfg.AutoNATConfig.ConcurrencyLimit = n
+		return nil
+	}
+}
+
 // ConnectionGater configures libp2p to use the given ConnectionGater
 // to actively reject inbound/outbound connections based on the lifecycle stage
 // of the connection.
@@ -494,14 +566,35 @@ func UserAgent(userAgent string) Option {
 	}
 }
 
-// MultiaddrResolver sets the libp2p dns resolver
+// MultiaddrResolver sets the libp2p dns resolver. It can't be combined with
+// DNSResolver.
 func MultiaddrResolver(rslv *madns.Resolver) Option {
 	return func(cfg *Config) error {
+		if cfg.DNSResolverOpts != nil {
+			return errors.New("cannot configure both a multiaddr resolver and a DNS resolver")
+		}
 		cfg.MultiaddrResolver = rslv
 		return nil
 	}
 }
 
+// DNSResolver configures libp2p to resolve DNS multiaddrs using a caching
+// resolver configured with opts. By default, it uses the system resolver. Use
+// dnsresolver.WithServers or dnsresolver.WithDoH to use other DNS servers.
+// The resolver is shared by all subsystems of the host.
+func DNSResolver(opts ...dnsresolver.Option) Option {
+	return func(cfg *Config) error {
+		if cfg.DNSResolverOpts != nil {
+			return errors.New("DNS resolver already configured")
+		}
+		if cfg.MultiaddrResolver != nil {
+			return errors.New("cannot configure both a multiaddr resolver and a DNS resolver")
+		}
+		cfg.DNSResolverOpts = append([]dnsresolver.Option{}, opts...)
+		return nil
+	}
+}
+
 // Experimental
 // EnableHolePunching enables NAT traversal by enabling NATT'd peers to both initiate and respond to hole punching attempts
 // to create direct/NAT-traversed connections with other peers. (default: disabled)
@@ -576,6 +669,81 @@ func PrometheusRegisterer(reg prometheus.Registerer) Option {
 	}
 }
 
+// MetricsSubsystem is a subsystem that exports Prometheus metrics.
+type MetricsSubsystem = config.MetricsSubsystem
+
+const (
+	MetricsSwarm           = config.MetricsSwarm
+	MetricsIdentify        = config.MetricsIdentify
+	MetricsAutoNAT         = config.MetricsAutoNAT
+	MetricsRelayService    = config.MetricsRelayService
+	MetricsAutoRelay       = config.MetricsAutoRelay
+	MetricsHolePunch       = config.MetricsHolePunch
+	MetricsEventBus        = config.MetricsEventBus
+	MetricsResourceManager = config.MetricsResourceManager
+	MetricsConnManager     = config.MetricsConnManager
+	MetricsStreamLimiter   = config.MetricsStreamLimiter
+	MetricsDNS             = config.MetricsDNS
+	MetricsUpgrader        = config.MetricsUpgrader
+)
+
+type metricsConfig struct {
+	namespace  string
+	subsystems []MetricsSubsystem
+}
+
+// MetricsOption is an option for WithMetrics.
+type MetricsOption func(*metricsConfig)
+
+// MetricsNamespace prefixes the names of all metrics with the given namespace,
+// e.g. "myapp" turns libp2p_swarm_connections_opened_total into
+// myapp_libp2p_swarm_connections_opened_total.
+func MetricsNamespace(ns string) MetricsOption {
+	return func(c *metricsConfig) {
+		c.namespace = ns
+	}
+}
+
+// MetricsSubsystems enables metrics only for the given subsystems.
+// By default, metrics are enabled for all subsystems.
+func MetricsSubsystems(subsystems ...MetricsSubsystem) MetricsOption {
+	return func(c *metricsConfig) {
+		c.subsystems = append(c.subsystems, subsystems...)
+	}
+}
+
+// WithMetrics enables Prometheus metrics for all subsystems (swarm, identify,
+// AutoNAT, relay, AutoRelay, hole punching, event bus, resource manager and
+// connection manager), registering them with reg.
+//
+// Metrics for the resource manager and the connection manager are only
+// enabled if they are constructed by libp2p, i.e. if no custom resource
+// manager or connection manager is configured.
+func WithMetrics(reg prometheus.Registerer, opts ...MetricsOption) Option {
+	return func(cfg *Config) error {
+		if reg == nil {
+			return errors.New("registerer cannot be nil")
+		}
+		var mc metricsConfig
+		for _, opt := range opts {
+			opt(&mc)
+		}
+		if mc.namespace != "" {
+			reg = prometheus.WrapRegistererWithPrefix(mc.namespace+"_", reg)
+		}
+		if err := cfg.Apply(PrometheusRegisterer(reg)); err != nil {
+			return err
+		}
+		if mc.subsystems != nil {
+			cfg.MetricsSubsystems = make(map[MetricsSubsystem]struct{}, len(mc.subsystems))
+			for _, s := range mc.subsystems {
+				cfg.MetricsSubsystems[s] = struct{}{}
+			}
+		}
+		return nil
+	}
+}
+
 // DialRanker configures libp2p to use d as the dial ranker. To enable smart
 // dialing use `swarm.DefaultDialRanker`. use `swarm.NoDelayDialRanker` to
 // disable smart dialing.
@@ -591,6 +759,19 @@ func DialRanker(d network.DialRanker) Option {
 	}
 }
 
+// AddrTranslator configures libp2p to translate the addresses of peers using t
+// before dialing them, and to translate observed addresses back in identify.
+// Use swarm.NewNAT64Translator on IPv6-only networks with a NAT64 gateway.
+func AddrTranslator(t network.AddrTranslator) Option {
+	return func(cfg *Config) error {
+		if cfg.AddrTranslator != nil {
+			return errors.New("address translator already configured")
+		}
+		cfg.AddrTranslator = t
+		return nil
+	}
+}
+
 // SwarmOpts configures libp2p to use swarm with opts
 func SwarmOpts(opts ...swarm.Option) Option {
 	return func(cfg *Config) error {
@@ -599,6 +780,88 @@ func SwarmOpts(opts ...swarm.Option) Option {
 	}
 }
 
+// UpgraderOptions configures the upgrader that secures and multiplexes the
+// connections of stream transports like TCP and WebSocket, e.g. its per-stage
+// timeouts and the number of concurrent inbound handshakes.
+// See the upgrader package for the available options.
+func UpgraderOptions(opts ...tptu.Option) Option {
+	return func(cfg *Config) error {
+		if cfg.UpgraderOpts != nil {
+			return errors.New("upgrader options already configured")
+		}
+		cfg.UpgraderOpts = opts
+		return nil
+	}
+}
+
+// Bootstrap configures libp2p to maintain connections to the given bootstrap
+// peers. See the bootstrap package for details and available options.
+func Bootstrap(peers []peer.AddrInfo, opts ...bootstrap.Option) Option {
+	return func(cfg *Config) error {
+		if len(peers) == 0 {
+			return errors.New("no bootstrap peers given")
+		}
+		if cfg.BootstrapPeers != nil {
+			return errors.New("bootstrap peers already configured")
+		}
+		cfg.BootstrapPeers = peers
+		cfg.BootstrapOpts = opts
+		return nil
+	}
+}
+
+// KeepAlive configures libp2p to ping connections that have been idle for a
+// while, so that NATs and load balancers don't drop them. QUIC connections
+// are skipped by default, since QUIC sends its own keep-alives.
+// See ping.KeepAlive for details and available options.
+func KeepAlive(opts ...ping.KeepAliveOption) Option {
+	return func(cfg *Config) error {
+		if cfg.EnableKeepAlive {
+			return errors.New("keep-alive already configured")
+		}
+		cfg.EnableKeepAlive = true
+		cfg.KeepAliveOpts = opts
+		return nil
+	}
+}
+
+// PeerScorer configures libp2p to report feedback about peers to the given
+// scorer: failed identifications and keep-alives, and peers exceeding their
+// resource limits. If the default connection manager is used, peers with a low
+// score are trimmed first. The scorer is owned by the caller and is not closed
+// when the host is closed.
+//
+// To learn about peers exceeding their limits, the default resource manager
+// reports every reservation to the scorer (see
+// peerscore.Scorer.ResourceManagerReporter), which has a small cost for every
+// reservation. Configure the resource manager with the ResourceManager option
+// to avoid this.
+// See peerscore.Scorer for details.
+func PeerScorer(s *peerscore.Scorer) Option {
+	return func(cfg *Config) error {
+		if cfg.PeerScorer != nil {
+			return errors.New("peer scorer already configured")
+		}
+		cfg.PeerScorer = s
+		return nil
+	}
+}
+
+// WithClock configures libp2p to use the given clock for time-dependent
+// services: the default peerstore's address TTLs, the default connection
+// manager (including tag decay), dial backoffs and identify. This makes it
+// possible to write deterministic tests and simulations.
+// Defaults to the real clock.
+func WithClock(cl clock.Clock) Option {
+	return func(cfg *Config) error {
+		if cfg.Clock != nil {
+			return errors.New("clock already configured")
+		}
+		cfg.Clock = cl
+		return nil
+	}
+}
+
 // DisableIdentifyAddressDiscovery disables address discovery using peer provided observed addresses
 // in identify. If you know your public addresses upfront, the recommended way is to use
 // AddressFactory to provide the external adddress to the host and use this option to disable
@@ -610,6 +873,26 @@ func DisableIdentifyAddressDiscovery() Option {
 	}
 }
 
+// IdentifyPushScheduling configures how identify pushes are scheduled when our
+// identify information changes. At most concurrency pushes are sent at the
+// same time, and if budget is positive, a push round stops starting new
+// pushes after budget and continues in a new round. Peers are pushed to in
+// order of importance: protected peers, tagged peers, then peers with open
+// streams.
+func IdentifyPushScheduling(concurrency int, budget time.Duration) Option {
+	return func(cfg *Config) error {
+		if concurrency <= 0 {
+			return errors.New("identify push concurrency must be positive")
+		}
+		if budget < 0 {
+			return errors.New("identify push round budget must not be negative")
+		}
+		cfg.IdentifyPushConcurrency = concurrency
+		cfg.IdentifyPushRoundBudget = budget
+		return nil
+	}
+}
+
 // EnableAutoNATv2 enables autonat v2
 func EnableAutoNATv2() Option {
 	return func(cfg *Config) error {
@@ -635,3 +918,43 @@ func IPv6BlackHoleSuccessCounter(f *swarm.BlackHoleSuccessCounter) Option {
 		return nil
 	}
 }
+
+// Logger configures libp2p to send the logs of the swarm, identify and the
+// relay service to l, instead of the go-log loggers of these subsystems.
+// Every record carries the name of the subsystem in the "system" attribute.
+func Logger(l *slog.Logger) Option {
+	return func(cfg *Config) error {
+		if cfg.Logger != nil {
+			return errors.New("logger already configured")
+		}
+		cfg.Logger = l
+		return nil
+	}
+}
+
+// StreamOpenLimit limits the rate at which streams of a service are opened to
+// a single peer. NewStream waits until the limit allows opening the stream,
+// or fails with basichost.ErrStreamRateLimited if too many calls are waiting
+// already. See basichost.StreamOpenLimit for details.
+func StreamOpenLimit(limit bhost.StreamOpenLimit) Option {
+	return func(cfg *Config) error {
+		cfg.StreamOpenLimits = append(cfg.StreamOpenLimits, limit)
+		return nil
+	}
+}
+
+// RandSource configures libp2p to use src as the source of randomness of the
+// services that make random choices, like relay selection and AutoNAT peer
+// selection. Using a seeded source, e.g. rand.NewSource(seed), makes these
+// choices reproducible, which is useful for tests and simulations.
+// Every service gets its own source, seeded from src when the node is
+// constructed. Cryptographic keys and nonces are not affected.
+func RandSource(src mrand.Source) Option {
+	return func(cfg *Config) error {
+		if cfg.RandSource != nil {
+			return errors.New("rand source already configured")
+		}
+		cfg.RandSource = src
+		return nil
+	}
+}
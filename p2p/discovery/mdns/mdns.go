@@ -3,11 +3,15 @@ package mdns
 import (
 	"context"
 	"errors"
+	"fmt"
 	"io"
 	"math/rand"
+	"net"
 	"strings"
 	"sync"
+	"time"
 
+	"github.com/libp2p/go-libp2p/core/event"
 	"github.com/libp2p/go-libp2p/core/host"
 	"github.com/libp2p/go-libp2p/core/peer"
 
@@ -35,22 +39,76 @@ type Notifee interface {
 	HandlePeerFound(peer.AddrInfo)
 }
 
+// Option is an option for the mDNS service.
+type Option func(*mdnsService)
+
+// WithInterfaces restricts announcing and browsing to the network interfaces
+// with the given names. Only addresses assigned to these interfaces are
+// announced. By default, all multicast capable interfaces are used.
+func WithInterfaces(names ...string) Option {
+	return func(s *mdnsService) {
+		s.ifaceNames = names
+	}
+}
+
+// WithIPVersions selects whether IPv4 and IPv6 are used, both for the addresses
+// announced and for the multicast traffic. By default, both are used.
+func WithIPVersions(ipv4, ipv6 bool) Option {
+	return func(s *mdnsService) {
+		s.ipv4, s.ipv6 = ipv4, ipv6
+	}
+}
+
+// WithQueryInterval makes the service query the network for peers at the given
+// interval, and report all peers that respond again. By default, the network is
+// queried with an exponential backoff, and every peer is reported once per
+// response it sends.
+func WithQueryInterval(d time.Duration) Option {
+	return func(s *mdnsService) {
+		s.queryInterval = d
+	}
+}
+
+// WithAnnounceInterval makes the service announce itself to the network at the
+// given interval, with the addresses the host is listening on at that time.
+// Every announcement re-registers the service, so peers are told to forget the
+// previous registration first. By default, the service only announces itself
+// when it is started.
+func WithAnnounceInterval(d time.Duration) Option {
+	return func(s *mdnsService) {
+		s.announceInterval = d
+	}
+}
+
 type mdnsService struct {
 	host        host.Host
 	serviceName string
 	peerName    string
 
+	ifaceNames       []string
+	ipv4, ipv6       bool
+	queryInterval    time.Duration
+	announceInterval time.Duration
+	ifaces           []net.Interface
+
 	// The context is canceled when Close() is called.
 	ctx       context.Context
 	ctxCancel context.CancelFunc
 
-	resolverWG sync.WaitGroup
-	server     *zeroconf.Server
+	refCount sync.WaitGroup
+	// Once started, the server is only accessed by the announce goroutine,
+	// until it has stopped.
+	server *zeroconf.Server
 
 	notifee Notifee
+	emitter event.Emitter
 }
 
-func NewMdnsService(host host.Host, serviceName string, notifee Notifee) *mdnsService {
+// NewMdnsService creates a new mDNS discovery service. serviceName is the
+// service tag used to find other peers, it defaults to ServiceName.
+// Found peers are passed to the notifee, if it is not nil, and emitted as
+// EvtMdnsPeerFound events on the event bus of the host.
+func NewMdnsService(host host.Host, serviceName string, notifee Notifee, opts ...Option) *mdnsService {
 	if serviceName == "" {
 		serviceName = ServiceName
 	}
@@ -59,32 +117,113 @@ func NewMdnsService(host host.Host, serviceName string, notifee Notifee) *mdnsSe
 		serviceName: serviceName,
 		peerName:    randomString(32 + rand.Intn(32)), // generate a random string between 32 and 63 characters long
 		notifee:     notifee,
+		ipv4:        true,
+		ipv6:        true,
+	}
+	for _, opt := range opts {
+		opt(s)
 	}
 	s.ctx, s.ctxCancel = context.WithCancel(context.Background())
 	return s
 }
 
 func (s *mdnsService) Start() error {
-	if err := s.startServer(); err != nil {
+	if !s.ipv4 && !s.ipv6 {
+		return errors.New("at least one of IPv4 and IPv6 must be enabled")
+	}
+	for _, name := range s.ifaceNames {
+		iface, err := net.InterfaceByName(name)
+		if err != nil {
+			return fmt.Errorf("failed to find interface %s: %w", name, err)
+		}
+		s.ifaces = append(s.ifaces, *iface)
+	}
+
+	emitter, err := s.host.EventBus().Emitter(new(event.EvtMdnsPeerFound))
+	if err != nil {
 		return err
 	}
+	s.emitter = emitter
+
+	server, err := s.newServer()
+	if err != nil {
+		s.emitter.Close()
+		return err
+	}
+	s.server = server
 	s.startResolver(s.ctx)
+	if s.announceInterval > 0 {
+		s.refCount.Add(1)
+		go s.announce(s.ctx)
+	}
 	return nil
 }
 
 func (s *mdnsService) Close() error {
 	s.ctxCancel()
+	s.refCount.Wait()
 	if s.server != nil {
 		s.server.Shutdown()
 	}
-	s.resolverWG.Wait()
+	if s.emitter != nil {
+		return s.emitter.Close()
+	}
 	return nil
 }
 
+// ipAllowed checks if an IP address matches the configured IP versions and interfaces.
+func (s *mdnsService) ipAllowed(ip net.IP, ifaceIPs map[string]struct{}) bool {
+	if ip.To4() != nil {
+		if !s.ipv4 {
+			return false
+		}
+	} else if !s.ipv6 {
+		return false
+	}
+	if ifaceIPs == nil {
+		return true
+	}
+	_, ok := ifaceIPs[ip.String()]
+	return ok
+}
+
+// interfaceIPs returns the set of IP addresses assigned to the selected
+// interfaces, or nil if no interfaces were selected.
+func (s *mdnsService) interfaceIPs() (map[string]struct{}, error) {
+	if len(s.ifaces) == 0 {
+		return nil, nil
+	}
+	ips := make(map[string]struct{})
+	for _, iface := range s.ifaces {
+		addrs, err := iface.Addrs()
+		if err != nil {
+			return nil, err
+		}
+		for _, addr := range addrs {
+			if ipnet, ok := addr.(*net.IPNet); ok {
+				ips[ipnet.IP.String()] = struct{}{}
+			}
+		}
+	}
+	return ips, nil
+}
+
+func (s *mdnsService) ipType() zeroconf.IPType {
+	switch {
+	case s.ipv4 && !s.ipv6:
+		return zeroconf.IPv4
+	case s.ipv6 && !s.ipv4:
+		return zeroconf.IPv6
+	default:
+		return zeroconf.IPv4AndIPv6
+	}
+}
+
 // We don't really care about the IP addresses, but the spec (and various routers / firewalls) require us
 // to send A and AAAA records.
 func (s *mdnsService) getIPs(addrs []ma.Multiaddr) ([]string, error) {
 	var ip4, ip6 string
+	var ip6IsLinkLocal bool
 	for _, addr := range addrs {
 		first, _ := ma.SplitFirst(addr)
 		if first == nil {
@@ -92,8 +231,14 @@ func (s *mdnsService) getIPs(addrs []ma.Multiaddr) ([]string, error) {
 		}
 		if ip4 == "" && first.Protocol().Code == ma.P_IP4 {
 			ip4 = first.Value()
-		} else if ip6 == "" && first.Protocol().Code == ma.P_IP6 {
-			ip6 = first.Value()
+		} else if first.Protocol().Code == ma.P_IP6 {
+			// Prefer routable IPv6 addresses over link-local ones,
+			// since link-local addresses are useless without a zone.
+			linkLocal := net.ParseIP(first.Value()).IsLinkLocalUnicast()
+			if ip6 == "" || ip6IsLinkLocal && !linkLocal {
+				ip6 = first.Value()
+				ip6IsLinkLocal = linkLocal
+			}
 		}
 	}
 	ips := make([]string, 0, 2)
@@ -109,31 +254,41 @@ func (s *mdnsService) getIPs(addrs []ma.Multiaddr) ([]string, error) {
 	return ips, nil
 }
 
-func (s *mdnsService) startServer() error {
+func (s *mdnsService) newServer() (*zeroconf.Server, error) {
 	interfaceAddrs, err := s.host.Network().InterfaceListenAddresses()
 	if err != nil {
-		return err
+		return nil, err
 	}
 	addrs, err := peer.AddrInfoToP2pAddrs(&peer.AddrInfo{
 		ID:    s.host.ID(),
 		Addrs: interfaceAddrs,
 	})
 	if err != nil {
-		return err
+		return nil, err
+	}
+	ifaceIPs, err := s.interfaceIPs()
+	if err != nil {
+		return nil, err
 	}
 	var txts []string
+	announced := make([]ma.Multiaddr, 0, len(addrs))
 	for _, addr := range addrs {
-		if manet.IsThinWaist(addr) { // don't announce circuit addresses
-			txts = append(txts, dnsaddrPrefix+addr.String())
+		if !manet.IsThinWaist(addr) { // don't announce circuit addresses
+			continue
 		}
+		if ip, err := manet.ToIP(addr); err != nil || !s.ipAllowed(ip, ifaceIPs) {
+			continue
+		}
+		txts = append(txts, dnsaddrPrefix+addr.String())
+		announced = append(announced, addr)
 	}
 
-	ips, err := s.getIPs(addrs)
+	ips, err := s.getIPs(announced)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	server, err := zeroconf.RegisterProxy(
+	return zeroconf.RegisterProxy(
 		s.peerName,
 		s.serviceName,
 		mdnsDomain,
@@ -141,20 +296,40 @@ func (s *mdnsService) startServer() error {
 		s.peerName,
 		ips,
 		txts,
-		nil,
+		s.ifaces,
 	)
-	if err != nil {
-		return err
+}
+
+// announce periodically re-registers the server, which makes it announce
+// itself with the current addresses.
+func (s *mdnsService) announce(ctx context.Context) {
+	defer s.refCount.Done()
+	ticker := time.NewTicker(s.announceInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+		// Shut down the old server first, so that its goodbye doesn't
+		// remove the new registration from the peers' caches.
+		if s.server != nil {
+			s.server.Shutdown()
+		}
+		server, err := s.newServer()
+		if err != nil {
+			log.Debugf("failed to announce mDNS service: %s", err)
+		}
+		s.server = server
 	}
-	s.server = server
-	return nil
 }
 
 func (s *mdnsService) startResolver(ctx context.Context) {
-	s.resolverWG.Add(2)
+	s.refCount.Add(2)
 	entryChan := make(chan *zeroconf.ServiceEntry, 1000)
 	go func() {
-		defer s.resolverWG.Done()
+		defer s.refCount.Done()
 		for entry := range entryChan {
 			// We only care about the TXT records.
 			// Ignore A, AAAA and PTR.
@@ -180,18 +355,76 @@ func (s *mdnsService) startResolver(ctx context.Context) {
 				if info.ID == s.host.ID() {
 					continue
 				}
-				go s.notifee.HandlePeerFound(info)
+				if s.notifee != nil {
+					go s.notifee.HandlePeerFound(info)
+				}
+				if err := s.emitter.Emit(event.EvtMdnsPeerFound{Peer: info}); err != nil {
+					log.Debugf("failed to emit peer found event: %s", err)
+				}
 			}
 		}
 	}()
 	go func() {
-		defer s.resolverWG.Done()
-		if err := zeroconf.Browse(ctx, s.serviceName, mdnsDomain, entryChan); err != nil {
-			log.Debugf("zeroconf browsing failed: %s", err)
+		defer s.refCount.Done()
+		defer close(entryChan)
+		opts := []zeroconf.ClientOption{zeroconf.SelectIPTraffic(s.ipType())}
+		if len(s.ifaces) > 0 {
+			opts = append(opts, zeroconf.SelectIfaces(s.ifaces))
+		}
+		if s.queryInterval <= 0 {
+			if err := s.browse(ctx, entryChan, opts...); err != nil {
+				log.Debugf("zeroconf browsing failed: %s", err)
+			}
+			return
+		}
+
+		for ctx.Err() == nil {
+			roundCtx, cancel := context.WithTimeout(ctx, s.queryInterval)
+			if err := s.browse(roundCtx, entryChan, opts...); err != nil {
+				log.Debugf("zeroconf browsing failed: %s", err)
+				cancel()
+				return
+			}
+			<-roundCtx.Done()
+			cancel()
 		}
 	}()
 }
 
+// browse browses the network for peers until ctx is canceled, and forwards the
+// entries found to entryChan.
+func (s *mdnsService) browse(ctx context.Context, entryChan chan<- *zeroconf.ServiceEntry, opts ...zeroconf.ClientOption) error {
+	entries := make(chan *zeroconf.ServiceEntry, 100)
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- zeroconf.Browse(ctx, s.serviceName, mdnsDomain, entries, opts...)
+	}()
+	for {
+		select {
+		case e, ok := <-entries:
+			if !ok {
+				return <-errChan
+			}
+			entryChan <- e
+		case err := <-errChan:
+			// Browse doesn't send any entries after returning, but it only closes
+			// entries if it failed after it started browsing.
+			for {
+				select {
+				case e, ok := <-entries:
+					if !ok {
+						return err
+					}
+					entryChan <- e
+				default:
+					close(entries)
+					return err
+				}
+			}
+		}
+	}
+}
+
 func randomString(l int) string {
 	const alphabet = "abcdefghijklmnopqrstuvwxyz0123456789"
 	s := make([]byte, 0, l)
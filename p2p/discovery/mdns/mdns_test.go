@@ -6,8 +6,10 @@ import (
 	"time"
 
 	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p/core/event"
 	"github.com/libp2p/go-libp2p/core/peer"
 
+	ma "github.com/multiformats/go-multiaddr"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -96,3 +98,91 @@ func TestOtherDiscovery(t *testing.T) {
 		"expected peers to find each other",
 	)
 }
+
+func TestEventBus(t *testing.T) {
+	host, err := libp2p.New(libp2p.ListenAddrStrings("/ip4/127.0.0.1/tcp/0"))
+	require.NoError(t, err)
+	defer host.Close()
+	sub, err := host.EventBus().Subscribe(new(event.EvtMdnsPeerFound))
+	require.NoError(t, err)
+	defer sub.Close()
+
+	// Announce on the other host only, and only listen for events on this one.
+	s := NewMdnsService(host, "", nil, WithQueryInterval(time.Second))
+	require.NoError(t, s.Start())
+	defer s.Close()
+	other := setupMDNS(t, nil)
+
+	timeout := time.After(25 * time.Second)
+	for {
+		select {
+		case e := <-sub.Out():
+			if e.(event.EvtMdnsPeerFound).Peer.ID == other {
+				return
+			}
+		case <-timeout:
+			t.Fatal("expected to find the other peer")
+		}
+	}
+}
+
+func TestAnnounceInterval(t *testing.T) {
+	const serviceName = "_announce-test._udp"
+	host, err := libp2p.New(libp2p.ListenAddrStrings("/ip4/127.0.0.1/tcp/0"))
+	require.NoError(t, err)
+	defer host.Close()
+	s := NewMdnsService(host, serviceName, nil, WithAnnounceInterval(500*time.Millisecond))
+	require.NoError(t, s.Start())
+	defer s.Close()
+
+	other, err := libp2p.New(libp2p.ListenAddrStrings("/ip4/127.0.0.1/tcp/0"))
+	require.NoError(t, err)
+	defer other.Close()
+	sub, err := other.EventBus().Subscribe(new(event.EvtMdnsPeerFound))
+	require.NoError(t, err)
+	defer sub.Close()
+	otherService := NewMdnsService(other, serviceName, nil)
+	require.NoError(t, otherService.Start())
+	defer otherService.Close()
+
+	// Addresses the host starts listening on later are picked up by the next announcement.
+	addr := ma.StringCast("/ip4/127.0.0.1/udp/0/quic-v1")
+	require.NoError(t, host.Network().Listen(addr))
+	timeout := time.After(25 * time.Second)
+	for {
+		select {
+		case e := <-sub.Out():
+			info := e.(event.EvtMdnsPeerFound).Peer
+			if info.ID != host.ID() {
+				continue
+			}
+			for _, a := range info.Addrs {
+				if _, err := a.ValueForProtocol(ma.P_QUIC_V1); err == nil {
+					return
+				}
+			}
+		case <-timeout:
+			t.Fatal("expected to find the new address")
+		}
+	}
+}
+
+func TestInvalidOptions(t *testing.T) {
+	host, err := libp2p.New(libp2p.ListenAddrStrings("/ip4/127.0.0.1/tcp/0"))
+	require.NoError(t, err)
+	defer host.Close()
+
+	require.Error(t, NewMdnsService(host, "", nil, WithInterfaces("does-not-exist")).Start())
+	require.Error(t, NewMdnsService(host, "", nil, WithIPVersions(false, false)).Start())
+}
+
+func TestIPv6LinkLocalNotPreferred(t *testing.T) {
+	s := &mdnsService{}
+	ips, err := s.getIPs([]ma.Multiaddr{
+		ma.StringCast("/ip6/fe80::1/tcp/1234"),
+		ma.StringCast("/ip6/2001:db8::1/tcp/1234"),
+		ma.StringCast("/ip4/192.168.1.2/tcp/1234"),
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"192.168.1.2", "2001:db8::1"}, ips)
+}
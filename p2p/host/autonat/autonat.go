@@ -45,6 +45,9 @@ type AmbientAutoNAT struct {
 	lastProbeTry time.Time
 	lastProbe    time.Time
 	recentProbes map[peer.ID]time.Time
+	// rng is used to select the peer to probe. It's only used by the
+	// background go routine.
+	rng *rand.Rand
 
 	service *autoNATService
 
@@ -112,6 +115,7 @@ func New(h host.Host, options ...Option) (AutoNAT, error) {
 		emitReachabilityChanged: emitReachabilityChanged,
 		service:                 service,
 		recentProbes:            make(map[peer.ID]time.Time),
+		rng:                     rand.New(conf.randSource),
 	}
 	reachability := network.ReachabilityUnknown
 	as.status.Store(&reachability)
@@ -425,7 +429,7 @@ func (as *AmbientAutoNAT) getPeerToProbe() peer.ID {
 		return ""
 	}
 
-	return candidates[rand.Intn(len(candidates))]
+	return candidates[as.rng.Intn(len(candidates))]
 }
 
 func (as *AmbientAutoNAT) Close() error {
@@ -11,61 +11,108 @@ import (
 
 const metricNamespace = "libp2p_autonat"
 
-var (
-	reachabilityStatus = prometheus.NewGauge(
-		prometheus.GaugeOpts{
-			Namespace: metricNamespace,
-			Name:      "reachability_status",
-			Help:      "Current node reachability",
-		},
-	)
-	reachabilityStatusConfidence = prometheus.NewGauge(
-		prometheus.GaugeOpts{
-			Namespace: metricNamespace,
-			Name:      "reachability_status_confidence",
-			Help:      "Node reachability status confidence",
-		},
-	)
-	receivedDialResponseTotal = prometheus.NewCounterVec(
-		prometheus.CounterOpts{
-			Namespace: metricNamespace,
-			Name:      "received_dial_response_total",
-			Help:      "Count of dial responses for client",
-		},
-		[]string{"response_status"},
-	)
-	outgoingDialResponseTotal = prometheus.NewCounterVec(
-		prometheus.CounterOpts{
-			Namespace: metricNamespace,
-			Name:      "outgoing_dial_response_total",
-			Help:      "Count of dial responses for server",
-		},
-		[]string{"response_status"},
-	)
-	outgoingDialRefusedTotal = prometheus.NewCounterVec(
-		prometheus.CounterOpts{
-			Namespace: metricNamespace,
-			Name:      "outgoing_dial_refused_total",
-			Help:      "Count of dial requests refused by server",
-		},
-		[]string{"refusal_reason"},
-	)
-	nextProbeTimestamp = prometheus.NewGauge(
-		prometheus.GaugeOpts{
-			Namespace: metricNamespace,
-			Name:      "next_probe_timestamp",
-			Help:      "Time of next probe",
-		},
-	)
-	collectors = []prometheus.Collector{
-		reachabilityStatus,
-		reachabilityStatusConfidence,
-		receivedDialResponseTotal,
-		outgoingDialResponseTotal,
-		outgoingDialRefusedTotal,
-		nextProbeTimestamp,
+type metricsCollectors struct {
+	reachabilityStatus           prometheus.Gauge
+	reachabilityStatusConfidence prometheus.Gauge
+	receivedDialResponseTotal    *prometheus.CounterVec
+	outgoingDialResponseTotal    *prometheus.CounterVec
+	outgoingDialRefusedTotal     *prometheus.CounterVec
+	incomingDialRequestTotal     prometheus.Counter
+	outgoingDialBackTotal        *prometheus.CounterVec
+	outgoingDialBackDuration     prometheus.Histogram
+	nextProbeTimestamp           prometheus.Gauge
+}
+
+func newMetricsCollectors() *metricsCollectors {
+	return &metricsCollectors{
+		reachabilityStatus: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace: metricNamespace,
+				Name:      "reachability_status",
+				Help:      "Current node reachability",
+			},
+		),
+		reachabilityStatusConfidence: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace: metricNamespace,
+				Name:      "reachability_status_confidence",
+				Help:      "Node reachability status confidence",
+			},
+		),
+		receivedDialResponseTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: metricNamespace,
+				Name:      "received_dial_response_total",
+				Help:      "Count of dial responses for client",
+			},
+			[]string{"response_status"},
+		),
+		outgoingDialResponseTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: metricNamespace,
+				Name:      "outgoing_dial_response_total",
+				Help:      "Count of dial responses for server",
+			},
+			[]string{"response_status"},
+		),
+		outgoingDialRefusedTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: metricNamespace,
+				Name:      "outgoing_dial_refused_total",
+				Help:      "Count of dial requests refused by server",
+			},
+			[]string{"refusal_reason"},
+		),
+		incomingDialRequestTotal: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Namespace: metricNamespace,
+				Name:      "incoming_dial_request_total",
+				Help:      "Count of dial requests received by server",
+			},
+		),
+		outgoingDialBackTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: metricNamespace,
+				Name:      "outgoing_dial_back_total",
+				Help:      "Count of dial backs performed by server",
+			},
+			[]string{"outcome"},
+		),
+		outgoingDialBackDuration: prometheus.NewHistogram(
+			prometheus.HistogramOpts{
+				Namespace: metricNamespace,
+				Name:      "outgoing_dial_back_duration_seconds",
+				Help:      "Time taken by server to dial back",
+				Buckets:   []float64{0.05, 0.1, 0.25, 0.5, 1, 2, 5, 10, 15, 30},
+			},
+		),
+		nextProbeTimestamp: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace: metricNamespace,
+				Name:      "next_probe_timestamp",
+				Help:      "Time of next probe",
+			},
+		),
 	}
-)
+}
+
+func (c *metricsCollectors) Collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		c.reachabilityStatus,
+		c.reachabilityStatusConfidence,
+		c.receivedDialResponseTotal,
+		c.outgoingDialResponseTotal,
+		c.outgoingDialRefusedTotal,
+		c.incomingDialRequestTotal,
+		c.outgoingDialBackTotal,
+		c.outgoingDialBackDuration,
+		c.nextProbeTimestamp,
+	}
+}
+
+// defaultCollectors are shared by all metrics tracers, unless they register
+// with a metricshelper.LabeledRegisterer.
+var defaultCollectors = newMetricsCollectors()
 
 type MetricsTracer interface {
 	ReachabilityStatus(status network.Reachability)
@@ -76,6 +123,17 @@ type MetricsTracer interface {
 	NextProbeTime(t time.Time)
 }
 
+// ServiceMetricsTracer is an optional interface of a MetricsTracer. If the
+// MetricsTracer implements it, it tracks the dial requests handled by the
+// AutoNAT service.
+type ServiceMetricsTracer interface {
+	// IncomingDialRequest is called for every dial request stream.
+	IncomingDialRequest()
+	// OutgoingDialBack is called after dialing back a peer, with the duration
+	// of the dial.
+	OutgoingDialBack(success bool, d time.Duration)
+}
+
 func getResponseStatus(status pb.Message_ResponseStatus) string {
 	var s string
 	switch status {
@@ -96,14 +154,21 @@ func getResponseStatus(status pb.Message_ResponseStatus) string {
 }
 
 const (
-	rate_limited     = "rate limited"
-	dial_blocked     = "dial blocked"
-	no_valid_address = "no valid address"
+	rate_limited        = "rate limited"
+	peer_rate_limited   = "peer rate limited"
+	concurrency_limited = "concurrency limited"
+	dial_blocked        = "dial blocked"
+	no_valid_address    = "no valid address"
 )
 
-type metricsTracer struct{}
+type metricsTracer struct {
+	*metricsCollectors
+}
 
-var _ MetricsTracer = &metricsTracer{}
+var (
+	_ MetricsTracer        = &metricsTracer{}
+	_ ServiceMetricsTracer = &metricsTracer{}
+)
 
 type metricsTracerSetting struct {
 	reg prometheus.Registerer
@@ -124,39 +189,54 @@ func NewMetricsTracer(opts ...MetricsTracerOption) MetricsTracer {
 	for _, opt := range opts {
 		opt(setting)
 	}
-	metricshelper.RegisterCollectors(setting.reg, collectors...)
-	return &metricsTracer{}
+	return &metricsTracer{metricshelper.Collectors(setting.reg, defaultCollectors, newMetricsCollectors)}
 }
 
 func (mt *metricsTracer) ReachabilityStatus(status network.Reachability) {
-	reachabilityStatus.Set(float64(status))
+	mt.reachabilityStatus.Set(float64(status))
 }
 
 func (mt *metricsTracer) ReachabilityStatusConfidence(confidence int) {
-	reachabilityStatusConfidence.Set(float64(confidence))
+	mt.reachabilityStatusConfidence.Set(float64(confidence))
 }
 
 func (mt *metricsTracer) ReceivedDialResponse(status pb.Message_ResponseStatus) {
 	tags := metricshelper.GetStringSlice()
 	defer metricshelper.PutStringSlice(tags)
 	*tags = append(*tags, getResponseStatus(status))
-	receivedDialResponseTotal.WithLabelValues(*tags...).Inc()
+	mt.receivedDialResponseTotal.WithLabelValues(*tags...).Inc()
 }
 
 func (mt *metricsTracer) OutgoingDialResponse(status pb.Message_ResponseStatus) {
 	tags := metricshelper.GetStringSlice()
 	defer metricshelper.PutStringSlice(tags)
 	*tags = append(*tags, getResponseStatus(status))
-	outgoingDialResponseTotal.WithLabelValues(*tags...).Inc()
+	mt.outgoingDialResponseTotal.WithLabelValues(*tags...).Inc()
 }
 
 func (mt *metricsTracer) OutgoingDialRefused(reason string) {
 	tags := metricshelper.GetStringSlice()
 	defer metricshelper.PutStringSlice(tags)
 	*tags = append(*tags, reason)
-	outgoingDialRefusedTotal.WithLabelValues(*tags...).Inc()
+	mt.outgoingDialRefusedTotal.WithLabelValues(*tags...).Inc()
+}
+
+func (mt *metricsTracer) IncomingDialRequest() {
+	mt.incomingDialRequestTotal.Inc()
+}
+
+func (mt *metricsTracer) OutgoingDialBack(success bool, d time.Duration) {
+	tags := metricshelper.GetStringSlice()
+	defer metricshelper.PutStringSlice(tags)
+	if success {
+		*tags = append(*tags, "success")
+	} else {
+		*tags = append(*tags, "failed")
+	}
+	mt.outgoingDialBackTotal.WithLabelValues(*tags...).Inc()
+	mt.outgoingDialBackDuration.Observe(d.Seconds())
 }
 
 func (mt *metricsTracer) NextProbeTime(t time.Time) {
-	nextProbeTimestamp.Set(float64(t.Unix()))
+	mt.nextProbeTimestamp.Set(float64(t.Unix()))
 }
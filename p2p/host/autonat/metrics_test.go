@@ -48,7 +48,7 @@ func BenchmarkServerDialRefused(b *testing.B) {
 }
 
 func TestMetricsNoAllocNoCover(t *testing.T) {
-	mt := NewMetricsTracer()
+	mt := NewMetricsTracer().(*metricsTracer)
 	statuses := []network.Reachability{
 		network.ReachabilityPublic,
 		network.ReachabilityPrivate,
@@ -62,6 +62,8 @@ func TestMetricsNoAllocNoCover(t *testing.T) {
 	}
 	reasons := []string{
 		rate_limited,
+		peer_rate_limited,
+		concurrency_limited,
 		"bad request",
 		"no valid address",
 	}
@@ -71,6 +73,8 @@ func TestMetricsNoAllocNoCover(t *testing.T) {
 		"ReceivedDialResponse":         func() { mt.ReceivedDialResponse(respStatuses[rand.Intn(len(respStatuses))]) },
 		"OutgoingDialResponse":         func() { mt.OutgoingDialResponse(respStatuses[rand.Intn(len(respStatuses))]) },
 		"OutgoingDialRefused":          func() { mt.OutgoingDialRefused(reasons[rand.Intn(len(reasons))]) },
+		"IncomingDialRequest":          func() { mt.IncomingDialRequest() },
+		"OutgoingDialBack":             func() { mt.OutgoingDialBack(rand.Intn(2) == 1, time.Duration(rand.Intn(1000))*time.Millisecond) },
 		"NextProbeTime":                func() { mt.NextProbeTime(time.Now()) },
 	}
 	for method, f := range tests {
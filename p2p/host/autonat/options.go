@@ -2,6 +2,7 @@ package autonat
 
 import (
 	"errors"
+	"math/rand"
 	"time"
 
 	"github.com/libp2p/go-libp2p/core/host"
@@ -18,6 +19,7 @@ type config struct {
 	forceReachability bool
 	reachability      network.Reachability
 	metricsTracer     MetricsTracer
+	randSource        rand.Source
 
 	// client
 	bootDelay          time.Duration
@@ -33,6 +35,7 @@ type config struct {
 	throttlePeerMax     int
 	throttleResetPeriod time.Duration
 	throttleResetJitter time.Duration
+	maxConcurrentDials  int
 }
 
 var defaults = func(c *config) error {
@@ -48,6 +51,7 @@ var defaults = func(c *config) error {
 	c.throttlePeerMax = 3
 	c.throttleResetPeriod = 1 * time.Minute
 	c.throttleResetJitter = 15 * time.Second
+	c.randSource = rand.NewSource(rand.Int63())
 	return nil
 }
 
@@ -144,6 +148,57 @@ func WithPeerThrottling(amount int) Option {
 	}
 }
 
+// WithThrottleResetJitter overrides the random jitter added to each throttle
+// reset period. By default, the jitter is a quarter of the reset interval.
+// Must be applied after WithThrottling, which resets the jitter.
+func WithThrottleResetJitter(jitter time.Duration) Option {
+	return func(c *config) error {
+		if jitter < 0 {
+			return errors.New("throttle reset jitter must not be negative")
+		}
+		c.throttleResetJitter = jitter
+		return nil
+	}
+}
+
+// WithMaxConcurrentDials limits the number of dial-backs this node will perform
+// in parallel when acting as a server. Requests arriving while the limit is
+// reached are refused. A value of 0 (the default) disables the limit.
+func WithMaxConcurrentDials(n int) Option {
+	return func(c *config) error {
+		if n < 0 {
+			return errors.New("max concurrent dials must not be negative")
+		}
+		c.maxConcurrentDials = n
+		return nil
+	}
+}
+
+// WithDialTimeout sets the timeout for a single dial-back when acting as a
+// server.
+func WithDialTimeout(timeout time.Duration) Option {
+	return func(c *config) error {
+		if timeout <= 0 {
+			return errors.New("dial timeout must be positive")
+		}
+		c.dialTimeout = timeout
+		return nil
+	}
+}
+
+// WithRandSource sets the source of randomness used to select the peers to
+// probe and to jitter the throttle reset period of the service.
+// Using a seeded source makes AutoNAT reproducible, e.g. in simulations.
+func WithRandSource(src rand.Source) Option {
+	return func(c *config) error {
+		if src == nil {
+			return errors.New("rand source must not be nil")
+		}
+		c.randSource = src
+		return nil
+	}
+}
+
 // WithMetricsTracer uses mt to track autonat metrics
 func WithMetricsTracer(mt MetricsTracer) Option {
 	return func(c *config) error {
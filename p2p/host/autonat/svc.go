@@ -32,11 +32,15 @@ type autoNATService struct {
 	backgroundRunning chan struct{} // closed when background exits
 
 	config *config
+	// rng jitters the throttle reset period. It's only used by the background
+	// go routine.
+	rng *rand.Rand
 
 	// rate limiter
 	mx         sync.Mutex
 	reqs       map[peer.ID]int
 	globalReqs int
+	inflight   int
 }
 
 // NewAutoNATService creates a new AutoNATService instance attached to a host
@@ -46,6 +50,7 @@ func newAutoNATService(c *config) (*autoNATService, error) {
 	}
 	return &autoNATService{
 		config: c,
+		rng:    rand.New(rand.NewSource(c.randSource.Int63())),
 		reqs:   make(map[peer.ID]int),
 	}, nil
 }
@@ -69,6 +74,9 @@ func (as *autoNATService) handleStream(s network.Stream) {
 
 	pid := s.Conn().RemotePeer()
 	log.Debugf("New stream from %s", pid)
+	if mt, ok := as.config.metricsTracer.(ServiceMetricsTracer); ok {
+		mt.IncomingDialRequest()
+	}
 
 	r := pbio.NewDelimitedReader(s, maxMsgSize)
 	w := pbio.NewDelimitedWriter(s)
@@ -207,19 +215,34 @@ func (as *autoNATService) handleDial(p peer.ID, obsaddr ma.Multiaddr, mpi *pb.Me
 func (as *autoNATService) doDial(pi peer.AddrInfo) *pb.Message_DialResponse {
 	// rate limit check
 	as.mx.Lock()
+	var reason string
 	count := as.reqs[pi.ID]
-	if count >= as.config.throttlePeerMax || (as.config.throttleGlobalMax > 0 &&
-		as.globalReqs >= as.config.throttleGlobalMax) {
+	switch {
+	case count >= as.config.throttlePeerMax:
+		reason = peer_rate_limited
+	case as.config.throttleGlobalMax > 0 && as.globalReqs >= as.config.throttleGlobalMax:
+		reason = rate_limited
+	case as.config.maxConcurrentDials > 0 && as.inflight >= as.config.maxConcurrentDials:
+		reason = concurrency_limited
+	}
+	if reason != "" {
 		as.mx.Unlock()
 		if as.config.metricsTracer != nil {
-			as.config.metricsTracer.OutgoingDialRefused(rate_limited)
+			as.config.metricsTracer.OutgoingDialRefused(reason)
 		}
 		return newDialResponseError(pb.Message_E_DIAL_REFUSED, "too many dials")
 	}
 	as.reqs[pi.ID] = count + 1
 	as.globalReqs++
+	as.inflight++
 	as.mx.Unlock()
 
+	defer func() {
+		as.mx.Lock()
+		as.inflight--
+		as.mx.Unlock()
+	}()
+
 	ctx, cancel := context.WithTimeout(context.Background(), as.config.dialTimeout)
 	defer cancel()
 
@@ -232,7 +255,11 @@ func (as *autoNATService) doDial(pi peer.AddrInfo) *pb.Message_DialResponse {
 		as.config.dialer.Peerstore().RemovePeer(pi.ID)
 	}()
 
+	start := time.Now()
 	conn, err := as.config.dialer.DialPeer(ctx, pi.ID)
+	if mt, ok := as.config.metricsTracer.(ServiceMetricsTracer); ok {
+		mt.OutgoingDialBack(err == nil, time.Since(start))
+	}
 	if err != nil {
 		log.Debugf("error dialing %s: %s", pi.ID, err.Error())
 		// wait for the context to timeout to avoid leaking timing information
@@ -291,7 +318,7 @@ func (as *autoNATService) background(ctx context.Context) {
 			as.reqs = make(map[peer.ID]int)
 			as.globalReqs = 0
 			as.mx.Unlock()
-			jitter := rand.Float32() * float32(as.config.throttleResetJitter)
+			jitter := as.rng.Float32() * float32(as.config.throttleResetJitter)
 			timer.Reset(as.config.throttleResetPeriod + time.Duration(int64(jitter)))
 		case <-ctx.Done():
 			return
@@ -167,6 +167,49 @@ func TestAutoNATServiceGlobalLimiter(t *testing.T) {
 	}
 }
 
+func TestAutoNATServiceConcurrencyLimiter(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c := makeAutoNATConfig(t)
+	defer c.host.Close()
+	defer c.dialer.Close()
+
+	c.dialTimeout = time.Second
+	c.throttlePeerMax = 10
+	c.maxConcurrentDials = 1
+	svc := makeAutoNATService(t, c)
+
+	hc, ac := makeAutoNATClient(t)
+	defer hc.Close()
+	connect(t, c.host, hc)
+
+	// pretend another dial back is in progress
+	svc.mx.Lock()
+	svc.inflight = 1
+	svc.mx.Unlock()
+
+	err := ac.DialBack(ctx, c.host.ID())
+	if err == nil {
+		t.Fatal("Dial back succeeded unexpectedly!")
+	}
+	if !IsDialRefused(err) {
+		t.Fatal(err)
+	}
+
+	svc.mx.Lock()
+	svc.inflight = 0
+	svc.mx.Unlock()
+
+	err = ac.DialBack(ctx, c.host.ID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc.mx.Lock()
+	require.Zero(t, svc.inflight)
+	svc.mx.Unlock()
+}
+
 func TestAutoNATServiceRateLimitJitter(t *testing.T) {
 	c := makeAutoNATConfig(t)
 	defer c.host.Close()
@@ -11,105 +11,123 @@ import (
 
 const metricNamespace = "libp2p_autorelay"
 
-var (
-	status = prometheus.NewGauge(prometheus.GaugeOpts{
-		Namespace: metricNamespace,
-		Name:      "status",
-		Help:      "relay finder active",
-	})
-	reservationsOpenedTotal = prometheus.NewCounter(
-		prometheus.CounterOpts{
-			Namespace: metricNamespace,
-			Name:      "reservations_opened_total",
-			Help:      "Reservations Opened",
-		},
-	)
-	reservationsClosedTotal = prometheus.NewCounter(
-		prometheus.CounterOpts{
-			Namespace: metricNamespace,
-			Name:      "reservations_closed_total",
-			Help:      "Reservations Closed",
-		},
-	)
-	reservationRequestsOutcomeTotal = prometheus.NewCounterVec(
-		prometheus.CounterOpts{
-			Namespace: metricNamespace,
-			Name:      "reservation_requests_outcome_total",
-			Help:      "Reservation Request Outcome",
-		},
-		[]string{"request_type", "outcome"},
-	)
-
-	relayAddressesUpdatedTotal = prometheus.NewCounter(
-		prometheus.CounterOpts{
-			Namespace: metricNamespace,
-			Name:      "relay_addresses_updated_total",
-			Help:      "Relay Addresses Updated Count",
-		},
-	)
-	relayAddressesCount = prometheus.NewGauge(
-		prometheus.GaugeOpts{
-			Namespace: metricNamespace,
-			Name:      "relay_addresses_count",
-			Help:      "Relay Addresses Count",
-		},
-	)
+type metricsCollectors struct {
+	status                          prometheus.Gauge
+	reservationsOpenedTotal         prometheus.Counter
+	reservationsClosedTotal         prometheus.Counter
+	reservationRequestsOutcomeTotal *prometheus.CounterVec
+	relayAddressesUpdatedTotal      prometheus.Counter
+	relayAddressesCount             prometheus.Gauge
+	candidatesCircuitV2SupportTotal *prometheus.CounterVec
+	candidatesTotal                 *prometheus.CounterVec
+	candLoopState                   prometheus.Gauge
+	scheduledWorkTime               *prometheus.GaugeVec
+	desiredReservations             prometheus.Gauge
+}
 
-	candidatesCircuitV2SupportTotal = prometheus.NewCounterVec(
-		prometheus.CounterOpts{
-			Namespace: metricNamespace,
-			Name:      "candidates_circuit_v2_support_total",
-			Help:      "Candidates supporting circuit v2",
-		},
-		[]string{"support"},
-	)
-	candidatesTotal = prometheus.NewCounterVec(
-		prometheus.CounterOpts{
-			Namespace: metricNamespace,
-			Name:      "candidates_total",
-			Help:      "Candidates Total",
-		},
-		[]string{"type"},
-	)
-	candLoopState = prometheus.NewGauge(
-		prometheus.GaugeOpts{
+func newMetricsCollectors() *metricsCollectors {
+	return &metricsCollectors{
+		status: prometheus.NewGauge(prometheus.GaugeOpts{
 			Namespace: metricNamespace,
-			Name:      "candidate_loop_state",
-			Help:      "Candidate Loop State",
-		},
-	)
+			Name:      "status",
+			Help:      "relay finder active",
+		}),
+		reservationsOpenedTotal: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Namespace: metricNamespace,
+				Name:      "reservations_opened_total",
+				Help:      "Reservations Opened",
+			},
+		),
+		reservationsClosedTotal: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Namespace: metricNamespace,
+				Name:      "reservations_closed_total",
+				Help:      "Reservations Closed",
+			},
+		),
+		reservationRequestsOutcomeTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: metricNamespace,
+				Name:      "reservation_requests_outcome_total",
+				Help:      "Reservation Request Outcome",
+			},
+			[]string{"request_type", "outcome"},
+		),
+		relayAddressesUpdatedTotal: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Namespace: metricNamespace,
+				Name:      "relay_addresses_updated_total",
+				Help:      "Relay Addresses Updated Count",
+			},
+		),
+		relayAddressesCount: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace: metricNamespace,
+				Name:      "relay_addresses_count",
+				Help:      "Relay Addresses Count",
+			},
+		),
+		candidatesCircuitV2SupportTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: metricNamespace,
+				Name:      "candidates_circuit_v2_support_total",
+				Help:      "Candidates supporting circuit v2",
+			},
+			[]string{"support"},
+		),
+		candidatesTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: metricNamespace,
+				Name:      "candidates_total",
+				Help:      "Candidates Total",
+			},
+			[]string{"type"},
+		),
+		candLoopState: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace: metricNamespace,
+				Name:      "candidate_loop_state",
+				Help:      "Candidate Loop State",
+			},
+		),
+		scheduledWorkTime: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: metricNamespace,
+				Name:      "scheduled_work_time",
+				Help:      "Scheduled Work Times",
+			},
+			[]string{"work_type"},
+		),
+		desiredReservations: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace: metricNamespace,
+				Name:      "desired_reservations",
+				Help:      "Desired Reservations",
+			},
+		),
+	}
+}
 
-	scheduledWorkTime = prometheus.NewGaugeVec(
-		prometheus.GaugeOpts{
-			Namespace: metricNamespace,
-			Name:      "scheduled_work_time",
-			Help:      "Scheduled Work Times",
-		},
-		[]string{"work_type"},
-	)
-
-	desiredReservations = prometheus.NewGauge(
-		prometheus.GaugeOpts{
-			Namespace: metricNamespace,
-			Name:      "desired_reservations",
-			Help:      "Desired Reservations",
-		},
-	)
-
-	collectors = []prometheus.Collector{
-		status,
-		reservationsOpenedTotal,
-		reservationsClosedTotal,
-		reservationRequestsOutcomeTotal,
-		relayAddressesUpdatedTotal,
-		relayAddressesCount,
-		candidatesCircuitV2SupportTotal,
-		candidatesTotal,
-		candLoopState,
-		scheduledWorkTime,
-		desiredReservations,
+func (c *metricsCollectors) Collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		c.status,
+		c.reservationsOpenedTotal,
+		c.reservationsClosedTotal,
+		c.reservationRequestsOutcomeTotal,
+		c.relayAddressesUpdatedTotal,
+		c.relayAddressesCount,
+		c.candidatesCircuitV2SupportTotal,
+		c.candidatesTotal,
+		c.candLoopState,
+		c.scheduledWorkTime,
+		c.desiredReservations,
 	}
-)
+}
+
+// defaultCollectors are shared by all metrics tracers, unless they register
+// with a metricshelper.LabeledRegisterer.
+var defaultCollectors = newMetricsCollectors()
 
 type candidateLoopState int
 
@@ -141,7 +159,9 @@ type MetricsTracer interface {
 	DesiredReservations(int)
 }
 
-type metricsTracer struct{}
+type metricsTracer struct {
+	*metricsCollectors
+}
 
 var _ MetricsTracer = &metricsTracer{}
 
@@ -164,31 +184,31 @@ func NewMetricsTracer(opts ...MetricsTracerOption) MetricsTracer {
 	for _, opt := range opts {
 		opt(setting)
 	}
-	metricshelper.RegisterCollectors(setting.reg, collectors...)
+	c := metricshelper.Collectors(setting.reg, defaultCollectors, newMetricsCollectors)
 
 	// Initialise these counters to 0 otherwise the first reservation requests aren't handled
 	// correctly when using promql increase function
-	reservationRequestsOutcomeTotal.WithLabelValues("refresh", "success")
-	reservationRequestsOutcomeTotal.WithLabelValues("new", "success")
-	candidatesCircuitV2SupportTotal.WithLabelValues("yes")
-	candidatesCircuitV2SupportTotal.WithLabelValues("no")
-	return &metricsTracer{}
+	c.reservationRequestsOutcomeTotal.WithLabelValues("refresh", "success")
+	c.reservationRequestsOutcomeTotal.WithLabelValues("new", "success")
+	c.candidatesCircuitV2SupportTotal.WithLabelValues("yes")
+	c.candidatesCircuitV2SupportTotal.WithLabelValues("no")
+	return &metricsTracer{c}
 }
 
 func (mt *metricsTracer) RelayFinderStatus(isActive bool) {
 	if isActive {
-		status.Set(1)
+		mt.status.Set(1)
 	} else {
-		status.Set(0)
+		mt.status.Set(0)
 	}
 }
 
 func (mt *metricsTracer) ReservationEnded(cnt int) {
-	reservationsClosedTotal.Add(float64(cnt))
+	mt.reservationsClosedTotal.Add(float64(cnt))
 }
 
 func (mt *metricsTracer) ReservationOpened(cnt int) {
-	reservationsOpenedTotal.Add(float64(cnt))
+	mt.reservationsOpenedTotal.Add(float64(cnt))
 }
 
 func (mt *metricsTracer) ReservationRequestFinished(isRefresh bool, err error) {
@@ -201,19 +221,19 @@ func (mt *metricsTracer) ReservationRequestFinished(isRefresh bool, err error) {
 		*tags = append(*tags, "new")
 	}
 	*tags = append(*tags, getReservationRequestStatus(err))
-	reservationRequestsOutcomeTotal.WithLabelValues(*tags...).Inc()
+	mt.reservationRequestsOutcomeTotal.WithLabelValues(*tags...).Inc()
 
 	if !isRefresh && err == nil {
-		reservationsOpenedTotal.Inc()
+		mt.reservationsOpenedTotal.Inc()
 	}
 }
 
 func (mt *metricsTracer) RelayAddressUpdated() {
-	relayAddressesUpdatedTotal.Inc()
+	mt.relayAddressesUpdatedTotal.Inc()
 }
 
 func (mt *metricsTracer) RelayAddressCount(cnt int) {
-	relayAddressesCount.Set(float64(cnt))
+	mt.relayAddressesCount.Set(float64(cnt))
 }
 
 func (mt *metricsTracer) CandidateChecked(supportsCircuitV2 bool) {
@@ -224,25 +244,25 @@ func (mt *metricsTracer) CandidateChecked(supportsCircuitV2 bool) {
 	} else {
 		*tags = append(*tags, "no")
 	}
-	candidatesCircuitV2SupportTotal.WithLabelValues(*tags...).Inc()
+	mt.candidatesCircuitV2SupportTotal.WithLabelValues(*tags...).Inc()
 }
 
 func (mt *metricsTracer) CandidateAdded(cnt int) {
 	tags := metricshelper.GetStringSlice()
 	defer metricshelper.PutStringSlice(tags)
 	*tags = append(*tags, "added")
-	candidatesTotal.WithLabelValues(*tags...).Add(float64(cnt))
+	mt.candidatesTotal.WithLabelValues(*tags...).Add(float64(cnt))
 }
 
 func (mt *metricsTracer) CandidateRemoved(cnt int) {
 	tags := metricshelper.GetStringSlice()
 	defer metricshelper.PutStringSlice(tags)
 	*tags = append(*tags, "removed")
-	candidatesTotal.WithLabelValues(*tags...).Add(float64(cnt))
+	mt.candidatesTotal.WithLabelValues(*tags...).Add(float64(cnt))
 }
 
 func (mt *metricsTracer) CandidateLoopState(state candidateLoopState) {
-	candLoopState.Set(float64(state))
+	mt.candLoopState.Set(float64(state))
 }
 
 func (mt *metricsTracer) ScheduledWorkUpdated(scheduledWork *scheduledWorkTimes) {
@@ -250,23 +270,23 @@ func (mt *metricsTracer) ScheduledWorkUpdated(scheduledWork *scheduledWorkTimes)
 	defer metricshelper.PutStringSlice(tags)
 
 	*tags = append(*tags, "allowed peer source call")
-	scheduledWorkTime.WithLabelValues(*tags...).Set(float64(scheduledWork.nextAllowedCallToPeerSource.Unix()))
+	mt.scheduledWorkTime.WithLabelValues(*tags...).Set(float64(scheduledWork.nextAllowedCallToPeerSource.Unix()))
 	*tags = (*tags)[:0]
 
 	*tags = append(*tags, "reservation refresh")
-	scheduledWorkTime.WithLabelValues(*tags...).Set(float64(scheduledWork.nextRefresh.Unix()))
+	mt.scheduledWorkTime.WithLabelValues(*tags...).Set(float64(scheduledWork.nextRefresh.Unix()))
 	*tags = (*tags)[:0]
 
 	*tags = append(*tags, "clear backoff")
-	scheduledWorkTime.WithLabelValues(*tags...).Set(float64(scheduledWork.nextBackoff.Unix()))
+	mt.scheduledWorkTime.WithLabelValues(*tags...).Set(float64(scheduledWork.nextBackoff.Unix()))
 	*tags = (*tags)[:0]
 
 	*tags = append(*tags, "old candidate check")
-	scheduledWorkTime.WithLabelValues(*tags...).Set(float64(scheduledWork.nextOldCandidateCheck.Unix()))
+	mt.scheduledWorkTime.WithLabelValues(*tags...).Set(float64(scheduledWork.nextOldCandidateCheck.Unix()))
 }
 
 func (mt *metricsTracer) DesiredReservations(cnt int) {
-	desiredReservations.Set(float64(cnt))
+	mt.desiredReservations.Set(float64(cnt))
 }
 
 func getReservationRequestStatus(err error) string {
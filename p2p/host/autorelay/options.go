@@ -3,6 +3,7 @@ package autorelay
 import (
 	"context"
 	"errors"
+	"math/rand"
 	"time"
 
 	"github.com/libp2p/go-libp2p/core/peer"
@@ -42,6 +43,8 @@ type config struct {
 	setMinCandidates bool
 	// see WithMetricsTracer
 	metricsTracer MetricsTracer
+	// see WithRandSource
+	randSource rand.Source
 }
 
 var defaultConfig = config{
@@ -224,6 +227,15 @@ func WithMinInterval(interval time.Duration) Option {
 	}
 }
 
+// WithRandSource sets the source of randomness used to select relays.
+// Using a seeded source makes relay selection reproducible, e.g. in simulations.
+func WithRandSource(src rand.Source) Option {
+	return func(c *config) error {
+		c.randSource = src
+		return nil
+	}
+}
+
 // WithMetricsTracer configures autorelay to use mt to track metrics
 func WithMetricsTracer(mt MetricsTracer) Option {
 	return func(c *config) error {
@@ -62,6 +62,7 @@ type relayFinder struct {
 	candidateMx                sync.Mutex
 	candidates                 map[peer.ID]*candidate
 	backoff                    map[peer.ID]time.Time
+	rng                        *rand.Rand    // used to select candidates, guarded by candidateMx
 	maybeConnectToRelayTrigger chan struct{} // cap: 1
 	// Any time _something_ happens that might cause us to need new candidates.
 	// This could be
@@ -90,8 +91,14 @@ func newRelayFinder(host *basic.BasicHost, peerSource PeerSource, conf *config)
 		panic("Can not create a new relayFinder. Need a Peer Source fn or a list of static relays. Refer to the documentation around `libp2p.EnableAutoRelay`")
 	}
 
+	randSource := conf.randSource
+	if randSource == nil {
+		randSource = rand.NewSource(rand.Int63())
+	}
+
 	return &relayFinder{
 		bootTime:                   conf.clock.Now(),
+		rng:                        rand.New(randSource),
 		host:                       host,
 		conf:                       conf,
 		peerSource:                 peerSource,
@@ -701,7 +708,7 @@ func (rf *relayFinder) selectCandidates() []*candidate {
 
 	// TODO: better relay selection strategy; this just selects random relays,
 	// but we should probably use ping latency as the selection metric
-	rand.Shuffle(len(candidates), func(i, j int) {
+	rf.rng.Shuffle(len(candidates), func(i, j int) {
 		candidates[i], candidates[j] = candidates[j], candidates[i]
 	})
 	return candidates
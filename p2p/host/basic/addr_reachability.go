@@ -0,0 +1,265 @@
+package basichost
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/event"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/p2p/host/eventbus"
+	"github.com/libp2p/go-libp2p/p2p/protocol/autonatv2"
+
+	ma "github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
+)
+
+var (
+	// addrReachabilityProbeInterval is the interval at which the reachability
+	// of the public addresses is verified again using AutoNAT v2, once it's
+	// known.
+	addrReachabilityProbeInterval = 10 * time.Minute
+	// addrReachabilityRetryInterval is the interval at which an address is
+	// probed again when the last probe failed, or when more results are needed
+	// to confirm that it is unreachable.
+	addrReachabilityRetryInterval = time.Minute
+	// addrReachabilityProbeTimeout is the timeout for verifying a single address.
+	addrReachabilityProbeTimeout = time.Minute
+	// addrReachabilityConfidence is the number of consecutive results needed
+	// to consider an address unreachable.
+	addrReachabilityConfidence = 3
+	// addrReachabilityMaxConcurrentProbes is the maximum number of addresses
+	// being probed at the same time.
+	addrReachabilityMaxConcurrentProbes = 3
+)
+
+// SetAddrReachability records the reachability of addr, as determined by
+// AutoNAT or some other dial-back probing. Unless the host was constructed
+// with DisableAddrWithdrawal, addresses found unreachable are no longer
+// advertised, though they are still returned by AllAddrs. Peers are notified
+// of changes with an identify push.
+func (h *BasicHost) SetAddrReachability(addr ma.Multiaddr, reachability network.Reachability) {
+	key := string(h.NormalizeMultiaddr(addr).Bytes())
+	h.addrReachabilityMx.Lock()
+	prev := h.addrReachability[key]
+	if reachability == network.ReachabilityUnknown {
+		delete(h.addrReachability, key)
+	} else {
+		h.addrReachability[key] = reachability
+	}
+	h.addrReachabilityMx.Unlock()
+
+	if prev != reachability {
+		h.SignalAddressChange()
+	}
+}
+
+// AddrReachability returns the reachability of addr, as recorded with
+// SetAddrReachability. It's sent to peers along with the address over
+// identify v2.
+func (h *BasicHost) AddrReachability(addr ma.Multiaddr) network.Reachability {
+	h.addrReachabilityMx.RLock()
+	defer h.addrReachabilityMx.RUnlock()
+	return h.addrReachability[string(h.NormalizeMultiaddr(addr).Bytes())]
+}
+
+// UnreachableAddrs returns the addresses of the host that were found
+// unreachable. Unless the host was constructed with DisableAddrWithdrawal,
+// these addresses aren't advertised.
+func (h *BasicHost) UnreachableAddrs() []ma.Multiaddr {
+	all := h.AddrsFactory(h.AllAddrs())
+	h.addrReachabilityMx.RLock()
+	defer h.addrReachabilityMx.RUnlock()
+	var addrs []ma.Multiaddr
+	for _, a := range all {
+		if h.addrReachability[string(h.NormalizeMultiaddr(a).Bytes())] == network.ReachabilityPrivate {
+			addrs = append(addrs, a)
+		}
+	}
+	return addrs
+}
+
+// withdrawUnreachable removes the addresses found unreachable from addrs.
+func (h *BasicHost) withdrawUnreachable(addrs []ma.Multiaddr) []ma.Multiaddr {
+	if h.disableAddrWithdrawal {
+		return addrs
+	}
+	h.addrReachabilityMx.RLock()
+	defer h.addrReachabilityMx.RUnlock()
+	if len(h.addrReachability) == 0 {
+		return addrs
+	}
+	out := make([]ma.Multiaddr, 0, len(addrs))
+	for _, a := range addrs {
+		if h.addrReachability[string(h.NormalizeMultiaddr(a).Bytes())] == network.ReachabilityPrivate {
+			continue
+		}
+		out = append(out, a)
+	}
+	return out
+}
+
+// probeAddrWithAutoNATv2 verifies the reachability of addr using AutoNAT v2.
+func (h *BasicHost) probeAddrWithAutoNATv2(ctx context.Context, addr ma.Multiaddr) (network.Reachability, error) {
+	res, err := h.autonatv2.GetReachability(ctx, []autonatv2.Request{{Addr: addr}})
+	if err != nil {
+		return network.ReachabilityUnknown, err
+	}
+	return res.Reachability, nil
+}
+
+// addrProbeState is the probing state of a public address.
+type addrProbeState struct {
+	addr ma.Multiaddr
+	// reachability is the result of the last successful probe, and count the
+	// number of consecutive probes that agreed with it.
+	reachability network.Reachability
+	count        int
+	nextProbe    time.Time
+	inProgress   bool
+}
+
+type addrProbeResult struct {
+	key          string
+	reachability network.Reachability
+	err          error
+}
+
+// probeAddrReachability verifies the reachability of the public addresses of
+// the host. New addresses are probed right away, and then periodically.
+// An address is only considered unreachable once addrReachabilityConfidence
+// consecutive probes agreed, a single successful dial back is enough to
+// consider it reachable again.
+func (h *BasicHost) probeAddrReachability() {
+	defer h.refCount.Done()
+
+	// get notified of new addresses
+	sub, err := h.eventbus.Subscribe(new(event.EvtLocalAddressesUpdated), eventbus.Name("basichost (addr reachability)"))
+	if err != nil {
+		log.Errorf("failed to subscribe to address updates: %s", err)
+		return
+	}
+	defer sub.Close()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	results := make(chan addrProbeResult, addrReachabilityMaxConcurrentProbes)
+	states := make(map[string]*addrProbeState)
+	inProgress := 0
+
+	timer := h.clock.Timer(0)
+	defer timer.Stop()
+	for {
+		now := h.clock.Now()
+		h.updateAddrProbeStates(states, now)
+
+		// start the probes that are due, and find out when the next one is
+		var next time.Time
+		for key, st := range states {
+			if st.inProgress {
+				continue
+			}
+			if !st.nextProbe.After(now) {
+				if inProgress >= addrReachabilityMaxConcurrentProbes {
+					// started once one of the running probes returns a result
+					continue
+				}
+				st.inProgress = true
+				inProgress++
+				wg.Add(1)
+				go func(key string, addr ma.Multiaddr) {
+					defer wg.Done()
+					ctx, cancel := context.WithTimeout(h.ctx, addrReachabilityProbeTimeout)
+					defer cancel()
+					r, err := h.probeAddr(ctx, addr)
+					select {
+					case results <- addrProbeResult{key: key, reachability: r, err: err}:
+					case <-h.ctx.Done():
+					}
+				}(key, st.addr)
+				continue
+			}
+			if next.IsZero() || st.nextProbe.Before(next) {
+				next = st.nextProbe
+			}
+		}
+		timer.Stop()
+		if !next.IsZero() {
+			timer.Reset(next.Sub(now))
+		}
+
+		select {
+		case <-timer.C:
+		case <-sub.Out():
+		case res := <-results:
+			inProgress--
+			if st, ok := states[res.key]; ok {
+				h.handleAddrProbeResult(st, res, h.clock.Now())
+			}
+		case <-h.ctx.Done():
+			return
+		}
+	}
+}
+
+// updateAddrProbeStates adds the new public addresses to states, so that they
+// are probed right away, and forgets about the addresses we no longer have.
+func (h *BasicHost) updateAddrProbeStates(states map[string]*addrProbeState, now time.Time) {
+	addrs := h.AddrsFactory(h.AllAddrs())
+	known := make(map[string]struct{}, len(addrs))
+	for _, a := range addrs {
+		key := string(h.NormalizeMultiaddr(a).Bytes())
+		known[key] = struct{}{}
+		if isRelayAddr(a) || !manet.IsPublicAddr(a) {
+			continue
+		}
+		if _, ok := states[key]; !ok {
+			states[key] = &addrProbeState{addr: a, nextProbe: now}
+		}
+	}
+	for key, st := range states {
+		// keep the state of addresses being probed until we have the result
+		if _, ok := known[key]; !ok && !st.inProgress {
+			delete(states, key)
+		}
+	}
+
+	h.addrReachabilityMx.Lock()
+	for k := range h.addrReachability {
+		if _, ok := known[k]; !ok {
+			delete(h.addrReachability, k)
+		}
+	}
+	h.addrReachabilityMx.Unlock()
+}
+
+// handleAddrProbeResult records the result of a probe of st, and schedules
+// the next probe.
+func (h *BasicHost) handleAddrProbeResult(st *addrProbeState, res addrProbeResult, now time.Time) {
+	st.inProgress = false
+	if res.err != nil || res.reachability == network.ReachabilityUnknown {
+		if res.err != nil {
+			log.Debugf("failed to verify reachability of %s: %s", st.addr, res.err)
+		}
+		st.nextProbe = now.Add(addrReachabilityRetryInterval)
+		return
+	}
+	if res.reachability == st.reachability {
+		st.count++
+	} else {
+		st.reachability = res.reachability
+		st.count = 1
+	}
+
+	switch {
+	case st.reachability == network.ReachabilityPublic:
+		h.SetAddrReachability(st.addr, network.ReachabilityPublic)
+		st.nextProbe = now.Add(addrReachabilityProbeInterval)
+	case st.count >= addrReachabilityConfidence:
+		h.SetAddrReachability(st.addr, network.ReachabilityPrivate)
+		st.nextProbe = now.Add(addrReachabilityProbeInterval)
+	default:
+		// not confident yet, probe again soon
+		st.nextProbe = now.Add(addrReachabilityRetryInterval)
+	}
+}
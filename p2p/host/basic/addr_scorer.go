@@ -0,0 +1,137 @@
+package basichost
+
+import (
+	"sort"
+
+	"github.com/libp2p/go-libp2p/core/network"
+
+	ma "github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
+)
+
+// AddrScorer scores the addresses the host might advertise.
+//
+// The scores of all registered scorers are added up. The host advertises its
+// addresses ordered by score, highest first, and doesn't advertise addresses
+// with a negative score. Scorers should call BasicHost.SignalAddressChange
+// when the inputs of their scores change.
+type AddrScorer interface {
+	ScoreAddr(addr ma.Multiaddr) float64
+}
+
+// AddrScorerFunc is an adapter to use a function as an AddrScorer.
+type AddrScorerFunc func(addr ma.Multiaddr) float64
+
+func (f AddrScorerFunc) ScoreAddr(addr ma.Multiaddr) float64 { return f(addr) }
+
+// PreferAddrs returns an AddrScorer that adds score to the given addresses.
+// A negative score deprioritizes them, or prevents advertising them if the
+// total score is negative.
+func PreferAddrs(score float64, addrs ...ma.Multiaddr) AddrScorer {
+	return AddrScorerFunc(func(addr ma.Multiaddr) float64 {
+		for _, a := range addrs {
+			if a.Equal(addr) {
+				return score
+			}
+		}
+		return 0
+	})
+}
+
+// SetAddrScorer registers s under the given name, replacing the scorer
+// previously registered under that name.
+func (h *BasicHost) SetAddrScorer(name string, s AddrScorer) {
+	h.addrScorersMx.Lock()
+	h.addrScorers[name] = s
+	h.addrScorersMx.Unlock()
+	h.SignalAddressChange()
+}
+
+// RemoveAddrScorer removes the scorer registered under the given name.
+func (h *BasicHost) RemoveAddrScorer(name string) {
+	h.addrScorersMx.Lock()
+	delete(h.addrScorers, name)
+	h.addrScorersMx.Unlock()
+	h.SignalAddressChange()
+}
+
+// rankAddrs orders addrs by score and caps them to maxAdvertisedAddrs.
+// Scoring only applies if a scorer was registered or the number of addresses
+// is capped. In that case, the host's own scorers are consulted as well.
+func (h *BasicHost) rankAddrs(addrs []ma.Multiaddr) []ma.Multiaddr {
+	h.addrScorersMx.RLock()
+	if len(h.addrScorers) == 0 && h.maxAdvertisedAddrs <= 0 {
+		h.addrScorersMx.RUnlock()
+		return addrs
+	}
+	scorers := make([]AddrScorer, 0, len(h.addrScorers)+2)
+	for _, s := range h.addrScorers {
+		scorers = append(scorers, s)
+	}
+	h.addrScorersMx.RUnlock()
+	scorers = append(scorers, h.reachabilityScorer(), h.natMappingScorer())
+
+	type scoredAddr struct {
+		addr  ma.Multiaddr
+		score float64
+	}
+	scored := make([]scoredAddr, 0, len(addrs))
+	for _, a := range addrs {
+		var score float64
+		for _, s := range scorers {
+			score += s.ScoreAddr(a)
+		}
+		if score < 0 {
+			continue
+		}
+		scored = append(scored, scoredAddr{addr: a, score: score})
+	}
+	// Keep the original order for addresses with the same score.
+	sort.SliceStable(scored, func(i, j int) bool { return scored[i].score > scored[j].score })
+	if h.maxAdvertisedAddrs > 0 && len(scored) > h.maxAdvertisedAddrs {
+		scored = scored[:h.maxAdvertisedAddrs]
+	}
+	out := make([]ma.Multiaddr, 0, len(scored))
+	for _, s := range scored {
+		out = append(out, s.addr)
+	}
+	return out
+}
+
+// reachabilityScorer prefers the addresses AutoNAT determined we're reachable
+// on: public addresses if we're publicly reachable, relay addresses if not.
+func (h *BasicHost) reachabilityScorer() AddrScorer {
+	var reachability network.Reachability
+	if an := h.GetAutoNat(); an != nil {
+		reachability = an.Status()
+	}
+	return AddrScorerFunc(func(addr ma.Multiaddr) float64 {
+		isRelay := isRelayAddr(addr)
+		switch {
+		case reachability == network.ReachabilityPublic && !isRelay && manet.IsPublicAddr(addr):
+			return 1
+		case reachability == network.ReachabilityPrivate && isRelay:
+			return 1
+		}
+		return 0
+	})
+}
+
+// natMappingScorer prefers the addresses we obtained a port mapping for.
+func (h *BasicHost) natMappingScorer() AddrScorer {
+	if h.natmgr == nil || !h.natmgr.HasDiscoveredNAT() {
+		return AddrScorerFunc(func(ma.Multiaddr) float64 { return 0 })
+	}
+	var mapped []ma.Multiaddr
+	for _, listen := range h.Network().ListenAddresses() {
+		if ext := h.natmgr.GetMapping(listen); ext != nil {
+			mapped = append(mapped, ext)
+		}
+	}
+	return PreferAddrs(1, mapped...)
+}
+
+func isRelayAddr(addr ma.Multiaddr) bool {
+	_, err := addr.ValueForProtocol(ma.P_CIRCUIT)
+	return err == nil
+}
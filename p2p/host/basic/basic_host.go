@@ -5,6 +5,8 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"log/slog"
+	"math/rand"
 	"net"
 	"slices"
 	"sync"
@@ -24,6 +26,7 @@ import (
 	"github.com/libp2p/go-libp2p/p2p/host/eventbus"
 	"github.com/libp2p/go-libp2p/p2p/host/pstoremanager"
 	"github.com/libp2p/go-libp2p/p2p/host/relaysvc"
+	inat "github.com/libp2p/go-libp2p/p2p/net/nat"
 	"github.com/libp2p/go-libp2p/p2p/protocol/autonatv2"
 	relayv2 "github.com/libp2p/go-libp2p/p2p/protocol/circuitv2/relay"
 	"github.com/libp2p/go-libp2p/p2p/protocol/holepunch"
@@ -35,6 +38,7 @@ import (
 
 	"github.com/libp2p/go-netroute"
 
+	"github.com/benbjohnson/clock"
 	logging "github.com/ipfs/go-log/v2"
 	ma "github.com/multiformats/go-multiaddr"
 	madns "github.com/multiformats/go-multiaddr-dns"
@@ -76,6 +80,7 @@ type BasicHost struct {
 
 	network      network.Network
 	psManager    *pstoremanager.PeerstoreManager
+	connectivity *connectivityTracker
 	mux          *msmux.MultistreamMuxer[protocol.ID]
 	ids          identify.IDService
 	hps          *holepunch.Service
@@ -93,6 +98,8 @@ type BasicHost struct {
 	emitters struct {
 		evtLocalProtocolsUpdated event.Emitter
 		evtLocalAddrsUpdated     event.Emitter
+		evtNATPortMappingChanged event.Emitter
+		evtConnManagerTrimmed    event.Emitter
 	}
 
 	addrChangeChan chan struct{}
@@ -108,6 +115,20 @@ type BasicHost struct {
 	autoNat autonat.AutoNAT
 
 	autonatv2 *autonatv2.AutoNAT
+
+	addrScorersMx      sync.RWMutex
+	addrScorers        map[string]AddrScorer
+	maxAdvertisedAddrs int
+
+	addrReachabilityMx    sync.RWMutex
+	addrReachability      map[string]network.Reachability // keyed by the bytes of the normalized address
+	disableAddrWithdrawal bool
+	// probeAddr verifies the reachability of an address, using AutoNAT v2.
+	probeAddr func(context.Context, ma.Multiaddr) (network.Reachability, error)
+
+	clock clock.Clock
+
+	streamLimiter *streamLimiter
 }
 
 var _ host.Host = (*BasicHost)(nil)
@@ -130,6 +151,14 @@ type HostOpts struct {
 	// If omitted, there's no override or filtering, and the results of Addrs and AllAddrs are the same.
 	AddrsFactory AddrsFactory
 
+	// AddrScorers are the scorers used to rank the addresses returned by Addrs,
+	// by name. See AddrScorer.
+	AddrScorers map[string]AddrScorer
+
+	// MaxAdvertisedAddrs caps the number of addresses returned by Addrs to the
+	// highest scoring ones. If 0, the number of addresses isn't capped.
+	MaxAdvertisedAddrs int
+
 	// MultiaddrResolves holds the go-multiaddr-dns.Resolver used for resolving
 	// /dns4, /dns6, and /dnsaddr addresses before trying to connect to a peer.
 	MultiaddrResolver *madns.Resolver
@@ -141,6 +170,10 @@ type HostOpts struct {
 	// ConnManager is a libp2p connection manager
 	ConnManager connmgr.ConnManager
 
+	// ConnectionGater is the connection gater used by the network. If it
+	// implements connmgr.IdentifyGater, it is consulted by identify.
+	ConnectionGater connmgr.ConnectionGater
+
 	// EnablePing indicates whether to instantiate the ping service
 	EnablePing bool
 
@@ -165,6 +198,10 @@ type HostOpts struct {
 
 	// EnableMetrics enables the metrics subsystems
 	EnableMetrics bool
+	// DisabledMetrics disables metrics for some of the subsystems, even if
+	// EnableMetrics is set. Valid subsystems are "identify", "holepunch",
+	// "relay", "autonat" and "streamlimiter".
+	DisabledMetrics map[string]struct{}
 	// PrometheusRegisterer is the PrometheusRegisterer used for metrics
 	PrometheusRegisterer prometheus.Registerer
 
@@ -172,6 +209,45 @@ type HostOpts struct {
 	DisableIdentifyAddressDiscovery bool
 	EnableAutoNATv2                 bool
 	AutoNATv2Dialer                 host.Host
+
+	// Clock is the clock used by time-dependent services of the host.
+	// Defaults to the real clock.
+	Clock clock.Clock
+
+	// Logger is the logger used by the identify service and the relay
+	// service. Defaults to the go-log loggers of these subsystems.
+	Logger *slog.Logger
+
+	// StreamOpenLimits limit the rate at which NewStream opens the streams
+	// of a service to a single peer.
+	StreamOpenLimits []StreamOpenLimit
+
+	// AddrTranslator is used by the identify service to translate observed
+	// addresses.
+	AddrTranslator network.AddrTranslator
+
+	// RandSource is the source of randomness used by AutoNAT v2.
+	// If nil, AutoNAT v2 is randomly seeded.
+	RandSource rand.Source
+
+	// DisableAddrWithdrawal keeps advertising addresses that AutoNAT found
+	// unreachable.
+	DisableAddrWithdrawal bool
+
+	// IdentifyPushConcurrency is the maximum number of identify pushes sent
+	// concurrently. See identify.WithPushConcurrency.
+	IdentifyPushConcurrency int
+	// IdentifyPushRoundBudget limits the time spent starting the identify
+	// pushes of a single round. See identify.WithPushRoundBudget.
+	IdentifyPushRoundBudget time.Duration
+}
+
+func (opts *HostOpts) metricsEnabled(subsystem string) bool {
+	if !opts.EnableMetrics {
+		return false
+	}
+	_, disabled := opts.DisabledMetrics[subsystem]
+	return !disabled
 }
 
 // NewHost constructs a new *BasicHost and activates it by attaching its stream and connection handlers to the given inet.Network.
@@ -187,19 +263,30 @@ func NewHost(n network.Network, opts *HostOpts) (*BasicHost, error) {
 	if err != nil {
 		return nil, err
 	}
+	connectivity, err := newConnectivityTracker(opts.EventBus, n)
+	if err != nil {
+		return nil, err
+	}
 	hostCtx, cancel := context.WithCancel(context.Background())
 	h := &BasicHost{
 		network:                 n,
 		psManager:               psManager,
+		connectivity:            connectivity,
 		mux:                     msmux.NewMultistreamMuxer[protocol.ID](),
 		negtimeout:              DefaultNegotiationTimeout,
 		AddrsFactory:            DefaultAddrsFactory,
 		maResolver:              madns.DefaultResolver,
 		eventbus:                opts.EventBus,
 		addrChangeChan:          make(chan struct{}, 1),
+		addrScorers:             make(map[string]AddrScorer),
+		addrReachability:        make(map[string]network.Reachability),
 		ctx:                     hostCtx,
 		ctxCancel:               cancel,
 		disableSignedPeerRecord: opts.DisableSignedPeerRecord,
+		clock:                   opts.Clock,
+	}
+	if h.clock == nil {
+		h.clock = clock.New()
 	}
 
 	h.updateLocalIpAddr()
@@ -210,6 +297,12 @@ func NewHost(n network.Network, opts *HostOpts) (*BasicHost, error) {
 	if h.emitters.evtLocalAddrsUpdated, err = h.eventbus.Emitter(&event.EvtLocalAddressesUpdated{}, eventbus.Stateful); err != nil {
 		return nil, err
 	}
+	if h.emitters.evtNATPortMappingChanged, err = h.eventbus.Emitter(&event.EvtNATPortMappingChanged{}); err != nil {
+		return nil, err
+	}
+	if h.emitters.evtConnManagerTrimmed, err = h.eventbus.Emitter(&event.EvtConnManagerTrimmed{}); err != nil {
+		return nil, err
+	}
 
 	if !h.disableSignedPeerRecord {
 		cab, ok := peerstore.GetCertifiedAddrBook(n.Peerstore())
@@ -241,6 +334,17 @@ func NewHost(n network.Network, opts *HostOpts) (*BasicHost, error) {
 		h.mux = opts.MultistreamMuxer
 	}
 
+	if len(opts.StreamOpenLimits) > 0 {
+		var mt MetricsTracer
+		if opts.metricsEnabled("streamlimiter") {
+			mt = NewMetricsTracer(WithRegisterer(opts.PrometheusRegisterer))
+		}
+		h.streamLimiter, err = newStreamLimiter(opts.StreamOpenLimits, h.clock, mt)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	idOpts := []identify.Option{
 		identify.UserAgent(opts.UserAgent),
 		identify.ProtocolVersion(opts.ProtocolVersion),
@@ -250,7 +354,7 @@ func NewHost(n network.Network, opts *HostOpts) (*BasicHost, error) {
 	if h.disableSignedPeerRecord {
 		idOpts = append(idOpts, identify.DisableSignedPeerRecord())
 	}
-	if opts.EnableMetrics {
+	if opts.metricsEnabled("identify") {
 		idOpts = append(idOpts,
 			identify.WithMetricsTracer(
 				identify.NewMetricsTracer(identify.WithRegisterer(opts.PrometheusRegisterer))))
@@ -258,6 +362,24 @@ func NewHost(n network.Network, opts *HostOpts) (*BasicHost, error) {
 	if opts.DisableIdentifyAddressDiscovery {
 		idOpts = append(idOpts, identify.DisableObservedAddrManager())
 	}
+	if opts.Clock != nil {
+		idOpts = append(idOpts, identify.WithClock(opts.Clock))
+	}
+	if g, ok := opts.ConnectionGater.(connmgr.IdentifyGater); ok {
+		idOpts = append(idOpts, identify.WithIdentifyGater(g))
+	}
+	if opts.Logger != nil {
+		idOpts = append(idOpts, identify.WithLogger(opts.Logger))
+	}
+	if opts.AddrTranslator != nil {
+		idOpts = append(idOpts, identify.WithAddrTranslator(opts.AddrTranslator))
+	}
+	if opts.IdentifyPushConcurrency > 0 {
+		idOpts = append(idOpts, identify.WithPushConcurrency(opts.IdentifyPushConcurrency))
+	}
+	if opts.IdentifyPushRoundBudget > 0 {
+		idOpts = append(idOpts, identify.WithPushRoundBudget(opts.IdentifyPushRoundBudget))
+	}
 
 	h.ids, err = identify.NewIDService(h, idOpts...)
 	if err != nil {
@@ -265,7 +387,7 @@ func NewHost(n network.Network, opts *HostOpts) (*BasicHost, error) {
 	}
 
 	if opts.EnableHolePunching {
-		if opts.EnableMetrics {
+		if opts.metricsEnabled("holepunch") {
 			hpOpts := []holepunch.Option{
 				holepunch.WithMetricsTracer(holepunch.NewMetricsTracer(holepunch.WithRegisterer(opts.PrometheusRegisterer)))}
 			opts.HolePunchingOptions = append(hpOpts, opts.HolePunchingOptions...)
@@ -284,9 +406,17 @@ func NewHost(n network.Network, opts *HostOpts) (*BasicHost, error) {
 	if opts.AddrsFactory != nil {
 		h.AddrsFactory = opts.AddrsFactory
 	}
+	for name, s := range opts.AddrScorers {
+		h.addrScorers[name] = s
+	}
+	h.maxAdvertisedAddrs = opts.MaxAdvertisedAddrs
+	h.disableAddrWithdrawal = opts.DisableAddrWithdrawal
 
 	if opts.NATManager != nil {
 		h.natmgr = opts.NATManager(n)
+		if nmgr, ok := h.natmgr.(*natManager); ok {
+			nmgr.setEmitter(h.emitters.evtNATPortMappingChanged)
+		}
 	}
 
 	if opts.MultiaddrResolver != nil {
@@ -298,16 +428,23 @@ func NewHost(n network.Network, opts *HostOpts) (*BasicHost, error) {
 	} else {
 		h.cmgr = opts.ConnManager
 		n.Notify(h.cmgr.Notifee())
+		if cm, ok := h.cmgr.(interface{ SetTrimEmitter(event.Emitter) }); ok {
+			cm.SetTrimEmitter(h.emitters.evtConnManagerTrimmed)
+		}
 	}
 
 	if opts.EnableRelayService {
-		if opts.EnableMetrics {
+		if opts.metricsEnabled("relay") {
 			// Prefer explicitly provided metrics tracer
 			metricsOpt := []relayv2.Option{
 				relayv2.WithMetricsTracer(
 					relayv2.NewMetricsTracer(relayv2.WithRegisterer(opts.PrometheusRegisterer)))}
 			opts.RelayServiceOpts = append(metricsOpt, opts.RelayServiceOpts...)
 		}
+		if opts.Logger != nil {
+			// Prefer an explicitly provided logger
+			opts.RelayServiceOpts = append([]relayv2.Option{relayv2.WithLogger(opts.Logger)}, opts.RelayServiceOpts...)
+		}
 		h.relayManager = relaysvc.NewRelayManager(h, opts.RelayServiceOpts...)
 	}
 
@@ -317,13 +454,18 @@ func NewHost(n network.Network, opts *HostOpts) (*BasicHost, error) {
 
 	if opts.EnableAutoNATv2 {
 		var mt autonatv2.MetricsTracer
-		if opts.EnableMetrics {
+		if opts.metricsEnabled("autonat") {
 			mt = autonatv2.NewMetricsTracer(opts.PrometheusRegisterer)
 		}
-		h.autonatv2, err = autonatv2.New(h, opts.AutoNATv2Dialer, autonatv2.WithMetricsTracer(mt))
+		autonatv2Opts := []autonatv2.AutoNATOption{autonatv2.WithMetricsTracer(mt)}
+		if opts.RandSource != nil {
+			autonatv2Opts = append(autonatv2Opts, autonatv2.WithRandSource(opts.RandSource))
+		}
+		h.autonatv2, err = autonatv2.New(h, opts.AutoNATv2Dialer, autonatv2Opts...)
 		if err != nil {
 			return nil, fmt.Errorf("failed to create autonatv2: %w", err)
 		}
+		h.probeAddr = h.probeAddrWithAutoNATv2
 	}
 
 	n.SetStreamHandler(h.newStreamHandler)
@@ -412,6 +554,7 @@ func (h *BasicHost) updateLocalIpAddr() {
 // Start starts background tasks in the host
 func (h *BasicHost) Start() {
 	h.psManager.Start()
+	h.connectivity.Start()
 	h.refCount.Add(1)
 	h.ids.Start()
 	if h.autonatv2 != nil {
@@ -420,6 +563,10 @@ func (h *BasicHost) Start() {
 			log.Errorf("autonat v2 failed to start: %s", err)
 		}
 	}
+	if h.probeAddr != nil {
+		h.refCount.Add(1)
+		go h.probeAddrReachability()
+	}
 	go h.background()
 }
 
@@ -481,6 +628,109 @@ func (h *BasicHost) SignalAddressChange() {
 	}
 }
 
+// ListenOn makes the host listen on the given addresses, in addition to the
+// addresses it's already listening on. If listening on any of the addresses
+// fails, the host doesn't listen on any of them.
+// The new addresses are advertised to connected peers using identify push.
+func (h *BasicHost) ListenOn(addrs ...ma.Multiaddr) error {
+	n, ok := h.Network().(interface{ ListenOn(...ma.Multiaddr) error })
+	if !ok {
+		return errors.New("network doesn't support adding listen addresses")
+	}
+	return n.ListenOn(addrs...)
+}
+
+// StopListening stops listening on the given addresses, without affecting any
+// other listeners. The addresses must be listen addresses of the host's
+// network, as returned by Network().ListenAddresses().
+// Connected peers are notified of the removal using identify push.
+func (h *BasicHost) StopListening(addrs ...ma.Multiaddr) error {
+	n, ok := h.Network().(interface{ StopListening(...ma.Multiaddr) error })
+	if !ok {
+		return errors.New("network doesn't support removing listen addresses")
+	}
+	err := n.StopListening(addrs...)
+	// The network only notifies us once the listeners have shut down.
+	// Don't wait for that to update our addresses.
+	h.SignalAddressChange()
+	return err
+}
+
+// ListenerSockets reports, for every listen address, on the socket it's bound
+// to, which other listeners share that socket, and which listeners conflict
+// with it. It returns nil if the network doesn't support this.
+func (h *BasicHost) ListenerSockets() []transport.ListenerSocket {
+	n, ok := h.Network().(interface {
+		ListenerSockets() []transport.ListenerSocket
+	})
+	if !ok {
+		return nil
+	}
+	return n.ListenerSockets()
+}
+
+// SetListenerReusePort sets whether outgoing connections may be dialed from
+// the port of the listener for addr. This allows opting a listener out of
+// port reuse at runtime. addr must be a listen address of the host's network.
+func (h *BasicHost) SetListenerReusePort(addr ma.Multiaddr, enable bool) error {
+	n, ok := h.Network().(interface {
+		SetListenerReusePort(ma.Multiaddr, bool) error
+	})
+	if !ok {
+		return errors.New("network doesn't support configuring port reuse")
+	}
+	return n.SetListenerReusePort(addr, enable)
+}
+
+// PeerProtocolUsage returns the usage of every protocol that had a stream
+// opened over the current connections to peer p, aggregated over these
+// connections.
+func (h *BasicHost) PeerProtocolUsage(p peer.ID) map[protocol.ID]network.ProtocolUsage {
+	usage := make(map[protocol.ID]network.ProtocolUsage)
+	for _, c := range h.Network().ConnsToPeer(p) {
+		addProtocolUsage(usage, c)
+	}
+	return usage
+}
+
+// ProtocolPeers returns the usage of protocol proto by every connected peer
+// that had a stream opened for it.
+func (h *BasicHost) ProtocolPeers(proto protocol.ID) map[peer.ID]network.ProtocolUsage {
+	peers := make(map[peer.ID]network.ProtocolUsage)
+	for _, c := range h.Network().Conns() {
+		uc, ok := c.(network.ProtocolUsageConn)
+		if !ok {
+			continue
+		}
+		u, ok := uc.ProtocolUsage()[proto]
+		if !ok {
+			continue
+		}
+		peers[c.RemotePeer()] = mergeProtocolUsage(peers[c.RemotePeer()], u)
+	}
+	return peers
+}
+
+func addProtocolUsage(usage map[protocol.ID]network.ProtocolUsage, c network.Conn) {
+	uc, ok := c.(network.ProtocolUsageConn)
+	if !ok {
+		return
+	}
+	for proto, u := range uc.ProtocolUsage() {
+		usage[proto] = mergeProtocolUsage(usage[proto], u)
+	}
+}
+
+func mergeProtocolUsage(a, b network.ProtocolUsage) network.ProtocolUsage {
+	a.Streams += b.Streams
+	a.BytesIn += b.BytesIn
+	a.BytesOut += b.BytesOut
+	if b.LastUsed.After(a.LastUsed) {
+		a.LastUsed = b.LastUsed
+	}
+	return a
+}
+
 func makeUpdatedAddrEvent(prev, current []ma.Multiaddr) *event.EvtLocalAddressesUpdated {
 	prevmap := make(map[string]ma.Multiaddr, len(prev))
 	evt := event.EvtLocalAddressesUpdated{Diffs: true}
@@ -661,6 +911,8 @@ func (h *BasicHost) RemoveStreamHandler(pid protocol.ID) {
 // NewStream opens a new stream to given peer p, and writes a p2p/protocol
 // header with given protocol.ID. If there is no connection to p, attempts
 // to create one. If ProtocolID is "", writes no header.
+// If the first protocol belongs to a service with a StreamOpenLimit, NewStream
+// waits until the limit allows opening the stream.
 // (Thread-safe)
 func (h *BasicHost) NewStream(ctx context.Context, p peer.ID, pids ...protocol.ID) (str network.Stream, strErr error) {
 	// If the caller wants to prevent the host from dialing, it should use the NoDial option.
@@ -671,6 +923,12 @@ func (h *BasicHost) NewStream(ctx context.Context, p peer.ID, pids ...protocol.I
 		}
 	}
 
+	if h.streamLimiter != nil && len(pids) > 0 {
+		if err := h.streamLimiter.Acquire(ctx, p, pids[0]); err != nil {
+			return nil, err
+		}
+	}
+
 	s, err := h.Network().NewStream(network.WithNoDial(ctx, "already dialed"), p)
 	if err != nil {
 		// TODO: It would be nicer to get the actual error from the swarm,
@@ -752,6 +1010,118 @@ func (h *BasicHost) preferredProtocol(p peer.ID, pids []protocol.ID) (protocol.I
 	return out, nil
 }
 
+// NewStreamWithFallback opens a new stream to peer p, speaking the first of
+// the given protocols the peer accepts. pids is ordered by preference, e.g.
+// newest protocol version first.
+//
+// Protocols the peer is known to support (from identify) are tried first.
+// Unlike NewStream, the protocol is always negotiated before returning, so a
+// peer that stopped supporting a protocol makes us fall back to the next one
+// instead of failing on first use of the stream. The outcome is recorded in
+// the peerstore: the selected protocol is added to the peer's protocols, the
+// protocols the peer rejected are removed.
+func (h *BasicHost) NewStreamWithFallback(ctx context.Context, p peer.ID, pids ...protocol.ID) (str network.Stream, strErr error) {
+	if len(pids) == 0 {
+		return nil, errors.New("no protocols given")
+	}
+	if nodial, _ := network.GetNoDial(ctx); !nodial {
+		if err := h.Connect(ctx, peer.AddrInfo{ID: p}); err != nil {
+			return nil, err
+		}
+	}
+
+	if h.streamLimiter != nil {
+		if err := h.streamLimiter.Acquire(ctx, p, pids[0]); err != nil {
+			return nil, err
+		}
+	}
+
+	s, err := h.Network().NewStream(network.WithNoDial(ctx, "already dialed"), p)
+	if err != nil {
+		if errors.Is(err, network.ErrNoConn) {
+			return nil, errors.New("connection failed")
+		}
+		return nil, fmt.Errorf("failed to open stream: %w", err)
+	}
+	defer func() {
+		if strErr != nil {
+			s.Reset()
+		}
+	}()
+
+	select {
+	case <-h.ids.IdentifyWait(s.Conn()):
+	case <-ctx.Done():
+		return nil, fmt.Errorf("identify failed to complete: %w", ctx.Err())
+	}
+
+	ordered, err := h.orderByKnownSupport(p, pids)
+	if err != nil {
+		return nil, err
+	}
+
+	var selected protocol.ID
+	errCh := make(chan error, 1)
+	go func() {
+		selected, err = msmux.SelectOneOf(ordered, s)
+		errCh <- err
+	}()
+	select {
+	case err = <-errCh:
+	case <-ctx.Done():
+		s.Reset()
+		<-errCh
+		return nil, fmt.Errorf("failed to negotiate protocol: %w", ctx.Err())
+	}
+	if err != nil {
+		if errors.Is(err, msmux.ErrNotSupported[protocol.ID]{}) {
+			_ = h.Peerstore().RemoveProtocols(p, ordered...)
+		}
+		return nil, fmt.Errorf("failed to negotiate protocol: %w", err)
+	}
+
+	// SelectOneOf proposes the protocols in order, so all protocols before the
+	// selected one were rejected.
+	for i, pid := range ordered {
+		if pid == selected {
+			if i > 0 {
+				_ = h.Peerstore().RemoveProtocols(p, ordered[:i]...)
+			}
+			break
+		}
+	}
+	if err := s.SetProtocol(selected); err != nil {
+		return nil, err
+	}
+	_ = h.Peerstore().AddProtocols(p, selected)
+	return s, nil
+}
+
+// orderByKnownSupport returns pids, with the protocols p is known to support
+// moved to the front. The relative order of the protocols is preserved otherwise.
+func (h *BasicHost) orderByKnownSupport(p peer.ID, pids []protocol.ID) ([]protocol.ID, error) {
+	supported, err := h.Peerstore().SupportsProtocols(p, pids...)
+	if err != nil {
+		return nil, err
+	}
+	known := make(map[protocol.ID]struct{}, len(supported))
+	for _, pid := range supported {
+		known[pid] = struct{}{}
+	}
+	ordered := make([]protocol.ID, 0, len(pids))
+	for _, pid := range pids {
+		if _, ok := known[pid]; ok {
+			ordered = append(ordered, pid)
+		}
+	}
+	for _, pid := range pids {
+		if _, ok := known[pid]; !ok {
+			ordered = append(ordered, pid)
+		}
+	}
+	return ordered, nil
+}
+
 // Connect ensures there is a connection between this host and the peer with
 // given peer.ID. If there is not an active connection, Connect will issue a
 // h.Network.Dial, and block until a connection is open, or an error is returned.
@@ -802,7 +1172,8 @@ func (h *BasicHost) ConnManager() connmgr.ConnManager {
 }
 
 // Addrs returns listening addresses that are safe to announce to the network.
-// The output is the same as AllAddrs, but processed by AddrsFactory.
+// The output is the same as AllAddrs, but processed by AddrsFactory, and
+// ranked by the registered AddrScorers.
 func (h *BasicHost) Addrs() []ma.Multiaddr {
 	// This is a temporary workaround/hack that fixes #2233. Once we have a
 	// proper address pipeline, rework this. See the issue for more context.
@@ -818,7 +1189,7 @@ func (h *BasicHost) Addrs() []ma.Multiaddr {
 
 	s, ok := h.Network().(transportForListeninger)
 	if !ok {
-		return addrs
+		return h.rankAddrs(h.withdrawUnreachable(addrs))
 	}
 
 	// Copy addrs slice since we'll be modifying it.
@@ -844,7 +1215,7 @@ func (h *BasicHost) Addrs() []ma.Multiaddr {
 		}
 	}
 
-	return addrs
+	return h.rankAddrs(h.withdrawUnreachable(addrs))
 }
 
 // NormalizeMultiaddr returns a multiaddr suitable for equality checks.
@@ -1088,6 +1459,18 @@ func trimHostAddrList(addrs []ma.Multiaddr, maxSize int) []ma.Multiaddr {
 	return addrs
 }
 
+// NATMappings returns the port mappings the NAT manager maintains on the NAT
+// device, including mappings that the device didn't grant.
+// It returns nil if port mapping is disabled, no NAT device was discovered,
+// or the NAT manager doesn't support listing mappings.
+func (h *BasicHost) NATMappings() []inat.Mapping {
+	nmgr, ok := h.natmgr.(interface{ Mappings() []inat.Mapping })
+	if !ok {
+		return nil
+	}
+	return nmgr.Mappings()
+}
+
 // SetAutoNat sets the autonat service for the host.
 func (h *BasicHost) SetAutoNat(a autonat.AutoNAT) {
 	h.addrMu.Lock()
@@ -1132,8 +1515,11 @@ func (h *BasicHost) Close() error {
 
 		_ = h.emitters.evtLocalProtocolsUpdated.Close()
 		_ = h.emitters.evtLocalAddrsUpdated.Close()
+		_ = h.emitters.evtNATPortMappingChanged.Close()
+		_ = h.emitters.evtConnManagerTrimmed.Close()
 
 		h.psManager.Close()
+		h.connectivity.Close()
 		if h.Peerstore() != nil {
 			h.Peerstore().Close()
 		}
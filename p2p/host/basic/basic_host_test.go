@@ -8,9 +8,11 @@ import (
 	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/benbjohnson/clock"
 	"github.com/libp2p/go-libp2p/core/event"
 	"github.com/libp2p/go-libp2p/core/host"
 	"github.com/libp2p/go-libp2p/core/network"
@@ -20,10 +22,12 @@ import (
 	"github.com/libp2p/go-libp2p/core/record"
 	"github.com/libp2p/go-libp2p/p2p/host/autonat"
 	"github.com/libp2p/go-libp2p/p2p/host/eventbus"
+	"github.com/libp2p/go-libp2p/p2p/net/swarm"
 	swarmt "github.com/libp2p/go-libp2p/p2p/net/swarm/testing"
 	"github.com/libp2p/go-libp2p/p2p/protocol/identify"
 
 	ma "github.com/multiformats/go-multiaddr"
+	msmux "github.com/multiformats/go-multistream"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -113,6 +117,297 @@ func TestSignedPeerRecordWithNoListenAddrs(t *testing.T) {
 	require.NotEmpty(t, rec.(*peer.PeerRecord).Addrs)
 }
 
+func TestSignedPeerRecordAPI(t *testing.T) {
+	h1, err := NewHost(swarmt.GenSwarm(t), nil)
+	require.NoError(t, err)
+	defer h1.Close()
+	h1.Start()
+	h2, err := NewHost(swarmt.GenSwarm(t), nil)
+	require.NoError(t, err)
+	defer h2.Close()
+	h2.Start()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	updates, err := h1.SubscribeSignedPeerRecord(ctx)
+	require.NoError(t, err)
+	var env *record.Envelope
+	select {
+	case env = <-updates:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected a signed peer record")
+	}
+
+	b, seq, err := h1.SignedPeerRecord()
+	require.NoError(t, err)
+	require.LessOrEqual(t, peerRecordFromEnvelope(t, env).Seq, seq)
+
+	// verify it on another host, as if it was obtained out of band
+	rec, err := h2.VerifySignedPeerRecord(b)
+	require.NoError(t, err)
+	require.Equal(t, h1.ID(), rec.PeerID)
+	require.Equal(t, seq, rec.Seq)
+	require.NotEmpty(t, rec.Addrs)
+
+	// a record for h1, signed by h2
+	forged, err := record.Seal(rec, h2.Peerstore().PrivKey(h2.ID()))
+	require.NoError(t, err)
+	fb, err := forged.Marshal()
+	require.NoError(t, err)
+	_, err = h2.VerifySignedPeerRecord(fb)
+	require.Error(t, err)
+
+	cancel()
+	require.Eventually(t, func() bool {
+		select {
+		case _, ok := <-updates:
+			return !ok
+		default:
+			return false
+		}
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestSignedPeerRecordDisabled(t *testing.T) {
+	h, err := NewHost(swarmt.GenSwarm(t), &HostOpts{DisableSignedPeerRecord: true})
+	require.NoError(t, err)
+	defer h.Close()
+
+	_, _, err = h.SignedPeerRecord()
+	require.ErrorIs(t, err, ErrSignedPeerRecordDisabled)
+	_, err = h.SubscribeSignedPeerRecord(context.Background())
+	require.ErrorIs(t, err, ErrSignedPeerRecordDisabled)
+}
+
+func TestAddrScorer(t *testing.T) {
+	h, err := NewHost(swarmt.GenSwarm(t), nil)
+	require.NoError(t, err)
+	defer h.Close()
+	h.Start()
+
+	all := h.Addrs()
+	require.GreaterOrEqual(t, len(all), 2)
+	last := all[len(all)-1]
+
+	h.SetAddrScorer("prefer", PreferAddrs(10, last))
+	addrs := h.Addrs()
+	require.Len(t, addrs, len(all))
+	require.Equal(t, last, addrs[0])
+	// addresses with equal scores keep their order
+	require.Equal(t, all[:len(all)-1], addrs[1:])
+
+	h.SetAddrScorer("filter", PreferAddrs(-1, all[0]))
+	require.NotContains(t, h.Addrs(), all[0])
+
+	// the change is reflected in the address update events
+	sub, err := h.EventBus().Subscribe(new(event.EvtLocalAddressesUpdated))
+	require.NoError(t, err)
+	defer sub.Close()
+	require.Eventually(t, func() bool {
+		e := (<-sub.Out()).(event.EvtLocalAddressesUpdated)
+		for _, a := range e.Current {
+			if a.Address.Equal(all[0]) {
+				return false
+			}
+		}
+		return true
+	}, 5*time.Second, 10*time.Millisecond)
+
+	h.RemoveAddrScorer("prefer")
+	h.RemoveAddrScorer("filter")
+	require.Equal(t, all, h.Addrs())
+}
+
+func TestAddrWithdrawal(t *testing.T) {
+	h, err := NewHost(swarmt.GenSwarm(t), nil)
+	require.NoError(t, err)
+	defer h.Close()
+	h.Start()
+
+	all := h.Addrs()
+	require.GreaterOrEqual(t, len(all), 2)
+	unreachable := all[0]
+
+	sub, err := h.EventBus().Subscribe(new(event.EvtLocalAddressesUpdated))
+	require.NoError(t, err)
+	defer sub.Close()
+
+	h.SetAddrReachability(unreachable, network.ReachabilityPrivate)
+	require.NotContains(t, h.Addrs(), unreachable)
+	require.Contains(t, h.AllAddrs(), unreachable)
+	require.Equal(t, []ma.Multiaddr{unreachable}, h.UnreachableAddrs())
+
+	// peers are notified of the withdrawal
+	timeout := time.After(5 * time.Second)
+	for withdrawn := false; !withdrawn; {
+		select {
+		case e := <-sub.Out():
+			withdrawn = true
+			for _, a := range e.(event.EvtLocalAddressesUpdated).Current {
+				if a.Address.Equal(unreachable) {
+					withdrawn = false
+				}
+			}
+		case <-timeout:
+			t.Fatal("didn't withdraw the unreachable address")
+		}
+	}
+
+	h.SetAddrReachability(unreachable, network.ReachabilityPublic)
+	require.Equal(t, all, h.Addrs())
+	require.Empty(t, h.UnreachableAddrs())
+}
+
+func TestAddrReachabilityProbing(t *testing.T) {
+	reachable := ma.StringCast("/ip4/1.2.3.4/tcp/1")
+	unreachable := ma.StringCast("/ip4/1.2.3.5/tcp/1")
+	addrs := []ma.Multiaddr{
+		reachable,
+		unreachable,
+		ma.StringCast("/ip4/1.2.3.6/tcp/1"),
+		ma.StringCast("/ip4/1.2.3.7/tcp/1"),
+	}
+	clk := clock.NewMock()
+	h, err := NewHost(swarmt.GenSwarm(t), &HostOpts{
+		Clock:        clk,
+		AddrsFactory: func([]ma.Multiaddr) []ma.Multiaddr { return addrs },
+	})
+	require.NoError(t, err)
+	defer h.Close()
+
+	var mx sync.Mutex
+	probes := make(map[string]int)
+	var running, maxRunning int
+	release := make(chan struct{})
+	h.probeAddr = func(ctx context.Context, a ma.Multiaddr) (network.Reachability, error) {
+		mx.Lock()
+		probes[string(a.Bytes())]++
+		running++
+		maxRunning = max(maxRunning, running)
+		mx.Unlock()
+		defer func() {
+			mx.Lock()
+			running--
+			mx.Unlock()
+		}()
+		select {
+		case <-release:
+		case <-ctx.Done():
+			return network.ReachabilityUnknown, ctx.Err()
+		}
+		if a.Equal(unreachable) {
+			return network.ReachabilityPrivate, nil
+		}
+		return network.ReachabilityPublic, nil
+	}
+	numProbes := func(a ma.Multiaddr) int {
+		mx.Lock()
+		defer mx.Unlock()
+		return probes[string(a.Bytes())]
+	}
+	h.Start()
+
+	// new addresses are probed right away, but only a few at a time
+	require.Eventually(t, func() bool {
+		mx.Lock()
+		defer mx.Unlock()
+		return running == addrReachabilityMaxConcurrentProbes
+	}, 5*time.Second, 10*time.Millisecond)
+	close(release)
+	require.Eventually(t, func() bool {
+		for _, a := range addrs {
+			if numProbes(a) == 0 {
+				return false
+			}
+		}
+		return true
+	}, 5*time.Second, 10*time.Millisecond)
+	mx.Lock()
+	require.Equal(t, addrReachabilityMaxConcurrentProbes, maxRunning)
+	mx.Unlock()
+
+	// a single result isn't enough to withdraw an address
+	require.Contains(t, h.Addrs(), unreachable)
+
+	// the unreachable address is probed again until we're confident
+	require.Eventually(t, func() bool {
+		clk.Add(addrReachabilityRetryInterval)
+		return len(h.UnreachableAddrs()) > 0
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, []ma.Multiaddr{unreachable}, h.UnreachableAddrs())
+	require.NotContains(t, h.Addrs(), unreachable)
+	require.Equal(t, addrReachabilityConfidence, numProbes(unreachable))
+	// reachable addresses are only probed again after addrReachabilityProbeInterval
+	require.Equal(t, 1, numProbes(reachable))
+}
+
+func TestAddrReachabilityProbingWaitsForSlot(t *testing.T) {
+	var addrs []ma.Multiaddr
+	for i := 0; i <= addrReachabilityMaxConcurrentProbes; i++ {
+		addrs = append(addrs, ma.StringCast(fmt.Sprintf("/ip4/1.2.3.%d/tcp/1", i+1)))
+	}
+	var factoryCalls atomic.Int32
+	h, err := NewHost(swarmt.GenSwarm(t), &HostOpts{
+		AddrsFactory: func([]ma.Multiaddr) []ma.Multiaddr {
+			factoryCalls.Add(1)
+			return addrs
+		},
+	})
+	require.NoError(t, err)
+	defer h.Close()
+
+	var running atomic.Int32
+	h.probeAddr = func(ctx context.Context, a ma.Multiaddr) (network.Reachability, error) {
+		running.Add(1)
+		<-ctx.Done()
+		return network.ReachabilityUnknown, ctx.Err()
+	}
+	h.Start()
+	require.Eventually(t, func() bool {
+		return running.Load() == int32(addrReachabilityMaxConcurrentProbes)
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// the address waiting for a probe slot doesn't keep the loop busy
+	calls := factoryCalls.Load()
+	time.Sleep(100 * time.Millisecond)
+	require.Less(t, factoryCalls.Load()-calls, int32(5))
+}
+
+func TestAddrWithdrawalDisabled(t *testing.T) {
+	h, err := NewHost(swarmt.GenSwarm(t), &HostOpts{DisableAddrWithdrawal: true})
+	require.NoError(t, err)
+	defer h.Close()
+
+	all := h.Addrs()
+	h.SetAddrReachability(all[0], network.ReachabilityPrivate)
+	require.Equal(t, all, h.Addrs())
+	require.Equal(t, []ma.Multiaddr{all[0]}, h.UnreachableAddrs())
+}
+
+func TestMaxAdvertisedAddrs(t *testing.T) {
+	isQUIC := func(a ma.Multiaddr) bool {
+		_, err := a.ValueForProtocol(ma.P_QUIC_V1)
+		return err == nil
+	}
+	h, err := NewHost(swarmt.GenSwarm(t), &HostOpts{
+		MaxAdvertisedAddrs: 1,
+		AddrScorers: map[string]AddrScorer{
+			"prefer-quic": AddrScorerFunc(func(a ma.Multiaddr) float64 {
+				if isQUIC(a) {
+					return 1
+				}
+				return 0
+			}),
+		},
+	})
+	require.NoError(t, err)
+	defer h.Close()
+
+	require.Greater(t, len(h.AllAddrs()), 1)
+	addrs := h.Addrs()
+	require.Len(t, addrs, 1)
+	require.True(t, isQUIC(addrs[0]))
+}
+
 func TestProtocolHandlerEvents(t *testing.T) {
 	h, err := NewHost(swarmt.GenSwarm(t), nil)
 	require.NoError(t, err)
@@ -272,6 +567,38 @@ func assertWait(t *testing.T, c chan protocol.ID, exp protocol.ID) {
 	}
 }
 
+func TestProtocolUsage(t *testing.T) {
+	h1, h2 := getHostPair(t)
+	defer h1.Close()
+	defer h2.Close()
+
+	const proto = "/testing/usage"
+	h2.SetStreamHandler(proto, func(s network.Stream) {
+		io.Copy(s, s)
+		s.Close()
+	})
+
+	s, err := h1.NewStream(context.Background(), h2.ID(), proto)
+	require.NoError(t, err)
+	_, err = s.Write([]byte("hello"))
+	require.NoError(t, err)
+	require.NoError(t, s.CloseWrite())
+	_, err = io.ReadAll(s)
+	require.NoError(t, err)
+	s.Close()
+
+	bh1 := h1.(*BasicHost)
+	u, ok := bh1.PeerProtocolUsage(h2.ID())[proto]
+	require.True(t, ok)
+	require.Equal(t, 1, u.Streams)
+	require.Equal(t, int64(5), u.BytesIn)
+
+	peers := bh1.ProtocolPeers(proto)
+	require.Len(t, peers, 1)
+	require.Equal(t, u, peers[h2.ID()])
+	require.Empty(t, bh1.ProtocolPeers("/unused"))
+}
+
 func TestHostProtoPreference(t *testing.T) {
 	h1, h2 := getHostPair(t)
 	defer h1.Close()
@@ -368,6 +695,7 @@ func TestHostProtoPreknowledge(t *testing.T) {
 
 	// Prevent pushing identify information so this test actually _uses_ the super protocol.
 	h1.RemoveStreamHandler(identify.IDPush)
+	h1.RemoveStreamHandler(identify.IDPushv2)
 
 	h2pi := h2.Peerstore().PeerInfo(h2.ID())
 	// Filter to only 1 address so that we don't have to think about parallel
@@ -549,6 +877,123 @@ func TestProtoDowngrade(t *testing.T) {
 	assertWait(t, connectedOn, "/testing")
 }
 
+func TestNewStreamWithFallback(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	h1, h2 := getHostPair(t)
+	defer h1.Close()
+	defer h2.Close()
+	bh1 := h1.(*BasicHost)
+
+	connectedOn := make(chan protocol.ID)
+	h2.SetStreamHandler("/testing", func(s network.Stream) {
+		defer s.Close()
+		connectedOn <- s.Protocol()
+	})
+
+	// wait for identify, so it doesn't overwrite the stale protocol below
+	select {
+	case <-bh1.ids.IdentifyWait(h1.Network().ConnsToPeer(h2.ID())[0]):
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for identify")
+	}
+	// pretend we think h2 supports /testing/1.0.0
+	require.NoError(t, h1.Peerstore().AddProtocols(h2.ID(), "/testing/1.0.0"))
+
+	s, err := bh1.NewStreamWithFallback(ctx, h2.ID(), "/testing/2.0.0", "/testing/1.0.0", "/testing")
+	require.NoError(t, err)
+	require.Equal(t, protocol.ID("/testing"), s.Protocol())
+	assertWait(t, connectedOn, "/testing")
+	s.Close()
+
+	supported, err := h1.Peerstore().SupportsProtocols(h2.ID(), "/testing/2.0.0", "/testing/1.0.0", "/testing")
+	require.NoError(t, err)
+	require.Equal(t, []protocol.ID{"/testing"}, supported)
+
+	_, err = bh1.NewStreamWithFallback(ctx, h2.ID(), "/foo", "/bar")
+	require.ErrorIs(t, err, msmux.ErrNotSupported[protocol.ID]{})
+}
+
+func TestNewStreamOpenLimit(t *testing.T) {
+	h1, err := NewHost(swarmt.GenSwarm(t), &HostOpts{
+		StreamOpenLimits: []StreamOpenLimit{{
+			Service:   "testing",
+			Protocols: []protocol.ID{"/testing"},
+			Rate:      0.001,
+			Burst:     1,
+		}},
+	})
+	require.NoError(t, err)
+	h1.Start()
+	defer h1.Close()
+	h2, err := NewHost(swarmt.GenSwarm(t), nil)
+	require.NoError(t, err)
+	h2.Start()
+	defer h2.Close()
+	require.NoError(t, h1.Connect(context.Background(), h2.Peerstore().PeerInfo(h2.ID())))
+	h2.SetStreamHandler("/testing", func(s network.Stream) { s.Close() })
+
+	s, err := h1.NewStream(context.Background(), h2.ID(), "/testing")
+	require.NoError(t, err)
+	s.Close()
+	_, err = h1.NewStream(context.Background(), h2.ID(), "/testing")
+	require.ErrorIs(t, err, ErrStreamRateLimited)
+	_, err = h1.NewStreamWithFallback(context.Background(), h2.ID(), "/testing")
+	require.ErrorIs(t, err, ErrStreamRateLimited)
+
+	// other protocols aren't limited
+	h2.SetStreamHandler("/other", func(s network.Stream) { s.Close() })
+	s, err = h1.NewStream(context.Background(), h2.ID(), "/other")
+	require.NoError(t, err)
+	s.Close()
+}
+
+func TestListenOnAndStopListening(t *testing.T) {
+	h1, err := NewHost(swarmt.GenSwarm(t), nil)
+	require.NoError(t, err)
+	h1.Start()
+	defer h1.Close()
+	h2, err := NewHost(swarmt.GenSwarm(t), nil)
+	require.NoError(t, err)
+	h2.Start()
+	defer h2.Close()
+	require.NoError(t, h2.Connect(context.Background(), peer.AddrInfo{ID: h1.ID(), Addrs: h1.Addrs()}))
+
+	sub, err := h1.EventBus().Subscribe(&event.EvtLocalAddressesUpdated{}, eventbus.BufSize(10))
+	require.NoError(t, err)
+	defer sub.Close()
+	// drain the current state
+	waitForAddrChangeEvent(context.Background(), sub, t)
+
+	before := h1.Network().ListenAddresses()
+	require.NoError(t, h1.ListenOn(ma.StringCast("/ip4/127.0.0.1/tcp/0")))
+	var added ma.Multiaddr
+	for _, a := range h1.Network().ListenAddresses() {
+		if !ma.Contains(before, a) {
+			added = a
+		}
+	}
+	require.NotNil(t, added)
+
+	evt := waitForAddrChangeEvent(context.Background(), sub, t)
+	require.Contains(t, evt.Current, event.UpdatedAddress{Address: added, Action: event.Added})
+	// the new address is pushed to connected peers
+	require.Eventually(t, func() bool {
+		return ma.Contains(h2.Peerstore().Addrs(h1.ID()), added)
+	}, 5*time.Second, 10*time.Millisecond)
+
+	require.NoError(t, h1.StopListening(added))
+	require.ElementsMatch(t, before, h1.Network().ListenAddresses())
+	evt = waitForAddrChangeEvent(context.Background(), sub, t)
+	require.Equal(t, []event.UpdatedAddress{{Address: added, Action: event.Removed}}, evt.Removed)
+	// the other listeners still work
+	require.NoError(t, h1.Network().ClosePeer(h2.ID()))
+	require.NoError(t, h2.Connect(context.Background(), peer.AddrInfo{ID: h1.ID(), Addrs: h1.Addrs()}))
+
+	require.ErrorIs(t, h1.StopListening(added), swarm.ErrNoListener)
+}
+
 func TestAddrChangeImmediatelyIfAddressNonEmpty(t *testing.T) {
 	ctx := context.Background()
 	taddrs := []ma.Multiaddr{ma.StringCast("/ip4/1.2.3.4/tcp/1234")}
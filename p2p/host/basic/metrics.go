@@ -0,0 +1,109 @@
+package basichost
+
+import (
+	"time"
+
+	"github.com/libp2p/go-libp2p/p2p/metricshelper"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const metricNamespace = "libp2p_host"
+
+type metricsCollectors struct {
+	streamOpensLimitedTotal *prometheus.CounterVec
+	streamOpenWaitSeconds   *prometheus.HistogramVec
+}
+
+func newMetricsCollectors() *metricsCollectors {
+	return &metricsCollectors{
+		streamOpensLimitedTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: metricNamespace,
+				Name:      "stream_opens_limited_total",
+				Help:      "Stream opens subject to a stream open limit",
+			},
+			[]string{"service", "outcome"},
+		),
+		streamOpenWaitSeconds: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace: metricNamespace,
+				Name:      "stream_open_wait_seconds",
+				Help:      "Time a stream open waited for the stream open limit",
+				Buckets:   []float64{0.01, 0.05, 0.1, 0.25, 0.5, 1, 2, 5, 10, 30},
+			},
+			[]string{"service"},
+		),
+	}
+}
+
+func (c *metricsCollectors) Collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		c.streamOpensLimitedTotal,
+		c.streamOpenWaitSeconds,
+	}
+}
+
+// defaultCollectors are shared by all metrics tracers, unless they register
+// with a metricshelper.LabeledRegisterer.
+var defaultCollectors = newMetricsCollectors()
+
+// MetricsTracer is the interface for tracking metrics of the host.
+type MetricsTracer interface {
+	// StreamOpenAllowed tracks a stream open of service that was allowed
+	// after waiting for wait.
+	StreamOpenAllowed(service string, wait time.Duration)
+	// StreamOpenRejected tracks a stream open of service that was rejected,
+	// either because the queue was full or because the context was canceled.
+	StreamOpenRejected(service string)
+}
+
+type metricsTracer struct {
+	*metricsCollectors
+}
+
+var _ MetricsTracer = &metricsTracer{}
+
+type metricsTracerSetting struct {
+	reg prometheus.Registerer
+}
+
+type MetricsTracerOption func(*metricsTracerSetting)
+
+func WithRegisterer(reg prometheus.Registerer) MetricsTracerOption {
+	return func(s *metricsTracerSetting) {
+		if reg != nil {
+			s.reg = reg
+		}
+	}
+}
+
+func NewMetricsTracer(opts ...MetricsTracerOption) MetricsTracer {
+	setting := &metricsTracerSetting{reg: prometheus.DefaultRegisterer}
+	for _, opt := range opts {
+		opt(setting)
+	}
+	return &metricsTracer{metricshelper.Collectors(setting.reg, defaultCollectors, newMetricsCollectors)}
+}
+
+func (m *metricsTracer) StreamOpenAllowed(service string, wait time.Duration) {
+	tags := metricshelper.GetStringSlice()
+	defer metricshelper.PutStringSlice(tags)
+
+	*tags = append(*tags, service)
+	if wait > 0 {
+		*tags = append(*tags, "delayed")
+	} else {
+		*tags = append(*tags, "allowed")
+	}
+	m.streamOpensLimitedTotal.WithLabelValues(*tags...).Inc()
+	m.streamOpenWaitSeconds.WithLabelValues(service).Observe(wait.Seconds())
+}
+
+func (m *metricsTracer) StreamOpenRejected(service string) {
+	tags := metricshelper.GetStringSlice()
+	defer metricshelper.PutStringSlice(tags)
+
+	*tags = append(*tags, service, "rejected")
+	m.streamOpensLimitedTotal.WithLabelValues(*tags...).Inc()
+}
@@ -0,0 +1,26 @@
+//go:build nocover
+
+package basichost
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestMetricsNoAllocNoCover(t *testing.T) {
+	services := []string{"identify", "kad", "bitswap"}
+	mt := NewMetricsTracer()
+	tests := map[string]func(){
+		"StreamOpenAllowed": func() {
+			mt.StreamOpenAllowed(services[rand.Intn(len(services))], time.Duration(rand.Intn(2))*time.Second)
+		},
+		"StreamOpenRejected": func() { mt.StreamOpenRejected(services[rand.Intn(len(services))]) },
+	}
+	for method, f := range tests {
+		allocs := testing.AllocsPerRun(1000, f)
+		if allocs > 0 {
+			t.Fatalf("Alloc Test: %s, got: %0.2f, expected: 0 allocs", method, allocs)
+		}
+	}
+}
@@ -14,6 +14,7 @@ import (
 	netip "net/netip"
 	reflect "reflect"
 
+	inat "github.com/libp2p/go-libp2p/p2p/net/nat"
 	gomock "go.uber.org/mock/gomock"
 )
 
@@ -83,6 +84,20 @@ func (mr *MockNATMockRecorder) GetMapping(arg0, arg1 any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMapping", reflect.TypeOf((*MockNAT)(nil).GetMapping), arg0, arg1)
 }
 
+// Mappings mocks base method.
+func (m *MockNAT) Mappings() []inat.Mapping {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Mappings")
+	ret0, _ := ret[0].([]inat.Mapping)
+	return ret0
+}
+
+// Mappings indicates an expected call of Mappings.
+func (mr *MockNATMockRecorder) Mappings() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Mappings", reflect.TypeOf((*MockNAT)(nil).Mappings))
+}
+
 // RemoveMapping mocks base method.
 func (m *MockNAT) RemoveMapping(arg0 context.Context, arg1 string, arg2 int) error {
 	m.ctrl.T.Helper()
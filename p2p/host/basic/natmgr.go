@@ -9,6 +9,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/libp2p/go-libp2p/core/event"
 	"github.com/libp2p/go-libp2p/core/network"
 	inat "github.com/libp2p/go-libp2p/p2p/net/nat"
 
@@ -30,6 +31,17 @@ func NewNATManager(net network.Network) NATManager {
 	return newNATManager(net)
 }
 
+// NewNATManagerWithOptions returns a NAT manager constructor that passes opts
+// to the NAT device once it is discovered. This can be used to configure the
+// renewal policy of port mappings, e.g.
+//
+//	libp2p.NATManager(basichost.NewNATManagerWithOptions(nat.WithMappingDuration(time.Hour)))
+func NewNATManagerWithOptions(opts ...inat.Option) func(network.Network) NATManager {
+	return func(net network.Network) NATManager {
+		return newNATManager(net, opts...)
+	}
+}
+
 type entry struct {
 	protocol string
 	port     int
@@ -39,11 +51,12 @@ type nat interface {
 	AddMapping(ctx context.Context, protocol string, port int) error
 	RemoveMapping(ctx context.Context, protocol string, port int) error
 	GetMapping(protocol string, port int) (netip.AddrPort, bool)
+	Mappings() []inat.Mapping
 	io.Closer
 }
 
 // so we can mock it in tests
-var discoverNAT = func(ctx context.Context) (nat, error) { return inat.DiscoverNAT(ctx) }
+var discoverNAT = func(ctx context.Context, opts ...inat.Option) (nat, error) { return inat.DiscoverNAT(ctx, opts...) }
 
 // natManager takes care of adding + removing port mappings to the nat.
 // Initialized with the host if it has a NATPortMap option enabled.
@@ -55,6 +68,10 @@ type natManager struct {
 	net   network.Network
 	natMx sync.RWMutex
 	nat   nat
+	opts  []inat.Option
+
+	emitterMx sync.Mutex
+	emitter   event.Emitter // emits EvtNATPortMappingChanged, may be nil
 
 	syncFlag chan struct{} // cap: 1
 
@@ -65,10 +82,11 @@ type natManager struct {
 	ctxCancel context.CancelFunc
 }
 
-func newNATManager(net network.Network) *natManager {
+func newNATManager(net network.Network, opts ...inat.Option) *natManager {
 	ctx, cancel := context.WithCancel(context.Background())
 	nmgr := &natManager{
 		net:       net,
+		opts:      opts,
 		syncFlag:  make(chan struct{}, 1),
 		ctx:       ctx,
 		ctxCancel: cancel,
@@ -93,6 +111,55 @@ func (nmgr *natManager) HasDiscoveredNAT() bool {
 	return nmgr.nat != nil
 }
 
+// Mappings returns the port mappings currently maintained on the NAT device.
+// It returns nil if no NAT device has been discovered.
+func (nmgr *natManager) Mappings() []inat.Mapping {
+	nmgr.natMx.RLock()
+	defer nmgr.natMx.RUnlock()
+	if nmgr.nat == nil {
+		return nil
+	}
+	return nmgr.nat.Mappings()
+}
+
+// setEmitter sets the emitter used to emit EvtNATPortMappingChanged events.
+func (nmgr *natManager) setEmitter(em event.Emitter) {
+	nmgr.emitterMx.Lock()
+	defer nmgr.emitterMx.Unlock()
+	nmgr.emitter = em
+}
+
+func (nmgr *natManager) emitMappingEvent(e inat.MappingEvent) {
+	nmgr.emitterMx.Lock()
+	defer nmgr.emitterMx.Unlock()
+	if nmgr.emitter == nil {
+		return
+	}
+	var change event.NATPortMappingChange
+	switch e.Type {
+	case inat.MappingCreated:
+		change = event.NATPortMappingCreated
+	case inat.MappingRenewed:
+		change = event.NATPortMappingRenewed
+	case inat.MappingLost:
+		change = event.NATPortMappingLost
+	case inat.MappingRemoved:
+		change = event.NATPortMappingRemoved
+	default:
+		return
+	}
+	if err := nmgr.emitter.Emit(event.EvtNATPortMappingChanged{
+		Change:       change,
+		Protocol:     e.Mapping.Protocol,
+		InternalPort: e.Mapping.InternalPort,
+		External:     e.Mapping.External,
+		Gateway:      e.Mapping.Gateway,
+		Expiry:       e.Mapping.Expiry,
+	}); err != nil {
+		log.Debugf("failed to emit NAT port mapping event: %s", err)
+	}
+}
+
 func (nmgr *natManager) background(ctx context.Context) {
 	defer nmgr.refCount.Done()
 
@@ -107,7 +174,8 @@ func (nmgr *natManager) background(ctx context.Context) {
 
 	discoverCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
-	natInstance, err := discoverNAT(discoverCtx)
+	opts := append([]inat.Option{inat.WithMappingNotifier(nmgr.emitMappingEvent)}, nmgr.opts...)
+	natInstance, err := discoverNAT(discoverCtx, opts...)
 	if err != nil {
 		log.Info("DiscoverNAT error:", err)
 		return
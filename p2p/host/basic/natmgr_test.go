@@ -10,6 +10,9 @@ import (
 
 	ma "github.com/multiformats/go-multiaddr"
 
+	"github.com/libp2p/go-libp2p/core/event"
+	"github.com/libp2p/go-libp2p/p2p/host/eventbus"
+	inat "github.com/libp2p/go-libp2p/p2p/net/nat"
 	swarmt "github.com/libp2p/go-libp2p/p2p/net/swarm/testing"
 
 	"go.uber.org/mock/gomock"
@@ -20,7 +23,7 @@ func setupMockNAT(t *testing.T) (mockNAT *MockNAT, reset func()) {
 	ctrl := gomock.NewController(t)
 	mockNAT = NewMockNAT(ctrl)
 	origDiscoverNAT := discoverNAT
-	discoverNAT = func(ctx context.Context) (nat, error) { return mockNAT, nil }
+	discoverNAT = func(ctx context.Context, _ ...inat.Option) (nat, error) { return mockNAT, nil }
 	return mockNAT, func() {
 		discoverNAT = origDiscoverNAT
 		ctrl.Finish()
@@ -106,3 +109,45 @@ func TestAddAndRemoveListeners(t *testing.T) {
 	mockNAT.EXPECT().RemoveMapping(gomock.Any(), "tcp", 1234).MaxTimes(1)
 	mockNAT.EXPECT().Close().MaxTimes(1)
 }
+
+func TestNATMappingEvents(t *testing.T) {
+	mockNAT, reset := setupMockNAT(t)
+	defer reset()
+
+	sw := swarmt.GenSwarm(t)
+	defer sw.Close()
+	m := newNATManager(sw)
+	require.Eventually(t, func() bool {
+		m.natMx.Lock()
+		defer m.natMx.Unlock()
+		return m.nat != nil
+	}, time.Second, time.Millisecond)
+
+	bus := eventbus.NewBus()
+	sub, err := bus.Subscribe(new(event.EvtNATPortMappingChanged))
+	require.NoError(t, err)
+	defer sub.Close()
+	em, err := bus.Emitter(new(event.EvtNATPortMappingChanged))
+	require.NoError(t, err)
+	defer em.Close()
+	m.setEmitter(em)
+
+	external := netip.AddrPortFrom(netip.AddrFrom4([4]byte{1, 2, 3, 4}), 4321)
+	mapping := inat.Mapping{Protocol: "tcp", InternalPort: 1234, External: external}
+	m.emitMappingEvent(inat.MappingEvent{Type: inat.MappingCreated, Mapping: mapping})
+	select {
+	case e := <-sub.Out():
+		evt := e.(event.EvtNATPortMappingChanged)
+		require.Equal(t, event.NATPortMappingCreated, evt.Change)
+		require.Equal(t, "tcp", evt.Protocol)
+		require.Equal(t, 1234, evt.InternalPort)
+		require.Equal(t, external, evt.External)
+	case <-time.After(time.Second):
+		t.Fatal("didn't receive a NAT port mapping event")
+	}
+
+	mockNAT.EXPECT().Mappings().Return([]inat.Mapping{mapping})
+	require.Equal(t, []inat.Mapping{mapping}, m.Mappings())
+
+	mockNAT.EXPECT().Close().MaxTimes(1)
+}
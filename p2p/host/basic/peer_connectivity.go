@@ -0,0 +1,185 @@
+package basichost
+
+import (
+	"context"
+	"sync"
+
+	"github.com/libp2p/go-libp2p/core/event"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/p2p/host/eventbus"
+
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+// PeerConnectivity summarizes how we're connected to a peer.
+type PeerConnectivity struct {
+	// Connectedness is the connectedness to the peer.
+	Connectedness network.Connectedness
+	// Direct is true if we have at least one direct, i.e. not relayed,
+	// connection to the peer.
+	Direct bool
+	// Relays are the relays through which we have a connection to the peer.
+	Relays []peer.ID
+	// HolePunchAttempted is true if we tried to hole punch to the peer
+	// while connected to it.
+	HolePunchAttempted bool
+	// HolePunchSucceeded is true if hole punching to the peer established a
+	// direct connection.
+	HolePunchSucceeded bool
+}
+
+// PeerConnectivity returns a summary of how we're connected to p.
+func (h *BasicHost) PeerConnectivity(p peer.ID) PeerConnectivity {
+	pc := PeerConnectivity{Connectedness: h.Network().Connectedness(p)}
+	for _, c := range h.Network().ConnsToPeer(p) {
+		relay, ok := relayOf(c.RemoteMultiaddr())
+		if !ok {
+			pc.Direct = true
+			continue
+		}
+		if relay != "" {
+			pc.Relays = append(pc.Relays, relay)
+		}
+	}
+	if hp, ok := h.connectivity.holePunch(p); ok {
+		pc.HolePunchAttempted = true
+		pc.HolePunchSucceeded = hp.Success
+	}
+	return pc
+}
+
+// relayOf returns the ID of the relay a connection with remote address a goes
+// through. It returns false if a isn't a relayed address.
+func relayOf(a ma.Multiaddr) (peer.ID, bool) {
+	relayAddr, circuit := ma.SplitFunc(a, func(c ma.Component) bool {
+		return c.Protocol().Code == ma.P_CIRCUIT
+	})
+	if circuit == nil {
+		return "", false
+	}
+	if relayAddr == nil {
+		return "", true
+	}
+	_, relay := peer.SplitAddr(relayAddr)
+	return relay, true
+}
+
+// connectivityTracker keeps track of the relays we're connected to peers
+// through, and of the outcome of hole punches, and emits an
+// EvtPeerConnectionUpgraded when a relayed connection is upgraded to a direct
+// one.
+type connectivityTracker struct {
+	eventbus event.Bus
+	network  network.Network
+	emitter  event.Emitter
+
+	ctx      context.Context
+	cancel   context.CancelFunc
+	refCount sync.WaitGroup
+
+	mx          sync.Mutex
+	relays      map[peer.ID][]peer.ID
+	holePunches map[peer.ID]event.EvtHolePunchFinished
+}
+
+func newConnectivityTracker(bus event.Bus, n network.Network) (*connectivityTracker, error) {
+	emitter, err := bus.Emitter(new(event.EvtPeerConnectionUpgraded))
+	if err != nil {
+		return nil, err
+	}
+	t := &connectivityTracker{
+		eventbus:    bus,
+		network:     n,
+		emitter:     emitter,
+		relays:      make(map[peer.ID][]peer.ID),
+		holePunches: make(map[peer.ID]event.EvtHolePunchFinished),
+	}
+	t.ctx, t.cancel = context.WithCancel(context.Background())
+	return t, nil
+}
+
+func (t *connectivityTracker) Start() {
+	sub, err := t.eventbus.Subscribe(
+		[]interface{}{new(event.EvtPeerConnectednessChanged), new(event.EvtHolePunchFinished)},
+		eventbus.Name("basichost (connectivity tracker)"),
+	)
+	if err != nil {
+		log.Errorf("failed to subscribe to connectivity events: %s", err)
+		return
+	}
+	t.refCount.Add(1)
+	go t.background(sub)
+}
+
+func (t *connectivityTracker) background(sub event.Subscription) {
+	defer t.refCount.Done()
+	defer sub.Close()
+
+	for {
+		select {
+		case e, ok := <-sub.Out():
+			if !ok {
+				return
+			}
+			switch e := e.(type) {
+			case event.EvtPeerConnectednessChanged:
+				t.handleConnectednessChanged(e)
+			case event.EvtHolePunchFinished:
+				t.handleHolePunchFinished(e)
+			}
+		case <-t.ctx.Done():
+			return
+		}
+	}
+}
+
+func (t *connectivityTracker) handleConnectednessChanged(e event.EvtPeerConnectednessChanged) {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+
+	switch e.Connectedness {
+	case network.Limited:
+		var relays []peer.ID
+		for _, c := range t.network.ConnsToPeer(e.Peer) {
+			if relay, ok := relayOf(c.RemoteMultiaddr()); ok && relay != "" {
+				relays = append(relays, relay)
+			}
+		}
+		t.relays[e.Peer] = relays
+	case network.Connected:
+		relays, ok := t.relays[e.Peer]
+		if !ok {
+			return
+		}
+		delete(t.relays, e.Peer)
+		t.emitter.Emit(event.EvtPeerConnectionUpgraded{Peer: e.Peer, Relays: relays})
+	default:
+		delete(t.relays, e.Peer)
+		delete(t.holePunches, e.Peer)
+	}
+}
+
+func (t *connectivityTracker) handleHolePunchFinished(e event.EvtHolePunchFinished) {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+
+	// Don't keep state for peers we already disconnected from.
+	if t.network.Connectedness(e.Peer) == network.NotConnected {
+		return
+	}
+	t.holePunches[e.Peer] = e
+}
+
+func (t *connectivityTracker) holePunch(p peer.ID) (event.EvtHolePunchFinished, bool) {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	e, ok := t.holePunches[p]
+	return e, ok
+}
+
+func (t *connectivityTracker) Close() error {
+	t.cancel()
+	t.refCount.Wait()
+	return t.emitter.Close()
+}
@@ -0,0 +1,105 @@
+package basichost
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/event"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/test"
+	"github.com/libp2p/go-libp2p/p2p/host/eventbus"
+	swarmt "github.com/libp2p/go-libp2p/p2p/net/swarm/testing"
+
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRelayOf(t *testing.T) {
+	relay := test.RandPeerIDFatal(t)
+	p := test.RandPeerIDFatal(t)
+
+	_, ok := relayOf(ma.StringCast("/ip4/1.2.3.4/tcp/1"))
+	require.False(t, ok)
+
+	r, ok := relayOf(ma.StringCast("/ip4/1.2.3.4/tcp/1/p2p/" + relay.String() + "/p2p-circuit"))
+	require.True(t, ok)
+	require.Equal(t, relay, r)
+
+	r, ok = relayOf(ma.StringCast("/ip4/1.2.3.4/tcp/1/p2p/" + relay.String() + "/p2p-circuit/p2p/" + p.String()))
+	require.True(t, ok)
+	require.Equal(t, relay, r)
+
+	r, ok = relayOf(ma.StringCast("/p2p-circuit"))
+	require.True(t, ok)
+	require.Empty(t, r)
+}
+
+func TestPeerConnectivity(t *testing.T) {
+	bus := eventbus.NewBus()
+	h1, err := NewHost(swarmt.GenSwarm(t, swarmt.EventBus(bus)), &HostOpts{EventBus: bus})
+	require.NoError(t, err)
+	h1.Start()
+	defer h1.Close()
+	h2, err := NewHost(swarmt.GenSwarm(t), nil)
+	require.NoError(t, err)
+	h2.Start()
+	defer h2.Close()
+
+	require.Equal(t, PeerConnectivity{Connectedness: network.NotConnected}, h1.PeerConnectivity(h2.ID()))
+
+	require.NoError(t, h1.Connect(context.Background(), peer.AddrInfo{ID: h2.ID(), Addrs: h2.Addrs()}))
+	require.Equal(t, PeerConnectivity{Connectedness: network.Connected, Direct: true}, h1.PeerConnectivity(h2.ID()))
+
+	em, err := h1.EventBus().Emitter(new(event.EvtHolePunchFinished))
+	require.NoError(t, err)
+	defer em.Close()
+	require.NoError(t, em.Emit(event.EvtHolePunchFinished{Peer: h2.ID(), Success: true, Attempts: 2}))
+	require.Eventually(t, func() bool {
+		pc := h1.PeerConnectivity(h2.ID())
+		return pc.HolePunchAttempted && pc.HolePunchSucceeded
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// the hole punch state is removed once we disconnect
+	require.NoError(t, h1.Network().ClosePeer(h2.ID()))
+	require.Eventually(t, func() bool {
+		pc := h1.PeerConnectivity(h2.ID())
+		return pc.Connectedness == network.NotConnected && !pc.HolePunchAttempted
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestPeerConnectionUpgradedEvent(t *testing.T) {
+	h, err := NewHost(swarmt.GenSwarm(t), nil)
+	require.NoError(t, err)
+	h.Start()
+	defer h.Close()
+
+	sub, err := h.EventBus().Subscribe(new(event.EvtPeerConnectionUpgraded))
+	require.NoError(t, err)
+	defer sub.Close()
+	em, err := h.EventBus().Emitter(new(event.EvtPeerConnectednessChanged))
+	require.NoError(t, err)
+	defer em.Close()
+
+	// a direct connection to a peer that we weren't connected to through a
+	// relay isn't an upgrade
+	p1 := test.RandPeerIDFatal(t)
+	require.NoError(t, em.Emit(event.EvtPeerConnectednessChanged{Peer: p1, Connectedness: network.Connected}))
+
+	p2 := test.RandPeerIDFatal(t)
+	require.NoError(t, em.Emit(event.EvtPeerConnectednessChanged{Peer: p2, Connectedness: network.Limited}))
+	require.NoError(t, em.Emit(event.EvtPeerConnectednessChanged{Peer: p2, Connectedness: network.Connected}))
+
+	select {
+	case e := <-sub.Out():
+		require.Equal(t, p2, e.(event.EvtPeerConnectionUpgraded).Peer)
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected an EvtPeerConnectionUpgraded")
+	}
+	select {
+	case e := <-sub.Out():
+		t.Fatalf("unexpected event: %v", e)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
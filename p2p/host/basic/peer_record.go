@@ -0,0 +1,94 @@
+package basichost
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/libp2p/go-libp2p/core/event"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/record"
+	"github.com/libp2p/go-libp2p/p2p/host/eventbus"
+	"github.com/libp2p/go-libp2p/p2p/protocol/identify"
+)
+
+// ErrSignedPeerRecordDisabled is returned when accessing our own signed peer
+// record on a host with signed peer records disabled.
+var ErrSignedPeerRecordDisabled = errors.New("signed peer records are disabled")
+
+// SignedPeerRecord returns our current signed peer record, as sent to other
+// peers by identify, serialized in a record.Envelope, and its sequence number.
+func (h *BasicHost) SignedPeerRecord() ([]byte, uint64, error) {
+	if h.disableSignedPeerRecord {
+		return nil, 0, ErrSignedPeerRecordDisabled
+	}
+	env := h.caBook.GetPeerRecord(h.ID())
+	if env == nil {
+		return nil, 0, errors.New("no signed peer record for self")
+	}
+	r, err := env.Record()
+	if err != nil {
+		return nil, 0, err
+	}
+	rec, ok := r.(*peer.PeerRecord)
+	if !ok {
+		return nil, 0, errors.New("not a peer record")
+	}
+	b, err := env.Marshal()
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to marshal signed peer record: %w", err)
+	}
+	return b, rec.Seq, nil
+}
+
+// SubscribeSignedPeerRecord returns a channel on which our signed peer record
+// is sent every time it changes, starting with the current one. The channel is
+// closed when ctx is canceled or the host is closed.
+func (h *BasicHost) SubscribeSignedPeerRecord(ctx context.Context) (<-chan *record.Envelope, error) {
+	if h.disableSignedPeerRecord {
+		return nil, ErrSignedPeerRecordDisabled
+	}
+	sub, err := h.eventbus.Subscribe(new(event.EvtLocalAddressesUpdated), eventbus.Name("signed peer record"))
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(chan *record.Envelope, 1)
+	h.refCount.Add(1)
+	go func() {
+		defer h.refCount.Done()
+		defer close(out)
+		defer sub.Close()
+		for {
+			var e interface{}
+			select {
+			case e = <-sub.Out():
+			case <-ctx.Done():
+				return
+			case <-h.ctx.Done():
+				return
+			}
+			evt := e.(event.EvtLocalAddressesUpdated)
+			if evt.SignedPeerRecord == nil {
+				continue
+			}
+			select {
+			case out <- evt.SignedPeerRecord:
+			case <-ctx.Done():
+				return
+			case <-h.ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// VerifySignedPeerRecord verifies a signed peer record obtained out of band,
+// e.g. from a configuration file, and returns the peer record it contains.
+// The record is not added to the peerstore. Use the peerstore's
+// CertifiedAddrBook to do so.
+func (h *BasicHost) VerifySignedPeerRecord(data []byte) (*peer.PeerRecord, error) {
+	_, rec, err := identify.ConsumeSignedPeerRecord(data)
+	return rec, err
+}
@@ -0,0 +1,184 @@
+package basichost
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+
+	"github.com/benbjohnson/clock"
+)
+
+// ErrStreamRateLimited is returned by NewStream if opening the stream would
+// exceed the StreamOpenLimit of the service, and too many calls are already
+// waiting to open a stream.
+var ErrStreamRateLimited = errors.New("stream open rate limit exceeded")
+
+// StreamOpenLimit limits the rate at which the host opens streams of a
+// service to a single peer. Calls to NewStream that exceed the limit wait
+// until a stream can be opened (as long as the context allows), up to
+// MaxQueued calls per peer. Further calls fail with ErrStreamRateLimited.
+//
+// Limits are enforced on the dial side only, and are applied per peer: every
+// peer has its own token bucket for every service.
+type StreamOpenLimit struct {
+	// Service is the name of the service. It is used in metrics.
+	Service string
+	// Protocols are the protocols of the service. A stream belongs to the
+	// service if the first protocol passed to NewStream is one of them.
+	Protocols []protocol.ID
+	// Rate is the number of streams per second that can be opened.
+	Rate float64
+	// Burst is the number of streams that can be opened at once.
+	Burst int
+	// MaxQueued is the number of calls that can wait to open a stream.
+	MaxQueued int
+}
+
+const streamLimiterPruneInterval = time.Minute
+
+type streamLimiterKey struct {
+	p       peer.ID
+	service string
+}
+
+type streamBucket struct {
+	limit  *StreamOpenLimit
+	tokens float64
+	last   time.Time
+	queued int
+}
+
+// streamLimiter is a token bucket limiter for opening streams, keyed by
+// (peer, service).
+type streamLimiter struct {
+	clock  clock.Clock
+	limits map[protocol.ID]*StreamOpenLimit
+	mt     MetricsTracer
+
+	mu        sync.Mutex
+	buckets   map[streamLimiterKey]*streamBucket
+	lastPrune time.Time
+}
+
+func newStreamLimiter(limits []StreamOpenLimit, cl clock.Clock, mt MetricsTracer) (*streamLimiter, error) {
+	l := &streamLimiter{
+		clock:   cl,
+		limits:  make(map[protocol.ID]*StreamOpenLimit),
+		mt:      mt,
+		buckets: make(map[streamLimiterKey]*streamBucket),
+	}
+	services := make(map[string]struct{}, len(limits))
+	for _, limit := range limits {
+		limit := limit
+		if limit.Rate <= 0 || limit.Burst < 1 || limit.MaxQueued < 0 {
+			return nil, fmt.Errorf("invalid stream open limit for service %s", limit.Service)
+		}
+		if _, ok := services[limit.Service]; ok {
+			return nil, fmt.Errorf("duplicate stream open limit for service %s", limit.Service)
+		}
+		services[limit.Service] = struct{}{}
+		for _, pid := range limit.Protocols {
+			if other, ok := l.limits[pid]; ok {
+				return nil, fmt.Errorf("protocol %s is part of services %s and %s", pid, other.Service, limit.Service)
+			}
+			l.limits[pid] = &limit
+		}
+	}
+	l.lastPrune = cl.Now()
+	return l, nil
+}
+
+// refill adds the tokens accumulated since the last call to b.
+func (b *streamBucket) refill(now time.Time) {
+	b.tokens += now.Sub(b.last).Seconds() * b.limit.Rate
+	if b.tokens > float64(b.limit.Burst) {
+		b.tokens = float64(b.limit.Burst)
+	}
+	b.last = now
+}
+
+// Acquire blocks until a stream with protocol pid can be opened to p.
+func (l *streamLimiter) Acquire(ctx context.Context, p peer.ID, pid protocol.ID) error {
+	limit, ok := l.limits[pid]
+	if !ok {
+		return nil
+	}
+	key := streamLimiterKey{p: p, service: limit.Service}
+
+	l.mu.Lock()
+	now := l.clock.Now()
+	l.maybePrune(now)
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &streamBucket{limit: limit, tokens: float64(limit.Burst), last: now}
+		l.buckets[key] = b
+	}
+	b.refill(now)
+	if b.tokens >= 1 {
+		b.tokens--
+		l.mu.Unlock()
+		if l.mt != nil {
+			l.mt.StreamOpenAllowed(limit.Service, 0)
+		}
+		return nil
+	}
+	if b.queued >= limit.MaxQueued {
+		l.mu.Unlock()
+		if l.mt != nil {
+			l.mt.StreamOpenRejected(limit.Service)
+		}
+		return ErrStreamRateLimited
+	}
+	// Reserve a token. Reservations make the number of tokens go negative,
+	// so that queued calls are served in order.
+	b.tokens--
+	b.queued++
+	wait := time.Duration(-b.tokens / limit.Rate * float64(time.Second))
+	l.mu.Unlock()
+
+	t := l.clock.Timer(wait)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		l.mu.Lock()
+		b.queued--
+		l.mu.Unlock()
+		if l.mt != nil {
+			l.mt.StreamOpenAllowed(limit.Service, wait)
+		}
+		return nil
+	case <-ctx.Done():
+		l.mu.Lock()
+		b.queued--
+		// give back the reserved token
+		b.tokens++
+		l.mu.Unlock()
+		if l.mt != nil {
+			l.mt.StreamOpenRejected(limit.Service)
+		}
+		return ctx.Err()
+	}
+}
+
+// maybePrune removes the buckets that are full, since they're equivalent to
+// the bucket created on the next Acquire.
+func (l *streamLimiter) maybePrune(now time.Time) {
+	if now.Sub(l.lastPrune) < streamLimiterPruneInterval {
+		return
+	}
+	l.lastPrune = now
+	for key, b := range l.buckets {
+		if b.queued > 0 {
+			continue
+		}
+		b.refill(now)
+		if b.tokens >= float64(b.limit.Burst) {
+			delete(l.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package basichost
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	limitedProto   = protocol.ID("/limited")
+	unlimitedProto = protocol.ID("/unlimited")
+)
+
+func newTestStreamLimiter(t *testing.T, cl clock.Clock) *streamLimiter {
+	t.Helper()
+	l, err := newStreamLimiter([]StreamOpenLimit{{
+		Service:   "test",
+		Protocols: []protocol.ID{limitedProto},
+		Rate:      1,
+		Burst:     2,
+		MaxQueued: 1,
+	}}, cl, nil)
+	require.NoError(t, err)
+	return l
+}
+
+func (l *streamLimiter) queued(p peer.ID) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	b, ok := l.buckets[streamLimiterKey{p: p, service: "test"}]
+	if !ok {
+		return 0
+	}
+	return b.queued
+}
+
+func TestStreamLimiter(t *testing.T) {
+	cl := clock.NewMock()
+	l := newTestStreamLimiter(t, cl)
+	ctx := context.Background()
+	p1, p2 := peer.ID("p1"), peer.ID("p2")
+
+	// the burst is available immediately
+	require.NoError(t, l.Acquire(ctx, p1, limitedProto))
+	require.NoError(t, l.Acquire(ctx, p1, limitedProto))
+	// other peers and other protocols are not affected
+	require.NoError(t, l.Acquire(ctx, p2, limitedProto))
+	for i := 0; i < 10; i++ {
+		require.NoError(t, l.Acquire(ctx, p1, unlimitedProto))
+	}
+
+	// the next call is queued
+	done := make(chan error, 1)
+	go func() { done <- l.Acquire(ctx, p1, limitedProto) }()
+	require.Eventually(t, func() bool { return l.queued(p1) == 1 }, time.Second, time.Millisecond)
+	// the queue is full
+	require.ErrorIs(t, l.Acquire(ctx, p1, limitedProto), ErrStreamRateLimited)
+
+	cl.Add(999 * time.Millisecond)
+	select {
+	case <-done:
+		t.Fatal("didn't expect the call to return yet")
+	case <-time.After(10 * time.Millisecond):
+	}
+	cl.Add(time.Millisecond)
+	require.NoError(t, <-done)
+	require.Zero(t, l.queued(p1))
+
+	// the bucket refills over time
+	cl.Add(2 * time.Second)
+	require.NoError(t, l.Acquire(ctx, p1, limitedProto))
+	require.NoError(t, l.Acquire(ctx, p1, limitedProto))
+}
+
+func TestStreamLimiterCancel(t *testing.T) {
+	cl := clock.NewMock()
+	l := newTestStreamLimiter(t, cl)
+	p := peer.ID("p")
+	require.NoError(t, l.Acquire(context.Background(), p, limitedProto))
+	require.NoError(t, l.Acquire(context.Background(), p, limitedProto))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- l.Acquire(ctx, p, limitedProto) }()
+	require.Eventually(t, func() bool { return l.queued(p) == 1 }, time.Second, time.Millisecond)
+	cancel()
+	require.ErrorIs(t, <-done, context.Canceled)
+
+	// the reserved token was given back
+	cl.Add(time.Second)
+	require.NoError(t, l.Acquire(context.Background(), p, limitedProto))
+}
+
+func TestStreamLimiterPrune(t *testing.T) {
+	cl := clock.NewMock()
+	l := newTestStreamLimiter(t, cl)
+	require.NoError(t, l.Acquire(context.Background(), "p1", limitedProto))
+	cl.Add(streamLimiterPruneInterval)
+	require.NoError(t, l.Acquire(context.Background(), "p2", limitedProto))
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	require.Len(t, l.buckets, 1)
+	require.Contains(t, l.buckets, streamLimiterKey{p: "p2", service: "test"})
+}
+
+func TestStreamLimiterInvalidLimits(t *testing.T) {
+	valid := StreamOpenLimit{Service: "a", Protocols: []protocol.ID{"/a"}, Rate: 1, Burst: 1}
+	for name, limits := range map[string][]StreamOpenLimit{
+		"zero rate":          {{Service: "a", Rate: 0, Burst: 1}},
+		"zero burst":         {{Service: "a", Rate: 1, Burst: 0}},
+		"negative queue":     {{Service: "a", Rate: 1, Burst: 1, MaxQueued: -1}},
+		"duplicate service":  {valid, {Service: "a", Protocols: []protocol.ID{"/b"}, Rate: 1, Burst: 1}},
+		"duplicate protocol": {valid, {Service: "b", Protocols: []protocol.ID{"/a"}, Rate: 1, Burst: 1}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := newStreamLimiter(limits, clock.New(), nil)
+			require.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,237 @@
+// Package bootstrap maintains connections to a set of bootstrap peers.
+package bootstrap
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/event"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/p2p/host/eventbus"
+
+	"github.com/benbjohnson/clock"
+	logging "github.com/ipfs/go-log/v2"
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+var log = logging.Logger("bootstrap")
+
+// Bootstrapper maintains connections to a set of bootstrap peers.
+//
+// On Start, it dials the bootstrap peers, staggering the dials. Failed
+// connection attempts are retried with exponential backoff. Once connected,
+// the connection is checked periodically and reestablished if it was lost.
+// Bootstrap peers we successfully connected to are persisted, if a Store is
+// configured. Changes to the bootstrap health are emitted as
+// event.EvtBootstrapHealthChanged on the host's event bus.
+type Bootstrapper struct {
+	host    host.Host
+	cfg     config
+	peers   []peer.AddrInfo
+	emitter event.Emitter
+
+	ctx       context.Context
+	ctxCancel context.CancelFunc
+	refCount  sync.WaitGroup
+
+	mx        sync.Mutex
+	used      map[peer.ID]peer.AddrInfo
+	lastState event.EvtBootstrapHealthChanged
+}
+
+// New creates a new Bootstrapper for the given peers.
+// Connections are only established once Start is called.
+func New(h host.Host, peers []peer.AddrInfo, opts ...Option) (*Bootstrapper, error) {
+	cfg := defaultConfig
+	cfg.clock = clock.New()
+	for _, opt := range opts {
+		if err := opt(&cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	b := &Bootstrapper{
+		host: h,
+		cfg:  cfg,
+		used: make(map[peer.ID]peer.AddrInfo),
+	}
+	b.addPeers(peers)
+	if cfg.store != nil {
+		persisted, err := cfg.store.Load()
+		if err != nil {
+			log.Warnw("failed to load persisted bootstrap peers", "error", err)
+		}
+		for _, pi := range persisted {
+			b.used[pi.ID] = pi
+		}
+		b.addPeers(persisted)
+	}
+	if len(b.peers) == 0 {
+		return nil, errors.New("no bootstrap peers")
+	}
+
+	emitter, err := h.EventBus().Emitter(new(event.EvtBootstrapHealthChanged), eventbus.Stateful)
+	if err != nil {
+		return nil, err
+	}
+	b.emitter = emitter
+	b.lastState = event.EvtBootstrapHealthChanged{Total: len(b.peers)}
+	b.ctx, b.ctxCancel = context.WithCancel(context.Background())
+	return b, nil
+}
+
+// addPeers adds peers to the bootstrap set, merging the addresses of peers
+// that are already known.
+func (b *Bootstrapper) addPeers(peers []peer.AddrInfo) {
+	for _, pi := range peers {
+		if pi.ID == b.host.ID() {
+			continue
+		}
+		merged := false
+		for i, known := range b.peers {
+			if known.ID == pi.ID {
+				b.peers[i].Addrs = ma.Unique(append(known.Addrs, pi.Addrs...))
+				merged = true
+				break
+			}
+		}
+		if !merged {
+			b.peers = append(b.peers, peer.AddrInfo{ID: pi.ID, Addrs: append([]ma.Multiaddr(nil), pi.Addrs...)})
+		}
+	}
+}
+
+// Start starts connecting to the bootstrap peers.
+func (b *Bootstrapper) Start() error {
+	for i, pi := range b.peers {
+		b.refCount.Add(1)
+		go b.maintain(pi, time.Duration(i)*b.cfg.stagger)
+	}
+	return nil
+}
+
+// Close stops the Bootstrapper. It doesn't close existing connections.
+func (b *Bootstrapper) Close() error {
+	b.ctxCancel()
+	b.refCount.Wait()
+	return b.emitter.Close()
+}
+
+// Peers returns the bootstrap peers, including persisted ones.
+func (b *Bootstrapper) Peers() []peer.AddrInfo {
+	return append([]peer.AddrInfo(nil), b.peers...)
+}
+
+// Health returns the current bootstrap health.
+func (b *Bootstrapper) Health() event.EvtBootstrapHealthChanged {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	return b.lastState
+}
+
+// maintain keeps a connection to a single bootstrap peer.
+func (b *Bootstrapper) maintain(pi peer.AddrInfo, delay time.Duration) {
+	defer b.refCount.Done()
+
+	timer := b.cfg.clock.Timer(delay)
+	defer timer.Stop()
+	backoff := b.cfg.backoffBase
+	for {
+		select {
+		case <-b.ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		if b.host.Network().Connectedness(pi.ID) == network.Connected {
+			backoff = b.cfg.backoffBase
+			b.updateHealth()
+			timer.Reset(b.cfg.healthCheckInterval)
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(b.ctx, b.cfg.dialTimeout)
+		err := b.host.Connect(ctx, pi)
+		cancel()
+		if err != nil {
+			if b.ctx.Err() != nil {
+				return
+			}
+			log.Debugw("failed to connect to bootstrap peer", "peer", pi.ID, "error", err, "backoff", backoff)
+			b.updateHealth()
+			timer.Reset(backoff)
+			backoff *= 2
+			if backoff > b.cfg.backoffMax {
+				backoff = b.cfg.backoffMax
+			}
+			continue
+		}
+		log.Debugw("connected to bootstrap peer", "peer", pi.ID)
+		backoff = b.cfg.backoffBase
+		b.recordUsed(pi.ID)
+		b.updateHealth()
+		timer.Reset(b.cfg.healthCheckInterval)
+	}
+}
+
+// recordUsed records the addresses we're connected to p on and persists the
+// set of used bootstrap peers.
+func (b *Bootstrapper) recordUsed(p peer.ID) {
+	if b.cfg.store == nil {
+		return
+	}
+	var addrs []ma.Multiaddr
+	for _, c := range b.host.Network().ConnsToPeer(p) {
+		addrs = append(addrs, c.RemoteMultiaddr())
+	}
+	if len(addrs) == 0 {
+		return
+	}
+
+	b.mx.Lock()
+	b.used[p] = peer.AddrInfo{ID: p, Addrs: ma.Unique(addrs)}
+	used := make([]peer.AddrInfo, 0, len(b.used))
+	for _, pi := range b.used {
+		used = append(used, pi)
+	}
+	b.mx.Unlock()
+
+	sort.Slice(used, func(i, j int) bool { return used[i].ID < used[j].ID })
+	if err := b.cfg.store.Save(used); err != nil {
+		log.Warnw("failed to persist bootstrap peers", "error", err)
+	}
+}
+
+// updateHealth recomputes the bootstrap health and emits an event if it changed.
+func (b *Bootstrapper) updateHealth() {
+	var connected int
+	for _, pi := range b.peers {
+		if b.host.Network().Connectedness(pi.ID) == network.Connected {
+			connected++
+		}
+	}
+	minConnected := b.cfg.minConnected
+	if minConnected > len(b.peers) {
+		minConnected = len(b.peers)
+	}
+	state := event.EvtBootstrapHealthChanged{
+		Connected: connected,
+		Total:     len(b.peers),
+		Healthy:   connected >= minConnected,
+	}
+
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	if state == b.lastState {
+		return
+	}
+	b.lastState = state
+	if err := b.emitter.Emit(state); err != nil {
+		log.Warnw("failed to emit bootstrap health event", "error", err)
+	}
+}
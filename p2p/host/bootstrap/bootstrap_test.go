@@ -0,0 +1,119 @@
+package bootstrap
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/event"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	basic "github.com/libp2p/go-libp2p/p2p/host/basic"
+	swarmt "github.com/libp2p/go-libp2p/p2p/net/swarm/testing"
+
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/stretchr/testify/require"
+)
+
+func newHost(t *testing.T) host.Host {
+	t.Helper()
+	h, err := basic.NewHost(swarmt.GenSwarm(t), nil)
+	require.NoError(t, err)
+	h.Start()
+	t.Cleanup(func() { h.Close() })
+	return h
+}
+
+func nextHealth(t *testing.T, sub event.Subscription) event.EvtBootstrapHealthChanged {
+	t.Helper()
+	select {
+	case e := <-sub.Out():
+		return e.(event.EvtBootstrapHealthChanged)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for bootstrap health event")
+	}
+	return event.EvtBootstrapHealthChanged{}
+}
+
+func TestBootstrapConnectsAndPersists(t *testing.T) {
+	h := newHost(t)
+	bs1 := newHost(t)
+	bs2 := newHost(t)
+	store := NewFileStore(filepath.Join(t.TempDir(), "bootstrap.json"))
+
+	b, err := New(h, []peer.AddrInfo{
+		{ID: bs1.ID(), Addrs: bs1.Addrs()},
+		{ID: bs2.ID(), Addrs: bs2.Addrs()},
+	}, WithStagger(200*time.Millisecond), WithMinConnected(2), WithStore(store))
+	require.NoError(t, err)
+	sub, err := h.EventBus().Subscribe(new(event.EvtBootstrapHealthChanged))
+	require.NoError(t, err)
+	defer sub.Close()
+
+	require.NoError(t, b.Start())
+	defer b.Close()
+
+	e := nextHealth(t, sub)
+	require.Equal(t, event.EvtBootstrapHealthChanged{Connected: 1, Total: 2}, e)
+	e = nextHealth(t, sub)
+	require.Equal(t, event.EvtBootstrapHealthChanged{Connected: 2, Total: 2, Healthy: true}, e)
+	require.Equal(t, e, b.Health())
+
+	persisted, err := store.Load()
+	require.NoError(t, err)
+	require.Len(t, persisted, 2)
+
+	// a new bootstrapper picks up the persisted peers
+	b2, err := New(newHost(t), nil, WithStore(store))
+	require.NoError(t, err)
+	defer b2.Close()
+	require.Len(t, b2.Peers(), 2)
+}
+
+func TestBootstrapReconnects(t *testing.T) {
+	h := newHost(t)
+	bs := newHost(t)
+
+	b, err := New(h, []peer.AddrInfo{{ID: bs.ID(), Addrs: bs.Addrs()}},
+		WithHealthCheckInterval(50*time.Millisecond),
+	)
+	require.NoError(t, err)
+	require.NoError(t, b.Start())
+	defer b.Close()
+
+	require.Eventually(t, func() bool { return h.Network().Connectedness(bs.ID()) == network.Connected }, 5*time.Second, 10*time.Millisecond)
+	require.NoError(t, h.Network().ClosePeer(bs.ID()))
+	require.Eventually(t, func() bool { return h.Network().Connectedness(bs.ID()) == network.Connected }, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestBootstrapBackoff(t *testing.T) {
+	h := newHost(t)
+	bs := newHost(t)
+	addrs := bs.Addrs()
+	id := bs.ID()
+	bs.Close()
+	// closing the host doesn't close the swarm
+	bs.Network().Close()
+
+	b, err := New(h, []peer.AddrInfo{{ID: id, Addrs: addrs}},
+		WithBackoff(10*time.Millisecond, 20*time.Millisecond),
+		WithDialTimeout(100*time.Millisecond),
+	)
+	require.NoError(t, err)
+	require.NoError(t, b.Start())
+	defer b.Close()
+
+	time.Sleep(200 * time.Millisecond)
+	require.False(t, b.Health().Healthy)
+	require.Equal(t, 1, b.Health().Total)
+}
+
+func TestBootstrapNoPeers(t *testing.T) {
+	h := newHost(t)
+	_, err := New(h, nil)
+	require.Error(t, err)
+	// we never bootstrap off ourselves
+	_, err = New(h, []peer.AddrInfo{{ID: h.ID(), Addrs: []ma.Multiaddr{ma.StringCast("/ip4/127.0.0.1/tcp/1")}}})
+	require.Error(t, err)
+}
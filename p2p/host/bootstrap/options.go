@@ -0,0 +1,109 @@
+package bootstrap
+
+import (
+	"errors"
+	"time"
+
+	"github.com/benbjohnson/clock"
+)
+
+type config struct {
+	stagger             time.Duration
+	backoffBase         time.Duration
+	backoffMax          time.Duration
+	healthCheckInterval time.Duration
+	dialTimeout         time.Duration
+	minConnected        int
+	store               Store
+	clock               clock.Clock
+}
+
+var defaultConfig = config{
+	stagger:             500 * time.Millisecond,
+	backoffBase:         time.Second,
+	backoffMax:          5 * time.Minute,
+	healthCheckInterval: 30 * time.Second,
+	dialTimeout:         15 * time.Second,
+	minConnected:        1,
+}
+
+// Option is an option for the bootstrap service.
+type Option func(*config) error
+
+// WithStagger sets the delay between the initial dials to the bootstrap
+// peers, so that we don't dial all of them at the same time.
+func WithStagger(d time.Duration) Option {
+	return func(c *config) error {
+		if d < 0 {
+			return errors.New("stagger must not be negative")
+		}
+		c.stagger = d
+		return nil
+	}
+}
+
+// WithBackoff sets the initial and maximum backoff after failing to connect
+// to a bootstrap peer. The backoff doubles after every failed attempt.
+func WithBackoff(base, max time.Duration) Option {
+	return func(c *config) error {
+		if base <= 0 || max < base {
+			return errors.New("invalid backoff")
+		}
+		c.backoffBase = base
+		c.backoffMax = max
+		return nil
+	}
+}
+
+// WithHealthCheckInterval sets the interval at which the connection to
+// connected bootstrap peers is checked.
+func WithHealthCheckInterval(d time.Duration) Option {
+	return func(c *config) error {
+		if d <= 0 {
+			return errors.New("health check interval must be positive")
+		}
+		c.healthCheckInterval = d
+		return nil
+	}
+}
+
+// WithDialTimeout sets the timeout for a single connection attempt.
+func WithDialTimeout(d time.Duration) Option {
+	return func(c *config) error {
+		if d <= 0 {
+			return errors.New("dial timeout must be positive")
+		}
+		c.dialTimeout = d
+		return nil
+	}
+}
+
+// WithMinConnected sets the number of bootstrap peers we need to be connected
+// to in order to be considered healthy. Defaults to 1.
+func WithMinConnected(n int) Option {
+	return func(c *config) error {
+		if n <= 0 {
+			return errors.New("min connected must be positive")
+		}
+		c.minConnected = n
+		return nil
+	}
+}
+
+// WithStore sets the store used to persist bootstrap peers that we
+// successfully connected to. Persisted peers are loaded on construction
+// and used in addition to the configured peers.
+func WithStore(s Store) Option {
+	return func(c *config) error {
+		c.store = s
+		return nil
+	}
+}
+
+// WithClock sets the clock. Used for testing.
+func WithClock(cl clock.Clock) Option {
+	return func(c *config) error {
+		c.clock = cl
+		return nil
+	}
+}
@@ -0,0 +1,67 @@
+package bootstrap
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// Store persists the bootstrap peers we successfully connected to.
+type Store interface {
+	// Load returns the persisted peers.
+	Load() ([]peer.AddrInfo, error)
+	// Save replaces the persisted peers.
+	Save([]peer.AddrInfo) error
+}
+
+type fileStore struct {
+	path string
+}
+
+var _ Store = &fileStore{}
+
+// NewFileStore returns a Store that persists peers to a JSON file at path.
+// A missing file is treated as an empty store.
+func NewFileStore(path string) Store {
+	return &fileStore{path: path}
+}
+
+func (s *fileStore) Load() ([]peer.AddrInfo, error) {
+	b, err := os.ReadFile(s.path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var peers []peer.AddrInfo
+	if err := json.Unmarshal(b, &peers); err != nil {
+		return nil, err
+	}
+	return peers, nil
+}
+
+func (s *fileStore) Save(peers []peer.AddrInfo) error {
+	b, err := json.Marshal(peers)
+	if err != nil {
+		return err
+	}
+	// Write to a temporary file first, so that we never leave a truncated file behind.
+	f, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), s.path)
+}
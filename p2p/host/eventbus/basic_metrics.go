@@ -11,55 +11,72 @@ import (
 
 const metricNamespace = "libp2p_eventbus"
 
-var (
-	eventsEmitted = prometheus.NewCounterVec(
-		prometheus.CounterOpts{
-			Namespace: metricNamespace,
-			Name:      "events_emitted_total",
-			Help:      "Events Emitted",
-		},
-		[]string{"event"},
-	)
-	totalSubscribers = prometheus.NewGaugeVec(
-		prometheus.GaugeOpts{
-			Namespace: metricNamespace,
-			Name:      "subscribers_total",
-			Help:      "Number of subscribers for an event type",
-		},
-		[]string{"event"},
-	)
-	subscriberQueueLength = prometheus.NewGaugeVec(
-		prometheus.GaugeOpts{
-			Namespace: metricNamespace,
-			Name:      "subscriber_queue_length",
-			Help:      "Subscriber queue length",
-		},
-		[]string{"subscriber_name"},
-	)
-	subscriberQueueFull = prometheus.NewGaugeVec(
-		prometheus.GaugeOpts{
-			Namespace: metricNamespace,
-			Name:      "subscriber_queue_full",
-			Help:      "Subscriber Queue completely full",
-		},
-		[]string{"subscriber_name"},
-	)
-	subscriberEventQueued = prometheus.NewCounterVec(
-		prometheus.CounterOpts{
-			Namespace: metricNamespace,
-			Name:      "subscriber_event_queued",
-			Help:      "Event Queued for subscriber",
-		},
-		[]string{"subscriber_name"},
-	)
-	collectors = []prometheus.Collector{
-		eventsEmitted,
-		totalSubscribers,
-		subscriberQueueLength,
-		subscriberQueueFull,
-		subscriberEventQueued,
+type metricsCollectors struct {
+	eventsEmitted         *prometheus.CounterVec
+	totalSubscribers      *prometheus.GaugeVec
+	subscriberQueueLength *prometheus.GaugeVec
+	subscriberQueueFull   *prometheus.GaugeVec
+	subscriberEventQueued *prometheus.CounterVec
+}
+
+func newMetricsCollectors() *metricsCollectors {
+	return &metricsCollectors{
+		eventsEmitted: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: metricNamespace,
+				Name:      "events_emitted_total",
+				Help:      "Events Emitted",
+			},
+			[]string{"event"},
+		),
+		totalSubscribers: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: metricNamespace,
+				Name:      "subscribers_total",
+				Help:      "Number of subscribers for an event type",
+			},
+			[]string{"event"},
+		),
+		subscriberQueueLength: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: metricNamespace,
+				Name:      "subscriber_queue_length",
+				Help:      "Subscriber queue length",
+			},
+			[]string{"subscriber_name"},
+		),
+		subscriberQueueFull: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: metricNamespace,
+				Name:      "subscriber_queue_full",
+				Help:      "Subscriber Queue completely full",
+			},
+			[]string{"subscriber_name"},
+		),
+		subscriberEventQueued: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: metricNamespace,
+				Name:      "subscriber_event_queued",
+				Help:      "Event Queued for subscriber",
+			},
+			[]string{"subscriber_name"},
+		),
 	}
-)
+}
+
+func (c *metricsCollectors) Collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		c.eventsEmitted,
+		c.totalSubscribers,
+		c.subscriberQueueLength,
+		c.subscriberQueueFull,
+		c.subscriberEventQueued,
+	}
+}
+
+// defaultCollectors are shared by all metrics tracers, unless they register
+// with a metricshelper.LabeledRegisterer.
+var defaultCollectors = newMetricsCollectors()
 
 // MetricsTracer tracks metrics for the eventbus subsystem
 type MetricsTracer interface {
@@ -83,7 +100,9 @@ type MetricsTracer interface {
 	SubscriberEventQueued(name string)
 }
 
-type metricsTracer struct{}
+type metricsTracer struct {
+	*metricsCollectors
+}
 
 var _ MetricsTracer = &metricsTracer{}
 
@@ -106,8 +125,7 @@ func NewMetricsTracer(opts ...MetricsTracerOption) MetricsTracer {
 	for _, opt := range opts {
 		opt(setting)
 	}
-	metricshelper.RegisterCollectors(setting.reg, collectors...)
-	return &metricsTracer{}
+	return &metricsTracer{metricshelper.Collectors(setting.reg, defaultCollectors, newMetricsCollectors)}
 }
 
 func (m *metricsTracer) EventEmitted(typ reflect.Type) {
@@ -115,7 +133,7 @@ func (m *metricsTracer) EventEmitted(typ reflect.Type) {
 	defer metricshelper.PutStringSlice(tags)
 
 	*tags = append(*tags, strings.TrimPrefix(typ.String(), "event."))
-	eventsEmitted.WithLabelValues(*tags...).Inc()
+	m.eventsEmitted.WithLabelValues(*tags...).Inc()
 }
 
 func (m *metricsTracer) AddSubscriber(typ reflect.Type) {
@@ -123,7 +141,7 @@ func (m *metricsTracer) AddSubscriber(typ reflect.Type) {
 	defer metricshelper.PutStringSlice(tags)
 
 	*tags = append(*tags, strings.TrimPrefix(typ.String(), "event."))
-	totalSubscribers.WithLabelValues(*tags...).Inc()
+	m.totalSubscribers.WithLabelValues(*tags...).Inc()
 }
 
 func (m *metricsTracer) RemoveSubscriber(typ reflect.Type) {
@@ -131,7 +149,7 @@ func (m *metricsTracer) RemoveSubscriber(typ reflect.Type) {
 	defer metricshelper.PutStringSlice(tags)
 
 	*tags = append(*tags, strings.TrimPrefix(typ.String(), "event."))
-	totalSubscribers.WithLabelValues(*tags...).Dec()
+	m.totalSubscribers.WithLabelValues(*tags...).Dec()
 }
 
 func (m *metricsTracer) SubscriberQueueLength(name string, n int) {
@@ -139,7 +157,7 @@ func (m *metricsTracer) SubscriberQueueLength(name string, n int) {
 	defer metricshelper.PutStringSlice(tags)
 
 	*tags = append(*tags, name)
-	subscriberQueueLength.WithLabelValues(*tags...).Set(float64(n))
+	m.subscriberQueueLength.WithLabelValues(*tags...).Set(float64(n))
 }
 
 func (m *metricsTracer) SubscriberQueueFull(name string, isFull bool) {
@@ -147,7 +165,7 @@ func (m *metricsTracer) SubscriberQueueFull(name string, isFull bool) {
 	defer metricshelper.PutStringSlice(tags)
 
 	*tags = append(*tags, name)
-	observer := subscriberQueueFull.WithLabelValues(*tags...)
+	observer := m.subscriberQueueFull.WithLabelValues(*tags...)
 	if isFull {
 		observer.Set(1)
 	} else {
@@ -160,5 +178,5 @@ func (m *metricsTracer) SubscriberEventQueued(name string) {
 	defer metricshelper.PutStringSlice(tags)
 
 	*tags = append(*tags, name)
-	subscriberEventQueued.WithLabelValues(*tags...).Inc()
+	m.subscriberEventQueued.WithLabelValues(*tags...).Inc()
 }
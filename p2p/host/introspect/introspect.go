@@ -0,0 +1,268 @@
+// Package introspect exposes the live state of a libp2p host, for debugging
+// tools and dashboards.
+//
+// The state contains the open connections (including the transport, security
+// protocol, stream multiplexer and cipher suite used) and their streams, the listen,
+// advertised and observed addresses, the usage of the resource scopes, and the
+// relay reservations. It is served as JSON over HTTP, and pushed periodically
+// over a WebSocket:
+//
+//	i, err := introspect.New(h)
+//	...
+//	// Only ever serve this on a local interface!
+//	go http.ListenAndServe("127.0.0.1:5001", i)
+//
+// GET / returns the current State. Connecting to /ws with a WebSocket client
+// streams the State at the configured interval. Browsers can only connect
+// from the same origin or from localhost, unless other origins are allowed
+// using WithAllowedOrigins.
+package introspect
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	rcmgr "github.com/libp2p/go-libp2p/p2p/host/resource-manager"
+	"github.com/libp2p/go-libp2p/p2p/protocol/identify"
+
+	"github.com/gorilla/websocket"
+	logging "github.com/ipfs/go-log/v2"
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+var log = logging.Logger("introspect")
+
+// State is a snapshot of the state of the host.
+type State struct {
+	// ID is the peer ID of the host.
+	ID peer.ID `json:"id"`
+	// Time is the time the snapshot was taken.
+	Time time.Time `json:"time"`
+	// Addrs are the addresses of the host.
+	Addrs Addrs `json:"addrs"`
+	// Conns are the open connections.
+	Conns []Conn `json:"conns"`
+	// StreamsByProtocol is the number of open streams per protocol.
+	StreamsByProtocol map[protocol.ID]int `json:"streamsByProtocol"`
+	// Resources is the usage of the resource scopes. It is nil if the resource
+	// manager doesn't support usage reporting.
+	Resources *rcmgr.ResourceManagerUsage `json:"resources,omitempty"`
+	// Reservations are the relay reservations.
+	Reservations Reservations `json:"reservations"`
+}
+
+// Addrs are the addresses of the host.
+type Addrs struct {
+	// Listen are the addresses the host listens on.
+	Listen []ma.Multiaddr `json:"listen"`
+	// Advertised are the addresses the host advertises to other peers.
+	Advertised []ma.Multiaddr `json:"advertised"`
+	// Observed are our addresses as observed by other peers.
+	Observed []ma.Multiaddr `json:"observed"`
+}
+
+// Conn is an open connection.
+type Conn struct {
+	ID          string       `json:"id"`
+	Peer        peer.ID      `json:"peer"`
+	LocalAddr   ma.Multiaddr `json:"localAddr"`
+	RemoteAddr  ma.Multiaddr `json:"remoteAddr"`
+	Direction   string       `json:"direction"`
+	Opened      time.Time    `json:"opened"`
+	Limited     bool         `json:"limited"`
+	Transport   string       `json:"transport"`
+	Security    protocol.ID  `json:"security"`
+	Muxer       protocol.ID  `json:"muxer"`
+	EarlyMuxer  bool         `json:"earlyMuxer"`
+	CipherSuite string       `json:"cipherSuite,omitempty"`
+	EarlyData   bool         `json:"earlyData"`
+	Streams     []Stream     `json:"streams"`
+}
+
+// Stream is an open stream.
+type Stream struct {
+	ID        string      `json:"id"`
+	Protocol  protocol.ID `json:"protocol"`
+	Direction string      `json:"direction"`
+	Opened    time.Time   `json:"opened"`
+}
+
+// Reservations are the relay reservations.
+type Reservations struct {
+	// Relays are the relays we hold a reservation with, as inferred from our
+	// advertised relay addresses.
+	Relays []peer.ID `json:"relays"`
+	// Served are the reservations our relay service granted to other peers,
+	// with their expiration time. Only available if configured using WithRelayService.
+	Served map[peer.ID]time.Time `json:"served,omitempty"`
+}
+
+// RelayService is implemented by relay services that can report the
+// reservations they granted, e.g. *relay.Relay.
+type RelayService interface {
+	Reservations() map[peer.ID]time.Time
+}
+
+// Option is an option for the Introspector.
+type Option func(*Introspector) error
+
+// WithPushInterval sets the interval at which the state is pushed to WebSocket
+// clients. Defaults to one second.
+func WithPushInterval(d time.Duration) Option {
+	return func(i *Introspector) error {
+		if d <= 0 {
+			return errors.New("push interval must be positive")
+		}
+		i.pushInterval = d
+		return nil
+	}
+}
+
+// WithRelayService adds the reservations granted by the relay service to the state.
+func WithRelayService(r RelayService) Option {
+	return func(i *Introspector) error {
+		i.relay = r
+		return nil
+	}
+}
+
+// WithAllowedOrigins allows browsers to connect to the WebSocket from pages
+// served by the given origins, e.g. "https://dashboard.example.com". By
+// default, only pages served from the same origin as the Introspector or from
+// localhost can connect, so that other websites can't read the state of the
+// host (cross-site WebSocket hijacking). Use "*" to allow any origin.
+func WithAllowedOrigins(origins ...string) Option {
+	return func(i *Introspector) error {
+		for _, o := range origins {
+			if o == "*" {
+				i.allowAnyOrigin = true
+				continue
+			}
+			u, err := url.Parse(o)
+			if err != nil || u.Scheme == "" || u.Host == "" {
+				return fmt.Errorf("invalid origin: %q", o)
+			}
+			i.allowedOrigins[strings.ToLower(u.Scheme+"://"+u.Host)] = struct{}{}
+		}
+		return nil
+	}
+}
+
+// Introspector collects the state of a host, and serves it over HTTP.
+type Introspector struct {
+	host         host.Host
+	pushInterval time.Duration
+	relay        RelayService
+
+	allowAnyOrigin bool
+	allowedOrigins map[string]struct{}
+	upgrader       websocket.Upgrader
+}
+
+// New creates a new Introspector for the given host.
+func New(h host.Host, opts ...Option) (*Introspector, error) {
+	i := &Introspector{
+		host:           h,
+		pushInterval:   time.Second,
+		allowedOrigins: make(map[string]struct{}),
+	}
+	for _, opt := range opts {
+		if err := opt(i); err != nil {
+			return nil, err
+		}
+	}
+	i.upgrader = websocket.Upgrader{CheckOrigin: i.checkOrigin}
+	return i, nil
+}
+
+// State returns a snapshot of the current state of the host.
+func (i *Introspector) State() *State {
+	s := &State{
+		ID:                i.host.ID(),
+		Time:              time.Now(),
+		StreamsByProtocol: make(map[protocol.ID]int),
+	}
+
+	s.Addrs.Listen = i.host.Network().ListenAddresses()
+	s.Addrs.Advertised = i.host.Addrs()
+	if h, ok := i.host.(interface{ IDService() identify.IDService }); ok && h.IDService() != nil {
+		s.Addrs.Observed = h.IDService().OwnObservedAddrs()
+	}
+
+	for _, c := range i.host.Network().Conns() {
+		s.Conns = append(s.Conns, i.conn(c, s.StreamsByProtocol))
+	}
+	sort.Slice(s.Conns, func(a, b int) bool { return s.Conns[a].Opened.Before(s.Conns[b].Opened) })
+
+	if r, ok := i.host.Network().ResourceManager().(rcmgr.ResourceManagerUsageReporter); ok {
+		usage := r.Usage()
+		s.Resources = &usage
+	}
+
+	s.Reservations.Relays = relaysFromAddrs(s.Addrs.Advertised)
+	if i.relay != nil {
+		s.Reservations.Served = i.relay.Reservations()
+	}
+	return s
+}
+
+func (i *Introspector) conn(c network.Conn, streamsByProtocol map[protocol.ID]int) Conn {
+	stat := c.Stat()
+	state := c.ConnState()
+	conn := Conn{
+		ID:          c.ID(),
+		Peer:        c.RemotePeer(),
+		LocalAddr:   c.LocalMultiaddr(),
+		RemoteAddr:  c.RemoteMultiaddr(),
+		Direction:   stat.Direction.String(),
+		Opened:      stat.Opened,
+		Limited:     stat.Limited,
+		Transport:   state.Transport,
+		Security:    state.Security,
+		Muxer:       state.StreamMultiplexer,
+		EarlyMuxer:  state.UsedEarlyMuxerNegotiation,
+		CipherSuite: state.CipherSuite,
+		EarlyData:   state.UsedEarlyData,
+	}
+	for _, str := range c.GetStreams() {
+		sstat := str.Stat()
+		conn.Streams = append(conn.Streams, Stream{
+			ID:        str.ID(),
+			Protocol:  str.Protocol(),
+			Direction: sstat.Direction.String(),
+			Opened:    sstat.Opened,
+		})
+		streamsByProtocol[str.Protocol()]++
+	}
+	return conn
+}
+
+// relaysFromAddrs returns the relays used by the relay addresses.
+func relaysFromAddrs(addrs []ma.Multiaddr) []peer.ID {
+	var relays []peer.ID
+	seen := make(map[peer.ID]struct{})
+	for _, a := range addrs {
+		relayAddr, circuit := ma.SplitFunc(a, func(c ma.Component) bool { return c.Protocol().Code == ma.P_CIRCUIT })
+		if relayAddr == nil || circuit == nil {
+			continue
+		}
+		info, err := peer.AddrInfoFromP2pAddr(relayAddr)
+		if err != nil {
+			continue
+		}
+		if _, ok := seen[info.ID]; ok {
+			continue
+		}
+		seen[info.ID] = struct{}{}
+		relays = append(relays, info.ID)
+	}
+	return relays
+}
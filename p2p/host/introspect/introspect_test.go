@@ -0,0 +1,138 @@
+package introspect
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	bhost "github.com/libp2p/go-libp2p/p2p/host/basic"
+	swarmt "github.com/libp2p/go-libp2p/p2p/net/swarm/testing"
+
+	"github.com/gorilla/websocket"
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/stretchr/testify/require"
+)
+
+type mockRelay map[peer.ID]time.Time
+
+func (m mockRelay) Reservations() map[peer.ID]time.Time { return m }
+
+func TestState(t *testing.T) {
+	// QUIC connections don't report a security protocol and muxer
+	h1, err := bhost.NewHost(swarmt.GenSwarm(t, swarmt.OptDisableQUIC), nil)
+	require.NoError(t, err)
+	defer h1.Close()
+	h1.Start()
+	h2, err := bhost.NewHost(swarmt.GenSwarm(t, swarmt.OptDisableQUIC), nil)
+	require.NoError(t, err)
+	defer h2.Close()
+	h2.Start()
+
+	h2.SetStreamHandler("/test", func(s network.Stream) {})
+	require.NoError(t, h1.Connect(context.Background(), peer.AddrInfo{ID: h2.ID(), Addrs: h2.Addrs()}))
+	s, err := h1.NewStream(context.Background(), h2.ID(), "/test")
+	require.NoError(t, err)
+	defer s.Close()
+
+	expire := time.Now().Add(time.Hour)
+	i, err := New(h1, WithRelayService(mockRelay{h2.ID(): expire}))
+	require.NoError(t, err)
+
+	state := i.State()
+	require.Equal(t, h1.ID(), state.ID)
+	require.NotEmpty(t, state.Addrs.Listen)
+	require.Len(t, state.Conns, 1)
+	c := state.Conns[0]
+	require.Equal(t, h2.ID(), c.Peer)
+	require.NotEmpty(t, c.Transport)
+	require.NotEmpty(t, c.Security)
+	require.NotEmpty(t, c.Muxer)
+	require.Len(t, c.Streams, 1)
+	require.Equal(t, protocol.ID("/test"), c.Streams[0].Protocol)
+	require.Equal(t, 1, state.StreamsByProtocol["/test"])
+	require.Contains(t, state.Reservations.Served, h2.ID())
+}
+
+func TestRelaysFromAddrs(t *testing.T) {
+	relay := "QmcgpsyWgH8Y8ajJz1Cu72KnS5uo2Aa2LpzU7kinSupGVN"
+	relays := relaysFromAddrs([]ma.Multiaddr{
+		ma.StringCast("/ip4/1.2.3.4/tcp/1"),
+		ma.StringCast("/ip4/1.2.3.5/tcp/1/p2p/" + relay + "/p2p-circuit"),
+		ma.StringCast("/ip4/1.2.3.5/udp/1/quic-v1/p2p/" + relay + "/p2p-circuit"),
+	})
+	require.Len(t, relays, 1)
+	require.Equal(t, relay, relays[0].String())
+}
+
+func TestServeHTTP(t *testing.T) {
+	h, err := bhost.NewHost(swarmt.GenSwarm(t), nil)
+	require.NoError(t, err)
+	defer h.Close()
+
+	i, err := New(h, WithPushInterval(10*time.Millisecond))
+	require.NoError(t, err)
+	srv := httptest.NewServer(i)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	var state map[string]interface{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&state))
+	require.Equal(t, h.ID().String(), state["id"])
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/ws", nil)
+	require.NoError(t, err)
+	defer conn.Close()
+	for j := 0; j < 2; j++ {
+		var msg map[string]interface{}
+		require.NoError(t, conn.ReadJSON(&msg))
+		require.Equal(t, h.ID().String(), msg["id"])
+	}
+}
+
+func TestWebSocketOrigin(t *testing.T) {
+	h, err := bhost.NewHost(swarmt.GenSwarm(t), nil)
+	require.NoError(t, err)
+	defer h.Close()
+
+	dial := func(t *testing.T, i *Introspector, origin string) error {
+		srv := httptest.NewServer(i)
+		defer srv.Close()
+		header := http.Header{}
+		if origin != "" {
+			header.Set("Origin", origin)
+		}
+		conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/ws", header)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+
+	i, err := New(h)
+	require.NoError(t, err)
+	require.NoError(t, dial(t, i, ""))
+	require.NoError(t, dial(t, i, "http://localhost:3000"))
+	require.NoError(t, dial(t, i, "http://127.0.0.1:3000"))
+	require.Error(t, dial(t, i, "https://evil.example.com"))
+
+	i, err = New(h, WithAllowedOrigins("https://dashboard.example.com"))
+	require.NoError(t, err)
+	require.NoError(t, dial(t, i, "https://dashboard.example.com"))
+	require.Error(t, dial(t, i, "https://evil.example.com"))
+
+	i, err = New(h, WithAllowedOrigins("*"))
+	require.NoError(t, err)
+	require.NoError(t, dial(t, i, "https://evil.example.com"))
+
+	_, err = New(h, WithAllowedOrigins("dashboard.example.com"))
+	require.Error(t, err)
+}
@@ -0,0 +1,86 @@
+package introspect
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ServeHTTP serves the state of the host. Requests to a path ending with /ws
+// are upgraded to a WebSocket connection, on which the state is pushed
+// periodically. All other requests are answered with the current state.
+func (i *Introspector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if strings.HasSuffix(r.URL.Path, "/ws") {
+		i.serveWebSocket(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(i.State()); err != nil {
+		log.Debugw("failed to write state", "error", err)
+	}
+}
+
+// checkOrigin only allows browsers to connect from the same origin, from
+// localhost, or from one of the allowed origins. Requests without an Origin
+// header don't come from browsers, and are always allowed.
+func (i *Introspector) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" || i.allowAnyOrigin {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	if strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	if host := u.Hostname(); strings.EqualFold(host, "localhost") {
+		return true
+	} else if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return true
+	}
+	_, ok := i.allowedOrigins[strings.ToLower(u.Scheme+"://"+u.Host)]
+	return ok
+}
+
+func (i *Introspector) serveWebSocket(w http.ResponseWriter, r *http.Request) {
+	conn, err := i.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Debugw("failed to upgrade connection", "error", err)
+		return
+	}
+	defer conn.Close()
+
+	// Read (and discard) messages, to process control frames and notice when the client goes away.
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(i.pushInterval)
+	defer ticker.Stop()
+	for {
+		conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+		if err := conn.WriteJSON(i.State()); err != nil {
+			log.Debugw("failed to push state", "error", err)
+			return
+		}
+		select {
+		case <-ticker.C:
+		case <-closed:
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
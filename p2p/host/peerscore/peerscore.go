@@ -0,0 +1,282 @@
+// Package peerscore implements a shared peer scoring framework.
+//
+// Services report feedback about peers to a Scorer, which keeps a decaying,
+// weighted score per peer and source. The total score of a peer is exposed to
+// the connection manager (see ConnMgrScore), to connection gating (see Gater)
+// and to applications (see Score and Scores).
+package peerscore
+
+import (
+	"errors"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/connmgr"
+	"github.com/libp2p/go-libp2p/core/event"
+	"github.com/libp2p/go-libp2p/core/peer"
+
+	"github.com/benbjohnson/clock"
+	logging "github.com/ipfs/go-log/v2"
+)
+
+var log = logging.Logger("peerscore")
+
+const (
+	defaultHalfLife   = 10 * time.Minute
+	defaultMaxScore   = 100
+	defaultGCInterval = time.Minute
+	// scores closer to 0 than this are dropped during GC
+	gcThreshold = 0.01
+)
+
+// Option is an option for the Scorer.
+type Option func(*Scorer) error
+
+// WithHalfLife sets the half-life of scores. Defaults to 10 minutes.
+func WithHalfLife(d time.Duration) Option {
+	return func(s *Scorer) error {
+		if d <= 0 {
+			return errors.New("half-life must be positive")
+		}
+		s.halfLife = d
+		return nil
+	}
+}
+
+// WithSourceWeight sets the weight of a source. The score a peer has for a
+// source is multiplied by the weight when computing its total score.
+// Sources default to a weight of 1.
+func WithSourceWeight(source string, weight float64) Option {
+	return func(s *Scorer) error {
+		s.weights[source] = weight
+		return nil
+	}
+}
+
+// WithMaxScore sets the maximum absolute score a peer can have for a single
+// source, before applying the weight. This prevents a single source from
+// dominating the total score. Defaults to 100.
+func WithMaxScore(max float64) Option {
+	return func(s *Scorer) error {
+		if max <= 0 {
+			return errors.New("max score must be positive")
+		}
+		s.maxScore = max
+		return nil
+	}
+}
+
+// WithClock sets the clock. Used for testing.
+func WithClock(cl clock.Clock) Option {
+	return func(s *Scorer) error {
+		s.clock = cl
+		return nil
+	}
+}
+
+type sourceScore struct {
+	value   float64
+	updated time.Time
+}
+
+// Scorer keeps track of the scores of peers. It implements connmgr.PeerScorer.
+type Scorer struct {
+	halfLife time.Duration
+	maxScore float64
+	weights  map[string]float64
+	clock    clock.Clock
+
+	mx     sync.Mutex
+	scores map[peer.ID]map[string]*sourceScore
+
+	closeOnce sync.Once
+	closing   chan struct{}
+	refCount  sync.WaitGroup
+}
+
+var _ connmgr.PeerScorer = &Scorer{}
+
+// New creates a new Scorer. Close must be called to release its resources.
+func New(opts ...Option) (*Scorer, error) {
+	s := &Scorer{
+		halfLife: defaultHalfLife,
+		maxScore: defaultMaxScore,
+		weights:  make(map[string]float64),
+		clock:    clock.New(),
+		scores:   make(map[peer.ID]map[string]*sourceScore),
+		closing:  make(chan struct{}),
+	}
+	for _, opt := range opts {
+		if err := opt(s); err != nil {
+			return nil, err
+		}
+	}
+	s.refCount.Add(1)
+	go s.background()
+	return s, nil
+}
+
+// Close stops the Scorer, including all event subscriptions started by Watch.
+func (s *Scorer) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.closing)
+		s.refCount.Wait()
+	})
+	return nil
+}
+
+// decayed returns the value of sc, decayed up to now.
+func (s *Scorer) decayed(sc *sourceScore, now time.Time) float64 {
+	elapsed := now.Sub(sc.updated)
+	if elapsed <= 0 {
+		return sc.value
+	}
+	return sc.value * math.Exp2(-float64(elapsed)/float64(s.halfLife))
+}
+
+func (s *Scorer) weight(source string) float64 {
+	if w, ok := s.weights[source]; ok {
+		return w
+	}
+	return 1
+}
+
+// Report adds delta to the score of p for the given source.
+func (s *Scorer) Report(p peer.ID, source string, delta float64) {
+	now := s.clock.Now()
+
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	sources, ok := s.scores[p]
+	if !ok {
+		sources = make(map[string]*sourceScore)
+		s.scores[p] = sources
+	}
+	sc, ok := sources[source]
+	if !ok {
+		sc = &sourceScore{updated: now}
+		sources[source] = sc
+	}
+	sc.value = math.Max(-s.maxScore, math.Min(s.maxScore, s.decayed(sc, now)+delta))
+	sc.updated = now
+}
+
+// Score returns the current total score of p, i.e. the weighted sum of its
+// scores for all sources.
+func (s *Scorer) Score(p peer.ID) float64 {
+	now := s.clock.Now()
+
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	var total float64
+	for source, sc := range s.scores[p] {
+		total += s.weight(source) * s.decayed(sc, now)
+	}
+	return total
+}
+
+// Scores returns the current (unweighted) scores of p, by source.
+func (s *Scorer) Scores(p peer.ID) map[string]float64 {
+	now := s.clock.Now()
+
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	out := make(map[string]float64, len(s.scores[p]))
+	for source, sc := range s.scores[p] {
+		out[source] = s.decayed(sc, now)
+	}
+	return out
+}
+
+// Forget drops all scores of p.
+func (s *Scorer) Forget(p peer.ID) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	delete(s.scores, p)
+}
+
+func (s *Scorer) background() {
+	defer s.refCount.Done()
+	ticker := s.clock.Ticker(defaultGCInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.gc()
+		case <-s.closing:
+			return
+		}
+	}
+}
+
+// gc drops the scores that decayed to (almost) 0.
+func (s *Scorer) gc() {
+	now := s.clock.Now()
+
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	for p, sources := range s.scores {
+		for source, sc := range sources {
+			if math.Abs(s.decayed(sc, now)) < gcThreshold {
+				delete(sources, source)
+			}
+		}
+		if len(sources) == 0 {
+			delete(s.scores, p)
+		}
+	}
+}
+
+// Watch subscribes to the events on bus that are relevant for scoring peers:
+//   - failed identifications lower the peer's score for identify by 1
+//   - identifications denied by the connection gater lower it by 10
+//
+// The subscription is closed when the returned stop function is called, or
+// when the Scorer is closed. Call stop once the bus is no longer used, e.g.
+// when its host is closed.
+func (s *Scorer) Watch(bus event.Bus) (stop func(), err error) {
+	sub, err := bus.Subscribe([]interface{}{
+		new(event.EvtPeerIdentificationFailed),
+		new(event.EvtPeerIdentificationDenied),
+	})
+	if err != nil {
+		return nil, err
+	}
+	stopCh := make(chan struct{})
+	done := make(chan struct{})
+	s.refCount.Add(1)
+	go func() {
+		defer s.refCount.Done()
+		defer close(done)
+		defer sub.Close()
+		for {
+			select {
+			case e, ok := <-sub.Out():
+				if !ok {
+					return
+				}
+				s.handleEvent(e)
+			case <-stopCh:
+				return
+			case <-s.closing:
+				return
+			}
+		}
+	}()
+	return sync.OnceFunc(func() {
+		close(stopCh)
+		<-done
+	}), nil
+}
+
+func (s *Scorer) handleEvent(e interface{}) {
+	switch evt := e.(type) {
+	case event.EvtPeerIdentificationFailed:
+		s.Report(evt.Peer, SourceIdentify, -1)
+	case event.EvtPeerIdentificationDenied:
+		s.Report(evt.Peer, SourceIdentify, -10)
+	default:
+		log.Debugf("unexpected event: %T", e)
+	}
+}
@@ -0,0 +1,166 @@
+package peerscore
+
+import (
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/event"
+	"github.com/libp2p/go-libp2p/core/test"
+	"github.com/libp2p/go-libp2p/p2p/host/eventbus"
+	rcmgr "github.com/libp2p/go-libp2p/p2p/host/resource-manager"
+	"github.com/libp2p/go-libp2p/p2p/net/connmgr"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/require"
+)
+
+func newScorer(t *testing.T, opts ...Option) (*Scorer, *clock.Mock) {
+	t.Helper()
+	cl := clock.NewMock()
+	s, err := New(append([]Option{WithClock(cl)}, opts...)...)
+	require.NoError(t, err)
+	t.Cleanup(func() { s.Close() })
+	return s, cl
+}
+
+func TestReportAndDecay(t *testing.T) {
+	s, cl := newScorer(t, WithHalfLife(time.Minute))
+	p := test.RandPeerIDFatal(t)
+
+	require.Zero(t, s.Score(p))
+	s.Report(p, "foo", -4)
+	s.Report(p, "bar", 2)
+	require.InDelta(t, -2, s.Score(p), 1e-9)
+	require.Equal(t, map[string]float64{"foo": -4, "bar": 2}, s.Scores(p))
+
+	cl.Add(time.Minute)
+	require.InDelta(t, -1, s.Score(p), 1e-9)
+	scores := s.Scores(p)
+	require.InDelta(t, -2, scores["foo"], 1e-9)
+	require.InDelta(t, 1, scores["bar"], 1e-9)
+
+	// reporting applies the decay first
+	s.Report(p, "foo", -2)
+	require.InDelta(t, -4, s.Scores(p)["foo"], 1e-9)
+
+	s.Forget(p)
+	require.Zero(t, s.Score(p))
+	require.Empty(t, s.Scores(p))
+}
+
+func TestSourceWeights(t *testing.T) {
+	s, _ := newScorer(t, WithSourceWeight("foo", 0.5), WithSourceWeight("bar", 3))
+	p := test.RandPeerIDFatal(t)
+
+	s.Report(p, "foo", -4)
+	s.Report(p, "bar", 1)
+	s.Report(p, "baz", 1)
+	require.InDelta(t, -2+3+1, s.Score(p), 1e-9)
+	// Scores isn't weighted
+	require.Equal(t, map[string]float64{"foo": -4, "bar": 1, "baz": 1}, s.Scores(p))
+}
+
+func TestMaxScore(t *testing.T) {
+	s, _ := newScorer(t, WithMaxScore(10), WithSourceWeight("foo", 2))
+	p := test.RandPeerIDFatal(t)
+
+	for i := 0; i < 20; i++ {
+		s.Report(p, "foo", -1)
+	}
+	require.Equal(t, -10.0, s.Scores(p)["foo"])
+	require.Equal(t, -20.0, s.Score(p))
+	s.Report(p, "foo", 100)
+	require.Equal(t, 10.0, s.Scores(p)["foo"])
+}
+
+func TestInvalidOptions(t *testing.T) {
+	_, err := New(WithHalfLife(0))
+	require.Error(t, err)
+	_, err = New(WithMaxScore(-1))
+	require.Error(t, err)
+}
+
+func TestGC(t *testing.T) {
+	s, cl := newScorer(t, WithHalfLife(time.Second))
+	p1 := test.RandPeerIDFatal(t)
+	p2 := test.RandPeerIDFatal(t)
+
+	s.Report(p1, "foo", -1)
+	cl.Add(time.Minute)
+	s.Report(p2, "foo", -1)
+	s.gc()
+
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	require.NotContains(t, s.scores, p1)
+	require.Contains(t, s.scores, p2)
+}
+
+func TestConnMgrScore(t *testing.T) {
+	s, _ := newScorer(t)
+	p := test.RandPeerIDFatal(t)
+	s.Report(p, "foo", -5)
+
+	score := s.ConnMgrScore(func(connmgr.PeerQuality) float64 { return 10 })
+	require.Equal(t, 5.0, score(connmgr.PeerQuality{ID: p}))
+	require.Equal(t, 10.0, score(connmgr.PeerQuality{ID: test.RandPeerIDFatal(t)}))
+}
+
+func TestGater(t *testing.T) {
+	s, _ := newScorer(t)
+	good := test.RandPeerIDFatal(t)
+	bad := test.RandPeerIDFatal(t)
+	s.Report(good, "foo", -1)
+	s.Report(bad, "foo", -10)
+
+	g := s.Gater(-5)
+	require.True(t, g.InterceptPeerDial(good))
+	require.True(t, g.InterceptAddrDial(good, nil))
+	require.True(t, g.InterceptPeerDial(test.RandPeerIDFatal(t)))
+	require.False(t, g.InterceptPeerDial(bad))
+	require.False(t, g.InterceptAddrDial(bad, nil))
+	require.False(t, g.InterceptSecured(0, bad, nil))
+}
+
+func TestResourceManagerReporter(t *testing.T) {
+	s, _ := newScorer(t)
+	p := test.RandPeerIDFatal(t)
+
+	r := s.ResourceManagerReporter()
+	r.ConsumeEvent(rcmgr.TraceEvt{Type: rcmgr.TraceBlockAddStreamEvt, Name: "peer:" + p.String()})
+	r.ConsumeEvent(rcmgr.TraceEvt{Type: rcmgr.TraceBlockReserveMemoryEvt, Name: "peer:" + p.String()})
+	// not blocked
+	r.ConsumeEvent(rcmgr.TraceEvt{Type: rcmgr.TraceAddStreamEvt, Name: "peer:" + p.String()})
+	// not a peer scope
+	r.ConsumeEvent(rcmgr.TraceEvt{Type: rcmgr.TraceBlockAddStreamEvt, Name: "system"})
+	require.Equal(t, map[string]float64{SourceResourceManager: -2}, s.Scores(p))
+}
+
+func TestWatch(t *testing.T) {
+	s, _ := newScorer(t)
+	bus := eventbus.NewBus()
+	stop, err := s.Watch(bus)
+	require.NoError(t, err)
+
+	failed, err := bus.Emitter(new(event.EvtPeerIdentificationFailed))
+	require.NoError(t, err)
+	defer failed.Close()
+	denied, err := bus.Emitter(new(event.EvtPeerIdentificationDenied))
+	require.NoError(t, err)
+	defer denied.Close()
+
+	p1 := test.RandPeerIDFatal(t)
+	p2 := test.RandPeerIDFatal(t)
+	require.NoError(t, failed.Emit(event.EvtPeerIdentificationFailed{Peer: p1}))
+	require.NoError(t, denied.Emit(event.EvtPeerIdentificationDenied{Peer: p2}))
+
+	require.Eventually(t, func() bool {
+		return s.Score(p1) == -1 && s.Score(p2) == -10
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, map[string]float64{SourceIdentify: -1}, s.Scores(p1))
+
+	// once stopped, events are ignored
+	stop()
+	require.NoError(t, failed.Emit(event.EvtPeerIdentificationFailed{Peer: p1}))
+	require.Equal(t, -1.0, s.Score(p1))
+}
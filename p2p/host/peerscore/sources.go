@@ -0,0 +1,81 @@
+package peerscore
+
+import (
+	coreconnmgr "github.com/libp2p/go-libp2p/core/connmgr"
+	"github.com/libp2p/go-libp2p/core/control"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	rcmgr "github.com/libp2p/go-libp2p/p2p/host/resource-manager"
+	"github.com/libp2p/go-libp2p/p2p/net/connmgr"
+	"github.com/libp2p/go-libp2p/p2p/protocol/identify"
+	"github.com/libp2p/go-libp2p/p2p/protocol/ping"
+
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+// The sources used by libp2p services.
+const (
+	SourceIdentify        = identify.ServiceName
+	SourcePing            = ping.ServiceName
+	SourceResourceManager = "libp2p.rcmgr"
+)
+
+// ConnMgrScore returns a connmgr.ScoreFunc that adds the score of a peer to
+// the score computed by base. Pass the result to connmgr.WithScoring, so that
+// badly behaving peers are trimmed first.
+func (s *Scorer) ConnMgrScore(base connmgr.ScoreFunc) connmgr.ScoreFunc {
+	return func(pq connmgr.PeerQuality) float64 {
+		return base(pq) + s.Score(pq.ID)
+	}
+}
+
+type gater struct {
+	s         *Scorer
+	threshold float64
+}
+
+// Gater returns a connection gater that rejects connections to and from peers
+// whose score is below threshold.
+func (s *Scorer) Gater(threshold float64) coreconnmgr.ConnectionGater {
+	return &gater{s: s, threshold: threshold}
+}
+
+func (g *gater) allowed(p peer.ID) bool {
+	return g.s.Score(p) >= g.threshold
+}
+
+func (g *gater) InterceptPeerDial(p peer.ID) bool                 { return g.allowed(p) }
+func (g *gater) InterceptAddrDial(p peer.ID, _ ma.Multiaddr) bool { return g.allowed(p) }
+func (g *gater) InterceptAccept(network.ConnMultiaddrs) bool      { return true }
+func (g *gater) InterceptSecured(_ network.Direction, p peer.ID, _ network.ConnMultiaddrs) bool {
+	return g.allowed(p)
+}
+func (g *gater) InterceptUpgraded(network.Conn) (bool, control.DisconnectReason) { return true, 0 }
+
+type rcmgrReporter struct {
+	s *Scorer
+}
+
+// ResourceManagerReporter returns a rcmgr.TraceReporter that lowers the score
+// of a peer by 1 every time the resource manager blocks a reservation in the
+// peer's scope. Pass it to rcmgr.WithTraceReporter.
+func (s *Scorer) ResourceManagerReporter() rcmgr.TraceReporter {
+	return &rcmgrReporter{s: s}
+}
+
+func (r *rcmgrReporter) ConsumeEvent(evt rcmgr.TraceEvt) {
+	switch evt.Type {
+	case rcmgr.TraceBlockReserveMemoryEvt, rcmgr.TraceBlockAddStreamEvt, rcmgr.TraceBlockAddConnEvt:
+	default:
+		return
+	}
+	ps := rcmgr.PeerStrInScopeName(evt.Name)
+	if ps == "" {
+		return
+	}
+	p, err := peer.Decode(ps)
+	if err != nil {
+		return
+	}
+	r.s.Report(p, SourceResourceManager, -1)
+}
@@ -6,6 +6,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/libp2p/go-libp2p/core/peer"
 	pstore "github.com/libp2p/go-libp2p/core/peerstore"
 	pt "github.com/libp2p/go-libp2p/p2p/host/peerstore/test"
 
@@ -67,6 +68,83 @@ func TestDsAddrBook(t *testing.T) {
 	}
 }
 
+func TestDsPeerMetadataTTL(t *testing.T) {
+	for name, dsFactory := range dstores {
+		t.Run(name, func(t *testing.T) {
+			opts := DefaultOpts()
+			clk := mockClock.NewMock()
+			opts.Clock = clk
+
+			pt.TestPeerMetadataTTL(t, func() (pstore.PeerMetadataWithTTL, func()) {
+				ps, closeFunc := peerstoreFactory(t, dsFactory, opts)()
+				return ps.(pstore.PeerMetadataWithTTL), closeFunc
+			}, clk)
+		})
+	}
+}
+
+func TestDsPeerMetadataGC(t *testing.T) {
+	store, closeStore := leveldbStore(t)
+	defer closeStore()
+	clk := mockClock.NewMock()
+	opts := DefaultOpts()
+	opts.GCPurgeInterval = 0
+	opts.Clock = clk
+	pm, err := NewPeerMetadata(context.Background(), store, opts)
+	require.NoError(t, err)
+	defer pm.Close()
+
+	require.NoError(t, pm.PutWithTTL("foo", "key", "v", time.Hour))
+	require.NoError(t, pm.PutWithTTL("bar", "key", "v", 3*time.Hour))
+	clk.Add(2 * time.Hour)
+	pm.gc()
+
+	for _, base := range []ds.Key{pmBase, pmExpiryBase} {
+		ok, err := store.Has(context.Background(), metadataKey(base, "foo", "key"))
+		require.NoError(t, err)
+		require.False(t, ok)
+		ok, err = store.Has(context.Background(), metadataKey(base, "bar", "key"))
+		require.NoError(t, err)
+		require.True(t, ok)
+	}
+}
+
+func TestDsPeerMetadataBackgroundGC(t *testing.T) {
+	store, closeStore := leveldbStore(t)
+	defer closeStore()
+	clk := mockClock.NewMock()
+	opts := DefaultOpts()
+	opts.Clock = clk
+	pm, err := NewPeerMetadata(context.Background(), store, opts)
+	require.NoError(t, err)
+	defer pm.Close()
+
+	// GC only starts once a value has been stored with a TTL
+	require.NoError(t, pm.Put("foo", "key", "v"))
+	pm.gcMx.Lock()
+	require.False(t, pm.gcStarted)
+	pm.gcMx.Unlock()
+
+	require.NoError(t, pm.PutWithTTL("foo", "key", "v", time.Hour))
+	require.NoError(t, pm.PutWithTTL("bar", "key", "v", time.Hour))
+	require.NoError(t, pm.PutWithTTL("bar", "other", "v", 100*opts.GCPurgeInterval))
+	pm.gcMx.Lock()
+	require.True(t, pm.gcStarted)
+	pm.gcMx.Unlock()
+
+	// the GC follows the injected clock
+	hasExpiry := func(p peer.ID, key string) bool {
+		ok, err := store.Has(context.Background(), metadataKey(pmExpiryBase, p, key))
+		require.NoError(t, err)
+		return ok
+	}
+	require.Eventually(t, func() bool {
+		clk.Add(opts.GCPurgeInterval)
+		return !hasExpiry("foo", "key") && !hasExpiry("bar", "key")
+	}, 5*time.Second, 10*time.Millisecond)
+	require.True(t, hasExpiry("bar", "other"))
+}
+
 func TestDsKeyBook(t *testing.T) {
 	for name, dsFactory := range dstores {
 		t.Run(name, func(t *testing.T) {
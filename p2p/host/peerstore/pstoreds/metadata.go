@@ -3,7 +3,11 @@ package pstoreds
 import (
 	"bytes"
 	"context"
+	"encoding/binary"
 	"encoding/gob"
+	"strings"
+	"sync"
+	"time"
 
 	pool "github.com/libp2p/go-buffer-pool"
 	"github.com/libp2p/go-libp2p/core/peer"
@@ -19,11 +23,29 @@ import (
 // /peers/metadata/<b32 peer id no padding>/<key>
 var pmBase = ds.NewKey("/peers/metadata")
 
+// The expiry of metadata stored with a TTL is stored as unix nanoseconds
+// under the following db key pattern:
+// /peers/metadata-ttl/<b32 peer id no padding>/<key>
+var pmExpiryBase = ds.NewKey("/peers/metadata-ttl")
+
 type dsPeerMetadata struct {
-	ds ds.Datastore
+	ds    ds.Datastore
+	clock clock
+
+	gcInitialDelay  time.Duration
+	gcPurgeInterval time.Duration
+
+	gcMx      sync.Mutex
+	gcStarted bool
+	closed    bool
+
+	ctx      context.Context
+	cancel   context.CancelFunc
+	refCount sync.WaitGroup
 }
 
 var _ pstore.PeerMetadata = (*dsPeerMetadata)(nil)
+var _ pstore.PeerMetadataWithTTL = (*dsPeerMetadata)(nil)
 
 func init() {
 	// Gob registers basic types by default.
@@ -37,13 +59,115 @@ func init() {
 // See `init()` to learn which types are registered by default. Modules wishing to store
 // values of other types will need to `gob.Register()` them explicitly, or else callers
 // will receive runtime errors.
-func NewPeerMetadata(_ context.Context, store ds.Datastore, _ Options) (*dsPeerMetadata, error) {
-	return &dsPeerMetadata{store}, nil
+//
+// Once a value has been stored with a TTL, expired metadata is garbage collected
+// every GCPurgeInterval, after GCInitialDelay. Close stops the garbage collection.
+func NewPeerMetadata(_ context.Context, store ds.Datastore, opts Options) (*dsPeerMetadata, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	pm := &dsPeerMetadata{
+		ds:              store,
+		clock:           realclock{},
+		gcInitialDelay:  opts.GCInitialDelay,
+		gcPurgeInterval: opts.GCPurgeInterval,
+		ctx:             ctx,
+		cancel:          cancel,
+	}
+	if opts.Clock != nil {
+		pm.clock = opts.Clock
+	}
+	return pm, nil
+}
+
+// maybeStartGC starts the garbage collection of expired metadata, unless it's
+// already running or disabled.
+func (pm *dsPeerMetadata) maybeStartGC() {
+	if pm.gcPurgeInterval <= 0 {
+		return
+	}
+	pm.gcMx.Lock()
+	defer pm.gcMx.Unlock()
+	if pm.gcStarted || pm.closed {
+		return
+	}
+	pm.gcStarted = true
+	pm.refCount.Add(1)
+	go pm.background()
+}
+
+func (pm *dsPeerMetadata) background() {
+	defer pm.refCount.Done()
+
+	delay := pm.gcInitialDelay
+	for {
+		select {
+		case <-pm.clock.After(delay):
+			pm.gc()
+		case <-pm.ctx.Done():
+			return
+		}
+		delay = pm.gcPurgeInterval
+	}
+}
+
+func (pm *dsPeerMetadata) Close() error {
+	pm.gcMx.Lock()
+	pm.closed = true
+	pm.gcMx.Unlock()
+	pm.cancel()
+	pm.refCount.Wait()
+	return nil
+}
+
+// gc removes expired metadata.
+func (pm *dsPeerMetadata) gc() {
+	results, err := pm.ds.Query(context.TODO(), query.Query{Prefix: pmExpiryBase.String()})
+	if err != nil {
+		log.Warnw("querying datastore for expired metadata failed", "error", err)
+		return
+	}
+	defer results.Close()
+
+	now := pm.clock.Now()
+	for entry := range results.Next() {
+		if entry.Error != nil {
+			log.Warnw("querying datastore for expired metadata failed", "error", entry.Error)
+			return
+		}
+		if !expired(entry.Value, now) {
+			continue
+		}
+		k := ds.NewKey(entry.Key)
+		pm.ds.Delete(context.TODO(), pmBase.Child(ds.NewKey(strings.TrimPrefix(k.String(), pmExpiryBase.String()))))
+		pm.ds.Delete(context.TODO(), k)
+	}
+}
+
+func metadataKey(base ds.Key, p peer.ID, key string) ds.Key {
+	return base.ChildString(base32.RawStdEncoding.EncodeToString([]byte(p))).ChildString(key)
+}
+
+// expired returns true if the encoded expiry b isn't after now.
+func expired(b []byte, now time.Time) bool {
+	if len(b) != 8 {
+		return true
+	}
+	return int64(binary.BigEndian.Uint64(b)) <= now.UnixNano()
 }
 
 func (pm *dsPeerMetadata) Get(p peer.ID, key string) (interface{}, error) {
-	k := pmBase.ChildString(base32.RawStdEncoding.EncodeToString([]byte(p))).ChildString(key)
-	value, err := pm.ds.Get(context.TODO(), k)
+	// we may be in between the expiration time and the GC interval
+	exp, err := pm.ds.Get(context.TODO(), metadataKey(pmExpiryBase, p, key))
+	switch err {
+	case nil:
+		if expired(exp, pm.clock.Now()) {
+			return nil, pstore.ErrNotFound
+		}
+	case ds.ErrNotFound:
+	default:
+		return nil, err
+	}
+
+	value, err := pm.ds.Get(context.TODO(), metadataKey(pmBase, p, key))
 	if err != nil {
 		if err == ds.ErrNotFound {
 			err = pstore.ErrNotFound
@@ -59,24 +183,53 @@ func (pm *dsPeerMetadata) Get(p peer.ID, key string) (interface{}, error) {
 }
 
 func (pm *dsPeerMetadata) Put(p peer.ID, key string, val interface{}) error {
-	k := pmBase.ChildString(base32.RawStdEncoding.EncodeToString([]byte(p))).ChildString(key)
+	if err := pm.put(p, key, val); err != nil {
+		return err
+	}
+	// remove the expiry, if the value was previously stored with a TTL
+	expKey := metadataKey(pmExpiryBase, p, key)
+	if has, err := pm.ds.Has(context.TODO(), expKey); err != nil || !has {
+		return err
+	}
+	return pm.ds.Delete(context.TODO(), expKey)
+}
+
+func (pm *dsPeerMetadata) PutWithTTL(p peer.ID, key string, val interface{}, ttl time.Duration) error {
+	if ttl <= 0 {
+		if err := pm.ds.Delete(context.TODO(), metadataKey(pmBase, p, key)); err != nil {
+			return err
+		}
+		return pm.ds.Delete(context.TODO(), metadataKey(pmExpiryBase, p, key))
+	}
+	if err := pm.put(p, key, val); err != nil {
+		return err
+	}
+	pm.maybeStartGC()
+	exp := make([]byte, 8)
+	binary.BigEndian.PutUint64(exp, uint64(pm.clock.Now().Add(ttl).UnixNano()))
+	return pm.ds.Put(context.TODO(), metadataKey(pmExpiryBase, p, key), exp)
+}
+
+func (pm *dsPeerMetadata) put(p peer.ID, key string, val interface{}) error {
 	var buf pool.Buffer
 	if err := gob.NewEncoder(&buf).Encode(&val); err != nil {
 		return err
 	}
-	return pm.ds.Put(context.TODO(), k, buf.Bytes())
+	return pm.ds.Put(context.TODO(), metadataKey(pmBase, p, key), buf.Bytes())
 }
 
 func (pm *dsPeerMetadata) RemovePeer(p peer.ID) {
-	result, err := pm.ds.Query(context.TODO(), query.Query{
-		Prefix:   pmBase.ChildString(base32.RawStdEncoding.EncodeToString([]byte(p))).String(),
-		KeysOnly: true,
-	})
-	if err != nil {
-		log.Warnw("querying datastore when removing peer failed", "peer", p, "error", err)
-		return
-	}
-	for entry := range result.Next() {
-		pm.ds.Delete(context.TODO(), ds.NewKey(entry.Key))
+	for _, base := range []ds.Key{pmBase, pmExpiryBase} {
+		result, err := pm.ds.Query(context.TODO(), query.Query{
+			Prefix:   base.ChildString(base32.RawStdEncoding.EncodeToString([]byte(p))).String(),
+			KeysOnly: true,
+		})
+		if err != nil {
+			log.Warnw("querying datastore when removing peer failed", "peer", p, "error", err)
+			return
+		}
+		for entry := range result.Next() {
+			pm.ds.Delete(context.TODO(), ds.NewKey(entry.Key))
+		}
 	}
 }
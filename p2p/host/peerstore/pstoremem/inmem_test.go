@@ -2,7 +2,9 @@ package pstoremem
 
 import (
 	"testing"
+	"time"
 
+	"github.com/libp2p/go-libp2p/core/peer"
 	pstore "github.com/libp2p/go-libp2p/core/peerstore"
 	pt "github.com/libp2p/go-libp2p/p2p/host/peerstore/test"
 
@@ -51,6 +53,40 @@ func TestInMemoryAddrBook(t *testing.T) {
 	}, clk)
 }
 
+func TestInMemoryPeerMetadataTTL(t *testing.T) {
+	clk := mockClock.NewMock()
+	pt.TestPeerMetadataTTL(t, func() (pstore.PeerMetadataWithTTL, func()) {
+		ps, err := NewPeerstore(WithClock(clk))
+		require.NoError(t, err)
+		return ps, func() { ps.Close() }
+	}, clk)
+}
+
+func TestInMemoryPeerMetadataGC(t *testing.T) {
+	clk := mockClock.NewMock()
+	pm := newPeerMetadata(clk)
+
+	require.NoError(t, pm.PutWithTTL("foo", "key", "v", time.Hour))
+	require.NoError(t, pm.PutWithTTL("bar", "key", "v", 3*time.Hour))
+	clk.Add(2 * time.Hour)
+	pm.gc()
+	require.NotContains(t, pm.ds, peer.ID("foo"))
+	require.NotContains(t, pm.expiry, peer.ID("foo"))
+	require.Contains(t, pm.ds, peer.ID("bar"))
+
+	// storing a value with a TTL collects the expired values, at most once
+	// per metadataGCInterval
+	clk.Add(2 * time.Hour)
+	require.NoError(t, pm.PutWithTTL("baz", "key", "v", time.Minute))
+	require.NotContains(t, pm.ds, peer.ID("bar"))
+	clk.Add(metadataGCInterval / 2)
+	require.NoError(t, pm.PutWithTTL("qux", "key", "v", time.Hour))
+	require.Contains(t, pm.ds, peer.ID("baz"))
+	clk.Add(metadataGCInterval / 2)
+	require.NoError(t, pm.PutWithTTL("qux", "key", "v", time.Hour))
+	require.NotContains(t, pm.ds, peer.ID("baz"))
+}
+
 func TestInMemoryKeyBook(t *testing.T) {
 	pt.TestKeyBook(t, func() (pstore.KeyBook, func()) {
 		ps, err := NewPeerstore()
@@ -2,35 +2,120 @@ package pstoremem
 
 import (
 	"sync"
+	"time"
 
 	"github.com/libp2p/go-libp2p/core/peer"
 	pstore "github.com/libp2p/go-libp2p/core/peerstore"
 )
 
+// metadataGCInterval is the minimum interval between two garbage collections
+// of expired metadata.
+const metadataGCInterval = 10 * time.Minute
+
 type memoryPeerMetadata struct {
 	// store other data, like versions
-	ds     map[peer.ID]map[string]interface{}
+	ds map[peer.ID]map[string]interface{}
+	// expiry of the values stored with a TTL
+	expiry map[peer.ID]map[string]time.Time
+	// nextGC is the earliest time at which expired values are garbage
+	// collected again
+	nextGC time.Time
 	dslock sync.RWMutex
+
+	clock clock
 }
 
 var _ pstore.PeerMetadata = (*memoryPeerMetadata)(nil)
+var _ pstore.PeerMetadataWithTTL = (*memoryPeerMetadata)(nil)
 
 func NewPeerMetadata() *memoryPeerMetadata {
+	return newPeerMetadata(realclock{})
+}
+
+func newPeerMetadata(clock clock) *memoryPeerMetadata {
 	return &memoryPeerMetadata{
-		ds: make(map[peer.ID]map[string]interface{}),
+		ds:     make(map[peer.ID]map[string]interface{}),
+		expiry: make(map[peer.ID]map[string]time.Time),
+		clock:  clock,
+	}
+}
+
+// gc removes expired values.
+func (ps *memoryPeerMetadata) gc() {
+	ps.dslock.Lock()
+	defer ps.dslock.Unlock()
+	ps.gcLocked(ps.clock.Now())
+}
+
+func (ps *memoryPeerMetadata) gcLocked(now time.Time) {
+	for p, exp := range ps.expiry {
+		for key, t := range exp {
+			if !t.After(now) {
+				ps.deleteLocked(p, key)
+			}
+		}
 	}
+	ps.nextGC = now.Add(metadataGCInterval)
 }
 
 func (ps *memoryPeerMetadata) Put(p peer.ID, key string, val interface{}) error {
 	ps.dslock.Lock()
 	defer ps.dslock.Unlock()
+	ps.putLocked(p, key, val)
+	if exp, ok := ps.expiry[p]; ok {
+		delete(exp, key)
+		if len(exp) == 0 {
+			delete(ps.expiry, p)
+		}
+	}
+	return nil
+}
+
+func (ps *memoryPeerMetadata) PutWithTTL(p peer.ID, key string, val interface{}, ttl time.Duration) error {
+	ps.dslock.Lock()
+	defer ps.dslock.Unlock()
+	if ttl <= 0 {
+		ps.deleteLocked(p, key)
+		return nil
+	}
+	// Expired values are garbage collected when new values with a TTL are
+	// stored, so that we don't need a background goroutine.
+	now := ps.clock.Now()
+	if !now.Before(ps.nextGC) {
+		ps.gcLocked(now)
+	}
+	ps.putLocked(p, key, val)
+	exp, ok := ps.expiry[p]
+	if !ok {
+		exp = make(map[string]time.Time)
+		ps.expiry[p] = exp
+	}
+	exp[key] = now.Add(ttl)
+	return nil
+}
+
+func (ps *memoryPeerMetadata) putLocked(p peer.ID, key string, val interface{}) {
 	m, ok := ps.ds[p]
 	if !ok {
 		m = make(map[string]interface{})
 		ps.ds[p] = m
 	}
 	m[key] = val
-	return nil
+}
+
+func (ps *memoryPeerMetadata) deleteLocked(p peer.ID, key string) {
+	if m, ok := ps.ds[p]; ok {
+		delete(m, key)
+		if len(m) == 0 {
+			delete(ps.ds, p)
+		}
+	}
+	if exp, ok := ps.expiry[p]; ok {
+		delete(exp, key)
+		if len(exp) == 0 {
+			delete(ps.expiry, p)
+		}
+	}
 }
 
 func (ps *memoryPeerMetadata) Get(p peer.ID, key string) (interface{}, error) {
@@ -44,11 +129,16 @@ func (ps *memoryPeerMetadata) Get(p peer.ID, key string) (interface{}, error) {
 	if !ok {
 		return nil, pstore.ErrNotFound
 	}
+	// we may be in between the expiration time and the GC interval
+	if t, ok := ps.expiry[p][key]; ok && !t.After(ps.clock.Now()) {
+		return nil, pstore.ErrNotFound
+	}
 	return val, nil
 }
 
 func (ps *memoryPeerMetadata) RemovePeer(p peer.ID) {
 	ps.dslock.Lock()
 	delete(ps.ds, p)
+	delete(ps.expiry, p)
 	ps.dslock.Unlock()
 }
@@ -53,7 +53,7 @@ func NewPeerstore(opts ...Option) (ps *pstoremem, err error) {
 		memoryKeyBook:      NewKeyBook(),
 		memoryAddrBook:     ab,
 		memoryProtoBook:    pb,
-		memoryPeerMetadata: NewPeerMetadata(),
+		memoryPeerMetadata: newPeerMetadata(ab.clock),
 	}, nil
 }
 
@@ -0,0 +1,59 @@
+package test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	pstore "github.com/libp2p/go-libp2p/core/peerstore"
+
+	mockClock "github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/require"
+)
+
+type PeerMetadataFactory func() (pstore.PeerMetadataWithTTL, func())
+
+// TestPeerMetadataTTL tests expiring metadata. clk must be the clock used by
+// the PeerMetadata created by factory.
+func TestPeerMetadataTTL(t *testing.T, factory PeerMetadataFactory, clk *mockClock.Mock) {
+	pm, closeFunc := factory()
+	if closeFunc != nil {
+		defer closeFunc()
+	}
+
+	p := peer.ID("foo")
+	key := pstore.MetadataKey("test", "key")
+	require.Equal(t, "test/key", key)
+
+	require.NoError(t, pm.PutWithTTL(p, key, "v1", time.Hour))
+	require.NoError(t, pm.Put(p, "permanent", "v1"))
+	v, err := pm.Get(p, key)
+	require.NoError(t, err)
+	require.Equal(t, "v1", v)
+
+	clk.Add(2 * time.Hour)
+	_, err = pm.Get(p, key)
+	require.ErrorIs(t, err, pstore.ErrNotFound)
+	v, err = pm.Get(p, "permanent")
+	require.NoError(t, err)
+	require.Equal(t, "v1", v)
+
+	// Put removes the TTL
+	require.NoError(t, pm.PutWithTTL(p, key, "v2", time.Hour))
+	require.NoError(t, pm.Put(p, key, "v3"))
+	clk.Add(2 * time.Hour)
+	v, err = pm.Get(p, key)
+	require.NoError(t, err)
+	require.Equal(t, "v3", v)
+
+	// a TTL of 0 removes the value
+	require.NoError(t, pm.PutWithTTL(p, key, "v4", 0))
+	_, err = pm.Get(p, key)
+	require.ErrorIs(t, err, pstore.ErrNotFound)
+
+	// removing the peer removes values with a TTL
+	require.NoError(t, pm.PutWithTTL(p, key, "v5", time.Hour))
+	pm.RemovePeer(p)
+	_, err = pm.Get(p, key)
+	require.ErrorIs(t, err, pstore.ErrNotFound)
+}
@@ -25,6 +25,9 @@ type Allowlist struct {
 
 	// Only the specified peers can use these IPs
 	allowedPeerByNetwork map[peer.ID][]*net.IPNet
+
+	// These peers are allowed, no matter which IP they use
+	allowedPeers map[peer.ID]struct{}
 }
 
 // WithAllowlistedMultiaddrs sets the multiaddrs to be in the allowlist
@@ -40,9 +43,20 @@ func WithAllowlistedMultiaddrs(mas []multiaddr.Multiaddr) Option {
 	}
 }
 
+// WithAllowlistedPeers sets the peers to be in the allowlist
+func WithAllowlistedPeers(peers []peer.ID) Option {
+	return func(rm *resourceManager) error {
+		for _, p := range peers {
+			rm.allowlist.AddPeer(p)
+		}
+		return nil
+	}
+}
+
 func newAllowlist() Allowlist {
 	return Allowlist{
 		allowedPeerByNetwork: make(map[peer.ID][]*net.IPNet),
+		allowedPeers:         make(map[peer.ID]struct{}),
 	}
 }
 
@@ -124,6 +138,40 @@ func (al *Allowlist) Add(ma multiaddr.Multiaddr) error {
 	return nil
 }
 
+// AddPeer adds a peer to the allowlist. Connections of this peer are allowed,
+// no matter which IP address they use. Since the peer ID of a connection is only
+// known after the handshake, a connection that is opened while the system or
+// transient scope is at its limit is only allowed if its IP address is allowlisted
+// as well, or if its multiaddr contains the peer ID (e.g. /ip4/1.2.3.4/tcp/1/p2p/QmFoo).
+// Once the peer ID is known, the connection is moved to the allowlisted scopes.
+func (al *Allowlist) AddPeer(p peer.ID) {
+	al.mu.Lock()
+	defer al.mu.Unlock()
+
+	if al.allowedPeers == nil {
+		al.allowedPeers = make(map[peer.ID]struct{})
+	}
+	al.allowedPeers[p] = struct{}{}
+}
+
+// RemovePeer removes a peer from the allowlist. Connections of the peer that are
+// already open keep using the allowlisted scopes.
+func (al *Allowlist) RemovePeer(p peer.ID) {
+	al.mu.Lock()
+	defer al.mu.Unlock()
+
+	delete(al.allowedPeers, p)
+}
+
+// AllowedPeer returns whether the peer was added to the allowlist using AddPeer.
+func (al *Allowlist) AllowedPeer(p peer.ID) bool {
+	al.mu.RLock()
+	defer al.mu.RUnlock()
+
+	_, ok := al.allowedPeers[p]
+	return ok
+}
+
 func (al *Allowlist) Remove(ma multiaddr.Multiaddr) error {
 	ipnet, allowedPeer, err := toIPNet(ma)
 	if err != nil {
@@ -172,6 +220,14 @@ func (al *Allowlist) Allowed(ma multiaddr.Multiaddr) bool {
 	al.mu.RLock()
 	defer al.mu.RUnlock()
 
+	if len(al.allowedPeers) > 0 {
+		if _, p := peer.SplitAddr(ma); p != "" {
+			if _, ok := al.allowedPeers[p]; ok {
+				return true
+			}
+		}
+	}
+
 	for _, network := range al.allowedNetworks {
 		if network.Contains(ip) {
 			return true
@@ -190,12 +246,18 @@ func (al *Allowlist) Allowed(ma multiaddr.Multiaddr) bool {
 }
 
 func (al *Allowlist) AllowedPeerAndMultiaddr(peerID peer.ID, ma multiaddr.Multiaddr) bool {
+	al.mu.RLock()
+	defer al.mu.RUnlock()
+
+	if _, ok := al.allowedPeers[peerID]; ok {
+		// This peer is allowed no matter which IP it uses
+		return true
+	}
+
 	ip, err := manet.ToIP(ma)
 	if err != nil {
 		return false
 	}
-	al.mu.RLock()
-	defer al.mu.RUnlock()
 
 	for _, network := range al.allowedNetworks {
 		if network.Contains(ip) {
@@ -215,6 +215,36 @@ func TestRemoved(t *testing.T) {
 	}
 }
 
+func TestAllowedPeer(t *testing.T) {
+	peerA := test.RandPeerIDFatal(t)
+	peerB := test.RandPeerIDFatal(t)
+	allowlist := newAllowlist()
+	allowlist.AddPeer(peerA)
+
+	maA := multiaddr.StringCast("/ip4/1.2.3.4/tcp/1234")
+	if !allowlist.AllowedPeer(peerA) {
+		t.Fatalf("peer should be allowed")
+	}
+	if !allowlist.AllowedPeerAndMultiaddr(peerA, maA) {
+		t.Fatalf("peer should be allowed from any addr")
+	}
+	if allowlist.AllowedPeerAndMultiaddr(peerB, maA) {
+		t.Fatalf("other peer should not be allowed")
+	}
+	// Without a peer ID in the multiaddr, the connection isn't allowlisted
+	if allowlist.Allowed(maA) {
+		t.Fatalf("addr should not be allowed")
+	}
+	if !allowlist.Allowed(maA.Encapsulate(multiaddr.StringCast("/p2p/" + peerA.String()))) {
+		t.Fatalf("addr with allowed peer should be allowed")
+	}
+
+	allowlist.RemovePeer(peerA)
+	if allowlist.AllowedPeer(peerA) || allowlist.AllowedPeerAndMultiaddr(peerA, maA) {
+		t.Fatalf("peer should not be allowed")
+	}
+}
+
 // BenchmarkAllowlistCheck benchmarks the allowlist with plausible conditions.
 func BenchmarkAllowlistCheck(b *testing.B) {
 	allowlist := newAllowlist()
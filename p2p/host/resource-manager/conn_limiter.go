@@ -140,6 +140,26 @@ func (cl *connLimiter) addNetworkPrefixLimit(isIP6 bool, npLimit NetworkPrefixLi
 	}
 }
 
+// updateNetworkPrefixLimit changes the connection limit of an existing network
+// prefix limit. It does nothing if there's no limit for the network prefix.
+func (cl *connLimiter) updateNetworkPrefixLimit(isIP6 bool, npLimit NetworkPrefixLimit) {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+	limits := &cl.networkPrefixLimitV4
+	if isIP6 {
+		limits = &cl.networkPrefixLimitV6
+	}
+	i := slices.IndexFunc(*limits, func(l NetworkPrefixLimit) bool { return l.Network == npLimit.Network })
+	if i < 0 {
+		return
+	}
+	// The limits may be shared with the defaults or with the caller of
+	// WithNetworkPrefixLimit, so copy them before changing them. The order
+	// stays the same, so the connection counts remain valid.
+	*limits = slices.Clone(*limits)
+	(*limits)[i].ConnCount = npLimit.ConnCount
+}
+
 // addConn adds a connection for the given IP address. It returns true if the connection is allowed.
 func (cl *connLimiter) addConn(ip netip.Addr) bool {
 	cl.mu.Lock()
@@ -2,9 +2,13 @@ package rcmgr
 
 import (
 	"bytes"
+	"fmt"
+	"io"
+	"slices"
 	"sort"
 	"strings"
 
+	"github.com/libp2p/go-libp2p/core/event"
 	"github.com/libp2p/go-libp2p/core/network"
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/libp2p/go-libp2p/core/protocol"
@@ -18,6 +22,58 @@ type ResourceScopeLimiter interface {
 
 var _ ResourceScopeLimiter = (*resourceScope)(nil)
 
+// ResourceManagerLimitUpdater is a trait interface that allows you to change
+// the limits of a running resource manager.
+type ResourceManagerLimitUpdater interface {
+	// UpdateLimits replaces the Limiter of the resource manager and applies the
+	// new limits to the system, transient, service, protocol and peer scopes.
+	//
+	// Reservations made under the old limits are kept, even if they exceed
+	// the new limits. In that case, new reservations in the affected scopes
+	// fail until enough resources have been released.
+	// Connection and stream scopes that are already open keep their limits.
+	// Protocol and peer scopes whose limit was set explicitly using SetLimit
+	// keep that limit.
+	// The connection limits of allowlisted network prefixes are updated to
+	// the new allowlisted system limits.
+	//
+	// Once the new limits have been applied, an EvtLimitsUpdated is emitted
+	// on the event buses added with ResourceManagerEventEmitter.
+	UpdateLimits(Limiter)
+}
+
+var _ ResourceManagerLimitUpdater = (*resourceManager)(nil)
+
+// UpdateLimits tries to update the limits of the given resourcemanager by
+// checking to see if its concrete type implements ResourceManagerLimitUpdater.
+func UpdateLimits(rcmgr network.ResourceManager, limits Limiter) error {
+	r, ok := rcmgr.(ResourceManagerLimitUpdater)
+	if !ok {
+		return fmt.Errorf("resource manager %T doesn't support updating limits", rcmgr)
+	}
+	r.UpdateLimits(limits)
+	return nil
+}
+
+// EvtLimitsUpdated is emitted after the limits of a resource manager have been
+// updated using UpdateLimits.
+type EvtLimitsUpdated struct {
+	// Limiter is the new Limiter of the resource manager.
+	Limiter Limiter
+}
+
+// ResourceManagerEventEmitter is a trait interface that allows you to receive
+// resource manager events, such as EvtLimitsUpdated, on an event bus.
+//
+// libp2p adds the event bus of the host automatically.
+type ResourceManagerEventEmitter interface {
+	// AddEventBus starts emitting resource manager events on the given bus.
+	// Closing the returned io.Closer stops emitting events on it.
+	AddEventBus(event.Bus) (io.Closer, error)
+}
+
+var _ ResourceManagerEventEmitter = (*resourceManager)(nil)
+
 // ResourceManagerStat is a trait that allows you to access resource manager state.
 type ResourceManagerState interface {
 	ListServices() []string
@@ -46,9 +102,10 @@ func (s *resourceScope) Limit() Limit {
 
 func (s *resourceScope) SetLimit(limit Limit) {
 	s.Lock()
-	defer s.Unlock()
-
 	s.rc.limit = limit
+	s.Unlock()
+
+	s.trace.UpdateLimit(s.name, limit)
 }
 
 func (s *protocolScope) SetLimit(limit Limit) {
@@ -61,6 +118,99 @@ func (s *peerScope) SetLimit(limit Limit) {
 	s.resourceScope.SetLimit(limit)
 }
 
+func (r *resourceManager) UpdateLimits(limits Limiter) {
+	r.limitsMx.Lock()
+	r.limits = limits
+	r.limitsMx.Unlock()
+
+	r.system.resourceScope.SetLimit(limits.GetSystemLimits())
+	r.transient.resourceScope.SetLimit(limits.GetTransientLimits())
+	r.allowlistedSystem.resourceScope.SetLimit(limits.GetAllowlistedSystemLimits())
+	r.allowlistedTransient.resourceScope.SetLimit(limits.GetAllowlistedTransientLimits())
+
+	r.mx.Lock()
+	svcs := make([]*serviceScope, 0, len(r.svc))
+	for _, svc := range r.svc {
+		svcs = append(svcs, svc)
+	}
+	protos := make([]*protocolScope, 0, len(r.proto))
+	for proto, s := range r.proto {
+		if _, sticky := r.stickyProto[proto]; !sticky {
+			protos = append(protos, s)
+		}
+	}
+	peers := make([]*peerScope, 0, len(r.peer))
+	for p, s := range r.peer {
+		if _, sticky := r.stickyPeer[p]; !sticky {
+			peers = append(peers, s)
+		}
+	}
+	r.mx.Unlock()
+
+	for _, s := range svcs {
+		s.resourceScope.SetLimit(limits.GetServiceLimits(s.service))
+		l := limits.GetServicePeerLimits(s.service)
+		s.Lock()
+		for _, ps := range s.peers {
+			ps.SetLimit(l)
+		}
+		s.Unlock()
+	}
+	for _, s := range protos {
+		s.resourceScope.SetLimit(limits.GetProtocolLimits(s.proto))
+		l := limits.GetProtocolPeerLimits(s.proto)
+		s.Lock()
+		for _, ps := range s.peers {
+			ps.SetLimit(l)
+		}
+		s.Unlock()
+	}
+	for _, s := range peers {
+		s.resourceScope.SetLimit(limits.GetPeerLimits(s.peer))
+	}
+
+	connCount := limits.GetAllowlistedSystemLimits().GetConnTotalLimit()
+	for _, prefix := range r.allowlistedPrefixes {
+		r.connLimiter.updateNetworkPrefixLimit(prefix.Addr().Is6(), NetworkPrefixLimit{
+			Network:   prefix,
+			ConnCount: connCount,
+		})
+	}
+
+	r.emittersMx.Lock()
+	emitters := slices.Clone(r.emitters)
+	r.emittersMx.Unlock()
+	for _, em := range emitters {
+		if err := em.Emit(EvtLimitsUpdated{Limiter: limits}); err != nil {
+			log.Debugf("failed to emit limits updated event: %s", err)
+		}
+	}
+}
+
+func (r *resourceManager) AddEventBus(bus event.Bus) (io.Closer, error) {
+	em, err := bus.Emitter(new(EvtLimitsUpdated))
+	if err != nil {
+		return nil, err
+	}
+	r.emittersMx.Lock()
+	r.emitters = append(r.emitters, em)
+	r.emittersMx.Unlock()
+	return &rcmgrEmitter{em: em, rcmgr: r}, nil
+}
+
+// rcmgrEmitter removes the emitter from the resource manager when closed.
+type rcmgrEmitter struct {
+	em    event.Emitter
+	rcmgr *resourceManager
+}
+
+func (e *rcmgrEmitter) Close() error {
+	e.rcmgr.emittersMx.Lock()
+	e.rcmgr.emitters = slices.DeleteFunc(e.rcmgr.emitters, func(em event.Emitter) bool { return em == e.em })
+	e.rcmgr.emittersMx.Unlock()
+	return e.em.Close()
+}
+
 func (r *resourceManager) ListServices() []string {
 	r.mx.Lock()
 	defer r.mx.Unlock()
@@ -147,5 +297,5 @@ func (r *resourceManager) Stat() (result ResourceManagerStat) {
 }
 
 func (r *resourceManager) GetConnLimit() int {
-	return r.limits.GetSystemLimits().GetConnTotalLimit()
+	return r.getLimits().GetSystemLimits().GetConnTotalLimit()
 }
@@ -0,0 +1,237 @@
+// Package otelreporter exports resource manager trace events to OpenTelemetry.
+//
+// The number of scopes, the resources currently reserved, reservations and
+// reservations blocked by a limit are exported as metrics, labeled with the
+// class of the scope (system, transient, peer, etc.) they were recorded in.
+// In addition, every blocked reservation is recorded as a short span, so that
+// resource exhaustion can be correlated with application traces.
+//
+// Use it by passing the reporter to the resource manager:
+//
+//	r, err := otelreporter.New()
+//	...
+//	mgr, err := rcmgr.NewResourceManager(limiter, rcmgr.WithTraceReporter(r))
+package otelreporter
+
+import (
+	"context"
+	"strings"
+
+	rcmgr "github.com/libp2p/go-libp2p/p2p/host/resource-manager"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/libp2p/go-libp2p/p2p/host/resource-manager"
+
+const (
+	scopeNameKey  = attribute.Key("libp2p.rcmgr.scope.name")
+	scopeClassKey = attribute.Key("libp2p.rcmgr.scope.class")
+	eventTypeKey  = attribute.Key("libp2p.rcmgr.event")
+	resourceKey   = attribute.Key("libp2p.rcmgr.resource")
+	directionKey  = attribute.Key("libp2p.rcmgr.direction")
+)
+
+type config struct {
+	tracerProvider trace.TracerProvider
+	meterProvider  metric.MeterProvider
+	scopeFilter    func(name string) bool
+}
+
+// Option is an option for the OpenTelemetry trace reporter.
+type Option func(*config)
+
+// WithTracerProvider sets the TracerProvider used to create spans.
+// Defaults to the global TracerProvider.
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(c *config) {
+		if tp != nil {
+			c.tracerProvider = tp
+		}
+	}
+}
+
+// WithMeterProvider sets the MeterProvider used to create metrics.
+// Defaults to the global MeterProvider.
+func WithMeterProvider(mp metric.MeterProvider) Option {
+	return func(c *config) {
+		if mp != nil {
+			c.meterProvider = mp
+		}
+	}
+}
+
+// WithScopeFilter sets a function that decides for which scopes blocked
+// reservations are recorded as spans, based on the name of the scope whose
+// limit blocked the reservation. Blocked reservations in scopes that are
+// filtered out are still counted in the metrics. By default, spans are
+// recorded for all scopes.
+func WithScopeFilter(f func(name string) bool) Option {
+	return func(c *config) {
+		c.scopeFilter = f
+	}
+}
+
+// TraceReporter is an rcmgr.TraceReporter that exports events to OpenTelemetry.
+type TraceReporter struct {
+	tracer      trace.Tracer
+	scopeFilter func(name string) bool
+
+	scopes       metric.Int64UpDownCounter
+	memory       metric.Int64UpDownCounter
+	streams      metric.Int64UpDownCounter
+	conns        metric.Int64UpDownCounter
+	fds          metric.Int64UpDownCounter
+	reservations metric.Int64Counter
+	blocked      metric.Int64Counter
+}
+
+var _ rcmgr.TraceReporter = (*TraceReporter)(nil)
+
+// New creates a new OpenTelemetry trace reporter.
+func New(opts ...Option) (*TraceReporter, error) {
+	cfg := &config{
+		tracerProvider: otel.GetTracerProvider(),
+		meterProvider:  otel.GetMeterProvider(),
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	r := &TraceReporter{
+		tracer:      cfg.tracerProvider.Tracer(instrumentationName),
+		scopeFilter: cfg.scopeFilter,
+	}
+	meter := cfg.meterProvider.Meter(instrumentationName)
+	var err error
+	if r.scopes, err = meter.Int64UpDownCounter("libp2p.rcmgr.scopes",
+		metric.WithDescription("Number of resource scopes")); err != nil {
+		return nil, err
+	}
+	if r.memory, err = meter.Int64UpDownCounter("libp2p.rcmgr.memory",
+		metric.WithDescription("Amount of memory reserved"), metric.WithUnit("By")); err != nil {
+		return nil, err
+	}
+	if r.streams, err = meter.Int64UpDownCounter("libp2p.rcmgr.streams",
+		metric.WithDescription("Number of streams")); err != nil {
+		return nil, err
+	}
+	if r.conns, err = meter.Int64UpDownCounter("libp2p.rcmgr.connections",
+		metric.WithDescription("Number of connections")); err != nil {
+		return nil, err
+	}
+	if r.fds, err = meter.Int64UpDownCounter("libp2p.rcmgr.fds",
+		metric.WithDescription("Number of file descriptors")); err != nil {
+		return nil, err
+	}
+	if r.reservations, err = meter.Int64Counter("libp2p.rcmgr.reservations",
+		metric.WithDescription("Number of resource reservations")); err != nil {
+		return nil, err
+	}
+	if r.blocked, err = meter.Int64Counter("libp2p.rcmgr.blocked",
+		metric.WithDescription("Number of resource reservations blocked by a limit")); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *TraceReporter) ConsumeEvent(evt rcmgr.TraceEvt) {
+	ctx := context.Background()
+	class := scopeClassKey.String(scopeClass(evt.Name))
+	switch evt.Type {
+	case rcmgr.TraceCreateScopeEvt:
+		r.scopes.Add(ctx, 1, metric.WithAttributes(class))
+	case rcmgr.TraceDestroyScopeEvt:
+		r.scopes.Add(ctx, -1, metric.WithAttributes(class))
+	case rcmgr.TraceReserveMemoryEvt, rcmgr.TraceReleaseMemoryEvt:
+		r.memory.Add(ctx, evt.Delta, metric.WithAttributes(class))
+		if evt.Type == rcmgr.TraceReserveMemoryEvt {
+			r.countReservation(ctx, class, "memory")
+		}
+	case rcmgr.TraceAddStreamEvt, rcmgr.TraceRemoveStreamEvt:
+		r.addDirectional(ctx, r.streams, class, evt.DeltaIn, evt.DeltaOut)
+		if evt.Type == rcmgr.TraceAddStreamEvt {
+			r.countReservation(ctx, class, "streams")
+		}
+	case rcmgr.TraceAddConnEvt, rcmgr.TraceRemoveConnEvt:
+		r.addDirectional(ctx, r.conns, class, evt.DeltaIn, evt.DeltaOut)
+		if evt.Delta != 0 {
+			r.fds.Add(ctx, evt.Delta, metric.WithAttributes(class))
+		}
+		if evt.Type == rcmgr.TraceAddConnEvt {
+			r.countReservation(ctx, class, "conns")
+		}
+	case rcmgr.TraceBlockReserveMemoryEvt:
+		r.limitExceeded(ctx, evt, class, "memory", attribute.Int64("delta", evt.Delta), attribute.Int64("memory", evt.Memory))
+	case rcmgr.TraceBlockAddStreamEvt:
+		r.limitExceeded(ctx, evt, class, "streams", attribute.Int("delta_in", evt.DeltaIn), attribute.Int("delta_out", evt.DeltaOut),
+			attribute.Int("streams_in", evt.StreamsIn), attribute.Int("streams_out", evt.StreamsOut))
+	case rcmgr.TraceBlockAddConnEvt:
+		r.limitExceeded(ctx, evt, class, "conns", attribute.Int("delta_in", evt.DeltaIn), attribute.Int("delta_out", evt.DeltaOut),
+			attribute.Int("conns_in", evt.ConnsIn), attribute.Int("conns_out", evt.ConnsOut), attribute.Int("fd", evt.FD))
+	}
+}
+
+func (r *TraceReporter) addDirectional(ctx context.Context, c metric.Int64UpDownCounter, class attribute.KeyValue, deltaIn, deltaOut int) {
+	if deltaIn != 0 {
+		c.Add(ctx, int64(deltaIn), metric.WithAttributes(class, directionKey.String("inbound")))
+	}
+	if deltaOut != 0 {
+		c.Add(ctx, int64(deltaOut), metric.WithAttributes(class, directionKey.String("outbound")))
+	}
+}
+
+func (r *TraceReporter) countReservation(ctx context.Context, class attribute.KeyValue, resource string) {
+	r.reservations.Add(ctx, 1, metric.WithAttributes(class, resourceKey.String(resource)))
+}
+
+// limitExceeded counts a blocked reservation and records it as a span that
+// ends right away. The span is started from a background context, as the
+// resource manager doesn't know the context of the reservation.
+func (r *TraceReporter) limitExceeded(ctx context.Context, evt rcmgr.TraceEvt, class attribute.KeyValue, resource string, attrs ...attribute.KeyValue) {
+	r.blocked.Add(ctx, 1, metric.WithAttributes(class, resourceKey.String(resource)))
+	if r.scopeFilter != nil && !r.scopeFilter(evt.Name) {
+		return
+	}
+	attrs = append(attrs, scopeNameKey.String(evt.Name), class, eventTypeKey.String(string(evt.Type)), resourceKey.String(resource))
+	_, span := r.tracer.Start(ctx, "rcmgr.limit_exceeded",
+		trace.WithSpanKind(trace.SpanKindInternal),
+		trace.WithAttributes(attrs...),
+	)
+	span.SetStatus(codes.Error, resource+" limit exceeded")
+	span.End()
+}
+
+// scopeClass returns the class of a scope, as used by the JSON trace output of
+// the resource manager.
+func scopeClass(name string) string {
+	if idx := strings.Index(name, ".span:"); idx > -1 {
+		name = name[:idx]
+	}
+	switch {
+	case name == "system", name == "transient", name == "allowlistedSystem", name == "allowlistedTransient":
+		return name
+	case strings.HasPrefix(name, "conn-"):
+		return "conn"
+	case strings.HasPrefix(name, "stream-"):
+		return "stream"
+	case strings.HasPrefix(name, "peer:"):
+		return "peer"
+	case strings.HasPrefix(name, "service:"):
+		if strings.Contains(name, "peer:") {
+			return "service-peer"
+		}
+		return "service"
+	case strings.HasPrefix(name, "protocol:"):
+		if strings.Contains(name, "peer:") {
+			return "protocol-peer"
+		}
+		return "protocol"
+	default:
+		return "unknown"
+	}
+}
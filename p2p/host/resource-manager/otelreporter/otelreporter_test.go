@@ -0,0 +1,213 @@
+package otelreporter
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	rcmgr "github.com/libp2p/go-libp2p/p2p/host/resource-manager"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	metricembedded "go.opentelemetry.io/otel/metric/embedded"
+	metricnoop "go.opentelemetry.io/otel/metric/noop"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/embedded"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+type recordingSpan struct {
+	noop.Span
+
+	name   string
+	status codes.Code
+	ended  bool
+}
+
+func (s *recordingSpan) SetStatus(code codes.Code, _ string) { s.status = code }
+func (s *recordingSpan) End(...trace.SpanEndOption)          { s.ended = true }
+
+type recordingTracer struct {
+	embedded.Tracer
+
+	mx    sync.Mutex
+	spans []*recordingSpan
+}
+
+func (t *recordingTracer) Start(ctx context.Context, _ string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	cfg := trace.NewSpanStartConfig(opts...)
+	s := &recordingSpan{}
+	for _, a := range cfg.Attributes() {
+		if a.Key == scopeNameKey {
+			s.name = a.Value.AsString()
+		}
+	}
+	t.mx.Lock()
+	t.spans = append(t.spans, s)
+	t.mx.Unlock()
+	return ctx, s
+}
+
+func (t *recordingTracer) getSpans() []*recordingSpan {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	return append([]*recordingSpan(nil), t.spans...)
+}
+
+type recordingTracerProvider struct {
+	embedded.TracerProvider
+	tracer *recordingTracer
+}
+
+func (p *recordingTracerProvider) Tracer(string, ...trace.TracerOption) trace.Tracer { return p.tracer }
+
+// recordingMeter sums up the values of all counters, by name and attributes.
+type recordingMeter struct {
+	metricnoop.Meter
+
+	mx     sync.Mutex
+	values map[string]map[attribute.Distinct]int64
+}
+
+func (m *recordingMeter) add(name string, v int64, opts []metric.AddOption) {
+	attrs := metric.NewAddConfig(opts).Attributes()
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	if m.values[name] == nil {
+		m.values[name] = make(map[attribute.Distinct]int64)
+	}
+	m.values[name][attrs.Equivalent()] += v
+}
+
+func (m *recordingMeter) value(name string, attrs ...attribute.KeyValue) int64 {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	set := attribute.NewSet(attrs...)
+	return m.values[name][set.Equivalent()]
+}
+
+func (m *recordingMeter) Int64Counter(name string, _ ...metric.Int64CounterOption) (metric.Int64Counter, error) {
+	return &recordingCounter{meter: m, name: name}, nil
+}
+
+func (m *recordingMeter) Int64UpDownCounter(name string, _ ...metric.Int64UpDownCounterOption) (metric.Int64UpDownCounter, error) {
+	return &recordingUpDownCounter{meter: m, name: name}, nil
+}
+
+type recordingCounter struct {
+	metricembedded.Int64Counter
+	meter *recordingMeter
+	name  string
+}
+
+func (c *recordingCounter) Add(_ context.Context, v int64, opts ...metric.AddOption) {
+	c.meter.add(c.name, v, opts)
+}
+
+type recordingUpDownCounter struct {
+	metricembedded.Int64UpDownCounter
+	meter *recordingMeter
+	name  string
+}
+
+func (c *recordingUpDownCounter) Add(_ context.Context, v int64, opts ...metric.AddOption) {
+	c.meter.add(c.name, v, opts)
+}
+
+type recordingMeterProvider struct {
+	metricembedded.MeterProvider
+	meter *recordingMeter
+}
+
+func (p *recordingMeterProvider) Meter(string, ...metric.MeterOption) metric.Meter { return p.meter }
+
+func TestTraceReporter(t *testing.T) {
+	tracer := &recordingTracer{}
+	meter := &recordingMeter{values: make(map[string]map[attribute.Distinct]int64)}
+	reporter, err := New(
+		WithTracerProvider(&recordingTracerProvider{tracer: tracer}),
+		WithMeterProvider(&recordingMeterProvider{meter: meter}),
+	)
+	require.NoError(t, err)
+
+	limits := rcmgr.PartialLimitConfig{
+		System: rcmgr.ResourceLimits{Memory: 1024},
+	}.Build(rcmgr.DefaultLimits.AutoScale())
+	mgr, err := rcmgr.NewResourceManager(rcmgr.NewFixedLimiter(limits), rcmgr.WithTraceReporter(reporter))
+	require.NoError(t, err)
+	defer mgr.Close()
+
+	system := scopeClassKey.String("system")
+	require.Equal(t, int64(1), meter.value("libp2p.rcmgr.scopes", system))
+
+	s, err := mgr.OpenStream(peer.ID("A"), network.DirInbound)
+	require.NoError(t, err)
+	require.NoError(t, s.ReserveMemory(512, network.ReservationPriorityAlways))
+	require.Error(t, s.ReserveMemory(1024, network.ReservationPriorityAlways))
+
+	require.Equal(t, int64(1), meter.value("libp2p.rcmgr.streams", system, directionKey.String("inbound")))
+	require.Equal(t, int64(512), meter.value("libp2p.rcmgr.memory", system))
+	require.Equal(t, int64(1), meter.value("libp2p.rcmgr.blocked", system, resourceKey.String("memory")))
+	require.Equal(t, int64(1), meter.value("libp2p.rcmgr.scopes", scopeClassKey.String("stream")))
+
+	// the blocked reservation is recorded as a span that has already ended
+	spans := tracer.getSpans()
+	require.Len(t, spans, 1)
+	require.Equal(t, "system", spans[0].name)
+	require.Equal(t, codes.Error, spans[0].status)
+	require.True(t, spans[0].ended)
+
+	s.Done()
+	require.Zero(t, meter.value("libp2p.rcmgr.streams", system, directionKey.String("inbound")))
+	require.Zero(t, meter.value("libp2p.rcmgr.memory", system))
+	require.Zero(t, meter.value("libp2p.rcmgr.scopes", scopeClassKey.String("stream")))
+	require.Equal(t, int64(1), meter.value("libp2p.rcmgr.reservations", system, resourceKey.String("memory")))
+}
+
+func TestTraceReporterScopeFilter(t *testing.T) {
+	tracer := &recordingTracer{}
+	meter := &recordingMeter{values: make(map[string]map[attribute.Distinct]int64)}
+	reporter, err := New(
+		WithTracerProvider(&recordingTracerProvider{tracer: tracer}),
+		WithMeterProvider(&recordingMeterProvider{meter: meter}),
+		WithScopeFilter(func(name string) bool { return name != "system" }),
+	)
+	require.NoError(t, err)
+
+	limits := rcmgr.PartialLimitConfig{
+		System: rcmgr.ResourceLimits{Memory: 1024},
+	}.Build(rcmgr.DefaultLimits.AutoScale())
+	mgr, err := rcmgr.NewResourceManager(rcmgr.NewFixedLimiter(limits), rcmgr.WithTraceReporter(reporter))
+	require.NoError(t, err)
+	defer mgr.Close()
+
+	s, err := mgr.OpenStream(peer.ID("A"), network.DirOutbound)
+	require.NoError(t, err)
+	defer s.Done()
+	require.Error(t, s.ReserveMemory(2048, network.ReservationPriorityAlways))
+
+	require.Empty(t, tracer.getSpans())
+	require.Equal(t, int64(1), meter.value("libp2p.rcmgr.blocked", scopeClassKey.String("system"), resourceKey.String("memory")))
+}
+
+func TestScopeClass(t *testing.T) {
+	for name, class := range map[string]string{
+		"system":                          "system",
+		"allowlistedTransient":            "allowlistedTransient",
+		"conn-3":                          "conn",
+		"stream-5.span:2":                 "stream",
+		"peer:QmFoo":                      "peer",
+		"service:foo":                     "service",
+		"service:foo.peer:QmFoo":          "service-peer",
+		"protocol:/foo/1.0.0":             "protocol",
+		"protocol:/foo/1.0.0.peer:QmFoo":  "protocol-peer",
+		"protocol:/foo/1.0.0.span:1":      "protocol",
+		"something-the-rcmgr-doesnt-have": "unknown",
+	} {
+		require.Equal(t, class, scopeClass(name), name)
+	}
+}
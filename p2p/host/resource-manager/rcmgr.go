@@ -8,6 +8,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/libp2p/go-libp2p/core/event"
 	"github.com/libp2p/go-libp2p/core/network"
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/libp2p/go-libp2p/core/protocol"
@@ -20,9 +21,16 @@ import (
 var log = logging.Logger("rcmgr")
 
 type resourceManager struct {
-	limits Limiter
+	limitsMx sync.RWMutex
+	limits   Limiter
 
 	connLimiter *connLimiter
+	// network prefixes from the allowlist that the connLimiter limits using
+	// the allowlisted system limits.
+	allowlistedPrefixes []netip.Prefix
+
+	emittersMx sync.Mutex
+	emitters   []event.Emitter
 
 	trace          *trace
 	metrics        *metrics
@@ -167,6 +175,7 @@ func NewResourceManager(limits Limiter, opts ...Option) (network.ResourceManager
 				Network:   prefix,
 				ConnCount: r.limits.GetAllowlistedSystemLimits().GetConnTotalLimit(),
 			})
+			r.allowlistedPrefixes = append(r.allowlistedPrefixes, prefix)
 		}
 	}
 
@@ -214,20 +223,35 @@ func NewResourceManager(limits Limiter, opts ...Option) (network.ResourceManager
 	return r, nil
 }
 
+func (r *resourceManager) getLimits() Limiter {
+	r.limitsMx.RLock()
+	defer r.limitsMx.RUnlock()
+	return r.limits
+}
+
+// ResourceManagerAllowlister is a trait interface that gives access to the
+// allowlist of a resource manager.
+type ResourceManagerAllowlister interface {
+	GetAllowlist() *Allowlist
+}
+
+var _ ResourceManagerAllowlister = (*resourceManager)(nil)
+
 func (r *resourceManager) GetAllowlist() *Allowlist {
 	return r.allowlist
 }
 
 // GetAllowlist tries to get the allowlist from the given resourcemanager
-// interface by checking to see if its concrete type is a resourceManager.
+// interface by checking to see if its concrete type implements
+// ResourceManagerAllowlister.
 // Returns nil if it fails to get the allowlist.
 func GetAllowlist(rcmgr network.ResourceManager) *Allowlist {
-	r, ok := rcmgr.(*resourceManager)
+	r, ok := rcmgr.(ResourceManagerAllowlister)
 	if !ok {
 		return nil
 	}
 
-	return r.allowlist
+	return r.GetAllowlist()
 }
 
 func (r *resourceManager) ViewSystem(f func(network.ResourceScope) error) error {
@@ -265,7 +289,7 @@ func (r *resourceManager) getServiceScope(svc string) *serviceScope {
 
 	s, ok := r.svc[svc]
 	if !ok {
-		s = newServiceScope(svc, r.limits.GetServiceLimits(svc), r)
+		s = newServiceScope(svc, r.getLimits().GetServiceLimits(svc), r)
 		r.svc[svc] = s
 	}
 
@@ -279,7 +303,7 @@ func (r *resourceManager) getProtocolScope(proto protocol.ID) *protocolScope {
 
 	s, ok := r.proto[proto]
 	if !ok {
-		s = newProtocolScope(proto, r.limits.GetProtocolLimits(proto), r)
+		s = newProtocolScope(proto, r.getLimits().GetProtocolLimits(proto), r)
 		r.proto[proto] = s
 	}
 
@@ -303,7 +327,7 @@ func (r *resourceManager) getPeerScope(p peer.ID) *peerScope {
 
 	s, ok := r.peer[p]
 	if !ok {
-		s = newPeerScope(p, r.limits.GetPeerLimits(p), r)
+		s = newPeerScope(p, r.getLimits().GetPeerLimits(p), r)
 		r.peer[p] = s
 	}
 
@@ -360,12 +384,24 @@ func (r *resourceManager) OpenConnection(dir network.Direction, usefd bool, endp
 func (r *resourceManager) openConnection(dir network.Direction, usefd bool, endpoint multiaddr.Multiaddr, ip netip.Addr) (network.ConnManagementScope, error) {
 	if ip.IsValid() {
 		if ok := r.connLimiter.addConn(ip); !ok {
-			return nil, fmt.Errorf("connections per ip limit exceeded for %s", endpoint)
+			if !r.allowlist.Allowed(endpoint) {
+				return nil, fmt.Errorf("connections per ip limit exceeded for %s", endpoint)
+			}
+			// Allowlisted connections are not subject to the per subnet limits,
+			// they are limited by the allowlisted scopes instead.
+			conn := newAllowListedConnectionScope(dir, usefd, r.getLimits().GetConnLimits(), r, endpoint)
+			if err := conn.AddConn(dir, usefd); err != nil {
+				conn.Done()
+				r.metrics.BlockConn(dir, usefd)
+				return nil, err
+			}
+			r.metrics.AllowConn(dir, usefd)
+			return conn, nil
 		}
 	}
 
 	var conn *connectionScope
-	conn = newConnectionScope(dir, usefd, r.limits.GetConnLimits(), r, endpoint, ip)
+	conn = newConnectionScope(dir, usefd, r.getLimits().GetConnLimits(), r, endpoint, ip)
 
 	err := conn.AddConn(dir, usefd)
 	if err != nil && ip.IsValid() {
@@ -374,7 +410,7 @@ func (r *resourceManager) openConnection(dir network.Direction, usefd bool, endp
 		allowed := r.allowlist.Allowed(endpoint)
 		if allowed {
 			conn.Done()
-			conn = newAllowListedConnectionScope(dir, usefd, r.limits.GetConnLimits(), r, endpoint)
+			conn = newAllowListedConnectionScope(dir, usefd, r.getLimits().GetConnLimits(), r, endpoint)
 			err = conn.AddConn(dir, usefd)
 		}
 	}
@@ -391,7 +427,7 @@ func (r *resourceManager) openConnection(dir network.Direction, usefd bool, endp
 
 func (r *resourceManager) OpenStream(p peer.ID, dir network.Direction) (network.StreamManagementScope, error) {
 	peer := r.getPeerScope(p)
-	stream := newStreamScope(dir, r.limits.GetStreamLimits(p), peer, r)
+	stream := newStreamScope(dir, r.getLimits().GetStreamLimits(p), peer, r)
 	peer.DecRef() // we have the reference in edges
 
 	err := stream.AddStream(dir)
@@ -641,7 +677,7 @@ func (s *serviceScope) getPeerScope(p peer.ID) *resourceScope {
 		return ps
 	}
 
-	l := s.rcmgr.limits.GetServicePeerLimits(s.service)
+	l := s.rcmgr.getLimits().GetServicePeerLimits(s.service)
 
 	if s.peers == nil {
 		s.peers = make(map[peer.ID]*resourceScope)
@@ -668,7 +704,7 @@ func (s *protocolScope) getPeerScope(p peer.ID) *resourceScope {
 		return ps
 	}
 
-	l := s.rcmgr.limits.GetProtocolPeerLimits(s.proto)
+	l := s.rcmgr.getLimits().GetProtocolPeerLimits(s.proto)
 
 	if s.peers == nil {
 		s.peers = make(map[peer.ID]*resourceScope)
@@ -709,6 +745,39 @@ func (s *connectionScope) Done() {
 	s.resourceScope.doneUnlocked()
 }
 
+// transferStandardToAllowed transfers this connection scope from the standard
+// set of scopes to the allowlisted set of scopes. Happens when a connection was
+// opened within the standard limits, but its peer turned out to be allowlisted.
+// If the allowlisted scopes can't accommodate the connection, it's left in the
+// standard scopes.
+func (s *connectionScope) transferStandardToAllowed() error {
+	systemScope := s.rcmgr.allowlistedSystem.resourceScope
+	transientScope := s.rcmgr.allowlistedTransient.resourceScope
+
+	stat := s.resourceScope.rc.stat()
+
+	if err := systemScope.ReserveForChild(stat); err != nil {
+		return err
+	}
+	if err := transientScope.ReserveForChild(stat); err != nil {
+		systemScope.ReleaseForChild(stat)
+		return err
+	}
+	systemScope.IncRef()
+	transientScope.IncRef()
+
+	for _, scope := range s.edges {
+		scope.ReleaseForChild(stat)
+		scope.DecRef() // removed from edges
+	}
+	s.edges = []*resourceScope{
+		transientScope,
+		systemScope,
+	}
+	s.isAllowlisted = true
+	return nil
+}
+
 // transferAllowedToStandard transfers this connection scope from being part of
 // the allowlist set of scopes to being part of the standard set of scopes.
 // Happens when we first allowlisted this connection due to its IP, but later
@@ -785,6 +854,16 @@ func (s *connectionScope) SetPeer(p peer.ID) error {
 			system = s.rcmgr.system
 			transient = s.rcmgr.transient
 		}
+	} else if s.rcmgr.allowlist.AllowedPeer(p) {
+		// This connection belongs to an allowlisted peer. Move it to the
+		// allowlisted scopes, so that it doesn't count towards the standard
+		// system and transient limits.
+		if err := s.transferStandardToAllowed(); err != nil {
+			log.Debugf("failed to move connection of allowlisted peer %s to the allowlisted scopes: %s", p, err)
+		} else {
+			system = s.rcmgr.allowlistedSystem
+			transient = s.rcmgr.allowlistedTransient
+		}
 	}
 
 	s.peer = s.rcmgr.getPeerScope(p)
@@ -3,11 +3,13 @@ package rcmgr
 import (
 	"net/netip"
 	"testing"
+	"time"
 
 	"github.com/libp2p/go-libp2p/core/network"
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/libp2p/go-libp2p/core/protocol"
 	"github.com/libp2p/go-libp2p/core/test"
+	"github.com/libp2p/go-libp2p/p2p/host/eventbus"
 	"github.com/stretchr/testify/require"
 
 	"github.com/multiformats/go-multiaddr"
@@ -1054,6 +1056,73 @@ func TestResourceManagerWithAllowlist(t *testing.T) {
 	}
 }
 
+// TestResourceManagerWithAllowlistedPeers checks that connections of allowlisted peers bypass the limits of the standard scopes.
+func TestResourceManagerWithAllowlistedPeers(t *testing.T) {
+	peerA := test.RandPeerIDFatal(t)
+	peerB := test.RandPeerIDFatal(t)
+
+	limits := DefaultLimits.AutoScale()
+	limits.system.Conns = 1
+	limits.system.ConnsInbound = 1
+	limits.system.ConnsOutbound = 1
+	limits.transient.Conns = 1
+	limits.transient.ConnsInbound = 1
+	limits.transient.ConnsOutbound = 1
+
+	rcmgr, err := NewResourceManager(NewFixedLimiter(limits), WithAllowlistedPeers([]peer.ID{peerA}))
+	require.NoError(t, err)
+	defer rcmgr.Close()
+
+	// The connection of an allowlisted peer is moved out of the standard scopes once the peer is known
+	connA, err := rcmgr.OpenConnection(network.DirInbound, true, multiaddr.StringCast("/ip4/1.2.3.4/tcp/1"))
+	require.NoError(t, err)
+	require.NoError(t, connA.SetPeer(peerA))
+	defer connA.Done()
+
+	connB, err := rcmgr.OpenConnection(network.DirInbound, true, multiaddr.StringCast("/ip4/1.2.3.5/tcp/1"))
+	require.NoError(t, err)
+	require.NoError(t, connB.SetPeer(peerB))
+	defer connB.Done()
+
+	// The standard scopes are now full
+	_, err = rcmgr.OpenConnection(network.DirInbound, true, multiaddr.StringCast("/ip4/1.2.3.6/tcp/1"))
+	require.Error(t, err)
+	// but a connection to an allowlisted peer can still be opened, if we know the peer ID
+	connA2, err := rcmgr.OpenConnection(network.DirOutbound, true, multiaddr.StringCast("/ip4/1.2.3.7/tcp/1/p2p/"+peerA.String()))
+	require.NoError(t, err)
+	require.NoError(t, connA2.SetPeer(peerA))
+	connA2.Done()
+
+	// Peers can be allowlisted at runtime
+	peerC := test.RandPeerIDFatal(t)
+	GetAllowlist(rcmgr).AddPeer(peerC)
+	connC, err := rcmgr.OpenConnection(network.DirOutbound, true, multiaddr.StringCast("/ip4/1.2.3.8/tcp/1/p2p/"+peerC.String()))
+	require.NoError(t, err)
+	require.NoError(t, connC.SetPeer(peerC))
+	connC.Done()
+}
+
+func TestResourceManagerAllowlistBypassesConnLimiter(t *testing.T) {
+	rcmgr, err := NewResourceManager(NewFixedLimiter(DefaultLimits.AutoScale()), WithLimitPerSubnet(
+		[]ConnLimitPerSubnet{{PrefixLength: 32, ConnCount: 1}}, nil,
+	))
+	require.NoError(t, err)
+	defer rcmgr.Close()
+
+	addr := multiaddr.StringCast("/ip4/1.2.3.4/tcp/1")
+	conn, err := rcmgr.OpenConnection(network.DirInbound, true, addr)
+	require.NoError(t, err)
+	defer conn.Done()
+	_, err = rcmgr.OpenConnection(network.DirInbound, true, addr)
+	require.Error(t, err)
+
+	// Networks added at runtime are exempt from the per subnet limits
+	require.NoError(t, GetAllowlist(rcmgr).Add(multiaddr.StringCast("/ip4/1.2.3.0/ipcidr/24")))
+	conn2, err := rcmgr.OpenConnection(network.DirInbound, true, addr)
+	require.NoError(t, err)
+	conn2.Done()
+}
+
 // TestAllowlistAndConnLimiterPlayNice checks that the connLimiter learns about network prefix limits from the allowlist.
 func TestAllowlistAndConnLimiterPlayNice(t *testing.T) {
 	limits := DefaultLimits.AutoScale()
@@ -1111,3 +1180,152 @@ func TestAllowlistAndConnLimiterPlayNice(t *testing.T) {
 		require.Equal(t, 1, rcmgr.(*resourceManager).connLimiter.networkPrefixLimitV4[0].ConnCount)
 	})
 }
+
+type mockTraceReporter struct {
+	events []TraceEvt
+}
+
+func (m *mockTraceReporter) ConsumeEvent(evt TraceEvt) {
+	m.events = append(m.events, evt)
+}
+
+func TestResourceManagerUpdateLimits(t *testing.T) {
+	limits := func(n int) Limiter {
+		l := BaseLimit{Memory: 4096, Streams: n, StreamsInbound: n, StreamsOutbound: n, Conns: n, ConnsInbound: n, ConnsOutbound: n, FD: n}
+		return NewFixedLimiter(ConcreteLimitConfig{
+			system:               l,
+			transient:            l,
+			allowlistedSystem:    l,
+			allowlistedTransient: l,
+			serviceDefault:       l,
+			servicePeerDefault:   l,
+			protocolDefault:      l,
+			protocolPeerDefault:  l,
+			peerDefault:          l,
+			conn:                 l,
+			stream:               l,
+		})
+	}
+
+	reporter := &mockTraceReporter{}
+	mgr, err := NewResourceManager(limits(2), WithTraceReporter(reporter))
+	require.NoError(t, err)
+	defer mgr.Close()
+
+	p := peer.ID("A")
+	s1, err := mgr.OpenStream(p, network.DirInbound)
+	require.NoError(t, err)
+	defer s1.Done()
+	s2, err := mgr.OpenStream(p, network.DirInbound)
+	require.NoError(t, err)
+
+	// lower the limits below the current usage
+	require.NoError(t, UpdateLimits(mgr, limits(1)))
+	require.Equal(t, 1, mgr.(*resourceManager).system.Limit().GetStreamTotalLimit())
+	mgr.ViewPeer(p, func(s network.PeerScope) error {
+		require.Equal(t, 1, s.(ResourceScopeLimiter).Limit().GetStreamTotalLimit())
+		// existing reservations are kept
+		require.Equal(t, 2, s.Stat().NumStreamsInbound)
+		return nil
+	})
+
+	// new streams are blocked until usage drops below the limit
+	_, err = mgr.OpenStream(p, network.DirInbound)
+	require.Error(t, err)
+	s2.Done()
+	_, err = mgr.OpenStream(p, network.DirInbound)
+	require.Error(t, err)
+
+	// raise the limits again
+	require.NoError(t, UpdateLimits(mgr, limits(3)))
+	s3, err := mgr.OpenStream(p, network.DirInbound)
+	require.NoError(t, err)
+	s3.Done()
+
+	var updates int
+	for _, evt := range reporter.events {
+		if evt.Type == TraceUpdateLimitEvt {
+			updates++
+		}
+	}
+	require.NotZero(t, updates)
+}
+
+func TestResourceManagerUpdateLimitsKeepsExplicitLimits(t *testing.T) {
+	mgr, err := NewResourceManager(NewFixedLimiter(DefaultLimits.AutoScale()))
+	require.NoError(t, err)
+	defer mgr.Close()
+
+	p := peer.ID("A")
+	explicit := &BaseLimit{Streams: 42}
+	mgr.ViewPeer(p, func(s network.PeerScope) error {
+		s.(ResourceScopeLimiter).SetLimit(explicit)
+		return nil
+	})
+
+	require.NoError(t, UpdateLimits(mgr, NewFixedLimiter(InfiniteLimits)))
+	mgr.ViewPeer(p, func(s network.PeerScope) error {
+		require.Equal(t, explicit, s.(ResourceScopeLimiter).Limit())
+		return nil
+	})
+}
+
+func TestResourceManagerUpdateLimitsEvent(t *testing.T) {
+	mgr, err := NewResourceManager(NewFixedLimiter(DefaultLimits.AutoScale()))
+	require.NoError(t, err)
+	defer mgr.Close()
+
+	bus := eventbus.NewBus()
+	sub, err := bus.Subscribe(new(EvtLimitsUpdated))
+	require.NoError(t, err)
+	defer sub.Close()
+	c, err := mgr.(ResourceManagerEventEmitter).AddEventBus(bus)
+	require.NoError(t, err)
+
+	limiter := NewFixedLimiter(InfiniteLimits)
+	require.NoError(t, UpdateLimits(mgr, limiter))
+	select {
+	case e := <-sub.Out():
+		require.Equal(t, limiter, e.(EvtLimitsUpdated).Limiter)
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected a limits updated event")
+	}
+
+	// no more events once the bus has been removed
+	require.NoError(t, c.Close())
+	require.NoError(t, UpdateLimits(mgr, limiter))
+	select {
+	case <-sub.Out():
+		t.Fatal("didn't expect an event")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestResourceManagerUpdateLimitsAllowlistedPrefixes(t *testing.T) {
+	limits := DefaultLimits.AutoScale()
+	limits.allowlistedSystem.Conns = 8
+	mgr, err := NewResourceManager(NewFixedLimiter(limits), WithAllowlistedMultiaddrs([]multiaddr.Multiaddr{
+		multiaddr.StringCast("/ip4/1.2.3.0/ipcidr/24"),
+	}))
+	require.NoError(t, err)
+	defer mgr.Close()
+
+	connLimiter := mgr.(*resourceManager).connLimiter
+	prefixLimit := func(prefix netip.Prefix) int {
+		for _, l := range connLimiter.networkPrefixLimitV4 {
+			if l.Network == prefix {
+				return l.ConnCount
+			}
+		}
+		return 0
+	}
+	allowlisted := netip.MustParsePrefix("1.2.3.0/24")
+	loopback := netip.MustParsePrefix("127.0.0.0/8")
+	require.Equal(t, 8, prefixLimit(allowlisted))
+
+	limits.allowlistedSystem.Conns = 16
+	require.NoError(t, UpdateLimits(mgr, NewFixedLimiter(limits)))
+	require.Equal(t, 16, prefixLimit(allowlisted))
+	// limits that weren't derived from the allowlist are left untouched
+	require.Equal(t, DefaultNetworkPrefixLimitV4[0].ConnCount, prefixLimit(loopback))
+}
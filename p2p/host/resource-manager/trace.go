@@ -69,6 +69,7 @@ const (
 	TraceAddConnEvt            TraceEvtTyp = "add_conn"
 	TraceBlockAddConnEvt       TraceEvtTyp = "block_add_conn"
 	TraceRemoveConnEvt         TraceEvtTyp = "remove_conn"
+	TraceUpdateLimitEvt        TraceEvtTyp = "update_limit"
 )
 
 type scopeClass struct {
@@ -366,6 +367,18 @@ func (t *trace) CreateScope(scope string, limit Limit) {
 	})
 }
 
+func (t *trace) UpdateLimit(scope string, limit Limit) {
+	if t == nil {
+		return
+	}
+
+	t.push(TraceEvt{
+		Type:  TraceUpdateLimitEvt,
+		Name:  scope,
+		Limit: limit,
+	})
+}
+
 func (t *trace) DestroyScope(scope string) {
 	if t == nil {
 		return
@@ -0,0 +1,246 @@
+package rcmgr
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+)
+
+// ScopeUsage is a snapshot of the resources reserved in a scope, together with
+// the limit currently applied to that scope.
+type ScopeUsage struct {
+	network.ScopeStat
+	Limit BaseLimit
+}
+
+// PeersUsage is the usage of peer scopes, by peer.
+type PeersUsage map[peer.ID]ScopeUsage
+
+// MarshalJSON encodes the peer IDs as strings. encoding/json uses the raw bytes
+// of map keys of a string type, even if they implement encoding.TextMarshaler.
+func (u PeersUsage) MarshalJSON() ([]byte, error) {
+	m := make(map[string]ScopeUsage, len(u))
+	for p, su := range u {
+		m[p.String()] = su
+	}
+	return json.Marshal(m)
+}
+
+// ServiceUsage is the usage of a service scope, broken down by peer.
+type ServiceUsage struct {
+	ScopeUsage
+	Peers PeersUsage `json:",omitempty"`
+}
+
+// ProtocolUsage is the usage of a protocol scope, broken down by peer.
+type ProtocolUsage struct {
+	ScopeUsage
+	Peers PeersUsage `json:",omitempty"`
+}
+
+// ResourceManagerUsage is a snapshot of the usage of all scopes known to the
+// resource manager.
+type ResourceManagerUsage struct {
+	System               ScopeUsage
+	Transient            ScopeUsage
+	AllowlistedSystem    ScopeUsage
+	AllowlistedTransient ScopeUsage
+	Services             map[string]ServiceUsage
+	Protocols            map[protocol.ID]ProtocolUsage
+	Peers                PeersUsage
+}
+
+// ResourceManagerUsageReporter is a trait interface that allows you to query the
+// current usage of the resource manager, per scope.
+// Unlike ResourceManagerState, it reports the limits alongside the usage, as well
+// as the per-peer sub-scopes of services and protocols.
+type ResourceManagerUsageReporter interface {
+	// Usage returns the usage of all scopes.
+	Usage() ResourceManagerUsage
+	// ServiceUsage returns the usage of the given service.
+	// It returns false if the resource manager has no scope for the service.
+	ServiceUsage(svc string) (ServiceUsage, bool)
+	// ProtocolUsage returns the usage of the given protocol.
+	// It returns false if the resource manager has no scope for the protocol.
+	ProtocolUsage(proto protocol.ID) (ProtocolUsage, bool)
+	// PeerUsage returns the usage of the given peer.
+	// It returns false if the resource manager has no scope for the peer.
+	PeerUsage(p peer.ID) (ScopeUsage, bool)
+}
+
+var _ ResourceManagerUsageReporter = (*resourceManager)(nil)
+
+func toBaseLimit(l Limit) BaseLimit {
+	if bl, ok := l.(*BaseLimit); ok {
+		return *bl
+	}
+	if bl, ok := l.(BaseLimit); ok {
+		return bl
+	}
+	return BaseLimit{
+		Streams:         l.GetStreamTotalLimit(),
+		StreamsInbound:  l.GetStreamLimit(network.DirInbound),
+		StreamsOutbound: l.GetStreamLimit(network.DirOutbound),
+		Conns:           l.GetConnTotalLimit(),
+		ConnsInbound:    l.GetConnLimit(network.DirInbound),
+		ConnsOutbound:   l.GetConnLimit(network.DirOutbound),
+		FD:              l.GetFDLimit(),
+		Memory:          l.GetMemoryLimit(),
+	}
+}
+
+func (s *resourceScope) usage() ScopeUsage {
+	s.Lock()
+	defer s.Unlock()
+
+	return ScopeUsage{ScopeStat: s.rc.stat(), Limit: toBaseLimit(s.rc.limit)}
+}
+
+// peerUsage returns the usage of the per-peer sub-scopes of a service or protocol
+// scope. The caller must hold the lock of the parent scope.
+func peerUsage(peers map[peer.ID]*resourceScope) PeersUsage {
+	if len(peers) == 0 {
+		return nil
+	}
+	result := make(PeersUsage, len(peers))
+	for p, s := range peers {
+		result[p] = s.usage()
+	}
+	return result
+}
+
+func (s *serviceScope) usage() ServiceUsage {
+	u := ServiceUsage{ScopeUsage: s.resourceScope.usage()}
+	s.Lock()
+	u.Peers = peerUsage(s.peers)
+	s.Unlock()
+	return u
+}
+
+func (s *protocolScope) usage() ProtocolUsage {
+	u := ProtocolUsage{ScopeUsage: s.resourceScope.usage()}
+	s.Lock()
+	u.Peers = peerUsage(s.peers)
+	s.Unlock()
+	return u
+}
+
+func (r *resourceManager) Usage() (result ResourceManagerUsage) {
+	r.mx.Lock()
+	svcs := make([]*serviceScope, 0, len(r.svc))
+	for _, svc := range r.svc {
+		svcs = append(svcs, svc)
+	}
+	protos := make([]*protocolScope, 0, len(r.proto))
+	for _, proto := range r.proto {
+		protos = append(protos, proto)
+	}
+	peers := make([]*peerScope, 0, len(r.peer))
+	for _, peer := range r.peer {
+		peers = append(peers, peer)
+	}
+	r.mx.Unlock()
+
+	// As in Stat, the system scope is read last so that it's the most up-to-date snapshot.
+	result.Peers = make(PeersUsage, len(peers))
+	for _, peer := range peers {
+		result.Peers[peer.peer] = peer.resourceScope.usage()
+	}
+	result.Protocols = make(map[protocol.ID]ProtocolUsage, len(protos))
+	for _, proto := range protos {
+		result.Protocols[proto.proto] = proto.usage()
+	}
+	result.Services = make(map[string]ServiceUsage, len(svcs))
+	for _, svc := range svcs {
+		result.Services[svc.service] = svc.usage()
+	}
+	result.AllowlistedTransient = r.allowlistedTransient.resourceScope.usage()
+	result.AllowlistedSystem = r.allowlistedSystem.resourceScope.usage()
+	result.Transient = r.transient.resourceScope.usage()
+	result.System = r.system.resourceScope.usage()
+
+	return result
+}
+
+func (r *resourceManager) ServiceUsage(svc string) (ServiceUsage, bool) {
+	r.mx.Lock()
+	s, ok := r.svc[svc]
+	r.mx.Unlock()
+	if !ok {
+		return ServiceUsage{}, false
+	}
+	return s.usage(), true
+}
+
+func (r *resourceManager) ProtocolUsage(proto protocol.ID) (ProtocolUsage, bool) {
+	r.mx.Lock()
+	s, ok := r.proto[proto]
+	r.mx.Unlock()
+	if !ok {
+		return ProtocolUsage{}, false
+	}
+	return s.usage(), true
+}
+
+func (r *resourceManager) PeerUsage(p peer.ID) (ScopeUsage, bool) {
+	r.mx.Lock()
+	s, ok := r.peer[p]
+	r.mx.Unlock()
+	if !ok {
+		return ScopeUsage{}, false
+	}
+	return s.resourceScope.usage(), true
+}
+
+// NewUsageHandler returns an http.Handler that serves the usage of the resource
+// manager as JSON. The usage of a single scope can be requested using one of the
+// "service", "protocol" or "peer" query parameters; without any of them, the usage
+// of all scopes is returned.
+func NewUsageHandler(rcmgr network.ResourceManager) (http.Handler, error) {
+	r, ok := rcmgr.(ResourceManagerUsageReporter)
+	if !ok {
+		return nil, fmt.Errorf("resource manager %T doesn't support usage reporting", rcmgr)
+	}
+	return &usageHandler{r: r}, nil
+}
+
+type usageHandler struct {
+	r ResourceManagerUsageReporter
+}
+
+func (h *usageHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	q := req.URL.Query()
+
+	var (
+		result interface{}
+		found  = true
+	)
+	switch {
+	case q.Has("service"):
+		result, found = h.r.ServiceUsage(q.Get("service"))
+	case q.Has("protocol"):
+		result, found = h.r.ProtocolUsage(protocol.ID(q.Get("protocol")))
+	case q.Has("peer"):
+		p, err := peer.Decode(q.Get("peer"))
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid peer ID: %s", err), http.StatusBadRequest)
+			return
+		}
+		result, found = h.r.PeerUsage(p)
+	default:
+		result = h.r.Usage()
+	}
+	if !found {
+		http.Error(w, "scope not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		log.Debugf("failed to write usage response: %s", err)
+	}
+}
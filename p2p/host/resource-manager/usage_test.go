@@ -0,0 +1,92 @@
+package rcmgr
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/test"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResourceManagerUsage(t *testing.T) {
+	mgr, err := NewResourceManager(NewFixedLimiter(DefaultLimits.AutoScale()))
+	require.NoError(t, err)
+	defer mgr.Close()
+
+	p := test.RandPeerIDFatal(t)
+	s, err := mgr.OpenStream(p, network.DirInbound)
+	require.NoError(t, err)
+	defer s.Done()
+	require.NoError(t, s.SetProtocol("/test"))
+	require.NoError(t, s.SetService("test"))
+	require.NoError(t, s.ReserveMemory(1024, network.ReservationPriorityAlways))
+
+	usage := mgr.(ResourceManagerUsageReporter).Usage()
+	require.Equal(t, 1, usage.System.NumStreamsInbound)
+	require.Equal(t, int64(1024), usage.System.Memory)
+	require.Equal(t, DefaultLimits.AutoScale().system.Memory, usage.System.Limit.Memory)
+	require.Contains(t, usage.Peers, p)
+
+	svc, ok := mgr.(ResourceManagerUsageReporter).ServiceUsage("test")
+	require.True(t, ok)
+	require.Equal(t, int64(1024), svc.Memory)
+	require.Equal(t, 1, svc.NumStreamsInbound)
+	require.Equal(t, int64(1024), svc.Peers[p].Memory)
+	require.Equal(t, svc, usage.Services["test"])
+
+	proto, ok := mgr.(ResourceManagerUsageReporter).ProtocolUsage("/test")
+	require.True(t, ok)
+	require.Equal(t, 1, proto.Peers[p].NumStreamsInbound)
+
+	_, ok = mgr.(ResourceManagerUsageReporter).ServiceUsage("unknown")
+	require.False(t, ok)
+}
+
+func TestResourceManagerUsageHandler(t *testing.T) {
+	mgr, err := NewResourceManager(NewFixedLimiter(DefaultLimits.AutoScale()))
+	require.NoError(t, err)
+	defer mgr.Close()
+
+	p := test.RandPeerIDFatal(t)
+	s, err := mgr.OpenStream(p, network.DirOutbound)
+	require.NoError(t, err)
+	defer s.Done()
+	require.NoError(t, s.SetProtocol("/test"))
+	require.NoError(t, s.SetService("test"))
+
+	h, err := NewUsageHandler(mgr)
+	require.NoError(t, err)
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	get := func(query string) *http.Response {
+		t.Helper()
+		resp, err := http.Get(srv.URL + query)
+		require.NoError(t, err)
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+
+	resp := get("")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var usage ResourceManagerUsage
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&usage))
+	require.Equal(t, 1, usage.System.NumStreamsOutbound)
+	require.Equal(t, 1, usage.Peers[p].NumStreamsOutbound)
+
+	resp = get("?service=test")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var svc ServiceUsage
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&svc))
+	require.Equal(t, 1, svc.NumStreamsOutbound)
+	require.Equal(t, 1, svc.Peers[p].NumStreamsOutbound)
+
+	require.Equal(t, http.StatusNotFound, get("?service=unknown").StatusCode)
+	require.Equal(t, http.StatusBadRequest, get("?peer=foo").StatusCode)
+
+	_, err = NewUsageHandler(&network.NullResourceManager{})
+	require.Error(t, err)
+}
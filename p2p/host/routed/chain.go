@@ -0,0 +1,224 @@
+package routedhost
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/peerstore"
+	"github.com/libp2p/go-libp2p/core/routing"
+
+	"github.com/benbjohnson/clock"
+)
+
+const (
+	// DefaultCacheTTL is the default time results found by a Chain are cached for.
+	DefaultCacheTTL = time.Minute
+	// DefaultMaxCacheEntries is the default maximum number of cached results.
+	DefaultMaxCacheEntries = 1024
+)
+
+type stage struct {
+	name    string
+	routing Routing
+	timeout time.Duration
+}
+
+type cacheEntry struct {
+	info    peer.AddrInfo
+	expires time.Time
+}
+
+// Chain is a Routing that queries a list of routers in order, falling back
+// to the next one if a router fails to find the peer or times out.
+// Successful results are cached.
+//
+// A typical chain consults mDNS first, then the DHT and finally a delegated
+// HTTP router:
+//
+//	r, err := NewChain(
+//		WithStage("mdns", mdnsRouter, time.Second),
+//		WithStage("dht", dht, 10*time.Second),
+//		WithStage("http", delegated, 5*time.Second),
+//	)
+type Chain struct {
+	stages     []stage
+	cacheTTL   time.Duration
+	maxEntries int
+	clock      clock.Clock
+
+	mx    sync.Mutex
+	cache map[peer.ID]cacheEntry
+}
+
+var _ Routing = (*Chain)(nil)
+
+// ChainOption is an option for NewChain.
+type ChainOption func(*Chain) error
+
+// WithStage appends a router to the chain. If timeout is positive, a query to
+// this router is aborted after timeout and the next router is tried.
+func WithStage(name string, r Routing, timeout time.Duration) ChainOption {
+	return func(c *Chain) error {
+		if r == nil {
+			return fmt.Errorf("router for stage %s is nil", name)
+		}
+		c.stages = append(c.stages, stage{name: name, routing: r, timeout: timeout})
+		return nil
+	}
+}
+
+// WithCacheTTL sets the time results are cached for. A TTL of 0 disables caching.
+func WithCacheTTL(ttl time.Duration) ChainOption {
+	return func(c *Chain) error {
+		if ttl < 0 {
+			return errors.New("cache TTL must not be negative")
+		}
+		c.cacheTTL = ttl
+		return nil
+	}
+}
+
+// WithMaxCacheEntries sets the maximum number of results that are cached.
+func WithMaxCacheEntries(n int) ChainOption {
+	return func(c *Chain) error {
+		if n <= 0 {
+			return errors.New("max cache entries must be positive")
+		}
+		c.maxEntries = n
+		return nil
+	}
+}
+
+// WithChainClock sets the clock used for the cache expiry. Used for testing.
+func WithChainClock(cl clock.Clock) ChainOption {
+	return func(c *Chain) error {
+		c.clock = cl
+		return nil
+	}
+}
+
+// NewChain creates a new router chain. At least one stage must be configured.
+func NewChain(opts ...ChainOption) (*Chain, error) {
+	c := &Chain{
+		cacheTTL:   DefaultCacheTTL,
+		maxEntries: DefaultMaxCacheEntries,
+		clock:      clock.New(),
+		cache:      make(map[peer.ID]cacheEntry),
+	}
+	for _, opt := range opts {
+		if err := opt(c); err != nil {
+			return nil, err
+		}
+	}
+	if len(c.stages) == 0 {
+		return nil, errors.New("router chain needs at least one stage")
+	}
+	return c, nil
+}
+
+// FindPeer queries the routers in order and returns the first non-empty result.
+func (c *Chain) FindPeer(ctx context.Context, id peer.ID) (peer.AddrInfo, error) {
+	if pi, ok := c.cached(id); ok {
+		return pi, nil
+	}
+
+	var errs []error
+	for _, s := range c.stages {
+		if ctx.Err() != nil {
+			return peer.AddrInfo{}, ctx.Err()
+		}
+		pi, err := c.query(ctx, s, id)
+		if err == nil && len(pi.Addrs) == 0 {
+			err = routing.ErrNotFound
+		}
+		if err != nil {
+			log.Debugw("router chain stage failed", "stage", s.name, "peer", id, "error", err)
+			errs = append(errs, fmt.Errorf("%s: %w", s.name, err))
+			continue
+		}
+		c.store(id, pi)
+		return pi, nil
+	}
+	if ctx.Err() != nil {
+		return peer.AddrInfo{}, ctx.Err()
+	}
+	return peer.AddrInfo{}, errors.Join(errs...)
+}
+
+func (c *Chain) query(ctx context.Context, s stage, id peer.ID) (peer.AddrInfo, error) {
+	if s.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.timeout)
+		defer cancel()
+	}
+	return s.routing.FindPeer(ctx, id)
+}
+
+func (c *Chain) cached(id peer.ID) (peer.AddrInfo, bool) {
+	if c.cacheTTL == 0 {
+		return peer.AddrInfo{}, false
+	}
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	e, ok := c.cache[id]
+	if !ok {
+		return peer.AddrInfo{}, false
+	}
+	if !c.clock.Now().Before(e.expires) {
+		delete(c.cache, id)
+		return peer.AddrInfo{}, false
+	}
+	return e.info, true
+}
+
+func (c *Chain) store(id peer.ID, pi peer.AddrInfo) {
+	if c.cacheTTL == 0 {
+		return
+	}
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	now := c.clock.Now()
+	if _, ok := c.cache[id]; !ok && len(c.cache) >= c.maxEntries {
+		for p, e := range c.cache {
+			if !now.Before(e.expires) {
+				delete(c.cache, p)
+			}
+		}
+		if len(c.cache) >= c.maxEntries {
+			// The cache is full of live entries. Don't cache this result.
+			return
+		}
+	}
+	c.cache[id] = cacheEntry{info: pi, expires: now.Add(c.cacheTTL)}
+}
+
+// Invalidate removes a peer from the cache, e.g. after dialing the cached
+// addresses failed.
+func (c *Chain) Invalidate(id peer.ID) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	delete(c.cache, id)
+}
+
+type peerstoreRouting struct {
+	ps peerstore.Peerstore
+}
+
+// PeerstoreRouting returns a Routing that looks up addresses in the peerstore.
+// It is intended to be used as the first stage of a Chain that is used outside
+// of a RoutedHost; RoutedHost already consults the peerstore before routing.
+func PeerstoreRouting(ps peerstore.Peerstore) Routing {
+	return &peerstoreRouting{ps: ps}
+}
+
+func (r *peerstoreRouting) FindPeer(_ context.Context, id peer.ID) (peer.AddrInfo, error) {
+	addrs := r.ps.Addrs(id)
+	if len(addrs) == 0 {
+		return peer.AddrInfo{}, routing.ErrNotFound
+	}
+	return peer.AddrInfo{ID: id, Addrs: addrs}, nil
+}
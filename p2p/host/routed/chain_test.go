@@ -0,0 +1,97 @@
+package routedhost
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/routing"
+	"github.com/libp2p/go-libp2p/core/test"
+	"github.com/libp2p/go-libp2p/p2p/host/peerstore/pstoremem"
+
+	"github.com/benbjohnson/clock"
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChainFallback(t *testing.T) {
+	p := test.RandPeerIDFatal(t)
+	addr := ma.StringCast("/ip4/1.2.3.4/tcp/1")
+
+	failing := &mockRouting{findPeerFn: func(context.Context, peer.ID) (peer.AddrInfo, error) {
+		return peer.AddrInfo{}, errors.New("failed")
+	}}
+	empty := &mockRouting{findPeerFn: func(_ context.Context, id peer.ID) (peer.AddrInfo, error) {
+		return peer.AddrInfo{ID: id}, nil
+	}}
+	slow := &mockRouting{findPeerFn: func(ctx context.Context, _ peer.ID) (peer.AddrInfo, error) {
+		<-ctx.Done()
+		return peer.AddrInfo{}, ctx.Err()
+	}}
+	working := &mockRouting{findPeerFn: func(_ context.Context, id peer.ID) (peer.AddrInfo, error) {
+		return peer.AddrInfo{ID: id, Addrs: []ma.Multiaddr{addr}}, nil
+	}}
+	unused := &mockRouting{findPeerFn: func(context.Context, peer.ID) (peer.AddrInfo, error) {
+		t.Fatal("should not be called")
+		return peer.AddrInfo{}, nil
+	}}
+
+	c, err := NewChain(
+		WithStage("failing", failing, 0),
+		WithStage("empty", empty, 0),
+		WithStage("slow", slow, 10*time.Millisecond),
+		WithStage("working", working, 0),
+		WithStage("unused", unused, 0),
+	)
+	require.NoError(t, err)
+
+	pi, err := c.FindPeer(context.Background(), p)
+	require.NoError(t, err)
+	require.Equal(t, []ma.Multiaddr{addr}, pi.Addrs)
+	require.Equal(t, 1, failing.callCount)
+	require.Equal(t, 1, empty.callCount)
+	require.Equal(t, 1, slow.callCount)
+	require.Equal(t, 1, working.callCount)
+}
+
+func TestChainCache(t *testing.T) {
+	p := test.RandPeerIDFatal(t)
+	cl := clock.NewMock()
+	r := &mockRouting{findPeerFn: func(_ context.Context, id peer.ID) (peer.AddrInfo, error) {
+		return peer.AddrInfo{ID: id, Addrs: []ma.Multiaddr{ma.StringCast("/ip4/1.2.3.4/tcp/1")}}, nil
+	}}
+	c, err := NewChain(WithStage("r", r, 0), WithCacheTTL(time.Minute), WithChainClock(cl))
+	require.NoError(t, err)
+
+	_, err = c.FindPeer(context.Background(), p)
+	require.NoError(t, err)
+	_, err = c.FindPeer(context.Background(), p)
+	require.NoError(t, err)
+	require.Equal(t, 1, r.callCount)
+
+	cl.Add(time.Minute)
+	_, err = c.FindPeer(context.Background(), p)
+	require.NoError(t, err)
+	require.Equal(t, 2, r.callCount)
+
+	c.Invalidate(p)
+	_, err = c.FindPeer(context.Background(), p)
+	require.NoError(t, err)
+	require.Equal(t, 3, r.callCount)
+}
+
+func TestChainNotFound(t *testing.T) {
+	ps, err := pstoremem.NewPeerstore()
+	require.NoError(t, err)
+	defer ps.Close()
+
+	c, err := NewChain(WithStage("peerstore", PeerstoreRouting(ps), 0))
+	require.NoError(t, err)
+	_, err = c.FindPeer(context.Background(), test.RandPeerIDFatal(t))
+	require.ErrorIs(t, err, routing.ErrNotFound)
+
+	_, err = NewChain()
+	require.Error(t, err)
+}
@@ -114,6 +114,10 @@ func (rh *RoutedHost) Connect(ctx context.Context, pi peer.AddrInfo) error {
 		// up-to-date addresses for the given peer. If there
 		// are addresses we didn't know about previously, we
 		// try to connect again.
+		if inv, ok := rh.route.(interface{ Invalidate(peer.ID) }); ok {
+			// don't get served the addresses we just failed to dial from a cache
+			inv.Invalidate(pi.ID)
+		}
 		newAddrs, err := rh.findPeerAddrs(ctx, pi.ID)
 		if err != nil {
 			log.Debugf("failed to find more peer addresses %s: %s", pi.ID, err)
@@ -2,6 +2,8 @@ package metricshelper
 
 import (
 	"errors"
+	"reflect"
+	"sync"
 
 	"github.com/prometheus/client_golang/prometheus"
 )
@@ -18,3 +20,99 @@ func RegisterCollectors(reg prometheus.Registerer, collectors ...prometheus.Coll
 		}
 	}
 }
+
+// LabeledRegisterer is a prometheus.Registerer that adds constant labels to
+// all metrics registered with it, like prometheus.WrapRegistererWith.
+//
+// By default, all metrics tracers of a package share the package's
+// collectors, so that tracers registering with the same registerer report
+// into the same series. Tracers registering with a LabeledRegisterer use
+// dedicated collectors instead (see Collectors). This way, multiple
+// LabeledRegisterers with different labels, e.g. one per host, can share a
+// registry, and each only reports the metrics of its own tracers.
+type LabeledRegisterer struct {
+	reg    prometheus.Registerer
+	labels func() (prometheus.Labels, error)
+
+	mx         sync.Mutex
+	wrapped    prometheus.Registerer
+	collectors map[reflect.Type]any
+}
+
+var _ prometheus.Registerer = (*LabeledRegisterer)(nil)
+
+// NewLabeledRegisterer creates a LabeledRegisterer that registers collectors
+// with reg. The labels are determined when the first collector is
+// registered, so that they can depend on configuration that isn't known yet
+// when the registerer is created, like the ID of a host.
+func NewLabeledRegisterer(reg prometheus.Registerer, labels func() (prometheus.Labels, error)) *LabeledRegisterer {
+	return &LabeledRegisterer{
+		reg:        reg,
+		labels:     labels,
+		collectors: make(map[reflect.Type]any),
+	}
+}
+
+func (r *LabeledRegisterer) registerer() (prometheus.Registerer, error) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	if r.wrapped == nil {
+		labels, err := r.labels()
+		if err != nil {
+			return nil, err
+		}
+		r.wrapped = prometheus.WrapRegistererWith(labels, r.reg)
+	}
+	return r.wrapped, nil
+}
+
+func (r *LabeledRegisterer) Register(c prometheus.Collector) error {
+	reg, err := r.registerer()
+	if err != nil {
+		return err
+	}
+	return reg.Register(c)
+}
+
+func (r *LabeledRegisterer) MustRegister(cs ...prometheus.Collector) {
+	for _, c := range cs {
+		if err := r.Register(c); err != nil {
+			panic(err)
+		}
+	}
+}
+
+func (r *LabeledRegisterer) Unregister(c prometheus.Collector) bool {
+	reg, err := r.registerer()
+	if err != nil {
+		return false
+	}
+	return reg.Unregister(c)
+}
+
+// Collectors registers the collectors of a metrics tracer with reg, and
+// returns the collectors the tracer should use.
+// Usually, these are the package level collectors shared by all tracers. If
+// reg is a LabeledRegisterer, the tracer gets dedicated collectors created by
+// newCollectors. They are shared by all tracers of the same package
+// registering with that LabeledRegisterer.
+func Collectors[T interface{ Collectors() []prometheus.Collector }](reg prometheus.Registerer, shared T, newCollectors func() T) T {
+	lr, ok := reg.(*LabeledRegisterer)
+	if !ok {
+		RegisterCollectors(reg, shared.Collectors()...)
+		return shared
+	}
+
+	typ := reflect.TypeOf(shared)
+	lr.mx.Lock()
+	c, ok := lr.collectors[typ].(T)
+	if !ok {
+		c = newCollectors()
+		lr.collectors[typ] = c
+	}
+	lr.mx.Unlock()
+	// If the collectors were created by another tracer, they're registered already.
+	RegisterCollectors(reg, c.Collectors()...)
+	return c
+}
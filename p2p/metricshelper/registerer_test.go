@@ -1,6 +1,7 @@
 package metricshelper
 
 import (
+	"errors"
 	"testing"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -30,3 +31,51 @@ func TestRegisterCollectors(t *testing.T) {
 	require.NotPanics(t, func() { RegisterCollectors(reg, c1, c2) })
 	require.NotPanics(t, func() { RegisterCollectors(reg, c3) }, "should not panic on duplicate registration")
 }
+
+type testCollectors struct {
+	counter prometheus.Counter
+}
+
+func newTestCollectors() *testCollectors {
+	return &testCollectors{counter: prometheus.NewCounter(prometheus.CounterOpts{Name: "counter"})}
+}
+
+func (c *testCollectors) Collectors() []prometheus.Collector {
+	return []prometheus.Collector{c.counter}
+}
+
+func TestLabeledRegisterer(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	shared := newTestCollectors()
+
+	// tracers without a LabeledRegisterer share the collectors
+	c1 := Collectors(reg, shared, newTestCollectors)
+	c2 := Collectors(reg, shared, newTestCollectors)
+	require.Same(t, shared, c1)
+	require.Same(t, shared, c2)
+
+	reg = prometheus.NewRegistry()
+	lr1 := NewLabeledRegisterer(reg, func() (prometheus.Labels, error) { return prometheus.Labels{"host": "1"}, nil })
+	lr2 := NewLabeledRegisterer(reg, func() (prometheus.Labels, error) { return prometheus.Labels{"host": "2"}, nil })
+	c1 = Collectors(lr1, shared, newTestCollectors)
+	c2 = Collectors(lr2, shared, newTestCollectors)
+	require.NotSame(t, shared, c1)
+	require.NotSame(t, c1, c2)
+	require.Same(t, c1, Collectors(lr1, shared, newTestCollectors))
+
+	c1.counter.Add(1)
+	c2.counter.Add(2)
+	mfs, err := reg.Gather()
+	require.NoError(t, err)
+	require.Len(t, mfs, 1)
+	values := make(map[string]float64)
+	for _, m := range mfs[0].GetMetric() {
+		values[m.GetLabel()[0].GetValue()] = m.GetCounter().GetValue()
+	}
+	require.Equal(t, map[string]float64{"1": 1, "2": 2}, values)
+}
+
+func TestLabeledRegistererError(t *testing.T) {
+	lr := NewLabeledRegisterer(prometheus.NewRegistry(), func() (prometheus.Labels, error) { return nil, errors.New("no labels") })
+	require.Error(t, lr.Register(prometheus.NewCounter(prometheus.CounterOpts{Name: "counter"})))
+}
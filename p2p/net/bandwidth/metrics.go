@@ -0,0 +1,65 @@
+package bandwidth
+
+import (
+	"github.com/libp2p/go-libp2p/core/protocol"
+	"github.com/libp2p/go-libp2p/p2p/metricshelper"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const metricNamespace = "libp2p_bandwidth"
+
+type metricsCollectors struct {
+	bytesTotal  *prometheus.CounterVec
+	messageSize *prometheus.HistogramVec
+}
+
+func newMetricsCollectors() *metricsCollectors {
+	return &metricsCollectors{
+		bytesTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: metricNamespace,
+				Name:      "bytes_total",
+				Help:      "Bytes transferred on streams",
+			},
+			[]string{"protocol", "dir"},
+		),
+		messageSize: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace: metricNamespace,
+				Name:      "message_size_bytes",
+				Help:      "Size of the messages read from and written to streams",
+				Buckets:   prometheus.ExponentialBuckets(16, 4, 8),
+			},
+			[]string{"protocol", "dir"},
+		),
+	}
+}
+
+func (c *metricsCollectors) Collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		c.bytesTotal,
+		c.messageSize,
+	}
+}
+
+// defaultCollectors are shared by all metrics tracers, unless they register
+// with a metricshelper.LabeledRegisterer.
+var defaultCollectors = newMetricsCollectors()
+
+type metricsTracer struct {
+	*metricsCollectors
+}
+
+func newMetricsTracer(reg prometheus.Registerer) *metricsTracer {
+	return &metricsTracer{metricshelper.Collectors(reg, defaultCollectors, newMetricsCollectors)}
+}
+
+func (m *metricsTracer) message(proto protocol.ID, dir string, size int64) {
+	tags := metricshelper.GetStringSlice()
+	defer metricshelper.PutStringSlice(tags)
+	*tags = append(*tags, string(proto), dir)
+
+	m.bytesTotal.WithLabelValues(*tags...).Add(float64(size))
+	m.messageSize.WithLabelValues(*tags...).Observe(float64(size))
+}
@@ -0,0 +1,251 @@
+// Package bandwidth provides a bandwidth reporter that attributes the traffic
+// of the local node to (peer, protocol, direction) tuples.
+//
+// Use it by passing it to libp2p:
+//
+//	r := bandwidth.NewReporter(bandwidth.WithRegisterer(prometheus.DefaultRegisterer))
+//	h, err := libp2p.New(libp2p.BandwidthReporter(r))
+//
+// It can then be used to answer questions like "which protocol / peer is
+// consuming my uplink":
+//
+//	for key, stats := range r.GetBandwidthByPeerProtocol() {
+//		fmt.Println(key.Peer, key.Protocol, stats.RateOut)
+//	}
+package bandwidth
+
+import (
+	"encoding/binary"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/metrics"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+
+	"github.com/libp2p/go-flow-metrics"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// OtherPeer is the peer that traffic is attributed to when the number of
+	// tracked peers exceeds the limit set by WithMaxPeers.
+	OtherPeer = peer.ID("other")
+	// OtherProtocol is the protocol that traffic is attributed to when the number
+	// of tracked protocols exceeds the limit set by WithMaxProtocols.
+	OtherProtocol = protocol.ID("other")
+	// UnknownProtocol is the protocol that traffic is attributed to before
+	// protocol negotiation completed.
+	UnknownProtocol = protocol.ID("unknown")
+)
+
+// PeerProtocol identifies the traffic with a peer on a protocol.
+type PeerProtocol struct {
+	Peer     peer.ID
+	Protocol protocol.ID
+}
+
+// key returns the key of the meters of pp. Peer IDs can contain any byte, so
+// the peer ID is length-prefixed.
+func (pp PeerProtocol) key() string {
+	b := binary.AppendUvarint(nil, uint64(len(pp.Peer)))
+	b = append(b, pp.Peer...)
+	b = append(b, pp.Protocol...)
+	return string(b)
+}
+
+// Option is an option for the Reporter.
+type Option func(*Reporter)
+
+// WithMaxPeers limits the number of peers that traffic is attributed to
+// individually. Once the limit is reached, traffic with additional peers is
+// attributed to OtherPeer, until idle peers are removed with TrimIdle.
+// Defaults to 1000. Set to 0 for no limit.
+func WithMaxPeers(n int) Option {
+	return func(r *Reporter) {
+		r.maxPeers = n
+	}
+}
+
+// WithMaxProtocols limits the number of protocols that traffic is attributed
+// to individually. Once the limit is reached, traffic on additional protocols is
+// attributed to OtherProtocol, until idle protocols are removed with TrimIdle.
+// Defaults to 100. Set to 0 for no limit.
+func WithMaxProtocols(n int) Option {
+	return func(r *Reporter) {
+		r.maxProtocols = n
+	}
+}
+
+// WithRegisterer enables exporting Prometheus metrics: the number of bytes
+// sent and received, and a histogram of message sizes, per protocol and
+// direction. Peers are not used as labels, to keep the cardinality low.
+func WithRegisterer(reg prometheus.Registerer) Option {
+	return func(r *Reporter) {
+		r.reg = reg
+	}
+}
+
+// Reporter is a metrics.Reporter that, in addition to the totals per peer and
+// per protocol, tracks the bandwidth per (peer, protocol, direction).
+type Reporter struct {
+	*metrics.BandwidthCounter
+
+	maxPeers, maxProtocols int
+	reg                    prometheus.Registerer
+	mt                     *metricsTracer
+
+	in, out flow.MeterRegistry
+
+	mx        sync.Mutex
+	peers     map[peer.ID]time.Time
+	protocols map[protocol.ID]time.Time
+}
+
+var _ metrics.Reporter = (*Reporter)(nil)
+
+// NewReporter creates a new Reporter.
+func NewReporter(opts ...Option) *Reporter {
+	r := &Reporter{
+		BandwidthCounter: metrics.NewBandwidthCounter(),
+		maxPeers:         1000,
+		maxProtocols:     100,
+		peers:            make(map[peer.ID]time.Time),
+		protocols:        make(map[protocol.ID]time.Time),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	if r.reg != nil {
+		r.mt = newMetricsTracer(r.reg)
+	}
+	return r
+}
+
+// admit returns the peer and protocol that the traffic should be attributed to,
+// respecting the cardinality limits.
+func (r *Reporter) admit(p peer.ID, proto protocol.ID) PeerProtocol {
+	if proto == "" {
+		proto = UnknownProtocol
+	}
+	now := time.Now()
+
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	if _, ok := r.peers[p]; ok || r.maxPeers <= 0 || len(r.peers) < r.maxPeers {
+		r.peers[p] = now
+	} else {
+		p = OtherPeer
+	}
+	if _, ok := r.protocols[proto]; ok || r.maxProtocols <= 0 || len(r.protocols) < r.maxProtocols {
+		r.protocols[proto] = now
+	} else {
+		proto = OtherProtocol
+	}
+	return PeerProtocol{Peer: p, Protocol: proto}
+}
+
+// LogSentMessageStream records the size of an outgoing message over a stream.
+func (r *Reporter) LogSentMessageStream(size int64, proto protocol.ID, p peer.ID) {
+	r.BandwidthCounter.LogSentMessageStream(size, proto, p)
+	pp := r.admit(p, proto)
+	r.out.Get(pp.key()).Mark(uint64(size))
+	if r.mt != nil {
+		r.mt.message(pp.Protocol, "outbound", size)
+	}
+}
+
+// LogRecvMessageStream records the size of an incoming message over a stream.
+func (r *Reporter) LogRecvMessageStream(size int64, proto protocol.ID, p peer.ID) {
+	r.BandwidthCounter.LogRecvMessageStream(size, proto, p)
+	pp := r.admit(p, proto)
+	r.in.Get(pp.key()).Mark(uint64(size))
+	if r.mt != nil {
+		r.mt.message(pp.Protocol, "inbound", size)
+	}
+}
+
+// GetBandwidthForPeerProtocol returns the bandwidth used with a peer on a protocol.
+func (r *Reporter) GetBandwidthForPeerProtocol(p peer.ID, proto protocol.ID) metrics.Stats {
+	key := PeerProtocol{Peer: p, Protocol: proto}.key()
+	inSnap := r.in.Get(key).Snapshot()
+	outSnap := r.out.Get(key).Snapshot()
+
+	return metrics.Stats{
+		TotalIn:  int64(inSnap.Total),
+		TotalOut: int64(outSnap.Total),
+		RateIn:   inSnap.Rate,
+		RateOut:  outSnap.Rate,
+	}
+}
+
+// GetBandwidthByPeerProtocol returns the bandwidth used for every (peer, protocol)
+// tuple that is tracked. This method may be very expensive.
+func (r *Reporter) GetBandwidthByPeerProtocol() map[PeerProtocol]metrics.Stats {
+	stats := make(map[PeerProtocol]metrics.Stats)
+
+	r.in.ForEach(func(key string, meter *flow.Meter) {
+		pp := parseKey(key)
+		snap := meter.Snapshot()
+
+		stat := stats[pp]
+		stat.TotalIn = int64(snap.Total)
+		stat.RateIn = snap.Rate
+		stats[pp] = stat
+	})
+
+	r.out.ForEach(func(key string, meter *flow.Meter) {
+		pp := parseKey(key)
+		snap := meter.Snapshot()
+
+		stat := stats[pp]
+		stat.TotalOut = int64(snap.Total)
+		stat.RateOut = snap.Rate
+		stats[pp] = stat
+	})
+
+	return stats
+}
+
+func parseKey(key string) PeerProtocol {
+	l, n := binary.Uvarint([]byte(key))
+	if n <= 0 || uint64(len(key)-n) < l {
+		return PeerProtocol{}
+	}
+	key = key[n:]
+	return PeerProtocol{Peer: peer.ID(key[:l]), Protocol: protocol.ID(key[l:])}
+}
+
+// Reset clears all stats.
+func (r *Reporter) Reset() {
+	r.BandwidthCounter.Reset()
+	r.in.Clear()
+	r.out.Clear()
+
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	r.peers = make(map[peer.ID]time.Time)
+	r.protocols = make(map[protocol.ID]time.Time)
+}
+
+// TrimIdle trims all meters idle since the given time, and stops counting
+// peers and protocols without traffic since then against the cardinality limits.
+func (r *Reporter) TrimIdle(since time.Time) {
+	r.BandwidthCounter.TrimIdle(since)
+	r.in.TrimIdle(since)
+	r.out.TrimIdle(since)
+
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	for p, t := range r.peers {
+		if t.Before(since) {
+			delete(r.peers, p)
+		}
+	}
+	for proto, t := range r.protocols {
+		if t.Before(since) {
+			delete(r.protocols, proto)
+		}
+	}
+}
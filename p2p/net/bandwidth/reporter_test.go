@@ -0,0 +1,106 @@
+package bandwidth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+
+	"github.com/benbjohnson/clock"
+	"github.com/libp2p/go-flow-metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+var cl = clock.NewMock()
+
+func init() {
+	flow.SetClock(cl)
+}
+
+// sweepUntil advances the clock until cond holds. The meters register with the
+// sweeper asynchronously, so the first ticks may not update them yet.
+func sweepUntil(t *testing.T, cond func() bool) {
+	t.Helper()
+	require.Eventually(t, func() bool {
+		cl.Add(time.Second)
+		return cond()
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+// randPeerID returns the ID of a random Ed25519 key. These IDs are identity
+// multihashes, which start with a zero byte.
+func randPeerID(t *testing.T) peer.ID {
+	t.Helper()
+	_, pub, err := crypto.GenerateEd25519Key(nil)
+	require.NoError(t, err)
+	p, err := peer.IDFromPublicKey(pub)
+	require.NoError(t, err)
+	return p
+}
+
+func TestAttribution(t *testing.T) {
+	a, b := randPeerID(t), randPeerID(t)
+	require.Zero(t, a[0])
+
+	r := NewReporter()
+	r.LogSentMessageStream(100, "/foo", a)
+	r.LogSentMessageStream(50, "/bar", a)
+	r.LogRecvMessageStream(10, "/foo", b)
+	r.LogRecvMessageStream(20, "", b)
+	sweepUntil(t, func() bool {
+		return r.GetBandwidthForPeerProtocol(a, "/foo").TotalOut == 100 &&
+			r.GetBandwidthForPeerProtocol(a, "/bar").TotalOut == 50 &&
+			r.GetBandwidthForPeerProtocol(b, "/foo").TotalIn == 10 &&
+			r.GetBandwidthForPeerProtocol(b, UnknownProtocol).TotalIn == 20
+	})
+	require.Equal(t, int64(150), r.GetBandwidthForPeer(a).TotalOut)
+
+	stats := r.GetBandwidthByPeerProtocol()
+	require.Len(t, stats, 4)
+	require.Equal(t, int64(100), stats[PeerProtocol{Peer: a, Protocol: "/foo"}].TotalOut)
+	require.Equal(t, int64(50), stats[PeerProtocol{Peer: a, Protocol: "/bar"}].TotalOut)
+	require.Equal(t, int64(10), stats[PeerProtocol{Peer: b, Protocol: "/foo"}].TotalIn)
+	require.Equal(t, int64(20), stats[PeerProtocol{Peer: b, Protocol: UnknownProtocol}].TotalIn)
+}
+
+func TestCardinalityLimits(t *testing.T) {
+	peers := []peer.ID{randPeerID(t), randPeerID(t), randPeerID(t), randPeerID(t)}
+	a := peers[0]
+
+	r := NewReporter(WithMaxPeers(2), WithMaxProtocols(1))
+	for _, p := range peers {
+		r.LogSentMessageStream(10, "/foo", p)
+	}
+	r.LogSentMessageStream(10, "/bar", a)
+	sweepUntil(t, func() bool {
+		stats := r.GetBandwidthByPeerProtocol()
+		return stats[PeerProtocol{Peer: OtherPeer, Protocol: "/foo"}].TotalOut == 20 &&
+			stats[PeerProtocol{Peer: a, Protocol: OtherProtocol}].TotalOut == 10
+	})
+	require.NotContains(t, r.GetBandwidthByPeerProtocol(), PeerProtocol{Peer: peers[2], Protocol: "/foo"})
+
+	// trimming frees up room for new peers
+	r.TrimIdle(time.Now().Add(time.Hour))
+	e := randPeerID(t)
+	r.LogSentMessageStream(10, "/foo", e)
+	sweepUntil(t, func() bool {
+		return r.GetBandwidthForPeerProtocol(e, "/foo").TotalOut == 10
+	})
+}
+
+func TestPrometheus(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	r := NewReporter(WithRegisterer(reg))
+	r.LogSentMessageStream(100, "/foo", randPeerID(t))
+
+	mfs, err := reg.Gather()
+	require.NoError(t, err)
+	var names []string
+	for _, mf := range mfs {
+		names = append(names, mf.GetName())
+	}
+	require.Contains(t, names, "libp2p_bandwidth_bytes_total")
+	require.Contains(t, names, "libp2p_bandwidth_message_size_bytes")
+}
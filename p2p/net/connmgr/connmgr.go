@@ -10,8 +10,10 @@ import (
 
 	"github.com/benbjohnson/clock"
 	"github.com/libp2p/go-libp2p/core/connmgr"
+	"github.com/libp2p/go-libp2p/core/event"
 	"github.com/libp2p/go-libp2p/core/network"
 	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
 
 	logging "github.com/ipfs/go-log/v2"
 	ma "github.com/multiformats/go-multiaddr"
@@ -37,6 +39,9 @@ type BasicConnMgr struct {
 	plk       sync.RWMutex
 	protected map[peer.ID]map[string]struct{}
 
+	floorsMx sync.Mutex
+	floors   map[protocol.ID]map[string]int
+
 	// channel-based semaphore that enforces only a single trim is in progress
 	trimMutex sync.Mutex
 	connCount atomic.Int32
@@ -47,6 +52,9 @@ type BasicConnMgr struct {
 	lastTrimMu sync.RWMutex
 	lastTrim   time.Time
 
+	emitterMx sync.Mutex
+	emitter   event.Emitter
+
 	refCount                sync.WaitGroup
 	ctx                     context.Context
 	cancel                  func()
@@ -125,11 +133,16 @@ func NewConnManager(low, hi int, opts ...Option) (*BasicConnMgr, error) {
 		// Set the default decayer config.
 		cfg.decayer = (&DecayerCfg{}).WithDefaults()
 	}
+	if cfg.decayer.Clock == nil {
+		// Decay tags using the connection manager's clock, unless configured otherwise.
+		cfg.decayer.Clock = cfg.clock
+	}
 
 	cm := &BasicConnMgr{
 		cfg:       cfg,
 		clock:     cfg.clock,
 		protected: make(map[peer.ID]map[string]struct{}, 16),
+		floors:    make(map[protocol.ID]map[string]int),
 		segments:  segments{},
 	}
 
@@ -141,6 +154,10 @@ func NewConnManager(low, hi int, opts ...Option) (*BasicConnMgr, error) {
 
 	cm.ctx, cm.cancel = context.WithCancel(context.Background())
 
+	if cfg.metricsTracer != nil {
+		cfg.metricsTracer.Watermarks(cfg.lowWater, cfg.highWater)
+	}
+
 	if cfg.emergencyTrim {
 		// When we're running low on memory, immediately trigger a trim.
 		cm.unregisterMemoryWatcher = registerWatchdog(cm.memoryEmergency)
@@ -173,10 +190,12 @@ func (cm *BasicConnMgr) memoryEmergency() {
 	defer cm.trimMutex.Unlock()
 
 	// Trim connections without paying attention to the silence period.
-	for _, c := range cm.getConnsToCloseEmergency(target) {
+	conns := cm.getConnsToCloseEmergency(target)
+	for _, c := range conns {
 		log.Infow("low on memory. closing conn", "peer", c.RemotePeer())
 		c.Close()
 	}
+	cm.reportTrim(event.TrimReasonMemoryEmergency, connCount, conns)
 
 	// finally, update the last trim time.
 	cm.lastTrimMu.Lock()
@@ -365,7 +384,7 @@ func (cm *BasicConnMgr) background() {
 		case <-cm.ctx.Done():
 			return
 		}
-		cm.trim()
+		cm.trim(event.TrimReasonHighWater)
 	}
 }
 
@@ -375,7 +394,7 @@ func (cm *BasicConnMgr) doTrim() {
 	cm.trimMutex.Lock()
 	defer cm.trimMutex.Unlock()
 	if count == atomic.LoadUint64(&cm.trimCount) {
-		cm.trim()
+		cm.trim(event.TrimReasonManual)
 		cm.lastTrimMu.Lock()
 		cm.lastTrim = cm.clock.Now()
 		cm.lastTrimMu.Unlock()
@@ -384,12 +403,79 @@ func (cm *BasicConnMgr) doTrim() {
 }
 
 // trim starts the trim, if the last trim happened before the configured silence period.
-func (cm *BasicConnMgr) trim() {
+func (cm *BasicConnMgr) trim(reason event.ConnManagerTrimReason) {
+	connCount := int(cm.connCount.Load())
 	// do the actual trim.
-	for _, c := range cm.getConnsToClose() {
+	conns := cm.getConnsToClose()
+	for _, c := range conns {
 		log.Debugw("closing conn", "peer", c.RemotePeer())
 		c.Close()
 	}
+	cm.reportTrim(reason, connCount, conns)
+}
+
+// SetTrimEmitter sets the emitter used to emit an EvtConnManagerTrimmed event
+// after every trim that closed connections.
+func (cm *BasicConnMgr) SetTrimEmitter(em event.Emitter) {
+	cm.emitterMx.Lock()
+	defer cm.emitterMx.Unlock()
+	cm.emitter = em
+}
+
+// reportTrim reports a trim that closed the given connections to the metrics
+// tracer and on the event bus.
+func (cm *BasicConnMgr) reportTrim(reason event.ConnManagerTrimReason, connCount int, closed []network.Conn) {
+	if cm.cfg.metricsTracer != nil {
+		cm.cfg.metricsTracer.Trimmed(reason, len(closed))
+		cm.cfg.metricsTracer.Protected(cm.protectedCount())
+	}
+	if len(closed) == 0 {
+		return
+	}
+
+	cm.emitterMx.Lock()
+	defer cm.emitterMx.Unlock()
+	if cm.emitter == nil {
+		return
+	}
+	peers := make([]peer.ID, 0, len(closed))
+	seen := make(map[peer.ID]struct{}, len(closed))
+	for _, c := range closed {
+		p := c.RemotePeer()
+		if _, ok := seen[p]; ok {
+			continue
+		}
+		seen[p] = struct{}{}
+		peers = append(peers, p)
+	}
+	if err := cm.emitter.Emit(event.EvtConnManagerTrimmed{
+		Reason:      reason,
+		ConnsClosed: len(closed),
+		Peers:       peers,
+		ConnCount:   connCount,
+		LowWater:    cm.cfg.lowWater,
+		HighWater:   cm.cfg.highWater,
+	}); err != nil {
+		log.Debugw("failed to emit trim event", "error", err)
+	}
+}
+
+// protectedCount returns the number of protected peers, and the number of
+// connections to these peers.
+func (cm *BasicConnMgr) protectedCount() (peers, conns int) {
+	cm.plk.RLock()
+	defer cm.plk.RUnlock()
+
+	peers = len(cm.protected)
+	for p := range cm.protected {
+		s := cm.segments.get(p)
+		s.Lock()
+		if inf, ok := s.peers[p]; ok {
+			conns += len(inf.conns)
+		}
+		s.Unlock()
+	}
+	return peers, conns
 }
 
 func (cm *BasicConnMgr) getConnsToCloseEmergency(target int) []network.Conn {
@@ -410,7 +496,7 @@ func (cm *BasicConnMgr) getConnsToCloseEmergency(target int) []network.Conn {
 	cm.plk.RUnlock()
 
 	// Sort peers according to their value.
-	candidates.SortByValueAndStreams(&cm.segments, true)
+	cm.sortCandidates(candidates, true)
 
 	selected := make([]network.Conn, 0, target+10)
 	for _, inf := range candidates {
@@ -443,7 +529,7 @@ func (cm *BasicConnMgr) getConnsToCloseEmergency(target int) []network.Conn {
 	}
 	cm.plk.RUnlock()
 
-	candidates.SortByValueAndStreams(&cm.segments, true)
+	cm.sortCandidates(candidates, true)
 	for _, inf := range candidates {
 		if target <= 0 {
 			break
@@ -508,8 +594,9 @@ func (cm *BasicConnMgr) getConnsToClose() []network.Conn {
 	}
 
 	// Sort peers according to their value.
-	candidates.SortByValueAndStreams(&cm.segments, false)
+	cm.sortCandidates(candidates, false)
 
+	floors := cm.getProtocolFloors()
 	target := ncandidates - cm.cfg.lowWater
 
 	// slightly overallocate because we may have more than one conns per peer
@@ -527,7 +614,7 @@ func (cm *BasicConnMgr) getConnsToClose() []network.Conn {
 			// handle temporary entries for early tags -- this entry has gone past the grace period
 			// and still holds no connections, so prune it.
 			delete(s.peers, inf.id)
-		} else {
+		} else if !floors.keep(inf.id) {
 			for c := range inf.conns {
 				selected = append(selected, c)
 			}
@@ -698,7 +785,10 @@ func (nn *cmNotifee) Connected(n network.Network, c network.Conn) {
 	}
 
 	pinfo.conns[c] = cm.clock.Now()
-	cm.connCount.Add(1)
+	count := cm.connCount.Add(1)
+	if cm.cfg.metricsTracer != nil {
+		cm.cfg.metricsTracer.ConnCount(int(count))
+	}
 }
 
 // Disconnected is called by notifiers to inform that an existing connection has been closed or terminated.
@@ -727,7 +817,10 @@ func (nn *cmNotifee) Disconnected(n network.Network, c network.Conn) {
 	if len(cinf.conns) == 0 {
 		delete(s.peers, p)
 	}
-	cm.connCount.Add(-1)
+	count := cm.connCount.Add(-1)
+	if cm.cfg.metricsTracer != nil {
+		cm.cfg.metricsTracer.ConnCount(int(count))
+	}
 }
 
 // Listen is no-op in this implementation.
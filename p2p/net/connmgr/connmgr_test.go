@@ -9,9 +9,11 @@ import (
 
 	"github.com/benbjohnson/clock"
 	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/event"
 	"github.com/libp2p/go-libp2p/core/network"
 	"github.com/libp2p/go-libp2p/core/peer"
 	tu "github.com/libp2p/go-libp2p/core/test"
+	"github.com/libp2p/go-libp2p/p2p/host/eventbus"
 
 	ma "github.com/multiformats/go-multiaddr"
 	"github.com/stretchr/testify/require"
@@ -986,3 +988,89 @@ type testLimitGetter struct {
 func (g testLimitGetter) GetConnLimit() int {
 	return g.limit
 }
+
+type mockMetricsTracer struct {
+	mx        sync.Mutex
+	trims     map[event.ConnManagerTrimReason]int
+	closed    int
+	conns     int
+	protected int
+	low, high int
+}
+
+func (m *mockMetricsTracer) Trimmed(reason event.ConnManagerTrimReason, closed int) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	m.trims[reason]++
+	m.closed += closed
+}
+
+func (m *mockMetricsTracer) ConnCount(n int) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	m.conns = n
+}
+
+func (m *mockMetricsTracer) Protected(peers, _ int) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	m.protected = peers
+}
+
+func (m *mockMetricsTracer) Watermarks(low, high int) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	m.low, m.high = low, high
+}
+
+func TestTrimEventsAndMetrics(t *testing.T) {
+	mt := &mockMetricsTracer{trims: make(map[event.ConnManagerTrimReason]int)}
+	cm, err := NewConnManager(5, 10, WithGracePeriod(0), WithMetricsTracer(mt))
+	require.NoError(t, err)
+	defer cm.Close()
+
+	bus := eventbus.NewBus()
+	em, err := bus.Emitter(new(event.EvtConnManagerTrimmed))
+	require.NoError(t, err)
+	defer em.Close()
+	sub, err := bus.Subscribe(new(event.EvtConnManagerTrimmed))
+	require.NoError(t, err)
+	defer sub.Close()
+	cm.SetTrimEmitter(em)
+
+	not := cm.Notifee()
+	var conns []network.Conn
+	for i := 0; i < 10; i++ {
+		rc := randConn(t, not.Disconnected)
+		conns = append(conns, rc)
+		not.Connected(nil, rc)
+	}
+	cm.Protect(conns[0].RemotePeer(), "test")
+
+	cm.TrimOpenConns(context.Background())
+
+	// The protected connection doesn't count towards the low watermark,
+	// so we trim down to 5 unprotected connections.
+	select {
+	case e := <-sub.Out():
+		evt := e.(event.EvtConnManagerTrimmed)
+		require.Equal(t, event.TrimReasonManual, evt.Reason)
+		require.Equal(t, 4, evt.ConnsClosed)
+		require.Len(t, evt.Peers, 4)
+		require.Equal(t, 10, evt.ConnCount)
+		require.Equal(t, 5, evt.LowWater)
+		require.Equal(t, 10, evt.HighWater)
+		require.NotContains(t, evt.Peers, conns[0].RemotePeer())
+	case <-time.After(time.Second):
+		t.Fatal("expected a trim event")
+	}
+
+	mt.mx.Lock()
+	defer mt.mx.Unlock()
+	require.Equal(t, 1, mt.trims[event.TrimReasonManual])
+	require.Equal(t, 4, mt.closed)
+	require.Equal(t, 6, mt.conns)
+	require.Equal(t, 1, mt.protected)
+	require.Equal(t, 5, mt.low)
+	require.Equal(t, 10, mt.high)
+}
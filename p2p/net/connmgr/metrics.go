@@ -0,0 +1,148 @@
+package connmgr
+
+import (
+	"github.com/libp2p/go-libp2p/core/event"
+	"github.com/libp2p/go-libp2p/p2p/metricshelper"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const metricNamespace = "libp2p_connmgr"
+
+type metricsCollectors struct {
+	trimsTotal        *prometheus.CounterVec
+	trimmedConnsTotal *prometheus.CounterVec
+	connsTracked      prometheus.Gauge
+	protectedPeers    prometheus.Gauge
+	protectedConns    prometheus.Gauge
+	watermark         *prometheus.GaugeVec
+}
+
+func newMetricsCollectors() *metricsCollectors {
+	return &metricsCollectors{
+		trimsTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: metricNamespace,
+				Name:      "trims_total",
+				Help:      "Number of trims performed",
+			},
+			[]string{"reason"},
+		),
+		trimmedConnsTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: metricNamespace,
+				Name:      "trimmed_connections_total",
+				Help:      "Number of connections closed by trims",
+			},
+			[]string{"reason"},
+		),
+		connsTracked: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace: metricNamespace,
+				Name:      "connections",
+				Help:      "Number of connections tracked by the connection manager",
+			},
+		),
+		protectedPeers: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace: metricNamespace,
+				Name:      "protected_peers",
+				Help:      "Number of protected peers",
+			},
+		),
+		protectedConns: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace: metricNamespace,
+				Name:      "protected_connections",
+				Help:      "Number of connections to protected peers",
+			},
+		),
+		watermark: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: metricNamespace,
+				Name:      "watermark",
+				Help:      "Configured connection watermarks",
+			},
+			[]string{"type"},
+		),
+	}
+}
+
+func (c *metricsCollectors) Collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		c.trimsTotal,
+		c.trimmedConnsTotal,
+		c.connsTracked,
+		c.protectedPeers,
+		c.protectedConns,
+		c.watermark,
+	}
+}
+
+// defaultCollectors are shared by all metrics tracers, unless they register
+// with a metricshelper.LabeledRegisterer.
+var defaultCollectors = newMetricsCollectors()
+
+// MetricsTracer tracks metrics of the connection manager.
+type MetricsTracer interface {
+	// Trimmed is called after every trim with the number of connections closed.
+	Trimmed(reason event.ConnManagerTrimReason, closed int)
+	// ConnCount is called whenever the number of tracked connections changes.
+	ConnCount(n int)
+	// Protected is called after every trim with the number of protected peers
+	// and the number of connections to them.
+	Protected(peers, conns int)
+	// Watermarks is called once, when the connection manager is created.
+	Watermarks(low, high int)
+}
+
+type metricsTracer struct {
+	*metricsCollectors
+}
+
+var _ MetricsTracer = &metricsTracer{}
+
+type metricsTracerSetting struct {
+	reg prometheus.Registerer
+}
+
+type MetricsTracerOption func(*metricsTracerSetting)
+
+func WithRegisterer(reg prometheus.Registerer) MetricsTracerOption {
+	return func(s *metricsTracerSetting) {
+		if reg != nil {
+			s.reg = reg
+		}
+	}
+}
+
+func NewMetricsTracer(opts ...MetricsTracerOption) MetricsTracer {
+	setting := &metricsTracerSetting{reg: prometheus.DefaultRegisterer}
+	for _, opt := range opts {
+		opt(setting)
+	}
+	return &metricsTracer{metricshelper.Collectors(setting.reg, defaultCollectors, newMetricsCollectors)}
+}
+
+func (m *metricsTracer) Trimmed(reason event.ConnManagerTrimReason, closed int) {
+	tags := metricshelper.GetStringSlice()
+	defer metricshelper.PutStringSlice(tags)
+	*tags = append(*tags, string(reason))
+
+	m.trimsTotal.WithLabelValues(*tags...).Inc()
+	m.trimmedConnsTotal.WithLabelValues(*tags...).Add(float64(closed))
+}
+
+func (m *metricsTracer) ConnCount(n int) {
+	m.connsTracked.Set(float64(n))
+}
+
+func (m *metricsTracer) Protected(peers, conns int) {
+	m.protectedPeers.Set(float64(peers))
+	m.protectedConns.Set(float64(conns))
+}
+
+func (m *metricsTracer) Watermarks(low, high int) {
+	m.watermark.WithLabelValues("low").Set(float64(low))
+	m.watermark.WithLabelValues("high").Set(float64(high))
+}
@@ -0,0 +1,25 @@
+//go:build nocover
+
+package connmgr
+
+import (
+	"testing"
+
+	"github.com/libp2p/go-libp2p/core/event"
+)
+
+func TestNoCoverNoAlloc(t *testing.T) {
+	mt := NewMetricsTracer()
+	tests := map[string]func(){
+		"Trimmed":    func() { mt.Trimmed(event.TrimReasonHighWater, 10) },
+		"ConnCount":  func() { mt.ConnCount(100) },
+		"Protected":  func() { mt.Protected(5, 7) },
+		"Watermarks": func() { mt.Watermarks(160, 192) },
+	}
+	for method, f := range tests {
+		allocs := testing.AllocsPerRun(1000, f)
+		if allocs > 0 {
+			t.Fatalf("%s alloc test failed expected 0 received %0.2f", method, allocs)
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/benbjohnson/clock"
+	"github.com/libp2p/go-libp2p/core/peerstore"
 )
 
 // config is the configuration struct for the basic connection manager.
@@ -16,6 +17,10 @@ type config struct {
 	decayer       *DecayerCfg
 	emergencyTrim bool
 	clock         clock.Clock
+	score         ScoreFunc
+	latency       peerstore.Metrics
+	metricsTracer MetricsTracer
+	protoBook     peerstore.ProtoBook
 }
 
 // Option represents an option for the basic connection manager.
@@ -62,3 +67,12 @@ func WithSilencePeriod(p time.Duration) Option {
 		return nil
 	}
 }
+
+// WithMetricsTracer configures the connection manager to report metrics,
+// e.g. the trims performed and the number of protected connections.
+func WithMetricsTracer(mt MetricsTracer) Option {
+	return func(cfg *config) error {
+		cfg.metricsTracer = mt
+		return nil
+	}
+}
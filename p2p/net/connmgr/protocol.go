@@ -0,0 +1,136 @@
+package connmgr
+
+import (
+	"github.com/libp2p/go-libp2p/core/connmgr"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/peerstore"
+	"github.com/libp2p/go-libp2p/core/protocol"
+)
+
+var _ connmgr.ProtocolProtector = (*BasicConnMgr)(nil)
+
+// WithProtoBook sets the ProtoBook used to look up the protocols spoken by
+// connected peers. It is required for protocol floors (see ProtectProtocol) to
+// take effect.
+func WithProtoBook(pb peerstore.ProtoBook) Option {
+	return func(cfg *config) error {
+		cfg.protoBook = pb
+		return nil
+	}
+}
+
+// ProtectProtocol asks the connection manager to keep connections to at least
+// n peers speaking proto when trimming. See connmgr.ProtocolProtector.
+func (cm *BasicConnMgr) ProtectProtocol(proto protocol.ID, tag string, n int) {
+	if cm.cfg.protoBook == nil {
+		log.Warnw("protocol floor has no effect without a ProtoBook, see WithProtoBook", "protocol", proto)
+	}
+
+	cm.floorsMx.Lock()
+	defer cm.floorsMx.Unlock()
+
+	tags, ok := cm.floors[proto]
+	if !ok {
+		tags = make(map[string]int, 2)
+		cm.floors[proto] = tags
+	}
+	tags[tag] = n
+}
+
+// UnprotectProtocol removes the protocol floor declared using the given tag.
+func (cm *BasicConnMgr) UnprotectProtocol(proto protocol.ID, tag string) (protected bool) {
+	cm.floorsMx.Lock()
+	defer cm.floorsMx.Unlock()
+
+	tags, ok := cm.floors[proto]
+	if !ok {
+		return false
+	}
+	if delete(tags, tag); len(tags) == 0 {
+		delete(cm.floors, proto)
+		return false
+	}
+	return true
+}
+
+// protocolFloors keeps track of the protocol floors during a trim.
+type protocolFloors struct {
+	// floor is the effective floor for every protected protocol.
+	floor map[protocol.ID]int
+	// count is the number of connected peers speaking every protected protocol.
+	count map[protocol.ID]int
+	// protos holds the protected protocols spoken by each connected peer.
+	protos map[peer.ID][]protocol.ID
+}
+
+// keep returns true if closing the connections to the peer would bring the
+// number of peers speaking one of the protected protocols below its floor.
+// Otherwise, it assumes that the connections to the peer will be closed.
+func (f *protocolFloors) keep(p peer.ID) bool {
+	if f == nil {
+		return false
+	}
+	protos := f.protos[p]
+	for _, proto := range protos {
+		if f.count[proto] <= f.floor[proto] {
+			return true
+		}
+	}
+	for _, proto := range protos {
+		f.count[proto]--
+	}
+	return false
+}
+
+// getProtocolFloors looks up the protocols spoken by all connected peers, and
+// returns nil if no protocol floors are configured.
+func (cm *BasicConnMgr) getProtocolFloors() *protocolFloors {
+	if cm.cfg.protoBook == nil {
+		return nil
+	}
+
+	cm.floorsMx.Lock()
+	if len(cm.floors) == 0 {
+		cm.floorsMx.Unlock()
+		return nil
+	}
+	f := &protocolFloors{
+		floor:  make(map[protocol.ID]int, len(cm.floors)),
+		count:  make(map[protocol.ID]int, len(cm.floors)),
+		protos: make(map[peer.ID][]protocol.ID),
+	}
+	protos := make([]protocol.ID, 0, len(cm.floors))
+	for proto, tags := range cm.floors {
+		for _, n := range tags {
+			if n > f.floor[proto] {
+				f.floor[proto] = n
+			}
+		}
+		protos = append(protos, proto)
+	}
+	cm.floorsMx.Unlock()
+
+	// Don't hold the segment locks while querying the peerstore.
+	peers := make([]peer.ID, 0, cm.segments.countPeers())
+	for _, s := range cm.segments.buckets {
+		s.Lock()
+		for id, inf := range s.peers {
+			if len(inf.conns) > 0 {
+				peers = append(peers, id)
+			}
+		}
+		s.Unlock()
+	}
+
+	for _, p := range peers {
+		supported, err := cm.cfg.protoBook.SupportsProtocols(p, protos...)
+		if err != nil || len(supported) == 0 {
+			continue
+		}
+		f.protos[p] = supported
+		for _, proto := range supported {
+			f.count[proto]++
+		}
+	}
+	return f
+}
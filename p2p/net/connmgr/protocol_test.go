@@ -0,0 +1,60 @@
+package connmgr
+
+import (
+	"context"
+	"testing"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/p2p/host/peerstore/pstoremem"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProtocolFloor(t *testing.T) {
+	ps, err := pstoremem.NewPeerstore()
+	require.NoError(t, err)
+	defer ps.Close()
+
+	cm, err := NewConnManager(2, 4, WithGracePeriod(0), WithProtoBook(ps))
+	require.NoError(t, err)
+	defer cm.Close()
+	not := cm.Notifee()
+
+	var conns []network.Conn
+	for i := 0; i < 6; i++ {
+		c := randConn(t, not.Disconnected)
+		conns = append(conns, c)
+		not.Connected(nil, c)
+	}
+	// The peers speaking the protocol have the lowest value, so they'd be trimmed first.
+	for _, c := range conns[3:] {
+		cm.TagPeer(c.RemotePeer(), "important", 10)
+	}
+	for _, c := range conns[:3] {
+		require.NoError(t, ps.AddProtocols(c.RemotePeer(), "/foo"))
+	}
+
+	cm.ProtectProtocol("/foo", "test", 2)
+	cm.TrimOpenConns(context.Background())
+
+	var kept int
+	for _, c := range conns[:3] {
+		if !c.(*tconn).isClosed() {
+			kept++
+		}
+	}
+	require.Equal(t, 2, kept, "should have kept two peers speaking the protocol")
+}
+
+func TestProtocolFloorTags(t *testing.T) {
+	cm, err := NewConnManager(2, 4)
+	require.NoError(t, err)
+	defer cm.Close()
+
+	cm.ProtectProtocol("/foo", "a", 2)
+	cm.ProtectProtocol("/foo", "b", 5)
+	require.True(t, cm.UnprotectProtocol("/foo", "a"))
+	require.False(t, cm.UnprotectProtocol("/foo", "b"))
+	require.False(t, cm.UnprotectProtocol("/foo", "b"))
+	require.False(t, cm.UnprotectProtocol("/bar", "a"))
+}
@@ -0,0 +1,167 @@
+package connmgr
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/peerstore"
+
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+// ConnQuality holds the quality signals of a single connection, as passed to a ScoreFunc.
+type ConnQuality struct {
+	// Direction is the direction of the connection.
+	Direction network.Direction
+	// Transport is the transport used by the connection, e.g. "tcp" or "quic-v1".
+	Transport string
+	// Relayed is true if this is a connection over a circuit v2 relay.
+	Relayed bool
+	// NumStreams is the number of streams currently open on the connection.
+	NumStreams int
+	// Age is the time since the connection was opened.
+	Age time.Duration
+	// Idle is the time since the most recent stream activity on this
+	// connection: a stream being opened or, if the connection tracks protocol
+	// usage, a stream being read from or written to. It is the time since the
+	// connection was opened if it never had a stream.
+	Idle time.Duration
+}
+
+// PeerQuality holds the information about a peer that is used to score it when
+// selecting the connections to close during a trim.
+type PeerQuality struct {
+	ID peer.ID
+	// Value is the sum of the values of all tags of the peer.
+	Value int
+	// Latency is the EWMA of the latency to the peer, as recorded in the
+	// peerstore. It is zero if no latency metrics were configured using
+	// WithLatencyMetrics or if the latency isn't known.
+	Latency time.Duration
+	// Conns holds the quality signals for every connection to the peer.
+	Conns []ConnQuality
+}
+
+// ScoreFunc computes the score of a peer. When trimming, the connections of the
+// peers with the lowest scores are closed first.
+type ScoreFunc func(PeerQuality) float64
+
+// DefaultScore is a ScoreFunc that combines the tag value of a peer with the
+// quality of its connections:
+//   - every open stream adds 1, up to 10 per connection
+//   - connections we opened add 5, as we probably opened them for a reason
+//   - connections that have been idle for more than 5 minutes subtract 5
+//   - relayed connections subtract 5, as a direct connection to the peer is more valuable
+//   - every 100ms of latency subtracts 1
+func DefaultScore(p PeerQuality) float64 {
+	score := float64(p.Value)
+	for _, c := range p.Conns {
+		score += math.Min(float64(c.NumStreams), 10)
+		if c.Direction == network.DirOutbound {
+			score += 5
+		}
+		if c.Idle > 5*time.Minute {
+			score -= 5
+		}
+		if c.Relayed {
+			score -= 5
+		}
+	}
+	score -= float64(p.Latency) / float64(100*time.Millisecond)
+	return score
+}
+
+// WithScoring makes the connection manager select the connections to close by
+// scoring every peer using the given function, instead of only considering the
+// tag values. Use DefaultScore for a sensible default.
+// Peers with only temporary tag entries (i.e. no connections) are still pruned first.
+func WithScoring(score ScoreFunc) Option {
+	return func(cfg *config) error {
+		cfg.score = score
+		return nil
+	}
+}
+
+// WithLatencyMetrics makes the latency of peers, as recorded in the given peerstore
+// metrics, available to the ScoreFunc.
+func WithLatencyMetrics(m peerstore.Metrics) Option {
+	return func(cfg *config) error {
+		cfg.latency = m
+		return nil
+	}
+}
+
+// peerQuality collects the quality signals for a peer.
+// The segment of the peer must be locked by the caller.
+func (cm *BasicConnMgr) peerQuality(inf *peerInfo, now time.Time) PeerQuality {
+	pq := PeerQuality{
+		ID:    inf.id,
+		Value: inf.value,
+		Conns: make([]ConnQuality, 0, len(inf.conns)),
+	}
+	if cm.cfg.latency != nil {
+		pq.Latency = cm.cfg.latency.LatencyEWMA(inf.id)
+	}
+	for c := range inf.conns {
+		stat := c.Stat()
+		lastActivity := stat.Opened
+		for _, s := range c.GetStreams() {
+			if opened := s.Stat().Opened; opened.After(lastActivity) {
+				lastActivity = opened
+			}
+		}
+		if uc, ok := c.(network.ProtocolUsageConn); ok {
+			for _, u := range uc.ProtocolUsage() {
+				if u.LastUsed.After(lastActivity) {
+					lastActivity = u.LastUsed
+				}
+			}
+		}
+		_, err := c.RemoteMultiaddr().ValueForProtocol(ma.P_CIRCUIT)
+		pq.Conns = append(pq.Conns, ConnQuality{
+			Direction:  stat.Direction,
+			Transport:  c.ConnState().Transport,
+			Relayed:    err == nil,
+			NumStreams: stat.NumStreams,
+			Age:        now.Sub(stat.Opened),
+			Idle:       now.Sub(lastActivity),
+		})
+	}
+	return pq
+}
+
+// sortByScore sorts the candidates in ascending order of their score, using the
+// configured ScoreFunc. Temporary peers are sorted first.
+func (cm *BasicConnMgr) sortByScore(candidates peerInfos) {
+	now := cm.clock.Now()
+	scores := make(map[peer.ID]float64, len(candidates))
+	for _, inf := range candidates {
+		s := cm.segments.get(inf.id)
+		s.Lock()
+		if !inf.temp {
+			scores[inf.id] = cm.cfg.score(cm.peerQuality(inf, now))
+		}
+		s.Unlock()
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		left, right := candidates[i], candidates[j]
+		// temporary peers are preferred for pruning.
+		if left.temp != right.temp {
+			return left.temp
+		}
+		return scores[left.id] < scores[right.id]
+	})
+}
+
+// sortCandidates sorts the candidates in the order in which they should be pruned.
+func (cm *BasicConnMgr) sortCandidates(candidates peerInfos, sortByMoreStreams bool) {
+	if cm.cfg.score != nil {
+		cm.sortByScore(candidates)
+		return
+	}
+	candidates.SortByValueAndStreams(&cm.segments, sortByMoreStreams)
+}
@@ -0,0 +1,138 @@
+package connmgr
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	tu "github.com/libp2p/go-libp2p/core/test"
+
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/stretchr/testify/require"
+)
+
+type scoreConn struct {
+	tconn
+	stats network.ConnStats
+	addr  ma.Multiaddr
+}
+
+func (c *scoreConn) Stat() network.ConnStats       { return c.stats }
+func (c *scoreConn) RemoteMultiaddr() ma.Multiaddr { return c.addr }
+func (c *scoreConn) GetStreams() []network.Stream  { return nil }
+func (c *scoreConn) ConnState() network.ConnectionState {
+	return network.ConnectionState{Transport: "tcp"}
+}
+
+func newScoreConn(t *testing.T, dir network.Direction, numStreams int, addr string) *scoreConn {
+	c := &scoreConn{addr: ma.StringCast(addr)}
+	c.peer = tu.RandPeerIDFatal(t)
+	c.stats.Direction = dir
+	c.stats.NumStreams = numStreams
+	c.stats.Opened = time.Now()
+	return c
+}
+
+type mockLatencyMetrics map[peer.ID]time.Duration
+
+func (m mockLatencyMetrics) RecordLatency(peer.ID, time.Duration) {}
+func (m mockLatencyMetrics) LatencyEWMA(p peer.ID) time.Duration  { return m[p] }
+func (m mockLatencyMetrics) RemovePeer(peer.ID)                   {}
+
+func TestDefaultScore(t *testing.T) {
+	base := PeerQuality{Conns: []ConnQuality{{Direction: network.DirInbound}}}
+	withStreams := PeerQuality{Conns: []ConnQuality{{Direction: network.DirInbound, NumStreams: 3}}}
+	outbound := PeerQuality{Conns: []ConnQuality{{Direction: network.DirOutbound}}}
+	relayed := PeerQuality{Conns: []ConnQuality{{Direction: network.DirInbound, Relayed: true}}}
+	idle := PeerQuality{Conns: []ConnQuality{{Direction: network.DirInbound, Idle: time.Hour}}}
+	slow := PeerQuality{Latency: time.Second, Conns: []ConnQuality{{Direction: network.DirInbound}}}
+
+	require.Greater(t, DefaultScore(withStreams), DefaultScore(base))
+	require.Greater(t, DefaultScore(outbound), DefaultScore(base))
+	require.Less(t, DefaultScore(relayed), DefaultScore(base))
+	require.Less(t, DefaultScore(idle), DefaultScore(base))
+	require.Less(t, DefaultScore(slow), DefaultScore(base))
+}
+
+func TestTrimWithScoring(t *testing.T) {
+	slow := newScoreConn(t, network.DirOutbound, 1, "/ip4/1.2.3.4/tcp/1")
+	relayed := newScoreConn(t, network.DirOutbound, 1, "/ip4/1.2.3.4/tcp/1/p2p/QmcgpsyWgH8Y8ajJz1Cu72KnS5uo2Aa2LpzU7kinSupGVN/p2p-circuit")
+	good := newScoreConn(t, network.DirOutbound, 1, "/ip4/1.2.3.5/tcp/1")
+
+	latencies := mockLatencyMetrics{slow.peer: 2 * time.Second}
+	cm, err := NewConnManager(1, 1,
+		WithGracePeriod(0),
+		WithScoring(DefaultScore),
+		WithLatencyMetrics(latencies),
+	)
+	require.NoError(t, err)
+	defer cm.Close()
+
+	not := cm.Notifee()
+	for _, c := range []*scoreConn{slow, relayed, good} {
+		not.Connected(nil, c)
+	}
+	// tag values would normally protect the slow peer
+	cm.TagPeer(slow.peer, "important", 5)
+
+	cm.TrimOpenConns(context.Background())
+
+	require.True(t, slow.isClosed(), "slow connection should have been closed")
+	require.True(t, relayed.isClosed(), "relayed connection should have been closed")
+	require.False(t, good.isClosed(), "good connection should have been kept")
+}
+
+func TestTrimWithCustomScoring(t *testing.T) {
+	a := newScoreConn(t, network.DirInbound, 0, "/ip4/1.2.3.4/tcp/1")
+	b := newScoreConn(t, network.DirInbound, 0, "/ip4/1.2.3.5/tcp/1")
+
+	cm, err := NewConnManager(1, 1,
+		WithGracePeriod(0),
+		WithScoring(func(p PeerQuality) float64 {
+			if p.ID == a.peer {
+				return 100
+			}
+			return 0
+		}),
+	)
+	require.NoError(t, err)
+	defer cm.Close()
+
+	not := cm.Notifee()
+	not.Connected(nil, a)
+	not.Connected(nil, b)
+	cm.TagPeer(b.peer, "important", 1000)
+
+	cm.TrimOpenConns(context.Background())
+	require.False(t, a.isClosed())
+	require.True(t, b.isClosed())
+}
+
+type usageConn struct {
+	*scoreConn
+	usage map[protocol.ID]network.ProtocolUsage
+}
+
+func (c *usageConn) ProtocolUsage() map[protocol.ID]network.ProtocolUsage { return c.usage }
+
+func TestPeerQualityIdle(t *testing.T) {
+	cm, err := NewConnManager(10, 20)
+	require.NoError(t, err)
+	defer cm.Close()
+
+	now := time.Now()
+	c := newScoreConn(t, network.DirInbound, 1, "/ip4/1.2.3.4/tcp/1")
+	c.stats.Opened = now.Add(-time.Hour)
+	inf := &peerInfo{id: c.peer, conns: map[network.Conn]time.Time{c: c.stats.Opened}}
+	require.Equal(t, time.Hour, cm.peerQuality(inf, now).Conns[0].Idle)
+
+	// Reading from and writing to streams counts as activity.
+	uc := &usageConn{scoreConn: c, usage: map[protocol.ID]network.ProtocolUsage{
+		"/foo": {Streams: 1, LastUsed: now.Add(-time.Minute)},
+	}}
+	inf = &peerInfo{id: c.peer, conns: map[network.Conn]time.Time{uc: c.stats.Opened}}
+	require.Equal(t, time.Minute, cm.peerQuality(inf, now).Conns[0].Idle)
+}
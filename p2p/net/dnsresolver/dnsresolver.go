@@ -0,0 +1,283 @@
+// Package dnsresolver provides a caching DNS resolver for resolving DNS
+// multiaddrs.
+//
+// The Resolver implements madns.BasicResolver. It resolves names using the
+// system resolver, a list of DNS servers, or a DNS over HTTPS (DoH) endpoint,
+// and caches the results. When resolving using DNS servers or DoH, results are
+// cached for the TTL of the DNS records. The system resolver doesn't report
+// TTLs, so its results are cached for a fixed duration.
+package dnsresolver
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	madns "github.com/multiformats/go-multiaddr-dns"
+)
+
+const (
+	// systemResolverTTL is the duration results of the system resolver are
+	// cached for, since it doesn't report the TTL of records.
+	systemResolverTTL = time.Minute
+	// negativeTTL is the duration that names which don't exist are cached for.
+	negativeTTL = 30 * time.Second
+
+	defaultMaxTTL    = time.Hour
+	defaultCacheSize = 1024
+)
+
+const (
+	lookupIP  = "ip"
+	lookupTXT = "txt"
+)
+
+// upstream resolves names, reporting the TTL of the results.
+type upstream interface {
+	lookupIPAddr(ctx context.Context, name string) ([]net.IPAddr, time.Duration, error)
+	lookupTXT(ctx context.Context, name string) ([]string, time.Duration, error)
+}
+
+type cacheKey struct {
+	kind string
+	name string
+}
+
+type cacheEntry struct {
+	ips     []net.IPAddr
+	txts    []string
+	err     error
+	expires time.Time
+}
+
+// Resolver is a caching DNS resolver. It is safe for concurrent use.
+type Resolver struct {
+	upstream  upstream
+	clock     clock.Clock
+	maxTTL    time.Duration
+	cacheSize int
+	mt        MetricsTracer
+	servers   []string
+	dohURL    string
+	dohClient *http.Client
+
+	mu    sync.Mutex
+	cache map[cacheKey]*cacheEntry
+}
+
+var _ madns.BasicResolver = &Resolver{}
+
+// Option is an option for the Resolver.
+type Option func(*Resolver) error
+
+// WithServers configures the Resolver to send queries to the given DNS
+// servers instead of using the system resolver. Servers are given as
+// host:port. If the port is omitted, port 53 is used. Servers are tried in
+// order until one of them responds.
+func WithServers(servers ...string) Option {
+	return func(r *Resolver) error {
+		if len(servers) == 0 {
+			return errors.New("no DNS servers given")
+		}
+		r.servers = make([]string, 0, len(servers))
+		for _, s := range servers {
+			if _, _, err := net.SplitHostPort(s); err != nil {
+				s = net.JoinHostPort(s, "53")
+			}
+			r.servers = append(r.servers, s)
+		}
+		return nil
+	}
+}
+
+// WithDoH configures the Resolver to send queries to the DNS over HTTPS
+// endpoint at url, as specified in RFC 8484, instead of using the system
+// resolver. If client is nil, http.DefaultClient is used.
+func WithDoH(url string, client *http.Client) Option {
+	return func(r *Resolver) error {
+		if url == "" {
+			return errors.New("no DoH URL given")
+		}
+		if client == nil {
+			client = http.DefaultClient
+		}
+		r.dohURL = url
+		r.dohClient = client
+		return nil
+	}
+}
+
+// WithCacheSize sets the maximum number of names the Resolver caches. A size
+// of 0 disables caching. Defaults to 1024.
+func WithCacheSize(n int) Option {
+	return func(r *Resolver) error {
+		if n < 0 {
+			return errors.New("cache size must not be negative")
+		}
+		r.cacheSize = n
+		return nil
+	}
+}
+
+// WithMaxTTL sets the maximum duration results are cached for, regardless of
+// the TTL of the DNS records. Defaults to one hour.
+func WithMaxTTL(d time.Duration) Option {
+	return func(r *Resolver) error {
+		r.maxTTL = d
+		return nil
+	}
+}
+
+// WithClock sets the clock used to expire cache entries.
+func WithClock(cl clock.Clock) Option {
+	return func(r *Resolver) error {
+		r.clock = cl
+		return nil
+	}
+}
+
+// WithMetricsTracer sets the tracer used to track lookups.
+func WithMetricsTracer(mt MetricsTracer) Option {
+	return func(r *Resolver) error {
+		r.mt = mt
+		return nil
+	}
+}
+
+// New creates a new Resolver. By default, it uses the system resolver.
+func New(opts ...Option) (*Resolver, error) {
+	r := &Resolver{
+		clock:     clock.New(),
+		maxTTL:    defaultMaxTTL,
+		cacheSize: defaultCacheSize,
+		cache:     make(map[cacheKey]*cacheEntry),
+	}
+	for _, opt := range opts {
+		if err := opt(r); err != nil {
+			return nil, err
+		}
+	}
+	switch {
+	case r.servers != nil && r.dohURL != "":
+		return nil, errors.New("cannot use both DNS servers and DoH")
+	case r.servers != nil:
+		r.upstream = &msgUpstream{exchanger: newServerExchanger(r.servers)}
+	case r.dohURL != "":
+		r.upstream = &msgUpstream{exchanger: &dohExchanger{url: r.dohURL, client: r.dohClient}}
+	default:
+		r.upstream = &systemUpstream{resolver: net.DefaultResolver}
+	}
+	return r, nil
+}
+
+// LookupIPAddr looks up the IPv4 and IPv6 addresses of name.
+func (r *Resolver) LookupIPAddr(ctx context.Context, name string) ([]net.IPAddr, error) {
+	e, err := r.lookup(ctx, lookupIP, name, func(ctx context.Context) (*cacheEntry, time.Duration, error) {
+		ips, ttl, err := r.upstream.lookupIPAddr(ctx, name)
+		return &cacheEntry{ips: ips}, ttl, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return e.ips, nil
+}
+
+// LookupTXT looks up the TXT records of name.
+func (r *Resolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	e, err := r.lookup(ctx, lookupTXT, name, func(ctx context.Context) (*cacheEntry, time.Duration, error) {
+		txts, ttl, err := r.upstream.lookupTXT(ctx, name)
+		return &cacheEntry{txts: txts}, ttl, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return e.txts, nil
+}
+
+func (r *Resolver) lookup(ctx context.Context, kind, name string, resolve func(context.Context) (*cacheEntry, time.Duration, error)) (*cacheEntry, error) {
+	key := cacheKey{kind: kind, name: strings.ToLower(strings.TrimSuffix(name, "."))}
+
+	r.mu.Lock()
+	e, ok := r.cache[key]
+	if ok && r.clock.Now().Before(e.expires) {
+		r.mu.Unlock()
+		if r.mt != nil {
+			r.mt.CacheHit(kind)
+		}
+		return e, e.err
+	}
+	r.mu.Unlock()
+
+	start := r.clock.Now()
+	e, ttl, err := resolve(ctx)
+	if r.mt != nil {
+		r.mt.Lookup(kind, r.clock.Since(start), err)
+	}
+	if err != nil {
+		var dnsErr *net.DNSError
+		if !errors.As(err, &dnsErr) || !dnsErr.IsNotFound {
+			// don't cache transient failures
+			return nil, err
+		}
+		e = &cacheEntry{err: err}
+		ttl = negativeTTL
+	}
+	r.add(key, e, ttl)
+	return e, err
+}
+
+// add adds an entry to the cache.
+func (r *Resolver) add(key cacheKey, e *cacheEntry, ttl time.Duration) {
+	if ttl > r.maxTTL {
+		ttl = r.maxTTL
+	}
+	if ttl <= 0 || r.cacheSize == 0 {
+		return
+	}
+	now := r.clock.Now()
+	e.expires = now.Add(ttl)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.cache[key]; !ok && len(r.cache) >= r.cacheSize {
+		for k, e := range r.cache {
+			if !now.Before(e.expires) {
+				delete(r.cache, k)
+			}
+		}
+		// if all entries are still valid, evict a random one
+		if len(r.cache) >= r.cacheSize {
+			for k := range r.cache {
+				delete(r.cache, k)
+				break
+			}
+		}
+	}
+	r.cache[key] = e
+}
+
+// notFound returns the error returned when name doesn't exist, or doesn't have
+// any records of the requested type.
+func notFound(name string) error {
+	return &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+}
+
+// systemUpstream resolves names using a net.Resolver.
+type systemUpstream struct {
+	resolver *net.Resolver
+}
+
+func (u *systemUpstream) lookupIPAddr(ctx context.Context, name string) ([]net.IPAddr, time.Duration, error) {
+	ips, err := u.resolver.LookupIPAddr(ctx, name)
+	return ips, systemResolverTTL, err
+}
+
+func (u *systemUpstream) lookupTXT(ctx context.Context, name string) ([]string, time.Duration, error) {
+	txts, err := u.resolver.LookupTXT(ctx, name)
+	return txts, systemResolverTTL, err
+}
@@ -0,0 +1,226 @@
+package dnsresolver
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/require"
+)
+
+// testHandler answers queries for example.com, and counts them.
+type testHandler struct {
+	queries atomic.Int32
+	// queries of these types are answered with SERVFAIL
+	failTypes []uint16
+}
+
+func (h *testHandler) response(req *dns.Msg) *dns.Msg {
+	h.queries.Add(1)
+	resp := new(dns.Msg)
+	resp.SetReply(req)
+	q := req.Question[0]
+	if q.Name != "example.com." {
+		resp.Rcode = dns.RcodeNameError
+		return resp
+	}
+	if slices.Contains(h.failTypes, q.Qtype) {
+		resp.Rcode = dns.RcodeServerFailure
+		return resp
+	}
+	hdr := dns.RR_Header{Name: q.Name, Rrtype: q.Qtype, Class: dns.ClassINET, Ttl: 60}
+	switch q.Qtype {
+	case dns.TypeA:
+		resp.Answer = append(resp.Answer, &dns.A{Hdr: hdr, A: net.ParseIP("1.2.3.4")})
+	case dns.TypeAAAA:
+		hdr.Ttl = 30
+		resp.Answer = append(resp.Answer, &dns.AAAA{Hdr: hdr, AAAA: net.ParseIP("::1")})
+	case dns.TypeTXT:
+		resp.Answer = append(resp.Answer, &dns.TXT{Hdr: hdr, Txt: []string{"dnsaddr=", "/ip4/1.2.3.4/tcp/1"}})
+	}
+	return resp
+}
+
+func (h *testHandler) ServeDNS(w dns.ResponseWriter, req *dns.Msg) {
+	w.WriteMsg(h.response(req))
+}
+
+func (h *testHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b, err := io.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	req := new(dns.Msg)
+	if err := req.Unpack(b); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	resp, err := h.response(req).Pack()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", dohMediaType)
+	w.Write(resp)
+}
+
+func startDNSServer(t *testing.T, h *testHandler) string {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	started := make(chan struct{})
+	srv := &dns.Server{PacketConn: conn, Handler: h, NotifyStartedFunc: func() { close(started) }}
+	go srv.ActivateAndServe()
+	t.Cleanup(func() { srv.Shutdown() })
+	<-started
+	return conn.LocalAddr().String()
+}
+
+func testResolver(t *testing.T, r *Resolver, cl *clock.Mock, h *testHandler) {
+	ctx := context.Background()
+
+	ips, err := r.LookupIPAddr(ctx, "example.com")
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"1.2.3.4", "::1"}, []string{ips[0].IP.String(), ips[1].IP.String()})
+	require.EqualValues(t, 2, h.queries.Load()) // A and AAAA
+
+	txts, err := r.LookupTXT(ctx, "example.com")
+	require.NoError(t, err)
+	require.Equal(t, []string{"dnsaddr=/ip4/1.2.3.4/tcp/1"}, txts)
+	require.EqualValues(t, 3, h.queries.Load())
+
+	_, err = r.LookupIPAddr(ctx, "unknown.com")
+	var dnsErr *net.DNSError
+	require.True(t, errors.As(err, &dnsErr))
+	require.True(t, dnsErr.IsNotFound)
+	require.EqualValues(t, 4, h.queries.Load())
+
+	// cached
+	_, err = r.LookupIPAddr(ctx, "EXAMPLE.com.")
+	require.NoError(t, err)
+	_, err = r.LookupTXT(ctx, "example.com")
+	require.NoError(t, err)
+	_, err = r.LookupIPAddr(ctx, "unknown.com")
+	require.Error(t, err)
+	require.EqualValues(t, 4, h.queries.Load())
+
+	// the IP addresses expire with the shorter TTL of the AAAA record,
+	// as does the negative cache entry
+	cl.Add(30 * time.Second)
+	_, err = r.LookupTXT(ctx, "example.com")
+	require.NoError(t, err)
+	require.EqualValues(t, 4, h.queries.Load())
+	_, err = r.LookupIPAddr(ctx, "example.com")
+	require.NoError(t, err)
+	require.EqualValues(t, 6, h.queries.Load())
+	_, err = r.LookupIPAddr(ctx, "unknown.com")
+	require.Error(t, err)
+	require.EqualValues(t, 7, h.queries.Load())
+
+	cl.Add(30 * time.Second)
+	_, err = r.LookupTXT(ctx, "example.com")
+	require.NoError(t, err)
+	require.EqualValues(t, 8, h.queries.Load())
+}
+
+func TestResolverServers(t *testing.T) {
+	h := &testHandler{}
+	addr := startDNSServer(t, h)
+	cl := clock.NewMock()
+	r, err := New(WithServers(addr), WithClock(cl))
+	require.NoError(t, err)
+	testResolver(t, r, cl, h)
+}
+
+func TestResolverDoH(t *testing.T) {
+	h := &testHandler{}
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+	cl := clock.NewMock()
+	r, err := New(WithDoH(srv.URL, srv.Client()), WithClock(cl))
+	require.NoError(t, err)
+	testResolver(t, r, cl, h)
+}
+
+func TestResolverServerFallback(t *testing.T) {
+	h := &testHandler{}
+	addr := startDNSServer(t, h)
+	// nothing listens on this port, so the query fails
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	unreachable := conn.LocalAddr().String()
+	conn.Close()
+
+	r, err := New(WithServers(unreachable, addr))
+	require.NoError(t, err)
+	_, err = r.LookupTXT(context.Background(), "example.com")
+	require.NoError(t, err)
+}
+
+func TestResolverPartialIPFailure(t *testing.T) {
+	ctx := context.Background()
+	t.Run("AAAA fails", func(t *testing.T) {
+		h := &testHandler{failTypes: []uint16{dns.TypeAAAA}}
+		r, err := New(WithServers(startDNSServer(t, h)))
+		require.NoError(t, err)
+		ips, err := r.LookupIPAddr(ctx, "example.com")
+		require.NoError(t, err)
+		require.Len(t, ips, 1)
+		require.Equal(t, "1.2.3.4", ips[0].IP.String())
+	})
+	t.Run("A fails", func(t *testing.T) {
+		h := &testHandler{failTypes: []uint16{dns.TypeA}}
+		r, err := New(WithServers(startDNSServer(t, h)))
+		require.NoError(t, err)
+		ips, err := r.LookupIPAddr(ctx, "example.com")
+		require.NoError(t, err)
+		require.Len(t, ips, 1)
+		require.Equal(t, "::1", ips[0].IP.String())
+	})
+	t.Run("both fail", func(t *testing.T) {
+		h := &testHandler{failTypes: []uint16{dns.TypeA, dns.TypeAAAA}}
+		r, err := New(WithServers(startDNSServer(t, h)))
+		require.NoError(t, err)
+		_, err = r.LookupIPAddr(ctx, "example.com")
+		require.Error(t, err)
+		require.EqualValues(t, 2, h.queries.Load())
+	})
+}
+
+func TestResolverCacheSize(t *testing.T) {
+	h := &testHandler{}
+	addr := startDNSServer(t, h)
+	r, err := New(WithServers(addr), WithCacheSize(1))
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	_, err = r.LookupTXT(ctx, "example.com")
+	require.NoError(t, err)
+	_, err = r.LookupIPAddr(ctx, "example.com")
+	require.NoError(t, err)
+	require.Len(t, r.cache, 1)
+	require.Contains(t, r.cache, cacheKey{kind: lookupIP, name: "example.com"})
+}
+
+func TestResolverInvalidOptions(t *testing.T) {
+	_, err := New(WithServers())
+	require.Error(t, err)
+	_, err = New(WithServers("127.0.0.1"), WithDoH("https://example.com/dns-query", nil))
+	require.Error(t, err)
+	_, err = New(WithCacheSize(-1))
+	require.Error(t, err)
+
+	r, err := New(WithServers("127.0.0.1", "[::1]:5353"))
+	require.NoError(t, err)
+	require.Equal(t, []string{"127.0.0.1:53", "[::1]:5353"}, r.servers)
+}
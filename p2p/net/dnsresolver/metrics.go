@@ -0,0 +1,113 @@
+package dnsresolver
+
+import (
+	"net"
+	"time"
+
+	"github.com/libp2p/go-libp2p/p2p/metricshelper"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const metricNamespace = "libp2p_dns"
+
+type metricsCollectors struct {
+	lookupsTotal   *prometheus.CounterVec
+	lookupDuration *prometheus.HistogramVec
+}
+
+func newMetricsCollectors() *metricsCollectors {
+	return &metricsCollectors{
+		lookupsTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: metricNamespace,
+				Name:      "lookups_total",
+				Help:      "DNS lookups",
+			},
+			[]string{"type", "outcome"},
+		),
+		lookupDuration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace: metricNamespace,
+				Name:      "lookup_duration_seconds",
+				Help:      "Duration of DNS lookups not answered from the cache",
+				Buckets:   []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2, 5},
+			},
+			[]string{"type"},
+		),
+	}
+}
+
+func (c *metricsCollectors) Collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		c.lookupsTotal,
+		c.lookupDuration,
+	}
+}
+
+// defaultCollectors are shared by all metrics tracers, unless they register
+// with a metricshelper.LabeledRegisterer.
+var defaultCollectors = newMetricsCollectors()
+
+// MetricsTracer tracks metrics of the Resolver.
+type MetricsTracer interface {
+	// CacheHit tracks a lookup of type "ip" or "txt" answered from the cache.
+	CacheHit(kind string)
+	// Lookup tracks a lookup of type "ip" or "txt" that was sent upstream.
+	Lookup(kind string, d time.Duration, err error)
+}
+
+type metricsTracer struct {
+	*metricsCollectors
+}
+
+var _ MetricsTracer = &metricsTracer{}
+
+type metricsTracerSetting struct {
+	reg prometheus.Registerer
+}
+
+type MetricsTracerOption func(*metricsTracerSetting)
+
+func WithRegisterer(reg prometheus.Registerer) MetricsTracerOption {
+	return func(s *metricsTracerSetting) {
+		if reg != nil {
+			s.reg = reg
+		}
+	}
+}
+
+func NewMetricsTracer(opts ...MetricsTracerOption) MetricsTracer {
+	setting := &metricsTracerSetting{reg: prometheus.DefaultRegisterer}
+	for _, opt := range opts {
+		opt(setting)
+	}
+	return &metricsTracer{metricshelper.Collectors(setting.reg, defaultCollectors, newMetricsCollectors)}
+}
+
+func (m *metricsTracer) CacheHit(kind string) {
+	tags := metricshelper.GetStringSlice()
+	defer metricshelper.PutStringSlice(tags)
+
+	*tags = append(*tags, kind, "cache_hit")
+	m.lookupsTotal.WithLabelValues(*tags...).Inc()
+}
+
+func (m *metricsTracer) Lookup(kind string, d time.Duration, err error) {
+	tags := metricshelper.GetStringSlice()
+	defer metricshelper.PutStringSlice(tags)
+
+	*tags = append(*tags, kind)
+	// not found errors are returned unwrapped by all upstreams
+	dnsErr, isDNSErr := err.(*net.DNSError)
+	switch {
+	case err == nil:
+		*tags = append(*tags, "success")
+	case isDNSErr && dnsErr.IsNotFound:
+		*tags = append(*tags, "not_found")
+	default:
+		*tags = append(*tags, "error")
+	}
+	m.lookupsTotal.WithLabelValues(*tags...).Inc()
+	m.lookupDuration.WithLabelValues(kind).Observe(d.Seconds())
+}
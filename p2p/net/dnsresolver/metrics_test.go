@@ -0,0 +1,28 @@
+//go:build nocover
+
+package dnsresolver
+
+import (
+	"errors"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestMetricsNoAllocNoCover(t *testing.T) {
+	mt := NewMetricsTracer()
+	kinds := []string{lookupIP, lookupTXT}
+	errs := []error{nil, notFound("example.com"), errors.New("timeout")}
+	tests := map[string]func(){
+		"CacheHit": func() { mt.CacheHit(kinds[rand.Intn(len(kinds))]) },
+		"Lookup": func() {
+			mt.Lookup(kinds[rand.Intn(len(kinds))], time.Duration(rand.Intn(1000))*time.Millisecond, errs[rand.Intn(len(errs))])
+		},
+	}
+	for method, f := range tests {
+		allocs := testing.AllocsPerRun(1000, f)
+		if allocs > 0 {
+			t.Fatalf("%s alloc test failed expected 0 received %0.2f", method, allocs)
+		}
+	}
+}
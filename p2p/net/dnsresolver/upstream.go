@@ -0,0 +1,190 @@
+package dnsresolver
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// exchanger sends a DNS query and returns the response.
+type exchanger interface {
+	exchange(ctx context.Context, m *dns.Msg) (*dns.Msg, error)
+}
+
+// msgUpstream resolves names by sending DNS queries using an exchanger.
+type msgUpstream struct {
+	exchanger exchanger
+}
+
+// query sends a query for name and returns the answers, along with the
+// minimum TTL of the answers.
+func (u *msgUpstream) query(ctx context.Context, name string, qtype uint16) ([]dns.RR, time.Duration, error) {
+	m := new(dns.Msg)
+	m.SetQuestion(dns.Fqdn(name), qtype)
+	resp, err := u.exchanger.exchange(ctx, m)
+	if err != nil {
+		return nil, 0, err
+	}
+	switch resp.Rcode {
+	case dns.RcodeSuccess:
+	case dns.RcodeNameError:
+		return nil, 0, notFound(name)
+	default:
+		return nil, 0, fmt.Errorf("lookup %s: server returned %s", name, dns.RcodeToString[resp.Rcode])
+	}
+	var ttl time.Duration
+	for i, rr := range resp.Answer {
+		if d := time.Duration(rr.Header().Ttl) * time.Second; i == 0 || d < ttl {
+			ttl = d
+		}
+	}
+	return resp.Answer, ttl, nil
+}
+
+// lookupIPAddr queries the A and AAAA records of name. If only one of the
+// queries fails, the results of the other one are returned.
+func (u *msgUpstream) lookupIPAddr(ctx context.Context, name string) ([]net.IPAddr, time.Duration, error) {
+	var ips []net.IPAddr
+	var ttl time.Duration
+	var haveTTL bool
+	var firstErr error
+	for _, qtype := range []uint16{dns.TypeA, dns.TypeAAAA} {
+		answers, t, err := u.query(ctx, name, qtype)
+		if err != nil {
+			// NXDOMAIN means that the name doesn't exist at all, so there's
+			// no point in sending the AAAA query.
+			var dnsErr *net.DNSError
+			if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+				return nil, 0, err
+			}
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		var found bool
+		for _, rr := range answers {
+			switch rr := rr.(type) {
+			case *dns.A:
+				ips = append(ips, net.IPAddr{IP: rr.A})
+				found = true
+			case *dns.AAAA:
+				ips = append(ips, net.IPAddr{IP: rr.AAAA})
+				found = true
+			}
+		}
+		if found && (!haveTTL || t < ttl) {
+			ttl = t
+			haveTTL = true
+		}
+	}
+	if len(ips) == 0 {
+		if firstErr != nil {
+			return nil, 0, firstErr
+		}
+		return nil, 0, notFound(name)
+	}
+	return ips, ttl, nil
+}
+
+func (u *msgUpstream) lookupTXT(ctx context.Context, name string) ([]string, time.Duration, error) {
+	answers, ttl, err := u.query(ctx, name, dns.TypeTXT)
+	if err != nil {
+		return nil, 0, err
+	}
+	var txts []string
+	for _, rr := range answers {
+		if rr, ok := rr.(*dns.TXT); ok {
+			// a TXT record can consist of multiple strings, which are concatenated
+			txts = append(txts, strings.Join(rr.Txt, ""))
+		}
+	}
+	if len(txts) == 0 {
+		return nil, 0, notFound(name)
+	}
+	return txts, ttl, nil
+}
+
+// serverExchanger sends queries to DNS servers, using UDP and falling back to
+// TCP for truncated responses.
+type serverExchanger struct {
+	servers []string
+	udp     *dns.Client
+	tcp     *dns.Client
+}
+
+func newServerExchanger(servers []string) *serverExchanger {
+	return &serverExchanger{
+		servers: servers,
+		udp:     &dns.Client{Net: "udp"},
+		tcp:     &dns.Client{Net: "tcp"},
+	}
+}
+
+func (e *serverExchanger) exchange(ctx context.Context, m *dns.Msg) (*dns.Msg, error) {
+	var lastErr error
+	for _, s := range e.servers {
+		resp, _, err := e.udp.ExchangeContext(ctx, m, s)
+		if err == nil && resp.Truncated {
+			resp, _, err = e.tcp.ExchangeContext(ctx, m, s)
+		}
+		if err != nil {
+			lastErr = err
+			if ctx.Err() != nil {
+				break
+			}
+			continue
+		}
+		return resp, nil
+	}
+	return nil, lastErr
+}
+
+// dohExchanger sends queries to a DNS over HTTPS endpoint.
+type dohExchanger struct {
+	url    string
+	client *http.Client
+}
+
+const dohMediaType = "application/dns-message"
+
+func (e *dohExchanger) exchange(ctx context.Context, m *dns.Msg) (*dns.Msg, error) {
+	// RFC 8484 recommends using an ID of 0 to make responses cacheable.
+	m = m.Copy()
+	m.Id = 0
+	b, err := m.Pack()
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", dohMediaType)
+	req.Header.Set("Accept", dohMediaType)
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("DoH server returned status %s", resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, dns.MaxMsgSize))
+	if err != nil {
+		return nil, err
+	}
+	r := new(dns.Msg)
+	if err := r.Unpack(body); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
@@ -0,0 +1,82 @@
+package framing
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Chunk splits msg into messages of at most maxSize bytes, that can be merged
+// back into msg (e.g. using proto.Merge).
+//
+// The elements of repeated and map fields are spread across the chunks. All
+// other fields are sent in exactly one chunk. Chunk fails if a single field or
+// element exceeds maxSize. The chunks share memory with msg.
+func Chunk(msg proto.Message, maxSize int) ([]proto.Message, error) {
+	if proto.Size(msg) <= maxSize {
+		return []proto.Message{msg}, nil
+	}
+
+	m := msg.ProtoReflect()
+	var (
+		chunks []proto.Message
+		cur    protoreflect.Message
+		size   int
+	)
+	// add adds a field (or an element of a field) to the current chunk, or
+	// starts a new chunk if it doesn't fit. single is a message containing
+	// only that field. The encoded size of a message is at most the sum of
+	// the sizes of its fields.
+	add := func(single protoreflect.Message, set func(protoreflect.Message)) error {
+		n := proto.Size(single.Interface())
+		if n > maxSize {
+			return fmt.Errorf("%w: field of %d bytes, maximum is %d", ErrMsgTooLarge, n, maxSize)
+		}
+		if cur == nil || size+n > maxSize {
+			cur = m.New()
+			chunks = append(chunks, cur.Interface())
+			size = 0
+		}
+		set(cur)
+		size += n
+		return nil
+	}
+
+	var err error
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		switch {
+		case fd.IsList():
+			l := v.List()
+			for i := 0; i < l.Len() && err == nil; i++ {
+				e := l.Get(i)
+				single := m.New()
+				single.Mutable(fd).List().Append(e)
+				err = add(single, func(c protoreflect.Message) { c.Mutable(fd).List().Append(e) })
+			}
+		case fd.IsMap():
+			v.Map().Range(func(k protoreflect.MapKey, e protoreflect.Value) bool {
+				single := m.New()
+				single.Mutable(fd).Map().Set(k, e)
+				err = add(single, func(c protoreflect.Message) { c.Mutable(fd).Map().Set(k, e) })
+				return err == nil
+			})
+		default:
+			single := m.New()
+			single.Set(fd, v)
+			err = add(single, func(c protoreflect.Message) { c.Set(fd, v) })
+		}
+		return err == nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if unknown := m.GetUnknown(); len(unknown) > 0 {
+		single := m.New()
+		single.SetUnknown(unknown)
+		if err := add(single, func(c protoreflect.Message) { c.SetUnknown(append(c.GetUnknown(), unknown...)) }); err != nil {
+			return nil, err
+		}
+	}
+	return chunks, nil
+}
@@ -0,0 +1,204 @@
+// Package framing implements length-prefixed message framing on top of libp2p
+// streams.
+//
+// Every message is prefixed with its length, encoded as an unsigned varint.
+// This is compatible with the varint framing of go-msgio and with the
+// delimited readers and writers of go-msgio/pbio.
+//
+// Readers and writers reserve the memory they need for buffering messages in
+// the resource scope of the stream, and check the length of every message
+// against a maximum before reading it. This prevents peers from making us
+// allocate arbitrary amounts of memory.
+//
+// Protobuf messages that exceed the maximum message size can be split into
+// multiple chunks using Writer.WriteChunked, and reassembled on the other side
+// using Reader.ReadChunked. This relies on protobuf merge semantics: repeated
+// fields are concatenated, and all other fields are sent in exactly one chunk.
+package framing
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/libp2p/go-libp2p/core/network"
+
+	pool "github.com/libp2p/go-buffer-pool"
+	"github.com/multiformats/go-varint"
+	"google.golang.org/protobuf/proto"
+)
+
+// ErrMsgTooLarge is returned when reading or writing a message that exceeds
+// the maximum message size.
+var ErrMsgTooLarge = errors.New("message too large")
+
+// Reader reads length-prefixed messages from a stream.
+//
+// Reads are unbuffered: a Reader never consumes more data than the messages
+// it returns, so the stream can be handed over to a different protocol
+// afterwards.
+type Reader struct {
+	r     io.Reader
+	scope network.ResourceScope
+	buf   []byte
+}
+
+// NewReader creates a Reader for messages of up to maxMsgSize bytes.
+// It reserves maxMsgSize bytes in the resource scope of the stream, which are
+// released when the Reader is closed.
+func NewReader(s network.Stream, maxMsgSize int) (*Reader, error) {
+	if maxMsgSize <= 0 {
+		return nil, errors.New("maximum message size must be positive")
+	}
+	if err := s.Scope().ReserveMemory(maxMsgSize, network.ReservationPriorityAlways); err != nil {
+		return nil, fmt.Errorf("failed to reserve memory for reader: %w", err)
+	}
+	return &Reader{r: s, scope: s.Scope(), buf: pool.Get(maxMsgSize)}, nil
+}
+
+func (r *Reader) ReadByte() (byte, error) {
+	buf := r.buf[:1]
+	if _, err := io.ReadFull(r.r, buf); err != nil {
+		return 0, err
+	}
+	return buf[0], nil
+}
+
+// ReadMsg reads the next message. The returned slice is only valid until the
+// next call to ReadMsg, or until the Reader is closed.
+// It returns io.EOF if the stream was closed before the next message.
+func (r *Reader) ReadMsg() ([]byte, error) {
+	length, err := varint.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	if length > uint64(len(r.buf)) {
+		return nil, fmt.Errorf("%w: %d bytes, maximum is %d", ErrMsgTooLarge, length, len(r.buf))
+	}
+	buf := r.buf[:length]
+	if _, err := io.ReadFull(r.r, buf); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return buf, nil
+}
+
+// ReadProto reads the next message into msg.
+func (r *Reader) ReadProto(msg proto.Message) error {
+	b, err := r.ReadMsg()
+	if err != nil {
+		return err
+	}
+	return proto.Unmarshal(b, msg)
+}
+
+// ReadChunked reads messages until the stream is closed, and merges them into
+// msg. It is the counterpart of Writer.WriteChunked. It fails if the peer sends
+// more than maxChunks messages.
+func (r *Reader) ReadChunked(msg proto.Message, maxChunks int) error {
+	proto.Reset(msg)
+	for i := 0; i < maxChunks; i++ {
+		b, err := r.ReadMsg()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := (proto.UnmarshalOptions{Merge: true}).Unmarshal(b, msg); err != nil {
+			return err
+		}
+	}
+	// make sure the peer is done sending
+	if _, err := r.ReadMsg(); err != io.EOF {
+		return fmt.Errorf("too many chunks, maximum is %d", maxChunks)
+	}
+	return nil
+}
+
+// Close releases the memory reserved for the Reader. It doesn't close the stream.
+func (r *Reader) Close() {
+	if r.buf == nil {
+		return
+	}
+	r.scope.ReleaseMemory(len(r.buf))
+	pool.Put(r.buf)
+	r.buf = nil
+}
+
+// Writer writes length-prefixed messages to a stream.
+type Writer struct {
+	w          io.Writer
+	scope      network.ResourceScope
+	maxMsgSize int
+	buf        []byte
+}
+
+// NewWriter creates a Writer for messages of up to maxMsgSize bytes.
+// It reserves the memory for encoding messages in the resource scope of the
+// stream, which is released when the Writer is closed.
+func NewWriter(s network.Stream, maxMsgSize int) (*Writer, error) {
+	if maxMsgSize <= 0 {
+		return nil, errors.New("maximum message size must be positive")
+	}
+	size := maxMsgSize + varint.MaxLenUvarint63
+	if err := s.Scope().ReserveMemory(size, network.ReservationPriorityAlways); err != nil {
+		return nil, fmt.Errorf("failed to reserve memory for writer: %w", err)
+	}
+	return &Writer{w: s, scope: s.Scope(), maxMsgSize: maxMsgSize, buf: pool.Get(size)}, nil
+}
+
+// WriteMsg writes b as a single message.
+func (w *Writer) WriteMsg(b []byte) error {
+	if len(b) > w.maxMsgSize {
+		return fmt.Errorf("%w: %d bytes, maximum is %d", ErrMsgTooLarge, len(b), w.maxMsgSize)
+	}
+	n := varint.PutUvarint(w.buf, uint64(len(b)))
+	n += copy(w.buf[n:], b)
+	_, err := w.w.Write(w.buf[:n])
+	return err
+}
+
+// WriteProto writes msg as a single message.
+func (w *Writer) WriteProto(msg proto.Message) error {
+	size := proto.Size(msg)
+	if size > w.maxMsgSize {
+		return fmt.Errorf("%w: %d bytes, maximum is %d", ErrMsgTooLarge, size, w.maxMsgSize)
+	}
+	n := varint.PutUvarint(w.buf, uint64(size))
+	b, err := proto.MarshalOptions{}.MarshalAppend(w.buf[:n], msg)
+	if err != nil {
+		return err
+	}
+	_, err = w.w.Write(b)
+	return err
+}
+
+// WriteChunked writes msg, splitting it into multiple messages if it exceeds
+// the maximum message size. The peer reads it using Reader.ReadChunked, which
+// reads until the stream is closed, so the stream must be closed for writing
+// after the message was written.
+func (w *Writer) WriteChunked(msg proto.Message) error {
+	chunks, err := Chunk(msg, w.maxMsgSize)
+	if err != nil {
+		return err
+	}
+	for _, c := range chunks {
+		if err := w.WriteProto(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close releases the memory reserved for the Writer. It doesn't close the stream.
+func (w *Writer) Close() {
+	if w.buf == nil {
+		return
+	}
+	w.scope.ReleaseMemory(len(w.buf))
+	pool.Put(w.buf)
+	w.buf = nil
+}
@@ -0,0 +1,175 @@
+package framing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer/pb"
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+// streamPair returns the two ends of a stream.
+func streamPair(t *testing.T) (network.Stream, network.Stream) {
+	mn, err := mocknet.FullMeshConnected(2)
+	require.NoError(t, err)
+	t.Cleanup(func() { mn.Close() })
+	h1, h2 := mn.Hosts()[0], mn.Hosts()[1]
+
+	accepted := make(chan network.Stream, 1)
+	h2.SetStreamHandler("/test", func(s network.Stream) { accepted <- s })
+	s, err := h1.NewStream(context.Background(), h2.ID(), "/test")
+	require.NoError(t, err)
+	// the stream is only accepted once we write to it
+	w, err := NewWriter(s, 10)
+	require.NoError(t, err)
+	defer w.Close()
+	require.NoError(t, w.WriteMsg([]byte("hello")))
+
+	remote := <-accepted
+	r, err := NewReader(remote, 10)
+	require.NoError(t, err)
+	defer r.Close()
+	b, err := r.ReadMsg()
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(b))
+	return s, remote
+}
+
+func TestReadWrite(t *testing.T) {
+	local, remote := streamPair(t)
+
+	w, err := NewWriter(local, 100)
+	require.NoError(t, err)
+	defer w.Close()
+	r, err := NewReader(remote, 100)
+	require.NoError(t, err)
+	defer r.Close()
+
+	go func() {
+		w.WriteMsg([]byte("foo"))
+		w.WriteMsg(nil)
+		w.WriteProto(&pb.PeerRecord{Seq: 42})
+		local.CloseWrite()
+	}()
+
+	b, err := r.ReadMsg()
+	require.NoError(t, err)
+	require.Equal(t, "foo", string(b))
+	b, err = r.ReadMsg()
+	require.NoError(t, err)
+	require.Empty(t, b)
+	var rec pb.PeerRecord
+	require.NoError(t, r.ReadProto(&rec))
+	require.Equal(t, uint64(42), rec.Seq)
+	_, err = r.ReadMsg()
+	require.ErrorIs(t, err, io.EOF)
+}
+
+func TestMsgTooLarge(t *testing.T) {
+	local, remote := streamPair(t)
+
+	w, err := NewWriter(local, 100)
+	require.NoError(t, err)
+	defer w.Close()
+	r, err := NewReader(remote, 10)
+	require.NoError(t, err)
+	defer r.Close()
+
+	require.ErrorIs(t, w.WriteMsg(make([]byte, 101)), ErrMsgTooLarge)
+	require.ErrorIs(t, w.WriteProto(&pb.PeerRecord{PeerId: make([]byte, 100)}), ErrMsgTooLarge)
+
+	go w.WriteMsg(make([]byte, 11))
+	_, err = r.ReadMsg()
+	require.ErrorIs(t, err, ErrMsgTooLarge)
+}
+
+func TestChunked(t *testing.T) {
+	local, remote := streamPair(t)
+
+	const maxMsgSize = 100
+	w, err := NewWriter(local, maxMsgSize)
+	require.NoError(t, err)
+	defer w.Close()
+	r, err := NewReader(remote, maxMsgSize)
+	require.NoError(t, err)
+	defer r.Close()
+
+	rec := &pb.PeerRecord{PeerId: []byte("peer"), Seq: 1}
+	for i := 0; i < 20; i++ {
+		rec.Addresses = append(rec.Addresses, &pb.PeerRecord_AddressInfo{Multiaddr: []byte(fmt.Sprintf("address %d", i))})
+	}
+	require.Greater(t, proto.Size(rec), maxMsgSize)
+	chunks, err := Chunk(rec, maxMsgSize)
+	require.NoError(t, err)
+	require.Greater(t, len(chunks), 1)
+	for _, c := range chunks {
+		require.LessOrEqual(t, proto.Size(c), maxMsgSize)
+	}
+
+	go func() {
+		w.WriteChunked(rec)
+		local.CloseWrite()
+	}()
+	var received pb.PeerRecord
+	require.NoError(t, r.ReadChunked(&received, len(chunks)))
+	require.True(t, proto.Equal(rec, &received))
+}
+
+func TestChunkedTooManyChunks(t *testing.T) {
+	local, remote := streamPair(t)
+
+	w, err := NewWriter(local, 20)
+	require.NoError(t, err)
+	defer w.Close()
+	r, err := NewReader(remote, 20)
+	require.NoError(t, err)
+	defer r.Close()
+
+	rec := &pb.PeerRecord{}
+	for i := 0; i < 10; i++ {
+		rec.Addresses = append(rec.Addresses, &pb.PeerRecord_AddressInfo{Multiaddr: make([]byte, 10)})
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		w.WriteChunked(rec)
+		local.CloseWrite()
+	}()
+	require.Error(t, r.ReadChunked(&pb.PeerRecord{}, 2))
+	// unblock the writer, and wait for it before closing it
+	remote.Reset()
+	<-done
+}
+
+func TestChunkFieldTooLarge(t *testing.T) {
+	_, err := Chunk(&pb.PeerRecord{PeerId: make([]byte, 100)}, 50)
+	require.ErrorIs(t, err, ErrMsgTooLarge)
+}
+
+type denyingScope struct {
+	network.NullScope
+}
+
+func (s *denyingScope) ReserveMemory(int, uint8) error { return network.ErrResourceLimitExceeded }
+
+type denyingStream struct {
+	network.Stream
+}
+
+func (s *denyingStream) Scope() network.StreamScope { return &denyingScope{} }
+
+func TestReserveMemory(t *testing.T) {
+	local, remote := streamPair(t)
+
+	_, err := NewWriter(&denyingStream{Stream: local}, 100)
+	require.True(t, errors.Is(err, network.ErrResourceLimitExceeded))
+	_, err = NewReader(&denyingStream{Stream: remote}, 100)
+	require.True(t, errors.Is(err, network.ErrResourceLimitExceeded))
+}
@@ -78,11 +78,30 @@ type Mocknet interface {
 }
 
 // LinkOptions are used to change aspects of the links.
-// Sorry but they dont work yet :(
+// They can be changed at runtime using Link.SetOptions; the new options apply
+// to all data written after the change.
 type LinkOptions struct {
-	Latency   time.Duration
-	Bandwidth float64 // in bytes-per-second
-	// we can make these values distributions down the road.
+	// Latency is the one-way delay applied to every write.
+	Latency time.Duration
+	// Jitter is the maximum random deviation from Latency. Each write is
+	// delayed by a value drawn uniformly from [Latency-Jitter, Latency+Jitter].
+	// Writes on a stream are never reordered.
+	Jitter time.Duration
+	// Bandwidth is the link capacity, in bytes-per-second. 0 means unlimited.
+	Bandwidth float64
+	// PacketLoss is the probability (0 to 1) that a write is lost and has to be
+	// retransmitted. Streams are reliable, so a lost write is delayed by
+	// RetransmitTimeout rather than discarded.
+	PacketLoss float64
+	// RetransmitTimeout is the delay added every time a write is lost.
+	// Defaults to twice the Latency, or 10ms if no latency is configured.
+	RetransmitTimeout time.Duration
+	// StreamDropRate is the probability (0 to 1) that opening a new stream
+	// fails with ErrStreamDropped.
+	StreamDropRate float64
+	// Seed seeds the random number generator used for jitter and loss, so
+	// that simulations are reproducible.
+	Seed int64
 }
 
 // Link represents the **possibility** of a connection between
@@ -139,6 +139,9 @@ func (c *conn) openStream() *stream {
 func (c *conn) NewStream(context.Context) (network.Stream, error) {
 	log.Debugf("Conn.NewStreamWithProtocol: %s --> %s", c.local, c.remote)
 
+	if c.link.dropStream() {
+		return nil, ErrStreamDropped
+	}
 	s := c.openStream()
 	return s, nil
 }
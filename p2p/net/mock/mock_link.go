@@ -1,6 +1,8 @@
 package mocknet
 
 import (
+	"errors"
+	"math/rand"
 	"sync"
 	"time"
 
@@ -8,6 +10,14 @@ import (
 	"github.com/libp2p/go-libp2p/core/peer"
 )
 
+// ErrStreamDropped is returned when opening a stream fails because of the
+// link's StreamDropRate.
+var ErrStreamDropped = errors.New("stream dropped by link")
+
+// maxRetransmits bounds the number of retransmissions of a single write, so
+// that a PacketLoss of 1 doesn't stall a stream forever.
+const maxRetransmits = 16
+
 // link implements mocknet.Link
 // and, for simplicity, network.Conn
 type link struct {
@@ -15,6 +25,7 @@ type link struct {
 	nets        []*peernet
 	opts        LinkOptions
 	ratelimiter *RateLimiter
+	rng         *rand.Rand
 	// this could have addresses on both sides.
 
 	sync.RWMutex
@@ -23,7 +34,8 @@ type link struct {
 func newLink(mn *mocknet, opts LinkOptions) *link {
 	l := &link{mock: mn,
 		opts:        opts,
-		ratelimiter: NewRateLimiter(opts.Bandwidth)}
+		ratelimiter: NewRateLimiter(opts.Bandwidth),
+		rng:         rand.New(rand.NewSource(opts.Seed))}
 	return l
 }
 
@@ -67,6 +79,9 @@ func (l *link) Peers() []peer.ID {
 func (l *link) SetOptions(o LinkOptions) {
 	l.Lock()
 	defer l.Unlock()
+	if o.Seed != l.opts.Seed {
+		l.rng = rand.New(rand.NewSource(o.Seed))
+	}
 	l.opts = o
 	l.ratelimiter.UpdateBandwidth(l.opts.Bandwidth)
 }
@@ -86,3 +101,38 @@ func (l *link) GetLatency() time.Duration {
 func (l *link) RateLimit(dataSize int) time.Duration {
 	return l.ratelimiter.Limit(dataSize)
 }
+
+// Delay returns how long a write of dataSize bytes takes to arrive at the
+// other end of the link, taking latency, jitter, packet loss and bandwidth
+// into account.
+func (l *link) Delay(dataSize int) time.Duration {
+	l.Lock()
+	delay := l.opts.Latency
+	if l.opts.Jitter > 0 {
+		delay += time.Duration(l.rng.Int63n(int64(2*l.opts.Jitter)+1)) - l.opts.Jitter
+		if delay < 0 {
+			delay = 0
+		}
+	}
+	if l.opts.PacketLoss > 0 {
+		rto := l.opts.RetransmitTimeout
+		if rto <= 0 {
+			rto = 2 * l.opts.Latency
+		}
+		if rto <= 0 {
+			rto = 10 * time.Millisecond
+		}
+		for i := 0; i < maxRetransmits && l.rng.Float64() < l.opts.PacketLoss; i++ {
+			delay += rto
+		}
+	}
+	l.Unlock()
+	return delay + l.RateLimit(dataSize)
+}
+
+// dropStream reports whether a new stream should be dropped.
+func (l *link) dropStream() bool {
+	l.Lock()
+	defer l.Unlock()
+	return l.opts.StreamDropRate > 0 && l.rng.Float64() < l.opts.StreamDropRate
+}
@@ -6,6 +6,7 @@ import (
 	"io"
 	"net"
 	"strconv"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -31,6 +32,9 @@ type stream struct {
 
 	writeErr error
 
+	arrivalMx   sync.Mutex
+	lastArrival time.Time
+
 	protocol atomic.Pointer[protocol.ID]
 	stat     network.Stats
 }
@@ -71,9 +75,7 @@ func newStream(w *io.PipeWriter, r *io.PipeReader, dir network.Direction) *strea
 
 // How to handle errors with writes?
 func (s *stream) Write(p []byte) (n int, err error) {
-	l := s.conn.link
-	delay := l.GetLatency() + l.RateLimit(len(p))
-	t := time.Now().Add(delay)
+	t := s.arrivalTime(len(p))
 
 	// Copy it.
 	cpy := make([]byte, len(p))
@@ -87,6 +89,21 @@ func (s *stream) Write(p []byte) (n int, err error) {
 	return len(p), nil
 }
 
+// arrivalTime computes when a write of size bytes arrives at the remote end.
+// Jitter may produce a smaller delay than for a previous write, but data on a
+// stream is delivered in order, so a write never arrives before its predecessor.
+func (s *stream) arrivalTime(size int) time.Time {
+	t := time.Now().Add(s.conn.link.Delay(size))
+
+	s.arrivalMx.Lock()
+	defer s.arrivalMx.Unlock()
+	if t.Before(s.lastArrival) {
+		t = s.lastArrival
+	}
+	s.lastArrival = t
+	return t
+}
+
 func (s *stream) ID() string {
 	return strconv.FormatInt(s.id, 10)
 }
@@ -605,6 +605,100 @@ func TestStreamsWithLatency(t *testing.T) {
 	}
 }
 
+func TestLinkJitterAndLoss(t *testing.T) {
+	opts := LinkOptions{
+		Latency:           50 * time.Millisecond,
+		Jitter:            10 * time.Millisecond,
+		PacketLoss:        0.3,
+		RetransmitTimeout: 100 * time.Millisecond,
+		Seed:              42,
+	}
+	l1 := newLink(nil, opts)
+	l2 := newLink(nil, opts)
+
+	var lost int
+	for i := 0; i < 1000; i++ {
+		d := l1.Delay(0)
+		// same seed, same sequence of delays
+		require.Equal(t, d, l2.Delay(0))
+
+		retransmits := (d - 40*time.Millisecond) / opts.RetransmitTimeout
+		jittered := d - retransmits*opts.RetransmitTimeout
+		require.GreaterOrEqual(t, jittered, 40*time.Millisecond)
+		require.LessOrEqual(t, jittered, 60*time.Millisecond)
+		if retransmits > 0 {
+			lost++
+		}
+	}
+	require.InDelta(t, 300, lost, 60)
+
+	// changing the options at runtime takes effect immediately
+	l1.SetOptions(LinkOptions{Latency: time.Second})
+	require.Equal(t, time.Second, l1.Delay(0))
+}
+
+func TestStreamDropRate(t *testing.T) {
+	mn, err := WithNPeers(2)
+	require.NoError(t, err)
+	defer mn.Close()
+
+	require.NoError(t, mn.LinkAll())
+	require.NoError(t, mn.ConnectAllButSelf())
+
+	h1, h2 := mn.Hosts()[0], mn.Hosts()[1]
+	h2.SetStreamHandler(protocol.TestingID, func(s network.Stream) { s.Close() })
+
+	for _, l := range mn.LinksBetweenPeers(h1.ID(), h2.ID()) {
+		l.SetOptions(LinkOptions{StreamDropRate: 1})
+	}
+	_, err = h1.NewStream(context.Background(), h2.ID(), protocol.TestingID)
+	require.ErrorIs(t, err, ErrStreamDropped)
+
+	for _, l := range mn.LinksBetweenPeers(h1.ID(), h2.ID()) {
+		l.SetOptions(LinkOptions{})
+	}
+	s, err := h1.NewStream(context.Background(), h2.ID(), protocol.TestingID)
+	require.NoError(t, err)
+	s.Close()
+}
+
+func TestStreamOrderingWithJitter(t *testing.T) {
+	mn, err := WithNPeers(2)
+	require.NoError(t, err)
+	defer mn.Close()
+
+	mn.SetLinkDefaults(LinkOptions{Latency: 20 * time.Millisecond, Jitter: 20 * time.Millisecond, Seed: 1})
+	require.NoError(t, mn.LinkAll())
+	require.NoError(t, mn.ConnectAllButSelf())
+
+	h1, h2 := mn.Hosts()[0], mn.Hosts()[1]
+	const n = 100
+	done := make(chan []byte, 1)
+	h2.SetStreamHandler(protocol.TestingID, func(s network.Stream) {
+		defer s.Close()
+		b, _ := io.ReadAll(s)
+		done <- b
+	})
+
+	s, err := h1.NewStream(context.Background(), h2.ID(), protocol.TestingID)
+	require.NoError(t, err)
+	for i := 0; i < n; i++ {
+		_, err := s.Write([]byte{byte(i)})
+		require.NoError(t, err)
+	}
+	require.NoError(t, s.CloseWrite())
+
+	select {
+	case b := <-done:
+		require.Len(t, b, n)
+		for i := 0; i < n; i++ {
+			require.Equal(t, byte(i), b[i])
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("timeout")
+	}
+}
+
 func TestEventBus(t *testing.T) {
 	const peers = 2
 
@@ -30,11 +30,128 @@ type entry struct {
 	port     int
 }
 
+// mapping is the state of a single port mapping.
+type mapping struct {
+	externalPort int // 0 if the mapping could not be established
+	renewed      time.Time
+	expiry       time.Time // zero if the mapping doesn't expire
+}
+
+// Mapping describes a port mapping on the NAT device.
+type Mapping struct {
+	// Protocol is either "tcp" or "udp".
+	Protocol string
+	// InternalPort is the local port that is mapped.
+	InternalPort int
+	// External is the external address and port of the mapping.
+	// It is invalid if the NAT device didn't grant the mapping (yet).
+	External netip.AddrPort
+	// Gateway is the address of the NAT device, if known.
+	Gateway netip.Addr
+	// Renewed is the last time the mapping was (attempted to be) established.
+	Renewed time.Time
+	// Expiry is the time the lease of the mapping expires.
+	// It is zero if the NAT device granted a permanent mapping.
+	Expiry time.Time
+}
+
+// MappingEventType is the type of a MappingEvent.
+type MappingEventType int
+
+const (
+	// MappingCreated is emitted when a mapping was established,
+	// either for the first time or after it was lost.
+	MappingCreated MappingEventType = iota
+	// MappingRenewed is emitted when the lease of an existing mapping was renewed.
+	MappingRenewed
+	// MappingLost is emitted when a mapping couldn't be established or renewed.
+	// It is emitted once, not again for every failed renewal until the mapping
+	// is created again.
+	MappingLost
+	// MappingRemoved is emitted when a mapping was removed by us.
+	MappingRemoved
+)
+
+func (t MappingEventType) String() string {
+	switch t {
+	case MappingCreated:
+		return "created"
+	case MappingRenewed:
+		return "renewed"
+	case MappingLost:
+		return "lost"
+	case MappingRemoved:
+		return "removed"
+	default:
+		return "unknown"
+	}
+}
+
+// MappingEvent notifies about a change of a port mapping.
+type MappingEvent struct {
+	Type    MappingEventType
+	Mapping Mapping
+}
+
+type config struct {
+	mappingDuration time.Duration
+	renewInterval   time.Duration
+	notify          func(MappingEvent)
+}
+
+// Option is an option for DiscoverNAT.
+type Option func(*config) error
+
+// WithMappingDuration sets the lease duration requested for port mappings.
+// Defaults to MappingDuration.
+func WithMappingDuration(d time.Duration) Option {
+	return func(c *config) error {
+		if d <= 0 {
+			return errors.New("mapping duration must be positive")
+		}
+		c.mappingDuration = d
+		return nil
+	}
+}
+
+// WithRenewalInterval sets how often port mappings are renewed.
+// Defaults to a third of the mapping duration. The interval should be
+// shorter than the mapping duration, otherwise mappings will expire
+// before they are renewed.
+func WithRenewalInterval(d time.Duration) Option {
+	return func(c *config) error {
+		if d <= 0 {
+			return errors.New("renewal interval must be positive")
+		}
+		c.renewInterval = d
+		return nil
+	}
+}
+
+// WithMappingNotifier sets a function that is called every time a port mapping
+// is created, renewed, lost or removed. The function must not block.
+func WithMappingNotifier(f func(MappingEvent)) Option {
+	return func(c *config) error {
+		c.notify = f
+		return nil
+	}
+}
+
 // so we can mock it in tests
 var discoverGateway = nat.DiscoverGateway
 
 // DiscoverNAT looks for a NAT device in the network and returns an object that can manage port mappings.
-func DiscoverNAT(ctx context.Context) (*NAT, error) {
+func DiscoverNAT(ctx context.Context, opts ...Option) (*NAT, error) {
+	cfg := config{mappingDuration: MappingDuration}
+	for _, opt := range opts {
+		if err := opt(&cfg); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.renewInterval == 0 {
+		cfg.renewInterval = cfg.mappingDuration / 3
+	}
+
 	natInstance, err := discoverGateway(ctx)
 	if err != nil {
 		return nil, err
@@ -43,21 +160,27 @@ func DiscoverNAT(ctx context.Context) (*NAT, error) {
 	extIP, err := natInstance.GetExternalAddress()
 	if err == nil {
 		extAddr, _ = netip.AddrFromSlice(extIP)
+		extAddr = extAddr.Unmap()
 	}
 
 	// Log the device addr.
+	var gateway netip.Addr
 	addr, err := natInstance.GetDeviceAddress()
 	if err != nil {
 		log.Debug("DiscoverGateway address error:", err)
 	} else {
 		log.Debug("DiscoverGateway address:", addr)
+		gateway, _ = netip.AddrFromSlice(addr)
+		gateway = gateway.Unmap()
 	}
 
 	ctx, cancel := context.WithCancel(context.Background())
 	nat := &NAT{
 		nat:       natInstance,
 		extAddr:   extAddr,
-		mappings:  make(map[entry]int),
+		gateway:   gateway,
+		cfg:       cfg,
+		mappings:  make(map[entry]mapping),
 		ctx:       ctx,
 		ctxCancel: cancel,
 	}
@@ -78,6 +201,9 @@ type NAT struct {
 	nat   nat.NAT
 	// External IP of the NAT. Will be renewed periodically (every CacheTime).
 	extAddr netip.Addr
+	gateway netip.Addr
+
+	cfg config
 
 	refCount  sync.WaitGroup
 	ctx       context.Context
@@ -85,7 +211,7 @@ type NAT struct {
 
 	mappingmu sync.RWMutex // guards mappings
 	closed    bool
-	mappings  map[entry]int
+	mappings  map[entry]mapping
 }
 
 // Close shuts down all port mappings. NAT can no longer be used.
@@ -106,11 +232,65 @@ func (nat *NAT) GetMapping(protocol string, port int) (addr netip.AddrPort, foun
 	if !nat.extAddr.IsValid() {
 		return netip.AddrPort{}, false
 	}
-	extPort, found := nat.mappings[entry{protocol: protocol, port: port}]
+	m, found := nat.mappings[entry{protocol: protocol, port: port}]
 	if !found {
 		return netip.AddrPort{}, false
 	}
-	return netip.AddrPortFrom(nat.extAddr, uint16(extPort)), true
+	return netip.AddrPortFrom(nat.extAddr, uint16(m.externalPort)), true
+}
+
+// Mappings returns all port mappings currently managed by the NAT,
+// including the ones that the NAT device didn't grant.
+func (nat *NAT) Mappings() []Mapping {
+	nat.mappingmu.RLock()
+	defer nat.mappingmu.RUnlock()
+
+	mappings := make([]Mapping, 0, len(nat.mappings))
+	for e, m := range nat.mappings {
+		mappings = append(mappings, nat.toMapping(e, m))
+	}
+	return mappings
+}
+
+// toMapping must be called with mappingmu held.
+func (nat *NAT) toMapping(e entry, m mapping) Mapping {
+	mp := Mapping{
+		Protocol:     e.protocol,
+		InternalPort: e.port,
+		Gateway:      nat.gateway,
+		Renewed:      m.renewed,
+		Expiry:       m.expiry,
+	}
+	if m.externalPort != 0 && nat.extAddr.IsValid() {
+		mp.External = netip.AddrPortFrom(nat.extAddr, uint16(m.externalPort))
+	}
+	return mp
+}
+
+func (nat *NAT) notify(typ MappingEventType, m Mapping) {
+	if nat.cfg.notify != nil {
+		nat.cfg.notify(MappingEvent{Type: typ, Mapping: m})
+	}
+}
+
+// updateMapping must be called with mappingmu held.
+// It returns the event that should be emitted for this update, if any.
+func (nat *NAT) updateMapping(e entry, newMapping mapping, existed bool, old mapping) (MappingEvent, bool) {
+	nat.mappings[e] = newMapping
+	var typ MappingEventType
+	switch {
+	case newMapping.externalPort == 0:
+		if existed && old.externalPort == 0 {
+			// already lost
+			return MappingEvent{}, false
+		}
+		typ = MappingLost
+	case existed && old.externalPort == newMapping.externalPort:
+		typ = MappingRenewed
+	default:
+		typ = MappingCreated
+	}
+	return MappingEvent{Type: typ, Mapping: nat.toMapping(e, newMapping)}, true
 }
 
 // AddMapping attempts to construct a mapping on protocol and internal port.
@@ -126,40 +306,51 @@ func (nat *NAT) AddMapping(ctx context.Context, protocol string, port int) error
 	}
 
 	nat.mappingmu.Lock()
-	defer nat.mappingmu.Unlock()
-
 	if nat.closed {
+		nat.mappingmu.Unlock()
 		return errors.New("closed")
 	}
 
 	// do it once synchronously, so first mapping is done right away, and before exiting,
 	// allowing users -- in the optimistic case -- to use results right after.
-	extPort := nat.establishMapping(ctx, protocol, port)
-	nat.mappings[entry{protocol: protocol, port: port}] = extPort
+	e := entry{protocol: protocol, port: port}
+	old, existed := nat.mappings[e]
+	evt, ok := nat.updateMapping(e, nat.establishMapping(ctx, protocol, port), existed, old)
+	nat.mappingmu.Unlock()
+
+	if ok {
+		nat.notify(evt.Type, evt.Mapping)
+	}
 	return nil
 }
 
 // RemoveMapping removes a port mapping.
 // It blocks until the NAT has removed the mapping.
 func (nat *NAT) RemoveMapping(ctx context.Context, protocol string, port int) error {
-	nat.mappingmu.Lock()
-	defer nat.mappingmu.Unlock()
-
 	switch protocol {
 	case "tcp", "udp":
-		e := entry{protocol: protocol, port: port}
-		if _, ok := nat.mappings[e]; ok {
-			delete(nat.mappings, e)
-			return nat.nat.DeletePortMapping(ctx, protocol, port)
-		}
-		return errors.New("unknown mapping")
 	default:
 		return fmt.Errorf("invalid protocol: %s", protocol)
 	}
+
+	nat.mappingmu.Lock()
+	e := entry{protocol: protocol, port: port}
+	m, ok := nat.mappings[e]
+	if !ok {
+		nat.mappingmu.Unlock()
+		return errors.New("unknown mapping")
+	}
+	delete(nat.mappings, e)
+	removed := nat.toMapping(e, m)
+	err := nat.nat.DeletePortMapping(ctx, protocol, port)
+	nat.mappingmu.Unlock()
+
+	nat.notify(MappingRemoved, removed)
+	return err
 }
 
 func (nat *NAT) background() {
-	const mappingUpdate = MappingDuration / 3
+	mappingUpdate := nat.cfg.renewInterval
 
 	now := time.Now()
 	nextMappingUpdate := now.Add(mappingUpdate)
@@ -169,13 +360,15 @@ func (nat *NAT) background() {
 	defer t.Stop()
 
 	var in []entry
-	var out []int // port numbers
+	var out []mapping
+	var evts []MappingEvent
 	for {
 		select {
 		case now := <-t.C:
 			if now.After(nextMappingUpdate) {
 				in = in[:0]
 				out = out[:0]
+				evts = evts[:0]
 				nat.mappingmu.Lock()
 				for e := range nat.mappings {
 					in = append(in, e)
@@ -188,12 +381,18 @@ func (nat *NAT) background() {
 				}
 				nat.mappingmu.Lock()
 				for i, p := range in {
-					if _, ok := nat.mappings[p]; !ok {
+					old, ok := nat.mappings[p]
+					if !ok {
 						continue // entry might have been deleted
 					}
-					nat.mappings[p] = out[i]
+					if evt, ok := nat.updateMapping(p, out[i], true, old); ok {
+						evts = append(evts, evt)
+					}
 				}
 				nat.mappingmu.Unlock()
+				for _, evt := range evts {
+					nat.notify(evt.Type, evt.Mapping)
+				}
 				nextMappingUpdate = time.Now().Add(mappingUpdate)
 			}
 			if now.After(nextAddrUpdate) {
@@ -201,8 +400,11 @@ func (nat *NAT) background() {
 				extIP, err := nat.nat.GetExternalAddress()
 				if err == nil {
 					extAddr, _ = netip.AddrFromSlice(extIP)
+					extAddr = extAddr.Unmap()
 				}
+				nat.mappingmu.Lock()
 				nat.extAddr = extAddr
+				nat.mappingmu.Unlock()
 				nextAddrUpdate = time.Now().Add(CacheTime)
 			}
 			t.Reset(time.Until(minTime(nextAddrUpdate, nextMappingUpdate)))
@@ -220,16 +422,18 @@ func (nat *NAT) background() {
 	}
 }
 
-func (nat *NAT) establishMapping(ctx context.Context, protocol string, internalPort int) (externalPort int) {
+func (nat *NAT) establishMapping(ctx context.Context, protocol string, internalPort int) mapping {
 	log.Debugf("Attempting port map: %s/%d", protocol, internalPort)
 	const comment = "libp2p"
 
+	now := time.Now()
 	nat.natmu.Lock()
-	var err error
-	externalPort, err = nat.nat.AddPortMapping(ctx, protocol, internalPort, comment, MappingDuration)
+	lease := nat.cfg.mappingDuration
+	externalPort, err := nat.nat.AddPortMapping(ctx, protocol, internalPort, comment, lease)
 	if err != nil {
 		// Some hardware does not support mappings with timeout, so try that
-		externalPort, err = nat.nat.AddPortMapping(ctx, protocol, internalPort, comment, 0)
+		lease = 0
+		externalPort, err = nat.nat.AddPortMapping(ctx, protocol, internalPort, comment, lease)
 	}
 	nat.natmu.Unlock()
 
@@ -242,11 +446,15 @@ func (nat *NAT) establishMapping(ctx context.Context, protocol string, internalP
 		}
 		// we do not close if the mapping failed,
 		// because it may work again next time.
-		return 0
+		return mapping{renewed: now}
 	}
 
 	log.Debugf("NAT Mapping: %d --> %d (%s)", externalPort, internalPort, protocol)
-	return externalPort
+	m := mapping{externalPort: externalPort, renewed: now}
+	if lease > 0 {
+		m.expiry = now.Add(lease)
+	}
+	return m
 }
 
 func minTime(a, b time.Time) time.Time {
@@ -5,7 +5,9 @@ import (
 	"errors"
 	"net"
 	"net/netip"
+	"sort"
 	"testing"
+	"time"
 
 	"github.com/libp2p/go-nat"
 
@@ -67,3 +69,57 @@ func TestRemoveMapping(t *testing.T) {
 	_, found = nat.GetMapping("tcp", 10000)
 	require.False(t, found, "didn't expect port mapping for deleted mapping")
 }
+
+func TestMappingsAndEvents(t *testing.T) {
+	mockNAT, reset := setupMockNAT(t)
+	defer reset()
+
+	var evts []MappingEvent
+	mockNAT.EXPECT().GetExternalAddress().Return(net.IPv4(1, 2, 3, 4), nil)
+	nat, err := DiscoverNAT(context.Background(),
+		WithMappingDuration(time.Hour),
+		WithMappingNotifier(func(e MappingEvent) { evts = append(evts, e) }),
+	)
+	require.NoError(t, err)
+	defer nat.Close()
+
+	mockNAT.EXPECT().AddPortMapping(gomock.Any(), "tcp", 10000, gomock.Any(), time.Hour).Return(1234, nil)
+	require.NoError(t, nat.AddMapping(context.Background(), "tcp", 10000))
+	mockNAT.EXPECT().AddPortMapping(gomock.Any(), "udp", 10000, gomock.Any(), time.Hour).Return(0, errors.New("nope"))
+	mockNAT.EXPECT().AddPortMapping(gomock.Any(), "udp", 10000, gomock.Any(), time.Duration(0)).Return(0, errors.New("nope"))
+	require.NoError(t, nat.AddMapping(context.Background(), "udp", 10000))
+
+	mappings := nat.Mappings()
+	require.Len(t, mappings, 2)
+	sort.Slice(mappings, func(i, j int) bool { return mappings[i].Protocol < mappings[j].Protocol })
+	require.Equal(t, "tcp", mappings[0].Protocol)
+	require.Equal(t, 10000, mappings[0].InternalPort)
+	require.Equal(t, netip.AddrPortFrom(netip.AddrFrom4([4]byte{1, 2, 3, 4}), 1234), mappings[0].External)
+	require.WithinDuration(t, time.Now().Add(time.Hour), mappings[0].Expiry, time.Minute)
+	require.Equal(t, "udp", mappings[1].Protocol)
+	require.False(t, mappings[1].External.IsValid())
+
+	require.Len(t, evts, 2)
+	require.Equal(t, MappingCreated, evts[0].Type)
+	require.Equal(t, MappingLost, evts[1].Type)
+
+	// failing to renew a lost mapping doesn't emit another event
+	mockNAT.EXPECT().AddPortMapping(gomock.Any(), "udp", 10000, gomock.Any(), time.Hour).Return(0, errors.New("nope"))
+	mockNAT.EXPECT().AddPortMapping(gomock.Any(), "udp", 10000, gomock.Any(), time.Duration(0)).Return(0, errors.New("nope"))
+	require.NoError(t, nat.AddMapping(context.Background(), "udp", 10000))
+	require.Len(t, evts, 2)
+
+	// renewing the mapping with the same external port
+	mockNAT.EXPECT().AddPortMapping(gomock.Any(), "tcp", 10000, gomock.Any(), time.Hour).Return(1234, nil)
+	require.NoError(t, nat.AddMapping(context.Background(), "tcp", 10000))
+	require.Len(t, evts, 3)
+	require.Equal(t, MappingRenewed, evts[2].Type)
+
+	mockNAT.EXPECT().DeletePortMapping(gomock.Any(), "tcp", 10000)
+	require.NoError(t, nat.RemoveMapping(context.Background(), "tcp", 10000))
+	require.Len(t, evts, 4)
+	require.Equal(t, MappingRemoved, evts[3].Type)
+	require.Equal(t, 10000, evts[3].Mapping.InternalPort)
+
+	mockNAT.EXPECT().DeletePortMapping(gomock.Any(), "udp", 10000).AnyTimes()
+}
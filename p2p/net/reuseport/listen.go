@@ -11,16 +11,47 @@ import (
 type listener struct {
 	manet.Listener
 	network *network
+
+	// closed and dialDisabled are protected by network.mu
+	closed       bool
+	dialDisabled bool
 }
 
 func (l *listener) Close() error {
 	l.network.mu.Lock()
+	l.closed = true
 	delete(l.network.listeners, l)
 	l.network.dialer = nil
 	l.network.mu.Unlock()
 	return l.Listener.Close()
 }
 
+// ReusePort returns whether dials may reuse the port of this listener.
+func (l *listener) ReusePort() bool {
+	l.network.mu.RLock()
+	defer l.network.mu.RUnlock()
+	return !l.dialDisabled
+}
+
+// SetReusePort sets whether dials may reuse the port of this listener. The
+// socket keeps SO_REUSEPORT set, but once disabled, future dials won't be
+// bound to the listener's port.
+func (l *listener) SetReusePort(enable bool) {
+	l.network.mu.Lock()
+	defer l.network.mu.Unlock()
+
+	l.dialDisabled = !enable
+	if l.closed {
+		return
+	}
+	if enable {
+		l.network.listeners[l] = struct{}{}
+	} else {
+		delete(l.network.listeners, l)
+	}
+	l.network.dialer = nil
+}
+
 // Listen listens on the given multiaddr.
 //
 // If reuseport is supported, it will be enabled for this listener and future
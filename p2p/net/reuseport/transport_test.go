@@ -275,3 +275,35 @@ func TestDuplicateGlobal(t *testing.T) {
 		dialOne(t, &trB, listenerA, port)
 	}
 }
+
+func TestSetReusePort(t *testing.T) {
+	var trA Transport
+	var trB Transport
+	listenerA, err := trA.Listen(loopbackV4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listenerA.Close()
+
+	listenerB, err := trB.Listen(loopbackV4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listenerB.Close()
+	portB := listenerB.Addr().(*net.TCPAddr).Port
+
+	l := listenerB.(*listener)
+	if !l.ReusePort() {
+		t.Fatal("expected port reuse to be enabled")
+	}
+	l.SetReusePort(false)
+	if l.ReusePort() {
+		t.Fatal("expected port reuse to be disabled")
+	}
+	if port := dialOne(t, &trB, listenerA); port == portB {
+		t.Fatalf("didn't expect to dial from port %d", portB)
+	}
+
+	l.SetReusePort(true)
+	dialOne(t, &trB, listenerA, portB)
+}
@@ -0,0 +1,80 @@
+package swarm
+
+import (
+	"errors"
+	"net/netip"
+
+	"github.com/libp2p/go-libp2p/core/network"
+
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+// WellKnownNAT64Prefix is the well-known prefix for IPv4/IPv6 translation
+// defined in RFC 6052.
+var WellKnownNAT64Prefix = netip.MustParsePrefix("64:ff9b::/96")
+
+// NAT64Translator is a network.AddrTranslator for hosts on IPv6-only networks
+// that reach IPv4 hosts through a NAT64 gateway. It rewrites public IPv4
+// addresses into IPv6 addresses within the NAT64 prefix when dialing, and
+// translates addresses within the prefix back into IPv4 addresses.
+type NAT64Translator struct {
+	prefix netip.Prefix
+}
+
+var _ network.AddrTranslator = &NAT64Translator{}
+
+// NewNAT64Translator creates a NAT64Translator for the given prefix, usually
+// WellKnownNAT64Prefix. Only /96 prefixes are supported.
+func NewNAT64Translator(prefix netip.Prefix) (*NAT64Translator, error) {
+	if !prefix.Addr().Is6() || prefix.Addr().Is4In6() || prefix.Bits() != 96 {
+		return nil, errors.New("NAT64 prefix must be an IPv6 /96 prefix")
+	}
+	return &NAT64Translator{prefix: prefix.Masked()}, nil
+}
+
+// TranslateDialAddr rewrites addresses starting with a public IPv4 address into
+// the NAT64 prefix. Private IPv4 addresses are not reachable through NAT64, and
+// are returned unchanged, as are all other addresses.
+func (t *NAT64Translator) TranslateDialAddr(addr ma.Multiaddr) []ma.Multiaddr {
+	first, rest := ma.SplitFirst(addr)
+	if first == nil || first.Protocol().Code != ma.P_IP4 {
+		return []ma.Multiaddr{addr}
+	}
+	ip, ok := netip.AddrFromSlice(first.RawValue())
+	if !ok || !ip.IsGlobalUnicast() || ip.IsPrivate() {
+		return []ma.Multiaddr{addr}
+	}
+	b := t.prefix.Addr().As16()
+	ip4 := ip.As4()
+	copy(b[12:], ip4[:])
+	c, err := ma.NewComponent("ip6", netip.AddrFrom16(b).String())
+	if err != nil {
+		return []ma.Multiaddr{addr}
+	}
+	if rest == nil {
+		return []ma.Multiaddr{c}
+	}
+	return []ma.Multiaddr{c.Encapsulate(rest)}
+}
+
+// ReverseTranslate rewrites addresses starting with an IPv6 address within the
+// NAT64 prefix into the IPv4 address they represent.
+func (t *NAT64Translator) ReverseTranslate(addr ma.Multiaddr) ma.Multiaddr {
+	first, rest := ma.SplitFirst(addr)
+	if first == nil || first.Protocol().Code != ma.P_IP6 {
+		return addr
+	}
+	ip, ok := netip.AddrFromSlice(first.RawValue())
+	if !ok || !t.prefix.Contains(ip) {
+		return addr
+	}
+	b := ip.As16()
+	c, err := ma.NewComponent("ip4", netip.AddrFrom4([4]byte(b[12:])).String())
+	if err != nil {
+		return addr
+	}
+	if rest == nil {
+		return c
+	}
+	return c.Encapsulate(rest)
+}
@@ -0,0 +1,69 @@
+package swarm
+
+import (
+	"context"
+	"net/netip"
+	"testing"
+
+	"github.com/libp2p/go-libp2p/core/peerstore"
+	"github.com/libp2p/go-libp2p/core/test"
+
+	ma "github.com/multiformats/go-multiaddr"
+	madns "github.com/multiformats/go-multiaddr-dns"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNAT64Translator(t *testing.T) {
+	tr, err := NewNAT64Translator(WellKnownNAT64Prefix)
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		addr, translated string
+	}{
+		{"/ip4/1.2.3.4/tcp/1", "/ip6/64:ff9b::102:304/tcp/1"},
+		{"/ip4/1.2.3.4", "/ip6/64:ff9b::102:304"},
+		{"/ip4/1.2.3.4/udp/1/quic-v1", "/ip6/64:ff9b::102:304/udp/1/quic-v1"},
+		// private and loopback addresses aren't reachable through NAT64
+		{"/ip4/192.168.1.1/tcp/1", "/ip4/192.168.1.1/tcp/1"},
+		{"/ip4/127.0.0.1/tcp/1", "/ip4/127.0.0.1/tcp/1"},
+		{"/ip6/2001:db8::1/tcp/1", "/ip6/2001:db8::1/tcp/1"},
+		{"/dns4/example.com/tcp/1", "/dns4/example.com/tcp/1"},
+	} {
+		addr := ma.StringCast(tc.addr)
+		translated := tr.TranslateDialAddr(addr)
+		require.Len(t, translated, 1, tc.addr)
+		require.True(t, ma.StringCast(tc.translated).Equal(translated[0]), "expected %s, got %s", tc.translated, translated[0])
+		require.True(t, addr.Equal(tr.ReverseTranslate(translated[0])), tc.addr)
+	}
+
+	// addresses outside of the prefix aren't translated back
+	outside := ma.StringCast("/ip6/64:ff9c::102:304/tcp/1")
+	require.Equal(t, outside, tr.ReverseTranslate(outside))
+
+	for _, prefix := range []string{"64:ff9b::/64", "10.0.0.0/8", "::ffff:0.0.0.0/96"} {
+		_, err := NewNAT64Translator(netip.MustParsePrefix(prefix))
+		require.Error(t, err, prefix)
+	}
+}
+
+func TestAddrsForDialTranslated(t *testing.T) {
+	resolver, err := madns.NewResolver(madns.WithDefaultResolver(&madns.MockResolver{}))
+	require.NoError(t, err)
+	s := newTestSwarmWithResolver(t, resolver)
+	tr, err := NewNAT64Translator(WellKnownNAT64Prefix)
+	require.NoError(t, err)
+	s.addrTranslator = tr
+
+	p := test.RandPeerIDFatal(t)
+	s.Peerstore().AddAddrs(p, []ma.Multiaddr{
+		ma.StringCast("/ip4/1.2.3.4/tcp/1"),
+		ma.StringCast("/ip4/192.168.1.1/tcp/1"),
+	}, peerstore.PermanentAddrTTL)
+
+	addrs, _, err := s.addrsForDial(context.Background(), p)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []ma.Multiaddr{
+		ma.StringCast("/ip6/64:ff9b::102:304/tcp/1"),
+		ma.StringCast("/ip4/192.168.1.1/tcp/1"),
+	}, addrs)
+}
@@ -2,6 +2,7 @@ package swarm
 
 import (
 	"fmt"
+	"log/slog"
 	"sync"
 
 	ma "github.com/multiformats/go-multiaddr"
@@ -64,6 +65,11 @@ type BlackHoleSuccessCounter struct {
 // state of the filter to Probing. A failed dial only blocks subsequent requests if the success
 // fraction over the last n outcomes is less than the minSuccessFraction of the filter.
 func (b *BlackHoleSuccessCounter) RecordResult(success bool) {
+	b.recordResult(success, log)
+}
+
+// recordResult is like RecordResult, logging state changes to l.
+func (b *BlackHoleSuccessCounter) recordResult(success bool, l *slog.Logger) {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
@@ -71,7 +77,7 @@ func (b *BlackHoleSuccessCounter) RecordResult(success bool) {
 		// If the call succeeds in a blocked state we reset to allowed.
 		// This is better than slowly accumulating values till we cross the minSuccessFraction
 		// threshold since a black hole is a binary property.
-		b.reset()
+		b.reset(l)
 		return
 	}
 
@@ -87,7 +93,7 @@ func (b *BlackHoleSuccessCounter) RecordResult(success bool) {
 		b.dialResults = b.dialResults[1:]
 	}
 
-	b.updateState()
+	b.updateState(l)
 }
 
 // HandleRequest returns the result of applying the black hole filter for the request.
@@ -106,14 +112,14 @@ func (b *BlackHoleSuccessCounter) HandleRequest() blackHoleState {
 	}
 }
 
-func (b *BlackHoleSuccessCounter) reset() {
+func (b *BlackHoleSuccessCounter) reset(l *slog.Logger) {
 	b.successes = 0
 	b.dialResults = b.dialResults[:0]
 	b.requests = 0
-	b.updateState()
+	b.updateState(l)
 }
 
-func (b *BlackHoleSuccessCounter) updateState() {
+func (b *BlackHoleSuccessCounter) updateState(l *slog.Logger) {
 	st := b.state
 
 	if len(b.dialResults) < b.N {
@@ -125,7 +131,7 @@ func (b *BlackHoleSuccessCounter) updateState() {
 	}
 
 	if st != b.state {
-		log.Debugf("%s blackHoleDetector state changed from %s to %s", b.Name, st, b.state)
+		l.Debug("blackHoleDetector state changed", "name", b.Name, "from", st, "to", b.state)
 	}
 }
 
@@ -178,6 +184,7 @@ type blackHoleDetector struct {
 	udp, ipv6 *BlackHoleSuccessCounter
 	mt        MetricsTracer
 	readOnly  bool
+	log       *slog.Logger
 }
 
 // FilterAddrs filters the peer's addresses removing black holed addresses
@@ -242,11 +249,11 @@ func (d *blackHoleDetector) RecordResult(addr ma.Multiaddr, success bool) {
 		return
 	}
 	if d.udp != nil && isProtocolAddr(addr, ma.P_UDP) {
-		d.udp.RecordResult(success)
+		d.udp.recordResult(success, d.log)
 		d.trackMetrics(d.udp)
 	}
 	if d.ipv6 != nil && isProtocolAddr(addr, ma.P_IP6) {
-		d.ipv6.RecordResult(success)
+		d.ipv6.recordResult(success, d.log)
 		d.trackMetrics(d.ipv6)
 	}
 }
@@ -89,7 +89,7 @@ func TestBlackHoleSuccessCounterSuccessFraction(t *testing.T) {
 func TestBlackHoleDetectorInApplicableAddress(t *testing.T) {
 	udpF := &BlackHoleSuccessCounter{N: 10, MinSuccesses: 5}
 	ipv6F := &BlackHoleSuccessCounter{N: 10, MinSuccesses: 5}
-	bhd := &blackHoleDetector{udp: udpF, ipv6: ipv6F}
+	bhd := &blackHoleDetector{udp: udpF, ipv6: ipv6F, log: log}
 	addrs := []ma.Multiaddr{
 		ma.StringCast("/ip4/1.2.3.4/tcp/1234"),
 		ma.StringCast("/ip4/1.2.3.4/tcp/1233"),
@@ -107,7 +107,7 @@ func TestBlackHoleDetectorInApplicableAddress(t *testing.T) {
 
 func TestBlackHoleDetectorUDPDisabled(t *testing.T) {
 	ipv6F := &BlackHoleSuccessCounter{N: 10, MinSuccesses: 5}
-	bhd := &blackHoleDetector{ipv6: ipv6F}
+	bhd := &blackHoleDetector{ipv6: ipv6F, log: log}
 	publicAddr := ma.StringCast("/ip4/1.2.3.4/udp/1234/quic-v1")
 	privAddr := ma.StringCast("/ip4/192.168.1.5/udp/1234/quic-v1")
 	for i := 0; i < 100; i++ {
@@ -123,7 +123,7 @@ func TestBlackHoleDetectorUDPDisabled(t *testing.T) {
 
 func TestBlackHoleDetectorIPv6Disabled(t *testing.T) {
 	udpF := &BlackHoleSuccessCounter{N: 10, MinSuccesses: 5}
-	bhd := &blackHoleDetector{udp: udpF}
+	bhd := &blackHoleDetector{udp: udpF, log: log}
 	publicAddr := ma.StringCast("/ip6/2001::1/tcp/1234")
 	privAddr := ma.StringCast("/ip6/::1/tcp/1234")
 	for i := 0; i < 100; i++ {
@@ -142,6 +142,7 @@ func TestBlackHoleDetectorProbes(t *testing.T) {
 	bhd := &blackHoleDetector{
 		udp:  &BlackHoleSuccessCounter{N: 2, MinSuccesses: 1, Name: "udp"},
 		ipv6: &BlackHoleSuccessCounter{N: 3, MinSuccesses: 1, Name: "ipv6"},
+		log:  log,
 	}
 	udp6Addr := ma.StringCast("/ip6/2001::1/udp/1234/quic-v1")
 	addrs := []ma.Multiaddr{udp6Addr}
@@ -177,6 +178,7 @@ func TestBlackHoleDetectorAddrFiltering(t *testing.T) {
 		bhd := &blackHoleDetector{
 			udp:  &BlackHoleSuccessCounter{N: 100, MinSuccesses: 10, Name: "udp"},
 			ipv6: &BlackHoleSuccessCounter{N: 100, MinSuccesses: 10, Name: "ipv6"},
+			log:  log,
 		}
 		for i := 0; i < 100; i++ {
 			bhd.RecordResult(udp4Pub, !udpBlocked)
@@ -215,7 +217,7 @@ func TestBlackHoleDetectorAddrFiltering(t *testing.T) {
 func TestBlackHoleDetectorReadOnlyMode(t *testing.T) {
 	udpF := &BlackHoleSuccessCounter{N: 10, MinSuccesses: 5}
 	ipv6F := &BlackHoleSuccessCounter{N: 10, MinSuccesses: 5}
-	bhd := &blackHoleDetector{udp: udpF, ipv6: ipv6F, readOnly: true}
+	bhd := &blackHoleDetector{udp: udpF, ipv6: ipv6F, readOnly: true, log: log}
 	publicAddr := ma.StringCast("/ip4/1.2.3.4/udp/1234/quic-v1")
 	privAddr := ma.StringCast("/ip6/::1/tcp/1234")
 	for i := 0; i < 100; i++ {
@@ -231,7 +233,7 @@ func TestBlackHoleDetectorReadOnlyMode(t *testing.T) {
 	require.ElementsMatch(t, wantRemovedAddrs, gotRemovedAddrs)
 
 	// a non readonly shared state black hole detector
-	nbhd := &blackHoleDetector{udp: bhd.udp, ipv6: bhd.ipv6, readOnly: false}
+	nbhd := &blackHoleDetector{udp: bhd.udp, ipv6: bhd.ipv6, readOnly: false, log: log}
 	for i := 0; i < 100; i++ {
 		nbhd.RecordResult(publicAddr, true)
 	}
@@ -1,11 +1,13 @@
 package swarm
 
 import (
+	"log/slog"
 	"sort"
 	"strconv"
 	"time"
 
 	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
 	ma "github.com/multiformats/go-multiaddr"
 	manet "github.com/multiformats/go-multiaddr/net"
 )
@@ -24,6 +26,41 @@ const (
 	RelayDelay = 500 * time.Millisecond
 )
 
+// PeerDialRanker adjusts the dial ranking for a peer. It is called with the
+// addresses of the peer, as ranked by the swarm's DialRanker, and returns the
+// addresses to dial along with their delays. This allows applications with
+// knowledge of the network topology (e.g. peers in the same datacenter) to
+// prefer the fastest paths.
+//
+// Addresses can be reordered, delayed or dropped. Returned addresses that
+// weren't passed in are ignored. Simultaneous connect requests (used for hole
+// punching) don't use the PeerDialRanker.
+type PeerDialRanker func(p peer.ID, ranked []network.AddrDelay) []network.AddrDelay
+
+// applyPeerDialRanker applies r to ranked, making sure it only returns
+// addresses that were passed in. Unknown addresses are logged to l.
+func applyPeerDialRanker(r PeerDialRanker, p peer.ID, ranked []network.AddrDelay, l *slog.Logger) []network.AddrDelay {
+	candidates := make(map[string]struct{}, len(ranked))
+	for _, a := range ranked {
+		candidates[string(a.Addr.Bytes())] = struct{}{}
+	}
+	in := make([]network.AddrDelay, len(ranked))
+	copy(in, ranked)
+	out := r(p, in)
+	res := make([]network.AddrDelay, 0, len(out))
+	for _, a := range out {
+		k := string(a.Addr.Bytes())
+		if _, ok := candidates[k]; !ok {
+			l.Debug("peer dial ranker returned an unknown address", "peer", p, "addr", a.Addr)
+			continue
+		}
+		// only dial every address once
+		delete(candidates, k)
+		res = append(res, a)
+	}
+	return res
+}
+
 // NoDelayDialRanker ranks addresses with no delay. This is useful for simultaneous connect requests.
 func NoDelayDialRanker(addrs []ma.Multiaddr) []network.AddrDelay {
 	return getAddrDelay(addrs, 0, 0, 0)
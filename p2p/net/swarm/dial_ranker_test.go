@@ -4,8 +4,10 @@ import (
 	"fmt"
 	"sort"
 	"testing"
+	"time"
 
 	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/libp2p/go-libp2p/core/test"
 	ma "github.com/multiformats/go-multiaddr"
 )
@@ -55,7 +57,7 @@ func TestNoDelayDialRanker(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			res := NoDelayDialRanker(tc.addrs)
 			if len(res) != len(tc.output) {
-				log.Errorf("expected %s got %s", tc.output, res)
+				log.Error("unexpected ranking", "expected", tc.output, "got", res)
 				t.Errorf("expected elems: %d got: %d", len(tc.output), len(res))
 			}
 			sortAddrDelays(res)
@@ -136,7 +138,7 @@ func TestDelayRankerQUICDelay(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			res := DefaultDialRanker(tc.addrs)
 			if len(res) != len(tc.output) {
-				log.Errorf("expected %s got %s", tc.output, res)
+				log.Error("unexpected ranking", "expected", tc.output, "got", res)
 				t.Errorf("expected elems: %d got: %d", len(tc.output), len(res))
 			}
 			sortAddrDelays(res)
@@ -232,7 +234,7 @@ func TestDelayRankerTCPDelay(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			res := DefaultDialRanker(tc.addrs)
 			if len(res) != len(tc.output) {
-				log.Errorf("expected %s got %s", tc.output, res)
+				log.Error("unexpected ranking", "expected", tc.output, "got", res)
 				t.Errorf("expected elems: %d got: %d", len(tc.output), len(res))
 			}
 			sortAddrDelays(res)
@@ -274,7 +276,7 @@ func TestDelayRankerRelay(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			res := DefaultDialRanker(tc.addrs)
 			if len(res) != len(tc.output) {
-				log.Errorf("expected %s got %s", tc.output, res)
+				log.Error("unexpected ranking", "expected", tc.output, "got", res)
 				t.Errorf("expected elems: %d got: %d", len(tc.output), len(res))
 			}
 			sortAddrDelays(res)
@@ -287,3 +289,44 @@ func TestDelayRankerRelay(t *testing.T) {
 		})
 	}
 }
+
+func TestApplyPeerDialRanker(t *testing.T) {
+	q1 := ma.StringCast("/ip4/1.2.3.4/udp/1/quic-v1")
+	t1 := ma.StringCast("/ip4/1.2.3.4/tcp/1")
+	unknown := ma.StringCast("/ip4/1.2.3.5/tcp/1")
+	p := test.RandPeerIDFatal(t)
+
+	ranked := []network.AddrDelay{
+		{Addr: q1, Delay: 0},
+		{Addr: t1, Delay: PublicTCPDelay},
+	}
+	res := applyPeerDialRanker(func(id peer.ID, in []network.AddrDelay) []network.AddrDelay {
+		if id != p {
+			t.Errorf("unexpected peer: %s", id)
+		}
+		in[0], in[1] = in[1], in[0]
+		return []network.AddrDelay{
+			{Addr: t1, Delay: 0},
+			{Addr: unknown, Delay: 0},
+			{Addr: q1, Delay: time.Second},
+			{Addr: t1, Delay: time.Second},
+		}
+	}, p, ranked, log)
+
+	want := []network.AddrDelay{
+		{Addr: t1, Delay: 0},
+		{Addr: q1, Delay: time.Second},
+	}
+	if len(res) != len(want) {
+		t.Fatalf("expected %d addrs, got %d: %v", len(want), len(res), res)
+	}
+	for i := range want {
+		if !res[i].Addr.Equal(want[i].Addr) || res[i].Delay != want[i].Delay {
+			t.Errorf("%d: expected %v, got %v", i, want[i], res[i])
+		}
+	}
+	// the input must not be modified by the ranker
+	if !ranked[0].Addr.Equal(q1) || !ranked[1].Addr.Equal(t1) {
+		t.Errorf("input was modified: %v", ranked)
+	}
+}
@@ -290,7 +290,7 @@ loop:
 				// spawn the dial
 				ad, ok := w.trackedDials[string(adelay.Addr.Bytes())]
 				if !ok {
-					log.Errorf("SWARM BUG: no entry for address %s in trackedDials", adelay.Addr)
+					w.s.log.Error("SWARM BUG: no entry for address in trackedDials", "peer", w.peer, "addr", adelay.Addr)
 					continue
 				}
 				ad.dialed = true
@@ -317,7 +317,7 @@ loop:
 
 			ad, ok := w.trackedDials[string(res.Addr.Bytes())]
 			if !ok {
-				log.Errorf("SWARM BUG: no entry for address %s in trackedDials", res.Addr)
+				w.s.log.Error("SWARM BUG: no entry for address in trackedDials", "peer", w.peer, "addr", res.Addr)
 				if res.Conn != nil {
 					res.Conn.Close()
 				}
@@ -373,8 +373,7 @@ loop:
 				// for consistency with the old dialer behavior.
 				w.s.backf.AddBackoff(w.peer, res.Addr)
 			} else if res.Err == ErrDialRefusedBlackHole {
-				log.Errorf("SWARM BUG: unexpected ErrDialRefusedBlackHole while dialing peer %s to addr %s",
-					w.peer, res.Addr)
+				w.s.log.Error("SWARM BUG: unexpected ErrDialRefusedBlackHole", "peer", w.peer, "addr", res.Addr)
 			}
 
 			w.dispatchError(ad, res.Err)
@@ -425,7 +424,11 @@ func (w *dialWorker) rankAddrs(addrs []ma.Multiaddr, isSimConnect bool) []networ
 	if isSimConnect {
 		return NoDelayDialRanker(addrs)
 	}
-	return w.s.dialRanker(addrs)
+	ranked := w.s.dialRanker(addrs)
+	if w.s.peerDialRanker != nil {
+		ranked = applyPeerDialRanker(w.s.peerDialRanker, w.peer, ranked, w.s.log)
+	}
+	return ranked
 }
 
 // dialQueue is a priority queue used to schedule dials
@@ -487,7 +487,7 @@ func TestDialQueueNextBatch(t *testing.T) {
 				sort.Slice(batch, func(i, j int) bool { return batch[i].String() < batch[j].String() })
 				for i := 0; i < len(b); i++ {
 					if !b[i].Addr.Equal(batch[i]) {
-						log.Errorf("expected %s got %s", batch[i], b[i].Addr)
+						log.Error("unexpected dial", "expected", batch[i], "got", b[i].Addr)
 					}
 				}
 			}
@@ -910,7 +910,7 @@ func TestDialWorkerLoopSchedulingProperty(t *testing.T) {
 		s1.dialRanker = makeRanker(tc.input)
 		err := checkDialWorkerLoopScheduling(t, s1, s2, tc)
 		if err != nil {
-			log.Error(err)
+			log.Error("dial worker loop scheduling failed", "error", err)
 		}
 		return err == nil
 	}
@@ -1194,3 +1194,56 @@ func BenchmarkDialRanker(b *testing.B) {
 		}
 	})
 }
+
+func TestDialBackoffClock(t *testing.T) {
+	cl := newMockClock()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	db := &DialBackoff{clock: cl}
+	db.init(ctx)
+
+	_, p := newPeer(t)
+	addr := ma.StringCast("/ip4/1.2.3.4/tcp/1")
+	db.AddBackoff(p, addr)
+	require.True(t, db.Backoff(p, addr))
+
+	cl.AdvanceBy(BackoffBase - time.Millisecond)
+	require.True(t, db.Backoff(p, addr))
+	cl.AdvanceBy(time.Millisecond)
+	require.False(t, db.Backoff(p, addr))
+}
+
+func TestDialWorkerPeerDialRanker(t *testing.T) {
+	s2 := makeSwarm(t)
+	defer s2.Close()
+	var tcpAddr ma.Multiaddr
+	for _, a := range s2.ListenAddresses() {
+		if _, err := a.ValueForProtocol(ma.P_TCP); err == nil {
+			tcpAddr = a
+		}
+	}
+	require.NotNil(t, tcpAddr)
+
+	s1 := makeSwarmWithNoListenAddrs(t, WithPeerDialRanker(func(p peer.ID, ranked []network.AddrDelay) []network.AddrDelay {
+		require.Equal(t, s2.LocalPeer(), p)
+		// only dial TCP, even though QUIC is ranked first
+		for _, a := range ranked {
+			if a.Addr.Equal(tcpAddr) {
+				return []network.AddrDelay{{Addr: a.Addr}}
+			}
+		}
+		return nil
+	}))
+	defer s1.Close()
+
+	s1.Peerstore().AddAddrs(s2.LocalPeer(), s2.ListenAddresses(), peerstore.PermanentAddrTTL)
+	c, err := s1.DialPeer(context.Background(), s2.LocalPeer())
+	require.NoError(t, err)
+	require.True(t, c.RemoteMultiaddr().Equal(tcpAddr))
+}
+
+func TestPeerDialRankerNil(t *testing.T) {
+	_, err := NewSwarm(test.RandPeerIDFatal(t), nil, eventbus.NewBus(), WithPeerDialRanker(nil))
+	require.Error(t, err)
+}
@@ -2,6 +2,7 @@ package swarm
 
 import (
 	"context"
+	"log/slog"
 	"os"
 	"strconv"
 	"sync"
@@ -33,6 +34,7 @@ type dialLimiter struct {
 	waitingOnFd []*dialJob
 
 	dialFunc dialfunc
+	log      *slog.Logger
 
 	activePerPeer      map[peer.ID]int
 	perPeerLimit       int
@@ -41,14 +43,16 @@ type dialLimiter struct {
 
 type dialfunc func(context.Context, peer.ID, ma.Multiaddr, chan<- transport.DialUpdate) (transport.CapableConn, error)
 
-func newDialLimiter(df dialfunc) *dialLimiter {
+func newDialLimiter(df dialfunc, logger *slog.Logger) *dialLimiter {
 	fd := ConcurrentFdDials
 	if env := os.Getenv("LIBP2P_SWARM_FD_LIMIT"); env != "" {
 		if n, err := strconv.ParseInt(env, 10, 32); err == nil {
 			fd = int(n)
 		}
 	}
-	return newDialLimiterWithParams(df, fd, DefaultPerPeerRateLimit)
+	dl := newDialLimiterWithParams(df, fd, DefaultPerPeerRateLimit)
+	dl.log = logger
+	return dl
 }
 
 func newDialLimiterWithParams(df dialfunc, fdLimit, perPeerLimit int) *dialLimiter {
@@ -58,13 +62,14 @@ func newDialLimiterWithParams(df dialfunc, fdLimit, perPeerLimit int) *dialLimit
 		waitingOnPeerLimit: make(map[peer.ID][]*dialJob),
 		activePerPeer:      make(map[peer.ID]int),
 		dialFunc:           df,
+		log:                log,
 	}
 }
 
 // freeFDToken frees FD token and if there are any schedules another waiting dialJob
 // in it's place
 func (dl *dialLimiter) freeFDToken() {
-	log.Debugf("[limiter] freeing FD token; waiting: %d; consuming: %d", len(dl.waitingOnFd), dl.fdConsuming)
+	dl.log.Debug("[limiter] freeing FD token", "waiting", len(dl.waitingOnFd), "consuming", dl.fdConsuming)
 	dl.fdConsuming--
 
 	for len(dl.waitingOnFd) > 0 {
@@ -91,8 +96,8 @@ func (dl *dialLimiter) freeFDToken() {
 }
 
 func (dl *dialLimiter) freePeerToken(dj *dialJob) {
-	log.Debugf("[limiter] freeing peer token; peer %s; addr: %s; active for peer: %d; waiting on peer limit: %d",
-		dj.peer, dj.addr, dl.activePerPeer[dj.peer], len(dl.waitingOnPeerLimit[dj.peer]))
+	dl.log.Debug("[limiter] freeing peer token", "peer", dj.peer, "addr", dj.addr,
+		"active", dl.activePerPeer[dj.peer], "waiting", len(dl.waitingOnPeerLimit[dj.peer]))
 	// release tokens in reverse order than we take them
 	dl.activePerPeer[dj.peer]--
 	if dl.activePerPeer[dj.peer] == 0 {
@@ -146,28 +151,26 @@ func (dl *dialLimiter) shouldConsumeFd(addr ma.Multiaddr) bool {
 func (dl *dialLimiter) addCheckFdLimit(dj *dialJob) {
 	if dl.shouldConsumeFd(dj.addr) {
 		if dl.fdConsuming >= dl.fdLimit {
-			log.Debugf("[limiter] blocked dial waiting on FD token; peer: %s; addr: %s; consuming: %d; "+
-				"limit: %d; waiting: %d", dj.peer, dj.addr, dl.fdConsuming, dl.fdLimit, len(dl.waitingOnFd))
+			dl.log.Debug("[limiter] blocked dial waiting on FD token", "peer", dj.peer, "addr", dj.addr,
+				"consuming", dl.fdConsuming, "limit", dl.fdLimit, "waiting", len(dl.waitingOnFd))
 			dl.waitingOnFd = append(dl.waitingOnFd, dj)
 			return
 		}
 
-		log.Debugf("[limiter] taking FD token: peer: %s; addr: %s; prev consuming: %d",
-			dj.peer, dj.addr, dl.fdConsuming)
+		dl.log.Debug("[limiter] taking FD token", "peer", dj.peer, "addr", dj.addr, "consuming", dl.fdConsuming)
 		// take token
 		dl.fdConsuming++
 	}
 
-	log.Debugf("[limiter] executing dial; peer: %s; addr: %s; FD consuming: %d; waiting: %d",
-		dj.peer, dj.addr, dl.fdConsuming, len(dl.waitingOnFd))
+	dl.log.Debug("[limiter] executing dial", "peer", dj.peer, "addr", dj.addr,
+		"consuming", dl.fdConsuming, "waiting", len(dl.waitingOnFd))
 	go dl.executeDial(dj)
 }
 
 func (dl *dialLimiter) addCheckPeerLimit(dj *dialJob) {
 	if dl.activePerPeer[dj.peer] >= dl.perPeerLimit {
-		log.Debugf("[limiter] blocked dial waiting on peer limit; peer: %s; addr: %s; active: %d; "+
-			"peer limit: %d; waiting: %d", dj.peer, dj.addr, dl.activePerPeer[dj.peer], dl.perPeerLimit,
-			len(dl.waitingOnPeerLimit[dj.peer]))
+		dl.log.Debug("[limiter] blocked dial waiting on peer limit", "peer", dj.peer, "addr", dj.addr,
+			"active", dl.activePerPeer[dj.peer], "limit", dl.perPeerLimit, "waiting", len(dl.waitingOnPeerLimit[dj.peer]))
 		wlist := dl.waitingOnPeerLimit[dj.peer]
 		dl.waitingOnPeerLimit[dj.peer] = append(wlist, dj)
 		return
@@ -184,7 +187,7 @@ func (dl *dialLimiter) AddDialJob(dj *dialJob) {
 	dl.lk.Lock()
 	defer dl.lk.Unlock()
 
-	log.Debugf("[limiter] adding a dial job through limiter: %v", dj.addr)
+	dl.log.Debug("[limiter] adding a dial job through limiter", "peer", dj.peer, "addr", dj.addr)
 	dl.addCheckPeerLimit(dj)
 }
 
@@ -192,7 +195,7 @@ func (dl *dialLimiter) clearAllPeerDials(p peer.ID) {
 	dl.lk.Lock()
 	defer dl.lk.Unlock()
 	delete(dl.waitingOnPeerLimit, p)
-	log.Debugf("[limiter] clearing all peer dials: %v", p)
+	dl.log.Debug("[limiter] clearing all peer dials", "peer", p)
 	// NB: the waitingOnFd list doesn't need to be cleaned out here, we will
 	// remove them as we encounter them because they are 'cancelled' at this
 	// point
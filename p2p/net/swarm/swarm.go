@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -17,9 +18,9 @@ import (
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/libp2p/go-libp2p/core/peerstore"
 	"github.com/libp2p/go-libp2p/core/transport"
+	"github.com/libp2p/go-libp2p/p2p/slogshim"
 	"golang.org/x/exp/slices"
 
-	logging "github.com/ipfs/go-log/v2"
 	ma "github.com/multiformats/go-multiaddr"
 	madns "github.com/multiformats/go-multiaddr-dns"
 )
@@ -34,7 +35,7 @@ const (
 	defaultDialTimeoutLocal = 5 * time.Second
 )
 
-var log = logging.Logger("swarm2")
+var log = slogshim.Logger("swarm2")
 
 // ErrSwarmClosed is returned when one attempts to operate on a closed swarm.
 var ErrSwarmClosed = errors.New("swarm closed")
@@ -112,6 +113,30 @@ func WithDialRanker(d network.DialRanker) Option {
 	}
 }
 
+// WithPeerDialRanker configures swarm to adjust the dial ranking computed by
+// the DialRanker using r. See PeerDialRanker.
+func WithPeerDialRanker(r PeerDialRanker) Option {
+	return func(s *Swarm) error {
+		if r == nil {
+			return errors.New("swarm: peer dial ranker cannot be nil")
+		}
+		s.peerDialRanker = r
+		return nil
+	}
+}
+
+// WithAddrTranslator configures swarm to translate the addresses of peers
+// using t before dialing them.
+func WithAddrTranslator(t network.AddrTranslator) Option {
+	return func(s *Swarm) error {
+		if t == nil {
+			return errors.New("swarm: address translator cannot be nil")
+		}
+		s.addrTranslator = t
+		return nil
+	}
+}
+
 // WithUDPBlackHoleSuccessCounter configures swarm to use the provided config for UDP black hole detection
 // n is the size of the sliding window used to evaluate black hole state
 // min is the minimum number of successes out of n required to not block requests
@@ -143,6 +168,30 @@ func WithReadOnlyBlackHoleDetector() Option {
 	}
 }
 
+// WithClock sets the clock used for dial backoffs and dial scheduling.
+// This is useful for tests and simulations. Defaults to the real clock.
+func WithClock(cl Clock) Option {
+	return func(s *Swarm) error {
+		if cl == nil {
+			return errors.New("swarm: clock cannot be nil")
+		}
+		s.clock = cl
+		return nil
+	}
+}
+
+// WithLogger sets the logger used by the swarm. By default, the swarm logs to
+// the go-log logger "swarm2".
+func WithLogger(l *slog.Logger) Option {
+	return func(s *Swarm) error {
+		if l == nil {
+			return errors.New("swarm: logger cannot be nil")
+		}
+		s.log = slogshim.ForSubsystem(l, "swarm2")
+		return nil
+	}
+}
+
 // Swarm is a connection muxer, allowing connections to other peers to
 // be opened and closed, while still using the same Chan for all
 // communication. The Chan sends/receives Messages, which note the
@@ -202,6 +251,7 @@ type Swarm struct {
 	// dialing helpers
 	dsync   *dialSync
 	backf   DialBackoff
+	clock   Clock
 	limiter *dialLimiter
 	gater   connmgr.ConnectionGater
 
@@ -212,13 +262,17 @@ type Swarm struct {
 	bwc           metrics.Reporter
 	metricsTracer MetricsTracer
 
-	dialRanker network.DialRanker
+	dialRanker     network.DialRanker
+	peerDialRanker PeerDialRanker
+	addrTranslator network.AddrTranslator
 
 	connectednessEventEmitter *connectednessEventEmitter
 	udpBHF                    *BlackHoleSuccessCounter
 	ipv6BHF                   *BlackHoleSuccessCounter
 	bhd                       *blackHoleDetector
 	readOnlyBHD               bool
+
+	log *slog.Logger
 }
 
 // NewSwarm constructs a Swarm.
@@ -238,6 +292,8 @@ func NewSwarm(local peer.ID, peers peerstore.Peerstore, eventBus event.Bus, opts
 		dialTimeoutLocal: defaultDialTimeoutLocal,
 		maResolver:       madns.DefaultResolver,
 		dialRanker:       DefaultDialRanker,
+		clock:            RealClock{},
+		log:              log,
 
 		// A black hole is a binary property. On a network if UDP dials are blocked or there is
 		// no IPv6 connectivity, all dials will fail. So a low success rate of 5 out 100 dials
@@ -264,7 +320,8 @@ func NewSwarm(local peer.ID, peers peerstore.Peerstore, eventBus event.Bus, opts
 
 	s.dsync = newDialSync(s.dialWorkerLoop)
 
-	s.limiter = newDialLimiter(s.dialAddr)
+	s.limiter = newDialLimiter(s.dialAddr, s.log)
+	s.backf.clock = s.clock
 	s.backf.init(s.ctx)
 
 	s.bhd = &blackHoleDetector{
@@ -272,6 +329,7 @@ func NewSwarm(local peer.ID, peers peerstore.Peerstore, eventBus event.Bus, opts
 		ipv6:     s.ipv6BHF,
 		mt:       s.metricsTracer,
 		readOnly: s.readOnlyBHD,
+		log:      s.log,
 	}
 	return s, nil
 }
@@ -307,7 +365,7 @@ func (s *Swarm) close() {
 		go func(l transport.Listener) {
 			defer s.refs.Done()
 			if err := l.Close(); err != nil && err != transport.ErrListenerClosed {
-				log.Errorf("error when shutting down listener: %s", err)
+				s.log.Error("error when shutting down listener", "error", err)
 			}
 		}(l)
 	}
@@ -316,7 +374,7 @@ func (s *Swarm) close() {
 		for _, c := range cs {
 			go func(c *Conn) {
 				if err := c.Close(); err != nil {
-					log.Errorf("error when shutting down connection: %s", err)
+					s.log.Error("error when shutting down connection", "error", err)
 				}
 			}(c)
 		}
@@ -347,7 +405,7 @@ func (s *Swarm) close() {
 			go func(c io.Closer) {
 				defer wg.Done()
 				if err := closer.Close(); err != nil {
-					log.Errorf("error when closing down transport %T: %s", c, err)
+					s.log.Error("error when closing down transport", "transport", fmt.Sprintf("%T", c), "error", err)
 				}
 			}(closer)
 		}
@@ -385,7 +443,7 @@ func (s *Swarm) addConn(tc transport.CapableConn, dir network.Direction) (*Conn,
 			// TODO Send disconnect with reason here
 			err := tc.Close()
 			if err != nil {
-				log.Warnf("failed to close connection with peer %s and addr %s; err: %s", p, addr, err)
+				s.log.Warn("failed to close connection", "peer", p, "addr", addr, "error", err)
 			}
 			return nil, ErrGaterDisallowedConnection
 		}
@@ -470,7 +528,7 @@ func (s *Swarm) StreamHandler() network.StreamHandler {
 // Use network.WithAllowLimitedConn to open a stream over a limited(relayed)
 // connection.
 func (s *Swarm) NewStream(ctx context.Context, p peer.ID) (network.Stream, error) {
-	log.Debugf("[%s] opening stream to peer [%s]", s.local, p)
+	s.log.Debug("opening stream", "peer", p)
 
 	// Algorithm:
 	// 1. Find the best connection, otherwise, dial.
@@ -814,6 +872,40 @@ func (s *Swarm) ResourceManager() network.ResourceManager {
 var _ network.Network = (*Swarm)(nil)
 var _ transport.TransportNetwork = (*Swarm)(nil)
 
+// connWithTimings records the duration of the transport handshake in the
+// connection's timings.
+type connWithTimings struct {
+	transport.CapableConn
+	handshake time.Duration
+}
+
+// withHandshakeTiming derives the duration of the transport handshake from the
+// total time it took to dial c. The upgrader already measured the security
+// handshake and the muxer negotiation, everything else was spent on the
+// transport handshake.
+func withHandshakeTiming(c transport.CapableConn, dialDuration time.Duration) connWithTimings {
+	handshake := dialDuration
+	if cs, ok := c.(network.ConnStat); ok {
+		timings := cs.Stat().Timings
+		handshake -= timings.Security + timings.Muxer
+	}
+	if handshake < 0 {
+		handshake = 0
+	}
+	return connWithTimings{CapableConn: c, handshake: handshake}
+}
+
+func (c connWithTimings) Stat() network.ConnStats {
+	var stat network.ConnStats
+	if cs, ok := c.CapableConn.(network.ConnStat); ok {
+		stat = cs.Stat()
+	}
+	stat.Timings.Handshake = c.handshake
+	return stat
+}
+
+var _ network.ConnStat = connWithTimings{}
+
 type connWithMetrics struct {
 	transport.CapableConn
 	opened        time.Time
@@ -10,6 +10,7 @@ import (
 	ic "github.com/libp2p/go-libp2p/core/crypto"
 	"github.com/libp2p/go-libp2p/core/network"
 	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
 	"github.com/libp2p/go-libp2p/core/transport"
 
 	ma "github.com/multiformats/go-multiaddr"
@@ -37,6 +38,11 @@ type Conn struct {
 		m map[*Stream]struct{}
 	}
 
+	protocols struct {
+		sync.Mutex
+		m map[protocol.ID]*protocolStats
+	}
+
 	stat network.ConnStats
 }
 
@@ -106,6 +106,7 @@ var DefaultPerPeerRateLimit = 8
 type DialBackoff struct {
 	entries map[peer.ID]map[string]*backoffAddr
 	lock    sync.RWMutex
+	clock   Clock
 }
 
 type backoffAddr struct {
@@ -117,6 +118,9 @@ func (db *DialBackoff) init(ctx context.Context) {
 	if db.entries == nil {
 		db.entries = make(map[peer.ID]map[string]*backoffAddr)
 	}
+	if db.clock == nil {
+		db.clock = RealClock{}
+	}
 	go db.background(ctx)
 }
 
@@ -140,7 +144,7 @@ func (db *DialBackoff) Backoff(p peer.ID, addr ma.Multiaddr) (backoff bool) {
 	defer db.lock.RUnlock()
 
 	ap, found := db.entries[p][string(addr.Bytes())]
-	return found && time.Now().Before(ap.until)
+	return found && db.now().Before(ap.until)
 }
 
 // BackoffBase is the base amount of time to backoff (default: 5s).
@@ -173,7 +177,7 @@ func (db *DialBackoff) AddBackoff(p peer.ID, addr ma.Multiaddr) {
 	if !ok {
 		bp[saddr] = &backoffAddr{
 			tries: 1,
-			until: time.Now().Add(BackoffBase),
+			until: db.now().Add(BackoffBase),
 		}
 		return
 	}
@@ -182,10 +186,18 @@ func (db *DialBackoff) AddBackoff(p peer.ID, addr ma.Multiaddr) {
 	if backoffTime > BackoffMax {
 		backoffTime = BackoffMax
 	}
-	ba.until = time.Now().Add(backoffTime)
+	ba.until = db.now().Add(backoffTime)
 	ba.tries++
 }
 
+// now returns the current time. The zero value of DialBackoff uses the real clock.
+func (db *DialBackoff) now() time.Time {
+	if db.clock == nil {
+		return time.Now()
+	}
+	return db.clock.Now()
+}
+
 // Clear removes a backoff record. Clients should call this after a
 // successful Dial.
 func (db *DialBackoff) Clear(p peer.ID) {
@@ -197,7 +209,7 @@ func (db *DialBackoff) Clear(p peer.ID) {
 func (db *DialBackoff) cleanup() {
 	db.lock.Lock()
 	defer db.lock.Unlock()
-	now := time.Now()
+	now := db.now()
 	for p, e := range db.entries {
 		good := false
 		for _, backoff := range e {
@@ -237,7 +249,7 @@ func (s *Swarm) DialPeer(ctx context.Context, p peer.ID) (network.Conn, error) {
 // It is gated by the swarm's dial synchronization systems: dialsync and
 // dialbackoff.
 func (s *Swarm) dialPeer(ctx context.Context, p peer.ID) (*Conn, error) {
-	log.Debugw("dialing peer", "from", s.local, "to", p)
+	s.log.Debug("dialing peer", "peer", p)
 	err := p.Validate()
 	if err != nil {
 		return nil, err
@@ -254,7 +266,7 @@ func (s *Swarm) dialPeer(ctx context.Context, p peer.ID) (*Conn, error) {
 	}
 
 	if s.gater != nil && !s.gater.InterceptPeerDial(p) {
-		log.Debugf("gater disallowed outbound connection to peer %s", p)
+		s.log.Debug("gater disallowed outbound connection", "peer", p)
 		return nil, &DialError{Peer: p, Cause: ErrGaterDisallowedConnection}
 	}
 
@@ -268,13 +280,13 @@ func (s *Swarm) dialPeer(ctx context.Context, p peer.ID) (*Conn, error) {
 		// This was most likely already checked by the security protocol, but it doesn't hurt do it again here.
 		if conn.RemotePeer() != p {
 			conn.Close()
-			log.Errorw("Handshake failed to properly authenticate peer", "authenticated", conn.RemotePeer(), "expected", p)
+			s.log.Error("handshake failed to properly authenticate peer", "peer", p, "authenticated", conn.RemotePeer())
 			return nil, fmt.Errorf("unexpected peer")
 		}
 		return conn, nil
 	}
 
-	log.Debugf("network for %s finished dialing %s", s.local, p)
+	s.log.Debug("finished dialing peer", "peer", p)
 
 	if ctx.Err() != nil {
 		// Context error trumps any dial errors as it was likely the ultimate cause.
@@ -291,7 +303,7 @@ func (s *Swarm) dialPeer(ctx context.Context, p peer.ID) (*Conn, error) {
 
 // dialWorkerLoop synchronizes and executes concurrent dials to a single peer
 func (s *Swarm) dialWorkerLoop(p peer.ID, reqch <-chan dialRequest) {
-	w := newDialWorker(s, p, reqch, nil)
+	w := newDialWorker(s, p, reqch, s.clock)
 	w.loop()
 }
 
@@ -307,6 +319,14 @@ func (s *Swarm) addrsForDial(ctx context.Context, p peer.ID) (goodAddrs []ma.Mul
 		return nil, nil, err
 	}
 
+	if s.addrTranslator != nil {
+		translated := make([]ma.Multiaddr, 0, len(resolved))
+		for _, a := range resolved {
+			translated = append(translated, s.addrTranslator.TranslateDialAddr(a)...)
+		}
+		resolved = translated
+	}
+
 	goodAddrs = ma.Unique(resolved)
 	goodAddrs, addrErrs = s.filterKnownUndialables(p, goodAddrs)
 	if forceDirect, _ := network.GetForceDirectDial(ctx); forceDirect {
@@ -353,11 +373,10 @@ func (s *Swarm) resolveAddrs(ctx context.Context, pi peer.AddrInfo) ([]ma.Multia
 		// We've resolved too many addresses. We can keep all the fully
 		// resolved addresses but we'll need to skip the rest.
 		if resolveSteps >= maxAddressResolution {
-			log.Warnf(
-				"peer %s asked us to resolve too many addresses: %s/%s",
-				pi.ID,
-				resolveSteps,
-				maxAddressResolution,
+			s.log.Warn("peer asked us to resolve too many addresses",
+				"peer", pi.ID,
+				"steps", resolveSteps,
+				"max", maxAddressResolution,
 			)
 			continue
 		}
@@ -367,7 +386,7 @@ func (s *Swarm) resolveAddrs(ctx context.Context, pi peer.AddrInfo) ([]ma.Multia
 		if ok {
 			resolvedAddrs, err := resolver.Resolve(ctx, addr)
 			if err != nil {
-				log.Warnf("Failed to resolve multiaddr %s by transport %v: %v", addr, tpt, err)
+				s.log.Warn("failed to resolve multiaddr by transport", "addr", addr, "transport", tpt, "error", err)
 				continue
 			}
 			var added bool
@@ -386,14 +405,14 @@ func (s *Swarm) resolveAddrs(ctx context.Context, pi peer.AddrInfo) ([]ma.Multia
 		reqaddr := addr.Encapsulate(p2paddr)
 		resaddrs, err := s.maResolver.Resolve(ctx, reqaddr)
 		if err != nil {
-			log.Infof("error resolving %s: %s", reqaddr, err)
+			s.log.Info("error resolving addr", "addr", reqaddr, "error", err)
 		}
 
 		// add the results to the toResolve list.
 		for _, res := range resaddrs {
 			pi, err := peer.AddrInfoFromP2pAddr(res)
 			if err != nil {
-				log.Infof("error parsing %s: %s", res, err)
+				s.log.Info("error parsing resolved addr", "addr", res, "error", err)
 			}
 			toResolve = append(toResolve, pi.Addrs...)
 		}
@@ -531,10 +550,10 @@ func (s *Swarm) dialAddr(ctx context.Context, p peer.ID, addr ma.Multiaddr, updC
 	}
 	// Check before we start work
 	if err := ctx.Err(); err != nil {
-		log.Debugf("%s swarm not dialing. Context cancelled: %v. %s %s", s.local, err, p, addr)
+		s.log.Debug("not dialing, context canceled", "peer", p, "addr", addr, "error", err)
 		return nil, err
 	}
-	log.Debugf("%s swarm dialing %s %s", s.local, p, addr)
+	s.log.Debug("dialing addr", "peer", p, "addr", addr)
 
 	tpt := s.TransportForDialing(addr)
 	if tpt == nil {
@@ -562,6 +581,7 @@ func (s *Swarm) dialAddr(ctx context.Context, p peer.ID, addr ma.Multiaddr, updC
 		return nil, err
 	}
 	canonicallog.LogPeerStatus(100, connC.RemotePeer(), connC.RemoteMultiaddr(), "connection_status", "established", "dir", "outbound")
+	connC = withHandshakeTiming(connC, time.Since(start))
 	if s.metricsTracer != nil {
 		connWithMetrics := wrapWithMetrics(connC, s.metricsTracer, start, network.DirOutbound)
 		connWithMetrics.completedHandshake()
@@ -572,7 +592,7 @@ func (s *Swarm) dialAddr(ctx context.Context, p peer.ID, addr ma.Multiaddr, updC
 	if connC.RemotePeer() != p {
 		connC.Close()
 		err = fmt.Errorf("BUG in transport %T: tried to dial %s, dialed %s", p, connC.RemotePeer(), tpt)
-		log.Error(err)
+		s.log.Error("transport dialed the wrong peer", "peer", p, "addr", addr, "error", err)
 		return nil, err
 	}
 
@@ -0,0 +1,156 @@
+package swarm
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+)
+
+// Dump is a snapshot of all open connections and streams of a swarm.
+type Dump struct {
+	LocalPeer   peer.ID    `json:"localPeer"`
+	Time        time.Time  `json:"time"`
+	ListenAddrs []string   `json:"listenAddrs"`
+	NumConns    int        `json:"numConns"`
+	NumStreams  int        `json:"numStreams"`
+	Conns       []ConnDump `json:"conns"`
+}
+
+// ConnDump is the state of a single connection.
+type ConnDump struct {
+	ID         string        `json:"id"`
+	Peer       peer.ID       `json:"peer"`
+	LocalAddr  string        `json:"localAddr"`
+	RemoteAddr string        `json:"remoteAddr"`
+	Direction  string        `json:"direction"`
+	Opened     time.Time     `json:"opened"`
+	Age        time.Duration `json:"age"`
+	Limited    bool          `json:"limited"`
+	Transport  string        `json:"transport"`
+	Security   protocol.ID   `json:"security"`
+	Muxer      protocol.ID   `json:"muxer"`
+	// Resources is the resource usage of the connection scope.
+	Resources network.ScopeStat `json:"resources"`
+	Streams   []StreamDump      `json:"streams"`
+}
+
+// StreamDump is the state of a single stream.
+type StreamDump struct {
+	ID        string        `json:"id"`
+	Protocol  protocol.ID   `json:"protocol"`
+	Direction string        `json:"direction"`
+	Opened    time.Time     `json:"opened"`
+	Age       time.Duration `json:"age"`
+	// Resources is the resource usage of the stream scope.
+	Resources network.ScopeStat `json:"resources"`
+}
+
+// Dump returns a snapshot of all open connections and streams. Connections are
+// sorted by peer, then by age. Streams are sorted by age.
+func (s *Swarm) Dump() Dump {
+	now := time.Now()
+	d := Dump{
+		LocalPeer: s.local,
+		Time:      now,
+	}
+	for _, a := range s.ListenAddresses() {
+		d.ListenAddrs = append(d.ListenAddrs, a.String())
+	}
+
+	s.conns.RLock()
+	conns := make([]*Conn, 0, len(s.conns.m))
+	for _, cs := range s.conns.m {
+		conns = append(conns, cs...)
+	}
+	s.conns.RUnlock()
+
+	for _, c := range conns {
+		cd := dumpConn(c, now)
+		d.NumStreams += len(cd.Streams)
+		d.Conns = append(d.Conns, cd)
+	}
+	d.NumConns = len(d.Conns)
+	sort.Slice(d.Conns, func(i, j int) bool {
+		if d.Conns[i].Peer != d.Conns[j].Peer {
+			return d.Conns[i].Peer < d.Conns[j].Peer
+		}
+		return d.Conns[i].Opened.Before(d.Conns[j].Opened)
+	})
+	return d
+}
+
+func dumpConn(c *Conn, now time.Time) ConnDump {
+	stat := c.Stat()
+	state := c.ConnState()
+	cd := ConnDump{
+		ID:         c.ID(),
+		Peer:       c.RemotePeer(),
+		LocalAddr:  c.LocalMultiaddr().String(),
+		RemoteAddr: c.RemoteMultiaddr().String(),
+		Direction:  stat.Direction.String(),
+		Opened:     stat.Opened,
+		Age:        now.Sub(stat.Opened),
+		Limited:    stat.Limited,
+		Transport:  state.Transport,
+		Security:   state.Security,
+		Muxer:      state.StreamMultiplexer,
+		Resources:  c.Scope().Stat(),
+	}
+	for _, str := range c.GetStreams() {
+		sstat := str.Stat()
+		cd.Streams = append(cd.Streams, StreamDump{
+			ID:        str.ID(),
+			Protocol:  str.Protocol(),
+			Direction: sstat.Direction.String(),
+			Opened:    sstat.Opened,
+			Age:       now.Sub(sstat.Opened),
+			Resources: str.Scope().Stat(),
+		})
+	}
+	sort.Slice(cd.Streams, func(i, j int) bool { return cd.Streams[i].Opened.Before(cd.Streams[j].Opened) })
+	return cd
+}
+
+// WriteText writes the dump in a human readable format, similar to a goroutine dump.
+func (d Dump) WriteText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 1, ' ', 0)
+	fmt.Fprintf(tw, "swarm %s at %s: %d connections, %d streams\n", d.LocalPeer, d.Time.Format(time.RFC3339), d.NumConns, d.NumStreams)
+	for _, c := range d.Conns {
+		fmt.Fprintf(tw, "\nconn %s\t%s\tpeer=%s\tremote=%s\tage=%s\ttransport=%s\tsecurity=%s\tmuxer=%s\tlimited=%t\tmem=%d\n",
+			c.ID, c.Direction, c.Peer, c.RemoteAddr, c.Age.Round(time.Second), c.Transport, c.Security, c.Muxer, c.Limited, c.Resources.Memory)
+		for _, s := range c.Streams {
+			fmt.Fprintf(tw, "\tstream %s\t%s\t%s\tage=%s\tmem=%d\n", s.ID, s.Direction, s.Protocol, s.Age.Round(time.Second), s.Resources.Memory)
+		}
+	}
+	return tw.Flush()
+}
+
+// DumpHandler returns an http.Handler that serves the Dump of the swarm as
+// JSON, or as text if the request has the query parameter format=text. It is
+// meant to be attached to a debug server, e.g. next to the pprof handlers:
+//
+//	http.Handle("/debug/libp2p/swarm", s.DumpHandler())
+func (s *Swarm) DumpHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		d := s.Dump()
+		if r.URL.Query().Get("format") == "text" {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			if err := d.WriteText(w); err != nil {
+				s.log.Debug("failed to write swarm dump", "error", err)
+			}
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(d); err != nil {
+			s.log.Debug("failed to write swarm dump", "error", err)
+		}
+	})
+}
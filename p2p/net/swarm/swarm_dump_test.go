@@ -0,0 +1,56 @@
+package swarm_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDump(t *testing.T) {
+	ctx := context.Background()
+	swarms := makeSwarms(t, 2)
+	defer closeSwarms(swarms)
+	connectSwarms(t, ctx, swarms)
+
+	s, err := swarms[0].NewStream(ctx, swarms[1].LocalPeer())
+	require.NoError(t, err)
+	defer s.Close()
+	require.NoError(t, s.SetProtocol("/test"))
+
+	d := swarms[0].Dump()
+	require.Equal(t, swarms[0].LocalPeer(), d.LocalPeer)
+	require.Equal(t, 1, d.NumConns)
+	require.Equal(t, 1, d.NumStreams)
+	c := d.Conns[0]
+	require.Equal(t, swarms[1].LocalPeer(), c.Peer)
+	require.Equal(t, "Outbound", c.Direction)
+	require.NotEmpty(t, c.Transport)
+	require.Len(t, c.Streams, 1)
+	require.Equal(t, "/test", string(c.Streams[0].Protocol))
+
+	var buf bytes.Buffer
+	require.NoError(t, d.WriteText(&buf))
+	require.Contains(t, buf.String(), c.ID)
+	require.Contains(t, buf.String(), "/test")
+}
+
+func TestDumpHandler(t *testing.T) {
+	swarms := makeSwarms(t, 1)
+	defer closeSwarms(swarms)
+	h := swarms[0].DumpHandler()
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	var d map[string]interface{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &d))
+	require.Equal(t, swarms[0].LocalPeer().String(), d["localPeer"])
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/?format=text", nil))
+	require.True(t, strings.HasPrefix(rec.Body.String(), "swarm "+swarms[0].LocalPeer().String()))
+}
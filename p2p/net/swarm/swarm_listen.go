@@ -3,6 +3,7 @@ package swarm
 import (
 	"errors"
 	"fmt"
+	"slices"
 	"time"
 
 	"github.com/libp2p/go-libp2p/core/canonicallog"
@@ -12,6 +13,10 @@ import (
 	ma "github.com/multiformats/go-multiaddr"
 )
 
+// ErrNoListener is returned by StopListening if the swarm isn't listening on
+// an address.
+var ErrNoListener = errors.New("not listening on address")
+
 // Listen sets up listeners for all of the given addresses.
 // It returns as long as we successfully listen on at least *one* address.
 func (s *Swarm) Listen(addrs ...ma.Multiaddr) error {
@@ -27,7 +32,7 @@ func (s *Swarm) Listen(addrs ...ma.Multiaddr) error {
 
 	for i, e := range errs {
 		if e != nil {
-			log.Warnw("listening failed", "on", addrs[i], "error", errs[i])
+			s.log.Warn("listening failed", "addr", addrs[i], "error", errs[i])
 		}
 	}
 
@@ -38,34 +43,86 @@ func (s *Swarm) Listen(addrs ...ma.Multiaddr) error {
 	return nil
 }
 
+// ListenOn sets up listeners for all of the given addresses. Unlike Listen,
+// it fails if listening on any of the addresses fails, in which case the
+// listeners set up by this call are closed again.
+func (s *Swarm) ListenOn(addrs ...ma.Multiaddr) error {
+	listeners := make([]transport.Listener, 0, len(addrs))
+	for _, a := range addrs {
+		list, err := s.addListenAddr(a)
+		if err != nil {
+			s.closeListeners(func(l transport.Listener) bool {
+				return slices.Contains(listeners, l)
+			})
+			return fmt.Errorf("failed to listen on %s: %w", a, err)
+		}
+		listeners = append(listeners, list)
+	}
+	return nil
+}
+
 // ListenClose stop and delete listeners for all of the given addresses. If an
 // any address belongs to one of the addreses a Listener provides, then the
 // Listener will close for *all* addresses it provides. For example if you close
 // and address with `/quic`, then the QUIC listener will close and also close
 // any `/quic-v1` address.
 func (s *Swarm) ListenClose(addrs ...ma.Multiaddr) {
-	listenersToClose := make(map[transport.Listener]struct{}, len(addrs))
+	s.closeListeners(func(l transport.Listener) bool {
+		return containsMultiaddr(addrs, l.Multiaddr())
+	})
+}
+
+// StopListening stops and deletes the listeners for all of the given
+// addresses, leaving all other listeners running. The addresses must be the
+// addresses the swarm is listening on, as returned by ListenAddresses. If the
+// swarm isn't listening on some of the addresses, it stops listening on the
+// others, and returns an error wrapping ErrNoListener.
+func (s *Swarm) StopListening(addrs ...ma.Multiaddr) error {
+	closed := s.closeListeners(func(l transport.Listener) bool {
+		return containsMultiaddr(addrs, l.Multiaddr())
+	})
+	var missing []ma.Multiaddr
+	for _, a := range addrs {
+		if !slices.ContainsFunc(closed, func(l transport.Listener) bool { return a.Equal(l.Multiaddr()) }) {
+			missing = append(missing, a)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%w: %s", ErrNoListener, missing)
+	}
+	return nil
+}
+
+// closeListeners stops and deletes the listeners that match, and returns them.
+func (s *Swarm) closeListeners(match func(transport.Listener) bool) []transport.Listener {
+	var listenersToClose []transport.Listener
 
 	s.listeners.Lock()
 	for l := range s.listeners.m {
-		if !containsMultiaddr(addrs, l.Multiaddr()) {
+		if !match(l) {
 			continue
 		}
 
 		delete(s.listeners.m, l)
-		listenersToClose[l] = struct{}{}
+		listenersToClose = append(listenersToClose, l)
 	}
 	s.listeners.cacheEOL = time.Time{}
 	s.listeners.Unlock()
 
-	for l := range listenersToClose {
+	for _, l := range listenersToClose {
 		l.Close()
 	}
+	return listenersToClose
 }
 
 // AddListenAddr tells the swarm to listen on a single address. Unlike Listen,
 // this method does not attempt to filter out bad addresses.
 func (s *Swarm) AddListenAddr(a ma.Multiaddr) error {
+	_, err := s.addListenAddr(a)
+	return err
+}
+
+func (s *Swarm) addListenAddr(a ma.Multiaddr) (transport.Listener, error) {
 	tpt := s.TransportForListening(a)
 	if tpt == nil {
 		// TransportForListening will return nil if either:
@@ -75,22 +132,22 @@ func (s *Swarm) AddListenAddr(a ma.Multiaddr) error {
 		// Distinguish between these two cases to avoid confusing users.
 		select {
 		case <-s.ctx.Done():
-			return ErrSwarmClosed
+			return nil, ErrSwarmClosed
 		default:
-			return ErrNoTransport
+			return nil, ErrNoTransport
 		}
 	}
 
 	list, err := tpt.Listen(a)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	s.listeners.Lock()
 	if s.listeners.m == nil {
 		s.listeners.Unlock()
 		list.Close()
-		return ErrSwarmClosed
+		return nil, ErrSwarmClosed
 	}
 	s.refs.Add(1)
 	s.listeners.m[list] = struct{}{}
@@ -116,7 +173,7 @@ func (s *Swarm) AddListenAddr(a ma.Multiaddr) error {
 
 			if ok {
 				list.Close()
-				log.Errorf("swarm listener unintentionally closed")
+				s.log.Error("swarm listener unintentionally closed", "addr", a)
 			}
 
 			// signal to our notifiees on listen close.
@@ -129,7 +186,7 @@ func (s *Swarm) AddListenAddr(a ma.Multiaddr) error {
 			c, err := list.Accept()
 			if err != nil {
 				if !errors.Is(err, transport.ErrListenerClosed) {
-					log.Errorf("swarm listener for %s accept error: %s", a, err)
+					s.log.Error("swarm listener accept error", "addr", a, "error", err)
 				}
 				return
 			}
@@ -138,7 +195,7 @@ func (s *Swarm) AddListenAddr(a ma.Multiaddr) error {
 				c = wrapWithMetrics(c, s.metricsTracer, time.Now(), network.DirInbound)
 			}
 
-			log.Debugf("swarm listener accepted connection: %s <-> %s", c.LocalMultiaddr(), c.RemoteMultiaddr())
+			s.log.Debug("swarm listener accepted connection", "peer", c.RemotePeer(), "addr", c.RemoteMultiaddr(), "local_addr", c.LocalMultiaddr())
 			s.refs.Add(1)
 			go func() {
 				defer s.refs.Done()
@@ -149,13 +206,13 @@ func (s *Swarm) AddListenAddr(a ma.Multiaddr) error {
 					// ignore.
 					return
 				default:
-					log.Warnw("adding connection failed", "to", a, "error", err)
+					s.log.Warn("adding connection failed", "addr", a, "error", err)
 					return
 				}
 			}()
 		}
 	}()
-	return nil
+	return list, nil
 }
 
 func containsMultiaddr(addrs []ma.Multiaddr, addr ma.Multiaddr) bool {
@@ -18,111 +18,134 @@ import (
 
 const metricNamespace = "libp2p_swarm"
 
-var (
-	connsOpened = prometheus.NewCounterVec(
-		prometheus.CounterOpts{
-			Namespace: metricNamespace,
-			Name:      "connections_opened_total",
-			Help:      "Connections Opened",
-		},
-		[]string{"dir", "transport", "security", "muxer", "early_muxer", "ip_version"},
-	)
-	keyTypes = prometheus.NewCounterVec(
-		prometheus.CounterOpts{
-			Namespace: metricNamespace,
-			Name:      "key_types_total",
-			Help:      "key type",
-		},
-		[]string{"dir", "key_type"},
-	)
-	connsClosed = prometheus.NewCounterVec(
-		prometheus.CounterOpts{
-			Namespace: metricNamespace,
-			Name:      "connections_closed_total",
-			Help:      "Connections Closed",
-		},
-		[]string{"dir", "transport", "security", "muxer", "early_muxer", "ip_version"},
-	)
-	dialError = prometheus.NewCounterVec(
-		prometheus.CounterOpts{
-			Namespace: metricNamespace,
-			Name:      "dial_errors_total",
-			Help:      "Dial Error",
-		},
-		[]string{"transport", "error", "ip_version"},
-	)
-	connDuration = prometheus.NewHistogramVec(
-		prometheus.HistogramOpts{
-			Namespace: metricNamespace,
-			Name:      "connection_duration_seconds",
-			Help:      "Duration of a Connection",
-			Buckets:   prometheus.ExponentialBuckets(1.0/16, 2, 25), // up to 24 days
-		},
-		[]string{"dir", "transport", "security", "muxer", "early_muxer", "ip_version"},
-	)
-	connHandshakeLatency = prometheus.NewHistogramVec(
-		prometheus.HistogramOpts{
-			Namespace: metricNamespace,
-			Name:      "handshake_latency_seconds",
-			Help:      "Duration of the libp2p Handshake",
-			Buckets:   prometheus.ExponentialBuckets(0.001, 1.3, 35),
-		},
-		[]string{"transport", "security", "muxer", "early_muxer", "ip_version"},
-	)
-	dialsPerPeer = prometheus.NewCounterVec(
-		prometheus.CounterOpts{
-			Namespace: metricNamespace,
-			Name:      "dials_per_peer_total",
-			Help:      "Number of addresses dialed per peer",
-		},
-		[]string{"outcome", "num_dials"},
-	)
-	dialRankingDelay = prometheus.NewHistogram(
-		prometheus.HistogramOpts{
-			Namespace: metricNamespace,
-			Name:      "dial_ranking_delay_seconds",
-			Help:      "delay introduced by the dial ranking logic",
-			Buckets:   []float64{0.001, 0.01, 0.05, 0.1, 0.2, 0.3, 0.4, 0.5, 0.75, 1, 2},
-		},
-	)
-	blackHoleSuccessCounterState = prometheus.NewGaugeVec(
-		prometheus.GaugeOpts{
-			Namespace: metricNamespace,
-			Name:      "black_hole_filter_state",
-			Help:      "State of the black hole filter",
-		},
-		[]string{"name"},
-	)
-	blackHoleSuccessCounterSuccessFraction = prometheus.NewGaugeVec(
-		prometheus.GaugeOpts{
-			Namespace: metricNamespace,
-			Name:      "black_hole_filter_success_fraction",
-			Help:      "Fraction of successful dials among the last n requests",
-		},
-		[]string{"name"},
-	)
-	blackHoleSuccessCounterNextRequestAllowedAfter = prometheus.NewGaugeVec(
-		prometheus.GaugeOpts{
-			Namespace: metricNamespace,
-			Name:      "black_hole_filter_next_request_allowed_after",
-			Help:      "Number of requests after which the next request will be allowed",
-		},
-		[]string{"name"},
-	)
-	collectors = []prometheus.Collector{
-		connsOpened,
-		keyTypes,
-		connsClosed,
-		dialError,
-		connDuration,
-		connHandshakeLatency,
-		dialsPerPeer,
-		dialRankingDelay,
-		blackHoleSuccessCounterSuccessFraction,
-		blackHoleSuccessCounterState,
-		blackHoleSuccessCounterNextRequestAllowedAfter,
+type metricsCollectors struct {
+	connsOpened                                    *prometheus.CounterVec
+	keyTypes                                       *prometheus.CounterVec
+	connsClosed                                    *prometheus.CounterVec
+	dialError                                      *prometheus.CounterVec
+	connDuration                                   *prometheus.HistogramVec
+	connHandshakeLatency                           *prometheus.HistogramVec
+	dialsPerPeer                                   *prometheus.CounterVec
+	dialRankingDelay                               prometheus.Histogram
+	blackHoleSuccessCounterState                   *prometheus.GaugeVec
+	blackHoleSuccessCounterSuccessFraction         *prometheus.GaugeVec
+	blackHoleSuccessCounterNextRequestAllowedAfter *prometheus.GaugeVec
+}
+
+func newMetricsCollectors() *metricsCollectors {
+	return &metricsCollectors{
+		connsOpened: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: metricNamespace,
+				Name:      "connections_opened_total",
+				Help:      "Connections Opened",
+			},
+			[]string{"dir", "transport", "security", "muxer", "early_muxer", "ip_version"},
+		),
+		keyTypes: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: metricNamespace,
+				Name:      "key_types_total",
+				Help:      "key type",
+			},
+			[]string{"dir", "key_type"},
+		),
+		connsClosed: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: metricNamespace,
+				Name:      "connections_closed_total",
+				Help:      "Connections Closed",
+			},
+			[]string{"dir", "transport", "security", "muxer", "early_muxer", "ip_version"},
+		),
+		dialError: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: metricNamespace,
+				Name:      "dial_errors_total",
+				Help:      "Dial Error",
+			},
+			[]string{"transport", "error", "ip_version"},
+		),
+		connDuration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace: metricNamespace,
+				Name:      "connection_duration_seconds",
+				Help:      "Duration of a Connection",
+				Buckets:   prometheus.ExponentialBuckets(1.0/16, 2, 25), // up to 24 days
+			},
+			[]string{"dir", "transport", "security", "muxer", "early_muxer", "ip_version"},
+		),
+		connHandshakeLatency: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace: metricNamespace,
+				Name:      "handshake_latency_seconds",
+				Help:      "Duration of the libp2p Handshake",
+				Buckets:   prometheus.ExponentialBuckets(0.001, 1.3, 35),
+			},
+			[]string{"transport", "security", "muxer", "early_muxer", "ip_version"},
+		),
+		dialsPerPeer: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: metricNamespace,
+				Name:      "dials_per_peer_total",
+				Help:      "Number of addresses dialed per peer",
+			},
+			[]string{"outcome", "num_dials"},
+		),
+		dialRankingDelay: prometheus.NewHistogram(
+			prometheus.HistogramOpts{
+				Namespace: metricNamespace,
+				Name:      "dial_ranking_delay_seconds",
+				Help:      "delay introduced by the dial ranking logic",
+				Buckets:   []float64{0.001, 0.01, 0.05, 0.1, 0.2, 0.3, 0.4, 0.5, 0.75, 1, 2},
+			},
+		),
+		blackHoleSuccessCounterState: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: metricNamespace,
+				Name:      "black_hole_filter_state",
+				Help:      "State of the black hole filter",
+			},
+			[]string{"name"},
+		),
+		blackHoleSuccessCounterSuccessFraction: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: metricNamespace,
+				Name:      "black_hole_filter_success_fraction",
+				Help:      "Fraction of successful dials among the last n requests",
+			},
+			[]string{"name"},
+		),
+		blackHoleSuccessCounterNextRequestAllowedAfter: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: metricNamespace,
+				Name:      "black_hole_filter_next_request_allowed_after",
+				Help:      "Number of requests after which the next request will be allowed",
+			},
+			[]string{"name"},
+		),
 	}
-)
+}
+
+func (c *metricsCollectors) Collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		c.connsOpened,
+		c.keyTypes,
+		c.connsClosed,
+		c.dialError,
+		c.connDuration,
+		c.connHandshakeLatency,
+		c.dialsPerPeer,
+		c.dialRankingDelay,
+		c.blackHoleSuccessCounterSuccessFraction,
+		c.blackHoleSuccessCounterState,
+		c.blackHoleSuccessCounterNextRequestAllowedAfter,
+	}
+}
+
+// defaultCollectors are shared by all metrics tracers, unless they register
+// with a metricshelper.LabeledRegisterer.
+var defaultCollectors = newMetricsCollectors()
 
 type MetricsTracer interface {
 	OpenedConnection(network.Direction, crypto.PubKey, network.ConnectionState, ma.Multiaddr)
@@ -134,7 +157,9 @@ type MetricsTracer interface {
 	UpdatedBlackHoleSuccessCounter(name string, state blackHoleState, nextProbeAfter int, successFraction float64)
 }
 
-type metricsTracer struct{}
+type metricsTracer struct {
+	*metricsCollectors
+}
 
 var _ MetricsTracer = &metricsTracer{}
 
@@ -157,8 +182,7 @@ func NewMetricsTracer(opts ...MetricsTracerOption) MetricsTracer {
 	for _, opt := range opts {
 		opt(setting)
 	}
-	metricshelper.RegisterCollectors(setting.reg, collectors...)
-	return &metricsTracer{}
+	return &metricsTracer{metricshelper.Collectors(setting.reg, defaultCollectors, newMetricsCollectors)}
 }
 
 func appendConnectionState(tags []string, cs network.ConnectionState) []string {
@@ -188,12 +212,12 @@ func (m *metricsTracer) OpenedConnection(dir network.Direction, p crypto.PubKey,
 	*tags = append(*tags, metricshelper.GetDirection(dir))
 	*tags = appendConnectionState(*tags, cs)
 	*tags = append(*tags, metricshelper.GetIPVersion(laddr))
-	connsOpened.WithLabelValues(*tags...).Inc()
+	m.connsOpened.WithLabelValues(*tags...).Inc()
 
 	*tags = (*tags)[:0]
 	*tags = append(*tags, metricshelper.GetDirection(dir))
 	*tags = append(*tags, p.Type().String())
-	keyTypes.WithLabelValues(*tags...).Inc()
+	m.keyTypes.WithLabelValues(*tags...).Inc()
 }
 
 func (m *metricsTracer) ClosedConnection(dir network.Direction, duration time.Duration, cs network.ConnectionState, laddr ma.Multiaddr) {
@@ -203,8 +227,8 @@ func (m *metricsTracer) ClosedConnection(dir network.Direction, duration time.Du
 	*tags = append(*tags, metricshelper.GetDirection(dir))
 	*tags = appendConnectionState(*tags, cs)
 	*tags = append(*tags, metricshelper.GetIPVersion(laddr))
-	connsClosed.WithLabelValues(*tags...).Inc()
-	connDuration.WithLabelValues(*tags...).Observe(duration.Seconds())
+	m.connsClosed.WithLabelValues(*tags...).Inc()
+	m.connDuration.WithLabelValues(*tags...).Observe(duration.Seconds())
 }
 
 func (m *metricsTracer) CompletedHandshake(t time.Duration, cs network.ConnectionState, laddr ma.Multiaddr) {
@@ -213,7 +237,7 @@ func (m *metricsTracer) CompletedHandshake(t time.Duration, cs network.Connectio
 
 	*tags = appendConnectionState(*tags, cs)
 	*tags = append(*tags, metricshelper.GetIPVersion(laddr))
-	connHandshakeLatency.WithLabelValues(*tags...).Observe(t.Seconds())
+	m.connHandshakeLatency.WithLabelValues(*tags...).Observe(t.Seconds())
 }
 
 func (m *metricsTracer) FailedDialing(addr ma.Multiaddr, dialErr error, cause error) {
@@ -247,7 +271,7 @@ func (m *metricsTracer) FailedDialing(addr ma.Multiaddr, dialErr error, cause er
 
 	*tags = append(*tags, transport, e)
 	*tags = append(*tags, metricshelper.GetIPVersion(addr))
-	dialError.WithLabelValues(*tags...).Inc()
+	m.dialError.WithLabelValues(*tags...).Inc()
 }
 
 func (m *metricsTracer) DialCompleted(success bool, totalDials int) {
@@ -267,11 +291,11 @@ func (m *metricsTracer) DialCompleted(success bool, totalDials int) {
 		numDials = numDialLabels[len(numDialLabels)-1]
 	}
 	*tags = append(*tags, numDials)
-	dialsPerPeer.WithLabelValues(*tags...).Inc()
+	m.dialsPerPeer.WithLabelValues(*tags...).Inc()
 }
 
 func (m *metricsTracer) DialRankingDelay(d time.Duration) {
-	dialRankingDelay.Observe(d.Seconds())
+	m.dialRankingDelay.Observe(d.Seconds())
 }
 
 func (m *metricsTracer) UpdatedBlackHoleSuccessCounter(name string, state blackHoleState,
@@ -281,7 +305,7 @@ func (m *metricsTracer) UpdatedBlackHoleSuccessCounter(name string, state blackH
 
 	*tags = append(*tags, name)
 
-	blackHoleSuccessCounterState.WithLabelValues(*tags...).Set(float64(state))
-	blackHoleSuccessCounterSuccessFraction.WithLabelValues(*tags...).Set(successFraction)
-	blackHoleSuccessCounterNextRequestAllowedAfter.WithLabelValues(*tags...).Set(float64(nextProbeAfter))
+	m.blackHoleSuccessCounterState.WithLabelValues(*tags...).Set(float64(state))
+	m.blackHoleSuccessCounterSuccessFraction.WithLabelValues(*tags...).Set(successFraction)
+	m.blackHoleSuccessCounterNextRequestAllowedAfter.WithLabelValues(*tags...).Set(float64(nextProbeAfter))
 }
@@ -0,0 +1,63 @@
+package swarm
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/protocol"
+)
+
+var _ network.ProtocolUsageConn = &Conn{}
+
+// protocolStats tracks the usage of a protocol on a connection. It is updated
+// by the streams of the connection without taking the connection lock.
+type protocolStats struct {
+	streams  atomic.Int64
+	bytesIn  atomic.Int64
+	bytesOut atomic.Int64
+	// lastUsed is the unix time in nanoseconds.
+	lastUsed atomic.Int64
+}
+
+func (ps *protocolStats) touch() {
+	ps.lastUsed.Store(time.Now().UnixNano())
+}
+
+func (ps *protocolStats) usage() network.ProtocolUsage {
+	return network.ProtocolUsage{
+		Streams:  int(ps.streams.Load()),
+		BytesIn:  ps.bytesIn.Load(),
+		BytesOut: ps.bytesOut.Load(),
+		LastUsed: time.Unix(0, ps.lastUsed.Load()),
+	}
+}
+
+// protocolStats returns the usage stats of protocol p on this connection,
+// creating them if needed.
+func (c *Conn) protocolStats(p protocol.ID) *protocolStats {
+	c.protocols.Lock()
+	defer c.protocols.Unlock()
+	if c.protocols.m == nil {
+		c.protocols.m = make(map[protocol.ID]*protocolStats)
+	}
+	ps, ok := c.protocols.m[p]
+	if !ok {
+		ps = &protocolStats{}
+		c.protocols.m[p] = ps
+	}
+	return ps
+}
+
+// ProtocolUsage returns the usage of every protocol that had a stream opened
+// over this connection. Only the bytes read and written after the protocol
+// was set on a stream are accounted for.
+func (c *Conn) ProtocolUsage() map[protocol.ID]network.ProtocolUsage {
+	c.protocols.Lock()
+	defer c.protocols.Unlock()
+	usage := make(map[protocol.ID]network.ProtocolUsage, len(c.protocols.m))
+	for p, ps := range c.protocols.m {
+		usage[p] = ps.usage()
+	}
+	return usage
+}
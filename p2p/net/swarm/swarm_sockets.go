@@ -0,0 +1,125 @@
+package swarm
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/libp2p/go-libp2p/core/transport"
+
+	ma "github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
+)
+
+// ListenerSockets reports on the sockets of all listeners.
+func (s *Swarm) ListenerSockets() []transport.ListenerSocket {
+	s.listeners.RLock()
+	listeners := make([]transport.Listener, 0, len(s.listeners.m))
+	for l := range s.listeners.m {
+		listeners = append(listeners, l)
+	}
+	s.listeners.RUnlock()
+
+	sockets := make([]transport.ListenerSocket, 0, len(listeners))
+	for _, l := range listeners {
+		ls := transport.ListenerSocket{
+			Addr:      l.Multiaddr(),
+			Transport: s.TransportForListening(l.Multiaddr()),
+		}
+		if sl, ok := l.(transport.SocketInfoListener); ok {
+			ls.SocketInfo = sl.SocketInfo()
+		} else {
+			ls.LocalAddr = socketAddr(l.Multiaddr())
+		}
+		sockets = append(sockets, ls)
+	}
+
+	for i := range sockets {
+		for j := range sockets {
+			if i == j {
+				continue
+			}
+			a, b := &sockets[i], &sockets[j]
+			switch {
+			case sameSocket(a.SocketInfo, b.SocketInfo):
+				a.SharedWith = append(a.SharedWith, b.Addr)
+			case socketsConflict(a.LocalAddr, b.LocalAddr):
+				a.Conflicts = append(a.Conflicts, b.Addr)
+			}
+		}
+	}
+	return sockets
+}
+
+// SetListenerReusePort sets whether outgoing connections may be dialed from
+// the port of the listener for addr. addr must be an address the swarm is
+// listening on, as returned by ListenAddresses.
+func (s *Swarm) SetListenerReusePort(addr ma.Multiaddr, enable bool) error {
+	s.listeners.RLock()
+	var list transport.Listener
+	for l := range s.listeners.m {
+		if l.Multiaddr().Equal(addr) {
+			list = l
+			break
+		}
+	}
+	s.listeners.RUnlock()
+
+	if list == nil {
+		return fmt.Errorf("%w: %s", ErrNoListener, addr)
+	}
+	rl, ok := list.(transport.ReusePortListener)
+	if !ok {
+		return fmt.Errorf("listener for %s doesn't support port reuse", addr)
+	}
+	return rl.SetReusePort(enable)
+}
+
+// socketAddr derives the address of the socket from the IP and TCP / UDP
+// components of the listen address.
+func socketAddr(a ma.Multiaddr) net.Addr {
+	ip, rest := ma.SplitFirst(a)
+	if ip == nil || rest == nil {
+		return nil
+	}
+	port, _ := ma.SplitFirst(rest)
+	if port == nil {
+		return nil
+	}
+	addr, err := manet.ToNetAddr(ma.Join(ip, port))
+	if err != nil {
+		return nil
+	}
+	return addr
+}
+
+// sameSocket returns true if both sockets are the same, intentionally shared
+// socket.
+func sameSocket(a, b transport.SocketInfo) bool {
+	return a.Shared && b.Shared && a.LocalAddr != nil && b.LocalAddr != nil &&
+		a.LocalAddr.Network() == b.LocalAddr.Network() && a.LocalAddr.String() == b.LocalAddr.String()
+}
+
+// socketsConflict returns true if two different sockets are bound to the same
+// port on overlapping IP addresses.
+func socketsConflict(a, b net.Addr) bool {
+	if a == nil || b == nil || a.Network() != b.Network() {
+		return false
+	}
+	ipA, portA, okA := ipAndPort(a)
+	ipB, portB, okB := ipAndPort(b)
+	if !okA || !okB || portA != portB {
+		return false
+	}
+	return ipA.Equal(ipB) || ipA.IsUnspecified() || ipB.IsUnspecified()
+}
+
+func ipAndPort(a net.Addr) (net.IP, int, bool) {
+	switch a := a.(type) {
+	case *net.TCPAddr:
+		return a.IP, a.Port, true
+	case *net.UDPAddr:
+		return a.IP, a.Port, true
+	default:
+		return nil, 0, false
+	}
+}
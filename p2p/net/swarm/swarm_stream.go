@@ -28,6 +28,8 @@ type Stream struct {
 	acceptStreamGoroutineCompleted bool
 
 	protocol atomic.Pointer[protocol.ID]
+	// usage tracks the usage of the protocol, once it is set.
+	usage atomic.Pointer[protocolStats]
 
 	stat network.Stats
 }
@@ -56,6 +58,10 @@ func (s *Stream) Conn() network.Conn {
 // Read reads bytes from a stream.
 func (s *Stream) Read(p []byte) (int, error) {
 	n, err := s.stream.Read(p)
+	if ps := s.usage.Load(); ps != nil && n > 0 {
+		ps.bytesIn.Add(int64(n))
+		ps.touch()
+	}
 	// TODO: push this down to a lower level for better accuracy.
 	if s.conn.swarm.bwc != nil {
 		s.conn.swarm.bwc.LogRecvMessage(int64(n))
@@ -67,6 +73,10 @@ func (s *Stream) Read(p []byte) (int, error) {
 // Write writes bytes to a stream, flushing for each call.
 func (s *Stream) Write(p []byte) (int, error) {
 	n, err := s.stream.Write(p)
+	if ps := s.usage.Load(); ps != nil && n > 0 {
+		ps.bytesOut.Add(int64(n))
+		ps.touch()
+	}
 	// TODO: push this down to a lower level for better accuracy.
 	if s.conn.swarm.bwc != nil {
 		s.conn.swarm.bwc.LogSentMessage(int64(n))
@@ -151,6 +161,10 @@ func (s *Stream) SetProtocol(p protocol.ID) error {
 	}
 
 	s.protocol.Store(&p)
+	ps := s.conn.protocolStats(p)
+	ps.streams.Add(1)
+	ps.touch()
+	s.usage.Store(ps)
 	return nil
 }
 
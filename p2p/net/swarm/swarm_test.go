@@ -20,6 +20,7 @@ import (
 	"github.com/libp2p/go-libp2p/core/test"
 	"github.com/libp2p/go-libp2p/p2p/net/swarm"
 	. "github.com/libp2p/go-libp2p/p2p/net/swarm/testing"
+	"github.com/libp2p/go-libp2p/p2p/transport/tcp"
 
 	logging "github.com/ipfs/go-log/v2"
 	ma "github.com/multiformats/go-multiaddr"
@@ -457,6 +458,35 @@ func TestStreamCount(t *testing.T) {
 	require.Equal(t, 8, countStreams())
 }
 
+func TestProtocolUsage(t *testing.T) {
+	swarms := makeSwarms(t, 2)
+	s1, s2 := swarms[0], swarms[1]
+	connectSwarms(t, context.Background(), swarms)
+
+	start := time.Now()
+	for i := 0; i < 2; i++ {
+		str, err := s2.NewStream(context.Background(), s1.LocalPeer())
+		require.NoError(t, err)
+		require.NoError(t, str.SetProtocol("/echo"))
+		_, err = str.Write([]byte("ping"))
+		require.NoError(t, err)
+		buf := make([]byte, 4)
+		_, err = io.ReadFull(str, buf)
+		require.NoError(t, err)
+		str.Close()
+	}
+
+	conns := s2.ConnsToPeer(s1.LocalPeer())
+	require.Len(t, conns, 1)
+	usage := conns[0].(network.ProtocolUsageConn).ProtocolUsage()
+	require.Len(t, usage, 1)
+	u := usage["/echo"]
+	require.Equal(t, 2, u.Streams)
+	require.Equal(t, int64(8), u.BytesOut)
+	require.Equal(t, int64(8), u.BytesIn)
+	require.False(t, u.LastUsed.Before(start))
+}
+
 func TestResourceManager(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -568,3 +598,68 @@ func TestListenCloseCount(t *testing.T) {
 	_, err := remainingAddrs[0].ValueForProtocol(ma.P_TCP)
 	require.NoError(t, err, "expected the TCP address to still be present")
 }
+
+func TestListenOnAndStopListening(t *testing.T) {
+	s := GenSwarm(t, OptDialOnly)
+	require.NoError(t, s.ListenOn(ma.StringCast("/ip4/127.0.0.1/tcp/0"), ma.StringCast("/ip4/127.0.0.1/udp/0/quic-v1")))
+	listenedAddrs := s.ListenAddresses()
+	require.Len(t, listenedAddrs, 2)
+
+	// if listening on any of the addresses fails, the swarm doesn't listen on any of them
+	err := s.ListenOn(ma.StringCast("/ip4/127.0.0.1/tcp/0"), ma.StringCast("/ip4/127.0.0.1/udp/0/webrtc-direct"))
+	require.ErrorIs(t, err, swarm.ErrNoTransport)
+	require.ElementsMatch(t, listenedAddrs, s.ListenAddresses())
+
+	var tcpAddr, quicAddr ma.Multiaddr
+	for _, addr := range listenedAddrs {
+		if _, err := addr.ValueForProtocol(ma.P_QUIC_V1); err == nil {
+			quicAddr = addr
+		} else {
+			tcpAddr = addr
+		}
+	}
+	notListening := ma.StringCast("/ip4/127.0.0.1/tcp/1")
+	require.ErrorIs(t, s.StopListening(tcpAddr, notListening), swarm.ErrNoListener)
+	require.Equal(t, []ma.Multiaddr{quicAddr}, s.ListenAddresses())
+
+	// the remaining listener still accepts connections
+	s2 := GenSwarm(t, OptDialOnly)
+	s2.Peerstore().AddAddrs(s.LocalPeer(), s.ListenAddresses(), peerstore.PermanentAddrTTL)
+	_, err = s2.DialPeer(context.Background(), s.LocalPeer())
+	require.NoError(t, err)
+}
+
+func TestListenerSockets(t *testing.T) {
+	if !tcp.ReuseportIsAvailable() {
+		t.Skip("reuseport not available")
+	}
+	s := GenSwarm(t, OptDialOnly)
+	require.NoError(t, s.ListenOn(ma.StringCast("/ip4/127.0.0.1/tcp/0"), ma.StringCast("/ip4/127.0.0.1/udp/0/quic-v1")))
+
+	var tcpAddr ma.Multiaddr
+	for _, ls := range s.ListenerSockets() {
+		require.NotNil(t, ls.Transport)
+		require.NotNil(t, ls.LocalAddr)
+		require.True(t, ls.ReusePort)
+		require.Empty(t, ls.SharedWith)
+		require.Empty(t, ls.Conflicts)
+		if _, err := ls.Addr.ValueForProtocol(ma.P_TCP); err == nil {
+			tcpAddr = ls.Addr
+		}
+	}
+	require.NotNil(t, tcpAddr)
+
+	// with reuseport, we can listen on the same TCP port a second time
+	require.NoError(t, s.ListenOn(tcpAddr))
+	var conflicts int
+	for _, ls := range s.ListenerSockets() {
+		if ls.Addr.Equal(tcpAddr) {
+			require.Equal(t, []ma.Multiaddr{tcpAddr}, ls.Conflicts)
+			conflicts++
+		}
+	}
+	require.Equal(t, 2, conflicts)
+
+	require.NoError(t, s.SetListenerReusePort(tcpAddr, false))
+	require.ErrorIs(t, s.SetListenerReusePort(ma.StringCast("/ip4/127.0.0.1/tcp/1"), false), swarm.ErrNoListener)
+}
@@ -23,7 +23,7 @@ func (s *Swarm) TransportForDialing(a ma.Multiaddr) transport.Transport {
 	if len(s.transports.m) == 0 {
 		// make sure we're not just shutting down.
 		if s.transports.m != nil {
-			log.Error("you have no transports configured")
+			s.log.Error("you have no transports configured")
 		}
 		return nil
 	}
@@ -51,7 +51,7 @@ func (s *Swarm) TransportForListening(a ma.Multiaddr) transport.Transport {
 	if len(s.transports.m) == 0 {
 		// make sure we're not just shutting down.
 		if s.transports.m != nil {
-			log.Error("you have no transports configured")
+			s.log.Error("you have no transports configured")
 		}
 		return nil
 	}
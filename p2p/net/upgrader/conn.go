@@ -19,6 +19,8 @@ type transportConn struct {
 	muxer                     protocol.ID
 	security                  protocol.ID
 	usedEarlyMuxerNegotiation bool
+	cipherSuite               string
+	usedEarlyData             bool
 }
 
 var _ transport.CapableConn = &transportConn{}
@@ -61,5 +63,7 @@ func (t *transportConn) ConnState() network.ConnectionState {
 		Security:                  t.security,
 		Transport:                 "tcp",
 		UsedEarlyMuxerNegotiation: t.usedEarlyMuxerNegotiation,
+		CipherSuite:               t.cipherSuite,
+		UsedEarlyData:             t.usedEarlyData,
 	}
 }
@@ -0,0 +1,95 @@
+package upgrader
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+var errHandshakeQueueFull = errors.New("inbound handshake queue full")
+
+// handshakeLimiter limits the number of concurrent inbound handshakes.
+// Connections that don't get a slot right away wait in a queue of limited
+// length.
+type handshakeLimiter struct {
+	limit    int
+	queueLen int
+	tracer   MetricsTracer
+
+	mx      sync.Mutex
+	active  int
+	queued  int
+	waiters []chan struct{}
+}
+
+func newHandshakeLimiter(limit, queueLen int, tracer MetricsTracer) *handshakeLimiter {
+	return &handshakeLimiter{limit: limit, queueLen: queueLen, tracer: tracer}
+}
+
+// Acquire waits for a handshake slot. It fails if the queue is full, or if the
+// context is done before a slot became available. Release must be called once
+// the handshake is done if, and only if, Acquire succeeded.
+func (l *handshakeLimiter) Acquire(ctx context.Context) error {
+	l.mx.Lock()
+	if l.active < l.limit {
+		l.active++
+		l.notifyLocked()
+		l.mx.Unlock()
+		return nil
+	}
+	if l.queued >= l.queueLen {
+		l.mx.Unlock()
+		return errHandshakeQueueFull
+	}
+	ch := make(chan struct{})
+	l.waiters = append(l.waiters, ch)
+	l.queued++
+	l.notifyLocked()
+	l.mx.Unlock()
+
+	select {
+	case <-ch:
+		// Release handed its slot over to us.
+		return nil
+	case <-ctx.Done():
+	}
+
+	l.mx.Lock()
+	defer l.mx.Unlock()
+	for i, w := range l.waiters {
+		if w == ch {
+			l.waiters = append(l.waiters[:i], l.waiters[i+1:]...)
+			l.queued--
+			l.notifyLocked()
+			return ctx.Err()
+		}
+	}
+	// Release handed its slot over to us concurrently with the context being
+	// canceled. Give it back.
+	l.releaseLocked()
+	return ctx.Err()
+}
+
+func (l *handshakeLimiter) Release() {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+	l.releaseLocked()
+}
+
+func (l *handshakeLimiter) releaseLocked() {
+	if len(l.waiters) > 0 {
+		// hand over the slot to the first connection in the queue
+		close(l.waiters[0])
+		l.waiters = l.waiters[1:]
+		l.queued--
+	} else {
+		l.active--
+	}
+	l.notifyLocked()
+}
+
+func (l *handshakeLimiter) notifyLocked() {
+	if l.tracer != nil {
+		l.tracer.InboundHandshakesChanged(l.active, l.queued)
+	}
+}
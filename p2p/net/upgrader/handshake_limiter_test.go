@@ -0,0 +1,39 @@
+package upgrader
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandshakeLimiter(t *testing.T) {
+	l := newHandshakeLimiter(1, 1, nil)
+	require.NoError(t, l.Acquire(context.Background()))
+
+	// the second handshake waits in the queue, the third one is rejected
+	acquired := make(chan error, 1)
+	go func() { acquired <- l.Acquire(context.Background()) }()
+	require.Eventually(t, func() bool {
+		l.mx.Lock()
+		defer l.mx.Unlock()
+		return l.queued == 1
+	}, time.Second, time.Millisecond)
+	require.ErrorIs(t, l.Acquire(context.Background()), errHandshakeQueueFull)
+
+	// releasing the slot hands it over to the queued handshake
+	l.Release()
+	require.NoError(t, <-acquired)
+	require.Equal(t, 1, l.active)
+	require.Zero(t, l.queued)
+
+	// a handshake stops waiting when its context is done
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, l.Acquire(ctx), context.DeadlineExceeded)
+	require.Zero(t, l.queued)
+
+	l.Release()
+	require.Zero(t, l.active)
+}
@@ -119,7 +119,23 @@ func (l *listener) handleIncoming() {
 			ctx, cancel := context.WithTimeout(l.ctx, l.upgrader.acceptTimeout)
 			defer cancel()
 
+			// Waiting for a handshake slot counts against the accept timeout.
+			limiter := l.upgrader.handshakeLimiter
+			if limiter != nil {
+				if err := limiter.Acquire(ctx); err != nil {
+					log.Debugw("dropping inbound connection", "addr", maconn.RemoteMultiaddr(), "error", err)
+					if l.upgrader.metricsTracer != nil && l.ctx.Err() == nil {
+						l.upgrader.metricsTracer.InboundHandshakeDropped()
+					}
+					maconn.Close()
+					connScope.Done()
+					return
+				}
+			}
 			conn, err := l.upgrader.Upgrade(ctx, l.transport, maconn, network.DirInbound, "", connScope)
+			if limiter != nil {
+				limiter.Release()
+			}
 			if err != nil {
 				// Don't bother bubbling this up. We just failed
 				// to completely negotiate the connection.
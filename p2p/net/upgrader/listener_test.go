@@ -415,3 +415,94 @@ func TestNoCommonSecurityProto(t *testing.T) {
 	ln.Close()
 	<-done
 }
+
+type handshakeTracer struct {
+	mx      sync.Mutex
+	active  int
+	queued  int
+	dropped int
+	stages  []string
+}
+
+var _ upgrader.MetricsTracer = &handshakeTracer{}
+
+func (t *handshakeTracer) StageCompleted(_ network.Direction, stage string, _ time.Duration, _ error) {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	t.stages = append(t.stages, stage)
+}
+
+func (t *handshakeTracer) InboundHandshakesChanged(active, queued int) {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	t.active = active
+	t.queued = queued
+}
+
+func (t *handshakeTracer) InboundHandshakeDropped() {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	t.dropped++
+}
+
+func (t *handshakeTracer) state() (active, queued, dropped int) {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	return t.active, t.queued, t.dropped
+}
+
+func TestInboundHandshakeLimit(t *testing.T) {
+	tracer := &handshakeTracer{}
+	id, u := createUpgraderWithOpts(t, upgrader.WithInboundHandshakeLimit(1, 0), upgrader.WithMetricsTracer(tracer))
+	ln := createListener(t, u)
+	defer ln.Close()
+
+	// This connection never completes the handshake, and takes the only slot.
+	stalled, err := manet.Dial(ln.Multiaddr())
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		active, _, _ := tracer.state()
+		return active == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	_, dialer := createUpgrader(t)
+	_, err = dial(t, dialer, ln.Multiaddr(), id, &network.NullScope{})
+	require.Error(t, err)
+	require.Eventually(t, func() bool {
+		_, _, dropped := tracer.state()
+		return dropped == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// once the stalled handshake fails, the slot is free again
+	stalled.Close()
+	require.Eventually(t, func() bool {
+		active, _, _ := tracer.state()
+		return active == 0
+	}, 5*time.Second, 10*time.Millisecond)
+
+	go ln.Accept()
+	conn, err := dial(t, dialer, ln.Multiaddr(), id, &network.NullScope{})
+	require.NoError(t, err)
+	conn.Close()
+}
+
+func TestSecurityTimeout(t *testing.T) {
+	tracer := &handshakeTracer{}
+	_, u := createUpgraderWithOpts(t, upgrader.WithSecurityTimeout(100*time.Millisecond), upgrader.WithMetricsTracer(tracer))
+	ln := createListener(t, u)
+	defer ln.Close()
+
+	conn, err := manet.Dial(ln.Multiaddr())
+	require.NoError(t, err)
+	defer conn.Close()
+	// the listener closes the connection once the security timeout expires
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	_, err = io.ReadAll(conn)
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		tracer.mx.Lock()
+		defer tracer.mx.Unlock()
+		return len(tracer.stages) == 1 && tracer.stages[0] == upgrader.StageSecurity
+	}, 5*time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,161 @@
+package upgrader
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/p2p/metricshelper"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const metricNamespace = "libp2p_upgrader"
+
+// The stages of a connection upgrade.
+const (
+	StageSecurity = "security"
+	StageMuxer    = "muxer"
+)
+
+type metricsCollectors struct {
+	stagesTotal              *prometheus.CounterVec
+	stageDuration            *prometheus.HistogramVec
+	inboundHandshakesActive  prometheus.Gauge
+	inboundHandshakesQueued  prometheus.Gauge
+	inboundHandshakesDropped prometheus.Counter
+}
+
+func newMetricsCollectors() *metricsCollectors {
+	return &metricsCollectors{
+		stagesTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: metricNamespace,
+				Name:      "stages_total",
+				Help:      "Upgrade stages completed, by outcome",
+			},
+			[]string{"dir", "stage", "outcome"},
+		),
+		stageDuration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace: metricNamespace,
+				Name:      "stage_duration_seconds",
+				Help:      "Duration of successful upgrade stages",
+				Buckets:   []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30},
+			},
+			[]string{"dir", "stage"},
+		),
+		inboundHandshakesActive: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace: metricNamespace,
+				Name:      "inbound_handshakes_active",
+				Help:      "Inbound handshakes in progress",
+			},
+		),
+		inboundHandshakesQueued: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace: metricNamespace,
+				Name:      "inbound_handshakes_queued",
+				Help:      "Inbound connections waiting for a handshake slot",
+			},
+		),
+		inboundHandshakesDropped: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Namespace: metricNamespace,
+				Name:      "inbound_handshakes_dropped_total",
+				Help:      "Inbound connections dropped because the handshake queue was full or they waited too long",
+			},
+		),
+	}
+}
+
+func (c *metricsCollectors) Collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		c.stagesTotal,
+		c.stageDuration,
+		c.inboundHandshakesActive,
+		c.inboundHandshakesQueued,
+		c.inboundHandshakesDropped,
+	}
+}
+
+// defaultCollectors are shared by all metrics tracers, unless they register
+// with a metricshelper.LabeledRegisterer.
+var defaultCollectors = newMetricsCollectors()
+
+// MetricsTracer tracks metrics for connection upgrades.
+type MetricsTracer interface {
+	// StageCompleted is called when a stage (StageSecurity or StageMuxer) of
+	// a connection upgrade completes, with the error it failed with, if any.
+	StageCompleted(dir network.Direction, stage string, d time.Duration, err error)
+	// InboundHandshakesChanged is called when the number of inbound
+	// handshakes in progress, or the number of inbound connections waiting
+	// for a handshake slot changes.
+	InboundHandshakesChanged(active, queued int)
+	// InboundHandshakeDropped is called when an inbound connection is closed
+	// without a handshake because the handshake queue is full, or because it
+	// waited for a handshake slot for too long.
+	InboundHandshakeDropped()
+}
+
+type metricsTracer struct {
+	*metricsCollectors
+}
+
+var _ MetricsTracer = &metricsTracer{}
+
+type metricsTracerSetting struct {
+	reg prometheus.Registerer
+}
+
+type MetricsTracerOption func(*metricsTracerSetting)
+
+func WithRegisterer(reg prometheus.Registerer) MetricsTracerOption {
+	return func(s *metricsTracerSetting) {
+		if reg != nil {
+			s.reg = reg
+		}
+	}
+}
+
+func NewMetricsTracer(opts ...MetricsTracerOption) MetricsTracer {
+	setting := &metricsTracerSetting{reg: prometheus.DefaultRegisterer}
+	for _, opt := range opts {
+		opt(setting)
+	}
+	return &metricsTracer{metricshelper.Collectors(setting.reg, defaultCollectors, newMetricsCollectors)}
+}
+
+func (m *metricsTracer) StageCompleted(dir network.Direction, stage string, d time.Duration, err error) {
+	tags := metricshelper.GetStringSlice()
+	defer metricshelper.PutStringSlice(tags)
+
+	*tags = append(*tags, metricshelper.GetDirection(dir), stage)
+	if err == nil {
+		m.stageDuration.WithLabelValues(*tags...).Observe(d.Seconds())
+	}
+	*tags = append(*tags, getOutcome(err))
+	m.stagesTotal.WithLabelValues(*tags...).Inc()
+}
+
+func (m *metricsTracer) InboundHandshakesChanged(active, queued int) {
+	m.inboundHandshakesActive.Set(float64(active))
+	m.inboundHandshakesQueued.Set(float64(queued))
+}
+
+func (m *metricsTracer) InboundHandshakeDropped() {
+	m.inboundHandshakesDropped.Inc()
+}
+
+func getOutcome(err error) string {
+	if err == nil {
+		return "success"
+	}
+	var nerr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &nerr) && nerr.Timeout()) {
+		return "timeout"
+	}
+	return "error"
+}
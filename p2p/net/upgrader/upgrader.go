@@ -41,6 +41,60 @@ func WithAcceptTimeout(t time.Duration) Option {
 	}
 }
 
+// WithSecurityTimeout limits the time spent on the security stage of a
+// connection upgrade, i.e. negotiating the security protocol and the security
+// handshake. By default, only the accept timeout (for inbound connections)
+// and the dial context (for outbound connections) apply.
+func WithSecurityTimeout(t time.Duration) Option {
+	return func(u *upgrader) error {
+		if t < 0 {
+			return errors.New("security timeout must not be negative")
+		}
+		u.securityTimeout = t
+		return nil
+	}
+}
+
+// WithMuxerTimeout limits the time spent on the muxer stage of a connection
+// upgrade, i.e. negotiating the stream multiplexer. Defaults to 60s.
+func WithMuxerTimeout(t time.Duration) Option {
+	return func(u *upgrader) error {
+		if t <= 0 {
+			return errors.New("muxer timeout must be positive")
+		}
+		u.muxerTimeout = t
+		return nil
+	}
+}
+
+// WithInboundHandshakeLimit limits the number of inbound connections that are
+// upgraded concurrently, across all listeners using this upgrader, to limit.
+// Up to queueLen connections wait for a handshake slot, for at most the accept
+// timeout. Connections beyond that are closed right away, so that a flood of
+// connections doesn't exhaust the CPU on handshakes.
+// By default, the number of concurrent inbound handshakes isn't limited.
+func WithInboundHandshakeLimit(limit, queueLen int) Option {
+	return func(u *upgrader) error {
+		if limit <= 0 {
+			return errors.New("inbound handshake limit must be positive")
+		}
+		if queueLen < 0 {
+			return errors.New("inbound handshake queue length must not be negative")
+		}
+		u.handshakeLimit = limit
+		u.handshakeQueueLen = queueLen
+		return nil
+	}
+}
+
+// WithMetricsTracer configures the upgrader to report metrics using mt.
+func WithMetricsTracer(mt MetricsTracer) Option {
+	return func(u *upgrader) error {
+		u.metricsTracer = mt
+		return nil
+	}
+}
+
 type StreamMuxer struct {
 	ID    protocol.ID
 	Muxer network.Multiplexer
@@ -67,6 +121,18 @@ type upgrader struct {
 	//
 	// If unset, the default value (15s) is used.
 	acceptTimeout time.Duration
+
+	// securityTimeout and muxerTimeout limit the duration of the security
+	// and the muxer stage. A zero securityTimeout means no limit.
+	securityTimeout time.Duration
+	muxerTimeout    time.Duration
+
+	handshakeLimit    int
+	handshakeQueueLen int
+	// handshakeLimiter is nil if inbound handshakes aren't limited.
+	handshakeLimiter *handshakeLimiter
+
+	metricsTracer MetricsTracer
 }
 
 var _ transport.Upgrader = &upgrader{}
@@ -74,6 +140,7 @@ var _ transport.Upgrader = &upgrader{}
 func New(security []sec.SecureTransport, muxers []StreamMuxer, psk ipnet.PSK, rcmgr network.ResourceManager, connGater connmgr.ConnectionGater, opts ...Option) (transport.Upgrader, error) {
 	u := &upgrader{
 		acceptTimeout: defaultAcceptTimeout,
+		muxerTimeout:  defaultNegotiateTimeout,
 		rcmgr:         rcmgr,
 		connGater:     connGater,
 		psk:           psk,
@@ -90,6 +157,9 @@ func New(security []sec.SecureTransport, muxers []StreamMuxer, psk ipnet.PSK, rc
 	if u.rcmgr == nil {
 		u.rcmgr = &network.NullResourceManager{}
 	}
+	if u.handshakeLimit > 0 {
+		u.handshakeLimiter = newHandshakeLimiter(u.handshakeLimit, u.handshakeQueueLen, u.metricsTracer)
+	}
 	u.muxerIDs = make([]protocol.ID, 0, len(muxers))
 	for _, m := range muxers {
 		u.muxerMuxer.AddHandler(m.ID, nil)
@@ -153,7 +223,12 @@ func (u *upgrader) upgrade(ctx context.Context, t transport.Transport, maconn ma
 	}
 
 	isServer := dir == network.DirInbound
+	secStart := time.Now()
 	sconn, security, err := u.setupSecurity(ctx, conn, p, isServer)
+	stat.Timings.Security = time.Since(secStart)
+	if u.metricsTracer != nil {
+		u.metricsTracer.StageCompleted(dir, StageSecurity, stat.Timings.Security, err)
+	}
 	if err != nil {
 		conn.Close()
 		return nil, fmt.Errorf("failed to negotiate security protocol: %w", err)
@@ -180,11 +255,19 @@ func (u *upgrader) upgrade(ctx context.Context, t transport.Transport, maconn ma
 		}
 	}
 
+	muxerStart := time.Now()
 	muxer, smconn, err := u.setupMuxer(ctx, sconn, isServer, connScope.PeerScope())
+	if u.metricsTracer != nil {
+		u.metricsTracer.StageCompleted(dir, StageMuxer, time.Since(muxerStart), err)
+	}
 	if err != nil {
 		sconn.Close()
 		return nil, fmt.Errorf("failed to negotiate stream multiplexer: %w", err)
 	}
+	secState := sconn.ConnState()
+	if !secState.UsedEarlyMuxerNegotiation {
+		stat.Timings.Muxer = time.Since(muxerStart)
+	}
 
 	tc := &transportConn{
 		MuxedConn:                 smconn,
@@ -195,12 +278,19 @@ func (u *upgrader) upgrade(ctx context.Context, t transport.Transport, maconn ma
 		scope:                     connScope,
 		muxer:                     muxer,
 		security:                  security,
-		usedEarlyMuxerNegotiation: sconn.ConnState().UsedEarlyMuxerNegotiation,
+		usedEarlyMuxerNegotiation: secState.UsedEarlyMuxerNegotiation,
+		cipherSuite:               secState.CipherSuite,
+		usedEarlyData:             secState.UsedEarlyData,
 	}
 	return tc, nil
 }
 
 func (u *upgrader) setupSecurity(ctx context.Context, conn net.Conn, p peer.ID, isServer bool) (sec.SecureConn, protocol.ID, error) {
+	if u.securityTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, u.securityTimeout)
+		defer cancel()
+	}
 	st, err := u.negotiateSecurity(ctx, conn, isServer)
 	if err != nil {
 		return nil, "", err
@@ -214,7 +304,7 @@ func (u *upgrader) setupSecurity(ctx context.Context, conn net.Conn, p peer.ID,
 }
 
 func (u *upgrader) negotiateMuxer(nc net.Conn, isServer bool) (*StreamMuxer, error) {
-	if err := nc.SetDeadline(time.Now().Add(defaultNegotiateTimeout)); err != nil {
+	if err := nc.SetDeadline(time.Now().Add(u.muxerTimeout)); err != nil {
 		return nil, err
 	}
 
@@ -267,6 +357,9 @@ func (u *upgrader) setupMuxer(ctx context.Context, conn sec.SecureConn, server b
 		return muxerSelected, c, nil
 	}
 
+	ctx, cancel := context.WithTimeout(ctx, u.muxerTimeout)
+	defer cancel()
+
 	type result struct {
 		smconn  network.MuxedConn
 		muxerID protocol.ID
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"math/rand"
 	"sync"
 	"time"
 
@@ -15,7 +16,6 @@ import (
 	"github.com/libp2p/go-libp2p/p2p/protocol/autonatv2/pb"
 	ma "github.com/multiformats/go-multiaddr"
 	manet "github.com/multiformats/go-multiaddr/net"
-	"golang.org/x/exp/rand"
 	"golang.org/x/exp/slices"
 )
 
@@ -103,7 +103,7 @@ func New(host host.Host, dialerHost host.Host, opts ...AutoNATOption) (*AutoNAT,
 		srv:               newServer(host, dialerHost, s),
 		cli:               newClient(host),
 		allowPrivateAddrs: s.allowPrivateAddrs,
-		peers:             newPeersMap(),
+		peers:             newPeersMap(rand.New(rand.NewSource(s.randSource.Int63()))),
 	}
 	return an, nil
 }
@@ -200,12 +200,14 @@ func (an *AutoNAT) updatePeer(p peer.ID) {
 type peersMap struct {
 	peerIdx map[peer.ID]int
 	peers   []peer.ID
+	rng     *rand.Rand
 }
 
-func newPeersMap() *peersMap {
+func newPeersMap(rng *rand.Rand) *peersMap {
 	return &peersMap{
 		peerIdx: make(map[peer.ID]int),
 		peers:   make([]peer.ID, 0),
+		rng:     rng,
 	}
 }
 
@@ -213,7 +215,7 @@ func (p *peersMap) GetRand() peer.ID {
 	if len(p.peers) == 0 {
 		return ""
 	}
-	return p.peers[rand.Intn(len(p.peers))]
+	return p.peers[p.rng.Intn(len(p.peers))]
 }
 
 func (p *peersMap) Put(pid peer.ID) {
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"math/rand"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -555,7 +556,7 @@ func TestPeersMap(t *testing.T) {
 	emptyPeerID := peer.ID("")
 
 	t.Run("single_item", func(t *testing.T) {
-		p := newPeersMap()
+		p := newPeersMap(rand.New(rand.NewSource(1)))
 		p.Put("peer1")
 		p.Delete("peer1")
 		p.Put("peer1")
@@ -565,7 +566,7 @@ func TestPeersMap(t *testing.T) {
 	})
 
 	t.Run("multiple_items", func(t *testing.T) {
-		p := newPeersMap()
+		p := newPeersMap(rand.New(rand.NewSource(1)))
 		require.Equal(t, emptyPeerID, p.GetRand())
 
 		allPeers := make(map[peer.ID]bool)
@@ -589,6 +590,19 @@ func TestPeersMap(t *testing.T) {
 		}
 		require.Equal(t, emptyPeerID, p.GetRand())
 	})
+
+	t.Run("seeded", func(t *testing.T) {
+		p1 := newPeersMap(rand.New(rand.NewSource(42)))
+		p2 := newPeersMap(rand.New(rand.NewSource(42)))
+		for i := 0; i < 20; i++ {
+			pid := peer.ID(fmt.Sprintf("peer-%d", i))
+			p1.Put(pid)
+			p2.Put(pid)
+		}
+		for i := 0; i < 100; i++ {
+			require.Equal(t, p1.GetRand(), p2.GetRand())
+		}
+	})
 }
 
 func TestAreAddrsConsistency(t *testing.T) {
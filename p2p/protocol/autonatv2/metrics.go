@@ -13,23 +13,37 @@ type MetricsTracer interface {
 
 const metricNamespace = "libp2p_autonatv2"
 
-var (
-	requestsCompleted = prometheus.NewCounterVec(
-		prometheus.CounterOpts{
-			Namespace: metricNamespace,
-			Name:      "requests_completed_total",
-			Help:      "Requests Completed",
-		},
-		[]string{"server_error", "response_status", "dial_status", "dial_data_required", "ip_or_dns_version", "transport"},
-	)
-)
+type metricsCollectors struct {
+	requestsCompleted *prometheus.CounterVec
+}
+
+func newMetricsCollectors() *metricsCollectors {
+	return &metricsCollectors{
+		requestsCompleted: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: metricNamespace,
+				Name:      "requests_completed_total",
+				Help:      "Requests Completed",
+			},
+			[]string{"server_error", "response_status", "dial_status", "dial_data_required", "ip_or_dns_version", "transport"},
+		),
+	}
+}
+
+func (c *metricsCollectors) Collectors() []prometheus.Collector {
+	return []prometheus.Collector{c.requestsCompleted}
+}
+
+// defaultCollectors are shared by all metrics tracers, unless they register
+// with a metricshelper.LabeledRegisterer.
+var defaultCollectors = newMetricsCollectors()
 
 type metricsTracer struct {
+	*metricsCollectors
 }
 
 func NewMetricsTracer(reg prometheus.Registerer) MetricsTracer {
-	metricshelper.RegisterCollectors(reg, requestsCompleted)
-	return &metricsTracer{}
+	return &metricsTracer{metricshelper.Collectors(reg, defaultCollectors, newMetricsCollectors)}
 }
 
 func (m *metricsTracer) CompletedRequest(e EventDialRequestCompleted) {
@@ -57,7 +71,7 @@ func (m *metricsTracer) CompletedRequest(e EventDialRequestCompleted) {
 		ip,
 		transport,
 	)
-	requestsCompleted.WithLabelValues(*labels...).Inc()
+	m.requestsCompleted.WithLabelValues(*labels...).Inc()
 }
 
 func getIPOrDNSVersion(a ma.Multiaddr) string {
@@ -1,6 +1,10 @@
 package autonatv2
 
-import "time"
+import (
+	"errors"
+	"math/rand"
+	"time"
+)
 
 // autoNATSettings is used to configure AutoNAT
 type autoNATSettings struct {
@@ -12,6 +16,7 @@ type autoNATSettings struct {
 	now                                  func() time.Time
 	amplificatonAttackPreventionDialWait time.Duration
 	metricsTracer                        MetricsTracer
+	randSource                           rand.Source
 }
 
 func defaultSettings() *autoNATSettings {
@@ -23,6 +28,7 @@ func defaultSettings() *autoNATSettings {
 		dataRequestPolicy:                    amplificationAttackPrevention,
 		amplificatonAttackPreventionDialWait: 3 * time.Second,
 		now:                                  time.Now,
+		randSource:                           rand.NewSource(rand.Int63()),
 	}
 }
 
@@ -44,6 +50,19 @@ func WithMetricsTracer(m MetricsTracer) AutoNATOption {
 	}
 }
 
+// WithRandSource sets the source of randomness used to select the peers to
+// request dial backs from, and the dial back delay and dial data size of the
+// server. Using a seeded source makes AutoNAT reproducible, e.g. in simulations.
+func WithRandSource(src rand.Source) AutoNATOption {
+	return func(s *autoNATSettings) error {
+		if src == nil {
+			return errors.New("rand source must not be nil")
+		}
+		s.randSource = src
+		return nil
+	}
+}
+
 func withDataRequestPolicy(drp dataRequestPolicyFunc) AutoNATOption {
 	return func(s *autoNATSettings) error {
 		s.dataRequestPolicy = drp
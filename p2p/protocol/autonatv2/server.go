@@ -52,6 +52,9 @@ type server struct {
 	amplificatonAttackPreventionDialWait time.Duration
 	metricsTracer                        MetricsTracer
 
+	rngMx sync.Mutex
+	rng   *rand.Rand
+
 	// for tests
 	now               func() time.Time
 	allowPrivateAddrs bool
@@ -72,9 +75,17 @@ func newServer(host, dialer host.Host, s *autoNATSettings) *server {
 		},
 		now:           s.now,
 		metricsTracer: s.metricsTracer,
+		rng:           rand.New(s.randSource),
 	}
 }
 
+// randIntn returns a random number in [0, n).
+func (as *server) randIntn(n int) int {
+	as.rngMx.Lock()
+	defer as.rngMx.Unlock()
+	return as.rng.Intn(n)
+}
+
 // Enable attaches the stream handler to the host.
 func (as *server) Start() {
 	as.host.SetStreamHandler(DialProtocol, as.handleDialRequest)
@@ -227,7 +238,8 @@ func (as *server) serveDialRequest(s network.Stream) EventDialRequestCompleted {
 	}
 
 	if isDialDataRequired {
-		if err := getDialData(w, s, &msg, addrIdx); err != nil {
+		numBytes := minHandshakeSizeBytes + as.randIntn(maxHandshakeSizeBytes-minHandshakeSizeBytes)
+		if err := getDialData(w, s, &msg, addrIdx, numBytes); err != nil {
 			s.Reset()
 			log.Debugf("%s refused dial data request: %s", p, err)
 			return EventDialRequestCompleted{
@@ -237,7 +249,7 @@ func (as *server) serveDialRequest(s network.Stream) EventDialRequestCompleted {
 			}
 		}
 		// wait for a bit to prevent thundering herd style attacks on a victim
-		waitTime := time.Duration(rand.Intn(int(as.amplificatonAttackPreventionDialWait) + 1)) // the range is [0, n)
+		waitTime := time.Duration(as.randIntn(int(as.amplificatonAttackPreventionDialWait) + 1)) // the range is [0, n)
 		t := time.NewTimer(waitTime)
 		defer t.Stop()
 		select {
@@ -280,8 +292,7 @@ func (as *server) serveDialRequest(s network.Stream) EventDialRequestCompleted {
 }
 
 // getDialData gets data from the client for dialing the address
-func getDialData(w pbio.Writer, s network.Stream, msg *pb.Message, addrIdx int) error {
-	numBytes := minHandshakeSizeBytes + rand.Intn(maxHandshakeSizeBytes-minHandshakeSizeBytes)
+func getDialData(w pbio.Writer, s network.Stream, msg *pb.Message, addrIdx int, numBytes int) error {
 	*msg = pb.Message{
 		Msg: &pb.Message_DialDataRequest{
 			DialDataRequest: &pb.DialDataRequest{
@@ -10,92 +10,109 @@ import (
 
 const metricNamespace = "libp2p_relaysvc"
 
-var (
-	status = prometheus.NewGauge(
-		prometheus.GaugeOpts{
-			Namespace: metricNamespace,
-			Name:      "status",
-			Help:      "Relay Status",
-		},
-	)
-
-	reservationsTotal = prometheus.NewCounterVec(
-		prometheus.CounterOpts{
-			Namespace: metricNamespace,
-			Name:      "reservations_total",
-			Help:      "Relay Reservation Request",
-		},
-		[]string{"type"},
-	)
-	reservationRequestResponseStatusTotal = prometheus.NewCounterVec(
-		prometheus.CounterOpts{
-			Namespace: metricNamespace,
-			Name:      "reservation_request_response_status_total",
-			Help:      "Relay Reservation Request Response Status",
-		},
-		[]string{"status"},
-	)
-	reservationRejectionsTotal = prometheus.NewCounterVec(
-		prometheus.CounterOpts{
-			Namespace: metricNamespace,
-			Name:      "reservation_rejections_total",
-			Help:      "Relay Reservation Rejected Reason",
-		},
-		[]string{"reason"},
-	)
-
-	connectionsTotal = prometheus.NewCounterVec(
-		prometheus.CounterOpts{
-			Namespace: metricNamespace,
-			Name:      "connections_total",
-			Help:      "Relay Connection Total",
-		},
-		[]string{"type"},
-	)
-	connectionRequestResponseStatusTotal = prometheus.NewCounterVec(
-		prometheus.CounterOpts{
-			Namespace: metricNamespace,
-			Name:      "connection_request_response_status_total",
-			Help:      "Relay Connection Request Status",
-		},
-		[]string{"status"},
-	)
-	connectionRejectionsTotal = prometheus.NewCounterVec(
-		prometheus.CounterOpts{
-			Namespace: metricNamespace,
-			Name:      "connection_rejections_total",
-			Help:      "Relay Connection Rejected Reason",
-		},
-		[]string{"reason"},
-	)
-	connectionDurationSeconds = prometheus.NewHistogram(
-		prometheus.HistogramOpts{
-			Namespace: metricNamespace,
-			Name:      "connection_duration_seconds",
-			Help:      "Relay Connection Duration",
-		},
-	)
-
-	dataTransferredBytesTotal = prometheus.NewCounter(
-		prometheus.CounterOpts{
-			Namespace: metricNamespace,
-			Name:      "data_transferred_bytes_total",
-			Help:      "Bytes Transferred Total",
-		},
-	)
-
-	collectors = []prometheus.Collector{
-		status,
-		reservationsTotal,
-		reservationRequestResponseStatusTotal,
-		reservationRejectionsTotal,
-		connectionsTotal,
-		connectionRequestResponseStatusTotal,
-		connectionRejectionsTotal,
-		connectionDurationSeconds,
-		dataTransferredBytesTotal,
+type metricsCollectors struct {
+	status                                prometheus.Gauge
+	reservationsTotal                     *prometheus.CounterVec
+	reservationRequestResponseStatusTotal *prometheus.CounterVec
+	reservationRejectionsTotal            *prometheus.CounterVec
+	connectionsTotal                      *prometheus.CounterVec
+	connectionRequestResponseStatusTotal  *prometheus.CounterVec
+	connectionRejectionsTotal             *prometheus.CounterVec
+	connectionDurationSeconds             prometheus.Histogram
+	dataTransferredBytesTotal             prometheus.Counter
+}
+
+func newMetricsCollectors() *metricsCollectors {
+	return &metricsCollectors{
+		status: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace: metricNamespace,
+				Name:      "status",
+				Help:      "Relay Status",
+			},
+		),
+		reservationsTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: metricNamespace,
+				Name:      "reservations_total",
+				Help:      "Relay Reservation Request",
+			},
+			[]string{"type"},
+		),
+		reservationRequestResponseStatusTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: metricNamespace,
+				Name:      "reservation_request_response_status_total",
+				Help:      "Relay Reservation Request Response Status",
+			},
+			[]string{"status"},
+		),
+		reservationRejectionsTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: metricNamespace,
+				Name:      "reservation_rejections_total",
+				Help:      "Relay Reservation Rejected Reason",
+			},
+			[]string{"reason"},
+		),
+		connectionsTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: metricNamespace,
+				Name:      "connections_total",
+				Help:      "Relay Connection Total",
+			},
+			[]string{"type"},
+		),
+		connectionRequestResponseStatusTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: metricNamespace,
+				Name:      "connection_request_response_status_total",
+				Help:      "Relay Connection Request Status",
+			},
+			[]string{"status"},
+		),
+		connectionRejectionsTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: metricNamespace,
+				Name:      "connection_rejections_total",
+				Help:      "Relay Connection Rejected Reason",
+			},
+			[]string{"reason"},
+		),
+		connectionDurationSeconds: prometheus.NewHistogram(
+			prometheus.HistogramOpts{
+				Namespace: metricNamespace,
+				Name:      "connection_duration_seconds",
+				Help:      "Relay Connection Duration",
+			},
+		),
+		dataTransferredBytesTotal: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Namespace: metricNamespace,
+				Name:      "data_transferred_bytes_total",
+				Help:      "Bytes Transferred Total",
+			},
+		),
 	}
-)
+}
+
+func (c *metricsCollectors) Collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		c.status,
+		c.reservationsTotal,
+		c.reservationRequestResponseStatusTotal,
+		c.reservationRejectionsTotal,
+		c.connectionsTotal,
+		c.connectionRequestResponseStatusTotal,
+		c.connectionRejectionsTotal,
+		c.connectionDurationSeconds,
+		c.dataTransferredBytesTotal,
+	}
+}
+
+// defaultCollectors are shared by all metrics tracers, unless they register
+// with a metricshelper.LabeledRegisterer.
+var defaultCollectors = newMetricsCollectors()
 
 const (
 	requestStatusOK       = "ok"
@@ -126,7 +143,9 @@ type MetricsTracer interface {
 	BytesTransferred(cnt int)
 }
 
-type metricsTracer struct{}
+type metricsTracer struct {
+	*metricsCollectors
+}
 
 var _ MetricsTracer = &metricsTracer{}
 
@@ -149,15 +168,14 @@ func NewMetricsTracer(opts ...MetricsTracerOption) MetricsTracer {
 	for _, opt := range opts {
 		opt(setting)
 	}
-	metricshelper.RegisterCollectors(setting.reg, collectors...)
-	return &metricsTracer{}
+	return &metricsTracer{metricshelper.Collectors(setting.reg, defaultCollectors, newMetricsCollectors)}
 }
 
 func (mt *metricsTracer) RelayStatus(enabled bool) {
 	if enabled {
-		status.Set(1)
+		mt.status.Set(1)
 	} else {
-		status.Set(0)
+		mt.status.Set(0)
 	}
 }
 
@@ -166,7 +184,7 @@ func (mt *metricsTracer) ConnectionOpened() {
 	defer metricshelper.PutStringSlice(tags)
 	*tags = append(*tags, "opened")
 
-	connectionsTotal.WithLabelValues(*tags...).Add(1)
+	mt.connectionsTotal.WithLabelValues(*tags...).Add(1)
 }
 
 func (mt *metricsTracer) ConnectionClosed(d time.Duration) {
@@ -174,8 +192,8 @@ func (mt *metricsTracer) ConnectionClosed(d time.Duration) {
 	defer metricshelper.PutStringSlice(tags)
 	*tags = append(*tags, "closed")
 
-	connectionsTotal.WithLabelValues(*tags...).Add(1)
-	connectionDurationSeconds.Observe(d.Seconds())
+	mt.connectionsTotal.WithLabelValues(*tags...).Add(1)
+	mt.connectionDurationSeconds.Observe(d.Seconds())
 }
 
 func (mt *metricsTracer) ConnectionRequestHandled(status pbv2.Status) {
@@ -185,11 +203,11 @@ func (mt *metricsTracer) ConnectionRequestHandled(status pbv2.Status) {
 	respStatus := getResponseStatus(status)
 
 	*tags = append(*tags, respStatus)
-	connectionRequestResponseStatusTotal.WithLabelValues(*tags...).Add(1)
+	mt.connectionRequestResponseStatusTotal.WithLabelValues(*tags...).Add(1)
 	if respStatus == requestStatusRejected {
 		*tags = (*tags)[:0]
 		*tags = append(*tags, getRejectionReason(status))
-		connectionRejectionsTotal.WithLabelValues(*tags...).Add(1)
+		mt.connectionRejectionsTotal.WithLabelValues(*tags...).Add(1)
 	}
 }
 
@@ -202,7 +220,7 @@ func (mt *metricsTracer) ReservationAllowed(isRenewal bool) {
 		*tags = append(*tags, "opened")
 	}
 
-	reservationsTotal.WithLabelValues(*tags...).Add(1)
+	mt.reservationsTotal.WithLabelValues(*tags...).Add(1)
 }
 
 func (mt *metricsTracer) ReservationClosed(cnt int) {
@@ -210,7 +228,7 @@ func (mt *metricsTracer) ReservationClosed(cnt int) {
 	defer metricshelper.PutStringSlice(tags)
 	*tags = append(*tags, "closed")
 
-	reservationsTotal.WithLabelValues(*tags...).Add(float64(cnt))
+	mt.reservationsTotal.WithLabelValues(*tags...).Add(float64(cnt))
 }
 
 func (mt *metricsTracer) ReservationRequestHandled(status pbv2.Status) {
@@ -220,16 +238,16 @@ func (mt *metricsTracer) ReservationRequestHandled(status pbv2.Status) {
 	respStatus := getResponseStatus(status)
 
 	*tags = append(*tags, respStatus)
-	reservationRequestResponseStatusTotal.WithLabelValues(*tags...).Add(1)
+	mt.reservationRequestResponseStatusTotal.WithLabelValues(*tags...).Add(1)
 	if respStatus == requestStatusRejected {
 		*tags = (*tags)[:0]
 		*tags = append(*tags, getRejectionReason(status))
-		reservationRejectionsTotal.WithLabelValues(*tags...).Add(1)
+		mt.reservationRejectionsTotal.WithLabelValues(*tags...).Add(1)
 	}
 }
 
 func (mt *metricsTracer) BytesTransferred(cnt int) {
-	dataTransferredBytesTotal.Add(float64(cnt))
+	mt.dataTransferredBytesTotal.Add(float64(cnt))
 }
 
 func getResponseStatus(status pbv2.Status) string {
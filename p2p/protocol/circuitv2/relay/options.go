@@ -1,5 +1,12 @@
 package relay
 
+import (
+	"errors"
+	"log/slog"
+
+	"github.com/libp2p/go-libp2p/p2p/slogshim"
+)
+
 type Option func(*Relay) error
 
 // WithResources is a Relay option that sets specific relay resources for the relay.
@@ -41,3 +48,15 @@ func WithMetricsTracer(mt MetricsTracer) Option {
 		return nil
 	}
 }
+
+// WithLogger is a Relay option that sets the logger used by the relay. By
+// default, the relay logs to the go-log logger "relay".
+func WithLogger(l *slog.Logger) Option {
+	return func(r *Relay) error {
+		if l == nil {
+			return errors.New("relay: logger cannot be nil")
+		}
+		r.log = slogshim.ForSubsystem(l, "relay")
+		return nil
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -16,8 +17,8 @@ import (
 	pbv2 "github.com/libp2p/go-libp2p/p2p/protocol/circuitv2/pb"
 	"github.com/libp2p/go-libp2p/p2p/protocol/circuitv2/proto"
 	"github.com/libp2p/go-libp2p/p2p/protocol/circuitv2/util"
+	"github.com/libp2p/go-libp2p/p2p/slogshim"
 
-	logging "github.com/ipfs/go-log/v2"
 	pool "github.com/libp2p/go-buffer-pool"
 	ma "github.com/multiformats/go-multiaddr"
 	manet "github.com/multiformats/go-multiaddr/net"
@@ -38,7 +39,7 @@ const (
 	maxMessageSize = 4096
 )
 
-var log = logging.Logger("relay")
+var log = slogshim.Logger("relay")
 
 // Relay is the (limited) relay service object.
 type Relay struct {
@@ -60,6 +61,7 @@ type Relay struct {
 	selfAddr ma.Multiaddr
 
 	metricsTracer MetricsTracer
+	log           *slog.Logger
 }
 
 // New constructs a new limited relay that can provide relay services in the given host.
@@ -74,6 +76,7 @@ func New(h host.Host, opts ...Option) (*Relay, error) {
 		acl:    nil,
 		rsvp:   make(map[peer.ID]time.Time),
 		conns:  make(map[peer.ID]int),
+		log:    log,
 	}
 
 	for _, opt := range opts {
@@ -129,17 +132,30 @@ func (r *Relay) Close() error {
 	return nil
 }
 
+// Reservations returns the peers that currently hold a reservation, with the
+// expiration time of their reservation.
+func (r *Relay) Reservations() map[peer.ID]time.Time {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	rsvps := make(map[peer.ID]time.Time, len(r.rsvp))
+	for p, expire := range r.rsvp {
+		rsvps[p] = expire
+	}
+	return rsvps
+}
+
 func (r *Relay) handleStream(s network.Stream) {
-	log.Infof("new relay stream from: %s", s.Conn().RemotePeer())
+	r.log.Info("new relay stream", "peer", s.Conn().RemotePeer(), "conn", s.Conn().ID())
 
 	if err := s.Scope().SetService(ServiceName); err != nil {
-		log.Debugf("error attaching stream to relay service: %s", err)
+		r.log.Debug("error attaching stream to relay service", "peer", s.Conn().RemotePeer(), "error", err)
 		s.Reset()
 		return
 	}
 
 	if err := s.Scope().ReserveMemory(maxMessageSize, network.ReservationPriorityAlways); err != nil {
-		log.Debugf("error reserving memory for stream: %s", err)
+		r.log.Debug("error reserving memory for stream", "peer", s.Conn().RemotePeer(), "error", err)
 		s.Reset()
 		return
 	}
@@ -181,13 +197,13 @@ func (r *Relay) handleReserve(s network.Stream) pbv2.Status {
 	a := s.Conn().RemoteMultiaddr()
 
 	if isRelayAddr(a) {
-		log.Debugf("refusing relay reservation for %s; reservation attempt over relay connection")
+		r.log.Debug("refusing relay reservation; reservation attempt over relay connection", "peer", p, "addr", a)
 		r.handleError(s, pbv2.Status_PERMISSION_DENIED)
 		return pbv2.Status_PERMISSION_DENIED
 	}
 
 	if r.acl != nil && !r.acl.AllowReserve(p, a) {
-		log.Debugf("refusing relay reservation for %s; permission denied", p)
+		r.log.Debug("refusing relay reservation; permission denied", "peer", p, "addr", a)
 		r.handleError(s, pbv2.Status_PERMISSION_DENIED)
 		return pbv2.Status_PERMISSION_DENIED
 	}
@@ -197,7 +213,7 @@ func (r *Relay) handleReserve(s network.Stream) pbv2.Status {
 	// Close() call
 	if r.closed {
 		r.mx.Unlock()
-		log.Debugf("refusing relay reservation for %s; relay closed", p)
+		r.log.Debug("refusing relay reservation; relay closed", "peer", p)
 		r.handleError(s, pbv2.Status_PERMISSION_DENIED)
 		return pbv2.Status_PERMISSION_DENIED
 	}
@@ -207,7 +223,7 @@ func (r *Relay) handleReserve(s network.Stream) pbv2.Status {
 	if !exists {
 		if err := r.constraints.AddReservation(p, a); err != nil {
 			r.mx.Unlock()
-			log.Debugf("refusing relay reservation for %s; IP constraint violation: %s", p, err)
+			r.log.Debug("refusing relay reservation; IP constraint violation", "peer", p, "addr", a, "error", err)
 			r.handleError(s, pbv2.Status_RESERVATION_REFUSED)
 			return pbv2.Status_RESERVATION_REFUSED
 		}
@@ -221,13 +237,13 @@ func (r *Relay) handleReserve(s network.Stream) pbv2.Status {
 		r.metricsTracer.ReservationAllowed(exists)
 	}
 
-	log.Debugf("reserving relay slot for %s", p)
+	r.log.Debug("reserving relay slot", "peer", p)
 
 	// Delivery of the reservation might fail for a number of reasons.
 	// For example, the stream might be reset or the connection might be closed before the reservation is received.
 	// In that case, the reservation will just be garbage collected later.
 	if err := r.writeResponse(s, pbv2.Status_OK, r.makeReservationMsg(p, expire), r.makeLimitMsg(p)); err != nil {
-		log.Debugf("error writing reservation response; retracting reservation for %s", p)
+		r.log.Debug("error writing reservation response; retracting reservation", "peer", p, "error", err)
 		s.Reset()
 		return pbv2.Status_CONNECTION_FAILED
 	}
@@ -240,7 +256,7 @@ func (r *Relay) handleConnect(s network.Stream, msg *pbv2.HopMessage) pbv2.Statu
 
 	span, err := r.scope.BeginSpan()
 	if err != nil {
-		log.Debugf("failed to begin relay transaction: %s", err)
+		r.log.Debug("failed to begin relay transaction", "peer", src, "error", err)
 		r.handleError(s, pbv2.Status_RESOURCE_LIMIT_EXCEEDED)
 		return pbv2.Status_RESOURCE_LIMIT_EXCEEDED
 	}
@@ -252,13 +268,13 @@ func (r *Relay) handleConnect(s network.Stream, msg *pbv2.HopMessage) pbv2.Statu
 
 	// reserve buffers for the relay
 	if err := span.ReserveMemory(2*r.rc.BufferSize, network.ReservationPriorityHigh); err != nil {
-		log.Debugf("error reserving memory for relay: %s", err)
+		r.log.Debug("error reserving memory for relay", "peer", src, "error", err)
 		fail(pbv2.Status_RESOURCE_LIMIT_EXCEEDED)
 		return pbv2.Status_RESOURCE_LIMIT_EXCEEDED
 	}
 
 	if isRelayAddr(a) {
-		log.Debugf("refusing connection from %s; connection attempt over relay connection")
+		r.log.Debug("refusing connection; connection attempt over relay connection", "peer", src, "addr", a)
 		fail(pbv2.Status_PERMISSION_DENIED)
 		return pbv2.Status_PERMISSION_DENIED
 	}
@@ -270,7 +286,7 @@ func (r *Relay) handleConnect(s network.Stream, msg *pbv2.HopMessage) pbv2.Statu
 	}
 
 	if r.acl != nil && !r.acl.AllowConnect(src, s.Conn().RemoteMultiaddr(), dest.ID) {
-		log.Debugf("refusing connection from %s to %s; permission denied", src, dest.ID)
+		r.log.Debug("refusing connection; permission denied", "peer", src, "dest", dest.ID)
 		fail(pbv2.Status_PERMISSION_DENIED)
 		return pbv2.Status_PERMISSION_DENIED
 	}
@@ -279,7 +295,7 @@ func (r *Relay) handleConnect(s network.Stream, msg *pbv2.HopMessage) pbv2.Statu
 	_, rsvp := r.rsvp[dest.ID]
 	if !rsvp {
 		r.mx.Unlock()
-		log.Debugf("refusing connection from %s to %s; no reservation", src, dest.ID)
+		r.log.Debug("refusing connection; no reservation", "peer", src, "dest", dest.ID)
 		fail(pbv2.Status_NO_RESERVATION)
 		return pbv2.Status_NO_RESERVATION
 	}
@@ -287,7 +303,7 @@ func (r *Relay) handleConnect(s network.Stream, msg *pbv2.HopMessage) pbv2.Statu
 	srcConns := r.conns[src]
 	if srcConns >= r.rc.MaxCircuits {
 		r.mx.Unlock()
-		log.Debugf("refusing connection from %s to %s; too many connections from %s", src, dest.ID, src)
+		r.log.Debug("refusing connection; too many connections from peer", "peer", src, "dest", dest.ID)
 		fail(pbv2.Status_RESOURCE_LIMIT_EXCEEDED)
 		return pbv2.Status_RESOURCE_LIMIT_EXCEEDED
 	}
@@ -295,7 +311,7 @@ func (r *Relay) handleConnect(s network.Stream, msg *pbv2.HopMessage) pbv2.Statu
 	destConns := r.conns[dest.ID]
 	if destConns >= r.rc.MaxCircuits {
 		r.mx.Unlock()
-		log.Debugf("refusing connection from %s to %s; too many connections to %s", src, dest.ID, dest.ID)
+		r.log.Debug("refusing connection; too many connections to dest", "peer", src, "dest", dest.ID)
 		fail(pbv2.Status_RESOURCE_LIMIT_EXCEEDED)
 		return pbv2.Status_RESOURCE_LIMIT_EXCEEDED
 	}
@@ -327,7 +343,7 @@ func (r *Relay) handleConnect(s network.Stream, msg *pbv2.HopMessage) pbv2.Statu
 
 	bs, err := r.host.NewStream(ctx, dest.ID, proto.ProtoIDv2Stop)
 	if err != nil {
-		log.Debugf("error opening relay stream to %s: %s", dest.ID, err)
+		r.log.Debug("error opening relay stream", "peer", src, "dest", dest.ID, "error", err)
 		cleanup()
 		r.handleError(s, pbv2.Status_CONNECTION_FAILED)
 		return pbv2.Status_CONNECTION_FAILED
@@ -340,14 +356,14 @@ func (r *Relay) handleConnect(s network.Stream, msg *pbv2.HopMessage) pbv2.Statu
 	}
 
 	if err := bs.Scope().SetService(ServiceName); err != nil {
-		log.Debugf("error attaching stream to relay service: %s", err)
+		r.log.Debug("error attaching stream to relay service", "peer", src, "dest", dest.ID, "error", err)
 		fail(pbv2.Status_RESOURCE_LIMIT_EXCEEDED)
 		return pbv2.Status_RESOURCE_LIMIT_EXCEEDED
 	}
 
 	// handshake
 	if err := bs.Scope().ReserveMemory(maxMessageSize, network.ReservationPriorityAlways); err != nil {
-		log.Debugf("error reserving memory for stream: %s", err)
+		r.log.Debug("error reserving memory for stream", "peer", src, "dest", dest.ID, "error", err)
 		fail(pbv2.Status_RESOURCE_LIMIT_EXCEEDED)
 		return pbv2.Status_RESOURCE_LIMIT_EXCEEDED
 	}
@@ -366,7 +382,7 @@ func (r *Relay) handleConnect(s network.Stream, msg *pbv2.HopMessage) pbv2.Statu
 
 	err = wr.WriteMsg(&stopmsg)
 	if err != nil {
-		log.Debugf("error writing stop handshake")
+		r.log.Debug("error writing stop handshake", "peer", src, "dest", dest.ID, "error", err)
 		fail(pbv2.Status_CONNECTION_FAILED)
 		return pbv2.Status_CONNECTION_FAILED
 	}
@@ -375,19 +391,19 @@ func (r *Relay) handleConnect(s network.Stream, msg *pbv2.HopMessage) pbv2.Statu
 
 	err = rd.ReadMsg(&stopmsg)
 	if err != nil {
-		log.Debugf("error reading stop response: %s", err.Error())
+		r.log.Debug("error reading stop response", "peer", src, "dest", dest.ID, "error", err)
 		fail(pbv2.Status_CONNECTION_FAILED)
 		return pbv2.Status_CONNECTION_FAILED
 	}
 
 	if t := stopmsg.GetType(); t != pbv2.StopMessage_STATUS {
-		log.Debugf("unexpected stop response; not a status message (%d)", t)
+		r.log.Debug("unexpected stop response; not a status message", "peer", src, "dest", dest.ID, "type", t)
 		fail(pbv2.Status_CONNECTION_FAILED)
 		return pbv2.Status_CONNECTION_FAILED
 	}
 
 	if status := stopmsg.GetStatus(); status != pbv2.Status_OK {
-		log.Debugf("relay stop failure: %d", status)
+		r.log.Debug("relay stop failure", "peer", src, "dest", dest.ID, "status", status)
 		fail(pbv2.Status_CONNECTION_FAILED)
 		return pbv2.Status_CONNECTION_FAILED
 	}
@@ -400,7 +416,7 @@ func (r *Relay) handleConnect(s network.Stream, msg *pbv2.HopMessage) pbv2.Statu
 	wr = util.NewDelimitedWriter(s)
 	err = wr.WriteMsg(&response)
 	if err != nil {
-		log.Debugf("error writing relay response: %s", err)
+		r.log.Debug("error writing relay response", "peer", src, "dest", dest.ID, "error", err)
 		bs.Reset()
 		s.Reset()
 		cleanup()
@@ -410,7 +426,7 @@ func (r *Relay) handleConnect(s network.Stream, msg *pbv2.HopMessage) pbv2.Statu
 	// reset deadline
 	bs.SetDeadline(time.Time{})
 
-	log.Infof("relaying connection from %s to %s", src, dest.ID)
+	r.log.Info("relaying connection", "peer", src, "dest", dest.ID)
 
 	var goroutines atomic.Int32
 	goroutines.Store(2)
@@ -467,7 +483,7 @@ func (r *Relay) relayLimited(src, dest network.Stream, srcID, destID peer.ID, li
 
 	count, err := r.copyWithBuffer(dest, limitedSrc, buf)
 	if err != nil {
-		log.Debugf("relay copy error: %s", err)
+		r.log.Debug("relay copy error", "peer", srcID, "dest", destID, "error", err)
 		// Reset both.
 		src.Reset()
 		dest.Reset()
@@ -480,7 +496,7 @@ func (r *Relay) relayLimited(src, dest network.Stream, srcID, destID peer.ID, li
 		}
 	}
 
-	log.Debugf("relayed %d bytes from %s to %s", count, srcID, destID)
+	r.log.Debug("relayed bytes", "peer", srcID, "dest", destID, "bytes", count)
 }
 
 func (r *Relay) relayUnlimited(src, dest network.Stream, srcID, destID peer.ID, done func()) {
@@ -491,7 +507,7 @@ func (r *Relay) relayUnlimited(src, dest network.Stream, srcID, destID peer.ID,
 
 	count, err := r.copyWithBuffer(dest, src, buf)
 	if err != nil {
-		log.Debugf("relay copy error: %s", err)
+		r.log.Debug("relay copy error", "peer", srcID, "dest", destID, "error", err)
 		// Reset both.
 		src.Reset()
 		dest.Reset()
@@ -500,7 +516,7 @@ func (r *Relay) relayUnlimited(src, dest network.Stream, srcID, destID peer.ID,
 		dest.CloseWrite()
 	}
 
-	log.Debugf("relayed %d bytes from %s to %s", count, srcID, destID)
+	r.log.Debug("relayed bytes", "peer", srcID, "dest", destID, "bytes", count)
 }
 
 // errInvalidWrite means that a write returned an impossible count.
@@ -545,11 +561,11 @@ func (r *Relay) copyWithBuffer(dst io.Writer, src io.Reader, buf []byte) (writte
 }
 
 func (r *Relay) handleError(s network.Stream, status pbv2.Status) {
-	log.Debugf("relay error: %s (%d)", pbv2.Status_name[int32(status)], status)
+	r.log.Debug("relay error", "peer", s.Conn().RemotePeer(), "status", status)
 	err := r.writeResponse(s, status, nil, nil)
 	if err != nil {
 		s.Reset()
-		log.Debugf("error writing relay response: %s", err.Error())
+		r.log.Debug("error writing relay response", "peer", s.Conn().RemotePeer(), "error", err)
 	} else {
 		s.Close()
 	}
@@ -595,13 +611,13 @@ func (r *Relay) makeReservationMsg(p peer.ID, expire time.Time) *pbv2.Reservatio
 
 	envelope, err := record.Seal(voucher, r.host.Peerstore().PrivKey(r.host.ID()))
 	if err != nil {
-		log.Errorf("error sealing voucher for %s: %s", p, err)
+		r.log.Error("error sealing voucher", "peer", p, "error", err)
 		return rsvp
 	}
 
 	blob, err := envelope.Marshal()
 	if err != nil {
-		log.Errorf("error marshalling voucher for %s: %s", p, err)
+		r.log.Error("error marshalling voucher", "peer", p, "error", err)
 		return rsvp
 	}
 
@@ -143,6 +143,10 @@ func TestBasicRelay(t *testing.T) {
 		t.Fatal("no reservation voucher")
 	}
 
+	if _, ok := r.Reservations()[hosts[0].ID()]; !ok {
+		t.Fatal("expected reservation to be reported")
+	}
+
 	raddr, err := ma.NewMultiaddr(fmt.Sprintf("/p2p/%s/p2p-circuit/p2p/%s", hosts[1].ID(), hosts[0].ID()))
 	if err != nil {
 		t.Fatal(err)
@@ -13,6 +13,7 @@ import (
 	"github.com/libp2p/go-libp2p/core/network"
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/libp2p/go-libp2p/core/peerstore"
+	basichost "github.com/libp2p/go-libp2p/p2p/host/basic"
 	"github.com/libp2p/go-libp2p/p2p/protocol/circuitv2/proto"
 	relayv2 "github.com/libp2p/go-libp2p/p2p/protocol/circuitv2/relay"
 	"github.com/libp2p/go-libp2p/p2p/protocol/holepunch"
@@ -154,6 +155,18 @@ func TestEndToEndSimConnect(t *testing.T) {
 	require.Equal(t, holepunch.HolePunchAttemptEvtT, h2Events[1].Type)
 	require.Equal(t, holepunch.EndHolePunchEvtT, h2Events[2].Type)
 
+	// the receiving host keeps track of the hole punch
+	type peerConnectivity interface {
+		PeerConnectivity(peer.ID) basichost.PeerConnectivity
+	}
+	require.Eventually(t,
+		func() bool {
+			return h2.(peerConnectivity).PeerConnectivity(h1.ID()).HolePunchAttempted
+		},
+		time.Second,
+		10*time.Millisecond,
+	)
+
 	h1Events := h1tr.getEvents()
 	// We don't really expect a hole-punched connection to be established in this test,
 	// as we probably don't get the timing right for the TCP simultaneous open.
@@ -7,6 +7,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/libp2p/go-libp2p/core/event"
 	"github.com/libp2p/go-libp2p/core/host"
 	"github.com/libp2p/go-libp2p/core/network"
 	"github.com/libp2p/go-libp2p/core/peer"
@@ -48,17 +49,21 @@ type holePuncher struct {
 	closeMx sync.RWMutex
 	closed  bool
 
-	tracer *tracer
-	filter AddrFilter
+	tracer  *tracer
+	emitter event.Emitter
+	filter  AddrFilter
+	retry   retryConfig
 }
 
-func newHolePuncher(h host.Host, ids identify.IDService, tracer *tracer, filter AddrFilter) *holePuncher {
+func newHolePuncher(h host.Host, ids identify.IDService, tracer *tracer, emitter event.Emitter, filter AddrFilter, retry retryConfig) *holePuncher {
 	hp := &holePuncher{
-		host:   h,
-		ids:    ids,
-		active: make(map[peer.ID]struct{}),
-		tracer: tracer,
-		filter: filter,
+		host:    h,
+		ids:     ids,
+		active:  make(map[peer.ID]struct{}),
+		tracer:  tracer,
+		emitter: emitter,
+		filter:  filter,
+		retry:   retry,
 	}
 	hp.ctx, hp.ctxCancel = context.WithCancel(context.Background())
 	h.Network().Notify((*netNotifiee)(hp))
@@ -104,6 +109,7 @@ func (hp *holePuncher) directConnect(rp peer.ID) error {
 	if getDirectConnection(hp.host, rp) != nil {
 		return nil
 	}
+	dcutrStart := time.Now()
 
 	// short-circuit hole punching if a direct dial works.
 	// attempt a direct connection ONLY if we have a public address for the remote peer
@@ -123,6 +129,7 @@ func (hp *holePuncher) directConnect(rp peer.ID) error {
 				break
 			}
 			hp.tracer.DirectDialSuccessful(rp, dt)
+			hp.tracer.DirectConnectionEstablished("initiator", time.Since(dcutrStart))
 			log.Debugw("direct connection to peer successful, no need for a hole punch", "peer", rp)
 			return nil
 		}
@@ -131,15 +138,31 @@ func (hp *holePuncher) directConnect(rp peer.ID) error {
 	log.Debugw("got inbound proxy conn", "peer", rp)
 
 	// hole punch
-	for i := 1; i <= maxRetries; i++ {
+	var minRTT time.Duration
+	for i := 1; i <= hp.retry.maxAttempts; i++ {
+		if i > 1 {
+			if d := hp.retry.delay(i); d > 0 {
+				t := time.NewTimer(d)
+				select {
+				case <-t.C:
+				case <-hp.ctx.Done():
+					t.Stop()
+					return hp.ctx.Err()
+				}
+			}
+		}
+
 		addrs, obsAddrs, rtt, err := hp.initiateHolePunch(rp)
 		if err != nil {
 			log.Debugw("hole punching failed", "peer", rp, "error", err)
 			hp.tracer.ProtocolError(rp, err)
 			return err
 		}
-		synTime := rtt / 2
-		log.Debugf("peer RTT is %s; starting hole punch in %s", rtt, synTime)
+		if minRTT == 0 || rtt < minRTT {
+			minRTT = rtt
+		}
+		synTime := hp.retry.syncDelay(minRTT)
+		log.Debugf("peer RTT is %s (min %s); starting hole punch in %s", rtt, minRTT, synTime)
 
 		// wait for sync to reach the other peer and then punch a hole for it in our NAT
 		// by attempting a connect to it.
@@ -150,22 +173,25 @@ func (hp *holePuncher) directConnect(rp peer.ID) error {
 				ID:    rp,
 				Addrs: addrs,
 			}
-			hp.tracer.StartHolePunch(rp, addrs, rtt)
+			hp.tracer.StartHolePunch("initiator", rp, addrs, rtt)
 			hp.tracer.HolePunchAttempt(pi.ID)
-			err := holePunchConnect(hp.ctx, hp.host, pi, true)
+			err := holePunchConnect(hp.ctx, hp.host, pi, true, hp.retry.attemptTimeout)
 			dt := time.Since(start)
 			hp.tracer.EndHolePunch(rp, dt, err)
 			if err == nil {
 				log.Debugw("hole punching with successful", "peer", rp, "time", dt)
 				hp.tracer.HolePunchFinished("initiator", i, addrs, obsAddrs, getDirectConnection(hp.host, rp))
+				hp.emitter.Emit(event.EvtHolePunchFinished{Peer: rp, Success: true, Attempts: i})
+				hp.tracer.DirectConnectionEstablished("initiator", time.Since(dcutrStart))
 				return nil
 			}
 		case <-hp.ctx.Done():
 			timer.Stop()
 			return hp.ctx.Err()
 		}
-		if i == maxRetries {
-			hp.tracer.HolePunchFinished("initiator", maxRetries, addrs, obsAddrs, nil)
+		if i == hp.retry.maxAttempts {
+			hp.tracer.HolePunchFinished("initiator", i, addrs, obsAddrs, nil)
+			hp.emitter.Emit(event.EvtHolePunchFinished{Peer: rp, Success: false, Attempts: i})
 		}
 	}
 	return fmt.Errorf("all retries for hole punch with peer %s failed", rp)
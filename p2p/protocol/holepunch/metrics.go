@@ -1,6 +1,8 @@
 package holepunch
 
 import (
+	"time"
+
 	"github.com/libp2p/go-libp2p/core/network"
 	"github.com/libp2p/go-libp2p/p2p/metricshelper"
 	ma "github.com/multiformats/go-multiaddr"
@@ -9,47 +11,109 @@ import (
 
 const metricNamespace = "libp2p_holepunch"
 
-var (
-	directDialsTotal = prometheus.NewCounterVec(
-		prometheus.CounterOpts{
-			Namespace: metricNamespace,
-			Name:      "direct_dials_total",
-			Help:      "Direct Dials Total",
-		},
-		[]string{"outcome"},
-	)
-	hpAddressOutcomesTotal = prometheus.NewCounterVec(
-		prometheus.CounterOpts{
-			Namespace: metricNamespace,
-			Name:      "address_outcomes_total",
-			Help:      "Hole Punch outcomes by Transport",
-		},
-		[]string{"side", "num_attempts", "ipv", "transport", "outcome"},
-	)
-	hpOutcomesTotal = prometheus.NewCounterVec(
-		prometheus.CounterOpts{
-			Namespace: metricNamespace,
-			Name:      "outcomes_total",
-			Help:      "Hole Punch outcomes overall",
-		},
-		[]string{"side", "num_attempts", "outcome"},
-	)
-
-	collectors = []prometheus.Collector{
-		directDialsTotal,
-		hpAddressOutcomesTotal,
-		hpOutcomesTotal,
+type metricsCollectors struct {
+	directDialsTotal       *prometheus.CounterVec
+	hpAddressOutcomesTotal *prometheus.CounterVec
+	hpOutcomesTotal        *prometheus.CounterVec
+	hpAttemptsTotal        *prometheus.CounterVec
+	hpRTT                  *prometheus.HistogramVec
+	timeToDirectConn       *prometheus.HistogramVec
+}
+
+func newMetricsCollectors() *metricsCollectors {
+	return &metricsCollectors{
+		directDialsTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: metricNamespace,
+				Name:      "direct_dials_total",
+				Help:      "Direct Dials Total",
+			},
+			[]string{"outcome"},
+		),
+		hpAddressOutcomesTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: metricNamespace,
+				Name:      "address_outcomes_total",
+				Help:      "Hole Punch outcomes by Transport",
+			},
+			[]string{"side", "num_attempts", "ipv", "transport", "outcome"},
+		),
+		hpOutcomesTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: metricNamespace,
+				Name:      "outcomes_total",
+				Help:      "Hole Punch outcomes overall",
+			},
+			[]string{"side", "num_attempts", "outcome"},
+		),
+		hpAttemptsTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: metricNamespace,
+				Name:      "attempts_total",
+				Help:      "Hole Punch attempts",
+			},
+			[]string{"side"},
+		),
+		hpRTT: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace: metricNamespace,
+				Name:      "rtt_seconds",
+				Help:      "RTT measured over the relayed connection before hole punching",
+				Buckets:   []float64{0.01, 0.025, 0.05, 0.1, 0.2, 0.3, 0.5, 0.75, 1, 2, 5},
+			},
+			[]string{"side"},
+		),
+		timeToDirectConn: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace: metricNamespace,
+				Name:      "time_to_direct_connection_seconds",
+				Help:      "Time from the start of DCUtR until a direct connection was established",
+				Buckets:   []float64{0.1, 0.25, 0.5, 1, 2, 3, 5, 7.5, 10, 15, 30},
+			},
+			[]string{"side"},
+		),
 	}
-)
+}
+
+func (c *metricsCollectors) Collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		c.directDialsTotal,
+		c.hpAddressOutcomesTotal,
+		c.hpOutcomesTotal,
+		c.hpAttemptsTotal,
+		c.hpRTT,
+		c.timeToDirectConn,
+	}
+}
+
+// defaultCollectors are shared by all metrics tracers, unless they register
+// with a metricshelper.LabeledRegisterer.
+var defaultCollectors = newMetricsCollectors()
 
 type MetricsTracer interface {
 	HolePunchFinished(side string, attemptNum int, theirAddrs []ma.Multiaddr, ourAddr []ma.Multiaddr, directConn network.ConnMultiaddrs)
 	DirectDialFinished(success bool)
 }
 
-type metricsTracer struct{}
+// TimingMetricsTracer is an optional interface of a MetricsTracer. If the
+// MetricsTracer implements it, it tracks the timing of hole punches.
+type TimingMetricsTracer interface {
+	// HolePunchStarted is called for every hole punch attempt, after the
+	// CONNECT / SYNC exchange measured the RTT to the remote peer.
+	HolePunchStarted(side string, rtt time.Duration)
+	// DirectConnectionEstablished is called when DCUtR results in a direct
+	// connection, with the time elapsed since the protocol was started.
+	DirectConnectionEstablished(side string, dt time.Duration)
+}
 
-var _ MetricsTracer = &metricsTracer{}
+type metricsTracer struct {
+	*metricsCollectors
+}
+
+var (
+	_ MetricsTracer       = &metricsTracer{}
+	_ TimingMetricsTracer = &metricsTracer{}
+)
 
 type metricsTracerSetting struct {
 	reg prometheus.Registerer
@@ -70,25 +134,25 @@ func NewMetricsTracer(opts ...MetricsTracerOption) MetricsTracer {
 	for _, opt := range opts {
 		opt(setting)
 	}
-	metricshelper.RegisterCollectors(setting.reg, collectors...)
+	c := metricshelper.Collectors(setting.reg, defaultCollectors, newMetricsCollectors)
 	// initialise metrics's labels so that the first data point is handled correctly
 	for _, side := range []string{"initiator", "receiver"} {
 		for _, numAttempts := range []string{"1", "2", "3", "4"} {
 			for _, outcome := range []string{"success", "failed", "cancelled", "no_suitable_address"} {
 				for _, ipv := range []string{"ip4", "ip6"} {
 					for _, transport := range []string{"quic", "quic-v1", "tcp", "webtransport"} {
-						hpAddressOutcomesTotal.WithLabelValues(side, numAttempts, ipv, transport, outcome)
+						c.hpAddressOutcomesTotal.WithLabelValues(side, numAttempts, ipv, transport, outcome)
 					}
 				}
 				if outcome == "cancelled" {
 					// not a valid outcome for the overall holepunch metric
 					continue
 				}
-				hpOutcomesTotal.WithLabelValues(side, numAttempts, outcome)
+				c.hpOutcomesTotal.WithLabelValues(side, numAttempts, outcome)
 			}
 		}
 	}
-	return &metricsTracer{}
+	return &metricsTracer{c}
 }
 
 // HolePunchFinished tracks metrics completion of a holepunch. Metrics are tracked on
@@ -143,14 +207,14 @@ func (mt *metricsTracer) HolePunchFinished(side string, numAttempts int,
 					// no connection was made
 					*tags = append(*tags, "failed")
 				}
-				hpAddressOutcomesTotal.WithLabelValues(*tags...).Inc()
+				mt.hpAddressOutcomesTotal.WithLabelValues(*tags...).Inc()
 				*tags = (*tags)[:2] // 2 because we want to keep (side, numAttempts)
 				break
 			}
 		}
 		if !matchingAddress {
 			*tags = append(*tags, lipv, ltransport, "no_suitable_address")
-			hpAddressOutcomesTotal.WithLabelValues(*tags...).Inc()
+			mt.hpAddressOutcomesTotal.WithLabelValues(*tags...).Inc()
 			*tags = (*tags)[:2] // 2 because we want to keep (side, numAttempts)
 		}
 	}
@@ -164,7 +228,7 @@ func (mt *metricsTracer) HolePunchFinished(side string, numAttempts int,
 	}
 
 	*tags = append(*tags, outcome)
-	hpOutcomesTotal.WithLabelValues(*tags...).Inc()
+	mt.hpOutcomesTotal.WithLabelValues(*tags...).Inc()
 }
 
 func getNumAttemptString(numAttempt int) string {
@@ -183,5 +247,20 @@ func (mt *metricsTracer) DirectDialFinished(success bool) {
 	} else {
 		*tags = append(*tags, "failed")
 	}
-	directDialsTotal.WithLabelValues(*tags...).Inc()
+	mt.directDialsTotal.WithLabelValues(*tags...).Inc()
+}
+
+func (mt *metricsTracer) HolePunchStarted(side string, rtt time.Duration) {
+	tags := metricshelper.GetStringSlice()
+	defer metricshelper.PutStringSlice(tags)
+	*tags = append(*tags, side)
+	mt.hpAttemptsTotal.WithLabelValues(*tags...).Inc()
+	mt.hpRTT.WithLabelValues(*tags...).Observe(rtt.Seconds())
+}
+
+func (mt *metricsTracer) DirectConnectionEstablished(side string, dt time.Duration) {
+	tags := metricshelper.GetStringSlice()
+	defer metricshelper.PutStringSlice(tags)
+	*tags = append(*tags, side)
+	mt.timeToDirectConn.WithLabelValues(*tags...).Observe(dt.Seconds())
 }
@@ -5,6 +5,7 @@ package holepunch
 import (
 	"math/rand"
 	"testing"
+	"time"
 
 	"github.com/libp2p/go-libp2p/core/network"
 	ma "github.com/multiformats/go-multiaddr"
@@ -30,13 +31,19 @@ func TestNoCoverNoAllocMetrics(t *testing.T) {
 		nil,
 	}
 	sides := []string{"initiator", "receiver"}
-	mt := NewMetricsTracer()
+	mt := NewMetricsTracer().(*metricsTracer)
 	testcases := map[string]func(){
 		"DirectDialFinished": func() { mt.DirectDialFinished(rand.Intn(2) == 1) },
 		"HolePunchFinished": func() {
 			mt.HolePunchFinished(sides[rand.Intn(len(sides))], rand.Intn(maxRetries), addrs1[rand.Intn(len(addrs1))],
 				addrs2[rand.Intn(len(addrs2))], conns[rand.Intn(len(conns))])
 		},
+		"HolePunchStarted": func() {
+			mt.HolePunchStarted(sides[rand.Intn(len(sides))], time.Duration(rand.Intn(1000))*time.Millisecond)
+		},
+		"DirectConnectionEstablished": func() {
+			mt.DirectConnectionEstablished(sides[rand.Intn(len(sides))], time.Duration(rand.Intn(1000))*time.Millisecond)
+		},
 	}
 	for method, f := range testcases {
 		t.Run(method, func(t *testing.T) {
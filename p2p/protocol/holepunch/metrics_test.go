@@ -2,6 +2,7 @@ package holepunch
 
 import (
 	"testing"
+	"time"
 
 	"github.com/libp2p/go-libp2p/core/network"
 	ma "github.com/multiformats/go-multiaddr"
@@ -71,12 +72,12 @@ func TestHolePunchOutcomeCounter(t *testing.T) {
 	for _, tc := range testcases {
 		t.Run(tc.name, func(t *testing.T) {
 			reg := prometheus.NewRegistry()
-			hpAddressOutcomesTotal.Reset()
+			defaultCollectors.hpAddressOutcomesTotal.Reset()
 			mt := NewMetricsTracer(WithRegisterer(reg))
 			for _, side := range []string{"receiver", "initiator"} {
 				mt.HolePunchFinished(side, 1, tc.theirAddrs, tc.ourAddrs, tc.conn)
 				for labels, value := range tc.result {
-					v := getCounterValue(t, hpAddressOutcomesTotal, side, "1", labels[0], labels[1], labels[2])
+					v := getCounterValue(t, defaultCollectors.hpAddressOutcomesTotal, side, "1", labels[0], labels[1], labels[2])
 					if v != value {
 						t.Errorf("Invalid metric value %s: expected: %d got: %d", labels, value, v)
 					}
@@ -86,6 +87,21 @@ func TestHolePunchOutcomeCounter(t *testing.T) {
 	}
 }
 
+func TestHolePunchAttemptsCounter(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	defaultCollectors.hpAttemptsTotal.Reset()
+	mt := NewMetricsTracer(WithRegisterer(reg)).(TimingMetricsTracer)
+	mt.HolePunchStarted("initiator", 50*time.Millisecond)
+	mt.HolePunchStarted("initiator", 70*time.Millisecond)
+	mt.HolePunchStarted("receiver", 20*time.Millisecond)
+	if v := getCounterValue(t, defaultCollectors.hpAttemptsTotal, "initiator"); v != 2 {
+		t.Errorf("invalid initiator attempts: expected: 2 got: %d", v)
+	}
+	if v := getCounterValue(t, defaultCollectors.hpAttemptsTotal, "receiver"); v != 1 {
+		t.Errorf("invalid receiver attempts: expected: 1 got: %d", v)
+	}
+}
+
 type mockConnMultiaddrs struct {
 	local, remote ma.Multiaddr
 }
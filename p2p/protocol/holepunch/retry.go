@@ -0,0 +1,93 @@
+package holepunch
+
+import (
+	"errors"
+	"time"
+)
+
+// retryConfig controls how often and how fast the initiator retries a hole punch.
+type retryConfig struct {
+	// maxAttempts is the number of hole punch attempts made before giving up.
+	maxAttempts int
+	// attemptTimeout bounds the dial performed in every hole punch attempt.
+	attemptTimeout time.Duration
+	// delay returns the time to wait before the given attempt (starting at 2).
+	delay func(attempt int) time.Duration
+	// syncOffset is subtracted from the half-RTT the initiator waits before dialing.
+	syncOffset time.Duration
+}
+
+func defaultRetryConfig() retryConfig {
+	return retryConfig{
+		maxAttempts:    maxRetries,
+		attemptTimeout: dialTimeout,
+		delay:          func(int) time.Duration { return 0 },
+	}
+}
+
+// WithMaxAttempts sets the number of hole punch attempts the initiator makes
+// before giving up. Defaults to 3.
+func WithMaxAttempts(n int) Option {
+	return func(hps *Service) error {
+		if n < 1 {
+			return errors.New("need at least one hole punch attempt")
+		}
+		hps.retry.maxAttempts = n
+		return nil
+	}
+}
+
+// WithAttemptTimeout sets the timeout of the dial performed in each hole punch
+// attempt, on both the initiator and the receiver side. Defaults to 5s.
+// High-latency links (e.g. mobile networks) may need a longer timeout.
+func WithAttemptTimeout(d time.Duration) Option {
+	return func(hps *Service) error {
+		if d <= 0 {
+			return errors.New("hole punch attempt timeout must be positive")
+		}
+		hps.retry.attemptTimeout = d
+		return nil
+	}
+}
+
+// WithRetryDelay sets a function that returns how long the initiator waits
+// before the given attempt. It's called with attempt numbers starting at 2,
+// i.e. it is never consulted before the first attempt. By default, retries are
+// made immediately.
+func WithRetryDelay(delay func(attempt int) time.Duration) Option {
+	return func(hps *Service) error {
+		if delay == nil {
+			return errors.New("retry delay function must not be nil")
+		}
+		hps.retry.delay = delay
+		return nil
+	}
+}
+
+// WithSyncOffset makes the initiator start its dial earlier by d than half of
+// the measured RTT. This compensates for the time the receiver spends
+// processing the SYNC message and setting up its dial, which otherwise makes
+// the initiator's SYN arrive late for a TCP simultaneous open.
+func WithSyncOffset(d time.Duration) Option {
+	return func(hps *Service) error {
+		if d < 0 {
+			return errors.New("sync offset must not be negative")
+		}
+		hps.retry.syncOffset = d
+		return nil
+	}
+}
+
+// syncDelay returns the time the initiator waits after sending SYNC before it
+// starts dialing. The receiver dials as soon as it gets the SYNC, which is
+// half an RTT after it was sent, so we aim at that point in time.
+// RTT samples taken over a relayed connection are noisy, since they include
+// queuing delays at the relay. As queuing only ever increases the RTT, the
+// smallest sample seen so far is the best estimate of the actual path latency.
+func (c *retryConfig) syncDelay(minRTT time.Duration) time.Duration {
+	d := minRTT/2 - c.syncOffset
+	if d < 0 {
+		return 0
+	}
+	return d
+}
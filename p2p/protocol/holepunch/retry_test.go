@@ -0,0 +1,37 @@
+package holepunch
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSyncDelay(t *testing.T) {
+	c := defaultRetryConfig()
+	require.Equal(t, 50*time.Millisecond, c.syncDelay(100*time.Millisecond))
+
+	c.syncOffset = 20 * time.Millisecond
+	require.Equal(t, 30*time.Millisecond, c.syncDelay(100*time.Millisecond))
+
+	// never wait a negative amount of time
+	c.syncOffset = time.Second
+	require.Zero(t, c.syncDelay(100*time.Millisecond))
+}
+
+func TestRetryOptions(t *testing.T) {
+	s := &Service{retry: defaultRetryConfig()}
+	require.Error(t, WithMaxAttempts(0)(s))
+	require.Error(t, WithAttemptTimeout(0)(s))
+	require.Error(t, WithRetryDelay(nil)(s))
+	require.Error(t, WithSyncOffset(-time.Second)(s))
+
+	require.NoError(t, WithMaxAttempts(5)(s))
+	require.NoError(t, WithAttemptTimeout(10*time.Second)(s))
+	require.NoError(t, WithRetryDelay(func(attempt int) time.Duration { return time.Duration(attempt) * time.Second })(s))
+	require.NoError(t, WithSyncOffset(10*time.Millisecond)(s))
+	require.Equal(t, 5, s.retry.maxAttempts)
+	require.Equal(t, 10*time.Second, s.retry.attemptTimeout)
+	require.Equal(t, 3*time.Second, s.retry.delay(3))
+	require.Equal(t, 10*time.Millisecond, s.retry.syncOffset)
+}
@@ -53,8 +53,10 @@ type Service struct {
 
 	hasPublicAddrsChan chan struct{}
 
-	tracer *tracer
-	filter AddrFilter
+	tracer  *tracer
+	emitter event.Emitter
+	filter  AddrFilter
+	retry   retryConfig
 
 	refCount sync.WaitGroup
 }
@@ -76,6 +78,7 @@ func NewService(h host.Host, ids identify.IDService, opts ...Option) (*Service,
 		host:               h,
 		ids:                ids,
 		hasPublicAddrsChan: make(chan struct{}),
+		retry:              defaultRetryConfig(),
 	}
 
 	for _, opt := range opts {
@@ -84,6 +87,12 @@ func NewService(h host.Host, ids identify.IDService, opts ...Option) (*Service,
 			return nil, err
 		}
 	}
+	emitter, err := h.EventBus().Emitter(new(event.EvtHolePunchFinished))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	s.emitter = emitter
 	s.tracer.Start()
 
 	s.refCount.Add(1)
@@ -143,7 +152,7 @@ func (s *Service) watchForPublicAddr() {
 				continue
 			}
 			s.holePuncherMx.Lock()
-			s.holePuncher = newHolePuncher(s.host, s.ids, s.tracer, s.filter)
+			s.holePuncher = newHolePuncher(s.host, s.ids, s.tracer, s.emitter, s.filter, s.retry)
 			s.holePuncherMx.Unlock()
 			close(s.hasPublicAddrsChan)
 			return
@@ -163,6 +172,7 @@ func (s *Service) Close() error {
 	s.host.RemoveStreamHandler(Protocol)
 	s.ctxCancel()
 	s.refCount.Wait()
+	s.emitter.Close()
 	return err
 }
 
@@ -250,6 +260,7 @@ func (s *Service) handleNewStream(str network.Stream) {
 	}
 
 	rp := str.Conn().RemotePeer()
+	dcutrStart := time.Now()
 	rtt, addrs, ownAddrs, err := s.incomingHolePunch(str)
 	if err != nil {
 		s.tracer.ProtocolError(rp, err)
@@ -264,14 +275,18 @@ func (s *Service) handleNewStream(str network.Stream) {
 		ID:    rp,
 		Addrs: addrs,
 	}
-	s.tracer.StartHolePunch(rp, addrs, rtt)
+	s.tracer.StartHolePunch("receiver", rp, addrs, rtt)
 	log.Debugw("starting hole punch", "peer", rp)
 	start := time.Now()
 	s.tracer.HolePunchAttempt(pi.ID)
-	err = holePunchConnect(s.ctx, s.host, pi, false)
+	err = holePunchConnect(s.ctx, s.host, pi, false, s.retry.attemptTimeout)
 	dt := time.Since(start)
 	s.tracer.EndHolePunch(rp, dt, err)
 	s.tracer.HolePunchFinished("receiver", 1, addrs, ownAddrs, getDirectConnection(s.host, rp))
+	s.emitter.Emit(event.EvtHolePunchFinished{Peer: rp, Success: err == nil, Attempts: 1})
+	if err == nil {
+		s.tracer.DirectConnectionEstablished("receiver", time.Since(dcutrStart))
+	}
 }
 
 // DirectConnect is only exposed for testing purposes.
@@ -189,7 +189,12 @@ func (t *tracer) ProtocolError(p peer.ID, err error) {
 	}
 }
 
-func (t *tracer) StartHolePunch(p peer.ID, obsAddrs []ma.Multiaddr, rtt time.Duration) {
+func (t *tracer) StartHolePunch(side string, p peer.ID, obsAddrs []ma.Multiaddr, rtt time.Duration) {
+	if t != nil {
+		if mt, ok := t.mt.(TimingMetricsTracer); ok {
+			mt.HolePunchStarted(side, rtt)
+		}
+	}
 	if t != nil && t.et != nil {
 		addrs := make([]string, 0, len(obsAddrs))
 		for _, a := range obsAddrs {
@@ -235,6 +240,14 @@ func (t *tracer) HolePunchFinished(side string, numAttempts int, theirAddrs []ma
 	}
 }
 
+func (t *tracer) DirectConnectionEstablished(side string, dt time.Duration) {
+	if t != nil {
+		if mt, ok := t.mt.(TimingMetricsTracer); ok {
+			mt.DirectConnectionEstablished(side, dt)
+		}
+	}
+}
+
 func (t *tracer) HolePunchAttempt(p peer.ID) {
 	if t != nil && t.et != nil {
 		now := time.Now()
@@ -2,6 +2,7 @@ package holepunch
 
 import (
 	"context"
+	"time"
 
 	"github.com/libp2p/go-libp2p/core/host"
 	"github.com/libp2p/go-libp2p/core/network"
@@ -64,10 +65,10 @@ func getDirectConnection(h host.Host, p peer.ID) network.Conn {
 	return nil
 }
 
-func holePunchConnect(ctx context.Context, host host.Host, pi peer.AddrInfo, isClient bool) error {
+func holePunchConnect(ctx context.Context, host host.Host, pi peer.AddrInfo, isClient bool, timeout time.Duration) error {
 	holePunchCtx := network.WithSimultaneousConnect(ctx, isClient, "hole-punching")
 	forceDirectConnCtx := network.WithForceDirectDial(holePunchCtx, "hole-punching")
-	dialCtx, cancel := context.WithTimeout(forceDirectConnCtx, dialTimeout)
+	dialCtx, cancel := context.WithTimeout(forceDirectConnCtx, timeout)
 	defer cancel()
 
 	if err := host.Connect(dialCtx, pi); err != nil {
@@ -0,0 +1,53 @@
+package identify
+
+import (
+	"github.com/libp2p/go-libp2p/core/event"
+)
+
+// Consumer receives every identify message the identify service accepts,
+// both identify responses and identify pushes, after it has been applied to
+// the peerstore. This allows external systems (e.g. a shared address book)
+// to mirror what we know about our peers.
+//
+// Consumers are called synchronously from the identify service and must not
+// block.
+type Consumer interface {
+	// ConsumeIdentify is called with the parsed identify message.
+	// isPush is set if the message was an identify push.
+	ConsumeIdentify(msg event.EvtPeerIdentificationCompleted, isPush bool)
+}
+
+// ConsumerFunc is an adapter to use a function as a Consumer.
+type ConsumerFunc func(msg event.EvtPeerIdentificationCompleted, isPush bool)
+
+func (f ConsumerFunc) ConsumeIdentify(msg event.EvtPeerIdentificationCompleted, isPush bool) {
+	f(msg, isPush)
+}
+
+// SetConsumer registers c under the given name, replacing the consumer
+// previously registered under that name.
+func (ids *idService) SetConsumer(name string, c Consumer) {
+	ids.consumersMu.Lock()
+	defer ids.consumersMu.Unlock()
+	ids.consumers[name] = c
+}
+
+// RemoveConsumer removes the consumer registered under the given name.
+func (ids *idService) RemoveConsumer(name string) {
+	ids.consumersMu.Lock()
+	defer ids.consumersMu.Unlock()
+	delete(ids.consumers, name)
+}
+
+func (ids *idService) notifyConsumers(msg event.EvtPeerIdentificationCompleted, isPush bool) {
+	ids.consumersMu.RLock()
+	consumers := make([]Consumer, 0, len(ids.consumers))
+	for _, c := range ids.consumers {
+		consumers = append(consumers, c)
+	}
+	ids.consumersMu.RUnlock()
+
+	for _, c := range consumers {
+		c.ConsumeIdentify(msg, isPush)
+	}
+}
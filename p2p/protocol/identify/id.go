@@ -2,15 +2,18 @@ package identify
 
 import (
 	"bytes"
+	"cmp"
 	"context"
 	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"sync"
 	"time"
 
 	"golang.org/x/exp/slices"
 
+	"github.com/libp2p/go-libp2p/core/connmgr"
 	"github.com/libp2p/go-libp2p/core/crypto"
 	"github.com/libp2p/go-libp2p/core/event"
 	"github.com/libp2p/go-libp2p/core/host"
@@ -21,8 +24,9 @@ import (
 	"github.com/libp2p/go-libp2p/core/record"
 	"github.com/libp2p/go-libp2p/p2p/host/eventbus"
 	"github.com/libp2p/go-libp2p/p2p/protocol/identify/pb"
+	"github.com/libp2p/go-libp2p/p2p/slogshim"
 
-	logging "github.com/ipfs/go-log/v2"
+	"github.com/benbjohnson/clock"
 	"github.com/libp2p/go-msgio/pbio"
 	ma "github.com/multiformats/go-multiaddr"
 	manet "github.com/multiformats/go-multiaddr/net"
@@ -32,7 +36,7 @@ import (
 
 //go:generate protoc --proto_path=$PWD:$PWD/../../.. --go_out=. --go_opt=Mpb/identify.proto=./pb pb/identify.proto
 
-var log = logging.Logger("net/identify")
+var log = slogshim.Logger("net/identify")
 
 var Timeout = 30 * time.Second // timeout on all incoming Identify interactions
 
@@ -42,6 +46,13 @@ const (
 	// IDPush is the protocol.ID of the Identify push protocol.
 	// It sends full identify messages containing the current state of the peer.
 	IDPush = "/ipfs/id/push/1.0.0"
+	// IDv2 is the protocol.ID of the experimental version 2 of the identify
+	// service. It sends the listen addresses with per-address metadata, see
+	// pb.Address. Peers negotiate it first, and fall back to ID.
+	// It is scoped to go-libp2p until the wire format is specified.
+	IDv2 = "/libp2p/go-libp2p/exp/id/2.0.0"
+	// IDPushv2 is the protocol.ID of the experimental version 2 of the Identify push protocol.
+	IDPushv2 = "/libp2p/go-libp2p/exp/id/push/2.0.0"
 
 	ServiceName = "libp2p.identify"
 
@@ -59,6 +70,8 @@ const (
 
 var defaultUserAgent = "github.com/libp2p/go-libp2p"
 
+var errIdentifyDenied = errors.New("connection denied by identify gater")
+
 type identifySnapshot struct {
 	seq       uint64
 	protocols []protocol.ID
@@ -106,6 +119,19 @@ type IDService interface {
 	// ObservedAddrsFor returns the addresses peers have reported we've dialed from,
 	// for a specific local address.
 	ObservedAddrsFor(local ma.Multiaddr) []ma.Multiaddr
+	// SetConsumer registers a Consumer that is called with every identify
+	// message received, replacing the consumer previously registered under
+	// that name.
+	SetConsumer(name string, c Consumer)
+	// RemoveConsumer removes the Consumer registered under the given name.
+	RemoveConsumer(name string)
+	// Sequence returns the sequence number of our current identify
+	// information. It increases every time the information changes, and is
+	// sent to peers with it.
+	Sequence() uint64
+	// PeerSequence returns the highest sequence number we received from a
+	// connected peer. It returns false if the peer didn't send any.
+	PeerSequence(p peer.ID) (uint64, bool)
 	Start()
 	io.Closer
 }
@@ -143,7 +169,10 @@ type idService struct {
 	UserAgent       string
 	ProtocolVersion string
 
-	metricsTracer MetricsTracer
+	metricsTracer  MetricsTracer
+	gater          connmgr.IdentifyGater
+	addrTranslator network.AddrTranslator
+	log            *slog.Logger
 
 	setupCompleted chan struct{} // is closed when Start has finished setting up
 	ctx            context.Context
@@ -152,6 +181,7 @@ type idService struct {
 	refCount sync.WaitGroup
 
 	disableSignedPeerRecord bool
+	disableV2               bool
 
 	connsMu sync.RWMutex
 	// The conns map contains all connections we're currently handling.
@@ -161,6 +191,9 @@ type idService struct {
 
 	addrMu sync.Mutex
 
+	consumersMu sync.RWMutex
+	consumers   map[string]Consumer
+
 	// our own observed addresses.
 	observedAddrMgr            *ObservedAddrManager
 	disableObservedAddrManager bool
@@ -169,6 +202,8 @@ type idService struct {
 		evtPeerProtocolsUpdated        event.Emitter
 		evtPeerIdentificationCompleted event.Emitter
 		evtPeerIdentificationFailed    event.Emitter
+		evtConnectionEstablished       event.Emitter
+		evtPeerIdentificationDenied    event.Emitter
 	}
 
 	currentSnapshot struct {
@@ -177,6 +212,16 @@ type idService struct {
 	}
 
 	natEmitter *natEmitter
+
+	pushConcurrency int
+	pushRoundBudget time.Duration
+	clock           clock.Clock
+
+	// the highest sequence number received from every connected peer
+	peerSeqs struct {
+		sync.Mutex
+		m map[peer.ID]uint64
+	}
 }
 
 type normalizer interface {
@@ -186,7 +231,7 @@ type normalizer interface {
 // NewIDService constructs a new *idService and activates it by
 // attaching its stream handler to the given host.Host.
 func NewIDService(h host.Host, opts ...Option) (*idService, error) {
-	var cfg config
+	cfg := config{clock: clock.New()}
 	for _, opt := range opts {
 		opt(&cfg)
 	}
@@ -204,9 +249,24 @@ func NewIDService(h host.Host, opts ...Option) (*idService, error) {
 		ctx:                     ctx,
 		ctxCancel:               cancel,
 		conns:                   make(map[network.Conn]entry),
+		consumers:               make(map[string]Consumer),
 		disableSignedPeerRecord: cfg.disableSignedPeerRecord,
+		disableV2:               cfg.disableV2,
 		setupCompleted:          make(chan struct{}),
 		metricsTracer:           cfg.metricsTracer,
+		gater:                   cfg.gater,
+		addrTranslator:          cfg.addrTranslator,
+		pushConcurrency:         maxPushConcurrency,
+		pushRoundBudget:         cfg.pushRoundBudget,
+		clock:                   cfg.clock,
+		log:                     log,
+	}
+	s.peerSeqs.m = make(map[peer.ID]uint64)
+	if cfg.pushConcurrency > 0 {
+		s.pushConcurrency = cfg.pushConcurrency
+	}
+	if cfg.logger != nil {
+		s.log = slogshim.ForSubsystem(cfg.logger, "net/identify")
 	}
 
 	var normalize func(ma.Multiaddr) ma.Multiaddr
@@ -218,12 +278,12 @@ func NewIDService(h host.Host, opts ...Option) (*idService, error) {
 	if cfg.disableObservedAddrManager {
 		s.disableObservedAddrManager = true
 	} else {
-		observedAddrs, err := NewObservedAddrManager(h.Network().ListenAddresses,
-			h.Addrs, h.Network().InterfaceListenAddresses, normalize)
+		observedAddrs, err := newObservedAddrManager(h.Network().ListenAddresses,
+			h.Addrs, h.Network().InterfaceListenAddresses, normalize, s.log)
 		if err != nil {
 			return nil, fmt.Errorf("failed to create observed address manager: %s", err)
 		}
-		natEmitter, err := newNATEmitter(h, observedAddrs, time.Minute)
+		natEmitter, err := newNATEmitter(h, observedAddrs, time.Minute, cfg.clock, s.log)
 		if err != nil {
 			return nil, fmt.Errorf("failed to create nat emitter: %s", err)
 		}
@@ -233,15 +293,23 @@ func NewIDService(h host.Host, opts ...Option) (*idService, error) {
 
 	s.emitters.evtPeerProtocolsUpdated, err = h.EventBus().Emitter(&event.EvtPeerProtocolsUpdated{})
 	if err != nil {
-		log.Warnf("identify service not emitting peer protocol updates; err: %s", err)
+		s.log.Warn("identify service not emitting peer protocol updates", "error", err)
 	}
 	s.emitters.evtPeerIdentificationCompleted, err = h.EventBus().Emitter(&event.EvtPeerIdentificationCompleted{})
 	if err != nil {
-		log.Warnf("identify service not emitting identification completed events; err: %s", err)
+		s.log.Warn("identify service not emitting identification completed events", "error", err)
 	}
 	s.emitters.evtPeerIdentificationFailed, err = h.EventBus().Emitter(&event.EvtPeerIdentificationFailed{})
 	if err != nil {
-		log.Warnf("identify service not emitting identification failed events; err: %s", err)
+		s.log.Warn("identify service not emitting identification failed events", "error", err)
+	}
+	s.emitters.evtPeerIdentificationDenied, err = h.EventBus().Emitter(&event.EvtPeerIdentificationDenied{})
+	if err != nil {
+		s.log.Warn("identify service not emitting identification denied events", "error", err)
+	}
+	s.emitters.evtConnectionEstablished, err = h.EventBus().Emitter(&event.EvtConnectionEstablished{})
+	if err != nil {
+		s.log.Warn("identify service not emitting connection established events", "error", err)
 	}
 	return s, nil
 }
@@ -250,6 +318,10 @@ func (ids *idService) Start() {
 	ids.Host.Network().Notify((*netNotifiee)(ids))
 	ids.Host.SetStreamHandler(ID, ids.handleIdentifyRequest)
 	ids.Host.SetStreamHandler(IDPush, ids.handlePush)
+	if !ids.disableV2 {
+		ids.Host.SetStreamHandler(IDv2, ids.handleIdentifyRequest)
+		ids.Host.SetStreamHandler(IDPushv2, ids.handlePush)
+	}
 	ids.updateSnapshot()
 	close(ids.setupCompleted)
 
@@ -266,7 +338,7 @@ func (ids *idService) loop(ctx context.Context) {
 		eventbus.Name("identify (loop)"),
 	)
 	if err != nil {
-		log.Errorf("failed to subscribe to events on the bus, err=%s", err)
+		ids.log.Error("failed to subscribe to events on the bus", "error", err)
 		return
 	}
 	defer sub.Close()
@@ -285,7 +357,13 @@ func (ids *idService) loop(ctx context.Context) {
 			case <-ctx.Done():
 				return
 			case <-triggerPush:
-				ids.sendPushes(ctx)
+				if !ids.sendPushes(ctx) {
+					// The round ran out of time. Continue in a new round.
+					select {
+					case triggerPush <- struct{}{}:
+					default:
+					}
+				}
 			}
 		}
 	}()
@@ -312,7 +390,10 @@ func (ids *idService) loop(ctx context.Context) {
 	}
 }
 
-func (ids *idService) sendPushes(ctx context.Context) {
+// sendPushes sends the current snapshot to all peers that haven't received it
+// yet, most important peers first. It returns false if it ran out of its time
+// budget before starting all pushes.
+func (ids *idService) sendPushes(ctx context.Context) bool {
 	ids.connsMu.RLock()
 	conns := make([]network.Conn, 0, len(ids.conns))
 	for c, e := range ids.conns {
@@ -323,9 +404,18 @@ func (ids *idService) sendPushes(ctx context.Context) {
 		}
 	}
 	ids.connsMu.RUnlock()
+	ids.sortByPushPriority(conns)
+
+	var roundDeadline, deadline <-chan time.Time
+	if ids.pushRoundBudget > 0 {
+		t := ids.clock.Timer(ids.pushRoundBudget)
+		defer t.Stop()
+		roundDeadline = t.C
+	}
 
-	sem := make(chan struct{}, maxPushConcurrency)
+	sem := make(chan struct{}, ids.pushConcurrency)
 	var wg sync.WaitGroup
+	defer wg.Wait()
 	for _, c := range conns {
 		// check if the connection is still alive
 		ids.connsMu.RLock()
@@ -339,29 +429,78 @@ func (ids *idService) sendPushes(ctx context.Context) {
 		snapshot := ids.currentSnapshot.snapshot
 		ids.currentSnapshot.Unlock()
 		if e.Sequence >= snapshot.seq {
-			log.Debugw("already sent this snapshot to peer", "peer", c.RemotePeer(), "seq", snapshot.seq)
+			ids.log.Debug("already sent this snapshot to peer", "peer", c.RemotePeer(), "conn", c.ID(), "seq", snapshot.seq)
 			continue
 		}
 		// we haven't, send it now
-		sem <- struct{}{}
+		select {
+		case sem <- struct{}{}:
+		case <-deadline:
+			ids.log.Debug("identify push round ran out of time")
+			return false
+		case <-ctx.Done():
+			return true
+		}
+		// Only enforce the budget once we've started a push, so that every
+		// round makes progress.
+		deadline = roundDeadline
 		wg.Add(1)
 		go func(c network.Conn) {
 			defer wg.Done()
 			defer func() { <-sem }()
-			ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+			ctx, cancel := ids.clock.WithTimeout(ctx, 5*time.Second)
 			defer cancel()
-			str, err := ids.Host.NewStream(ctx, c.RemotePeer(), IDPush)
+			str, err := ids.Host.NewStream(ctx, c.RemotePeer(), ids.pushProtocols()...)
 			if err != nil { // connection might have been closed recently
 				return
 			}
 			// TODO: find out if the peer supports push if we didn't have any information about push support
 			if err := ids.sendIdentifyResp(str, true); err != nil {
-				log.Debugw("failed to send identify push", "peer", c.RemotePeer(), "error", err)
+				ids.log.Debug("failed to send identify push", "peer", c.RemotePeer(), "conn", c.ID(), "error", err)
 				return
 			}
 		}(c)
 	}
-	wg.Wait()
+	return true
+}
+
+// sortByPushPriority sorts conns by the importance of their peers: protected
+// peers first, then peers with higher tag values, then connections with more
+// open streams.
+func (ids *idService) sortByPushPriority(conns []network.Conn) {
+	type priority struct {
+		protected  bool
+		value      int
+		numStreams int
+	}
+	cm := ids.Host.ConnManager()
+	prios := make(map[network.Conn]priority, len(conns))
+	byPeer := make(map[peer.ID]priority)
+	for _, c := range conns {
+		p, ok := byPeer[c.RemotePeer()]
+		if !ok && cm != nil {
+			p.protected = cm.IsProtected(c.RemotePeer(), "")
+			if info := cm.GetTagInfo(c.RemotePeer()); info != nil {
+				p.value = info.Value
+			}
+			byPeer[c.RemotePeer()] = p
+		}
+		p.numStreams = c.Stat().NumStreams
+		prios[c] = p
+	}
+	slices.SortStableFunc(conns, func(a, b network.Conn) int {
+		pa, pb := prios[a], prios[b]
+		if pa.protected != pb.protected {
+			if pa.protected {
+				return -1
+			}
+			return 1
+		}
+		if pa.value != pb.value {
+			return cmp.Compare(pb.value, pa.value)
+		}
+		return cmp.Compare(pb.numStreams, pa.numStreams)
+	})
 }
 
 // Close shuts down the idService
@@ -409,7 +548,7 @@ func (ids *idService) IdentifyWait(c network.Conn) <-chan struct{} {
 		// No entry found. We may have gotten an out of order notification. Check it we should have this conn (because we're still connected)
 		// We hold the ids.connsMu lock so this is safe since a disconnect event will be processed later if we are connected.
 		if c.IsClosed() {
-			log.Debugw("connection not found in identify service", "peer", c.RemotePeer())
+			ids.log.Debug("connection not found in identify service", "peer", c.RemotePeer(), "conn", c.ID())
 			ch := make(chan struct{})
 			close(ch)
 			return ch
@@ -431,33 +570,72 @@ func (ids *idService) IdentifyWait(c network.Conn) <-chan struct{} {
 	go func() {
 		defer close(e.IdentifyWaitChan)
 		if err := ids.identifyConn(c); err != nil {
-			log.Warnf("failed to identify %s: %s", c.RemotePeer(), err)
+			if errors.Is(err, errIdentifyDenied) {
+				// the denial was already reported
+				return
+			}
+			ids.log.Warn("failed to identify peer", "peer", c.RemotePeer(), "conn", c.ID(), "transport", c.ConnState().Transport, "error", err)
 			ids.emitters.evtPeerIdentificationFailed.Emit(event.EvtPeerIdentificationFailed{Peer: c.RemotePeer(), Reason: err})
 			return
 		}
+		ids.connEstablished(c)
 	}()
 
 	return e.IdentifyWaitChan
 }
 
+// connEstablished reports the timing breakdown of establishing c, now that
+// the first identify exchange on it completed.
+func (ids *idService) connEstablished(c network.Conn) {
+	stat := c.Stat()
+	timings := stat.Timings
+	if !stat.Opened.IsZero() {
+		timings.Identify = ids.clock.Since(stat.Opened)
+	}
+	state := c.ConnState()
+	if mt, ok := ids.metricsTracer.(ConnectionMetricsTracer); ok {
+		mt.ConnectionEstablished(stat.Direction, state, timings)
+	}
+	if ids.emitters.evtConnectionEstablished != nil {
+		ids.emitters.evtConnectionEstablished.Emit(event.EvtConnectionEstablished{
+			Peer:      c.RemotePeer(),
+			Conn:      c,
+			Transport: state.Transport,
+			Direction: stat.Direction,
+			Timings:   timings,
+		})
+	}
+}
+
 func (ids *idService) identifyConn(c network.Conn) error {
 	ctx, cancel := context.WithTimeout(context.Background(), Timeout)
 	defer cancel()
 	s, err := c.NewStream(network.WithAllowLimitedConn(ctx, "identify"))
 	if err != nil {
-		log.Debugw("error opening identify stream", "peer", c.RemotePeer(), "error", err)
+		ids.log.Debug("error opening identify stream", "peer", c.RemotePeer(), "conn", c.ID(), "error", err)
 		return err
 	}
 	s.SetDeadline(time.Now().Add(Timeout))
 
-	if err := s.SetProtocol(ID); err != nil {
-		log.Warnf("error setting identify protocol for stream: %s", err)
-		s.Reset()
+	// Only offer identify v2 to peers we know support it: offering it to
+	// other peers costs an additional round trip.
+	protos := []protocol.ID{ID}
+	if !ids.disableV2 {
+		if supported, _ := ids.Host.Peerstore().SupportsProtocols(c.RemotePeer(), IDv2); len(supported) > 0 {
+			protos = []protocol.ID{IDv2, ID}
+		}
 	}
-
 	// ok give the response to our handler.
-	if err := msmux.SelectProtoOrFail(ID, s); err != nil {
-		log.Infow("failed negotiate identify protocol with peer", "peer", c.RemotePeer(), "error", err)
+	selected, err := msmux.SelectOneOf(protos, s)
+	if err != nil {
+		ids.log.Info("failed negotiate identify protocol with peer", "peer", c.RemotePeer(), "conn", c.ID(), "error", err)
+		s.Reset()
+		return err
+	}
+	// The stream scope can only be attached to a protocol once, so we can
+	// only do so after the version was negotiated.
+	if err := s.SetProtocol(selected); err != nil {
+		ids.log.Warn("error setting identify protocol for stream", "protocol", selected, "error", err)
 		s.Reset()
 		return err
 	}
@@ -486,18 +664,28 @@ func (ids *idService) sendIdentifyResp(s network.Stream, isPush bool) error {
 	snapshot := ids.currentSnapshot.snapshot
 	ids.currentSnapshot.Unlock()
 
-	log.Debugw("sending snapshot", "seq", snapshot.seq, "protocols", snapshot.protocols, "addrs", snapshot.addrs)
+	ids.log.Debug("sending snapshot", "seq", snapshot.seq, "protocols", snapshot.protocols, "addrs", snapshot.addrs)
 
 	mes := ids.createBaseIdentifyResponse(s.Conn(), &snapshot)
 	mes.SignedPeerRecord = ids.getSignedRecord(&snapshot)
+	if snapshot.seq > 0 {
+		mes.Sequence = proto.Uint64(snapshot.seq)
+	}
+	if isV2(s.Protocol()) {
+		var reachability func(ma.Multiaddr) network.Reachability
+		if h, ok := ids.Host.(addrReachabilityHost); ok {
+			reachability = h.AddrReachability
+		}
+		setStructuredAddrs(mes, reachability)
+	}
 
-	log.Debugf("%s sending message to %s %s", ID, s.Conn().RemotePeer(), s.Conn().RemoteMultiaddr())
+	ids.log.Debug("sending identify message", "protocol", s.Protocol(), "peer", s.Conn().RemotePeer(), "conn", s.Conn().ID(), "addr", s.Conn().RemoteMultiaddr())
 	if err := ids.writeChunkedIdentifyMsg(s, mes); err != nil {
 		return err
 	}
 
 	if ids.metricsTracer != nil {
-		ids.metricsTracer.IdentifySent(isPush, len(mes.Protocols), len(mes.ListenAddrs))
+		ids.metricsTracer.IdentifySent(isPush, len(mes.Protocols), len(mes.ListenAddrs)+len(mes.Addrs))
 	}
 
 	ids.connsMu.Lock()
@@ -517,13 +705,13 @@ func (ids *idService) sendIdentifyResp(s network.Stream, isPush bool) error {
 
 func (ids *idService) handleIdentifyResponse(s network.Stream, isPush bool) error {
 	if err := s.Scope().SetService(ServiceName); err != nil {
-		log.Warnf("error attaching stream to identify service: %s", err)
+		ids.log.Warn("error attaching stream to identify service", "error", err)
 		s.Reset()
 		return err
 	}
 
 	if err := s.Scope().ReserveMemory(signedIDSize, network.ReservationPriorityAlways); err != nil {
-		log.Warnf("error reserving memory for identify stream: %s", err)
+		ids.log.Warn("error reserving memory for identify stream", "error", err)
 		s.Reset()
 		return err
 	}
@@ -535,19 +723,31 @@ func (ids *idService) handleIdentifyResponse(s network.Stream, isPush bool) erro
 	mes := &pb.Identify{}
 
 	if err := readAllIDMessages(r, mes); err != nil {
-		log.Warn("error reading identify message: ", err)
+		ids.log.Warn("error reading identify message", "protocol", s.Protocol(), "peer", s.Conn().RemotePeer(), "error", err)
 		s.Reset()
 		return err
 	}
 
 	defer s.Close()
 
-	log.Debugf("%s received message from %s %s", s.Protocol(), c.RemotePeer(), c.RemoteMultiaddr())
+	ids.log.Debug("received identify message", "protocol", s.Protocol(), "peer", c.RemotePeer(), "conn", c.ID(), "addr", c.RemoteMultiaddr())
 
-	ids.consumeMessage(mes, c, isPush)
+	if !ids.updatePeerSequence(c.RemotePeer(), mes.GetSequence()) {
+		// We already consumed a newer message, e.g. a push that overtook the
+		// response to our identify request. The peerstore is up to date, but
+		// we still need to record whether the peer supports identify push.
+		ids.log.Debug("ignoring stale identify message", "peer", c.RemotePeer(), "conn", c.ID(), "seq", mes.GetSequence(), "push", isPush)
+		if isPush {
+			return nil
+		}
+	} else {
+		if err := ids.consumeMessage(mes, c, isPush); err != nil {
+			return err
+		}
 
-	if ids.metricsTracer != nil {
-		ids.metricsTracer.IdentifyReceived(isPush, len(mes.Protocols), len(mes.ListenAddrs))
+		if ids.metricsTracer != nil {
+			ids.metricsTracer.IdentifyReceived(isPush, len(mes.Protocols), len(mes.ListenAddrs)+len(mes.Addrs))
+		}
 	}
 
 	ids.connsMu.Lock()
@@ -556,7 +756,7 @@ func (ids *idService) handleIdentifyResponse(s network.Stream, isPush bool) erro
 	if !ok { // might already have disconnected
 		return nil
 	}
-	sup, err := ids.Host.Peerstore().SupportsProtocols(c.RemotePeer(), IDPush)
+	sup, err := ids.Host.Peerstore().SupportsProtocols(c.RemotePeer(), ids.pushProtocols()...)
 	if supportsIdentifyPush := err == nil && len(sup) > 0; supportsIdentifyPush {
 		e.PushSupport = identifyPushSupported
 	} else {
@@ -571,6 +771,41 @@ func (ids *idService) handleIdentifyResponse(s network.Stream, isPush bool) erro
 	return nil
 }
 
+// updatePeerSequence records the sequence number of an identify message
+// received from peer p. It returns false if the message, be it a push or a
+// response to an identify request, is older than a message we already
+// received, in which case it must be ignored.
+// Messages without a sequence number, sent by peers that don't support it, are
+// always accepted.
+func (ids *idService) updatePeerSequence(p peer.ID, seq uint64) bool {
+	if seq == 0 {
+		return true
+	}
+	ids.peerSeqs.Lock()
+	defer ids.peerSeqs.Unlock()
+	last := ids.peerSeqs.m[p]
+	if seq < last {
+		return false
+	}
+	if seq > last {
+		ids.peerSeqs.m[p] = seq
+	}
+	return true
+}
+
+func (ids *idService) Sequence() uint64 {
+	ids.currentSnapshot.Lock()
+	defer ids.currentSnapshot.Unlock()
+	return ids.currentSnapshot.snapshot.seq
+}
+
+func (ids *idService) PeerSequence(p peer.ID) (uint64, bool) {
+	ids.peerSeqs.Lock()
+	defer ids.peerSeqs.Unlock()
+	seq, ok := ids.peerSeqs.m[p]
+	return seq, ok
+}
+
 func readAllIDMessages(r pbio.Reader, finalMsg proto.Message) error {
 	mes := &pb.Identify{}
 	for i := 0; i < maxMessages; i++ {
@@ -621,7 +856,7 @@ func (ids *idService) updateSnapshot() (updated bool) {
 	snapshot.seq = ids.currentSnapshot.snapshot.seq + 1
 	ids.currentSnapshot.snapshot = snapshot
 
-	log.Debugw("updating snapshot", "seq", snapshot.seq, "addrs", snapshot.addrs)
+	ids.log.Debug("updating snapshot", "seq", snapshot.seq, "addrs", snapshot.addrs)
 	return true
 }
 
@@ -652,7 +887,11 @@ func (ids *idService) createBaseIdentifyResponse(conn network.Conn, snapshot *id
 
 	// observed address so other side is informed of their
 	// "public" address, at least in relation to us.
-	mes.ObservedAddr = remoteAddr.Bytes()
+	if ids.addrTranslator != nil {
+		mes.ObservedAddr = ids.addrTranslator.ReverseTranslate(remoteAddr).Bytes()
+	} else {
+		mes.ObservedAddr = remoteAddr.Bytes()
+	}
 
 	// populate unsigned addresses.
 	// peers that do not yet support signed addresses will need this.
@@ -674,13 +913,13 @@ func (ids *idService) createBaseIdentifyResponse(conn network.Conn, snapshot *id
 		// check if we're even operating in "secure mode"
 		if ids.Host.Peerstore().PrivKey(ids.Host.ID()) != nil {
 			// private key is present. But NO public key. Something bad happened.
-			log.Errorf("did not have own public key in Peerstore")
+			ids.log.Error("did not have own public key in Peerstore")
 		}
 		// if neither of the key is present it is safe to assume that we are using an insecure transport.
 	} else {
 		// public key is present. Safe to proceed.
 		if kb, err := crypto.MarshalPublicKey(ownKey); err != nil {
-			log.Errorf("failed to convert key to bytes")
+			ids.log.Error("failed to convert key to bytes", "error", err)
 		} else {
 			mes.PublicKey = kb
 		}
@@ -700,7 +939,7 @@ func (ids *idService) getSignedRecord(snapshot *identifySnapshot) []byte {
 
 	recBytes, err := snapshot.record.Marshal()
 	if err != nil {
-		log.Errorw("failed to marshal signed record", "err", err)
+		ids.log.Error("failed to marshal signed record", "error", err)
 		return nil
 	}
 
@@ -737,11 +976,25 @@ func diff(a, b []protocol.ID) (added, removed []protocol.ID) {
 	return
 }
 
-func (ids *idService) consumeMessage(mes *pb.Identify, c network.Conn, isPush bool) {
+func (ids *idService) consumeMessage(mes *pb.Identify, c network.Conn, isPush bool) error {
 	p := c.RemotePeer()
 
-	supported, _ := ids.Host.Peerstore().GetProtocols(p)
 	mesProtocols := protocol.ConvertFromStrings(mes.Protocols)
+	if ids.gater != nil && !ids.gater.InterceptIdentified(c, mes.GetAgentVersion(), mesProtocols) {
+		ids.log.Debug("gater rejected identified connection", "peer", p, "conn", c.ID(), "agent", mes.GetAgentVersion())
+		c.Close()
+		if ids.emitters.evtPeerIdentificationDenied != nil {
+			ids.emitters.evtPeerIdentificationDenied.Emit(event.EvtPeerIdentificationDenied{
+				Peer:         p,
+				Conn:         c,
+				AgentVersion: mes.GetAgentVersion(),
+				Protocols:    mesProtocols,
+			})
+		}
+		return errIdentifyDenied
+	}
+
+	supported, _ := ids.Host.Peerstore().GetProtocols(p)
 	added, removed := diff(supported, mesProtocols)
 	ids.Host.Peerstore().SetProtocols(p, mesProtocols...)
 	if isPush {
@@ -754,10 +1007,13 @@ func (ids *idService) consumeMessage(mes *pb.Identify, c network.Conn, isPush bo
 
 	obsAddr, err := ma.NewMultiaddrBytes(mes.GetObservedAddr())
 	if err != nil {
-		log.Debugf("error parsing received observed addr for %s: %s", c, err)
+		ids.log.Debug("error parsing received observed addr", "peer", p, "conn", c.ID(), "error", err)
 		obsAddr = nil
 	}
 
+	if obsAddr != nil && ids.addrTranslator != nil {
+		obsAddr = ids.addrTranslator.ReverseTranslate(obsAddr)
+	}
 	if obsAddr != nil && !ids.disableObservedAddrManager {
 		// TODO refactor this to use the emitted events instead of having this func call explicitly.
 		ids.observedAddrMgr.Record(c, obsAddr)
@@ -769,11 +1025,26 @@ func (ids *idService) consumeMessage(mes *pb.Identify, c network.Conn, isPush bo
 	for _, addr := range laddrs {
 		maddr, err := ma.NewMultiaddrBytes(addr)
 		if err != nil {
-			log.Debugf("%s failed to parse multiaddr from %s %s", ID,
-				p, c.RemoteMultiaddr())
+			ids.log.Debug("failed to parse listen addr", "peer", p, "addr", c.RemoteMultiaddr(), "error", err)
+			continue
+		}
+		lmaddrs = append(lmaddrs, maddr)
+	}
+	// identify v2 sends structured addresses instead
+	var addrsReachability map[string]network.Reachability
+	for _, a := range mes.GetAddrs() {
+		maddr, reachability, err := addrFromPB(a)
+		if err != nil {
+			ids.log.Debug("failed to parse structured addr", "peer", p, "addr", c.RemoteMultiaddr(), "error", err)
 			continue
 		}
 		lmaddrs = append(lmaddrs, maddr)
+		if reachability != network.ReachabilityUnknown {
+			if addrsReachability == nil {
+				addrsReachability = make(map[string]network.Reachability)
+			}
+			addrsReachability[maddr.String()] = reachability
+		}
 	}
 
 	// NOTE: Do not add `c.RemoteMultiaddr()` to the peerstore if the remote
@@ -788,7 +1059,7 @@ func (ids *idService) consumeMessage(mes *pb.Identify, c network.Conn, isPush bo
 	// otherwise use the unsigned addresses.
 	signedPeerRecord, err := signedPeerRecordFromMessage(mes)
 	if err != nil {
-		log.Errorf("error getting peer record from Identify message: %v", err)
+		ids.log.Error("error getting peer record from identify message", "peer", p, "error", err)
 	}
 
 	// Extend the TTLs on the known (probably) good addresses.
@@ -812,7 +1083,7 @@ func (ids *idService) consumeMessage(mes *pb.Identify, c network.Conn, isPush bo
 	if signedPeerRecord != nil {
 		signedAddrs, err := ids.consumeSignedPeerRecord(c.RemotePeer(), signedPeerRecord)
 		if err != nil {
-			log.Debugf("failed to consume signed peer record: %s", err)
+			ids.log.Debug("failed to consume signed peer record", "peer", p, "error", err)
 			signedPeerRecord = nil
 		} else {
 			addrs = signedAddrs
@@ -829,34 +1100,77 @@ func (ids *idService) consumeMessage(mes *pb.Identify, c network.Conn, isPush bo
 
 	// Finally, expire all temporary addrs.
 	ids.Host.Peerstore().UpdateAddrs(p, peerstore.TempAddrTTL, 0)
-	ids.addrMu.Unlock()
-
-	log.Debugf("%s received listen addrs for %s: %s", c.LocalPeer(), c.RemotePeer(), addrs)
 
 	// get protocol versions
 	pv := mes.GetProtocolVersion()
 	av := mes.GetAgentVersion()
 
-	ids.Host.Peerstore().Put(p, "ProtocolVersion", pv)
-	ids.Host.Peerstore().Put(p, "AgentVersion", av)
+	// Like the addresses, the versions are kept while we're connected, and
+	// expire once we've been disconnected for RecentlyConnectedAddrTTL.
+	if ttl == peerstore.ConnectedAddrTTL {
+		ids.Host.Peerstore().Put(p, "ProtocolVersion", pv)
+		ids.Host.Peerstore().Put(p, "AgentVersion", av)
+	} else {
+		peerstore.PutWithTTL(ids.Host.Peerstore(), p, "ProtocolVersion", pv, ttl)
+		peerstore.PutWithTTL(ids.Host.Peerstore(), p, "AgentVersion", av, ttl)
+	}
+	ids.addrMu.Unlock()
+
+	ids.log.Debug("received listen addrs", "peer", p, "addrs", addrs)
 
 	// get the key from the other side. we may not have it (no-auth transport)
 	ids.consumeReceivedPubKey(c, mes.PublicKey)
 
-	ids.emitters.evtPeerIdentificationCompleted.Emit(event.EvtPeerIdentificationCompleted{
-		Peer:             c.RemotePeer(),
-		Conn:             c,
-		ListenAddrs:      lmaddrs,
-		Protocols:        mesProtocols,
-		SignedPeerRecord: signedPeerRecord,
-		ObservedAddr:     obsAddr,
-		ProtocolVersion:  pv,
-		AgentVersion:     av,
-	})
-
+	evt := event.EvtPeerIdentificationCompleted{
+		Peer:                    c.RemotePeer(),
+		Conn:                    c,
+		ListenAddrs:             lmaddrs,
+		ListenAddrsReachability: addrsReachability,
+		Protocols:               mesProtocols,
+		SignedPeerRecord:        signedPeerRecord,
+		ObservedAddr:            obsAddr,
+		ProtocolVersion:         pv,
+		AgentVersion:            av,
+	}
+	ids.notifyConsumers(evt, isPush)
+	ids.emitters.evtPeerIdentificationCompleted.Emit(evt)
+	return nil
 }
 
 func (ids *idService) consumeSignedPeerRecord(p peer.ID, signedPeerRecord *record.Envelope) ([]ma.Multiaddr, error) {
+	rec, err := verifySignedPeerRecord(signedPeerRecord)
+	if err != nil {
+		return nil, err
+	}
+	if rec.PeerID != p {
+		return nil, fmt.Errorf("received signed peer record for unexpected peer ID. expected %s, got %s", p, rec.PeerID)
+	}
+	// Don't put the signed peer record into the peer store.
+	// They're not used anywhere.
+	// All we care about are the addresses.
+	return rec.Addrs, nil
+}
+
+// ConsumeSignedPeerRecord unmarshals and verifies a signed peer record, as
+// exchanged by identify. This is useful to verify records obtained out of
+// band, e.g. from a configuration file.
+// It checks the envelope's signature, and that the record was signed by the
+// peer it describes.
+func ConsumeSignedPeerRecord(data []byte) (*record.Envelope, *peer.PeerRecord, error) {
+	env, _, err := record.ConsumeEnvelope(data, peer.PeerRecordEnvelopeDomain)
+	if err != nil {
+		return nil, nil, err
+	}
+	rec, err := verifySignedPeerRecord(env)
+	if err != nil {
+		return nil, nil, err
+	}
+	return env, rec, nil
+}
+
+// verifySignedPeerRecord checks that signedPeerRecord contains a peer record,
+// signed by the peer the record is for.
+func verifySignedPeerRecord(signedPeerRecord *record.Envelope) (*peer.PeerRecord, error) {
 	if signedPeerRecord.PublicKey == nil {
 		return nil, errors.New("missing pubkey")
 	}
@@ -864,9 +1178,6 @@ func (ids *idService) consumeSignedPeerRecord(p peer.ID, signedPeerRecord *recor
 	if err != nil {
 		return nil, fmt.Errorf("failed to derive peer ID: %s", err)
 	}
-	if id != p {
-		return nil, fmt.Errorf("received signed peer record envelope for unexpected peer ID. expected %s, got %s", p, id)
-	}
 	r, err := signedPeerRecord.Record()
 	if err != nil {
 		return nil, fmt.Errorf("failed to obtain record: %w", err)
@@ -875,34 +1186,30 @@ func (ids *idService) consumeSignedPeerRecord(p peer.ID, signedPeerRecord *recor
 	if !ok {
 		return nil, errors.New("not a peer record")
 	}
-	if rec.PeerID != p {
-		return nil, fmt.Errorf("received signed peer record for unexpected peer ID. expected %s, got %s", p, rec.PeerID)
+	if rec.PeerID != id {
+		return nil, fmt.Errorf("signed peer record for %s was signed by %s", rec.PeerID, id)
 	}
-	// Don't put the signed peer record into the peer store.
-	// They're not used anywhere.
-	// All we care about are the addresses.
-	return rec.Addrs, nil
+	return rec, nil
 }
 
 func (ids *idService) consumeReceivedPubKey(c network.Conn, kb []byte) {
-	lp := c.LocalPeer()
 	rp := c.RemotePeer()
 
 	if kb == nil {
-		log.Debugf("%s did not receive public key for remote peer: %s", lp, rp)
+		ids.log.Debug("did not receive public key for remote peer", "peer", rp)
 		return
 	}
 
 	newKey, err := crypto.UnmarshalPublicKey(kb)
 	if err != nil {
-		log.Warnf("%s cannot unmarshal key from remote peer: %s, %s", lp, rp, err)
+		ids.log.Warn("cannot unmarshal key from remote peer", "peer", rp, "error", err)
 		return
 	}
 
 	// verify key matches peer.ID
 	np, err := peer.IDFromPublicKey(newKey)
 	if err != nil {
-		log.Debugf("%s cannot get peer.ID from key of remote peer: %s, %s", lp, rp, err)
+		ids.log.Debug("cannot get peer.ID from key of remote peer", "peer", rp, "error", err)
 		return
 	}
 
@@ -913,12 +1220,12 @@ func (ids *idService) consumeReceivedPubKey(c network.Conn, kb []byte) {
 			// if local peerid is empty, then use the new, sent key.
 			err := ids.Host.Peerstore().AddPubKey(rp, newKey)
 			if err != nil {
-				log.Debugf("%s could not add key for %s to peerstore: %s", lp, rp, err)
+				ids.log.Debug("could not add key to peerstore", "peer", rp, "error", err)
 			}
 
 		} else {
 			// we have a local peer.ID and it does not match the sent key... error.
-			log.Errorf("%s received key for remote peer %s mismatch: %s", lp, rp, np)
+			ids.log.Error("received key for remote peer mismatch", "peer", rp, "key_peer", np)
 		}
 		return
 	}
@@ -928,7 +1235,7 @@ func (ids *idService) consumeReceivedPubKey(c network.Conn, kb []byte) {
 		// no key? no auth transport. set this one.
 		err := ids.Host.Peerstore().AddPubKey(rp, newKey)
 		if err != nil {
-			log.Debugf("%s could not add key for %s to peerstore: %s", lp, rp, err)
+			ids.log.Debug("could not add key to peerstore", "peer", rp, "error", err)
 		}
 		return
 	}
@@ -941,21 +1248,21 @@ func (ids *idService) consumeReceivedPubKey(c network.Conn, kb []byte) {
 	// weird, got a different key... but the different key MATCHES the peer.ID.
 	// this odd. let's log error and investigate. this should basically never happen
 	// and it means we have something funky going on and possibly a bug.
-	log.Errorf("%s identify got a different key for: %s", lp, rp)
+	ids.log.Error("identify got a different key", "peer", rp)
 
 	// okay... does ours NOT match the remote peer.ID?
 	cp, err := peer.IDFromPublicKey(currKey)
 	if err != nil {
-		log.Errorf("%s cannot get peer.ID from local key of remote peer: %s, %s", lp, rp, err)
+		ids.log.Error("cannot get peer.ID from local key of remote peer", "peer", rp, "error", err)
 		return
 	}
 	if cp != rp {
-		log.Errorf("%s local key for remote peer %s yields different peer.ID: %s", lp, rp, cp)
+		ids.log.Error("local key for remote peer yields different peer.ID", "peer", rp, "key_peer", cp)
 		return
 	}
 
 	// okay... curr key DOES NOT match new key. both match peer.ID. wat?
-	log.Errorf("%s local key and received key for %s do not match, but match peer.ID", lp, rp)
+	ids.log.Error("local key and received key do not match, but match peer.ID", "peer", rp)
 }
 
 // HasConsistentTransport returns true if the address 'a' shares a
@@ -1044,6 +1351,10 @@ func (nn *netNotifiee) Disconnected(_ network.Network, c network.Conn) {
 	case network.Connected, network.Limited:
 		return
 	}
+	// A peer that reconnects might have restarted, and its sequence numbers with it.
+	ids.peerSeqs.Lock()
+	delete(ids.peerSeqs.m, c.RemotePeer())
+	ids.peerSeqs.Unlock()
 	// peerstore returns the elements in a random order as it uses a map to store the addresses
 	addrs := ids.Host.Peerstore().Addrs(c.RemotePeer())
 	n := len(addrs)
@@ -1059,6 +1370,13 @@ func (nn *netNotifiee) Disconnected(_ network.Network, c network.Conn) {
 	ids.Host.Peerstore().UpdateAddrs(c.RemotePeer(), peerstore.ConnectedAddrTTL, peerstore.TempAddrTTL)
 	ids.Host.Peerstore().AddAddrs(c.RemotePeer(), addrs[:n], peerstore.RecentlyConnectedAddrTTL)
 	ids.Host.Peerstore().UpdateAddrs(c.RemotePeer(), peerstore.TempAddrTTL, 0)
+
+	// Expire the versions along with the addresses.
+	for _, key := range []string{"ProtocolVersion", "AgentVersion"} {
+		if v, err := ids.Host.Peerstore().Get(c.RemotePeer(), key); err == nil {
+			peerstore.PutWithTTL(ids.Host.Peerstore(), c.RemotePeer(), key, v, peerstore.RecentlyConnectedAddrTTL)
+		}
+	}
 }
 
 func (nn *netNotifiee) Listen(n network.Network, a ma.Multiaddr)      {}
@@ -11,7 +11,9 @@ import (
 	"github.com/libp2p/go-libp2p/core/peerstore"
 	recordPb "github.com/libp2p/go-libp2p/core/record/pb"
 	blhost "github.com/libp2p/go-libp2p/p2p/host/blank"
+	"github.com/libp2p/go-libp2p/p2p/net/connmgr"
 	swarmt "github.com/libp2p/go-libp2p/p2p/net/swarm/testing"
+	"github.com/libp2p/go-libp2p/p2p/protocol/identify/pb"
 	ma "github.com/multiformats/go-multiaddr"
 	"google.golang.org/protobuf/proto"
 
@@ -206,3 +208,128 @@ func TestIncomingAddrFilter(t *testing.T) {
 		})
 	}
 }
+
+func TestStructuredAddrs(t *testing.T) {
+	wt := ma.StringCast("/ip4/1.2.3.4/udp/1234/quic-v1/webtransport/certhash/uEiDDq4_xNyDorZBH3TlGazyJdOWSwvo4PUo5YHFMrvDE8g/certhash/uEiAmnGbQhUxCfu2YvI4v0HaYVf3A4cIbV73sePx4txqTdA")
+	tcp := ma.StringCast("/ip4/192.168.0.1/tcp/1234")
+
+	mes := &pb.Identify{ListenAddrs: [][]byte{wt.Bytes(), tcp.Bytes()}}
+	setStructuredAddrs(mes, nil)
+	require.Empty(t, mes.ListenAddrs)
+	require.Len(t, mes.Addrs, 2)
+
+	// certhashes are split off
+	require.Equal(t, ma.StringCast("/ip4/1.2.3.4/udp/1234/quic-v1/webtransport").Bytes(), mes.Addrs[0].Multiaddr)
+	require.Len(t, mes.Addrs[0].Certhashes, 2)
+	require.Empty(t, mes.Addrs[1].Certhashes)
+	// without a host that verifies them, the reachability is unknown
+	require.Equal(t, pb.Address_UNKNOWN, mes.Addrs[0].GetReachability())
+	require.Equal(t, pb.Address_UNKNOWN, mes.Addrs[1].GetReachability())
+
+	addr, reachability, err := addrFromPB(mes.Addrs[0])
+	require.NoError(t, err)
+	require.True(t, wt.Equal(addr), "expected %s, got %s", wt, addr)
+	require.Equal(t, network.ReachabilityUnknown, reachability)
+	addr, _, err = addrFromPB(mes.Addrs[1])
+	require.NoError(t, err)
+	require.True(t, tcp.Equal(addr))
+
+	// the reachability of each address is sent along with it
+	mes = &pb.Identify{ListenAddrs: [][]byte{wt.Bytes(), tcp.Bytes()}}
+	setStructuredAddrs(mes, func(a ma.Multiaddr) network.Reachability {
+		if a.Equal(wt) {
+			return network.ReachabilityPublic
+		}
+		return network.ReachabilityPrivate
+	})
+	_, reachability, err = addrFromPB(mes.Addrs[0])
+	require.NoError(t, err)
+	require.Equal(t, network.ReachabilityPublic, reachability)
+	_, reachability, err = addrFromPB(mes.Addrs[1])
+	require.NoError(t, err)
+	require.Equal(t, network.ReachabilityPrivate, reachability)
+
+	_, _, err = addrFromPB(&pb.Address{})
+	require.Error(t, err)
+	_, _, err = addrFromPB(&pb.Address{Multiaddr: tcp.Bytes(), Certhashes: [][]byte{[]byte("foobar")}})
+	require.Error(t, err)
+}
+
+func TestStructuredAddrsRoundTrip(t *testing.T) {
+	const certhash = "/certhash/uEiDDq4_xNyDorZBH3TlGazyJdOWSwvo4PUo5YHFMrvDE8g"
+	const relayID = "/p2p/QmZR5a9AAXGqQF2ADqoDdGS8zvqv8n3Pag6TDDnTNMcFW6"
+	for _, s := range []string{
+		"/ip4/1.2.3.4/udp/1234/webrtc-direct" + certhash,
+		"/ip4/1.2.3.4/udp/1234/webrtc-direct" + certhash + relayID + "/p2p-circuit/webrtc",
+		"/ip4/1.2.3.4/udp/1234/quic-v1/webtransport" + certhash + relayID + "/p2p-circuit",
+		"/ip4/1.2.3.4/udp/1234/quic-v1/webtransport" + certhash + relayID + "/p2p-circuit/webtransport" + certhash,
+		"/ip4/1.2.3.4/tcp/1234" + relayID + "/p2p-circuit",
+	} {
+		t.Run(s, func(t *testing.T) {
+			in := ma.StringCast(s)
+			out, _, err := addrFromPB(addrToPB(in))
+			require.NoError(t, err)
+			require.True(t, in.Equal(out), "expected %s, got %s", in, out)
+		})
+	}
+}
+
+func TestPushPriority(t *testing.T) {
+	cm, err := connmgr.NewConnManager(10, 20)
+	require.NoError(t, err)
+	defer cm.Close()
+	h := blhost.NewBlankHost(swarmt.GenSwarm(t), blhost.WithConnectionManager(cm))
+	defer h.Close()
+	ids, err := NewIDService(h)
+	require.NoError(t, err)
+	defer ids.Close()
+
+	peers := make([]*blhost.BlankHost, 4)
+	for i := range peers {
+		peers[i] = blhost.NewBlankHost(swarmt.GenSwarm(t))
+		defer peers[i].Close()
+		require.NoError(t, h.Connect(context.Background(), peer.AddrInfo{ID: peers[i].ID(), Addrs: peers[i].Addrs()}))
+	}
+	plain, streams, tagged, protected := peers[0], peers[1], peers[2], peers[3]
+	cm.Protect(protected.ID(), "test")
+	cm.TagPeer(tagged.ID(), "test", 10)
+	streams.SetStreamHandler("/test", func(s network.Stream) {})
+	_, err = h.NewStream(context.Background(), streams.ID(), "/test")
+	require.NoError(t, err)
+
+	var conns []network.Conn
+	for _, p := range peers {
+		conns = append(conns, h.Network().ConnsToPeer(p.ID())...)
+	}
+	require.Len(t, conns, len(peers))
+	ids.sortByPushPriority(conns)
+	order := make([]peer.ID, 0, len(conns))
+	for _, c := range conns {
+		order = append(order, c.RemotePeer())
+	}
+	require.Equal(t, []peer.ID{protected.ID(), tagged.ID(), streams.ID(), plain.ID()}, order)
+}
+
+func TestUpdatePeerSequence(t *testing.T) {
+	h := blhost.NewBlankHost(swarmt.GenSwarm(t))
+	defer h.Close()
+	ids, err := NewIDService(h)
+	require.NoError(t, err)
+	defer ids.Close()
+
+	p := peer.ID("peer")
+	require.True(t, ids.updatePeerSequence(p, 2))
+	seq, ok := ids.PeerSequence(p)
+	require.True(t, ok)
+	require.Equal(t, uint64(2), seq)
+
+	// a delayed push or identify response is ignored
+	require.False(t, ids.updatePeerSequence(p, 1))
+	// the same snapshot can be received on multiple connections
+	require.True(t, ids.updatePeerSequence(p, 2))
+	// messages of peers that don't send sequence numbers are never ignored
+	require.True(t, ids.updatePeerSequence(p, 0))
+
+	seq, _ = ids.PeerSequence(p)
+	require.Equal(t, uint64(2), seq)
+}
@@ -7,10 +7,12 @@ import (
 	"math/rand"
 	"slices"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p/core/control"
 	ic "github.com/libp2p/go-libp2p/core/crypto"
 	"github.com/libp2p/go-libp2p/core/event"
 	"github.com/libp2p/go-libp2p/core/host"
@@ -214,6 +216,11 @@ func TestIDService(t *testing.T) {
 			clk.Add(time.Second)
 			testKnowsAddrs(t, h1, h2p, []ma.Multiaddr{})
 			testKnowsAddrs(t, h2, h1p, []ma.Multiaddr{})
+			// and so are the versions
+			_, err = h1.Peerstore().Get(h2p, "AgentVersion")
+			require.ErrorIs(t, err, peerstore.ErrNotFound)
+			_, err = h2.Peerstore().Get(h1p, "ProtocolVersion")
+			require.ErrorIs(t, err, peerstore.ErrNotFound)
 
 			// test that we received the "identify completed" event.
 			select {
@@ -550,6 +557,252 @@ func TestUserAgent(t *testing.T) {
 	}
 }
 
+func TestConnectionEstablishedEvent(t *testing.T) {
+	h1, err := libp2p.New(libp2p.ListenAddrStrings("/ip4/127.0.0.1/tcp/0"))
+	require.NoError(t, err)
+	defer h1.Close()
+	h2, err := libp2p.New(libp2p.ListenAddrStrings("/ip4/127.0.0.1/tcp/0"))
+	require.NoError(t, err)
+	defer h2.Close()
+
+	sub1, err := h1.EventBus().Subscribe(new(event.EvtConnectionEstablished))
+	require.NoError(t, err)
+	defer sub1.Close()
+	sub2, err := h2.EventBus().Subscribe(new(event.EvtConnectionEstablished))
+	require.NoError(t, err)
+	defer sub2.Close()
+
+	require.NoError(t, h1.Connect(context.Background(), peer.AddrInfo{ID: h2.ID(), Addrs: h2.Addrs()}))
+
+	next := func(sub event.Subscription) event.EvtConnectionEstablished {
+		t.Helper()
+		select {
+		case e := <-sub.Out():
+			return e.(event.EvtConnectionEstablished)
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout waiting for connection established event")
+		}
+		return event.EvtConnectionEstablished{}
+	}
+
+	e := next(sub1)
+	require.Equal(t, h2.ID(), e.Peer)
+	require.Equal(t, "tcp", e.Transport)
+	require.Equal(t, network.DirOutbound, e.Direction)
+	require.Positive(t, e.Timings.Handshake)
+	require.Positive(t, e.Timings.Security)
+	require.Positive(t, e.Timings.Identify)
+	// the muxer is negotiated during the security handshake
+	require.Zero(t, e.Timings.Muxer)
+
+	e = next(sub2)
+	require.Equal(t, h1.ID(), e.Peer)
+	require.Equal(t, network.DirInbound, e.Direction)
+	require.Zero(t, e.Timings.Handshake)
+	require.Positive(t, e.Timings.Security)
+	require.Positive(t, e.Timings.Identify)
+}
+
+type agentGater struct {
+	denied string
+}
+
+func (g *agentGater) InterceptPeerDial(peer.ID) bool               { return true }
+func (g *agentGater) InterceptAddrDial(peer.ID, ma.Multiaddr) bool { return true }
+func (g *agentGater) InterceptAccept(network.ConnMultiaddrs) bool  { return true }
+func (g *agentGater) InterceptUpgraded(network.Conn) (bool, control.DisconnectReason) {
+	return true, 0
+}
+func (g *agentGater) InterceptSecured(network.Direction, peer.ID, network.ConnMultiaddrs) bool {
+	return true
+}
+func (g *agentGater) InterceptIdentified(_ network.Conn, agentVersion string, _ []protocol.ID) bool {
+	return agentVersion != g.denied
+}
+
+func TestIdentifyGater(t *testing.T) {
+	h1, err := libp2p.New(
+		libp2p.ListenAddrStrings("/ip4/127.0.0.1/tcp/0"),
+		libp2p.ConnectionGater(&agentGater{denied: "evil"}),
+	)
+	require.NoError(t, err)
+	defer h1.Close()
+	good, err := libp2p.New(libp2p.UserAgent("good"), libp2p.ListenAddrStrings("/ip4/127.0.0.1/tcp/0"))
+	require.NoError(t, err)
+	defer good.Close()
+	evil, err := libp2p.New(libp2p.UserAgent("evil"), libp2p.ListenAddrStrings("/ip4/127.0.0.1/tcp/0"))
+	require.NoError(t, err)
+	defer evil.Close()
+
+	sub, err := h1.EventBus().Subscribe(new(event.EvtPeerIdentificationDenied))
+	require.NoError(t, err)
+	defer sub.Close()
+
+	require.NoError(t, h1.Connect(context.Background(), peer.AddrInfo{ID: good.ID(), Addrs: good.Addrs()}))
+	require.Never(t, func() bool { return h1.Network().Connectedness(good.ID()) != network.Connected }, 500*time.Millisecond, 50*time.Millisecond)
+
+	// the connection is established, and only closed once identify completed
+	h1.Connect(context.Background(), peer.AddrInfo{ID: evil.ID(), Addrs: evil.Addrs()})
+	select {
+	case e := <-sub.Out():
+		evt := e.(event.EvtPeerIdentificationDenied)
+		require.Equal(t, evil.ID(), evt.Peer)
+		require.Equal(t, "evil", evt.AgentVersion)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for identification denied event")
+	}
+	require.Eventually(t, func() bool { return h1.Network().Connectedness(evil.ID()) != network.Connected }, 5*time.Second, 10*time.Millisecond)
+	_, err = h1.Peerstore().Get(evil.ID(), "AgentVersion")
+	require.ErrorIs(t, err, peerstore.ErrNotFound)
+}
+
+func TestIdentifyV2(t *testing.T) {
+	newHost := func(opts ...identify.Option) (host.Host, identify.IDService) {
+		h := blhost.NewBlankHost(swarmt.GenSwarm(t))
+		t.Cleanup(func() { h.Close() })
+		ids, err := identify.NewIDService(h, opts...)
+		require.NoError(t, err)
+		ids.Start()
+		t.Cleanup(func() { ids.Close() })
+		return h, ids
+	}
+	h1, _ := newHost()
+	h2, ids2 := newHost()
+	h3, ids3 := newHost(identify.DisableV2())
+
+	for _, tc := range []struct {
+		name     string
+		h        host.Host
+		ids      identify.IDService
+		v2       bool
+		remoteV2 bool
+	}{
+		{name: "v2 to v2", h: h2, ids: ids2, v2: true, remoteV2: true},
+		{name: "v1 to v2", h: h3, ids: ids3, v2: false, remoteV2: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			sub, err := tc.h.EventBus().Subscribe(new(event.EvtPeerIdentificationCompleted))
+			require.NoError(t, err)
+			defer sub.Close()
+
+			require.NoError(t, tc.h.Connect(context.Background(), peer.AddrInfo{ID: h1.ID(), Addrs: h1.Addrs()}))
+			tc.ids.IdentifyConn(tc.h.Network().ConnsToPeer(h1.ID())[0])
+
+			select {
+			case e := <-sub.Out():
+				evt := e.(event.EvtPeerIdentificationCompleted)
+				require.Equal(t, h1.ID(), evt.Peer)
+				require.ElementsMatch(t, h1.Addrs(), evt.ListenAddrs)
+			case <-time.After(5 * time.Second):
+				t.Fatal("timeout waiting for identification completed event")
+			}
+			testKnowsAddrs(t, tc.h, h1.ID(), h1.Addrs())
+
+			protos, err := tc.h.Peerstore().SupportsProtocols(h1.ID(), identify.IDv2)
+			require.NoError(t, err)
+			require.Equal(t, tc.remoteV2, len(protos) > 0)
+			// the other side learns our addresses as well, over whichever version we speak
+			require.Eventually(t, func() bool {
+				return len(h1.Peerstore().Addrs(tc.h.ID())) == len(tc.h.Addrs())
+			}, 5*time.Second, 10*time.Millisecond)
+			protos, err = h1.Peerstore().SupportsProtocols(tc.h.ID(), identify.IDv2)
+			require.NoError(t, err)
+			require.Equal(t, tc.v2, len(protos) > 0)
+		})
+	}
+}
+
+func TestIdentifyV2OnlyOfferedWhenSupported(t *testing.T) {
+	h1 := blhost.NewBlankHost(swarmt.GenSwarm(t))
+	defer h1.Close()
+	h2 := blhost.NewBlankHost(swarmt.GenSwarm(t))
+	defer h2.Close()
+	ids1, err := identify.NewIDService(h1)
+	require.NoError(t, err)
+	defer ids1.Close()
+	ids1.Start()
+	ids2, err := identify.NewIDService(h2)
+	require.NoError(t, err)
+	defer ids2.Close()
+	ids2.Start()
+
+	// h2 records identify v2 requests, but doesn't answer them
+	var v2Requests atomic.Int32
+	h2.SetStreamHandler(identify.IDv2, func(s network.Stream) {
+		v2Requests.Add(1)
+		s.Reset()
+	})
+
+	identifyNewConn := func() {
+		t.Helper()
+		h1.Network().ClosePeer(h2.ID())
+		require.NoError(t, h1.Connect(context.Background(), peer.AddrInfo{ID: h2.ID(), Addrs: h2.Addrs()}))
+		<-ids1.IdentifyWait(h1.Network().ConnsToPeer(h2.ID())[0])
+	}
+
+	// we don't know whether h2 supports v2, so only v1 is offered
+	h1.Peerstore().RemoveProtocols(h2.ID(), identify.IDv2)
+	identifyNewConn()
+	require.Zero(t, v2Requests.Load())
+
+	// once we know h2 supports v2, it's preferred
+	require.NoError(t, h1.Peerstore().AddProtocols(h2.ID(), identify.IDv2))
+	identifyNewConn()
+	require.EqualValues(t, 1, v2Requests.Load())
+}
+
+func TestIdentifyConsumer(t *testing.T) {
+	newHost := func() (host.Host, identify.IDService) {
+		h := blhost.NewBlankHost(swarmt.GenSwarm(t))
+		t.Cleanup(func() { h.Close() })
+		ids, err := identify.NewIDService(h, identify.UserAgent("consumer-test"))
+		require.NoError(t, err)
+		ids.Start()
+		t.Cleanup(func() { ids.Close() })
+		return h, ids
+	}
+	h1, ids1 := newHost()
+	h2, _ := newHost()
+
+	type msg struct {
+		evt    event.EvtPeerIdentificationCompleted
+		isPush bool
+	}
+	msgs := make(chan msg, 10)
+	ids1.SetConsumer("test", identify.ConsumerFunc(func(evt event.EvtPeerIdentificationCompleted, isPush bool) {
+		msgs <- msg{evt: evt, isPush: isPush}
+	}))
+	// removed consumers are not called
+	ids1.SetConsumer("removed", identify.ConsumerFunc(func(event.EvtPeerIdentificationCompleted, bool) {
+		t.Error("removed consumer called")
+	}))
+	ids1.RemoveConsumer("removed")
+
+	require.NoError(t, h1.Connect(context.Background(), peer.AddrInfo{ID: h2.ID(), Addrs: h2.Addrs()}))
+	ids1.IdentifyConn(h1.Network().ConnsToPeer(h2.ID())[0])
+
+	select {
+	case m := <-msgs:
+		require.False(t, m.isPush)
+		require.Equal(t, h2.ID(), m.evt.Peer)
+		require.Equal(t, "consumer-test", m.evt.AgentVersion)
+		require.ElementsMatch(t, h2.Addrs(), m.evt.ListenAddrs)
+		require.NotNil(t, m.evt.SignedPeerRecord)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for identify message")
+	}
+
+	// changing our protocols triggers a push
+	h2.SetStreamHandler("/consumer-test", func(network.Stream) {})
+	select {
+	case m := <-msgs:
+		require.True(t, m.isPush)
+		require.Contains(t, m.evt.Protocols, protocol.ID("/consumer-test"))
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for identify push")
+	}
+}
+
 func TestNotListening(t *testing.T) {
 	// Make sure we don't panic if we're not listening on any addresses.
 	//
@@ -614,6 +867,50 @@ func TestSendPush(t *testing.T) {
 		sup, err := h2.Peerstore().SupportsProtocols(h1.ID(), []protocol.ID{"rand"}...)
 		return err == nil && len(sup) == 0
 	}, time.Second, 10*time.Millisecond)
+
+	// h2 knows the sequence number of h1's current identify information
+	require.Eventually(t, func() bool {
+		seq, ok := ids2.PeerSequence(h1.ID())
+		return ok && seq == ids1.Sequence()
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestSendPushWithRoundBudget(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	h1 := blhost.NewBlankHost(swarmt.GenSwarm(t))
+	defer h1.Close()
+	// A budget this small makes every round stop after its first push.
+	ids1, err := identify.NewIDService(h1, identify.WithPushConcurrency(1), identify.WithPushRoundBudget(time.Nanosecond))
+	require.NoError(t, err)
+	defer ids1.Close()
+	ids1.Start()
+
+	peers := make([]*blhost.BlankHost, 5)
+	for i := range peers {
+		h := blhost.NewBlankHost(swarmt.GenSwarm(t))
+		defer h.Close()
+		ids, err := identify.NewIDService(h)
+		require.NoError(t, err)
+		defer ids.Close()
+		ids.Start()
+		require.NoError(t, h1.Connect(ctx, peer.AddrInfo{ID: h.ID(), Addrs: h.Addrs()}))
+		ids1.IdentifyConn(h1.Network().ConnsToPeer(h.ID())[0])
+		peers[i] = h
+	}
+
+	// all peers eventually receive the push
+	h1.SetStreamHandler("rand", func(network.Stream) {})
+	require.Eventually(t, func() bool {
+		for _, h := range peers {
+			sup, err := h.Peerstore().SupportsProtocols(h1.ID(), "rand")
+			if err != nil || len(sup) != 1 {
+				return false
+			}
+		}
+		return true
+	}, 5*time.Second, 10*time.Millisecond)
 }
 
 func TestLargeIdentifyMessage(t *testing.T) {
@@ -843,9 +1140,11 @@ func TestIdentifyResponseReadTimeout(t *testing.T) {
 	ids2.Start()
 
 	// remote stream handler will just hang and not send back an identify response
-	h2.SetStreamHandler(identify.ID, func(s network.Stream) {
+	hang := func(s network.Stream) {
 		time.Sleep(100 * time.Second)
-	})
+	}
+	h2.SetStreamHandler(identify.ID, hang)
+	h2.SetStreamHandler(identify.IDv2, hang)
 
 	sub, err := ids1.Host.EventBus().Subscribe(new(event.EvtPeerIdentificationFailed))
 	require.NoError(t, err)
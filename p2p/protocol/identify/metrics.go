@@ -1,6 +1,8 @@
 package identify
 
 import (
+	"time"
+
 	"github.com/libp2p/go-libp2p/core/event"
 	"github.com/libp2p/go-libp2p/core/network"
 	"github.com/libp2p/go-libp2p/p2p/metricshelper"
@@ -11,78 +13,6 @@ import (
 const metricNamespace = "libp2p_identify"
 
 var (
-	pushesTriggered = prometheus.NewCounterVec(
-		prometheus.CounterOpts{
-			Namespace: metricNamespace,
-			Name:      "identify_pushes_triggered_total",
-			Help:      "Pushes Triggered",
-		},
-		[]string{"trigger"},
-	)
-	identify = prometheus.NewCounterVec(
-		prometheus.CounterOpts{
-			Namespace: metricNamespace,
-			Name:      "identify_total",
-			Help:      "Identify",
-		},
-		[]string{"dir"},
-	)
-	identifyPush = prometheus.NewCounterVec(
-		prometheus.CounterOpts{
-			Namespace: metricNamespace,
-			Name:      "identify_push_total",
-			Help:      "Identify Push",
-		},
-		[]string{"dir"},
-	)
-	connPushSupportTotal = prometheus.NewCounterVec(
-		prometheus.CounterOpts{
-			Namespace: metricNamespace,
-			Name:      "conn_push_support_total",
-			Help:      "Identify Connection Push Support",
-		},
-		[]string{"support"},
-	)
-	protocolsCount = prometheus.NewGauge(
-		prometheus.GaugeOpts{
-			Namespace: metricNamespace,
-			Name:      "protocols_count",
-			Help:      "Protocols Count",
-		},
-	)
-	addrsCount = prometheus.NewGauge(
-		prometheus.GaugeOpts{
-			Namespace: metricNamespace,
-			Name:      "addrs_count",
-			Help:      "Address Count",
-		},
-	)
-	numProtocolsReceived = prometheus.NewHistogram(
-		prometheus.HistogramOpts{
-			Namespace: metricNamespace,
-			Name:      "protocols_received",
-			Help:      "Number of Protocols received",
-			Buckets:   buckets,
-		},
-	)
-	numAddrsReceived = prometheus.NewHistogram(
-		prometheus.HistogramOpts{
-			Namespace: metricNamespace,
-			Name:      "addrs_received",
-			Help:      "Number of addrs received",
-			Buckets:   buckets,
-		},
-	)
-	collectors = []prometheus.Collector{
-		pushesTriggered,
-		identify,
-		identifyPush,
-		connPushSupportTotal,
-		protocolsCount,
-		addrsCount,
-		numProtocolsReceived,
-		numAddrsReceived,
-	}
 	// 1 to 20 and then up to 100 in steps of 5
 	buckets = append(
 		prometheus.LinearBuckets(1, 1, 20),
@@ -90,6 +20,122 @@ var (
 	)
 )
 
+type metricsCollectors struct {
+	pushesTriggered           *prometheus.CounterVec
+	identify                  *prometheus.CounterVec
+	identifyPush              *prometheus.CounterVec
+	connPushSupportTotal      *prometheus.CounterVec
+	protocolsCount            prometheus.Gauge
+	addrsCount                prometheus.Gauge
+	numProtocolsReceived      prometheus.Histogram
+	numAddrsReceived          prometheus.Histogram
+	connEstablishmentDuration *prometheus.HistogramVec
+	connsEstablished          *prometheus.CounterVec
+}
+
+func newMetricsCollectors() *metricsCollectors {
+	return &metricsCollectors{
+		pushesTriggered: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: metricNamespace,
+				Name:      "identify_pushes_triggered_total",
+				Help:      "Pushes Triggered",
+			},
+			[]string{"trigger"},
+		),
+		identify: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: metricNamespace,
+				Name:      "identify_total",
+				Help:      "Identify",
+			},
+			[]string{"dir"},
+		),
+		identifyPush: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: metricNamespace,
+				Name:      "identify_push_total",
+				Help:      "Identify Push",
+			},
+			[]string{"dir"},
+		),
+		connPushSupportTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: metricNamespace,
+				Name:      "conn_push_support_total",
+				Help:      "Identify Connection Push Support",
+			},
+			[]string{"support"},
+		),
+		protocolsCount: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace: metricNamespace,
+				Name:      "protocols_count",
+				Help:      "Protocols Count",
+			},
+		),
+		addrsCount: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace: metricNamespace,
+				Name:      "addrs_count",
+				Help:      "Address Count",
+			},
+		),
+		numProtocolsReceived: prometheus.NewHistogram(
+			prometheus.HistogramOpts{
+				Namespace: metricNamespace,
+				Name:      "protocols_received",
+				Help:      "Number of Protocols received",
+				Buckets:   buckets,
+			},
+		),
+		numAddrsReceived: prometheus.NewHistogram(
+			prometheus.HistogramOpts{
+				Namespace: metricNamespace,
+				Name:      "addrs_received",
+				Help:      "Number of addrs received",
+				Buckets:   buckets,
+			},
+		),
+		connEstablishmentDuration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace: metricNamespace,
+				Name:      "conn_establishment_duration_seconds",
+				Help:      "Duration of the phases of establishing a connection",
+				Buckets:   prometheus.ExponentialBuckets(0.001, 2, 15),
+			},
+			[]string{"phase", "transport", "dir"},
+		),
+		connsEstablished: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: metricNamespace,
+				Name:      "conns_established_total",
+				Help:      "Connections established, by negotiated protocols and handshake properties",
+			},
+			[]string{"transport", "security", "muxer", "cipher_suite", "early_data"},
+		),
+	}
+}
+
+func (c *metricsCollectors) Collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		c.pushesTriggered,
+		c.identify,
+		c.identifyPush,
+		c.connPushSupportTotal,
+		c.protocolsCount,
+		c.addrsCount,
+		c.numProtocolsReceived,
+		c.numAddrsReceived,
+		c.connEstablishmentDuration,
+		c.connsEstablished,
+	}
+}
+
+// defaultCollectors are shared by all metrics tracers, unless they register
+// with a metricshelper.LabeledRegisterer.
+var defaultCollectors = newMetricsCollectors()
+
 type MetricsTracer interface {
 	// TriggeredPushes counts IdentifyPushes triggered by event
 	TriggeredPushes(event any)
@@ -104,9 +150,23 @@ type MetricsTracer interface {
 	IdentifySent(isPush bool, numProtocols int, numAddrs int)
 }
 
-type metricsTracer struct{}
+// ConnectionMetricsTracer is an optional interface of a MetricsTracer. If the
+// MetricsTracer implements it, it is notified about every connection that
+// completed its first identify exchange.
+type ConnectionMetricsTracer interface {
+	// ConnectionEstablished tracks the timing breakdown of establishing a connection,
+	// and the protocols negotiated on it
+	ConnectionEstablished(dir network.Direction, state network.ConnectionState, timings network.ConnTimings)
+}
 
-var _ MetricsTracer = &metricsTracer{}
+type metricsTracer struct {
+	*metricsCollectors
+}
+
+var (
+	_ MetricsTracer           = &metricsTracer{}
+	_ ConnectionMetricsTracer = &metricsTracer{}
+)
 
 type metricsTracerSetting struct {
 	reg prometheus.Registerer
@@ -127,8 +187,7 @@ func NewMetricsTracer(opts ...MetricsTracerOption) MetricsTracer {
 	for _, opt := range opts {
 		opt(setting)
 	}
-	metricshelper.RegisterCollectors(setting.reg, collectors...)
-	return &metricsTracer{}
+	return &metricsTracer{metricshelper.Collectors(setting.reg, defaultCollectors, newMetricsCollectors)}
 }
 
 func (t *metricsTracer) TriggeredPushes(ev any) {
@@ -143,7 +202,7 @@ func (t *metricsTracer) TriggeredPushes(ev any) {
 		typ = "addresses_updated"
 	}
 	*tags = append(*tags, typ)
-	pushesTriggered.WithLabelValues(*tags...).Inc()
+	t.pushesTriggered.WithLabelValues(*tags...).Inc()
 }
 
 func (t *metricsTracer) IncrementPushSupport(s identifyPushSupport) {
@@ -151,7 +210,7 @@ func (t *metricsTracer) IncrementPushSupport(s identifyPushSupport) {
 	defer metricshelper.PutStringSlice(tags)
 
 	*tags = append(*tags, getPushSupport(s))
-	connPushSupportTotal.WithLabelValues(*tags...).Inc()
+	t.connPushSupportTotal.WithLabelValues(*tags...).Inc()
 }
 
 func (t *metricsTracer) IdentifySent(isPush bool, numProtocols int, numAddrs int) {
@@ -160,14 +219,14 @@ func (t *metricsTracer) IdentifySent(isPush bool, numProtocols int, numAddrs int
 
 	if isPush {
 		*tags = append(*tags, metricshelper.GetDirection(network.DirOutbound))
-		identifyPush.WithLabelValues(*tags...).Inc()
+		t.identifyPush.WithLabelValues(*tags...).Inc()
 	} else {
 		*tags = append(*tags, metricshelper.GetDirection(network.DirInbound))
-		identify.WithLabelValues(*tags...).Inc()
+		t.identify.WithLabelValues(*tags...).Inc()
 	}
 
-	protocolsCount.Set(float64(numProtocols))
-	addrsCount.Set(float64(numAddrs))
+	t.protocolsCount.Set(float64(numProtocols))
+	t.addrsCount.Set(float64(numAddrs))
 }
 
 func (t *metricsTracer) IdentifyReceived(isPush bool, numProtocols int, numAddrs int) {
@@ -176,14 +235,14 @@ func (t *metricsTracer) IdentifyReceived(isPush bool, numProtocols int, numAddrs
 
 	if isPush {
 		*tags = append(*tags, metricshelper.GetDirection(network.DirInbound))
-		identifyPush.WithLabelValues(*tags...).Inc()
+		t.identifyPush.WithLabelValues(*tags...).Inc()
 	} else {
 		*tags = append(*tags, metricshelper.GetDirection(network.DirOutbound))
-		identify.WithLabelValues(*tags...).Inc()
+		t.identify.WithLabelValues(*tags...).Inc()
 	}
 
-	numProtocolsReceived.Observe(float64(numProtocols))
-	numAddrsReceived.Observe(float64(numAddrs))
+	t.numProtocolsReceived.Observe(float64(numProtocols))
+	t.numAddrsReceived.Observe(float64(numAddrs))
 }
 
 func (t *metricsTracer) ConnPushSupport(support identifyPushSupport) {
@@ -191,7 +250,7 @@ func (t *metricsTracer) ConnPushSupport(support identifyPushSupport) {
 	defer metricshelper.PutStringSlice(tags)
 
 	*tags = append(*tags, getPushSupport(support))
-	connPushSupportTotal.WithLabelValues(*tags...).Inc()
+	t.connPushSupportTotal.WithLabelValues(*tags...).Inc()
 }
 
 func getPushSupport(s identifyPushSupport) string {
@@ -204,3 +263,31 @@ func getPushSupport(s identifyPushSupport) string {
 		return "unknown"
 	}
 }
+
+func (t *metricsTracer) ConnectionEstablished(dir network.Direction, state network.ConnectionState, timings network.ConnTimings) {
+	tags := metricshelper.GetStringSlice()
+	defer metricshelper.PutStringSlice(tags)
+
+	transport := state.Transport
+	if transport == "" {
+		transport = "unknown"
+	}
+	earlyData := "false"
+	if state.UsedEarlyData {
+		earlyData = "true"
+	}
+	*tags = append(*tags, transport, string(state.Security), string(state.StreamMultiplexer), state.CipherSuite, earlyData)
+	t.connsEstablished.WithLabelValues(*tags...).Inc()
+
+	observe := func(phase string, d time.Duration) {
+		if d <= 0 {
+			return
+		}
+		*tags = append((*tags)[:0], phase, transport, metricshelper.GetDirection(dir))
+		t.connEstablishmentDuration.WithLabelValues(*tags...).Observe(d.Seconds())
+	}
+	observe("handshake", timings.Handshake)
+	observe("security", timings.Security)
+	observe("muxer", timings.Muxer)
+	observe("identify", timings.Identify)
+}
@@ -5,8 +5,10 @@ package identify
 import (
 	"math/rand"
 	"testing"
+	"time"
 
 	"github.com/libp2p/go-libp2p/core/event"
+	"github.com/libp2p/go-libp2p/core/network"
 )
 
 func TestMetricsNoAllocNoCover(t *testing.T) {
@@ -22,12 +24,28 @@ func TestMetricsNoAllocNoCover(t *testing.T) {
 		identifyPushUnsupported,
 	}
 
-	tr := NewMetricsTracer()
+	dirs := []network.Direction{network.DirInbound, network.DirOutbound}
+	states := []network.ConnectionState{
+		{Transport: "tcp", Security: "/noise", StreamMultiplexer: "/yamux/1.0.0", CipherSuite: "25519_ChaChaPoly_SHA256", UsedEarlyData: true},
+		{Transport: "quic-v1", CipherSuite: "TLS_AES_128_GCM_SHA256"},
+		{Transport: "websocket", Security: "/tls/1.0.0", StreamMultiplexer: "/yamux/1.0.0", CipherSuite: "TLS_CHACHA20_POLY1305_SHA256"},
+	}
+	timings := network.ConnTimings{
+		Handshake: time.Millisecond,
+		Security:  2 * time.Millisecond,
+		Muxer:     time.Millisecond,
+		Identify:  10 * time.Millisecond,
+	}
+
+	tr := NewMetricsTracer().(*metricsTracer)
 	tests := map[string]func(){
 		"TriggeredPushes":  func() { tr.TriggeredPushes(events[rand.Intn(len(events))]) },
 		"ConnPushSupport":  func() { tr.ConnPushSupport(pushSupport[rand.Intn(len(pushSupport))]) },
 		"IdentifyReceived": func() { tr.IdentifyReceived(rand.Intn(2) == 0, rand.Intn(20), rand.Intn(20)) },
 		"IdentifySent":     func() { tr.IdentifySent(rand.Intn(2) == 0, rand.Intn(20), rand.Intn(20)) },
+		"ConnectionEstablished": func() {
+			tr.ConnectionEstablished(dirs[rand.Intn(len(dirs))], states[rand.Intn(len(states))], timings)
+		},
 	}
 	for method, f := range tests {
 		allocs := testing.AllocsPerRun(1000, f)
@@ -3,9 +3,11 @@ package identify
 import (
 	"context"
 	"fmt"
+	"log/slog"
 	"sync"
 	"time"
 
+	"github.com/benbjohnson/clock"
 	"github.com/libp2p/go-libp2p/core/event"
 	"github.com/libp2p/go-libp2p/core/host"
 	"github.com/libp2p/go-libp2p/core/network"
@@ -19,6 +21,8 @@ type natEmitter struct {
 	reachabilitySub event.Subscription
 	reachability    network.Reachability
 	eventInterval   time.Duration
+	clock           clock.Clock
+	log             *slog.Logger
 
 	currentUDPNATDeviceType  network.NATDeviceType
 	currentTCPNATDeviceType  network.NATDeviceType
@@ -27,13 +31,15 @@ type natEmitter struct {
 	observedAddrMgr *ObservedAddrManager
 }
 
-func newNATEmitter(h host.Host, o *ObservedAddrManager, eventInterval time.Duration) (*natEmitter, error) {
+func newNATEmitter(h host.Host, o *ObservedAddrManager, eventInterval time.Duration, cl clock.Clock, logger *slog.Logger) (*natEmitter, error) {
 	ctx, cancel := context.WithCancel(context.Background())
 	n := &natEmitter{
 		observedAddrMgr: o,
 		ctx:             ctx,
 		cancel:          cancel,
 		eventInterval:   eventInterval,
+		clock:           cl,
+		log:             logger,
 	}
 	reachabilitySub, err := h.EventBus().Subscribe(new(event.EvtLocalReachabilityChanged), eventbus.Name("identify (nat emitter)"))
 	if err != nil {
@@ -55,7 +61,8 @@ func newNATEmitter(h host.Host, o *ObservedAddrManager, eventInterval time.Durat
 func (n *natEmitter) worker() {
 	defer n.wg.Done()
 	subCh := n.reachabilitySub.Out()
-	ticker := time.NewTicker(n.eventInterval)
+	ticker := n.clock.Ticker(n.eventInterval)
+	defer ticker.Stop()
 	pendingUpdate := false
 	enoughTimeSinceLastUpdate := true
 	for {
@@ -67,7 +74,7 @@ func (n *natEmitter) worker() {
 			}
 			ev, ok := evt.(event.EvtLocalReachabilityChanged)
 			if !ok {
-				log.Error("invalid event: %v", evt)
+				n.log.Error("invalid event", "event", evt)
 				continue
 			}
 			n.reachability = ev.Reachability
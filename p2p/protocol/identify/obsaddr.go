@@ -3,6 +3,7 @@ package identify
 import (
 	"context"
 	"fmt"
+	"log/slog"
 	"net"
 	"slices"
 	"sort"
@@ -148,6 +149,7 @@ type ObservedAddrManager struct {
 	hostAddrs func() []ma.Multiaddr
 	// Any normalization required before comparing. Useful to remove certhash
 	normalize func(ma.Multiaddr) ma.Multiaddr
+	log       *slog.Logger
 	// worker channel for new observations
 	wch chan observation
 	// notified on recording an observation
@@ -171,6 +173,12 @@ type ObservedAddrManager struct {
 // NewObservedAddrManager returns a new address manager using peerstore.OwnObservedAddressTTL as the TTL.
 func NewObservedAddrManager(listenAddrs, hostAddrs func() []ma.Multiaddr,
 	interfaceListenAddrs func() ([]ma.Multiaddr, error), normalize func(ma.Multiaddr) ma.Multiaddr) (*ObservedAddrManager, error) {
+	return newObservedAddrManager(listenAddrs, hostAddrs, interfaceListenAddrs, normalize, log)
+}
+
+func newObservedAddrManager(listenAddrs, hostAddrs func() []ma.Multiaddr,
+	interfaceListenAddrs func() ([]ma.Multiaddr, error), normalize func(ma.Multiaddr) ma.Multiaddr,
+	logger *slog.Logger) (*ObservedAddrManager, error) {
 	if normalize == nil {
 		normalize = func(addr ma.Multiaddr) ma.Multiaddr { return addr }
 	}
@@ -184,6 +192,7 @@ func NewObservedAddrManager(listenAddrs, hostAddrs func() []ma.Multiaddr,
 		interfaceListenAddrs: interfaceListenAddrs,
 		hostAddrs:            hostAddrs,
 		normalize:            normalize,
+		log:                  logger,
 	}
 	o.ctx, o.ctxCancel = context.WithCancel(context.Background())
 
@@ -271,8 +280,8 @@ func (o *ObservedAddrManager) Record(conn connMultiaddrs, observed ma.Multiaddr)
 		observed: observed,
 	}:
 	default:
-		log.Debugw("dropping address observation due to full buffer",
-			"from", conn.RemoteMultiaddr(),
+		o.log.Debug("dropping address observation due to full buffer",
+			"addr", conn.RemoteMultiaddr(),
 			"observed", observed,
 		)
 	}
@@ -312,7 +321,7 @@ func (o *ObservedAddrManager) shouldRecordObservation(conn connMultiaddrs, obser
 	// the same as the listen addr.
 	ifaceaddrs, err := o.interfaceListenAddrs()
 	if err != nil {
-		log.Infof("failed to get interface listen addrs", err)
+		o.log.Info("failed to get interface listen addrs", "error", err)
 		return false, thinWaist{}, thinWaist{}
 	}
 
@@ -350,9 +359,9 @@ func (o *ObservedAddrManager) shouldRecordObservation(conn connMultiaddrs, obser
 	// transports of one of our advertised addresses.
 	if !HasConsistentTransport(observed, hostAddrs) &&
 		!HasConsistentTransport(observed, listenAddrs) {
-		log.Debugw(
+		o.log.Debug(
 			"observed multiaddr doesn't match the transports of any announced addresses",
-			"from", conn.RemoteMultiaddr(),
+			"addr", conn.RemoteMultiaddr(),
 			"observed", observed,
 		)
 		return false, thinWaist{}, thinWaist{}
@@ -366,7 +375,7 @@ func (o *ObservedAddrManager) maybeRecordObservation(conn connMultiaddrs, observ
 	if !shouldRecord {
 		return
 	}
-	log.Debugw("added own observed listen addr", "observed", observed)
+	o.log.Debug("added own observed listen addr", "observed", observed)
 
 	o.mu.Lock()
 	defer o.mu.Unlock()
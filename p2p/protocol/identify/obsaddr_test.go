@@ -13,6 +13,7 @@ import (
 	"github.com/libp2p/go-libp2p/p2p/host/eventbus"
 	swarmt "github.com/libp2p/go-libp2p/p2p/net/swarm/testing"
 
+	"github.com/benbjohnson/clock"
 	ma "github.com/multiformats/go-multiaddr"
 	manet "github.com/multiformats/go-multiaddr/net"
 	"github.com/stretchr/testify/require"
@@ -450,7 +451,7 @@ func TestObservedAddrManager(t *testing.T) {
 		emitter.Emit(event.EvtLocalReachabilityChanged{Reachability: network.ReachabilityPrivate})
 
 		// start nat emitter
-		n, err := newNATEmitter(h, o, 10*time.Millisecond)
+		n, err := newNATEmitter(h, o, 10*time.Millisecond, clock.New(), log)
 		require.NoError(t, err)
 		defer n.Close()
 
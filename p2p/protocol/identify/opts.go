@@ -1,11 +1,28 @@
 package identify
 
+import (
+	"log/slog"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/connmgr"
+	"github.com/libp2p/go-libp2p/core/network"
+
+	"github.com/benbjohnson/clock"
+)
+
 type config struct {
 	protocolVersion            string
 	userAgent                  string
 	disableSignedPeerRecord    bool
 	metricsTracer              MetricsTracer
 	disableObservedAddrManager bool
+	clock                      clock.Clock
+	gater                      connmgr.IdentifyGater
+	disableV2                  bool
+	logger                     *slog.Logger
+	addrTranslator             network.AddrTranslator
+	pushConcurrency            int
+	pushRoundBudget            time.Duration
 }
 
 // Option is an option function for identify.
@@ -47,3 +64,68 @@ func DisableObservedAddrManager() Option {
 		cfg.disableObservedAddrManager = true
 	}
 }
+
+// WithClock sets the clock used by the identify service for the NAT type
+// events, the identify push rounds and timeouts, and the identify duration of
+// new connections. Stream deadlines always use the real time.
+// Defaults to the real clock.
+func WithClock(cl clock.Clock) Option {
+	return func(cfg *config) {
+		cfg.clock = cl
+	}
+}
+
+// WithIdentifyGater sets a gater that is consulted on every identify message
+// we receive, and that can close the connection based on the peer's agent
+// version and protocols.
+func WithIdentifyGater(g connmgr.IdentifyGater) Option {
+	return func(cfg *config) {
+		cfg.gater = g
+	}
+}
+
+// DisableV2 disables identify v2 (IDv2 and IDPushv2). Only identify v1 is
+// spoken, both for incoming and outgoing requests.
+func DisableV2() Option {
+	return func(cfg *config) {
+		cfg.disableV2 = true
+	}
+}
+
+// WithLogger sets the logger used by the identify service. By default, the
+// service logs to the go-log logger "net/identify".
+func WithLogger(l *slog.Logger) Option {
+	return func(cfg *config) {
+		cfg.logger = l
+	}
+}
+
+// WithAddrTranslator sets the translator used to translate the addresses of
+// connections back into the addresses peers use, before reporting them to the
+// peer as its observed address, and before recording the observed addresses
+// peers report to us.
+func WithAddrTranslator(t network.AddrTranslator) Option {
+	return func(cfg *config) {
+		cfg.addrTranslator = t
+	}
+}
+
+// WithPushConcurrency sets the maximum number of identify pushes that are sent
+// concurrently. Defaults to 32.
+func WithPushConcurrency(n int) Option {
+	return func(cfg *config) {
+		cfg.pushConcurrency = n
+	}
+}
+
+// WithPushRoundBudget limits the time spent starting the identify pushes of a
+// single push round. Peers are pushed to in order of importance: protected
+// peers first, then peers with the highest tag values, then peers with open
+// streams. Once the budget is used up, the remaining peers are pushed to in a
+// new round, which picks up any change of our identify information in the
+// meantime. By default, a round sends pushes to all peers.
+func WithPushRoundBudget(d time.Duration) Option {
+	return func(cfg *config) {
+		cfg.pushRoundBudget = d
+	}
+}
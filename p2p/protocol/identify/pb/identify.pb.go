@@ -1,6 +1,6 @@
 // Code generated by protoc-gen-go. DO NOT EDIT.
 // versions:
-// 	protoc-gen-go v1.30.0
+// 	protoc-gen-go v1.34.1
 // 	protoc        v3.21.12
 // source: pb/identify.proto
 
@@ -20,6 +20,65 @@ const (
 	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
 )
 
+type Address_Reachability int32
+
+const (
+	Address_UNKNOWN Address_Reachability = 0
+	Address_PUBLIC  Address_Reachability = 1
+	Address_PRIVATE Address_Reachability = 2
+)
+
+// Enum value maps for Address_Reachability.
+var (
+	Address_Reachability_name = map[int32]string{
+		0: "UNKNOWN",
+		1: "PUBLIC",
+		2: "PRIVATE",
+	}
+	Address_Reachability_value = map[string]int32{
+		"UNKNOWN": 0,
+		"PUBLIC":  1,
+		"PRIVATE": 2,
+	}
+)
+
+func (x Address_Reachability) Enum() *Address_Reachability {
+	p := new(Address_Reachability)
+	*p = x
+	return p
+}
+
+func (x Address_Reachability) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Address_Reachability) Descriptor() protoreflect.EnumDescriptor {
+	return file_pb_identify_proto_enumTypes[0].Descriptor()
+}
+
+func (Address_Reachability) Type() protoreflect.EnumType {
+	return &file_pb_identify_proto_enumTypes[0]
+}
+
+func (x Address_Reachability) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Do not use.
+func (x *Address_Reachability) UnmarshalJSON(b []byte) error {
+	num, err := protoimpl.X.UnmarshalJSONEnum(x.Descriptor(), b)
+	if err != nil {
+		return err
+	}
+	*x = Address_Reachability(num)
+	return nil
+}
+
+// Deprecated: Use Address_Reachability.Descriptor instead.
+func (Address_Reachability) EnumDescriptor() ([]byte, []int) {
+	return file_pb_identify_proto_rawDescGZIP(), []int{1, 0}
+}
+
 type Identify struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -48,6 +107,13 @@ type Identify struct {
 	// see github.com/libp2p/go-libp2p/core/record/pb/envelope.proto and
 	// github.com/libp2p/go-libp2p/core/peer/pb/peer_record.proto for message definitions.
 	SignedPeerRecord []byte `protobuf:"bytes,8,opt,name=signedPeerRecord" json:"signedPeerRecord,omitempty"`
+	// addrs are the multiaddrs the sender node listens for open connections on, with
+	// per-address metadata. Only sent on identify v2, instead of listenAddrs.
+	Addrs []*Address `protobuf:"bytes,9,rep,name=addrs" json:"addrs,omitempty"`
+	// sequence is the sequence number of the sender's identify information. It
+	// increases every time the information changes, so that receivers can
+	// ignore messages that arrive out of order.
+	Sequence *uint64 `protobuf:"varint,1000,opt,name=sequence" json:"sequence,omitempty"`
 }
 
 func (x *Identify) Reset() {
@@ -131,12 +197,95 @@ func (x *Identify) GetSignedPeerRecord() []byte {
 	return nil
 }
 
+func (x *Identify) GetAddrs() []*Address {
+	if x != nil {
+		return x.Addrs
+	}
+	return nil
+}
+
+func (x *Identify) GetSequence() uint64 {
+	if x != nil && x.Sequence != nil {
+		return *x.Sequence
+	}
+	return 0
+}
+
+// Address is a listen address, as sent by identify v2.
+type Address struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// multiaddr is the address, without its trailing certhash components.
+	Multiaddr []byte `protobuf:"bytes,1,opt,name=multiaddr" json:"multiaddr,omitempty"`
+	// certhashes are the multihashes of the certificates used by the address,
+	// for transports like WebTransport and WebRTC. They're appended to the end of
+	// multiaddr as certhash components.
+	Certhashes [][]byte `protobuf:"bytes,2,rep,name=certhashes" json:"certhashes,omitempty"`
+	// reachability is the reachability of the address, as determined by the sender.
+	Reachability *Address_Reachability `protobuf:"varint,3,opt,name=reachability,enum=identify.pb.Address_Reachability" json:"reachability,omitempty"`
+}
+
+func (x *Address) Reset() {
+	*x = Address{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_pb_identify_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Address) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Address) ProtoMessage() {}
+
+func (x *Address) ProtoReflect() protoreflect.Message {
+	mi := &file_pb_identify_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Address.ProtoReflect.Descriptor instead.
+func (*Address) Descriptor() ([]byte, []int) {
+	return file_pb_identify_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Address) GetMultiaddr() []byte {
+	if x != nil {
+		return x.Multiaddr
+	}
+	return nil
+}
+
+func (x *Address) GetCerthashes() [][]byte {
+	if x != nil {
+		return x.Certhashes
+	}
+	return nil
+}
+
+func (x *Address) GetReachability() Address_Reachability {
+	if x != nil && x.Reachability != nil {
+		return *x.Reachability
+	}
+	return Address_UNKNOWN
+}
+
 var File_pb_identify_proto protoreflect.FileDescriptor
 
 var file_pb_identify_proto_rawDesc = []byte{
 	0x0a, 0x11, 0x70, 0x62, 0x2f, 0x69, 0x64, 0x65, 0x6e, 0x74, 0x69, 0x66, 0x79, 0x2e, 0x70, 0x72,
 	0x6f, 0x74, 0x6f, 0x12, 0x0b, 0x69, 0x64, 0x65, 0x6e, 0x74, 0x69, 0x66, 0x79, 0x2e, 0x70, 0x62,
-	0x22, 0x86, 0x02, 0x0a, 0x08, 0x49, 0x64, 0x65, 0x6e, 0x74, 0x69, 0x66, 0x79, 0x12, 0x28, 0x0a,
+	0x22, 0xcf, 0x02, 0x0a, 0x08, 0x49, 0x64, 0x65, 0x6e, 0x74, 0x69, 0x66, 0x79, 0x12, 0x28, 0x0a,
 	0x0f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x63, 0x6f, 0x6c, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e,
 	0x18, 0x05, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x63, 0x6f, 0x6c,
 	0x56, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x12, 0x22, 0x0a, 0x0c, 0x61, 0x67, 0x65, 0x6e, 0x74,
@@ -152,7 +301,24 @@ var file_pb_identify_proto_rawDesc = []byte{
 	0x28, 0x09, 0x52, 0x09, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x63, 0x6f, 0x6c, 0x73, 0x12, 0x2a, 0x0a,
 	0x10, 0x73, 0x69, 0x67, 0x6e, 0x65, 0x64, 0x50, 0x65, 0x65, 0x72, 0x52, 0x65, 0x63, 0x6f, 0x72,
 	0x64, 0x18, 0x08, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x10, 0x73, 0x69, 0x67, 0x6e, 0x65, 0x64, 0x50,
-	0x65, 0x65, 0x72, 0x52, 0x65, 0x63, 0x6f, 0x72, 0x64,
+	0x65, 0x65, 0x72, 0x52, 0x65, 0x63, 0x6f, 0x72, 0x64, 0x12, 0x2a, 0x0a, 0x05, 0x61, 0x64, 0x64,
+	0x72, 0x73, 0x18, 0x09, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x14, 0x2e, 0x69, 0x64, 0x65, 0x6e, 0x74,
+	0x69, 0x66, 0x79, 0x2e, 0x70, 0x62, 0x2e, 0x41, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x52, 0x05,
+	0x61, 0x64, 0x64, 0x72, 0x73, 0x12, 0x1b, 0x0a, 0x08, 0x73, 0x65, 0x71, 0x75, 0x65, 0x6e, 0x63,
+	0x65, 0x18, 0xe8, 0x07, 0x20, 0x01, 0x28, 0x04, 0x52, 0x08, 0x73, 0x65, 0x71, 0x75, 0x65, 0x6e,
+	0x63, 0x65, 0x22, 0xc4, 0x01, 0x0a, 0x07, 0x41, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x12, 0x1c,
+	0x0a, 0x09, 0x6d, 0x75, 0x6c, 0x74, 0x69, 0x61, 0x64, 0x64, 0x72, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x0c, 0x52, 0x09, 0x6d, 0x75, 0x6c, 0x74, 0x69, 0x61, 0x64, 0x64, 0x72, 0x12, 0x1e, 0x0a, 0x0a,
+	0x63, 0x65, 0x72, 0x74, 0x68, 0x61, 0x73, 0x68, 0x65, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0c,
+	0x52, 0x0a, 0x63, 0x65, 0x72, 0x74, 0x68, 0x61, 0x73, 0x68, 0x65, 0x73, 0x12, 0x45, 0x0a, 0x0c,
+	0x72, 0x65, 0x61, 0x63, 0x68, 0x61, 0x62, 0x69, 0x6c, 0x69, 0x74, 0x79, 0x18, 0x03, 0x20, 0x01,
+	0x28, 0x0e, 0x32, 0x21, 0x2e, 0x69, 0x64, 0x65, 0x6e, 0x74, 0x69, 0x66, 0x79, 0x2e, 0x70, 0x62,
+	0x2e, 0x41, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x2e, 0x52, 0x65, 0x61, 0x63, 0x68, 0x61, 0x62,
+	0x69, 0x6c, 0x69, 0x74, 0x79, 0x52, 0x0c, 0x72, 0x65, 0x61, 0x63, 0x68, 0x61, 0x62, 0x69, 0x6c,
+	0x69, 0x74, 0x79, 0x22, 0x34, 0x0a, 0x0c, 0x52, 0x65, 0x61, 0x63, 0x68, 0x61, 0x62, 0x69, 0x6c,
+	0x69, 0x74, 0x79, 0x12, 0x0b, 0x0a, 0x07, 0x55, 0x4e, 0x4b, 0x4e, 0x4f, 0x57, 0x4e, 0x10, 0x00,
+	0x12, 0x0a, 0x0a, 0x06, 0x50, 0x55, 0x42, 0x4c, 0x49, 0x43, 0x10, 0x01, 0x12, 0x0b, 0x0a, 0x07,
+	0x50, 0x52, 0x49, 0x56, 0x41, 0x54, 0x45, 0x10, 0x02,
 }
 
 var (
@@ -167,16 +333,21 @@ func file_pb_identify_proto_rawDescGZIP() []byte {
 	return file_pb_identify_proto_rawDescData
 }
 
-var file_pb_identify_proto_msgTypes = make([]protoimpl.MessageInfo, 1)
+var file_pb_identify_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_pb_identify_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
 var file_pb_identify_proto_goTypes = []interface{}{
-	(*Identify)(nil), // 0: identify.pb.Identify
+	(Address_Reachability)(0), // 0: identify.pb.Address.Reachability
+	(*Identify)(nil),          // 1: identify.pb.Identify
+	(*Address)(nil),           // 2: identify.pb.Address
 }
 var file_pb_identify_proto_depIdxs = []int32{
-	0, // [0:0] is the sub-list for method output_type
-	0, // [0:0] is the sub-list for method input_type
-	0, // [0:0] is the sub-list for extension type_name
-	0, // [0:0] is the sub-list for extension extendee
-	0, // [0:0] is the sub-list for field type_name
+	2, // 0: identify.pb.Identify.addrs:type_name -> identify.pb.Address
+	0, // 1: identify.pb.Address.reachability:type_name -> identify.pb.Address.Reachability
+	2, // [2:2] is the sub-list for method output_type
+	2, // [2:2] is the sub-list for method input_type
+	2, // [2:2] is the sub-list for extension type_name
+	2, // [2:2] is the sub-list for extension extendee
+	0, // [0:2] is the sub-list for field type_name
 }
 
 func init() { file_pb_identify_proto_init() }
@@ -197,19 +368,32 @@ func file_pb_identify_proto_init() {
 				return nil
 			}
 		}
+		file_pb_identify_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Address); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
 	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_pb_identify_proto_rawDesc,
-			NumEnums:      0,
-			NumMessages:   1,
+			NumEnums:      1,
+			NumMessages:   2,
 			NumExtensions: 0,
 			NumServices:   0,
 		},
 		GoTypes:           file_pb_identify_proto_goTypes,
 		DependencyIndexes: file_pb_identify_proto_depIdxs,
+		EnumInfos:         file_pb_identify_proto_enumTypes,
 		MessageInfos:      file_pb_identify_proto_msgTypes,
 	}.Build()
 	File_pb_identify_proto = out.File
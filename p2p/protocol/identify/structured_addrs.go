@@ -0,0 +1,107 @@
+package identify
+
+import (
+	"errors"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	"github.com/libp2p/go-libp2p/p2p/protocol/identify/pb"
+
+	ma "github.com/multiformats/go-multiaddr"
+	mbase "github.com/multiformats/go-multibase"
+)
+
+func isV2(p protocol.ID) bool {
+	return p == IDv2 || p == IDPushv2
+}
+
+// pushProtocols returns the identify push protocols we speak, in order of preference.
+func (ids *idService) pushProtocols() []protocol.ID {
+	if ids.disableV2 {
+		return []protocol.ID{IDPush}
+	}
+	return []protocol.ID{IDPushv2, IDPush}
+}
+
+// addrReachabilityHost is implemented by hosts that verify the reachability
+// of each of their addresses, like the basic host.
+type addrReachabilityHost interface {
+	AddrReachability(ma.Multiaddr) network.Reachability
+}
+
+// setStructuredAddrs moves the listen addresses of mes to the addrs field used
+// by identify v2. If reachability is not nil, it's used to set the
+// reachability of each address.
+func setStructuredAddrs(mes *pb.Identify, reachability func(ma.Multiaddr) network.Reachability) {
+	mes.Addrs = make([]*pb.Address, 0, len(mes.ListenAddrs))
+	for _, b := range mes.ListenAddrs {
+		addr, err := ma.NewMultiaddrBytes(b)
+		if err != nil {
+			continue
+		}
+		a := addrToPB(addr)
+		if reachability != nil {
+			switch reachability(addr) {
+			case network.ReachabilityPublic:
+				a.Reachability = pb.Address_PUBLIC.Enum()
+			case network.ReachabilityPrivate:
+				a.Reachability = pb.Address_PRIVATE.Enum()
+			}
+		}
+		mes.Addrs = append(mes.Addrs, a)
+	}
+	mes.ListenAddrs = nil
+}
+
+// addrToPB converts addr into its identify v2 representation, splitting off
+// its trailing certhash components. Certhashes in the middle of the address,
+// e.g. in the relay part of a circuit address, are kept in place.
+func addrToPB(addr ma.Multiaddr) *pb.Address {
+	var comps []ma.Component
+	ma.ForEach(addr, func(c ma.Component) bool {
+		comps = append(comps, c)
+		return true
+	})
+	n := len(comps)
+	for n > 0 && comps[n-1].Protocol().Code == ma.P_CERTHASH {
+		n--
+	}
+	a := &pb.Address{}
+	for _, c := range comps[:n] {
+		a.Multiaddr = append(a.Multiaddr, c.Bytes()...)
+	}
+	for _, c := range comps[n:] {
+		a.Certhashes = append(a.Certhashes, c.RawValue())
+	}
+	return a
+}
+
+// addrFromPB is the inverse of addrToPB.
+func addrFromPB(a *pb.Address) (ma.Multiaddr, network.Reachability, error) {
+	if len(a.GetMultiaddr()) == 0 {
+		return nil, network.ReachabilityUnknown, errors.New("empty address")
+	}
+	addr, err := ma.NewMultiaddrBytes(a.GetMultiaddr())
+	if err != nil {
+		return nil, network.ReachabilityUnknown, err
+	}
+	for _, h := range a.GetCerthashes() {
+		s, err := mbase.Encode(mbase.Base64url, h)
+		if err != nil {
+			return nil, network.ReachabilityUnknown, err
+		}
+		c, err := ma.NewComponent(ma.ProtocolWithCode(ma.P_CERTHASH).Name, s)
+		if err != nil {
+			return nil, network.ReachabilityUnknown, err
+		}
+		addr = addr.Encapsulate(c)
+	}
+	var reachability network.Reachability
+	switch a.GetReachability() {
+	case pb.Address_PUBLIC:
+		reachability = network.ReachabilityPublic
+	case pb.Address_PRIVATE:
+		reachability = network.ReachabilityPrivate
+	}
+	return addr, reachability, nil
+}
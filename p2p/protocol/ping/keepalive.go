@@ -0,0 +1,279 @@
+package ping
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	mrand "math/rand"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/connmgr"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+
+	msmux "github.com/multiformats/go-multistream"
+)
+
+const (
+	defaultKeepAliveIdle        = time.Minute
+	maxConcurrentKeepAlivePings = 16
+)
+
+// defaultSkipTransports are the transports that have their own keep-alive
+// mechanism. Connections using them are never pinged.
+var defaultSkipTransports = []string{"quic", "quic-v1", "webtransport"}
+
+// KeepAliveOption is an option for the KeepAlive service.
+type KeepAliveOption func(*KeepAlive) error
+
+// WithKeepAliveIdle sets the duration a connection has to be idle before it
+// is pinged. Defaults to one minute.
+func WithKeepAliveIdle(d time.Duration) KeepAliveOption {
+	return func(k *KeepAlive) error {
+		if d <= 0 {
+			return errors.New("keep-alive idle duration must be positive")
+		}
+		k.idle = d
+		return nil
+	}
+}
+
+// WithSkipTransports sets the transports (as reported in
+// network.ConnectionState.Transport) whose connections are never pinged.
+// Defaults to QUIC and WebTransport, which send keep-alives themselves.
+func WithSkipTransports(transports ...string) KeepAliveOption {
+	return func(k *KeepAlive) error {
+		k.skipTransports = make(map[string]struct{}, len(transports))
+		for _, t := range transports {
+			k.skipTransports[t] = struct{}{}
+		}
+		return nil
+	}
+}
+
+// WithExemptPeers exempts the given peers from keep-alives.
+// Peers can also be exempted at runtime, see KeepAlive.Exempt.
+func WithExemptPeers(peers ...peer.ID) KeepAliveOption {
+	return func(k *KeepAlive) error {
+		for _, p := range peers {
+			k.exempt[p] = struct{}{}
+		}
+		return nil
+	}
+}
+
+// WithKeepAliveScorer reports failed keep-alives to the given peer scorer,
+// using ServiceName as the source.
+func WithKeepAliveScorer(sc connmgr.PeerScorer) KeepAliveOption {
+	return func(k *KeepAlive) error {
+		k.scorer = sc
+		return nil
+	}
+}
+
+// KeepAlive pings connections that have been idle for a while, so that NATs
+// and load balancers on the path don't drop them. A connection is considered
+// idle when no stream was opened on it for the configured duration, even if
+// older streams are still open.
+// Each ping is sent on the idle connection itself, not just to the peer.
+//
+// Connections using a transport with its own keep-alive mechanism, limited
+// (relayed) connections and connections to exempted peers are never pinged.
+// The remote peers must run the ping protocol.
+type KeepAlive struct {
+	host host.Host
+
+	idle           time.Duration
+	skipTransports map[string]struct{}
+	scorer         connmgr.PeerScorer
+
+	mx         sync.Mutex
+	exempt     map[peer.ID]struct{}
+	lastActive map[network.Conn]time.Time
+
+	ctx       context.Context
+	ctxCancel context.CancelFunc
+	refCount  sync.WaitGroup
+}
+
+// NewKeepAlive creates a new KeepAlive service. Call Start to start sending keep-alives.
+func NewKeepAlive(h host.Host, opts ...KeepAliveOption) (*KeepAlive, error) {
+	k := &KeepAlive{
+		host:       h,
+		idle:       defaultKeepAliveIdle,
+		exempt:     make(map[peer.ID]struct{}),
+		lastActive: make(map[network.Conn]time.Time),
+	}
+	if err := WithSkipTransports(defaultSkipTransports...)(k); err != nil {
+		return nil, err
+	}
+	for _, opt := range opts {
+		if err := opt(k); err != nil {
+			return nil, err
+		}
+	}
+	k.ctx, k.ctxCancel = context.WithCancel(context.Background())
+	return k, nil
+}
+
+// Start starts sending keep-alives.
+func (k *KeepAlive) Start() {
+	k.refCount.Add(1)
+	go k.background()
+}
+
+// Close stops sending keep-alives.
+func (k *KeepAlive) Close() error {
+	k.ctxCancel()
+	k.refCount.Wait()
+	return nil
+}
+
+// Exempt exempts a peer from keep-alives.
+func (k *KeepAlive) Exempt(p peer.ID) {
+	k.mx.Lock()
+	defer k.mx.Unlock()
+	k.exempt[p] = struct{}{}
+}
+
+// Unexempt removes a peer's exemption from keep-alives.
+func (k *KeepAlive) Unexempt(p peer.ID) {
+	k.mx.Lock()
+	defer k.mx.Unlock()
+	delete(k.exempt, p)
+}
+
+func (k *KeepAlive) background() {
+	defer k.refCount.Done()
+
+	// Check a few times per idle period, so that we don't overshoot it by much.
+	ticker := time.NewTicker(k.idle / 4)
+	defer ticker.Stop()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	sem := make(chan struct{}, maxConcurrentKeepAlivePings)
+	for {
+		select {
+		case <-ticker.C:
+		case <-k.ctx.Done():
+			return
+		}
+
+		for _, c := range k.idleConns(time.Now()) {
+			select {
+			case sem <- struct{}{}:
+			default:
+				// Too many keep-alives in flight. We'll try again on the next tick.
+				continue
+			}
+			wg.Add(1)
+			go func(c network.Conn) {
+				defer wg.Done()
+				defer func() { <-sem }()
+				k.ping(c)
+			}(c)
+		}
+	}
+}
+
+// idleConns returns the connections that need a keep-alive, and updates the
+// last activity time of all other connections.
+func (k *KeepAlive) idleConns(now time.Time) []network.Conn {
+	k.mx.Lock()
+	defer k.mx.Unlock()
+
+	conns := k.host.Network().Conns()
+	lastActive := make(map[network.Conn]time.Time, len(conns))
+	var idle []network.Conn
+	for _, c := range conns {
+		if c.IsClosed() || c.Stat().Limited {
+			continue
+		}
+		if _, ok := k.skipTransports[c.ConnState().Transport]; ok {
+			continue
+		}
+		if _, ok := k.exempt[c.RemotePeer()]; ok {
+			continue
+		}
+
+		last, ok := k.lastActive[c]
+		if !ok {
+			last = c.Stat().Opened
+			if last.IsZero() {
+				last = now
+			}
+		}
+		// A long-lived stream doesn't keep the connection active, as it might
+		// not carry any data. Only count when streams were opened.
+		for _, s := range c.GetStreams() {
+			if opened := s.Stat().Opened; opened.After(last) {
+				last = opened
+			}
+		}
+		if now.Sub(last) >= k.idle {
+			idle = append(idle, c)
+			// The keep-alive itself counts as activity.
+			last = now
+		}
+		lastActive[c] = last
+	}
+	k.lastActive = lastActive
+	return idle
+}
+
+// ping sends a single ping on the connection.
+func (k *KeepAlive) ping(c network.Conn) {
+	ctx, cancel := context.WithTimeout(k.ctx, pingTimeout)
+	defer cancel()
+
+	rtt, err := k.pingConn(ctx, c)
+	if err != nil {
+		if k.ctx.Err() == nil {
+			log.Debugw("keep-alive failed", "peer", c.RemotePeer(), "addr", c.RemoteMultiaddr(), "error", err)
+			if k.scorer != nil {
+				k.scorer.Report(c.RemotePeer(), ServiceName, -1)
+			}
+		}
+		return
+	}
+	k.host.Peerstore().RecordLatency(c.RemotePeer(), rtt)
+}
+
+func (k *KeepAlive) pingConn(ctx context.Context, c network.Conn) (time.Duration, error) {
+	s, err := c.NewStream(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer s.Close()
+	stop := context.AfterFunc(ctx, func() { s.Reset() })
+	defer stop()
+
+	if err := s.SetProtocol(ID); err != nil {
+		s.Reset()
+		return 0, err
+	}
+	if err := s.Scope().SetService(ServiceName); err != nil {
+		s.Reset()
+		return 0, err
+	}
+	if err := msmux.SelectProtoOrFail(ID, s); err != nil {
+		s.Reset()
+		return 0, err
+	}
+
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		s.Reset()
+		return 0, err
+	}
+	rtt, err := ping(s, mrand.New(mrand.NewSource(int64(binary.BigEndian.Uint64(b)))), PingSize)
+	if err != nil {
+		s.Reset()
+		return 0, err
+	}
+	return rtt, nil
+}
@@ -0,0 +1,87 @@
+package ping_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	bhost "github.com/libp2p/go-libp2p/p2p/host/basic"
+	swarmt "github.com/libp2p/go-libp2p/p2p/net/swarm/testing"
+	"github.com/libp2p/go-libp2p/p2p/protocol/ping"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newKeepAliveHosts(t *testing.T, n int, opts ...swarmt.Option) []*bhost.BasicHost {
+	t.Helper()
+	hosts := make([]*bhost.BasicHost, 0, n)
+	for i := 0; i < n; i++ {
+		h, err := bhost.NewHost(swarmt.GenSwarm(t, opts...), nil)
+		require.NoError(t, err)
+		t.Cleanup(func() { h.Close() })
+		h.Start()
+		ping.NewPingService(h)
+		hosts = append(hosts, h)
+	}
+	for _, h := range hosts[1:] {
+		require.NoError(t, hosts[0].Connect(context.Background(), peer.AddrInfo{ID: h.ID(), Addrs: h.Addrs()}))
+	}
+	return hosts
+}
+
+func TestKeepAlive(t *testing.T) {
+	hosts := newKeepAliveHosts(t, 3, swarmt.OptDisableQUIC)
+	h1, h2, h3 := hosts[0], hosts[1], hosts[2]
+
+	k, err := ping.NewKeepAlive(h1,
+		ping.WithKeepAliveIdle(100*time.Millisecond),
+		ping.WithExemptPeers(h3.ID()),
+	)
+	require.NoError(t, err)
+	k.Start()
+	defer k.Close()
+
+	require.Eventually(t, func() bool { return h1.Peerstore().LatencyEWMA(h2.ID()) > 0 }, 5*time.Second, 10*time.Millisecond)
+	require.Never(t, func() bool { return h1.Peerstore().LatencyEWMA(h3.ID()) > 0 }, 500*time.Millisecond, 50*time.Millisecond)
+
+	k.Unexempt(h3.ID())
+	require.Eventually(t, func() bool { return h1.Peerstore().LatencyEWMA(h3.ID()) > 0 }, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestKeepAliveSkipsQUIC(t *testing.T) {
+	hosts := newKeepAliveHosts(t, 2, swarmt.OptDisableTCP)
+	h1, h2 := hosts[0], hosts[1]
+
+	k, err := ping.NewKeepAlive(h1, ping.WithKeepAliveIdle(100*time.Millisecond))
+	require.NoError(t, err)
+	k.Start()
+	defer k.Close()
+	require.Never(t, func() bool { return h1.Peerstore().LatencyEWMA(h2.ID()) > 0 }, 500*time.Millisecond, 50*time.Millisecond)
+
+	// QUIC connections are pinged if we don't skip them explicitly
+	k2, err := ping.NewKeepAlive(h1, ping.WithKeepAliveIdle(100*time.Millisecond), ping.WithSkipTransports())
+	require.NoError(t, err)
+	k2.Start()
+	defer k2.Close()
+	require.Eventually(t, func() bool { return h1.Peerstore().LatencyEWMA(h2.ID()) > 0 }, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestKeepAliveLongLivedStream(t *testing.T) {
+	hosts := newKeepAliveHosts(t, 2, swarmt.OptDisableQUIC)
+	h1, h2 := hosts[0], hosts[1]
+
+	// a stream that stays open, but doesn't carry any data
+	h2.SetStreamHandler("/test", func(s network.Stream) {})
+	s, err := h1.NewStream(context.Background(), h2.ID(), "/test")
+	require.NoError(t, err)
+	defer s.Close()
+
+	k, err := ping.NewKeepAlive(h1, ping.WithKeepAliveIdle(100*time.Millisecond))
+	require.NoError(t, err)
+	k.Start()
+	defer k.Close()
+
+	require.Eventually(t, func() bool { return h1.Peerstore().LatencyEWMA(h2.ID()) > 0 }, 5*time.Second, 10*time.Millisecond)
+}
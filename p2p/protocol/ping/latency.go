@@ -0,0 +1,333 @@
+package ping
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/connmgr"
+	"github.com/libp2p/go-libp2p/core/event"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+const (
+	defaultMonitorInterval    = time.Minute
+	defaultChangeThreshold    = 0.25
+	defaultLatencyWindow      = 32
+	maxConcurrentMonitorPings = 16
+)
+
+// LatencyStats holds the latency statistics of a peer, as measured by the
+// LatencyMonitor.
+type LatencyStats struct {
+	// EWMA is the exponentially weighted moving average of the RTT, as
+	// recorded in the peerstore.
+	EWMA time.Duration
+	// P50, P90 and P99 are percentiles of the RTT over the most recent
+	// measurements (see WithLatencyWindow).
+	P50, P90, P99 time.Duration
+	// Samples is the number of measurements the percentiles were computed from.
+	Samples int
+	// LastMeasured is the time of the last successful measurement.
+	LastMeasured time.Time
+}
+
+type peerLatency struct {
+	// samples is a ring buffer of the most recent RTTs.
+	samples      []time.Duration
+	next         int
+	lastMeasured time.Time
+	// reported is the EWMA last reported in an EvtPeerLatencyChanged event.
+	reported time.Duration
+}
+
+func (l *peerLatency) add(rtt time.Duration, window int, now time.Time) {
+	if len(l.samples) < window {
+		l.samples = append(l.samples, rtt)
+	} else {
+		l.samples[l.next] = rtt
+		l.next = (l.next + 1) % window
+	}
+	l.lastMeasured = now
+}
+
+func (l *peerLatency) stats() LatencyStats {
+	sorted := make([]time.Duration, len(l.samples))
+	copy(sorted, l.samples)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return LatencyStats{
+		P50:          percentile(sorted, 0.5),
+		P90:          percentile(sorted, 0.9),
+		P99:          percentile(sorted, 0.99),
+		Samples:      len(sorted),
+		LastMeasured: l.lastMeasured,
+	}
+}
+
+// percentile returns the q-th percentile of the sorted RTTs, using the nearest-rank method.
+func percentile(sorted []time.Duration, q float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	idx := int(q*float64(len(sorted))+0.5) - 1
+	if idx < 0 {
+		idx = 0
+	}
+	if idx >= len(sorted) {
+		idx = len(sorted) - 1
+	}
+	return sorted[idx]
+}
+
+// LatencyMonitorOption is an option for the LatencyMonitor.
+type LatencyMonitorOption func(*LatencyMonitor) error
+
+// WithMonitorInterval sets the interval at which peers are pinged. Defaults to one minute.
+func WithMonitorInterval(d time.Duration) LatencyMonitorOption {
+	return func(m *LatencyMonitor) error {
+		if d <= 0 {
+			return errors.New("monitor interval must be positive")
+		}
+		m.interval = d
+		return nil
+	}
+}
+
+// WithMonitoredPeers restricts the monitor to the given set of peers. Peers we
+// aren't connected to are skipped, the monitor never opens new connections.
+// By default, all connected peers are monitored.
+func WithMonitoredPeers(peers ...peer.ID) LatencyMonitorOption {
+	return func(m *LatencyMonitor) error {
+		m.peers = make(map[peer.ID]struct{}, len(peers))
+		for _, p := range peers {
+			m.peers[p] = struct{}{}
+		}
+		return nil
+	}
+}
+
+// WithChangeThreshold sets the relative change of the EWMA latency of a peer
+// above which an EvtPeerLatencyChanged event is emitted. Defaults to 0.25, i.e.
+// an event is emitted when the latency changed by more than 25%.
+func WithChangeThreshold(t float64) LatencyMonitorOption {
+	return func(m *LatencyMonitor) error {
+		if t <= 0 {
+			return errors.New("change threshold must be positive")
+		}
+		m.threshold = t
+		return nil
+	}
+}
+
+// WithLatencyWindow sets the number of recent measurements per peer that the
+// percentiles are computed from. Defaults to 32.
+func WithLatencyWindow(n int) LatencyMonitorOption {
+	return func(m *LatencyMonitor) error {
+		if n <= 0 {
+			return errors.New("latency window must be positive")
+		}
+		m.window = n
+		return nil
+	}
+}
+
+// WithMonitorScorer reports failed measurements to the given peer scorer,
+// using ServiceName as the source.
+func WithMonitorScorer(sc connmgr.PeerScorer) LatencyMonitorOption {
+	return func(m *LatencyMonitor) error {
+		m.scorer = sc
+		return nil
+	}
+}
+
+// LatencyMonitor periodically pings peers and keeps track of the round trip
+// time to them. Measurements are recorded in the peerstore, so they are
+// available to every component using the peerstore metrics, e.g. the
+// connection manager. In addition, the monitor keeps the percentiles of the
+// recent measurements (see Stats), and emits an EvtPeerLatencyChanged event
+// when the latency to a peer changes significantly.
+type LatencyMonitor struct {
+	host host.Host
+
+	interval  time.Duration
+	threshold float64
+	window    int
+	peers     map[peer.ID]struct{}
+	scorer    connmgr.PeerScorer
+
+	emitter event.Emitter
+
+	mx      sync.Mutex
+	latency map[peer.ID]*peerLatency
+
+	ctx       context.Context
+	ctxCancel context.CancelFunc
+	refCount  sync.WaitGroup
+}
+
+// NewLatencyMonitor creates a new LatencyMonitor. Call Start to start monitoring.
+// The remote peers must run the ping protocol.
+func NewLatencyMonitor(h host.Host, opts ...LatencyMonitorOption) (*LatencyMonitor, error) {
+	m := &LatencyMonitor{
+		host:      h,
+		interval:  defaultMonitorInterval,
+		threshold: defaultChangeThreshold,
+		window:    defaultLatencyWindow,
+		latency:   make(map[peer.ID]*peerLatency),
+	}
+	for _, opt := range opts {
+		if err := opt(m); err != nil {
+			return nil, err
+		}
+	}
+
+	emitter, err := h.EventBus().Emitter(new(event.EvtPeerLatencyChanged))
+	if err != nil {
+		return nil, err
+	}
+	m.emitter = emitter
+	m.ctx, m.ctxCancel = context.WithCancel(context.Background())
+	return m, nil
+}
+
+// Start starts monitoring.
+func (m *LatencyMonitor) Start() {
+	m.refCount.Add(1)
+	go m.background()
+}
+
+// Close stops monitoring.
+func (m *LatencyMonitor) Close() error {
+	m.ctxCancel()
+	m.refCount.Wait()
+	return m.emitter.Close()
+}
+
+// Stats returns the latency statistics of a peer. It returns false if the
+// latency to the peer wasn't measured yet.
+func (m *LatencyMonitor) Stats(p peer.ID) (LatencyStats, bool) {
+	m.mx.Lock()
+	l, ok := m.latency[p]
+	if !ok {
+		m.mx.Unlock()
+		return LatencyStats{}, false
+	}
+	stats := l.stats()
+	m.mx.Unlock()
+
+	stats.EWMA = m.host.Peerstore().LatencyEWMA(p)
+	return stats, true
+}
+
+func (m *LatencyMonitor) background() {
+	defer m.refCount.Done()
+
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		m.measure()
+		select {
+		case <-ticker.C:
+		case <-m.ctx.Done():
+			return
+		}
+	}
+}
+
+// measure pings all monitored peers once.
+func (m *LatencyMonitor) measure() {
+	var peers []peer.ID
+	for _, p := range m.host.Network().Peers() {
+		if m.peers != nil {
+			if _, ok := m.peers[p]; !ok {
+				continue
+			}
+		}
+		peers = append(peers, p)
+	}
+	m.forget(peers)
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, maxConcurrentMonitorPings)
+	for _, p := range peers {
+		select {
+		case sem <- struct{}{}:
+		case <-m.ctx.Done():
+			wg.Wait()
+			return
+		}
+		wg.Add(1)
+		go func(p peer.ID) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			m.measurePeer(p)
+		}(p)
+	}
+	wg.Wait()
+}
+
+func (m *LatencyMonitor) measurePeer(p peer.ID) {
+	ctx, cancel := context.WithTimeout(m.ctx, pingTimeout)
+	defer cancel()
+
+	res := <-Ping(network.WithNoDial(ctx, "latency monitor"), m.host, p)
+	if res.Error != nil {
+		if m.ctx.Err() == nil {
+			log.Debugw("failed to measure latency", "peer", p, "error", res.Error)
+			if m.scorer != nil {
+				m.scorer.Report(p, ServiceName, -1)
+			}
+		}
+		return
+	}
+	// Ping already recorded the RTT in the peerstore.
+	ewma := m.host.Peerstore().LatencyEWMA(p)
+
+	m.mx.Lock()
+	l, ok := m.latency[p]
+	if !ok {
+		l = &peerLatency{reported: ewma}
+		m.latency[p] = l
+	}
+	l.add(res.RTT, m.window, time.Now())
+	prev := l.reported
+	changed := prev > 0 && float64(absDuration(ewma-prev)) > m.threshold*float64(prev)
+	if changed {
+		l.reported = ewma
+	}
+	m.mx.Unlock()
+
+	if changed {
+		if err := m.emitter.Emit(event.EvtPeerLatencyChanged{Peer: p, Previous: prev, Current: ewma}); err != nil {
+			log.Debugw("failed to emit latency event", "error", err)
+		}
+	}
+}
+
+// forget drops the statistics of all peers that are not monitored anymore.
+func (m *LatencyMonitor) forget(current []peer.ID) {
+	keep := make(map[peer.ID]struct{}, len(current))
+	for _, p := range current {
+		keep[p] = struct{}{}
+	}
+
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	for p := range m.latency {
+		if _, ok := keep[p]; !ok {
+			delete(m.latency, p)
+		}
+	}
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
@@ -0,0 +1,72 @@
+package ping_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	bhost "github.com/libp2p/go-libp2p/p2p/host/basic"
+	swarmt "github.com/libp2p/go-libp2p/p2p/net/swarm/testing"
+	"github.com/libp2p/go-libp2p/p2p/protocol/ping"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLatencyMonitor(t *testing.T) {
+	h1, err := bhost.NewHost(swarmt.GenSwarm(t), nil)
+	require.NoError(t, err)
+	defer h1.Close()
+	h1.Start()
+	h2, err := bhost.NewHost(swarmt.GenSwarm(t), nil)
+	require.NoError(t, err)
+	defer h2.Close()
+	h2.Start()
+	h3, err := bhost.NewHost(swarmt.GenSwarm(t), nil)
+	require.NoError(t, err)
+	defer h3.Close()
+	h3.Start()
+
+	ping.NewPingService(h2)
+	ping.NewPingService(h3)
+
+	for _, h := range []*bhost.BasicHost{h2, h3} {
+		require.NoError(t, h1.Connect(context.Background(), peer.AddrInfo{ID: h.ID(), Addrs: h.Addrs()}))
+	}
+
+	m, err := ping.NewLatencyMonitor(h1,
+		ping.WithMonitorInterval(50*time.Millisecond),
+		ping.WithMonitoredPeers(h2.ID()),
+	)
+	require.NoError(t, err)
+	m.Start()
+	defer m.Close()
+
+	require.Eventually(t, func() bool {
+		stats, ok := m.Stats(h2.ID())
+		return ok && stats.Samples >= 3
+	}, 5*time.Second, 50*time.Millisecond)
+
+	stats, _ := m.Stats(h2.ID())
+	require.NotZero(t, stats.EWMA)
+	require.NotZero(t, stats.P50)
+	require.LessOrEqual(t, stats.P50, stats.P90)
+	require.LessOrEqual(t, stats.P90, stats.P99)
+
+	// h3 isn't monitored.
+	_, ok := m.Stats(h3.ID())
+	require.False(t, ok)
+}
+
+func TestLatencyMonitorOptions(t *testing.T) {
+	h, err := bhost.NewHost(swarmt.GenSwarm(t), nil)
+	require.NoError(t, err)
+	defer h.Close()
+
+	_, err = ping.NewLatencyMonitor(h, ping.WithMonitorInterval(0))
+	require.Error(t, err)
+	_, err = ping.NewLatencyMonitor(h, ping.WithChangeThreshold(-1))
+	require.Error(t, err)
+	_, err = ping.NewLatencyMonitor(h, ping.WithLatencyWindow(0))
+	require.Error(t, err)
+}
@@ -6,8 +6,10 @@ import (
 	"crypto/rand"
 	"encoding/binary"
 	"errors"
+	"fmt"
 	"io"
 	mrand "math/rand"
+	"sync"
 	"time"
 
 	logging "github.com/ipfs/go-log/v2"
@@ -31,11 +33,24 @@ const (
 
 type PingService struct {
 	Host host.Host
+
+	statsMx sync.Mutex
+	stats   map[peer.ID]*Stats
 }
 
 func NewPingService(h host.Host) *PingService {
-	ps := &PingService{h}
+	ps := &PingService{Host: h, stats: make(map[peer.ID]*Stats)}
 	h.SetStreamHandler(ID, ps.PingHandler)
+	h.Network().Notify(&network.NotifyBundle{
+		DisconnectedF: func(n network.Network, c network.Conn) {
+			p := c.RemotePeer()
+			if n.Connectedness(p) != network.Connected {
+				ps.statsMx.Lock()
+				delete(ps.stats, p)
+				ps.statsMx.Unlock()
+			}
+		},
+	})
 	return ps
 }
 
@@ -100,8 +115,113 @@ type Result struct {
 	Error error
 }
 
-func (ps *PingService) Ping(ctx context.Context, p peer.ID) <-chan Result {
-	return Ping(ctx, ps.Host, p)
+// Stats are the statistics of the pings sent to a peer.
+type Stats struct {
+	// Sent is the number of pings sent.
+	Sent int
+	// Received is the number of pings that were answered.
+	Received int
+	// Min, Avg and Max are the minimum, average and maximum RTT of the answered pings.
+	Min, Avg, Max time.Duration
+}
+
+// Loss returns the fraction of the pings that weren't answered.
+func (s Stats) Loss() float64 {
+	if s.Sent == 0 {
+		return 0
+	}
+	return float64(s.Sent-s.Received) / float64(s.Sent)
+}
+
+func (s *Stats) add(res Result) {
+	s.Sent++
+	if res.Error != nil {
+		return
+	}
+	if s.Received == 0 || res.RTT < s.Min {
+		s.Min = res.RTT
+	}
+	if res.RTT > s.Max {
+		s.Max = res.RTT
+	}
+	s.Avg = (s.Avg*time.Duration(s.Received) + res.RTT) / time.Duration(s.Received+1)
+	s.Received++
+}
+
+type config struct {
+	payloadSize int
+	interval    time.Duration
+}
+
+// Option is an option for a ping.
+type Option func(*config) error
+
+// WithPayloadSize sets the size of the payload of each ping, e.g. to probe
+// the path MTU. Since the ping protocol echoes data in chunks of PingSize
+// bytes, the size must be a multiple of PingSize. Defaults to PingSize.
+func WithPayloadSize(size int) Option {
+	return func(cfg *config) error {
+		if size <= 0 || size%PingSize != 0 {
+			return fmt.Errorf("payload size must be a positive multiple of %d", PingSize)
+		}
+		cfg.payloadSize = size
+		return nil
+	}
+}
+
+// WithInterval sets the interval between the start of two consecutive pings.
+// By default, the next ping is sent as soon as the previous one was answered.
+func WithInterval(d time.Duration) Option {
+	return func(cfg *config) error {
+		if d < 0 {
+			return errors.New("interval must be non-negative")
+		}
+		cfg.interval = d
+		return nil
+	}
+}
+
+// Ping pings the remote peer until the context is canceled, returning a stream
+// of RTTs or errors. All pings are sent on a single stream. The results are
+// also accounted for in the statistics of the peer, see Stats.
+func (ps *PingService) Ping(ctx context.Context, p peer.ID, opts ...Option) <-chan Result {
+	results := Ping(ctx, ps.Host, p, opts...)
+	out := make(chan Result)
+	go func() {
+		defer close(out)
+		for res := range results {
+			ps.statsMx.Lock()
+			st, ok := ps.stats[p]
+			if !ok {
+				st = &Stats{}
+				ps.stats[p] = st
+			}
+			st.add(res)
+			ps.statsMx.Unlock()
+
+			select {
+			case out <- res:
+			case <-ctx.Done():
+				// drain, so that Ping can clean up.
+				for range results {
+				}
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// Stats returns the statistics of the pings sent to a peer using this service.
+// Statistics are dropped when we disconnect from the peer.
+func (ps *PingService) Stats(p peer.ID) (Stats, bool) {
+	ps.statsMx.Lock()
+	defer ps.statsMx.Unlock()
+	st, ok := ps.stats[p]
+	if !ok {
+		return Stats{}, false
+	}
+	return *st, true
 }
 
 func pingError(err error) chan Result {
@@ -113,7 +233,14 @@ func pingError(err error) chan Result {
 
 // Ping pings the remote peer until the context is canceled, returning a stream
 // of RTTs or errors.
-func Ping(ctx context.Context, h host.Host, p peer.ID) <-chan Result {
+func Ping(ctx context.Context, h host.Host, p peer.ID, opts ...Option) <-chan Result {
+	cfg := &config{payloadSize: PingSize}
+	for _, opt := range opts {
+		if err := opt(cfg); err != nil {
+			return pingError(err)
+		}
+	}
+
 	s, err := h.NewStream(network.WithAllowLimitedConn(ctx, "ping"), p, ID)
 	if err != nil {
 		return pingError(err)
@@ -140,9 +267,19 @@ func Ping(ctx context.Context, h host.Host, p peer.ID) <-chan Result {
 		defer close(out)
 		defer cancel()
 
+		var timer *time.Timer
+		if cfg.interval > 0 {
+			timer = time.NewTimer(cfg.interval)
+			defer timer.Stop()
+		}
+
 		for ctx.Err() == nil {
+			if timer != nil {
+				timer.Reset(cfg.interval)
+			}
+
 			var res Result
-			res.RTT, res.Error = ping(s, ra)
+			res.RTT, res.Error = ping(s, ra, cfg.payloadSize)
 
 			// canceled, ignore everything.
 			if ctx.Err() != nil {
@@ -159,6 +296,14 @@ func Ping(ctx context.Context, h host.Host, p peer.ID) <-chan Result {
 			case <-ctx.Done():
 				return
 			}
+
+			if timer != nil {
+				select {
+				case <-timer.C:
+				case <-ctx.Done():
+					return
+				}
+			}
 		}
 	}()
 	context.AfterFunc(ctx, func() {
@@ -169,15 +314,15 @@ func Ping(ctx context.Context, h host.Host, p peer.ID) <-chan Result {
 	return out
 }
 
-func ping(s network.Stream, randReader io.Reader) (time.Duration, error) {
-	if err := s.Scope().ReserveMemory(2*PingSize, network.ReservationPriorityAlways); err != nil {
+func ping(s network.Stream, randReader io.Reader, size int) (time.Duration, error) {
+	if err := s.Scope().ReserveMemory(2*size, network.ReservationPriorityAlways); err != nil {
 		log.Debugf("error reserving memory for ping stream: %s", err)
 		s.Reset()
 		return 0, err
 	}
-	defer s.Scope().ReleaseMemory(2 * PingSize)
+	defer s.Scope().ReleaseMemory(2 * size)
 
-	buf := pool.Get(PingSize)
+	buf := pool.Get(size)
 	defer pool.Put(buf)
 
 	if _, err := io.ReadFull(randReader, buf); err != nil {
@@ -189,7 +334,7 @@ func ping(s network.Stream, randReader io.Reader) (time.Duration, error) {
 		return 0, err
 	}
 
-	rbuf := pool.Get(PingSize)
+	rbuf := pool.Get(size)
 	defer pool.Put(rbuf)
 
 	if _, err := io.ReadFull(s, rbuf); err != nil {
@@ -55,3 +55,52 @@ func testPing(t *testing.T, ps *ping.PingService, p peer.ID) {
 	}
 
 }
+
+func TestPingOptions(t *testing.T) {
+	h1, err := bhost.NewHost(swarmt.GenSwarm(t), nil)
+	require.NoError(t, err)
+	defer h1.Close()
+	h1.Start()
+	h2, err := bhost.NewHost(swarmt.GenSwarm(t), nil)
+	require.NoError(t, err)
+	defer h2.Close()
+	h2.Start()
+
+	require.NoError(t, h1.Connect(context.Background(), peer.AddrInfo{ID: h2.ID(), Addrs: h2.Addrs()}))
+
+	ps1 := ping.NewPingService(h1)
+	ping.NewPingService(h2)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	const interval = 100 * time.Millisecond
+	ts := ps1.Ping(ctx, h2.ID(), ping.WithPayloadSize(32*ping.PingSize), ping.WithInterval(interval))
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		select {
+		case res := <-ts:
+			require.NoError(t, res.Error)
+		case <-time.After(4 * time.Second):
+			t.Fatal("failed to receive ping")
+		}
+	}
+	require.GreaterOrEqual(t, time.Since(start), 2*interval)
+
+	stats, ok := ps1.Stats(h2.ID())
+	require.True(t, ok)
+	require.GreaterOrEqual(t, stats.Received, 3)
+	require.NotZero(t, stats.Min)
+	require.LessOrEqual(t, stats.Min, stats.Avg)
+	require.LessOrEqual(t, stats.Avg, stats.Max)
+	require.Zero(t, stats.Loss())
+}
+
+func TestPingInvalidPayloadSize(t *testing.T) {
+	h, err := bhost.NewHost(swarmt.GenSwarm(t), nil)
+	require.NoError(t, err)
+	defer h.Close()
+
+	res := <-ping.Ping(context.Background(), h, h.ID(), ping.WithPayloadSize(ping.PingSize+1))
+	require.Error(t, res.Error)
+}
@@ -0,0 +1,187 @@
+package rendezvous
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/peerstore"
+	"github.com/libp2p/go-libp2p/core/record"
+	"github.com/libp2p/go-libp2p/p2p/protocol/rendezvous/pb"
+
+	"github.com/libp2p/go-msgio/pbio"
+)
+
+// RendezvousError is returned when the rendezvous point rejects a request.
+type RendezvousError struct {
+	Status pb.Message_ResponseStatus
+	Text   string
+}
+
+func (e *RendezvousError) Error() string {
+	return fmt.Sprintf("rendezvous error: %s (%s)", e.Status, e.Text)
+}
+
+// Client talks to a single rendezvous point.
+type Client struct {
+	host   host.Host
+	server peer.ID
+}
+
+// NewClient creates a new Client for the rendezvous point server.
+// The host needs to know the addresses of server, or be connected to it.
+func NewClient(h host.Host, server peer.ID) *Client {
+	return &Client{host: h, server: server}
+}
+
+// roundTrip sends req and reads a response of up to maxRespSize bytes.
+// If maxRespSize is 0, no response is expected.
+func (c *Client) roundTrip(ctx context.Context, req *pb.Message, maxRespSize int) (*pb.Message, error) {
+	s, err := c.host.NewStream(ctx, c.server, ProtocolID)
+	if err != nil {
+		return nil, err
+	}
+	defer s.Close()
+
+	if err := s.Scope().SetService(ServiceName); err != nil {
+		s.Reset()
+		return nil, fmt.Errorf("error attaching stream to rendezvous service: %w", err)
+	}
+	if maxRespSize > 0 {
+		if err := s.Scope().ReserveMemory(maxRespSize, network.ReservationPriorityAlways); err != nil {
+			s.Reset()
+			return nil, fmt.Errorf("error reserving memory for stream: %w", err)
+		}
+		defer s.Scope().ReleaseMemory(maxRespSize)
+	}
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(streamTimeout)
+	}
+	s.SetDeadline(deadline)
+
+	if err := pbio.NewDelimitedWriter(s).WriteMsg(req); err != nil {
+		s.Reset()
+		return nil, err
+	}
+	if maxRespSize == 0 {
+		return nil, nil
+	}
+	var resp pb.Message
+	if err := pbio.NewDelimitedReader(s, maxRespSize).ReadMsg(&resp); err != nil {
+		s.Reset()
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Register registers us at the rendezvous point under ns.
+// A ttl of 0 lets the rendezvous point pick the TTL.
+// It returns the TTL the rendezvous point accepted.
+func (c *Client) Register(ctx context.Context, ns string, ttl time.Duration) (time.Duration, error) {
+	if ns == "" || len(ns) > MaxNamespaceLength {
+		return 0, errors.New("invalid namespace")
+	}
+	privKey := c.host.Peerstore().PrivKey(c.host.ID())
+	if privKey == nil {
+		return 0, errors.New("missing private key")
+	}
+	rec := peer.PeerRecordFromAddrInfo(peer.AddrInfo{ID: c.host.ID(), Addrs: c.host.Addrs()})
+	env, err := record.Seal(rec, privKey)
+	if err != nil {
+		return 0, err
+	}
+	envBytes, err := env.Marshal()
+	if err != nil {
+		return 0, err
+	}
+
+	reg := &pb.Message_Register{
+		Ns:               &ns,
+		SignedPeerRecord: envBytes,
+	}
+	if ttl > 0 {
+		t := uint64(ttl / time.Second)
+		reg.Ttl = &t
+	}
+	resp, err := c.roundTrip(ctx, &pb.Message{Type: pb.Message_REGISTER.Enum(), Register: reg}, maxRegisterResponseSize)
+	if err != nil {
+		return 0, err
+	}
+	if resp.GetType() != pb.Message_REGISTER_RESPONSE {
+		return 0, fmt.Errorf("unexpected response: %s", resp.GetType())
+	}
+	r := resp.GetRegisterResponse()
+	if r.GetStatus() != pb.Message_OK {
+		return 0, &RendezvousError{Status: r.GetStatus(), Text: r.GetStatusText()}
+	}
+	return time.Duration(r.GetTtl()) * time.Second, nil
+}
+
+// Unregister removes our registration under ns.
+func (c *Client) Unregister(ctx context.Context, ns string) error {
+	_, err := c.roundTrip(ctx, &pb.Message{
+		Type:       pb.Message_UNREGISTER.Enum(),
+		Unregister: &pb.Message_Unregister{Ns: &ns},
+	}, 0)
+	return err
+}
+
+// Discover asks the rendezvous point for peers registered under ns.
+// An empty ns discovers peers in all namespaces. A limit of 0 returns as many
+// registrations as the rendezvous point allows.
+// The returned cookie can be passed to a subsequent call to only receive
+// registrations that weren't returned yet.
+// The addresses of discovered peers are added to the peerstore.
+func (c *Client) Discover(ctx context.Context, ns string, limit int, cookie []byte) ([]peer.AddrInfo, []byte, error) {
+	disc := &pb.Message_Discover{Ns: &ns, Cookie: cookie}
+	if limit > 0 {
+		l := uint64(limit)
+		disc.Limit = &l
+	}
+	resp, err := c.roundTrip(ctx, &pb.Message{Type: pb.Message_DISCOVER.Enum(), Discover: disc}, maxResponseSize)
+	if err != nil {
+		return nil, nil, err
+	}
+	if resp.GetType() != pb.Message_DISCOVER_RESPONSE {
+		return nil, nil, fmt.Errorf("unexpected response: %s", resp.GetType())
+	}
+	r := resp.GetDiscoverResponse()
+	if r.GetStatus() != pb.Message_OK {
+		return nil, nil, &RendezvousError{Status: r.GetStatus(), Text: r.GetStatusText()}
+	}
+
+	cab, hasCAB := peerstore.GetCertifiedAddrBook(c.host.Peerstore())
+	peers := make([]peer.AddrInfo, 0, len(r.GetRegistrations()))
+	for _, reg := range r.GetRegistrations() {
+		env, rec, err := record.ConsumeEnvelope(reg.GetSignedPeerRecord(), peer.PeerRecordEnvelopeDomain)
+		if err != nil {
+			log.Debugw("invalid signed peer record", "ns", reg.GetNs(), "error", err)
+			continue
+		}
+		pr, ok := rec.(*peer.PeerRecord)
+		if !ok {
+			continue
+		}
+		ttl := time.Duration(reg.GetTtl()) * time.Second
+		if ttl <= 0 {
+			ttl = peerstore.TempAddrTTL
+		}
+		if pr.PeerID != c.host.ID() {
+			if hasCAB {
+				if _, err := cab.ConsumePeerRecord(env, ttl); err != nil {
+					log.Debugw("failed to consume peer record", "peer", pr.PeerID, "error", err)
+				}
+			} else {
+				c.host.Peerstore().AddAddrs(pr.PeerID, pr.Addrs, ttl)
+			}
+		}
+		peers = append(peers, peer.AddrInfo{ID: pr.PeerID, Addrs: pr.Addrs})
+	}
+	return peers, r.GetCookie(), nil
+}
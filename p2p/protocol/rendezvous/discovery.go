@@ -0,0 +1,72 @@
+package rendezvous
+
+import (
+	"context"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/discovery"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// RendezvousDiscovery is an implementation of discovery.Discovery using a rendezvous point.
+type RendezvousDiscovery struct {
+	c *Client
+}
+
+var _ discovery.Discovery = &RendezvousDiscovery{}
+
+func NewRendezvousDiscovery(c *Client) *RendezvousDiscovery {
+	return &RendezvousDiscovery{c: c}
+}
+
+func (d *RendezvousDiscovery) Advertise(ctx context.Context, ns string, opts ...discovery.Option) (time.Duration, error) {
+	var options discovery.Options
+	if err := options.Apply(opts...); err != nil {
+		return 0, err
+	}
+
+	ttl := options.Ttl
+	if ttl == 0 {
+		ttl = DefaultTTL
+	}
+	return d.c.Register(ctx, ns, ttl)
+}
+
+func (d *RendezvousDiscovery) FindPeers(ctx context.Context, ns string, opts ...discovery.Option) (<-chan peer.AddrInfo, error) {
+	var options discovery.Options
+	if err := options.Apply(opts...); err != nil {
+		return nil, err
+	}
+
+	// Page through the registrations until we either reach the limit or the
+	// rendezvous point doesn't return any new registrations.
+	var peers []peer.AddrInfo
+	var cookie []byte
+	for options.Limit == 0 || len(peers) < options.Limit {
+		limit := 0
+		if options.Limit > 0 {
+			limit = options.Limit - len(peers)
+		}
+		page, c, err := d.c.Discover(ctx, ns, limit, cookie)
+		if err != nil {
+			return nil, err
+		}
+		cookie = c
+		if len(page) == 0 {
+			break
+		}
+		for _, pi := range page {
+			if pi.ID == d.c.host.ID() {
+				continue
+			}
+			peers = append(peers, pi)
+		}
+	}
+
+	ch := make(chan peer.AddrInfo, len(peers))
+	for _, pi := range peers {
+		ch <- pi
+	}
+	close(ch)
+	return ch, nil
+}
@@ -0,0 +1,46 @@
+// Package rendezvous implements the libp2p rendezvous protocol, as specified in
+// https://github.com/libp2p/specs/tree/master/rendezvous.
+//
+// Peers register with a rendezvous point under a namespace, and other peers
+// discover them by querying the rendezvous point for that namespace.
+package rendezvous
+
+import (
+	"time"
+
+	logging "github.com/ipfs/go-log/v2"
+)
+
+//go:generate protoc --proto_path=$PWD:$PWD/../../.. --go_out=. --go_opt=Mpb/rendezvous.proto=./pb pb/rendezvous.proto
+
+var log = logging.Logger("rendezvous")
+
+const (
+	// ProtocolID is the protocol ID of the rendezvous protocol.
+	ProtocolID = "/rendezvous/1.0.0"
+	// ServiceName is the name of the rendezvous service for the resource manager.
+	ServiceName = "libp2p.rendezvous"
+
+	// DefaultTTL is the TTL of a registration if the registering peer doesn't specify one.
+	DefaultTTL = 2 * time.Hour
+	// MinTTL is the minimum TTL of a registration.
+	MinTTL = 2 * time.Minute
+	// MaxTTL is the maximum TTL of a registration.
+	MaxTTL = 72 * time.Hour
+	// MaxNamespaceLength is the maximum length of a namespace.
+	MaxNamespaceLength = 255
+	// MaxPeerRecordSize is the maximum size of a signed peer record.
+	MaxPeerRecordSize = 2048
+	// MaxDiscoverLimit is the maximum number of registrations returned in a single response.
+	MaxDiscoverLimit = 1000
+
+	// maxRequestSize is the maximum size of a request, i.e. a REGISTER,
+	// UNREGISTER or DISCOVER message.
+	maxRequestSize = 4 * 1024
+	// maxRegisterResponseSize is the maximum size of a REGISTER_RESPONSE.
+	maxRegisterResponseSize = 4 * 1024
+	// maxResponseSize is the maximum size of a DISCOVER_RESPONSE. It is large
+	// enough to hold MaxDiscoverLimit registrations.
+	maxResponseSize = MaxDiscoverLimit * (MaxPeerRecordSize + MaxNamespaceLength + 32)
+	streamTimeout   = time.Minute
+)
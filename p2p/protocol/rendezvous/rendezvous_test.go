@@ -0,0 +1,224 @@
+package rendezvous
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/discovery"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/peerstore"
+	basic "github.com/libp2p/go-libp2p/p2p/host/basic"
+	swarmt "github.com/libp2p/go-libp2p/p2p/net/swarm/testing"
+	"github.com/libp2p/go-libp2p/p2p/protocol/rendezvous/pb"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/require"
+)
+
+func newHost(t *testing.T) host.Host {
+	t.Helper()
+	h, err := basic.NewHost(swarmt.GenSwarm(t), nil)
+	require.NoError(t, err)
+	h.Start()
+	t.Cleanup(func() { h.Close() })
+	return h
+}
+
+func setup(t *testing.T, n int, opts ...ServiceOption) (*Service, host.Host, []*Client) {
+	t.Helper()
+	server := newHost(t)
+	svc, err := NewService(server, opts...)
+	require.NoError(t, err)
+	t.Cleanup(func() { svc.Close() })
+
+	clients := make([]*Client, 0, n)
+	for i := 0; i < n; i++ {
+		h := newHost(t)
+		h.Peerstore().AddAddrs(server.ID(), server.Addrs(), peerstore.PermanentAddrTTL)
+		clients = append(clients, NewClient(h, server.ID()))
+	}
+	return svc, server, clients
+}
+
+func TestRegisterDiscover(t *testing.T) {
+	_, _, clients := setup(t, 3)
+	ctx := context.Background()
+
+	for _, c := range clients[:2] {
+		ttl, err := c.Register(ctx, "foo", 0)
+		require.NoError(t, err)
+		require.Equal(t, DefaultTTL, ttl)
+	}
+	_, err := clients[2].Register(ctx, "bar", 10*time.Minute)
+	require.NoError(t, err)
+
+	peers, _, err := clients[2].Discover(ctx, "foo", 0, nil)
+	require.NoError(t, err)
+	require.Len(t, peers, 2)
+	for i, pi := range peers {
+		require.Equal(t, clients[i].host.ID(), pi.ID)
+		require.NotEmpty(t, pi.Addrs)
+		require.ElementsMatch(t, clients[i].host.Addrs(), clients[2].host.Peerstore().Addrs(pi.ID))
+	}
+
+	// an empty namespace discovers all registrations
+	peers, _, err = clients[0].Discover(ctx, "", 0, nil)
+	require.NoError(t, err)
+	require.Len(t, peers, 3)
+
+	require.NoError(t, clients[0].Unregister(ctx, "foo"))
+	require.Eventually(t, func() bool {
+		peers, _, err := clients[2].Discover(ctx, "foo", 0, nil)
+		return err == nil && len(peers) == 1 && peers[0].ID == clients[1].host.ID()
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestDiscoverPagination(t *testing.T) {
+	_, _, clients := setup(t, 5)
+	ctx := context.Background()
+	for _, c := range clients {
+		_, err := c.Register(ctx, "foo", 0)
+		require.NoError(t, err)
+	}
+
+	var all []peer.ID
+	var cookie []byte
+	for i := 0; i < 3; i++ {
+		peers, c, err := clients[0].Discover(ctx, "foo", 2, cookie)
+		require.NoError(t, err)
+		require.LessOrEqual(t, len(peers), 2)
+		for _, pi := range peers {
+			all = append(all, pi.ID)
+		}
+		cookie = c
+	}
+	require.Len(t, all, 5)
+	for i, c := range clients {
+		require.Equal(t, c.host.ID(), all[i])
+	}
+
+	// no new registrations
+	peers, cookie, err := clients[0].Discover(ctx, "foo", 2, cookie)
+	require.NoError(t, err)
+	require.Empty(t, peers)
+
+	// re-registering makes the peer show up again
+	_, err = clients[3].Register(ctx, "foo", 0)
+	require.NoError(t, err)
+	peers, _, err = clients[0].Discover(ctx, "foo", 2, cookie)
+	require.NoError(t, err)
+	require.Len(t, peers, 1)
+	require.Equal(t, clients[3].host.ID(), peers[0].ID)
+
+	// a cookie is only valid for the namespace it was issued for
+	_, _, err = clients[0].Discover(ctx, "bar", 2, cookie)
+	var rerr *RendezvousError
+	require.True(t, errors.As(err, &rerr))
+	require.Equal(t, pb.Message_E_INVALID_COOKIE, rerr.Status)
+}
+
+func TestRegisterInvalid(t *testing.T) {
+	_, _, clients := setup(t, 1, WithMaxRegistrationsPerPeer(2))
+	ctx := context.Background()
+	c := clients[0]
+
+	var rerr *RendezvousError
+	_, err := c.Register(ctx, "foo", time.Second)
+	require.True(t, errors.As(err, &rerr))
+	require.Equal(t, pb.Message_E_INVALID_TTL, rerr.Status)
+
+	_, err = c.Register(ctx, "foo", 100*time.Hour)
+	require.True(t, errors.As(err, &rerr))
+	require.Equal(t, pb.Message_E_INVALID_TTL, rerr.Status)
+
+	_, err = c.Register(ctx, "foo", 0)
+	require.NoError(t, err)
+	_, err = c.Register(ctx, "bar", 0)
+	require.NoError(t, err)
+	// refreshing an existing registration doesn't count against the limit
+	_, err = c.Register(ctx, "foo", 0)
+	require.NoError(t, err)
+	_, err = c.Register(ctx, "baz", 0)
+	require.True(t, errors.As(err, &rerr))
+	require.Equal(t, pb.Message_E_NOT_AUTHORIZED, rerr.Status)
+}
+
+func TestMaxRegistrations(t *testing.T) {
+	_, _, clients := setup(t, 2, WithMaxRegistrations(2))
+	ctx := context.Background()
+
+	_, err := clients[0].Register(ctx, "foo", 0)
+	require.NoError(t, err)
+	_, err = clients[1].Register(ctx, "foo", 0)
+	require.NoError(t, err)
+	// refreshing an existing registration doesn't count against the limit
+	_, err = clients[0].Register(ctx, "foo", 0)
+	require.NoError(t, err)
+
+	var rerr *RendezvousError
+	_, err = clients[0].Register(ctx, "bar", 0)
+	require.True(t, errors.As(err, &rerr))
+	require.Equal(t, pb.Message_E_NOT_AUTHORIZED, rerr.Status)
+
+	// unregistering frees up space
+	require.NoError(t, clients[1].Unregister(ctx, "foo"))
+	require.Eventually(t, func() bool {
+		_, err := clients[0].Register(ctx, "bar", 0)
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestRegistrationExpiry(t *testing.T) {
+	cl := clock.NewMock()
+	svc, _, clients := setup(t, 2, WithClock(cl))
+	ctx := context.Background()
+
+	_, err := clients[0].Register(ctx, "foo", MinTTL)
+	require.NoError(t, err)
+	_, err = clients[1].Register(ctx, "foo", 2*MinTTL)
+	require.NoError(t, err)
+
+	cl.Add(MinTTL)
+	peers, _, err := clients[0].Discover(ctx, "foo", 0, nil)
+	require.NoError(t, err)
+	require.Len(t, peers, 1)
+	require.Equal(t, clients[1].host.ID(), peers[0].ID)
+
+	svc.gc()
+	svc.mx.Lock()
+	require.Len(t, svc.registrations["foo"], 1)
+	require.NotContains(t, svc.regsPerPeer, clients[0].host.ID())
+	svc.mx.Unlock()
+}
+
+func TestRendezvousDiscovery(t *testing.T) {
+	_, _, clients := setup(t, 4)
+	ctx := context.Background()
+
+	for _, c := range clients {
+		ttl, err := NewRendezvousDiscovery(c).Advertise(ctx, "foo", discovery.TTL(time.Hour))
+		require.NoError(t, err)
+		require.Equal(t, time.Hour, ttl)
+	}
+
+	d := NewRendezvousDiscovery(clients[0])
+	ch, err := d.FindPeers(ctx, "foo")
+	require.NoError(t, err)
+	var found []peer.ID
+	for pi := range ch {
+		found = append(found, pi.ID)
+	}
+	// we don't discover ourselves
+	require.ElementsMatch(t, []peer.ID{clients[1].host.ID(), clients[2].host.ID(), clients[3].host.ID()}, found)
+
+	ch, err = d.FindPeers(ctx, "foo", discovery.Limit(2))
+	require.NoError(t, err)
+	found = found[:0]
+	for pi := range ch {
+		found = append(found, pi.ID)
+	}
+	require.Len(t, found, 2)
+}
@@ -0,0 +1,388 @@
+package rendezvous
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/record"
+	"github.com/libp2p/go-libp2p/p2p/protocol/rendezvous/pb"
+
+	"github.com/benbjohnson/clock"
+	"github.com/libp2p/go-msgio/pbio"
+)
+
+const (
+	// DefaultMaxRegistrationsPerPeer is the default maximum number of
+	// registrations a single peer can hold at a rendezvous point.
+	DefaultMaxRegistrationsPerPeer = 1000
+	// DefaultMaxRegistrations is the default maximum number of registrations
+	// a rendezvous point holds in total.
+	DefaultMaxRegistrations = 100_000
+)
+
+type registration struct {
+	ns      string
+	peer    peer.ID
+	record  []byte
+	expires time.Time
+	counter uint64
+}
+
+// Service is a rendezvous point. It accepts registrations from peers and
+// answers discovery requests.
+type Service struct {
+	host           host.Host
+	maxRegs        int
+	maxRegsPerPeer int
+	clock          clock.Clock
+	gcInterval     time.Duration
+	closeOnce      sync.Once
+	closeCh        chan struct{}
+	refCount       sync.WaitGroup
+	mx             sync.Mutex
+	registrations  map[string]map[peer.ID]*registration
+	regsPerPeer    map[peer.ID]int
+	numRegs        int
+	counter        uint64
+}
+
+// ServiceOption is an option for NewService.
+type ServiceOption func(*Service) error
+
+// WithMaxRegistrationsPerPeer sets the maximum number of registrations a single peer can hold.
+func WithMaxRegistrationsPerPeer(n int) ServiceOption {
+	return func(s *Service) error {
+		if n <= 0 {
+			return errors.New("max registrations per peer must be positive")
+		}
+		s.maxRegsPerPeer = n
+		return nil
+	}
+}
+
+// WithMaxRegistrations sets the maximum number of registrations the
+// rendezvous point holds in total. Registrations beyond that are rejected.
+func WithMaxRegistrations(n int) ServiceOption {
+	return func(s *Service) error {
+		if n <= 0 {
+			return errors.New("max registrations must be positive")
+		}
+		s.maxRegs = n
+		return nil
+	}
+}
+
+// WithClock sets the clock used to expire registrations. Used for testing.
+func WithClock(cl clock.Clock) ServiceOption {
+	return func(s *Service) error {
+		s.clock = cl
+		return nil
+	}
+}
+
+// NewService creates a new rendezvous point and registers its stream handler on h.
+func NewService(h host.Host, opts ...ServiceOption) (*Service, error) {
+	s := &Service{
+		host:           h,
+		maxRegs:        DefaultMaxRegistrations,
+		maxRegsPerPeer: DefaultMaxRegistrationsPerPeer,
+		clock:          clock.New(),
+		gcInterval:     time.Minute,
+		closeCh:        make(chan struct{}),
+		registrations:  make(map[string]map[peer.ID]*registration),
+		regsPerPeer:    make(map[peer.ID]int),
+	}
+	for _, opt := range opts {
+		if err := opt(s); err != nil {
+			return nil, err
+		}
+	}
+
+	s.refCount.Add(1)
+	go s.background()
+	h.SetStreamHandler(ProtocolID, s.handleNewStream)
+	return s, nil
+}
+
+// Close stops the rendezvous point.
+func (s *Service) Close() error {
+	s.closeOnce.Do(func() {
+		s.host.RemoveStreamHandler(ProtocolID)
+		close(s.closeCh)
+		s.refCount.Wait()
+	})
+	return nil
+}
+
+func (s *Service) background() {
+	defer s.refCount.Done()
+	ticker := s.clock.Ticker(s.gcInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.gc()
+		case <-s.closeCh:
+			return
+		}
+	}
+}
+
+// gc removes expired registrations.
+func (s *Service) gc() {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	now := s.clock.Now()
+	for ns, regs := range s.registrations {
+		for p, r := range regs {
+			if !now.Before(r.expires) {
+				s.removeLocked(ns, p)
+			}
+		}
+	}
+}
+
+func (s *Service) removeLocked(ns string, p peer.ID) {
+	regs, ok := s.registrations[ns]
+	if !ok {
+		return
+	}
+	if _, ok := regs[p]; !ok {
+		return
+	}
+	delete(regs, p)
+	if len(regs) == 0 {
+		delete(s.registrations, ns)
+	}
+	s.numRegs--
+	s.regsPerPeer[p]--
+	if s.regsPerPeer[p] <= 0 {
+		delete(s.regsPerPeer, p)
+	}
+}
+
+func (s *Service) handleNewStream(str network.Stream) {
+	if err := str.Scope().SetService(ServiceName); err != nil {
+		log.Debugf("error attaching stream to rendezvous service: %s", err)
+		str.Reset()
+		return
+	}
+	if err := str.Scope().ReserveMemory(maxRequestSize, network.ReservationPriorityAlways); err != nil {
+		log.Debugf("error reserving memory for stream: %s", err)
+		str.Reset()
+		return
+	}
+	defer str.Scope().ReleaseMemory(maxRequestSize)
+
+	rd := pbio.NewDelimitedReader(str, maxRequestSize)
+	wr := pbio.NewDelimitedWriter(str)
+	rp := str.Conn().RemotePeer()
+	for {
+		str.SetDeadline(time.Now().Add(streamTimeout))
+
+		var req pb.Message
+		if err := rd.ReadMsg(&req); err != nil {
+			if err == io.EOF {
+				str.Close()
+			} else {
+				log.Debugw("error reading rendezvous request", "peer", rp, "error", err)
+				str.Reset()
+			}
+			return
+		}
+
+		var resp *pb.Message
+		switch req.GetType() {
+		case pb.Message_REGISTER:
+			resp = s.handleRegister(rp, req.GetRegister())
+		case pb.Message_UNREGISTER:
+			s.handleUnregister(rp, req.GetUnregister())
+			// unregistering doesn't have a response
+			continue
+		case pb.Message_DISCOVER:
+			resp = s.handleDiscover(req.GetDiscover())
+		default:
+			log.Debugw("unexpected rendezvous message", "peer", rp, "type", req.GetType())
+			str.Reset()
+			return
+		}
+
+		if err := wr.WriteMsg(resp); err != nil {
+			log.Debugw("error writing rendezvous response", "peer", rp, "error", err)
+			str.Reset()
+			return
+		}
+	}
+}
+
+func registerResponse(status pb.Message_ResponseStatus, text string, ttl time.Duration) *pb.Message {
+	r := &pb.Message_RegisterResponse{Status: status.Enum()}
+	if text != "" {
+		r.StatusText = &text
+	}
+	if ttl > 0 {
+		t := uint64(ttl / time.Second)
+		r.Ttl = &t
+	}
+	return &pb.Message{
+		Type:             pb.Message_REGISTER_RESPONSE.Enum(),
+		RegisterResponse: r,
+	}
+}
+
+func (s *Service) handleRegister(p peer.ID, req *pb.Message_Register) *pb.Message {
+	ns := req.GetNs()
+	if ns == "" || len(ns) > MaxNamespaceLength {
+		return registerResponse(pb.Message_E_INVALID_NAMESPACE, "invalid namespace", 0)
+	}
+
+	ttl := DefaultTTL
+	if req.Ttl != nil {
+		ttl = time.Duration(req.GetTtl()) * time.Second
+	}
+	if ttl < MinTTL || ttl > MaxTTL {
+		return registerResponse(pb.Message_E_INVALID_TTL, "invalid ttl", 0)
+	}
+
+	rec := req.GetSignedPeerRecord()
+	if len(rec) == 0 || len(rec) > MaxPeerRecordSize {
+		return registerResponse(pb.Message_E_INVALID_SIGNED_PEER_RECORD, "invalid signed peer record", 0)
+	}
+	_, r, err := record.ConsumeEnvelope(rec, peer.PeerRecordEnvelopeDomain)
+	if err != nil {
+		return registerResponse(pb.Message_E_INVALID_SIGNED_PEER_RECORD, fmt.Sprintf("invalid signed peer record: %s", err), 0)
+	}
+	pr, ok := r.(*peer.PeerRecord)
+	if !ok || pr.PeerID != p {
+		return registerResponse(pb.Message_E_INVALID_SIGNED_PEER_RECORD, "signed peer record doesn't match peer", 0)
+	}
+
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	regs, ok := s.registrations[ns]
+	if !ok {
+		regs = make(map[peer.ID]*registration)
+		s.registrations[ns] = regs
+	}
+	if _, exists := regs[p]; !exists {
+		if s.regsPerPeer[p] >= s.maxRegsPerPeer || s.numRegs >= s.maxRegs {
+			if len(regs) == 0 {
+				delete(s.registrations, ns)
+			}
+			return registerResponse(pb.Message_E_NOT_AUTHORIZED, "too many registrations", 0)
+		}
+		s.numRegs++
+		s.regsPerPeer[p]++
+	}
+	s.counter++
+	regs[p] = &registration{
+		ns:      ns,
+		peer:    p,
+		record:  rec,
+		expires: s.clock.Now().Add(ttl),
+		counter: s.counter,
+	}
+	log.Debugw("registered peer", "peer", p, "ns", ns, "ttl", ttl)
+	return registerResponse(pb.Message_OK, "", ttl)
+}
+
+func (s *Service) handleUnregister(p peer.ID, req *pb.Message_Unregister) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.removeLocked(req.GetNs(), p)
+}
+
+func discoverResponse(status pb.Message_ResponseStatus, text string) *pb.Message {
+	r := &pb.Message_DiscoverResponse{Status: status.Enum()}
+	if text != "" {
+		r.StatusText = &text
+	}
+	return &pb.Message{
+		Type:             pb.Message_DISCOVER_RESPONSE.Enum(),
+		DiscoverResponse: r,
+	}
+}
+
+// A cookie is the counter of the last registration returned, followed by the namespace.
+func makeCookie(ns string, counter uint64) []byte {
+	b := make([]byte, 8, 8+len(ns))
+	binary.BigEndian.PutUint64(b, counter)
+	return append(b, ns...)
+}
+
+func parseCookie(ns string, cookie []byte) (uint64, error) {
+	if len(cookie) < 8 {
+		return 0, errors.New("cookie too short")
+	}
+	if string(cookie[8:]) != ns {
+		return 0, errors.New("cookie doesn't match namespace")
+	}
+	return binary.BigEndian.Uint64(cookie[:8]), nil
+}
+
+// handleDiscover returns the registrations for the requested namespace. An
+// empty namespace matches registrations in all namespaces.
+func (s *Service) handleDiscover(req *pb.Message_Discover) *pb.Message {
+	ns := req.GetNs()
+	if len(ns) > MaxNamespaceLength {
+		return discoverResponse(pb.Message_E_INVALID_NAMESPACE, "invalid namespace")
+	}
+	limit := MaxDiscoverLimit
+	if l := req.GetLimit(); l > 0 && l < MaxDiscoverLimit {
+		limit = int(l)
+	}
+	var after uint64
+	if len(req.GetCookie()) > 0 {
+		var err error
+		after, err = parseCookie(ns, req.GetCookie())
+		if err != nil {
+			return discoverResponse(pb.Message_E_INVALID_COOKIE, err.Error())
+		}
+	}
+
+	s.mx.Lock()
+	now := s.clock.Now()
+	var regs []*registration
+	addRegs := func(m map[peer.ID]*registration) {
+		for _, r := range m {
+			if r.counter > after && now.Before(r.expires) {
+				regs = append(regs, r)
+			}
+		}
+	}
+	if ns == "" {
+		for _, m := range s.registrations {
+			addRegs(m)
+		}
+	} else {
+		addRegs(s.registrations[ns])
+	}
+	s.mx.Unlock()
+
+	sort.Slice(regs, func(i, j int) bool { return regs[i].counter < regs[j].counter })
+	if len(regs) > limit {
+		regs = regs[:limit]
+	}
+
+	resp := discoverResponse(pb.Message_OK, "")
+	cookie := after
+	for _, r := range regs {
+		ttl := uint64(r.expires.Sub(now) / time.Second)
+		resp.DiscoverResponse.Registrations = append(resp.DiscoverResponse.Registrations, &pb.Message_Register{
+			Ns:               &r.ns,
+			SignedPeerRecord: r.record,
+			Ttl:              &ttl,
+		})
+		cookie = r.counter
+	}
+	resp.DiscoverResponse.Cookie = makeCookie(ns, cookie)
+	return resp
+}
@@ -0,0 +1,49 @@
+package reqresp
+
+import (
+	"google.golang.org/protobuf/proto"
+)
+
+// Codec encodes and decodes the messages of a request/response protocol.
+//
+// Codecs for other encodings, e.g. CBOR, can be implemented by wrapping the
+// Marshal and Unmarshal functions of the encoding library.
+type Codec[T any] interface {
+	Marshal(msg T) ([]byte, error)
+	Unmarshal(b []byte) (T, error)
+}
+
+type protoCodec[T proto.Message] struct{}
+
+// ProtoCodec returns a Codec for the protobuf message type T, e.g.
+// ProtoCodec[*pb.Request]().
+func ProtoCodec[T proto.Message]() Codec[T] {
+	return protoCodec[T]{}
+}
+
+func (protoCodec[T]) Marshal(msg T) ([]byte, error) {
+	return proto.Marshal(msg)
+}
+
+func (protoCodec[T]) Unmarshal(b []byte) (T, error) {
+	var zero T
+	// ProtoReflect works on nil pointers of generated messages.
+	msg := zero.ProtoReflect().Type().New().Interface().(T)
+	if err := proto.Unmarshal(b, msg); err != nil {
+		return zero, err
+	}
+	return msg, nil
+}
+
+// CodecFuncs returns a Codec using the given functions.
+func CodecFuncs[T any](marshal func(T) ([]byte, error), unmarshal func([]byte) (T, error)) Codec[T] {
+	return codecFuncs[T]{marshal: marshal, unmarshal: unmarshal}
+}
+
+type codecFuncs[T any] struct {
+	marshal   func(T) ([]byte, error)
+	unmarshal func([]byte) (T, error)
+}
+
+func (c codecFuncs[T]) Marshal(msg T) ([]byte, error) { return c.marshal(msg) }
+func (c codecFuncs[T]) Unmarshal(b []byte) (T, error) { return c.unmarshal(b) }
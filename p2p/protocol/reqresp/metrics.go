@@ -0,0 +1,105 @@
+package reqresp
+
+import (
+	"context"
+	"errors"
+	"os"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	"github.com/libp2p/go-libp2p/p2p/metricshelper"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const metricNamespace = "libp2p_reqresp"
+
+type metricsCollectors struct {
+	requestDuration *prometheus.HistogramVec
+}
+
+func newMetricsCollectors() *metricsCollectors {
+	return &metricsCollectors{
+		requestDuration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace: metricNamespace,
+				Name:      "request_duration_seconds",
+				Help:      "Duration of requests, by protocol, direction and outcome",
+				Buckets:   prometheus.ExponentialBuckets(0.001, 2, 15),
+			},
+			[]string{"protocol", "dir", "outcome"},
+		),
+	}
+}
+
+func (c *metricsCollectors) Collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		c.requestDuration,
+	}
+}
+
+// defaultCollectors are shared by all metrics tracers, unless they register
+// with a metricshelper.LabeledRegisterer.
+var defaultCollectors = newMetricsCollectors()
+
+// MetricsTracer tracks the requests of request/response protocols.
+type MetricsTracer interface {
+	// RequestCompleted is called when a request completed. For outbound
+	// requests, it is called once per attempt.
+	RequestCompleted(dir network.Direction, p protocol.ID, err error, d time.Duration)
+}
+
+type metricsTracer struct {
+	*metricsCollectors
+}
+
+var _ MetricsTracer = &metricsTracer{}
+
+type metricsTracerSetting struct {
+	reg prometheus.Registerer
+}
+
+type MetricsTracerOption func(*metricsTracerSetting)
+
+func WithRegisterer(reg prometheus.Registerer) MetricsTracerOption {
+	return func(s *metricsTracerSetting) {
+		if reg != nil {
+			s.reg = reg
+		}
+	}
+}
+
+// NewMetricsTracer creates a MetricsTracer. It can be shared by multiple protocols.
+func NewMetricsTracer(opts ...MetricsTracerOption) MetricsTracer {
+	setting := &metricsTracerSetting{reg: prometheus.DefaultRegisterer}
+	for _, opt := range opts {
+		opt(setting)
+	}
+	return &metricsTracer{metricshelper.Collectors(setting.reg, defaultCollectors, newMetricsCollectors)}
+}
+
+func (t *metricsTracer) RequestCompleted(dir network.Direction, p protocol.ID, err error, d time.Duration) {
+	tags := metricshelper.GetStringSlice()
+	defer metricshelper.PutStringSlice(tags)
+
+	*tags = append(*tags, string(p), metricshelper.GetDirection(dir), getOutcome(err))
+	t.requestDuration.WithLabelValues(*tags...).Observe(d.Seconds())
+}
+
+func getOutcome(err error) string {
+	// Request returns RemoteErrors unwrapped. Avoid errors.As, it allocates.
+	_, isRemoteErr := err.(*RemoteError)
+	switch {
+	case err == nil:
+		return "ok"
+	case isRemoteErr, errors.Is(err, errHandler):
+		return "handler_error"
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, os.ErrDeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, network.ErrResourceLimitExceeded):
+		return "resource_limit_exceeded"
+	default:
+		return "error"
+	}
+}
@@ -0,0 +1,39 @@
+//go:build nocover
+
+package reqresp
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/protocol"
+)
+
+func TestMetricsNoAllocNoCover(t *testing.T) {
+	dirs := []network.Direction{network.DirInbound, network.DirOutbound}
+	protos := []protocol.ID{"/test/1.0.0", "/test/2.0.0"}
+	errs := []error{
+		nil,
+		&RemoteError{Msg: "not found"},
+		errHandler,
+		context.DeadlineExceeded,
+		network.ErrResourceLimitExceeded,
+		errors.New("stream reset"),
+	}
+	tr := NewMetricsTracer()
+	tests := map[string]func(){
+		"RequestCompleted": func() {
+			tr.RequestCompleted(dirs[rand.Intn(len(dirs))], protos[rand.Intn(len(protos))], errs[rand.Intn(len(errs))], time.Duration(rand.Intn(1000))*time.Millisecond)
+		},
+	}
+	for method, f := range tests {
+		allocs := testing.AllocsPerRun(1000, f)
+		if allocs > 0 {
+			t.Fatalf("Alloc Test: %s, got: %0.2f, expected: 0 allocs", method, allocs)
+		}
+	}
+}
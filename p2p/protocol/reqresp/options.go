@@ -0,0 +1,75 @@
+package reqresp
+
+import (
+	"errors"
+	"time"
+)
+
+type config struct {
+	timeout        time.Duration
+	retries        int
+	retryBackoff   time.Duration
+	maxRequestSize int
+	maxRespSize    int
+	serviceName    string
+	metricsTracer  MetricsTracer
+}
+
+// Option is an option for a Protocol.
+type Option func(*config) error
+
+// WithTimeout sets the deadline for a single request, both when making and
+// when handling a request. Defaults to 10 seconds.
+func WithTimeout(d time.Duration) Option {
+	return func(cfg *config) error {
+		if d <= 0 {
+			return errors.New("timeout must be positive")
+		}
+		cfg.timeout = d
+		return nil
+	}
+}
+
+// WithRetries makes Request retry failed requests up to n times, waiting
+// backoff between attempts. Errors returned by the remote handler are not
+// retried. Defaults to no retries.
+func WithRetries(n int, backoff time.Duration) Option {
+	return func(cfg *config) error {
+		if n < 0 || backoff < 0 {
+			return errors.New("retries and backoff must not be negative")
+		}
+		cfg.retries = n
+		cfg.retryBackoff = backoff
+		return nil
+	}
+}
+
+// WithMaxMessageSize sets the maximum size of encoded requests and responses.
+// Defaults to 4 KiB for requests and 64 KiB for responses.
+func WithMaxMessageSize(request, response int) Option {
+	return func(cfg *config) error {
+		if request <= 0 || response <= 0 {
+			return errors.New("maximum message size must be positive")
+		}
+		cfg.maxRequestSize = request
+		cfg.maxRespSize = response
+		return nil
+	}
+}
+
+// WithServiceName sets the resource manager service the streams are attached
+// to. Defaults to the protocol ID.
+func WithServiceName(name string) Option {
+	return func(cfg *config) error {
+		cfg.serviceName = name
+		return nil
+	}
+}
+
+// WithMetricsTracer configures the Protocol to use the given MetricsTracer.
+func WithMetricsTracer(mt MetricsTracer) Option {
+	return func(cfg *config) error {
+		cfg.metricsTracer = mt
+		return nil
+	}
+}
@@ -0,0 +1,309 @@
+// Package reqresp implements request/response protocols on top of streams.
+//
+// A request/response protocol opens a new stream for every request. The
+// request and the response are sent as a single length-prefixed message each
+// (see the framing package). Every response is prefixed with a status byte,
+// which allows the handler to return an error to the requesting peer instead
+// of a response.
+//
+// The framework takes care of the details that every protocol needs to get
+// right: deadlines on both sides, limits on the message sizes, reserving
+// memory in the resource manager and attaching streams to a resource manager
+// service, retries and metrics.
+//
+//	p, err := reqresp.New(h, "/my-app/lookup/1.0.0",
+//		reqresp.ProtoCodec[*pb.LookupRequest](), reqresp.ProtoCodec[*pb.LookupResponse]())
+//	...
+//	p.Handle(func(ctx context.Context, from peer.ID, req *pb.LookupRequest) (*pb.LookupResponse, error) {
+//		...
+//	})
+//	resp, err := p.Request(ctx, peerID, &pb.LookupRequest{Key: key})
+package reqresp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	"github.com/libp2p/go-libp2p/p2p/net/framing"
+
+	logging "github.com/ipfs/go-log/v2"
+)
+
+var log = logging.Logger("reqresp")
+
+const (
+	defaultTimeout        = 10 * time.Second
+	defaultMaxRequestSize = 4 << 10
+	defaultMaxRespSize    = 64 << 10
+)
+
+const (
+	statusOK byte = iota
+	statusError
+)
+
+var (
+	errInvalidResponse = errors.New("invalid response")
+	errHandler         = errors.New("handler error")
+)
+
+// RemoteError is returned by Request if the remote handler returned an error.
+type RemoteError struct {
+	// Msg is the error message returned by the handler.
+	Msg string
+}
+
+func (e *RemoteError) Error() string {
+	return "remote error: " + e.Msg
+}
+
+// Handler handles a request from peer p. The error is sent to the requesting
+// peer (see RemoteError), so it shouldn't contain sensitive information.
+// ctx is canceled when the request times out.
+type Handler[Req, Resp any] func(ctx context.Context, p peer.ID, req Req) (Resp, error)
+
+// Protocol is a request/response protocol.
+type Protocol[Req, Resp any] struct {
+	host      host.Host
+	id        protocol.ID
+	reqCodec  Codec[Req]
+	respCodec Codec[Resp]
+	cfg       config
+}
+
+// New creates a request/response protocol with the given protocol ID.
+// Call Handle to handle incoming requests.
+func New[Req, Resp any](h host.Host, id protocol.ID, reqCodec Codec[Req], respCodec Codec[Resp], opts ...Option) (*Protocol[Req, Resp], error) {
+	cfg := config{
+		timeout:        defaultTimeout,
+		maxRequestSize: defaultMaxRequestSize,
+		maxRespSize:    defaultMaxRespSize,
+		serviceName:    string(id),
+	}
+	for _, opt := range opts {
+		if err := opt(&cfg); err != nil {
+			return nil, err
+		}
+	}
+	return &Protocol[Req, Resp]{
+		host:      h,
+		id:        id,
+		reqCodec:  reqCodec,
+		respCodec: respCodec,
+		cfg:       cfg,
+	}, nil
+}
+
+// ID returns the protocol ID.
+func (p *Protocol[Req, Resp]) ID() protocol.ID {
+	return p.id
+}
+
+// Handle sets the handler for incoming requests, replacing the previous one.
+func (p *Protocol[Req, Resp]) Handle(handler Handler[Req, Resp]) {
+	p.host.SetStreamHandler(p.id, func(s network.Stream) {
+		p.handleStream(handler, s)
+	})
+}
+
+// RemoveHandler stops handling incoming requests.
+func (p *Protocol[Req, Resp]) RemoveHandler() {
+	p.host.RemoveStreamHandler(p.id)
+}
+
+func (p *Protocol[Req, Resp]) handleStream(handler Handler[Req, Resp], s network.Stream) {
+	start := time.Now()
+	err := p.serve(handler, s)
+	if err != nil && !errors.Is(err, errHandler) {
+		log.Debugw("failed to handle request", "protocol", p.id, "peer", s.Conn().RemotePeer(), "error", err)
+		s.Reset()
+	} else {
+		s.Close()
+	}
+	if p.cfg.metricsTracer != nil {
+		p.cfg.metricsTracer.RequestCompleted(network.DirInbound, p.id, err, time.Since(start))
+	}
+}
+
+func (p *Protocol[Req, Resp]) serve(handler Handler[Req, Resp], s network.Stream) error {
+	if err := s.Scope().SetService(p.cfg.serviceName); err != nil {
+		return fmt.Errorf("failed to attach stream to service: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), p.cfg.timeout)
+	defer cancel()
+	deadline, _ := ctx.Deadline()
+	s.SetDeadline(deadline)
+
+	r, err := framing.NewReader(s, p.cfg.maxRequestSize)
+	if err != nil {
+		return err
+	}
+	b, err := r.ReadMsg()
+	if err != nil {
+		r.Close()
+		return fmt.Errorf("failed to read request: %w", err)
+	}
+	// the handler might hold on to the request, don't let it reference our buffer
+	req, err := p.reqCodec.Unmarshal(append([]byte(nil), b...))
+	r.Close()
+	if err != nil {
+		return fmt.Errorf("failed to decode request: %w", err)
+	}
+
+	resp, herr := handler(ctx, s.Conn().RemotePeer(), req)
+	var msg []byte
+	if herr != nil {
+		msg = append([]byte{statusError}, herr.Error()...)
+		if len(msg) > p.cfg.maxRespSize+1 {
+			msg = msg[:p.cfg.maxRespSize+1]
+		}
+	} else {
+		b, err := p.respCodec.Marshal(resp)
+		if err != nil {
+			return fmt.Errorf("failed to encode response: %w", err)
+		}
+		msg = append([]byte{statusOK}, b...)
+	}
+
+	w, err := framing.NewWriter(s, p.cfg.maxRespSize+1)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	if err := w.WriteMsg(msg); err != nil {
+		return fmt.Errorf("failed to write response: %w", err)
+	}
+	if herr != nil {
+		return fmt.Errorf("%w: %w", errHandler, herr)
+	}
+	return nil
+}
+
+// Request sends req to peer to and returns its response. Failed requests are
+// retried as configured by WithRetries.
+func (p *Protocol[Req, Resp]) Request(ctx context.Context, to peer.ID, req Req) (Resp, error) {
+	var zero Resp
+	b, err := p.reqCodec.Marshal(req)
+	if err != nil {
+		return zero, fmt.Errorf("failed to encode request: %w", err)
+	}
+	if len(b) > p.cfg.maxRequestSize {
+		return zero, fmt.Errorf("%w: request of %d bytes, maximum is %d", framing.ErrMsgTooLarge, len(b), p.cfg.maxRequestSize)
+	}
+
+	for attempt := 0; ; attempt++ {
+		start := time.Now()
+		resp, err := p.request(ctx, to, b)
+		if p.cfg.metricsTracer != nil {
+			p.cfg.metricsTracer.RequestCompleted(network.DirOutbound, p.id, err, time.Since(start))
+		}
+		if err == nil || attempt >= p.cfg.retries || !isRetryable(err) || ctx.Err() != nil {
+			return resp, err
+		}
+		log.Debugw("request failed, retrying", "protocol", p.id, "peer", to, "attempt", attempt+1, "error", err)
+		select {
+		case <-time.After(p.cfg.retryBackoff):
+		case <-ctx.Done():
+			return zero, ctx.Err()
+		}
+	}
+}
+
+func (p *Protocol[Req, Resp]) request(ctx context.Context, to peer.ID, req []byte) (Resp, error) {
+	var zero Resp
+	ctx, cancel := context.WithTimeout(ctx, p.cfg.timeout)
+	defer cancel()
+
+	s, err := p.host.NewStream(ctx, to, p.id)
+	if err != nil {
+		return zero, err
+	}
+	// unblock reads and writes when the context is canceled
+	stop := context.AfterFunc(ctx, func() { s.Reset() })
+	defer stop()
+
+	resp, err := p.roundTrip(ctx, s, req)
+	if err != nil {
+		s.Reset()
+		if ctx.Err() != nil {
+			return zero, ctx.Err()
+		}
+		// The stream deadline is the deadline of the context, and might expire
+		// before the context is done.
+		var nerr net.Error
+		if errors.As(err, &nerr) && nerr.Timeout() {
+			return zero, context.DeadlineExceeded
+		}
+		return zero, err
+	}
+	s.Close()
+	return resp, nil
+}
+
+func (p *Protocol[Req, Resp]) roundTrip(ctx context.Context, s network.Stream, req []byte) (Resp, error) {
+	var zero Resp
+	if err := s.Scope().SetService(p.cfg.serviceName); err != nil {
+		return zero, fmt.Errorf("failed to attach stream to service: %w", err)
+	}
+	deadline, _ := ctx.Deadline()
+	s.SetDeadline(deadline)
+
+	w, err := framing.NewWriter(s, p.cfg.maxRequestSize)
+	if err != nil {
+		return zero, err
+	}
+	err = w.WriteMsg(req)
+	w.Close()
+	if err != nil {
+		return zero, fmt.Errorf("failed to write request: %w", err)
+	}
+	if err := s.CloseWrite(); err != nil {
+		return zero, err
+	}
+
+	r, err := framing.NewReader(s, p.cfg.maxRespSize+1)
+	if err != nil {
+		return zero, err
+	}
+	defer r.Close()
+	msg, err := r.ReadMsg()
+	if err != nil {
+		return zero, fmt.Errorf("failed to read response: %w", err)
+	}
+	if len(msg) == 0 {
+		return zero, errInvalidResponse
+	}
+	// don't let the response reference our buffer
+	payload := append([]byte(nil), msg[1:]...)
+	switch msg[0] {
+	case statusOK:
+		resp, err := p.respCodec.Unmarshal(payload)
+		if err != nil {
+			return zero, fmt.Errorf("%w: %w", errInvalidResponse, err)
+		}
+		return resp, nil
+	case statusError:
+		return zero, &RemoteError{Msg: string(payload)}
+	default:
+		return zero, fmt.Errorf("%w: unknown status %d", errInvalidResponse, msg[0])
+	}
+}
+
+func isRetryable(err error) bool {
+	var remoteErr *RemoteError
+	switch {
+	case errors.As(err, &remoteErr),
+		errors.Is(err, errInvalidResponse),
+		errors.Is(err, framing.ErrMsgTooLarge),
+		errors.Is(err, context.Canceled):
+		return false
+	}
+	return true
+}
@@ -0,0 +1,185 @@
+package reqresp
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/peer/pb"
+	bhost "github.com/libp2p/go-libp2p/p2p/host/basic"
+	"github.com/libp2p/go-libp2p/p2p/net/framing"
+	swarmt "github.com/libp2p/go-libp2p/p2p/net/swarm/testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testProto = "/test/reqresp/1.0.0"
+
+func makeHosts(t *testing.T) (host.Host, host.Host) {
+	t.Helper()
+	hosts := make([]host.Host, 2)
+	for i := range hosts {
+		h, err := bhost.NewHost(swarmt.GenSwarm(t), nil)
+		require.NoError(t, err)
+		h.Start()
+		t.Cleanup(func() { h.Close() })
+		hosts[i] = h
+	}
+	require.NoError(t, hosts[0].Connect(context.Background(), peer.AddrInfo{ID: hosts[1].ID(), Addrs: hosts[1].Addrs()}))
+	return hosts[0], hosts[1]
+}
+
+func newProtocol(t *testing.T, h host.Host, opts ...Option) *Protocol[*pb.PeerRecord, *pb.PeerRecord] {
+	t.Helper()
+	p, err := New(h, testProto, ProtoCodec[*pb.PeerRecord](), ProtoCodec[*pb.PeerRecord](), opts...)
+	require.NoError(t, err)
+	return p
+}
+
+func TestRequest(t *testing.T) {
+	h1, h2 := makeHosts(t)
+	server := newProtocol(t, h2)
+	server.Handle(func(_ context.Context, p peer.ID, req *pb.PeerRecord) (*pb.PeerRecord, error) {
+		require.Equal(t, h1.ID(), p)
+		return &pb.PeerRecord{Seq: req.Seq + 1}, nil
+	})
+
+	client := newProtocol(t, h1)
+	resp, err := client.Request(context.Background(), h2.ID(), &pb.PeerRecord{Seq: 41})
+	require.NoError(t, err)
+	require.Equal(t, uint64(42), resp.Seq)
+
+	server.RemoveHandler()
+	_, err = client.Request(context.Background(), h2.ID(), &pb.PeerRecord{Seq: 41})
+	require.Error(t, err)
+}
+
+func TestRequestCodecFuncs(t *testing.T) {
+	h1, h2 := makeHosts(t)
+	codec := CodecFuncs(
+		func(s string) ([]byte, error) { return []byte(s), nil },
+		func(b []byte) (string, error) { return string(b), nil },
+	)
+	server, err := New(h2, testProto, codec, codec)
+	require.NoError(t, err)
+	server.Handle(func(_ context.Context, _ peer.ID, req string) (string, error) {
+		return "hello " + req, nil
+	})
+	client, err := New(h1, testProto, codec, codec)
+	require.NoError(t, err)
+	resp, err := client.Request(context.Background(), h2.ID(), "world")
+	require.NoError(t, err)
+	require.Equal(t, "hello world", resp)
+}
+
+func TestRemoteError(t *testing.T) {
+	h1, h2 := makeHosts(t)
+	var calls atomic.Int32
+	server := newProtocol(t, h2)
+	server.Handle(func(context.Context, peer.ID, *pb.PeerRecord) (*pb.PeerRecord, error) {
+		calls.Add(1)
+		return nil, errors.New("not found")
+	})
+
+	client := newProtocol(t, h1, WithRetries(3, 0))
+	_, err := client.Request(context.Background(), h2.ID(), &pb.PeerRecord{})
+	var remoteErr *RemoteError
+	require.ErrorAs(t, err, &remoteErr)
+	require.Equal(t, "not found", remoteErr.Msg)
+	// errors returned by the handler are not retried
+	require.Equal(t, int32(1), calls.Load())
+}
+
+func TestTimeout(t *testing.T) {
+	h1, h2 := makeHosts(t)
+	server := newProtocol(t, h2)
+	server.Handle(func(ctx context.Context, _ peer.ID, _ *pb.PeerRecord) (*pb.PeerRecord, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+
+	client := newProtocol(t, h1, WithTimeout(100*time.Millisecond))
+	start := time.Now()
+	_, err := client.Request(context.Background(), h2.ID(), &pb.PeerRecord{})
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Less(t, time.Since(start), 5*time.Second)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+	}()
+	_, err = client.Request(ctx, h2.ID(), &pb.PeerRecord{})
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+func TestRetries(t *testing.T) {
+	h1, h2 := makeHosts(t)
+	server := newProtocol(t, h2)
+	handler := func(_ context.Context, _ peer.ID, req *pb.PeerRecord) (*pb.PeerRecord, error) {
+		return req, nil
+	}
+	var attempts atomic.Int32
+	h2.SetStreamHandler(testProto, func(s network.Stream) {
+		// fail the first two attempts
+		if attempts.Add(1) <= 2 {
+			s.Reset()
+			return
+		}
+		server.handleStream(handler, s)
+	})
+
+	client := newProtocol(t, h1, WithRetries(1, 10*time.Millisecond))
+	_, err := client.Request(context.Background(), h2.ID(), &pb.PeerRecord{Seq: 1})
+	require.Error(t, err)
+	require.Equal(t, int32(2), attempts.Load())
+
+	resp, err := client.Request(context.Background(), h2.ID(), &pb.PeerRecord{Seq: 1})
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), resp.Seq)
+	require.Equal(t, int32(3), attempts.Load())
+}
+
+func TestMaxMessageSize(t *testing.T) {
+	h1, h2 := makeHosts(t)
+	server := newProtocol(t, h2, WithMaxMessageSize(100, 100))
+	server.Handle(func(_ context.Context, _ peer.ID, req *pb.PeerRecord) (*pb.PeerRecord, error) {
+		return &pb.PeerRecord{PeerId: make([]byte, 200)}, nil
+	})
+
+	// the request is checked before it is sent
+	client := newProtocol(t, h1, WithMaxMessageSize(100, 1000))
+	_, err := client.Request(context.Background(), h2.ID(), &pb.PeerRecord{PeerId: make([]byte, 200)})
+	require.ErrorIs(t, err, framing.ErrMsgTooLarge)
+
+	// the server fails to send a response that is too large
+	_, err = client.Request(context.Background(), h2.ID(), &pb.PeerRecord{})
+	require.Error(t, err)
+
+	// requests larger than what the server accepts are rejected
+	server.Handle(func(_ context.Context, _ peer.ID, req *pb.PeerRecord) (*pb.PeerRecord, error) {
+		return req, nil
+	})
+	client = newProtocol(t, h1, WithMaxMessageSize(1000, 1000))
+	_, err = client.Request(context.Background(), h2.ID(), &pb.PeerRecord{PeerId: make([]byte, 200)})
+	require.Error(t, err)
+	_, err = client.Request(context.Background(), h2.ID(), &pb.PeerRecord{PeerId: make([]byte, 50)})
+	require.NoError(t, err)
+}
+
+func TestInvalidOptions(t *testing.T) {
+	h1, _ := makeHosts(t)
+	for _, opt := range []Option{
+		WithTimeout(0),
+		WithRetries(-1, 0),
+		WithMaxMessageSize(0, 100),
+	} {
+		_, err := New(h1, testProto, ProtoCodec[*pb.PeerRecord](), ProtoCodec[*pb.PeerRecord](), opt)
+		require.Error(t, err)
+	}
+}
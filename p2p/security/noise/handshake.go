@@ -104,6 +104,7 @@ func (s *secureSession) runHandshake(ctx context.Context) (err error) {
 		if err := s.sendHandshakeMessage(hs, payload, hbuf); err != nil {
 			return fmt.Errorf("error sending handshake message: %w", err)
 		}
+		s.setHandshakeState(s.initiatorEarlyDataHandler != nil, ed, rcvdEd)
 		return nil
 	} else {
 		// stage 0 //
@@ -140,10 +141,29 @@ func (s *secureSession) runHandshake(ctx context.Context) (err error) {
 				return err
 			}
 		}
+		s.setHandshakeState(s.responderEarlyDataHandler != nil, ed, rcvdEd)
 		return nil
 	}
 }
 
+// setHandshakeState records the properties of the completed handshake in the
+// connection state.
+func (s *secureSession) setHandshakeState(hasEDH bool, sent, rcvd *pb.NoiseExtensions) {
+	s.connectionState.CipherSuite = string(cipherSuite.Name())
+	// Received extensions are only handed to an EarlyDataHandler, if there is one.
+	s.connectionState.UsedEarlyData = hasEDH && (hasEarlyData(sent) || hasEarlyData(rcvd))
+}
+
+// hasEarlyData reports whether the extensions carry an early data payload.
+// The stream muxer list is sent by the transport in every handshake and is
+// reported as UsedEarlyMuxerNegotiation instead.
+func hasEarlyData(ext *pb.NoiseExtensions) bool {
+	if ext == nil {
+		return false
+	}
+	return len(ext.WebtransportCerthashes) > 0 || len(ext.ProtoReflect().GetUnknown()) > 0
+}
+
 // setCipherStates sets the initial cipher states that will be used to protect
 // traffic after the handshake.
 //
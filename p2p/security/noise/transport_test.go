@@ -14,6 +14,7 @@ import (
 	"golang.org/x/crypto/chacha20poly1305"
 
 	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/network"
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/libp2p/go-libp2p/core/protocol"
 	"github.com/libp2p/go-libp2p/core/sec"
@@ -496,7 +497,7 @@ func (e *earlyDataHandler) Received(ctx context.Context, conn net.Conn, ext *pb.
 }
 
 func TestEarlyDataAccepted(t *testing.T) {
-	handshake := func(t *testing.T, client, server EarlyDataHandler) {
+	handshake := func(t *testing.T, client, server EarlyDataHandler) (clientState, serverState network.ConnectionState) {
 		t.Helper()
 		initTransport, err := newTestTransport(t, crypto.Ed25519, 2048).WithSessionOptions(EarlyData(client, nil))
 		require.NoError(t, err)
@@ -508,7 +509,11 @@ func TestEarlyDataAccepted(t *testing.T) {
 
 		errChan := make(chan error)
 		go func() {
-			_, err := respTransport.SecureInbound(context.Background(), initConn, "")
+			conn, err := respTransport.SecureInbound(context.Background(), initConn, "")
+			if err == nil {
+				serverState = conn.(*secureSession).connectionState
+				conn.Close()
+			}
 			errChan <- err
 		}()
 
@@ -521,6 +526,7 @@ func TestEarlyDataAccepted(t *testing.T) {
 			require.NoError(t, err)
 		}
 		defer conn.Close()
+		return conn.(*secureSession).connectionState, serverState
 	}
 
 	var receivedExtensions *pb.NoiseExtensions
@@ -537,14 +543,25 @@ func TestEarlyDataAccepted(t *testing.T) {
 	}
 
 	t.Run("client sending", func(t *testing.T) {
-		handshake(t, sendingEDH, receivingEDH)
+		clientState, serverState := handshake(t, sendingEDH, receivingEDH)
 		require.Equal(t, [][]byte{[]byte("foobar")}, receivedExtensions.WebtransportCerthashes)
+		require.True(t, clientState.UsedEarlyData)
+		require.True(t, serverState.UsedEarlyData)
 		receivedExtensions = nil
 	})
 
 	t.Run("server sending", func(t *testing.T) {
-		handshake(t, receivingEDH, sendingEDH)
+		clientState, serverState := handshake(t, receivingEDH, sendingEDH)
 		require.Equal(t, [][]byte{[]byte("foobar")}, receivedExtensions.WebtransportCerthashes)
+		require.True(t, clientState.UsedEarlyData)
+		require.True(t, serverState.UsedEarlyData)
+		receivedExtensions = nil
+	})
+
+	t.Run("no payload", func(t *testing.T) {
+		clientState, serverState := handshake(t, receivingEDH, receivingEDH)
+		require.False(t, clientState.UsedEarlyData)
+		require.False(t, serverState.UsedEarlyData)
 		receivedExtensions = nil
 	})
 }
@@ -696,6 +713,10 @@ func TestHandshakeWithTransportEarlyData(t *testing.T) {
 		require.Equal(t, expectedProto != "", initConn.connectionState.UsedEarlyMuxerNegotiation)
 		require.Equal(t, expectedProto, respConn.connectionState.StreamMultiplexer)
 		require.Equal(t, expectedProto != "", respConn.connectionState.UsedEarlyMuxerNegotiation)
+		// the muxer list alone isn't early data
+		require.False(t, initConn.connectionState.UsedEarlyData)
+		require.False(t, respConn.connectionState.UsedEarlyData)
+		require.Equal(t, "25519_ChaChaPoly_SHA256", initConn.connectionState.CipherSuite)
 
 		initData := []byte("Test data for noise transport")
 		_, err := initConn.Write(initData)
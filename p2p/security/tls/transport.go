@@ -155,7 +155,8 @@ func (t *Transport) setupConn(tlsConn *tls.Conn, remotePubKey ci.PubKey) (sec.Se
 		return nil, err
 	}
 
-	nextProto := tlsConn.ConnectionState().NegotiatedProtocol
+	cs := tlsConn.ConnectionState()
+	nextProto := cs.NegotiatedProtocol
 	// The special ALPN extension value "libp2p" is used by libp2p versions
 	// that don't support early muxer negotiation. If we see this sepcial
 	// value selected, that means we are handshaking with a version that does
@@ -173,6 +174,7 @@ func (t *Transport) setupConn(tlsConn *tls.Conn, remotePubKey ci.PubKey) (sec.Se
 		connectionState: network.ConnectionState{
 			StreamMultiplexer:         protocol.ID(nextProto),
 			UsedEarlyMuxerNegotiation: nextProto != "",
+			CipherSuite:               tls.CipherSuiteName(cs.CipherSuite),
 		},
 	}, nil
 }
@@ -253,6 +253,8 @@ func TestHandshakeWithNextProtoSucceeds(t *testing.T) {
 		require.True(t, serverConn.RemotePublicKey().Equals(clientKey.GetPublic()), "client public key mismatch")
 		require.Equal(t, expectedMuxer, clientConn.ConnState().StreamMultiplexer)
 		require.Equal(t, expectedMuxer != "", clientConn.ConnState().UsedEarlyMuxerNegotiation)
+		require.NotEmpty(t, clientConn.ConnState().CipherSuite)
+		require.Equal(t, clientConn.ConnState().CipherSuite, serverConn.ConnState().CipherSuite)
 		// exchange some data
 		_, err = serverConn.Write([]byte("foobar"))
 		require.NoError(t, err)
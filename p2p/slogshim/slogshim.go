@@ -0,0 +1,125 @@
+// Package slogshim provides log/slog loggers for libp2p subsystems.
+//
+// By default, subsystems log to go-log (github.com/ipfs/go-log/v2), using
+// the log levels configured there (e.g. using GOLOG_LOG_LEVEL). Embedders
+// can route the logs into their own logging pipeline instead by passing a
+// *slog.Logger to the libp2p.Logger option. In that case, the name of the
+// subsystem is added to every record as the "system" attribute.
+//
+// Subsystems use the following keys consistently:
+//
+//   - "peer": the peer ID of the remote peer
+//   - "conn": the ID of the connection
+//   - "transport": the transport of the connection, e.g. tcp or quic-v1
+//   - "protocol": the protocol ID of a stream
+//   - "addr": a multiaddr
+//   - "error": the error that occurred
+package slogshim
+
+import (
+	"context"
+	"log/slog"
+	"runtime"
+
+	logging "github.com/ipfs/go-log/v2"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Logger returns a logger that writes to the go-log logger of the given
+// subsystem.
+func Logger(system string) *slog.Logger {
+	return slog.New(&handler{logger: logging.Logger(system).Desugar()})
+}
+
+// ForSubsystem returns the logger a subsystem should use: l annotated with
+// the name of the subsystem if l is set, and the go-log logger of the
+// subsystem otherwise.
+func ForSubsystem(l *slog.Logger, system string) *slog.Logger {
+	if l == nil {
+		return Logger(system)
+	}
+	return l.With("system", system)
+}
+
+// handler is a slog.Handler writing to a zap logger.
+type handler struct {
+	logger *zap.Logger
+	fields []zap.Field
+	prefix string // the current group, including a trailing dot
+}
+
+var _ slog.Handler = &handler{}
+
+func zapLevel(l slog.Level) zapcore.Level {
+	switch {
+	case l >= slog.LevelError:
+		return zapcore.ErrorLevel
+	case l >= slog.LevelWarn:
+		return zapcore.WarnLevel
+	case l >= slog.LevelInfo:
+		return zapcore.InfoLevel
+	default:
+		return zapcore.DebugLevel
+	}
+}
+
+func (h *handler) Enabled(_ context.Context, l slog.Level) bool {
+	return h.logger.Core().Enabled(zapLevel(l))
+}
+
+func (h *handler) Handle(_ context.Context, r slog.Record) error {
+	ce := h.logger.Check(zapLevel(r.Level), r.Message)
+	if ce == nil {
+		return nil
+	}
+	// zap determines the caller itself, which would be this handler
+	if r.PC != 0 {
+		f, _ := runtime.CallersFrames([]uintptr{r.PC}).Next()
+		ce.Caller = zapcore.NewEntryCaller(f.PC, f.File, f.Line, true)
+	}
+	fields := make([]zap.Field, 0, len(h.fields)+r.NumAttrs())
+	fields = append(fields, h.fields...)
+	r.Attrs(func(a slog.Attr) bool {
+		fields = appendAttr(fields, h.prefix, a)
+		return true
+	})
+	ce.Write(fields...)
+	return nil
+}
+
+func (h *handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	fields := make([]zap.Field, 0, len(h.fields)+len(attrs))
+	fields = append(fields, h.fields...)
+	for _, a := range attrs {
+		fields = appendAttr(fields, h.prefix, a)
+	}
+	return &handler{logger: h.logger, fields: fields, prefix: h.prefix}
+}
+
+func (h *handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &handler{logger: h.logger, fields: h.fields, prefix: h.prefix + name + "."}
+}
+
+func appendAttr(fields []zap.Field, prefix string, a slog.Attr) []zap.Field {
+	v := a.Value.Resolve()
+	if v.Kind() == slog.KindGroup {
+		groupPrefix := prefix
+		// attributes of groups without a key are inlined
+		if a.Key != "" {
+			groupPrefix = prefix + a.Key + "."
+		}
+		for _, ga := range v.Group() {
+			fields = appendAttr(fields, groupPrefix, ga)
+		}
+		return fields
+	}
+	// empty attributes are ignored, as required by slog.Handler
+	if a.Key == "" && v.Any() == nil {
+		return fields
+	}
+	return append(fields, zap.Any(prefix+a.Key, v.Any()))
+}
@@ -0,0 +1,53 @@
+package slogshim
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestHandler(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	l := slog.New(&handler{logger: zap.New(core)})
+
+	l.Debug("not logged")
+	require.Zero(t, logs.Len())
+
+	l.With("peer", "foo").WithGroup("g").Info("msg", "error", "bar", slog.Group("sub", "a", 1))
+	l.Warn("warning", slog.Group("", "inlined", true))
+	entries := logs.AllUntimed()
+	require.Len(t, entries, 2)
+
+	require.Equal(t, "msg", entries[0].Message)
+	require.True(t, entries[0].Caller.Defined)
+	require.True(t, strings.HasSuffix(entries[0].Caller.File, "slogshim_test.go"), "unexpected caller: %s", entries[0].Caller.File)
+	require.Equal(t, zapcore.InfoLevel, entries[0].Level)
+	require.Equal(t, map[string]interface{}{
+		"peer":    "foo",
+		"g.error": "bar",
+		"g.sub.a": int64(1),
+	}, entries[0].ContextMap())
+
+	require.Equal(t, zapcore.WarnLevel, entries[1].Level)
+	require.Equal(t, map[string]interface{}{"inlined": true}, entries[1].ContextMap())
+}
+
+func TestForSubsystem(t *testing.T) {
+	var buf bytes.Buffer
+	l := ForSubsystem(slog.New(slog.NewJSONHandler(&buf, nil)), "swarm2")
+	l.Info("msg", "peer", "foo")
+
+	var rec map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &rec))
+	require.Equal(t, "swarm2", rec["system"])
+	require.Equal(t, "foo", rec["peer"])
+
+	require.NotNil(t, ForSubsystem(nil, "swarm2"))
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/libp2p/go-libp2p/core/protocol"
 	"github.com/libp2p/go-libp2p/p2p/net/swarm"
+	"github.com/libp2p/go-libp2p/p2p/test/transport/suite"
 
 	"github.com/libp2p/go-libp2p-testing/race"
 
@@ -40,8 +41,8 @@ func TestInterceptPeerDial(t *testing.T) {
 			defer ctrl.Finish()
 			connGater := NewMockConnectionGater(ctrl)
 
-			h1 := tc.HostGenerator(t, TransportTestCaseOpts{NoListen: true, ConnGater: connGater})
-			h2 := tc.HostGenerator(t, TransportTestCaseOpts{})
+			h1 := tc.HostGenerator(t, suite.TransportTestCaseOpts{NoListen: true, ConnGater: connGater})
+			h2 := tc.HostGenerator(t, suite.TransportTestCaseOpts{})
 			require.Len(t, h2.Addrs(), 1)
 
 			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
@@ -62,8 +63,8 @@ func TestInterceptAddrDial(t *testing.T) {
 			defer ctrl.Finish()
 			connGater := NewMockConnectionGater(ctrl)
 
-			h1 := tc.HostGenerator(t, TransportTestCaseOpts{NoListen: true, ConnGater: connGater})
-			h2 := tc.HostGenerator(t, TransportTestCaseOpts{})
+			h1 := tc.HostGenerator(t, suite.TransportTestCaseOpts{NoListen: true, ConnGater: connGater})
+			h2 := tc.HostGenerator(t, suite.TransportTestCaseOpts{})
 			require.Len(t, h2.Addrs(), 1)
 
 			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
@@ -87,8 +88,8 @@ func TestInterceptSecuredOutgoing(t *testing.T) {
 			defer ctrl.Finish()
 			connGater := NewMockConnectionGater(ctrl)
 
-			h1 := tc.HostGenerator(t, TransportTestCaseOpts{NoListen: true, ConnGater: connGater})
-			h2 := tc.HostGenerator(t, TransportTestCaseOpts{})
+			h1 := tc.HostGenerator(t, suite.TransportTestCaseOpts{NoListen: true, ConnGater: connGater})
+			h2 := tc.HostGenerator(t, suite.TransportTestCaseOpts{})
 			defer h1.Close()
 			defer h2.Close()
 			require.Len(t, h2.Addrs(), 1)
@@ -121,8 +122,8 @@ func TestInterceptUpgradedOutgoing(t *testing.T) {
 			defer ctrl.Finish()
 			connGater := NewMockConnectionGater(ctrl)
 
-			h1 := tc.HostGenerator(t, TransportTestCaseOpts{NoListen: true, ConnGater: connGater})
-			h2 := tc.HostGenerator(t, TransportTestCaseOpts{})
+			h1 := tc.HostGenerator(t, suite.TransportTestCaseOpts{NoListen: true, ConnGater: connGater})
+			h2 := tc.HostGenerator(t, suite.TransportTestCaseOpts{})
 			defer h1.Close()
 			defer h2.Close()
 			require.Len(t, h2.Addrs(), 1)
@@ -157,8 +158,8 @@ func TestInterceptAccept(t *testing.T) {
 			defer ctrl.Finish()
 			connGater := NewMockConnectionGater(ctrl)
 
-			h1 := tc.HostGenerator(t, TransportTestCaseOpts{NoListen: true})
-			h2 := tc.HostGenerator(t, TransportTestCaseOpts{ConnGater: connGater})
+			h1 := tc.HostGenerator(t, suite.TransportTestCaseOpts{NoListen: true})
+			h2 := tc.HostGenerator(t, suite.TransportTestCaseOpts{ConnGater: connGater})
 			defer h1.Close()
 			defer h2.Close()
 			require.Len(t, h2.Addrs(), 1)
@@ -202,8 +203,8 @@ func TestInterceptSecuredIncoming(t *testing.T) {
 			defer ctrl.Finish()
 			connGater := NewMockConnectionGater(ctrl)
 
-			h1 := tc.HostGenerator(t, TransportTestCaseOpts{NoListen: true})
-			h2 := tc.HostGenerator(t, TransportTestCaseOpts{ConnGater: connGater})
+			h1 := tc.HostGenerator(t, suite.TransportTestCaseOpts{NoListen: true})
+			h2 := tc.HostGenerator(t, suite.TransportTestCaseOpts{ConnGater: connGater})
 			defer h1.Close()
 			defer h2.Close()
 			require.Len(t, h2.Addrs(), 1)
@@ -235,8 +236,8 @@ func TestInterceptUpgradedIncoming(t *testing.T) {
 			defer ctrl.Finish()
 			connGater := NewMockConnectionGater(ctrl)
 
-			h1 := tc.HostGenerator(t, TransportTestCaseOpts{NoListen: true})
-			h2 := tc.HostGenerator(t, TransportTestCaseOpts{ConnGater: connGater})
+			h1 := tc.HostGenerator(t, suite.TransportTestCaseOpts{NoListen: true})
+			h2 := tc.HostGenerator(t, suite.TransportTestCaseOpts{ConnGater: connGater})
 			defer h1.Close()
 			defer h2.Close()
 			require.Len(t, h2.Addrs(), 1)
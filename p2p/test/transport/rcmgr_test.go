@@ -15,6 +15,7 @@ import (
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/libp2p/go-libp2p/p2p/protocol/identify"
 	"github.com/libp2p/go-libp2p/p2p/protocol/ping"
+	"github.com/libp2p/go-libp2p/p2p/test/transport/suite"
 
 	"github.com/stretchr/testify/require"
 	"go.uber.org/mock/gomock"
@@ -42,14 +43,14 @@ func TestResourceManagerIsUsed(t *testing.T) {
 					var expectedDir network.Direction
 					var expectedAddr interface{}
 					if testDialer {
-						listener = tc.HostGenerator(t, TransportTestCaseOpts{NoRcmgr: true})
-						dialer = tc.HostGenerator(t, TransportTestCaseOpts{NoListen: true, ResourceManager: rcmgr})
+						listener = tc.HostGenerator(t, suite.TransportTestCaseOpts{NoRcmgr: true})
+						dialer = tc.HostGenerator(t, suite.TransportTestCaseOpts{NoListen: true, ResourceManager: rcmgr})
 						expectedPeer = listener.ID()
 						expectedDir = network.DirOutbound
 						expectedAddr = listener.Addrs()[0]
 					} else {
-						listener = tc.HostGenerator(t, TransportTestCaseOpts{ResourceManager: rcmgr})
-						dialer = tc.HostGenerator(t, TransportTestCaseOpts{NoListen: true, NoRcmgr: true})
+						listener = tc.HostGenerator(t, suite.TransportTestCaseOpts{ResourceManager: rcmgr})
+						dialer = tc.HostGenerator(t, suite.TransportTestCaseOpts{NoListen: true, NoRcmgr: true})
 						expectedPeer = dialer.ID()
 						expectedDir = network.DirInbound
 						expectedAddr = gomock.Any()
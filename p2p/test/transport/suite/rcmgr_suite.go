@@ -0,0 +1,72 @@
+package suite
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	rcmgr "github.com/libp2p/go-libp2p/p2p/host/resource-manager"
+	"github.com/libp2p/go-libp2p/p2p/protocol/ping"
+
+	"github.com/stretchr/testify/require"
+)
+
+// SubtestResourceManagerScopesReleased tests that the transport accounts its
+// connections and streams with the resource manager on both sides, and that
+// all scopes are released once the connection is closed.
+func SubtestResourceManagerScopesReleased(t *testing.T, tc TransportTestCase) {
+	newRcmgr := func() network.ResourceManager {
+		r, err := rcmgr.NewResourceManager(rcmgr.NewFixedLimiter(rcmgr.InfiniteLimits))
+		require.NoError(t, err)
+		return r
+	}
+	listenerRcmgr := newRcmgr()
+	dialerRcmgr := newRcmgr()
+	listener := tc.HostGenerator(t, TransportTestCaseOpts{ResourceManager: listenerRcmgr})
+	dialer := tc.HostGenerator(t, TransportTestCaseOpts{NoListen: true, ResourceManager: dialerRcmgr})
+	defer listener.Close()
+	defer dialer.Close()
+
+	connect(t, dialer, listener)
+
+	systemStat := func(r network.ResourceManager) (stat network.ScopeStat) {
+		r.ViewSystem(func(s network.ResourceScope) error {
+			stat = s.Stat()
+			return nil
+		})
+		return stat
+	}
+	stat := systemStat(listenerRcmgr)
+	require.Equal(t, 1, stat.NumConnsInbound, "expected the listener to account the connection")
+	stat = systemStat(dialerRcmgr)
+	require.Equal(t, 1, stat.NumConnsOutbound, "expected the dialer to account the connection")
+
+	listener.SetStreamHandler("echo", func(s network.Stream) {
+		io.Copy(s, s)
+		s.Close()
+	})
+	const streamCount = 10
+	for i := 0; i < streamCount; i++ {
+		s, err := dialer.NewStream(context.Background(), listener.ID(), "echo")
+		require.NoError(t, err)
+		_, err = s.Write([]byte("hello"))
+		require.NoError(t, err)
+		require.NoError(t, s.CloseWrite())
+		b, err := io.ReadAll(s)
+		require.NoError(t, err)
+		require.Equal(t, "hello", string(b))
+		require.NoError(t, s.Close())
+	}
+	require.NoError(t, (<-ping.Ping(context.Background(), dialer, listener.ID())).Error)
+
+	require.NoError(t, dialer.Network().ClosePeer(listener.ID()))
+
+	for _, r := range []network.ResourceManager{listenerRcmgr, dialerRcmgr} {
+		require.Eventually(t, func() bool {
+			stat := systemStat(r)
+			return stat.NumConnsInbound+stat.NumConnsOutbound+stat.NumStreamsInbound+stat.NumStreamsOutbound+stat.NumFD == 0
+		}, 5*time.Second, 10*time.Millisecond, "expected all connection and stream scopes to be released")
+	}
+}
@@ -0,0 +1,450 @@
+package suite
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	rcmgr "github.com/libp2p/go-libp2p/p2p/host/resource-manager"
+
+	"github.com/stretchr/testify/require"
+)
+
+// SubtestLotsOfDataManyStreams tests sending a lot of data on multiple streams.
+func SubtestLotsOfDataManyStreams(t *testing.T, tc TransportTestCase) {
+	// Skip on windows because of https://github.com/libp2p/go-libp2p/issues/2341
+	if runtime.GOOS == "windows" {
+		t.Skip("Skipping on windows because of https://github.com/libp2p/go-libp2p/issues/2341")
+	}
+
+	// 64k buffer
+	const bufSize = 64 << 10
+	sendBuf := [bufSize]byte{}
+	const totalStreams = 500
+	const parallel = 8
+	// Total sends are > 20MiB
+	require.Greater(t, len(sendBuf)*totalStreams, 20<<20)
+	t.Log("Total sends:", len(sendBuf)*totalStreams)
+
+	// Fill with random bytes
+	_, err := rand.Read(sendBuf[:])
+	require.NoError(t, err)
+
+	h1 := tc.HostGenerator(t, TransportTestCaseOpts{})
+	h2 := tc.HostGenerator(t, TransportTestCaseOpts{NoListen: true})
+	defer h1.Close()
+	defer h2.Close()
+	start := time.Now()
+	defer func() {
+		t.Log("Total time:", time.Since(start))
+	}()
+
+	connect(t, h2, h1)
+
+	h1.SetStreamHandler("/big-ping", func(s network.Stream) {
+		io.Copy(s, s)
+		s.Close()
+	})
+
+	sem := make(chan struct{}, parallel)
+	var wg sync.WaitGroup
+	for i := 0; i < totalStreams; i++ {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			recvBuf := [bufSize]byte{}
+			defer func() { <-sem }()
+
+			s, err := h2.NewStream(context.Background(), h1.ID(), "/big-ping")
+			require.NoError(t, err)
+			defer s.Close()
+
+			_, err = s.Write(sendBuf[:])
+			require.NoError(t, err)
+			s.CloseWrite()
+
+			_, err = io.ReadFull(s, recvBuf[:])
+			require.NoError(t, err)
+			require.Equal(t, sendBuf, recvBuf)
+
+			_, err = s.Read([]byte{0})
+			require.ErrorIs(t, err, io.EOF)
+		}()
+	}
+
+	wg.Wait()
+}
+
+func SubtestManyStreams(t *testing.T, tc TransportTestCase) {
+	const streamCount = 128
+
+	h1 := tc.HostGenerator(t, TransportTestCaseOpts{NoRcmgr: true})
+	h2 := tc.HostGenerator(t, TransportTestCaseOpts{NoListen: true, NoRcmgr: true})
+	defer h1.Close()
+	defer h2.Close()
+
+	connect(t, h2, h1)
+
+	h1.SetStreamHandler("echo", func(s network.Stream) {
+		io.Copy(s, s)
+		s.CloseWrite()
+	})
+
+	streams := make([]network.Stream, streamCount)
+	for i := 0; i < streamCount; i++ {
+		s, err := h2.NewStream(context.Background(), h1.ID(), "echo")
+		require.NoError(t, err)
+		streams[i] = s
+	}
+
+	wg := sync.WaitGroup{}
+	wg.Add(streamCount)
+	errCh := make(chan error, 1)
+	for _, s := range streams {
+		go func(s network.Stream) {
+			defer wg.Done()
+
+			s.Write([]byte("hello"))
+			s.CloseWrite()
+			b, err := io.ReadAll(s)
+			if err == nil {
+				if !bytes.Equal(b, []byte("hello")) {
+					err = fmt.Errorf("received data does not match sent data")
+				}
+			}
+			if err != nil {
+				select {
+				case errCh <- err:
+				default:
+				}
+			}
+		}(s)
+	}
+	wg.Wait()
+	close(errCh)
+
+	require.NoError(t, <-errCh)
+	for _, s := range streams {
+		require.NoError(t, s.Close())
+	}
+}
+
+// SubtestMoreStreamsThanOurLimits tests handling more streams than our and the
+// peer's resource limits. It spawns 1024 Go routines that try to open a stream
+// and send and receive data. If they encounter an error they'll try again after
+// a sleep. If the transport is well behaved, eventually all Go routines will
+// have sent and received a message.
+func SubtestMoreStreamsThanOurLimits(t *testing.T, tc TransportTestCase) {
+	const streamCount = 1024
+
+	listenerLimits := rcmgr.PartialLimitConfig{
+		PeerDefault: rcmgr.ResourceLimits{
+			Streams:         32,
+			StreamsInbound:  16,
+			StreamsOutbound: 16,
+		},
+	}
+	r, err := rcmgr.NewResourceManager(rcmgr.NewFixedLimiter(listenerLimits.Build(rcmgr.DefaultLimits.AutoScale())))
+	require.NoError(t, err)
+	listener := tc.HostGenerator(t, TransportTestCaseOpts{ResourceManager: r})
+	dialer := tc.HostGenerator(t, TransportTestCaseOpts{NoListen: true, NoRcmgr: true})
+	defer listener.Close()
+	defer dialer.Close()
+
+	connect(t, dialer, listener)
+
+	var handledStreams atomic.Int32
+	var sawFirstErr atomic.Bool
+
+	workQueue := make(chan struct{}, streamCount)
+	for i := 0; i < streamCount; i++ {
+		workQueue <- struct{}{}
+	}
+	close(workQueue)
+
+	listener.SetStreamHandler("echo", func(s network.Stream) {
+		// Wait a bit so that we have more parallel streams open at the same time
+		time.Sleep(time.Millisecond * 10)
+		io.Copy(s, s)
+		s.Close()
+	})
+
+	wg := sync.WaitGroup{}
+	errCh := make(chan error, 1)
+	var completedStreams atomic.Int32
+
+	const maxWorkerCount = streamCount
+	workerCount := 4
+
+	var startWorker func(workerIdx int)
+	startWorker = func(workerIdx int) {
+		wg.Add(1)
+		defer wg.Done()
+		for {
+			_, ok := <-workQueue
+			if !ok {
+				return
+			}
+
+			// Inline function so we can use defer
+			func() {
+				var didErr bool
+				defer completedStreams.Add(1)
+				defer func() {
+					// Only the first worker adds more workers
+					if workerIdx == 0 && !didErr && !sawFirstErr.Load() {
+						nextWorkerCount := workerCount * 2
+						if nextWorkerCount < maxWorkerCount {
+							for i := workerCount; i < nextWorkerCount; i++ {
+								go startWorker(i)
+							}
+							workerCount = nextWorkerCount
+						}
+					}
+				}()
+
+				var s network.Stream
+				var err error
+				// maxRetries is an arbitrary retry amount if there's any error.
+				maxRetries := streamCount * 4
+				shouldRetry := func(err error) bool {
+					didErr = true
+					sawFirstErr.Store(true)
+					maxRetries--
+					if maxRetries == 0 || len(errCh) > 0 {
+						select {
+						case errCh <- errors.New("max retries exceeded"):
+						default:
+						}
+						return false
+					}
+					return true
+				}
+
+				for {
+					s, err = dialer.NewStream(context.Background(), listener.ID(), "echo")
+					if err != nil {
+						if shouldRetry(err) {
+							time.Sleep(50 * time.Millisecond)
+							continue
+						}
+						t.Logf("opening stream failed: %v", err)
+						return
+					}
+					err = func(s network.Stream) error {
+						defer s.Close()
+						err = s.SetDeadline(time.Now().Add(100 * time.Millisecond))
+						if err != nil {
+							return err
+						}
+
+						_, err = s.Write([]byte("hello"))
+						if err != nil {
+							return err
+						}
+
+						err = s.CloseWrite()
+						if err != nil {
+							return err
+						}
+
+						b, err := io.ReadAll(s)
+						if err != nil {
+							return err
+						}
+						if !bytes.Equal(b, []byte("hello")) {
+							return errors.New("received data does not match sent data")
+						}
+						handledStreams.Add(1)
+
+						return nil
+					}(s)
+					if err != nil && shouldRetry(err) {
+						time.Sleep(50 * time.Millisecond)
+						continue
+					}
+					return
+				}
+			}()
+		}
+	}
+
+	// Create any initial parallel workers
+	for i := 1; i < workerCount; i++ {
+		go startWorker(i)
+	}
+
+	// Start the first worker
+	startWorker(0)
+
+	wg.Wait()
+	close(errCh)
+
+	require.NoError(t, <-errCh)
+	require.Equal(t, streamCount, int(handledStreams.Load()))
+	require.True(t, sawFirstErr.Load(), "Expected to see an error from the peer")
+}
+
+func SubtestListenerStreamResets(t *testing.T, tc TransportTestCase) {
+	h1 := tc.HostGenerator(t, TransportTestCaseOpts{})
+	h2 := tc.HostGenerator(t, TransportTestCaseOpts{NoListen: true})
+	defer h1.Close()
+	defer h2.Close()
+
+	connect(t, h2, h1)
+
+	h1.SetStreamHandler("reset", func(s network.Stream) {
+		s.Reset()
+	})
+
+	s, err := h2.NewStream(context.Background(), h1.ID(), "reset")
+	if err != nil {
+		require.ErrorIs(t, err, network.ErrReset)
+		return
+	}
+
+	_, err = s.Read([]byte{0})
+	require.ErrorIs(t, err, network.ErrReset)
+}
+
+func SubtestDialerStreamResets(t *testing.T, tc TransportTestCase) {
+	h1 := tc.HostGenerator(t, TransportTestCaseOpts{})
+	h2 := tc.HostGenerator(t, TransportTestCaseOpts{NoListen: true})
+	defer h1.Close()
+	defer h2.Close()
+
+	connect(t, h2, h1)
+
+	errCh := make(chan error, 1)
+	acceptedCh := make(chan struct{}, 1)
+	h1.SetStreamHandler("echo", func(s network.Stream) {
+		acceptedCh <- struct{}{}
+		_, err := io.Copy(s, s)
+		errCh <- err
+	})
+
+	s, err := h2.NewStream(context.Background(), h1.ID(), "echo")
+	require.NoError(t, err)
+	s.Write([]byte{})
+	<-acceptedCh
+	s.Reset()
+	require.ErrorIs(t, <-errCh, network.ErrReset)
+}
+
+func SubtestStreamReadDeadline(t *testing.T, tc TransportTestCase) {
+	h1 := tc.HostGenerator(t, TransportTestCaseOpts{})
+	h2 := tc.HostGenerator(t, TransportTestCaseOpts{NoListen: true})
+	defer h1.Close()
+	defer h2.Close()
+
+	connect(t, h2, h1)
+
+	h1.SetStreamHandler("echo", func(s network.Stream) {
+		io.Copy(s, s)
+	})
+
+	s, err := h2.NewStream(context.Background(), h1.ID(), "echo")
+	require.NoError(t, err)
+	require.NoError(t, s.SetReadDeadline(time.Now().Add(100*time.Millisecond)))
+	_, err = s.Read([]byte{0})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "deadline")
+	var nerr net.Error
+	require.ErrorAs(t, err, &nerr, "expected a net.Error")
+	require.True(t, nerr.Timeout(), "expected net.Error.Timeout() == true")
+	// now test that the stream is still usable
+	s.SetReadDeadline(time.Time{})
+	_, err = s.Write([]byte("foobar"))
+	require.NoError(t, err)
+	b := make([]byte, 6)
+	_, err = s.Read(b)
+	require.Equal(t, "foobar", string(b))
+	require.NoError(t, err)
+}
+
+func SubtestReadWriteDeadlines(t *testing.T, tc TransportTestCase) {
+	// Send a lot of data so that writes have to flush (can't just buffer it all)
+	sendBuf := make([]byte, 10<<20)
+
+	listener := tc.HostGenerator(t, TransportTestCaseOpts{})
+	defer listener.Close()
+	dialer := tc.HostGenerator(t, TransportTestCaseOpts{NoListen: true})
+	defer dialer.Close()
+
+	connect(t, dialer, listener)
+
+	// This simply stalls
+	listener.SetStreamHandler("/stall", func(s network.Stream) {
+		time.Sleep(time.Hour)
+		s.Close()
+	})
+
+	t.Run("ReadDeadline", func(t *testing.T) {
+		s, err := dialer.NewStream(context.Background(), listener.ID(), "/stall")
+		require.NoError(t, err)
+		defer s.Close()
+
+		start := time.Now()
+		// Set a deadline
+		s.SetReadDeadline(time.Now().Add(10 * time.Millisecond))
+		buf := make([]byte, 1)
+		_, err = s.Read(buf)
+		require.Error(t, err)
+		var nerr net.Error
+		require.ErrorAs(t, err, &nerr)
+		require.True(t, nerr.Timeout())
+		require.Less(t, time.Since(start), 1*time.Second)
+	})
+
+	t.Run("WriteDeadline", func(t *testing.T) {
+		s, err := dialer.NewStream(context.Background(), listener.ID(), "/stall")
+		require.NoError(t, err)
+		defer s.Close()
+
+		// Set a deadline
+		s.SetWriteDeadline(time.Now().Add(10 * time.Millisecond))
+		start := time.Now()
+		_, err = s.Write(sendBuf)
+		require.Error(t, err)
+		require.True(t, err.(net.Error).Timeout())
+		require.Less(t, time.Since(start), 1*time.Second)
+	})
+
+	// Like the above, but with SetDeadline
+	t.Run("SetDeadline", func(t *testing.T) {
+		for _, op := range []string{"Read", "Write"} {
+			t.Run(op, func(t *testing.T) {
+				s, err := dialer.NewStream(context.Background(), listener.ID(), "/stall")
+				require.NoError(t, err)
+				defer s.Close()
+
+				// Set a deadline
+				s.SetDeadline(time.Now().Add(10 * time.Millisecond))
+				start := time.Now()
+
+				if op == "Read" {
+					buf := make([]byte, 1)
+					_, err = s.Read(buf)
+				} else {
+					_, err = s.Write(sendBuf)
+				}
+				require.Error(t, err)
+				var nerr net.Error
+				require.ErrorAs(t, err, &nerr)
+				require.True(t, nerr.Timeout())
+				require.Less(t, time.Since(start), 1*time.Second)
+			})
+		}
+	})
+}
@@ -0,0 +1,110 @@
+// Package suite contains the transport conformance tests go-libp2p runs against
+// all of its transports. Third-party transport implementations can use it to
+// validate that they behave the same way when used inside a libp2p host.
+//
+// Usage:
+//
+//	func TestMyTransport(t *testing.T) {
+//		suite.Run(t, suite.TransportTestCase{
+//			Name: "MyTransport",
+//			HostGenerator: func(t *testing.T, opts suite.TransportTestCaseOpts) host.Host {
+//				libp2pOpts := suite.Options(opts)
+//				libp2pOpts = append(libp2pOpts, libp2p.Transport(mytransport.New))
+//				if opts.NoListen {
+//					libp2pOpts = append(libp2pOpts, libp2p.NoListenAddrs)
+//				} else {
+//					libp2pOpts = append(libp2pOpts, libp2p.ListenAddrStrings("/ip4/127.0.0.1/udp/0/my-transport"))
+//				}
+//				h, err := libp2p.New(libp2pOpts...)
+//				require.NoError(t, err)
+//				return h
+//			},
+//		})
+//	}
+package suite
+
+import (
+	"testing"
+
+	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p/config"
+	"github.com/libp2p/go-libp2p/core/connmgr"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+)
+
+// TransportTestCase describes a transport configuration to run the suite against.
+type TransportTestCase struct {
+	Name string
+	// HostGenerator constructs a new host using the transport under test,
+	// honoring the given options.
+	HostGenerator func(t *testing.T, opts TransportTestCaseOpts) host.Host
+	// Skip lists the names of subtests that should be skipped for this
+	// transport, e.g. because the transport has a known limitation.
+	Skip []string
+}
+
+// TransportTestCaseOpts are the options a HostGenerator must honor.
+type TransportTestCaseOpts struct {
+	// NoListen means the host must not listen on any address.
+	NoListen bool
+	// NoRcmgr means the host must use a resource manager that doesn't impose any limits.
+	NoRcmgr bool
+	// ConnGater is the connection gater the host must use, if set.
+	ConnGater connmgr.ConnectionGater
+	// ResourceManager is the resource manager the host must use, if set.
+	ResourceManager network.ResourceManager
+}
+
+// Options converts the TransportTestCaseOpts into libp2p options.
+// It doesn't handle NoListen, since the listen addresses depend on the transport.
+func Options(opts TransportTestCaseOpts) []config.Option {
+	var libp2pOpts []libp2p.Option
+
+	if opts.NoRcmgr {
+		libp2pOpts = append(libp2pOpts, libp2p.ResourceManager(&network.NullResourceManager{}))
+	}
+	if opts.ConnGater != nil {
+		libp2pOpts = append(libp2pOpts, libp2p.ConnectionGater(opts.ConnGater))
+	}
+
+	if opts.ResourceManager != nil {
+		libp2pOpts = append(libp2pOpts, libp2p.ResourceManager(opts.ResourceManager))
+	}
+	return libp2pOpts
+}
+
+// Subtest is a single conformance test.
+type Subtest struct {
+	Name string
+	Run  func(t *testing.T, tc TransportTestCase)
+}
+
+// Subtests is the list of conformance tests run by Run.
+var Subtests = []Subtest{
+	{Name: "Ping", Run: SubtestPing},
+	{Name: "BigPing", Run: SubtestBigPing},
+	{Name: "LotsOfDataManyStreams", Run: SubtestLotsOfDataManyStreams},
+	{Name: "ManyStreams", Run: SubtestManyStreams},
+	{Name: "MoreStreamsThanOurLimits", Run: SubtestMoreStreamsThanOurLimits},
+	{Name: "ListenerStreamResets", Run: SubtestListenerStreamResets},
+	{Name: "DialerStreamResets", Run: SubtestDialerStreamResets},
+	{Name: "StreamReadDeadline", Run: SubtestStreamReadDeadline},
+	{Name: "ReadWriteDeadlines", Run: SubtestReadWriteDeadlines},
+	{Name: "DiscoverPeerIDFromSecurityNegotiation", Run: SubtestDiscoverPeerIDFromSecurityNegotiation},
+	{Name: "ResourceManagerScopesReleased", Run: SubtestResourceManagerScopesReleased},
+}
+
+// Run runs all Subtests against the given transport.
+func Run(t *testing.T, tc TransportTestCase) {
+	for _, st := range Subtests {
+		t.Run(st.Name, func(t *testing.T) {
+			for _, s := range tc.Skip {
+				if s == st.Name {
+					t.Skipf("skipped for %s", tc.Name)
+				}
+			}
+			st.Run(t, tc)
+		})
+	}
+}
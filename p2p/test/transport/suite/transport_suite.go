@@ -0,0 +1,149 @@
+package suite
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/sec"
+	"github.com/libp2p/go-libp2p/p2p/net/swarm"
+	"github.com/libp2p/go-libp2p/p2p/protocol/ping"
+
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/stretchr/testify/require"
+)
+
+// connect connects the dialer to the listener.
+func connect(t *testing.T, dialer, listener host.Host) {
+	t.Helper()
+	require.NoError(t, dialer.Connect(context.Background(), peer.AddrInfo{
+		ID:    listener.ID(),
+		Addrs: listener.Addrs(),
+	}))
+}
+
+func SubtestPing(t *testing.T, tc TransportTestCase) {
+	h1 := tc.HostGenerator(t, TransportTestCaseOpts{})
+	h2 := tc.HostGenerator(t, TransportTestCaseOpts{NoListen: true})
+	defer h1.Close()
+	defer h2.Close()
+
+	connect(t, h2, h1)
+
+	ctx := context.Background()
+	res := <-ping.Ping(ctx, h2, h1.ID())
+	require.NoError(t, res.Error)
+}
+
+func SubtestBigPing(t *testing.T, tc TransportTestCase) {
+	// 64k buffers
+	sendBuf := make([]byte, 64<<10)
+	recvBuf := make([]byte, 64<<10)
+	const totalSends = 64
+
+	// Fill with random bytes
+	_, err := rand.Read(sendBuf)
+	require.NoError(t, err)
+
+	h1 := tc.HostGenerator(t, TransportTestCaseOpts{})
+	h2 := tc.HostGenerator(t, TransportTestCaseOpts{NoListen: true})
+	defer h1.Close()
+	defer h2.Close()
+
+	connect(t, h2, h1)
+
+	h1.SetStreamHandler("/big-ping", func(s network.Stream) {
+		io.Copy(s, s)
+		s.Close()
+	})
+
+	errCh := make(chan error, 1)
+	allocs := testing.AllocsPerRun(10, func() {
+		s, err := h2.NewStream(context.Background(), h1.ID(), "/big-ping")
+		require.NoError(t, err)
+		defer s.Close()
+
+		go func() {
+			for i := 0; i < totalSends; i++ {
+				_, err := io.ReadFull(s, recvBuf)
+				if err != nil {
+					errCh <- err
+					return
+				}
+				if !bytes.Equal(sendBuf, recvBuf) {
+					errCh <- fmt.Errorf("received data does not match sent data")
+				}
+
+			}
+			_, err = s.Read([]byte{0})
+			errCh <- err
+		}()
+
+		for i := 0; i < totalSends; i++ {
+			s.Write(sendBuf)
+		}
+		s.CloseWrite()
+		require.ErrorIs(t, <-errCh, io.EOF)
+	})
+
+	if int(allocs) > (len(sendBuf)*totalSends)/4 {
+		t.Logf("Expected fewer allocs, got: %f", allocs)
+	}
+}
+
+func SubtestDiscoverPeerIDFromSecurityNegotiation(t *testing.T, tc TransportTestCase) {
+	// extracts the peerID of the dialed peer from the error
+	extractPeerIDFromError := func(inputErr error) (peer.ID, error) {
+		var dialErr *swarm.DialError
+		if !errors.As(inputErr, &dialErr) {
+			return "", inputErr
+		}
+		innerErr := dialErr.DialErrors[0].Cause
+
+		var peerIDMismatchErr sec.ErrPeerIDMismatch
+		if errors.As(innerErr, &peerIDMismatchErr) {
+			return peerIDMismatchErr.Actual, nil
+		}
+
+		return "", inputErr
+	}
+
+	h1 := tc.HostGenerator(t, TransportTestCaseOpts{})
+	h2 := tc.HostGenerator(t, TransportTestCaseOpts{NoListen: true})
+	defer h1.Close()
+	defer h2.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Use a bogus peer ID so that when we connect to the target we get an error telling
+	// us the targets real peer ID
+	bogusPeerId, err := peer.Decode("QmadAdJ3f63JyNs65X7HHzqDwV53ynvCcKtNFvdNaz3nhk")
+	require.NoError(t, err, "the hard coded bogus peerID is invalid")
+
+	ai := &peer.AddrInfo{
+		ID:    bogusPeerId,
+		Addrs: []ma.Multiaddr{h1.Addrs()[0]},
+	}
+
+	// Try connecting with the bogus peer ID
+	err = h2.Connect(ctx, *ai)
+	require.Error(t, err, "somehow we successfully connected to a bogus peerID!")
+
+	// Extract the actual peer ID from the error
+	newPeerId, err := extractPeerIDFromError(err)
+	require.NoError(t, err)
+	ai.ID = newPeerId
+	// Make sure the new ID is what we expected
+	require.Equal(t, h1.ID(), ai.ID)
+
+	// and just to double-check try connecting again to make sure it works
+	require.NoError(t, h2.Connect(ctx, *ai))
+}
@@ -2,6 +2,7 @@ package libp2pquic
 
 import (
 	"context"
+	"crypto/tls"
 
 	ic "github.com/libp2p/go-libp2p/core/crypto"
 	"github.com/libp2p/go-libp2p/core/network"
@@ -87,5 +88,10 @@ func (c *conn) ConnState() network.ConnectionState {
 	if _, err := c.LocalMultiaddr().ValueForProtocol(ma.P_QUIC); err == nil {
 		t = "quic"
 	}
-	return network.ConnectionState{Transport: t}
+	cs := c.quicConn.ConnectionState()
+	return network.ConnectionState{
+		Transport:     t,
+		CipherSuite:   tls.CipherSuiteName(cs.TLS.CipherSuite),
+		UsedEarlyData: cs.Used0RTT,
+	}
 }
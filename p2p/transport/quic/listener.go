@@ -148,3 +148,14 @@ func (l *listener) Close() error {
 func (l *listener) Addr() net.Addr {
 	return l.reuseListener.Addr()
 }
+
+// SocketInfo reports on the UDP socket of this listener.
+func (l *listener) SocketInfo() tpt.SocketInfo {
+	return l.reuseListener.SocketInfo()
+}
+
+// SetReusePort sets whether the UDP socket of this listener may be used for
+// dialing.
+func (l *listener) SetReusePort(enable bool) error {
+	return l.reuseListener.SetReusePort(enable)
+}
@@ -23,6 +23,8 @@ type virtualListener struct {
 }
 
 var _ tpt.Listener = &virtualListener{}
+var _ tpt.SocketInfoListener = &virtualListener{}
+var _ tpt.ReusePortListener = &virtualListener{}
 
 func (l *virtualListener) Multiaddr() ma.Multiaddr {
 	return l.listener.localMultiaddrs[l.version]
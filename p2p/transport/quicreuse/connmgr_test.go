@@ -259,3 +259,54 @@ func testListener(t *testing.T, enableReuseport bool) {
 
 	checkClosed(t, cm)
 }
+
+func TestListenerSocketInfo(t *testing.T) {
+	t.Run("with reuseport", func(t *testing.T) {
+		testListenerSocketInfo(t, true)
+	})
+
+	t.Run("without reuseport", func(t *testing.T) {
+		testListenerSocketInfo(t, false)
+	})
+}
+
+func testListenerSocketInfo(t *testing.T, enableReuseport bool) {
+	var opts []Option
+	if !enableReuseport {
+		opts = append(opts, DisableReuseport())
+	}
+	cm, err := NewConnManager(quic.StatelessResetKey{}, quic.TokenGeneratorKey{}, opts...)
+	require.NoError(t, err)
+	defer checkClosed(t, cm)
+	defer cm.Close()
+
+	_, tlsConf1 := getTLSConfForProto(t, "proto1")
+	ln1, err := cm.ListenQUIC(ma.StringCast("/ip4/127.0.0.1/udp/0/quic-v1"), tlsConf1, nil)
+	require.NoError(t, err)
+	defer ln1.Close()
+
+	info := ln1.SocketInfo()
+	require.Equal(t, ln1.Addr(), info.LocalAddr)
+	require.Equal(t, enableReuseport, info.ReusePort)
+	require.False(t, info.PacketInfo) // only enabled for unspecified addresses
+	require.False(t, info.Shared)
+
+	_, tlsConf2 := getTLSConfForProto(t, "proto2")
+	ln2, err := cm.ListenQUIC(
+		ma.StringCast(fmt.Sprintf("/ip4/127.0.0.1/udp/%d/quic-v1", ln1.Addr().(*net.UDPAddr).Port)),
+		tlsConf2,
+		nil,
+	)
+	require.NoError(t, err)
+	defer ln2.Close()
+	require.True(t, ln1.SocketInfo().Shared)
+	require.True(t, ln2.SocketInfo().Shared)
+
+	if !enableReuseport {
+		require.Error(t, ln1.SetReusePort(false))
+		return
+	}
+	require.NoError(t, ln1.SetReusePort(false))
+	// the socket is shared, so this applies to both listeners
+	require.False(t, ln2.SocketInfo().ReusePort)
+}
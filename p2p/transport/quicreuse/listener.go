@@ -19,6 +19,10 @@ type Listener interface {
 	Accept(context.Context) (quic.Connection, error)
 	Addr() net.Addr
 	Multiaddrs() []ma.Multiaddr
+	// SocketInfo reports on the UDP socket the listener is bound to.
+	SocketInfo() transport.SocketInfo
+	// SetReusePort sets whether the UDP socket may be used for dialing.
+	SetReusePort(bool) error
 	io.Closer
 }
 
@@ -104,7 +108,7 @@ func (l *quicListener) Add(tlsConf *tls.Config, allowWindowIncrease func(conn qu
 		}
 	}
 
-	ln := newSingleListener(l.l.Addr(), l.addrs, func() {
+	ln := newSingleListener(l, l.l.Addr(), l.addrs, func() {
 		l.protocolsMu.Lock()
 		for _, proto := range tlsConf.NextProtos {
 			delete(l.protocols, proto)
@@ -122,6 +126,24 @@ func (l *quicListener) Add(tlsConf *tls.Config, allowWindowIncrease func(conn qu
 	return ln, nil
 }
 
+// socketInfo reports on the socket. It's shared if more than one listener
+// (i.e. ALPN set) was added.
+func (l *quicListener) socketInfo() transport.SocketInfo {
+	info := l.transport.SocketInfo()
+	l.protocolsMu.Lock()
+	defer l.protocolsMu.Unlock()
+	var first *listener
+	for _, conf := range l.protocols {
+		if first == nil {
+			first = conf.ln
+		} else if conf.ln != first {
+			info.Shared = true
+			break
+		}
+	}
+	return info
+}
+
 func (l *quicListener) Run() error {
 	defer close(l.running)
 	defer l.transport.DecreaseCount()
@@ -156,6 +178,7 @@ const queueLen = 16
 
 // A listener for a single ALPN protocol (set).
 type listener struct {
+	parent            *quicListener
 	queue             chan quic.Connection
 	acceptLoopRunning chan struct{}
 	addr              net.Addr
@@ -166,8 +189,9 @@ type listener struct {
 
 var _ Listener = &listener{}
 
-func newSingleListener(addr net.Addr, addrs []ma.Multiaddr, remove func(), running chan struct{}) *listener {
+func newSingleListener(parent *quicListener, addr net.Addr, addrs []ma.Multiaddr, remove func(), running chan struct{}) *listener {
 	return &listener{
+		parent:            parent,
 		queue:             make(chan quic.Connection, queueLen),
 		acceptLoopRunning: running,
 		remove:            remove,
@@ -184,6 +208,14 @@ func (l *listener) add(c quic.Connection) {
 	}
 }
 
+func (l *listener) SocketInfo() transport.SocketInfo {
+	return l.parent.socketInfo()
+}
+
+func (l *listener) SetReusePort(enable bool) error {
+	return l.parent.transport.SetReusePort(enable)
+}
+
 func (l *listener) Accept(ctx context.Context) (quic.Connection, error) {
 	select {
 	case <-ctx.Done():
@@ -3,10 +3,14 @@ package quicreuse
 import (
 	"context"
 	"crypto/tls"
+	"errors"
 	"net"
+	"runtime"
 	"sync"
 	"time"
 
+	"github.com/libp2p/go-libp2p/core/transport"
+
 	"github.com/google/gopacket/routing"
 	"github.com/libp2p/go-netroute"
 	"github.com/quic-go/quic-go"
@@ -24,6 +28,11 @@ type refCountedQuicTransport interface {
 	DecreaseCount()
 	IncreaseCount()
 
+	// SocketInfo reports on the UDP socket.
+	SocketInfo() transport.SocketInfo
+	// SetReusePort sets whether the socket may be used for dialing.
+	SetReusePort(bool) error
+
 	Dial(ctx context.Context, addr net.Addr, tlsConf *tls.Config, conf *quic.Config) (quic.Connection, error)
 	Listen(tlsConf *tls.Config, conf *quic.Config) (*quic.Listener, error)
 }
@@ -54,6 +63,17 @@ func (c *singleOwnerTransport) WriteTo(b []byte, addr net.Addr) (int, error) {
 	return c.Transport.WriteTo(b, addr)
 }
 
+func (c *singleOwnerTransport) SocketInfo() transport.SocketInfo {
+	return transport.SocketInfo{
+		LocalAddr:  c.LocalAddr(),
+		PacketInfo: packetInfoEnabled(c.packetConn),
+	}
+}
+
+func (c *singleOwnerTransport) SetReusePort(bool) error {
+	return errors.New("reuseport is disabled")
+}
+
 // Constant. Defined as variables to simplify testing.
 var (
 	garbageCollectInterval = 30 * time.Second
@@ -69,6 +89,8 @@ type refcountedTransport struct {
 	mutex       sync.Mutex
 	refCount    int
 	unusedSince time.Time
+	// dialDisabled is set if the transport must not be used for dialing.
+	dialDisabled bool
 }
 
 func (c *refcountedTransport) IncreaseCount() {
@@ -101,6 +123,46 @@ func (c *refcountedTransport) DecreaseCount() {
 	c.mutex.Unlock()
 }
 
+func (c *refcountedTransport) SocketInfo() transport.SocketInfo {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return transport.SocketInfo{
+		LocalAddr:  c.LocalAddr(),
+		ReusePort:  !c.dialDisabled,
+		PacketInfo: packetInfoEnabled(c.packetConn),
+	}
+}
+
+func (c *refcountedTransport) SetReusePort(enable bool) error {
+	c.mutex.Lock()
+	c.dialDisabled = !enable
+	c.mutex.Unlock()
+	return nil
+}
+
+func (c *refcountedTransport) canDial() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return !c.dialDisabled
+}
+
+// packetInfoEnabled returns whether quic-go enables IP_PKTINFO on conn. It
+// does so for UDP sockets bound to the unspecified address, on the platforms
+// that support it.
+func packetInfoEnabled(conn net.PacketConn) bool {
+	switch runtime.GOOS {
+	case "linux", "darwin", "freebsd":
+	default:
+		return false
+	}
+	udpConn, ok := conn.(*net.UDPConn)
+	if !ok {
+		return false
+	}
+	addr, ok := udpConn.LocalAddr().(*net.UDPAddr)
+	return ok && addr.IP.IsUnspecified()
+}
+
 func (c *refcountedTransport) ShouldGarbageCollect(now time.Time) bool {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
@@ -238,7 +300,9 @@ func (r *reuse) transportForDialLocked(network string, source *net.IP) (*refcoun
 		if trs, ok := r.unicast[source.String()]; ok {
 			// ... we don't care which port we're dialing from. Just use the first.
 			for _, tr := range trs {
-				return tr, nil
+				if tr.canDial() {
+					return tr, nil
+				}
 			}
 		}
 	}
@@ -246,7 +310,9 @@ func (r *reuse) transportForDialLocked(network string, source *net.IP) (*refcoun
 	// Use a transport listening on 0.0.0.0 (or ::).
 	// Again, we don't care about the port number.
 	for _, tr := range r.globalListeners {
-		return tr, nil
+		if tr.canDial() {
+			return tr, nil
+		}
 	}
 
 	// Use a transport we've previously dialed from
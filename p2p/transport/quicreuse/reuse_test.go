@@ -116,6 +116,33 @@ func TestReuseConnectionWhenDialing(t *testing.T) {
 	require.Equal(t, 2, conn.GetCount())
 }
 
+func TestReuseDisabledForDialing(t *testing.T) {
+	reuse := newReuse(nil, nil)
+	cleanup(t, reuse)
+
+	addr, err := net.ResolveUDPAddr("udp4", "0.0.0.0:0")
+	require.NoError(t, err)
+	lconn, err := reuse.TransportForListen("udp4", addr)
+	require.NoError(t, err)
+	require.True(t, lconn.SocketInfo().ReusePort)
+	require.NoError(t, lconn.SetReusePort(false))
+	require.False(t, lconn.SocketInfo().ReusePort)
+
+	// dial
+	raddr, err := net.ResolveUDPAddr("udp4", "1.1.1.1:1234")
+	require.NoError(t, err)
+	conn, err := reuse.TransportForDial("udp4", raddr)
+	require.NoError(t, err)
+	require.NotEqual(t, lconn, conn)
+	require.Equal(t, 1, lconn.GetCount())
+
+	require.NoError(t, lconn.SetReusePort(true))
+	conn, err = reuse.TransportForDial("udp4", raddr)
+	require.NoError(t, err)
+	require.Equal(t, lconn, conn)
+	require.Equal(t, 2, lconn.GetCount())
+}
+
 func TestReuseConnectionWhenListening(t *testing.T) {
 	reuse := newReuse(nil, nil)
 	cleanup(t, reuse)
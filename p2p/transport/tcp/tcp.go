@@ -245,14 +245,47 @@ func (t *TcpTransport) maListen(laddr ma.Multiaddr) (manet.Listener, error) {
 
 // Listen listens on the given multiaddr.
 func (t *TcpTransport) Listen(laddr ma.Multiaddr) (transport.Listener, error) {
-	list, err := t.maListen(laddr)
+	raw, err := t.maListen(laddr)
 	if err != nil {
 		return nil, err
 	}
+	list := raw
 	if t.enableMetrics {
 		list = newTracingListener(&tcpListener{list, 0})
 	}
-	return t.upgrader.UpgradeListener(t, list), nil
+	return &listener{Listener: t.upgrader.UpgradeListener(t, list), raw: raw}, nil
+}
+
+// reusePortListener is implemented by the listeners of the reuseport package.
+type reusePortListener interface {
+	ReusePort() bool
+	SetReusePort(bool)
+}
+
+// listener reports on the socket of the underlying TCP listener.
+type listener struct {
+	transport.Listener
+	raw manet.Listener
+}
+
+var _ transport.SocketInfoListener = &listener{}
+var _ transport.ReusePortListener = &listener{}
+
+func (l *listener) SocketInfo() transport.SocketInfo {
+	info := transport.SocketInfo{LocalAddr: l.raw.Addr()}
+	if rl, ok := l.raw.(reusePortListener); ok {
+		info.ReusePort = rl.ReusePort()
+	}
+	return info
+}
+
+func (l *listener) SetReusePort(enable bool) error {
+	rl, ok := l.raw.(reusePortListener)
+	if !ok {
+		return errors.New("listener doesn't use reuseport")
+	}
+	rl.SetReusePort(enable)
+	return nil
 }
 
 // Protocols returns the list of terminal protocols this transport can dial.
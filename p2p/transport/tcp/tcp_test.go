@@ -196,6 +196,39 @@ func TestDialWithUpdates(t *testing.T) {
 	require.Error(t, err)
 }
 
+func TestListenerSocketInfo(t *testing.T) {
+	_, ia := makeInsecureMuxer(t)
+	ua, err := tptu.New(ia, muxers, nil, nil, nil)
+	require.NoError(t, err)
+
+	for _, reuse := range []bool{true, false} {
+		var opts []Option
+		if !reuse {
+			opts = append(opts, DisableReuseport())
+		}
+		ta, err := NewTCPTransport(ua, nil, opts...)
+		require.NoError(t, err)
+		ln, err := ta.Listen(ma.StringCast("/ip4/127.0.0.1/tcp/0"))
+		require.NoError(t, err)
+		defer ln.Close()
+
+		info := ln.(transport.SocketInfoListener).SocketInfo()
+		require.Equal(t, ln.Addr(), info.LocalAddr)
+		require.Equal(t, ta.UseReuseport(), info.ReusePort)
+		require.False(t, info.Shared)
+
+		rl := ln.(transport.ReusePortListener)
+		if !ta.UseReuseport() {
+			require.Error(t, rl.SetReusePort(false))
+			continue
+		}
+		require.NoError(t, rl.SetReusePort(false))
+		require.False(t, ln.(transport.SocketInfoListener).SocketInfo().ReusePort)
+		require.NoError(t, rl.SetReusePort(true))
+		require.True(t, ln.(transport.SocketInfoListener).SocketInfo().ReusePort)
+	}
+}
+
 func makeInsecureMuxer(t *testing.T) (peer.ID, []sec.SecureTransport) {
 	t.Helper()
 	priv, _, err := crypto.GenerateKeyPair(crypto.Ed25519, 256)
@@ -24,6 +24,9 @@ type Conn struct {
 	reader             io.Reader
 	closeOnce          sync.Once
 
+	// compressionSpan holds the memory reserved for compression, if enabled
+	compressionSpan network.ResourceScopeSpan
+
 	readLock, writeLock sync.Mutex
 }
 
@@ -38,6 +41,14 @@ func NewConn(raw *ws.Conn, secure bool) *Conn {
 	}
 }
 
+// enableCompression is called when per-message deflate was negotiated.
+// span is released when the connection is closed.
+func (c *Conn) enableCompression(level int, span network.ResourceScopeSpan) {
+	c.compressionSpan = span
+	// the level was validated by WithCompression
+	_ = c.Conn.SetCompressionLevel(level)
+}
+
 func (c *Conn) Read(b []byte) (int, error) {
 	c.readLock.Lock()
 	defer c.readLock.Unlock()
@@ -111,6 +122,9 @@ func (c *Conn) Close() error {
 			time.Now().Add(GracefulCloseTimeout),
 		)
 		err2 := c.Conn.Close()
+		if c.compressionSpan != nil {
+			c.compressionSpan.Done()
+		}
 		switch {
 		case err1 != nil:
 			err = err1
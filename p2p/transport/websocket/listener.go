@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"sync"
 
+	"github.com/libp2p/go-libp2p/core/network"
 	"github.com/libp2p/go-libp2p/core/transport"
 
 	ma "github.com/multiformats/go-multiaddr"
@@ -23,6 +24,10 @@ type listener struct {
 
 	laddr ma.Multiaddr
 
+	// reserveCompressionMemory is set if compression is enabled
+	reserveCompressionMemory func() network.ResourceScopeSpan
+	compressionLevel         int
+
 	incoming chan *Conn
 
 	closeOnce sync.Once
@@ -100,14 +105,27 @@ func (l *listener) serve() {
 }
 
 func (l *listener) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	c, err := upgrader.Upgrade(w, r, nil)
+	u := upgrader
+	var compressionSpan network.ResourceScopeSpan
+	if l.reserveCompressionMemory != nil && offersCompression(r.Header) {
+		compressionSpan = l.reserveCompressionMemory()
+		u.EnableCompression = compressionSpan != nil
+	}
+	wc, err := u.Upgrade(w, r, nil)
 	if err != nil {
 		// The upgrader writes a response for us.
+		if compressionSpan != nil {
+			compressionSpan.Done()
+		}
 		return
 	}
 
+	c := NewConn(wc, l.isWss)
+	if compressionSpan != nil {
+		c.enableCompression(l.compressionLevel, compressionSpan)
+	}
 	select {
-	case l.incoming <- NewConn(c, l.isWss):
+	case l.incoming <- c:
 	case <-l.closed:
 		c.Close()
 	}
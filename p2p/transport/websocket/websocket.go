@@ -2,10 +2,13 @@
 package websocket
 
 import (
+	"compress/flate"
 	"context"
 	"crypto/tls"
+	"fmt"
 	"net"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/libp2p/go-libp2p/core/network"
@@ -80,6 +83,25 @@ func WithTLSConfig(conf *tls.Config) Option {
 	}
 }
 
+// WithCompression enables per-message deflate compression (RFC 7692) at the
+// given compression level (see compress/flate), if the peer supports it.
+// This trades CPU and memory for bandwidth, and is only useful for compressible
+// application data.
+//
+// The memory used by the compressor is reserved with the resource manager,
+// at low priority. Connections fall back to no compression if the reservation
+// fails.
+func WithCompression(level int) Option {
+	return func(t *WebsocketTransport) error {
+		if level < flate.HuffmanOnly || level > flate.BestCompression {
+			return fmt.Errorf("invalid compression level: %d", level)
+		}
+		t.compression = true
+		t.compressionLevel = level
+		return nil
+	}
+}
+
 // WebsocketTransport is the actual go-libp2p transport
 type WebsocketTransport struct {
 	upgrader transport.Upgrader
@@ -87,6 +109,9 @@ type WebsocketTransport struct {
 
 	tlsClientConf *tls.Config
 	tlsConf       *tls.Config
+
+	compression      bool
+	compressionLevel int
 }
 
 var _ transport.Transport = (*WebsocketTransport)(nil)
@@ -188,6 +213,11 @@ func (t *WebsocketTransport) maDial(ctx context.Context, raddr ma.Multiaddr) (ma
 	}
 	isWss := wsurl.Scheme == "wss"
 	dialer := ws.Dialer{HandshakeTimeout: 30 * time.Second}
+	var compressionSpan network.ResourceScopeSpan
+	if t.compression {
+		compressionSpan = t.reserveCompressionMemory()
+		dialer.EnableCompression = compressionSpan != nil
+	}
 	if isWss {
 		sni := ""
 		sni, err = raddr.ValueForProtocol(ma.P_SNI)
@@ -215,14 +245,25 @@ func (t *WebsocketTransport) maDial(ctx context.Context, raddr ma.Multiaddr) (ma
 		}
 	}
 
-	wscon, _, err := dialer.DialContext(ctx, wsurl.String(), nil)
+	wscon, resp, err := dialer.DialContext(ctx, wsurl.String(), nil)
 	if err != nil {
+		if compressionSpan != nil {
+			compressionSpan.Done()
+		}
 		return nil, err
 	}
 
-	mnc, err := manet.WrapNetConn(NewConn(wscon, isWss))
+	c := NewConn(wscon, isWss)
+	if compressionSpan != nil {
+		if offersCompression(resp.Header) {
+			c.enableCompression(t.compressionLevel, compressionSpan)
+		} else {
+			compressionSpan.Done()
+		}
+	}
+	mnc, err := manet.WrapNetConn(c)
 	if err != nil {
-		wscon.Close()
+		c.Close()
 		return nil, err
 	}
 	return mnc, nil
@@ -233,10 +274,57 @@ func (t *WebsocketTransport) maListen(a ma.Multiaddr) (manet.Listener, error) {
 	if err != nil {
 		return nil, err
 	}
+	if t.compression {
+		l.compressionLevel = t.compressionLevel
+		l.reserveCompressionMemory = t.reserveCompressionMemory
+	}
 	go l.serve()
 	return l, nil
 }
 
+// compressionMemory estimates the memory used by the deflate state of a
+// connection. gorilla/websocket pools compressors, so this is an upper bound.
+func compressionMemory(level int) int {
+	if level > flate.BestSpeed {
+		return 1<<20 + 64<<10
+	}
+	return 256 << 10
+}
+
+// reserveCompressionMemory reserves the memory needed to compress a
+// connection with the system scope. It returns nil if the resource manager
+// doesn't allow it.
+func (t *WebsocketTransport) reserveCompressionMemory() network.ResourceScopeSpan {
+	var span network.ResourceScopeSpan
+	err := t.rcmgr.ViewSystem(func(s network.ResourceScope) error {
+		var err error
+		span, err = s.BeginSpan()
+		if err != nil {
+			return err
+		}
+		if err := span.ReserveMemory(compressionMemory(t.compressionLevel), network.ReservationPriorityLow); err != nil {
+			span.Done()
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil
+	}
+	return span
+}
+
+// offersCompression says if the WebSocket handshake headers contain the
+// per-message deflate extension.
+func offersCompression(h http.Header) bool {
+	for _, ext := range h.Values("Sec-WebSocket-Extensions") {
+		if strings.Contains(ext, "permessage-deflate") {
+			return true
+		}
+	}
+	return false
+}
+
 func (t *WebsocketTransport) Listen(a ma.Multiaddr) (transport.Listener, error) {
 	malist, err := t.maListen(a)
 	if err != nil {
@@ -1,6 +1,7 @@
 package websocket
 
 import (
+	"compress/flate"
 	"context"
 	"crypto/ecdsa"
 	"crypto/elliptic"
@@ -522,6 +523,79 @@ func TestWriteZero(t *testing.T) {
 	}
 }
 
+type denyingResourceManager struct {
+	network.NullResourceManager
+}
+
+func (*denyingResourceManager) ViewSystem(func(network.ResourceScope) error) error {
+	return network.ErrResourceLimitExceeded
+}
+
+func TestCompression(t *testing.T) {
+	for _, tc := range []struct {
+		name             string
+		listenerOpts     []Option
+		dialerOpts       []Option
+		dialerRcmgr      network.ResourceManager
+		expectCompressed bool
+	}{
+		{name: "enabled", listenerOpts: []Option{WithCompression(flate.BestSpeed)}, dialerOpts: []Option{WithCompression(flate.BestSpeed)}, expectCompressed: true},
+		{name: "listener only", listenerOpts: []Option{WithCompression(flate.BestSpeed)}},
+		{name: "dialer only", dialerOpts: []Option{WithCompression(flate.BestSpeed)}},
+		{name: "out of memory", listenerOpts: []Option{WithCompression(flate.BestSpeed)}, dialerOpts: []Option{WithCompression(flate.BestSpeed)}, dialerRcmgr: &denyingResourceManager{}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, u := newUpgrader(t)
+			ltpt, err := New(u, &network.NullResourceManager{}, tc.listenerOpts...)
+			require.NoError(t, err)
+			rcmgr := tc.dialerRcmgr
+			if rcmgr == nil {
+				rcmgr = &network.NullResourceManager{}
+			}
+			dtpt, err := New(u, rcmgr, tc.dialerOpts...)
+			require.NoError(t, err)
+
+			ml, err := ltpt.maListen(ma.StringCast("/ip4/127.0.0.1/tcp/0/ws"))
+			require.NoError(t, err)
+			defer ml.Close()
+			l := ml.(*listener)
+
+			msg := []byte(strings.Repeat("compress me ", 1000))
+			errCh := make(chan error, 1)
+			go func() {
+				c, err := dtpt.maDial(context.Background(), l.Multiaddr())
+				if err != nil {
+					errCh <- err
+					return
+				}
+				defer c.Close()
+				_, err = c.Write(msg)
+				errCh <- err
+			}()
+
+			var c *Conn
+			select {
+			case c = <-l.incoming:
+			case <-time.After(5 * time.Second):
+				t.Fatal("timeout waiting for connection")
+			}
+			defer c.Close()
+			require.Equal(t, tc.expectCompressed, c.compressionSpan != nil)
+			b := make([]byte, len(msg))
+			_, err = io.ReadFull(c, b)
+			require.NoError(t, err)
+			require.Equal(t, msg, b)
+			require.NoError(t, <-errCh)
+		})
+	}
+}
+
+func TestCompressionInvalidLevel(t *testing.T) {
+	_, u := newUpgrader(t)
+	_, err := New(u, nil, WithCompression(42))
+	require.Error(t, err)
+}
+
 func TestResolveMultiaddr(t *testing.T) {
 	// map[unresolved]resolved
 	testCases := map[string]string{
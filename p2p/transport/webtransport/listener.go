@@ -89,6 +89,8 @@ type listener struct {
 }
 
 var _ tpt.Listener = &listener{}
+var _ tpt.SocketInfoListener = &listener{}
+var _ tpt.ReusePortListener = &listener{}
 
 func newListener(reuseListener quicreuse.Listener, t *transport, isStaticTLSConf bool) (tpt.Listener, error) {
 	localMultiaddr, err := toWebtransportMultiaddr(reuseListener.Addr())
@@ -281,6 +283,14 @@ func (l *listener) Multiaddr() ma.Multiaddr {
 	return l.multiaddr.Encapsulate(l.transport.certManager.AddrComponent())
 }
 
+func (l *listener) SocketInfo() tpt.SocketInfo {
+	return l.reuseListener.SocketInfo()
+}
+
+func (l *listener) SetReusePort(enable bool) error {
+	return l.reuseListener.SetReusePort(enable)
+}
+
 func (l *listener) Close() error {
 	l.ctxCancel()
 	l.reuseListener.Close()
@@ -0,0 +1,276 @@
+package libp2p
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	rcmgr "github.com/libp2p/go-libp2p/p2p/host/resource-manager"
+	"github.com/libp2p/go-libp2p/p2p/metricshelper"
+	"github.com/libp2p/go-libp2p/p2p/net/dnsresolver"
+
+	madns "github.com/multiformats/go-multiaddr-dns"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Shared holds infrastructure that multiple hosts running in the same process
+// can share: a resource manager, a caching DNS resolver and a Prometheus
+// registerer.
+//
+// Use Shared.Option to construct hosts on top of it:
+//
+//	shared, err := libp2p.NewShared()
+//	// ...
+//	defer shared.Close()
+//	h1, err := libp2p.New(shared.Option(), libp2p.Identity(k1))
+//	h2, err := libp2p.New(shared.Option(), libp2p.Identity(k2))
+//
+// The resource manager limits apply to all hosts together. Closing a host
+// doesn't close the shared resource manager; it is closed by Shared.Close,
+// which must only be called once all hosts have been closed.
+//
+// Hosts register their metrics with the shared registerer, labeled with
+// their peer ID (label "host"), so that each host reports its own series. The
+// metrics of the shared resource manager and DNS resolver are registered once,
+// without a host label.
+// Event buses aren't shared: the events they carry are host specific.
+type Shared struct {
+	rcmgr      network.ResourceManager
+	resolver   *madns.Resolver
+	registerer prometheus.Registerer
+	metrics    bool
+}
+
+type sharedConfig struct {
+	rcmgr          network.ResourceManager
+	dnsOpts        []dnsresolver.Option
+	dnsConfigured  bool
+	registerer     prometheus.Registerer
+	disableMetrics bool
+}
+
+// SharedOption configures the infrastructure constructed by NewShared.
+type SharedOption func(*sharedConfig) error
+
+// SharedResourceManager configures the resource manager shared by all hosts.
+// By default, a resource manager with the default, auto scaled limits is
+// used.
+func SharedResourceManager(mgr network.ResourceManager) SharedOption {
+	return func(cfg *sharedConfig) error {
+		if cfg.rcmgr != nil {
+			return errors.New("cannot configure multiple resource managers")
+		}
+		cfg.rcmgr = mgr
+		return nil
+	}
+}
+
+// SharedDNSResolver configures the caching DNS resolver shared by all hosts.
+// See DNSResolver.
+func SharedDNSResolver(opts ...dnsresolver.Option) SharedOption {
+	return func(cfg *sharedConfig) error {
+		if cfg.dnsConfigured {
+			return errors.New("DNS resolver already configured")
+		}
+		cfg.dnsConfigured = true
+		cfg.dnsOpts = append([]dnsresolver.Option{}, opts...)
+		return nil
+	}
+}
+
+// SharedPrometheusRegisterer configures the registerer all hosts register
+// their metrics with. It defaults to prometheus.DefaultRegisterer.
+func SharedPrometheusRegisterer(reg prometheus.Registerer) SharedOption {
+	return func(cfg *sharedConfig) error {
+		if cfg.disableMetrics {
+			return errors.New("cannot set registerer when metrics are disabled")
+		}
+		if cfg.registerer != nil {
+			return errors.New("registerer already set")
+		}
+		if reg == nil {
+			return errors.New("registerer cannot be nil")
+		}
+		cfg.registerer = reg
+		return nil
+	}
+}
+
+// SharedDisableMetrics disables metrics for the shared infrastructure and for
+// all hosts constructed with Shared.Option.
+func SharedDisableMetrics() SharedOption {
+	return func(cfg *sharedConfig) error {
+		if cfg.registerer != nil {
+			return errors.New("cannot disable metrics when a registerer is set")
+		}
+		cfg.disableMetrics = true
+		return nil
+	}
+}
+
+// NewShared constructs infrastructure to be shared by multiple hosts.
+func NewShared(opts ...SharedOption) (*Shared, error) {
+	var cfg sharedConfig
+	for _, opt := range opts {
+		if err := opt(&cfg); err != nil {
+			return nil, err
+		}
+	}
+	s := &Shared{metrics: !cfg.disableMetrics}
+	if s.metrics {
+		s.registerer = cfg.registerer
+		if s.registerer == nil {
+			s.registerer = prometheus.DefaultRegisterer
+		}
+	}
+
+	mgr := cfg.rcmgr
+	if mgr == nil {
+		limits := rcmgr.DefaultLimits
+		SetDefaultServiceLimits(&limits)
+		var rcmgrOpts []rcmgr.Option
+		if s.metrics {
+			str, err := rcmgr.NewStatsTraceReporter()
+			if err != nil {
+				return nil, err
+			}
+			rcmgrOpts = append(rcmgrOpts, rcmgr.WithTraceReporter(str))
+		}
+		var err error
+		mgr, err = rcmgr.NewResourceManager(rcmgr.NewFixedLimiter(limits.AutoScale()), rcmgrOpts...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create resource manager: %w", err)
+		}
+	}
+	s.rcmgr = newSharedResourceManager(mgr)
+	if s.metrics {
+		rcmgr.MustRegisterWith(s.registerer)
+	}
+
+	var dnsOpts []dnsresolver.Option
+	if s.metrics {
+		dnsOpts = append(dnsOpts, dnsresolver.WithMetricsTracer(dnsresolver.NewMetricsTracer(dnsresolver.WithRegisterer(s.registerer))))
+	}
+	dnsOpts = append(dnsOpts, cfg.dnsOpts...)
+	r, err := dnsresolver.New(dnsOpts...)
+	if err != nil {
+		mgr.Close()
+		return nil, fmt.Errorf("failed to create DNS resolver: %w", err)
+	}
+	s.resolver, err = madns.NewResolver(madns.WithDefaultResolver(r))
+	if err != nil {
+		mgr.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// Option returns an option that configures a host to use the shared
+// infrastructure. It conflicts with the ResourceManager, MultiaddrResolver,
+// DNSResolver and PrometheusRegisterer options.
+func (s *Shared) Option() Option {
+	return func(cfg *Config) error {
+		opts := []Option{ResourceManager(s.rcmgr), MultiaddrResolver(s.resolver)}
+		if !s.metrics {
+			opts = append(opts, DisableMetrics())
+			return cfg.Apply(opts...)
+		}
+		reg := metricshelper.NewLabeledRegisterer(s.registerer, func() (prometheus.Labels, error) {
+			if cfg.PeerKey == nil {
+				return nil, errors.New("no peer key configured")
+			}
+			id, err := peer.IDFromPrivateKey(cfg.PeerKey)
+			if err != nil {
+				return nil, err
+			}
+			return prometheus.Labels{"host": id.String()}, nil
+		})
+		// The resource manager and DNS resolver metrics are registered by NewShared.
+		opts = append(opts, WithMetrics(reg, MetricsSubsystems(
+			MetricsSwarm,
+			MetricsIdentify,
+			MetricsAutoNAT,
+			MetricsRelayService,
+			MetricsAutoRelay,
+			MetricsHolePunch,
+			MetricsEventBus,
+			MetricsConnManager,
+			MetricsStreamLimiter,
+			MetricsUpgrader,
+		)))
+		return cfg.Apply(opts...)
+	}
+}
+
+// ResourceManager returns the shared resource manager.
+func (s *Shared) ResourceManager() network.ResourceManager {
+	return s.rcmgr
+}
+
+// Close closes the shared resource manager. It must only be called once all
+// hosts using the shared infrastructure have been closed.
+func (s *Shared) Close() error {
+	return s.rcmgr.(interface{ closeShared() error }).closeShared()
+}
+
+// sharedResourceManager wraps a resource manager shared by multiple hosts.
+// Hosts close their resource manager when they're closed, so Close is a no-op.
+type sharedResourceManager struct {
+	network.ResourceManager
+}
+
+func newSharedResourceManager(mgr network.ResourceManager) network.ResourceManager {
+	s := &sharedResourceManager{ResourceManager: mgr}
+	if r, ok := mgr.(rcmgrExtensions); ok {
+		return &sharedRcmgrResourceManager{s, r}
+	}
+	if r, ok := mgr.(rcmgr.ResourceManagerUsageReporter); ok {
+		return &sharedUsageReportingResourceManager{s, r}
+	}
+	return s
+}
+
+func (s *sharedResourceManager) Close() error { return nil }
+
+func (s *sharedResourceManager) closeShared() error { return s.ResourceManager.Close() }
+
+// GetConnLimit returns the connection limit of the shared resource manager,
+// so that the connection manager limits of each host can be checked against
+// it.
+func (s *sharedResourceManager) GetConnLimit() int {
+	if l, ok := s.ResourceManager.(interface{ GetConnLimit() int }); ok {
+		return l.GetConnLimit()
+	}
+	return math.MaxInt
+}
+
+// sharedUsageReportingResourceManager is a sharedResourceManager that also
+// reports the usage of the wrapped resource manager.
+type sharedUsageReportingResourceManager struct {
+	*sharedResourceManager
+	rcmgr.ResourceManagerUsageReporter
+}
+
+var _ rcmgr.ResourceManagerUsageReporter = (*sharedUsageReportingResourceManager)(nil)
+
+// rcmgrExtensions are the extension interfaces implemented by the rcmgr
+// resource manager.
+type rcmgrExtensions interface {
+	rcmgr.ResourceManagerUsageReporter
+	rcmgr.ResourceManagerState
+	rcmgr.ResourceManagerLimitUpdater
+	rcmgr.ResourceManagerAllowlister
+	rcmgr.ResourceManagerEventEmitter
+}
+
+// sharedRcmgrResourceManager is a sharedResourceManager that also forwards the
+// extension interfaces of the rcmgr resource manager, so that its limits and
+// allowlist can be updated through any of the hosts.
+type sharedRcmgrResourceManager struct {
+	*sharedResourceManager
+	rcmgrExtensions
+}
+
+var _ rcmgrExtensions = (*sharedRcmgrResourceManager)(nil)